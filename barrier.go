@@ -3,8 +3,11 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
 )
 
 // BarrierStage synchronizes multiple upstream branches and waits for all to complete
@@ -30,18 +33,45 @@ func (bs *BarrierStage) Name() string {
 // Process implements the Stage interface
 // It waits for all upstream branches to complete and emits a single DoneEvent
 func (bs *BarrierStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
-	defer close(output)
+	strategy := bs.config.MergeStrategy
+	if strategy == core.MergeStrategyReduce && bs.config.Reduce == nil {
+		return fmt.Errorf("barrier %s: reduce merge strategy requires a Reduce function", bs.name)
+	}
 
 	// Collect events from all upstream branches
 	doneCount := 0
 	var firstError error
 	errorOccurred := false
+	var merged core.DoneEvent
+	reduce := core.MergeDone
+	if strategy == core.MergeStrategyReduce {
+		reduce = bs.config.Reduce
+	}
+
+	// For last-only, keep the latest event per branch keyed by origin stage,
+	// remembering the order in which branches were first seen
+	lastEvents := make(map[string]core.Event)
+	var branchOrder []string
 
-	for event := range input {
+	// The timeout starts with the first DoneEvent, so it only bounds the wait
+	// for slow branches, not the whole turn
+	var timeout <-chan time.Time
+	timedOut := false
+
+collect:
+	for {
+		var event core.Event
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
+		case <-timeout:
+			timedOut = true
+			break collect
+		case e, ok := <-input:
+			if !ok {
+				break collect
+			}
+			event = e
 		}
 
 		// Check if this is an error event
@@ -61,9 +91,26 @@ func (bs *BarrierStage) Process(ctx context.Context, input <-chan core.Event, ou
 		}
 
 		// Check if this is a DoneEvent
-		if _, ok := event.(core.DoneEvent); ok {
+		if doneEvent, ok := event.(core.DoneEvent); ok {
 			doneCount++
-			// Don't collect DoneEvents yet - we'll emit a single one at the end
+			// Fold branch metrics now - we'll emit a single DoneEvent at the end
+			merged = reduce(merged, doneEvent)
+			if doneCount == bs.config.UpstreamCount {
+				timeout = nil
+			} else if doneCount == 1 && bs.config.Timeout > 0 {
+				timer := time.NewTimer(bs.config.Timeout)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+			continue
+		}
+
+		if strategy == core.MergeStrategyLastOnly {
+			branch := core.MetaOf(event).Origin
+			if _, seen := lastEvents[branch]; !seen {
+				branchOrder = append(branchOrder, branch)
+			}
+			lastEvents[branch] = event
 			continue
 		}
 
@@ -88,22 +135,36 @@ func (bs *BarrierStage) Process(ctx context.Context, input <-chan core.Event, ou
 	}
 
 	// Verify we received DoneEvents from all upstream branches
-	if doneCount != bs.config.UpstreamCount {
+	if timedOut {
+		warning := i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgBarrierTimeout, map[string]string{
+			"barrier": bs.name,
+			"timeout": bs.config.Timeout.String(),
+			"missing": strconv.Itoa(bs.config.UpstreamCount - doneCount),
+			"total":   strconv.Itoa(bs.config.UpstreamCount),
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- warning:
+		}
+	} else if doneCount != bs.config.UpstreamCount {
 		return fmt.Errorf("barrier expected %d DoneEvents, got %d", bs.config.UpstreamCount, doneCount)
 	}
 
-	// Emit a single consolidated DoneEvent
-	consolidatedDone := core.DoneEvent{
-		FullText:      "",
-		TokensUsed:    0,
-		AudioDuration: 0,
-		ActionsCount:  0,
+	// Emit the final event of each branch
+	for _, branch := range branchOrder {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- lastEvents[branch]:
+		}
 	}
 
+	// Emit a single consolidated DoneEvent
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case output <- consolidatedDone:
+	case output <- merged:
 	}
 
 	return nil
@@ -117,14 +178,6 @@ func (bs *BarrierStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the output event types this stage produces
 func (bs *BarrierStage) OutputTypes() []core.EventType {
-	// Barrier produces all event types it receives plus DoneEvent
-	return []core.EventType{
-		core.EventTypeStatus,
-		core.EventTypeSTT,
-		core.EventTypeLLM,
-		core.EventTypeAudio,
-		core.EventTypeAction,
-		core.EventTypeError,
-		core.EventTypeDone,
-	}
+	// Barrier forwards every event type it receives, plus its DoneEvent
+	return []core.EventType{}
 }
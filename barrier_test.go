@@ -44,6 +44,8 @@ func TestBarrierBasicSynchronization(t *testing.T) {
 
 	// Collect output events
 	var outputEvents []core.Event
+	// The runner closes stage output channels
+	close(output)
 	for event := range output {
 		outputEvents = append(outputEvents, event)
 	}
@@ -128,6 +130,8 @@ func TestBarrierCollectsEvents(t *testing.T) {
 
 	// Collect output events
 	var outputEvents []core.Event
+	// The runner closes stage output channels
+	close(output)
 	for event := range output {
 		outputEvents = append(outputEvents, event)
 	}
@@ -197,6 +201,8 @@ func TestBarrierConsolidatesDoneEvents(t *testing.T) {
 
 	// Collect output events
 	var outputEvents []core.Event
+	// The runner closes stage output channels
+	close(output)
 	for event := range output {
 		outputEvents = append(outputEvents, event)
 	}
@@ -214,6 +220,158 @@ func TestBarrierConsolidatesDoneEvents(t *testing.T) {
 	}
 }
 
+// TestBarrierMergesDoneMetrics tests that the consolidated DoneEvent carries the summed branch metrics
+func TestBarrierMergesDoneMetrics(t *testing.T) {
+	config := &core.BarrierConfig{
+		UpstreamCount: 3,
+		MergeStrategy: core.MergeStrategyCollect,
+	}
+
+	barrier := NewBarrierStage("barrier", config)
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+
+	input <- core.DoneEvent{FullText: "hello", TokensUsed: 12}
+	input <- core.DoneEvent{FullText: "hello", AudioDuration: 1.5}
+	input <- core.DoneEvent{FullText: "world", ActionsCount: 2, Interrupted: true}
+	close(input)
+
+	if err := barrier.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("barrier process failed: %v", err)
+	}
+
+	var done *core.DoneEvent
+	// The runner closes stage output channels
+	close(output)
+	for event := range output {
+		if e, ok := event.(core.DoneEvent); ok {
+			done = &e
+		}
+	}
+
+	if done == nil {
+		t.Fatal("missing consolidated DoneEvent")
+	}
+	if done.TokensUsed != 12 || done.ActionsCount != 2 || done.AudioDuration != 1.5 {
+		t.Errorf("unexpected metrics: %+v", *done)
+	}
+	if done.FullText != "hello\nworld" {
+		t.Errorf("expected merged text %q, got %q", "hello\nworld", done.FullText)
+	}
+	if !done.Interrupted {
+		t.Error("expected consolidated DoneEvent to be interrupted")
+	}
+}
+
+// TestBarrierLastOnly tests that last-only emits the final event from each branch
+func TestBarrierLastOnly(t *testing.T) {
+	config := &core.BarrierConfig{
+		UpstreamCount: 2,
+		MergeStrategy: core.MergeStrategyLastOnly,
+	}
+
+	barrier := NewBarrierStage("barrier", config)
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+
+	llm := core.EventMeta{Origin: "llm"}
+	tts := core.EventMeta{Origin: "tts"}
+	input <- core.LLMEvent{Delta: "a", Content: "a", Meta: llm}
+	input <- core.AudioEvent{Data: []byte{1}, Meta: tts}
+	input <- core.LLMEvent{Delta: "b", Content: "ab", Meta: llm}
+	input <- core.DoneEvent{}
+	input <- core.AudioEvent{Data: []byte{2}, Meta: tts}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := barrier.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("barrier process failed: %v", err)
+	}
+
+	var outputEvents []core.Event
+	// The runner closes stage output channels
+	close(output)
+	for event := range output {
+		outputEvents = append(outputEvents, event)
+	}
+
+	if len(outputEvents) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(outputEvents))
+	}
+	if e, ok := outputEvents[0].(core.LLMEvent); !ok || e.Content != "ab" {
+		t.Errorf("expected last LLM event first, got %v", outputEvents[0])
+	}
+	if e, ok := outputEvents[1].(core.AudioEvent); !ok || e.Data[0] != 2 {
+		t.Errorf("expected last audio event second, got %v", outputEvents[1])
+	}
+	if _, ok := outputEvents[2].(core.DoneEvent); !ok {
+		t.Error("last event should be DoneEvent")
+	}
+}
+
+// TestBarrierReduce tests that reduce folds branch DoneEvents with the user function
+func TestBarrierReduce(t *testing.T) {
+	config := &core.BarrierConfig{
+		UpstreamCount: 2,
+		MergeStrategy: core.MergeStrategyReduce,
+		Reduce: func(acc, next core.DoneEvent) core.DoneEvent {
+			acc.TokensUsed += next.TokensUsed
+			acc.FullText += next.FullText
+			return acc
+		},
+	}
+
+	barrier := NewBarrierStage("barrier", config)
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+
+	input <- core.LLMEvent{Delta: "hi"}
+	input <- core.DoneEvent{FullText: "hello ", TokensUsed: 3}
+	input <- core.DoneEvent{FullText: "world", TokensUsed: 4}
+	close(input)
+
+	if err := barrier.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("barrier process failed: %v", err)
+	}
+
+	var done *core.DoneEvent
+	var forwarded int
+	// The runner closes stage output channels
+	close(output)
+	for event := range output {
+		if e, ok := event.(core.DoneEvent); ok {
+			done = &e
+			continue
+		}
+		forwarded++
+	}
+
+	if forwarded != 1 {
+		t.Errorf("expected 1 forwarded event, got %d", forwarded)
+	}
+	if done == nil || done.TokensUsed != 7 || done.FullText != "hello world" {
+		t.Errorf("unexpected reduced DoneEvent: %+v", done)
+	}
+}
+
+// TestBarrierReduceRequiresFunction tests that reduce without a function fails
+func TestBarrierReduceRequiresFunction(t *testing.T) {
+	barrier := NewBarrierStage("barrier", &core.BarrierConfig{
+		UpstreamCount: 1,
+		MergeStrategy: core.MergeStrategyReduce,
+	})
+
+	input := make(chan core.Event)
+	close(input)
+
+	if err := barrier.Process(context.Background(), input, make(chan core.Event, 1)); err == nil {
+		t.Error("expected error for missing Reduce function")
+	}
+}
+
 // TestBarrierMissingDoneEvent tests that barrier fails if not all branches send DoneEvent
 func TestBarrierMissingDoneEvent(t *testing.T) {
 	config := &core.BarrierConfig{
@@ -241,6 +399,49 @@ func TestBarrierMissingDoneEvent(t *testing.T) {
 	}
 }
 
+// TestBarrierTimeoutEmitsPartialResults tests that a barrier stops waiting
+// for a slow branch after the timeout and emits what it has with a warning
+func TestBarrierTimeoutEmitsPartialResults(t *testing.T) {
+	config := &core.BarrierConfig{
+		UpstreamCount: 2,
+		MergeStrategy: core.MergeStrategyCollect,
+		Timeout:       50 * time.Millisecond,
+	}
+
+	barrier := NewBarrierStage("barrier", config)
+
+	// The slow branch never finishes and the input stays open
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "hello"}
+	input <- core.DoneEvent{FullText: "hello"}
+
+	start := time.Now()
+	if err := barrier.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("expected partial results instead of error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("barrier waited %v despite the timeout", elapsed)
+	}
+
+	var outputEvents []core.Event
+	// The runner closes stage output channels
+	close(output)
+	for event := range output {
+		outputEvents = append(outputEvents, event)
+	}
+	if len(outputEvents) != 3 {
+		t.Fatalf("expected LLM, warning and Done events, got %v", outputEvents)
+	}
+	warning, ok := outputEvents[1].(core.ServiceMessageEvent)
+	if !ok || warning.MessageType != core.ServiceMessageWarning {
+		t.Errorf("expected warning ServiceMessageEvent, got %#v", outputEvents[1])
+	}
+	if done, ok := outputEvents[2].(core.DoneEvent); !ok || done.FullText != "hello" {
+		t.Errorf("expected DoneEvent with partial text, got %#v", outputEvents[2])
+	}
+}
+
 // TestBarrierContextCancellation tests that barrier respects context cancellation
 func TestBarrierContextCancellation(t *testing.T) {
 	config := &core.BarrierConfig{
@@ -306,6 +507,8 @@ func TestPropertyBarrierWaitsForAllUpstream(t *testing.T) {
 
 		// Collect output events
 		var outputEvents []core.Event
+		// The runner closes stage output channels
+		close(output)
 		for event := range output {
 			outputEvents = append(outputEvents, event)
 		}
@@ -365,6 +568,8 @@ func TestPropertyBarrierCollectsFromAllBranches(t *testing.T) {
 
 		// Collect output events
 		var outputEvents []core.Event
+		// The runner closes stage output channels
+		close(output)
 		for event := range output {
 			outputEvents = append(outputEvents, event)
 		}
@@ -458,6 +663,8 @@ func TestPropertyBarrierConsolidatesDoneEvents(t *testing.T) {
 
 		// Collect output events
 		var outputEvents []core.Event
+		// The runner closes stage output channels
+		close(output)
 		for event := range output {
 			outputEvents = append(outputEvents, event)
 		}
@@ -475,3 +682,21 @@ func TestPropertyBarrierConsolidatesDoneEvents(t *testing.T) {
 		}
 	})
 }
+
+// TestBarrierStageForwardsAllTypes tests that validation sees a barrier pass
+// on every event type, such as citations for a sink downstream
+func TestBarrierStageForwardsAllTypes(t *testing.T) {
+	citations := []core.EventType{core.EventTypeCitation}
+	_, err := NewBuilder().
+		AddStage("rag", &MockStage{name: "rag", outputTypes: append(citations, core.EventTypeDone)}).
+		AddStage("barrier", NewBarrierStage("barrier", &core.BarrierConfig{UpstreamCount: 1})).
+		AddStage("sink", &MockStage{name: "sink", inputTypes: citations}).
+		Connect("rag", "barrier").
+		Connect("barrier", "sink").
+		SetEntryNode("rag").
+		AddExitNode("sink").
+		Build()
+	if err != nil {
+		t.Errorf("expected citations to pass the barrier, got %v", err)
+	}
+}
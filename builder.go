@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/creastat/pipeline/core"
 )
@@ -13,6 +14,14 @@ type GraphBuilder struct {
 	edges       []edgeConfig
 	entryNode   string
 	exitNodes   []string
+	tracer      Tracer
+	deadLetters DeadLetterHandler
+	timeouts    map[string]time.Duration
+	panics      map[string]PanicPolicy
+	taps        map[string][]TapFunc
+	bufferSize  int
+	nodeBuffers map[string]int
+	edgeBuffers map[[2]string]int
 }
 
 // nodeConfig holds configuration for a node
@@ -27,6 +36,7 @@ type edgeConfig struct {
 	from        string
 	to          string
 	eventFilter []core.EventType
+	predicate   EdgePredicate
 }
 
 // NewBuilder creates a new graph-based pipeline builder
@@ -36,9 +46,39 @@ func NewBuilder() *GraphBuilder {
 		nodeConfigs: make(map[string]*nodeConfig),
 		edges:       make([]edgeConfig, 0),
 		exitNodes:   make([]string, 0),
+		timeouts:    make(map[string]time.Duration),
+		panics:      make(map[string]PanicPolicy),
+		taps:        make(map[string][]TapFunc),
+		nodeBuffers: make(map[string]int),
+		edgeBuffers: make(map[[2]string]int),
 	}
 }
 
+// Linear builds a pipeline that chains stages in order, each node named after
+// its stage. The first stage is the entry node and the last the exit node.
+func Linear(stages ...core.Stage) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("pipeline must have at least one stage")
+	}
+
+	b := NewBuilder()
+	for i, stage := range stages {
+		name := stage.Name()
+		if _, exists := b.nodeConfigs[name]; exists {
+			return nil, fmt.Errorf("duplicate stage name %q", name)
+		}
+		b.AddStage(name, stage)
+		if i > 0 {
+			b.Connect(stages[i-1].Name(), name)
+		}
+	}
+
+	return b.
+		SetEntryNode(stages[0].Name()).
+		AddExitNode(stages[len(stages)-1].Name()).
+		Build()
+}
+
 // AddStage adds a stage node to the pipeline
 func (b *GraphBuilder) AddStage(name string, stage core.Stage) *GraphBuilder {
 	b.nodeConfigs[name] = &nodeConfig{
@@ -49,7 +89,9 @@ func (b *GraphBuilder) AddStage(name string, stage core.Stage) *GraphBuilder {
 	return b
 }
 
-// AddFanOut adds a fan-out node that routes to multiple branches
+// AddFanOut adds a fan-out node that routes to multiple branches. Its
+// FanOutStage is kept across runs; Pipeline.FanOut returns it to add and
+// remove branches at runtime.
 func (b *GraphBuilder) AddFanOut(name string, config core.FanOutConfig) *GraphBuilder {
 	// Create a synthetic stage for the fan-out node
 	// The fan-out node itself doesn't process events, it just routes them
@@ -73,6 +115,23 @@ func (b *GraphBuilder) AddBarrier(name string, config core.BarrierConfig) *Graph
 	return b
 }
 
+// AddLoop adds a LoopStage node that runs config.Body iteratively, for
+// cycles such as LLM -> tool -> LLM that the graph itself rejects
+func (b *GraphBuilder) AddLoop(name string, config LoopConfig) *GraphBuilder {
+	return b.AddStage(name, NewLoopStage(name, config))
+}
+
+// AddRouter adds a RouterStage node and connects it to each of its branches,
+// which must be added as nodes with the branch names
+func (b *GraphBuilder) AddRouter(name string, routes []Route, defaultBranch string) *GraphBuilder {
+	router := NewRouterStage(name, routes, defaultBranch)
+	b.AddStage(name, router)
+	for _, branch := range router.Branches() {
+		b.ConnectIf(name, branch, router.Branch(branch))
+	}
+	return b
+}
+
 // Connect creates an edge from one node to another with optional event filtering
 func (b *GraphBuilder) Connect(from, to string, eventFilter ...core.EventType) *GraphBuilder {
 	b.edges = append(b.edges, edgeConfig{
@@ -83,6 +142,18 @@ func (b *GraphBuilder) Connect(from, to string, eventFilter ...core.EventType) *
 	return b
 }
 
+// ConnectIf creates an edge that forwards only events accepted by the predicate,
+// optionally restricted to the given event types
+func (b *GraphBuilder) ConnectIf(from, to string, predicate EdgePredicate, eventFilter ...core.EventType) *GraphBuilder {
+	b.edges = append(b.edges, edgeConfig{
+		from:        from,
+		to:          to,
+		eventFilter: eventFilter,
+		predicate:   predicate,
+	})
+	return b
+}
+
 // SetErrorPolicy sets the error policy for a fan-out node
 func (b *GraphBuilder) SetErrorPolicy(nodeName string, policy core.ErrorPolicy) *GraphBuilder {
 	if config, exists := b.nodeConfigs[nodeName]; exists && config.fanOut != nil {
@@ -103,6 +174,63 @@ func (b *GraphBuilder) AddExitNode(name string) *GraphBuilder {
 	return b
 }
 
+// WithDeadLetterHandler sets the handler that receives events the pipeline
+// drops or fails to deliver
+func (b *GraphBuilder) WithDeadLetterHandler(handler DeadLetterHandler) *GraphBuilder {
+	b.deadLetters = handler
+	return b
+}
+
+// WithTracer sets the tracer used to create pipeline and stage spans
+func (b *GraphBuilder) WithTracer(tracer Tracer) *GraphBuilder {
+	b.tracer = tracer
+	return b
+}
+
+// WithStageTimeout sets a deadline for a stage; if the stage doesn't finish in
+// time it fails with a core.StageTimeoutError instead of stalling the pipeline
+func (b *GraphBuilder) WithStageTimeout(nodeName string, timeout time.Duration) *GraphBuilder {
+	b.timeouts[nodeName] = timeout
+	return b
+}
+
+// WithPanicPolicy sets what happens when a node's stage panics, e.g.
+// PanicIsolate for a non-critical branch. The default is PanicPropagate.
+func (b *GraphBuilder) WithPanicPolicy(nodeName string, policy PanicPolicy) *GraphBuilder {
+	b.panics[nodeName] = policy
+	return b
+}
+
+// Tap attaches an observer that receives copies of every event the node
+// emits, for logging or inspection. Taps don't alter routing or apply
+// backpressure: a tap that falls behind misses events.
+func (b *GraphBuilder) Tap(nodeName string, fn TapFunc) *GraphBuilder {
+	b.taps[nodeName] = append(b.taps[nodeName], fn)
+	return b
+}
+
+// WithBufferSize sets the default capacity of the channels between stages and
+// of the pipeline output (default: DefaultBufferSize)
+func (b *GraphBuilder) WithBufferSize(size int) *GraphBuilder {
+	b.bufferSize = size
+	return b
+}
+
+// WithNodeBufferSize sets the capacity of a node's input and output channels,
+// e.g. larger for audio-heavy stages than for status-only ones
+func (b *GraphBuilder) WithNodeBufferSize(nodeName string, size int) *GraphBuilder {
+	b.nodeBuffers[nodeName] = size
+	return b
+}
+
+// WithEdgeBufferSize sets the buffer for events sent from one node to another.
+// Edges into a node share its input channel, which is sized to fit the
+// largest of them.
+func (b *GraphBuilder) WithEdgeBufferSize(from, to string, size int) *GraphBuilder {
+	b.edgeBuffers[[2]string{from, to}] = size
+	return b
+}
+
 // Build creates and validates the pipeline graph
 func (b *GraphBuilder) Build() (*Pipeline, error) {
 	// Validate that we have at least one node
@@ -124,11 +252,56 @@ func (b *GraphBuilder) Build() (*Pipeline, error) {
 
 	// Add all edges to the graph
 	for _, edge := range b.edges {
-		if err := b.graph.AddEdge(edge.from, edge.to, edge.eventFilter); err != nil {
+		if err := b.graph.AddPredicateEdge(edge.from, edge.to, edge.eventFilter, edge.predicate); err != nil {
 			return nil, fmt.Errorf("failed to add edge from %q to %q: %w", edge.from, edge.to, err)
 		}
 	}
 
+	// Infer barrier upstream counts that weren't set explicitly
+	for _, node := range b.graph.AllNodes() {
+		if barrier := barrierConfig(node); barrier != nil && barrier.UpstreamCount == 0 {
+			barrier.UpstreamCount = barrierUpstreams(node)
+		}
+	}
+
+	// Apply stage timeouts
+	for name, timeout := range b.timeouts {
+		if err := b.graph.SetStageTimeout(name, timeout); err != nil {
+			return nil, fmt.Errorf("failed to set timeout: %w", err)
+		}
+	}
+
+	// Apply panic policies
+	for name, policy := range b.panics {
+		if err := b.graph.SetPanicPolicy(name, policy); err != nil {
+			return nil, fmt.Errorf("failed to set panic policy: %w", err)
+		}
+	}
+
+	// Apply buffer sizes
+	if b.bufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must not be negative")
+	}
+	for name, size := range b.nodeBuffers {
+		if err := b.graph.SetBufferSize(name, size); err != nil {
+			return nil, fmt.Errorf("failed to set buffer size: %w", err)
+		}
+	}
+	for edge, size := range b.edgeBuffers {
+		if err := b.graph.SetEdgeBufferSize(edge[0], edge[1], size); err != nil {
+			return nil, fmt.Errorf("failed to set buffer size: %w", err)
+		}
+	}
+
+	// Attach taps
+	for name, taps := range b.taps {
+		for _, fn := range taps {
+			if err := b.graph.AddTap(name, fn); err != nil {
+				return nil, fmt.Errorf("failed to add tap: %w", err)
+			}
+		}
+	}
+
 	// Set entry node
 	if err := b.graph.SetEntryNode(b.entryNode); err != nil {
 		return nil, fmt.Errorf("failed to set entry node: %w", err)
@@ -142,12 +315,22 @@ func (b *GraphBuilder) Build() (*Pipeline, error) {
 	}
 
 	// Validate the graph structure
-	if err := ValidateGraph(b.graph); err != nil {
+	report := ValidateGraphDetailed(b.graph)
+	if err := report.Err(); err != nil {
 		return nil, fmt.Errorf("graph validation failed: %w", err)
 	}
 
 	// Create and return the pipeline
+	bufferSize := b.bufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultBufferSize
+	}
 	return &Pipeline{
-		graph: b.graph,
+		graph:       b.graph,
+		tracer:      b.tracer,
+		metrics:     newPipelineMetrics(),
+		deadLetters: b.deadLetters,
+		bufferSize:  bufferSize,
+		warnings:    report.Warnings,
 	}, nil
 }
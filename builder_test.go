@@ -13,7 +13,7 @@ func TestGraphBuilderAddStage(t *testing.T) {
 	stage := &MockStage{
 		name:        "test-stage",
 		inputTypes:  []core.EventType{core.EventTypeSTT},
-		outputTypes: []core.EventType{core.EventTypeLLM},
+		outputTypes: []core.EventType{core.EventTypeLLM, core.EventTypeDone},
 	}
 	
 	builder.AddStage("stage1", stage)
@@ -226,7 +226,7 @@ func TestGraphBuilderSetErrorPolicy(t *testing.T) {
 	
 	stage1 := &MockStage{
 		name:        "stage1",
-		outputTypes: []core.EventType{core.EventTypeSTT},
+		outputTypes: []core.EventType{core.EventTypeSTT, core.EventTypeDone},
 	}
 	
 	fanOutConfig := core.FanOutConfig{
@@ -250,3 +250,73 @@ func TestGraphBuilderSetErrorPolicy(t *testing.T) {
 		t.Fatal("Pipeline is nil")
 	}
 }
+
+// TestGraphBuilderBufferSizes tests the default, per-node and per-edge
+// channel capacities
+func TestGraphBuilderBufferSizes(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("source", &MockStage{name: "source"}).
+		AddStage("audio", &MockStage{name: "audio"}).
+		AddStage("status", &MockStage{name: "status"}).
+		Connect("source", "audio").
+		Connect("source", "status").
+		SetEntryNode("source").
+		AddExitNode("audio").
+		AddExitNode("status").
+		WithBufferSize(10).
+		WithNodeBufferSize("audio", 500).
+		WithEdgeBufferSize("source", "status", 50).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	graph := pipeline.Graph()
+	if got := graph.GetNode("source").InputBufferSize(pipeline.bufferSize); got != 10 {
+		t.Errorf("expected default input buffer 10, got %d", got)
+	}
+	if got := graph.GetNode("audio").OutputBufferSize(pipeline.bufferSize); got != 500 {
+		t.Errorf("expected audio output buffer 500, got %d", got)
+	}
+	if got := graph.GetNode("status").InputBufferSize(pipeline.bufferSize); got != 50 {
+		t.Errorf("expected status input buffer raised to 50 by its edge, got %d", got)
+	}
+	if got := graph.GetNode("status").OutputBufferSize(pipeline.bufferSize); got != 10 {
+		t.Errorf("expected status output buffer 10, got %d", got)
+	}
+
+	_, err = NewBuilder().
+		AddStage("source", &MockStage{name: "source"}).
+		SetEntryNode("source").
+		WithEdgeBufferSize("source", "missing", 50).
+		Build()
+	if err == nil {
+		t.Error("expected error for buffer on a missing edge")
+	}
+}
+
+// TestLinear tests chaining stages into a pipeline
+func TestLinear(t *testing.T) {
+	pipeline, err := Linear(&MockStage{name: "first"}, &MockStage{name: "second"}, &MockStage{name: "third"})
+	if err != nil {
+		t.Fatalf("Linear failed: %v", err)
+	}
+
+	graph := pipeline.Graph()
+	if graph.GetEntryNode().Name() != "first" {
+		t.Errorf("expected entry node first, got %q", graph.GetEntryNode().Name())
+	}
+	if exits := graph.GetExitNodes(); len(exits) != 1 || exits[0].Name() != "third" {
+		t.Errorf("expected exit node third, got %v", exits)
+	}
+	if outputs := graph.GetNode("second").Outputs(); len(outputs) != 1 || outputs[0].To().Name() != "third" {
+		t.Errorf("expected second to connect to third")
+	}
+
+	if _, err := Linear(&MockStage{name: "same"}, &MockStage{name: "same"}); err == nil {
+		t.Error("expected error for duplicate stage names")
+	}
+	if _, err := Linear(); err == nil {
+		t.Error("expected error without stages")
+	}
+}
@@ -0,0 +1,337 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Node types supported in declarative pipeline definitions
+const (
+	NodeTypeStage   = "stage"
+	NodeTypeFanOut  = "fanout"
+	NodeTypeBarrier = "barrier"
+)
+
+// PipelineConfig is a declarative description of a pipeline topology
+type PipelineConfig struct {
+	// Entry is the name of the entry node
+	Entry string `json:"entry" yaml:"entry"`
+
+	// Exits are the names of terminal nodes
+	Exits []string `json:"exits" yaml:"exits"`
+
+	// Nodes defines all stage, fan-out, and barrier nodes
+	Nodes []NodeDefinition `json:"nodes" yaml:"nodes"`
+
+	// Edges defines the connections between nodes
+	Edges []EdgeDefinition `json:"edges" yaml:"edges"`
+}
+
+// NodeDefinition describes a single node in a PipelineConfig
+type NodeDefinition struct {
+	// Name is the unique node name used by edges
+	Name string `json:"name" yaml:"name"`
+
+	// Type is one of "stage" (default), "fanout", or "barrier"
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Stage is the registry key of the stage constructor (stage nodes only)
+	Stage string `json:"stage,omitempty" yaml:"stage,omitempty"`
+
+	// Options are passed to the stage constructor
+	Options map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+
+	// FanOut configures a fan-out node
+	FanOut *FanOutDefinition `json:"fanout,omitempty" yaml:"fanout,omitempty"`
+
+	// Barrier configures a barrier node
+	Barrier *BarrierDefinition `json:"barrier,omitempty" yaml:"barrier,omitempty"`
+
+	// PanicPolicy is "propagate" (default), "isolate" or "restart"
+	PanicPolicy string `json:"panicPolicy,omitempty" yaml:"panicPolicy,omitempty"`
+}
+
+// FanOutDefinition describes a fan-out node
+type FanOutDefinition struct {
+	ErrorPolicy string             `json:"errorPolicy,omitempty" yaml:"errorPolicy,omitempty"`
+	MaxRetries  int                `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	Branches    []BranchDefinition `json:"branches" yaml:"branches"`
+}
+
+// BranchDefinition describes a single fan-out branch
+type BranchDefinition struct {
+	Stage   string         `json:"stage" yaml:"stage"`
+	Options map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+	Filter  []string       `json:"filter,omitempty" yaml:"filter,omitempty"`
+
+	BufferSize     int    `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty"`
+	OverflowPolicy string `json:"overflowPolicy,omitempty" yaml:"overflowPolicy,omitempty"` // block, drop or latest-only
+}
+
+// BarrierDefinition describes a barrier node
+type BarrierDefinition struct {
+	UpstreamCount int    `json:"upstreamCount,omitempty" yaml:"upstreamCount,omitempty"` // Zero infers it from incoming edges
+	MergeStrategy string `json:"mergeStrategy,omitempty" yaml:"mergeStrategy,omitempty"`
+	Timeout       string `json:"timeout,omitempty" yaml:"timeout,omitempty"` // Go duration, e.g. "5s"
+}
+
+// EdgeDefinition describes a directed edge with an optional event type filter
+type EdgeDefinition struct {
+	From   string   `json:"from" yaml:"from"`
+	To     string   `json:"to" yaml:"to"`
+	Filter []string `json:"filter,omitempty" yaml:"filter,omitempty"`
+}
+
+// StageConstructor creates a stage from declarative options
+type StageConstructor func(options map[string]any) (core.Stage, error)
+
+// StageRegistry maps stage names used in configs to their constructors
+type StageRegistry struct {
+	mu           sync.RWMutex
+	constructors map[string]StageConstructor
+}
+
+// NewStageRegistry creates an empty stage registry
+func NewStageRegistry() *StageRegistry {
+	return &StageRegistry{
+		constructors: make(map[string]StageConstructor),
+	}
+}
+
+// Register adds a stage constructor under the given name
+func (r *StageRegistry) Register(name string, constructor StageConstructor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.constructors[name]; exists {
+		return fmt.Errorf("stage %q already registered", name)
+	}
+	r.constructors[name] = constructor
+	return nil
+}
+
+// Create instantiates a registered stage with the given options
+func (r *StageRegistry) Create(name string, options map[string]any) (core.Stage, error) {
+	r.mu.RLock()
+	constructor, exists := r.constructors[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("stage %q is not registered", name)
+	}
+
+	stage, err := constructor(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stage %q: %w", name, err)
+	}
+	return stage, nil
+}
+
+// ParseConfig decodes a pipeline definition from JSON or YAML.
+// JSON is detected by a leading '{'; anything else is parsed as YAML.
+func ParseConfig(data []byte) (*PipelineConfig, error) {
+	var config PipelineConfig
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON pipeline config: %w", err)
+		}
+	} else {
+		if err := yaml.Unmarshal(trimmed, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML pipeline config: %w", err)
+		}
+	}
+
+	return &config, nil
+}
+
+// LoadConfig builds a pipeline from a JSON or YAML definition,
+// resolving stage names through the registry
+func LoadConfig(data []byte, registry *StageRegistry) (*Pipeline, error) {
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return BuildFromConfig(config, registry)
+}
+
+// LoadConfigFile reads a pipeline definition from disk and builds it
+func LoadConfigFile(path string, registry *StageRegistry) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline config %q: %w", path, err)
+	}
+	return LoadConfig(data, registry)
+}
+
+// BuildFromConfig builds and validates a pipeline from a parsed definition
+func BuildFromConfig(config *PipelineConfig, registry *StageRegistry) (*Pipeline, error) {
+	if registry == nil {
+		return nil, fmt.Errorf("stage registry must not be nil")
+	}
+
+	builder := NewBuilder()
+
+	for _, node := range config.Nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("node name must not be empty")
+		}
+
+		switch node.Type {
+		case "", NodeTypeStage:
+			stage, err := registry.Create(node.Stage, node.Options)
+			if err != nil {
+				return nil, fmt.Errorf("node %q: %w", node.Name, err)
+			}
+			builder.AddStage(node.Name, stage)
+
+		case NodeTypeFanOut:
+			fanOut, err := buildFanOutConfig(node, registry)
+			if err != nil {
+				return nil, fmt.Errorf("node %q: %w", node.Name, err)
+			}
+			builder.AddFanOut(node.Name, fanOut)
+
+		case NodeTypeBarrier:
+			if node.Barrier == nil {
+				return nil, fmt.Errorf("node %q: barrier configuration is required", node.Name)
+			}
+			var timeout time.Duration
+			if node.Barrier.Timeout != "" {
+				var err error
+				if timeout, err = time.ParseDuration(node.Barrier.Timeout); err != nil {
+					return nil, fmt.Errorf("node %q: invalid barrier timeout: %w", node.Name, err)
+				}
+			}
+			strategy := core.MergeStrategy(node.Barrier.MergeStrategy)
+			switch strategy {
+			case "", core.MergeStrategyCollect, core.MergeStrategyLastOnly, core.MergeStrategyReduce:
+			default:
+				return nil, fmt.Errorf("node %q: unknown merge strategy %q", node.Name, strategy)
+			}
+			builder.AddBarrier(node.Name, core.BarrierConfig{
+				UpstreamCount: node.Barrier.UpstreamCount,
+				MergeStrategy: strategy,
+				Timeout:       timeout,
+			})
+
+		default:
+			return nil, fmt.Errorf("node %q: unknown node type %q", node.Name, node.Type)
+		}
+
+		switch policy := PanicPolicy(node.PanicPolicy); policy {
+		case "":
+		case PanicPropagate, PanicIsolate, PanicRestart:
+			builder.WithPanicPolicy(node.Name, policy)
+		default:
+			return nil, fmt.Errorf("node %q: unknown panic policy %q", node.Name, policy)
+		}
+	}
+
+	for _, edge := range config.Edges {
+		filter, err := parseEventTypes(edge.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("edge %q -> %q: %w", edge.From, edge.To, err)
+		}
+		builder.Connect(edge.From, edge.To, filter...)
+	}
+
+	builder.SetEntryNode(config.Entry)
+	for _, exit := range config.Exits {
+		builder.AddExitNode(exit)
+	}
+
+	return builder.Build()
+}
+
+// buildFanOutConfig resolves a fan-out definition into a core.FanOutConfig
+func buildFanOutConfig(node NodeDefinition, registry *StageRegistry) (core.FanOutConfig, error) {
+	if node.FanOut == nil {
+		return core.FanOutConfig{}, fmt.Errorf("fanout configuration is required")
+	}
+
+	config := core.FanOutConfig{
+		ErrorPolicy: core.ErrorPolicy(node.FanOut.ErrorPolicy),
+		MaxRetries:  node.FanOut.MaxRetries,
+	}
+	switch config.ErrorPolicy {
+	case "":
+		config.ErrorPolicy = core.ErrorPolicyCancelAll
+	case core.ErrorPolicyCancelAll, core.ErrorPolicyIsolated, core.ErrorPolicyRetryBranch:
+	default:
+		return core.FanOutConfig{}, fmt.Errorf("unknown error policy %q", config.ErrorPolicy)
+	}
+
+	for i, branch := range node.FanOut.Branches {
+		stage, err := registry.Create(branch.Stage, branch.Options)
+		if err != nil {
+			return core.FanOutConfig{}, fmt.Errorf("branch %d: %w", i, err)
+		}
+		filter, err := parseEventTypes(branch.Filter)
+		if err != nil {
+			return core.FanOutConfig{}, fmt.Errorf("branch %d: %w", i, err)
+		}
+		policy := core.OverflowPolicy(branch.OverflowPolicy)
+		switch policy {
+		case "", core.OverflowBlock, core.OverflowDrop, core.OverflowLatestOnly:
+		default:
+			return core.FanOutConfig{}, fmt.Errorf("branch %d: unknown overflow policy %q", i, policy)
+		}
+		config.Branches = append(config.Branches, core.BranchConfig{
+			Stage:          stage,
+			EventFilter:    filter,
+			BufferSize:     branch.BufferSize,
+			OverflowPolicy: policy,
+		})
+	}
+
+	return config, nil
+}
+
+// knownEventTypes lists event types accepted in declarative filters
+var knownEventTypes = map[core.EventType]bool{
+	core.EventTypeStatus:         true,
+	core.EventTypeSTT:            true,
+	core.EventTypeLLM:            true,
+	core.EventTypeAudio:          true,
+	core.EventTypeAction:         true,
+	core.EventTypeError:          true,
+	core.EventTypeDone:           true,
+	core.EventTypeServiceMessage: true,
+	core.EventTypeInterrupt:      true,
+	core.EventTypeCitation:       true,
+	core.EventTypeUsage:          true,
+	core.EventTypeLanguage:       true,
+	core.EventTypeConfig:         true,
+	core.EventTypeCancelled:      true,
+	core.EventTypeConnectionLost: true,
+	core.EventTypeBatch:          true,
+	core.EventTypeLatency:        true,
+	core.EventTypeRawText:        true,
+	core.EventTypeActionBlock:    true,
+	core.EventTypeActionResult:   true,
+	core.EventTypeToolCall:       true,
+	core.EventTypeToolStart:      true,
+	core.EventTypeToolResult:     true,
+}
+
+// parseEventTypes converts filter names to event types, rejecting unknown names
+func parseEventTypes(names []string) ([]core.EventType, error) {
+	types := make([]core.EventType, 0, len(names))
+	for _, name := range names {
+		eventType := core.EventType(name)
+		if !knownEventTypes[eventType] {
+			return nil, fmt.Errorf("unknown event type %q in filter", name)
+		}
+		types = append(types, eventType)
+	}
+	return types, nil
+}
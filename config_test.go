@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// newTestRegistry creates a registry with a generic mock stage constructor
+func newTestRegistry(t *testing.T) *StageRegistry {
+	registry := NewStageRegistry()
+	err := registry.Register("mock", func(options map[string]any) (core.Stage, error) {
+		name, _ := options["name"].(string)
+		return &MockStage{name: name}, nil
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return registry
+}
+
+// TestLoadConfigJSON tests building a pipeline from a JSON definition
+func TestLoadConfigJSON(t *testing.T) {
+	data := []byte(`{
+		"entry": "stt",
+		"exits": ["sink"],
+		"nodes": [
+			{"name": "stt", "stage": "mock", "options": {"name": "stt"}},
+			{"name": "llm", "stage": "mock"},
+			{"name": "join", "type": "barrier", "barrier": {"upstreamCount": 1, "mergeStrategy": "collect"}},
+			{"name": "sink", "stage": "mock"}
+		],
+		"edges": [
+			{"from": "stt", "to": "llm", "filter": ["llm", "done"]},
+			{"from": "llm", "to": "join"},
+			{"from": "join", "to": "sink"}
+		]
+	}`)
+
+	pipeline, err := LoadConfig(data, newTestRegistry(t))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if entry := pipeline.graph.GetEntryNode(); entry == nil || entry.Name() != "stt" {
+		t.Fatalf("expected entry node 'stt'")
+	}
+
+	edges := pipeline.graph.GetNode("stt").Outputs()
+	if len(edges) != 1 || !edges[0].ShouldForwardEvent(core.EventTypeDone) || edges[0].ShouldForwardEvent(core.EventTypeSTT) {
+		t.Errorf("edge filter not applied correctly")
+	}
+
+	barrier := pipeline.graph.GetNode("join").Barrier()
+	if barrier == nil || barrier.UpstreamCount != 1 {
+		t.Errorf("barrier config not applied correctly")
+	}
+}
+
+// TestLoadConfigYAML tests building a pipeline from a YAML definition
+func TestLoadConfigYAML(t *testing.T) {
+	data := []byte(`
+entry: in
+exits: [out]
+nodes:
+  - name: in
+    stage: mock
+  - name: split
+    type: fanout
+    fanout:
+      errorPolicy: isolated
+      branches:
+        - stage: mock
+          filter: [llm]
+  - name: out
+    stage: mock
+edges:
+  - from: in
+    to: split
+  - from: split
+    to: out
+`)
+
+	pipeline, err := LoadConfig(data, newTestRegistry(t))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	fanOut := pipeline.graph.GetNode("split").FanOut()
+	if fanOut == nil || fanOut.ErrorPolicy != core.ErrorPolicyIsolated || len(fanOut.Branches) != 1 {
+		t.Fatalf("fan-out config not applied correctly")
+	}
+}
+
+// TestLoadConfigErrors tests that invalid definitions are rejected
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "unknown stage",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "stage": "missing"}]}`,
+			wantErr: "not registered",
+		},
+		{
+			name:    "unknown node type",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "type": "loop"}]}`,
+			wantErr: "unknown node type",
+		},
+		{
+			name:    "unknown event type",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "stage": "mock"}, {"name": "b", "stage": "mock"}], "edges": [{"from": "a", "to": "b", "filter": ["bogus"]}]}`,
+			wantErr: "unknown event type",
+		},
+		{
+			name:    "missing barrier config",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "type": "barrier"}]}`,
+			wantErr: "barrier configuration is required",
+		},
+		{
+			name:    "unknown merge strategy",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "type": "barrier", "barrier": {"mergeStrategy": "colect"}}]}`,
+			wantErr: `unknown merge strategy "colect"`,
+		},
+		{
+			name:    "unknown panic policy",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "stage": "mock", "panicPolicy": "ignore"}]}`,
+			wantErr: `unknown panic policy "ignore"`,
+		},
+		{
+			name:    "unknown error policy",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "type": "fanout", "fanout": {"errorPolicy": "isolate", "branches": [{"stage": "mock"}]}}]}`,
+			wantErr: `unknown error policy "isolate"`,
+		},
+		{
+			name:    "unreachable node",
+			data:    `{"entry": "a", "nodes": [{"name": "a", "stage": "mock"}, {"name": "b", "stage": "mock"}]}`,
+			wantErr: "unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig([]byte(tt.data), newTestRegistry(t))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+// TestStageRegistryDuplicate tests that duplicate registrations are rejected
+func TestStageRegistryDuplicate(t *testing.T) {
+	registry := newTestRegistry(t)
+	err := registry.Register("mock", func(options map[string]any) (core.Stage, error) {
+		return &MockStage{}, nil
+	})
+	if err == nil {
+		t.Error("expected error for duplicate registration")
+	}
+}
@@ -1,5 +1,7 @@
 package core
 
+import "time"
+
 // MergeStrategy defines how a barrier combines events from multiple upstream branches
 type MergeStrategy string
 
@@ -9,13 +11,56 @@ const (
 	
 	// MergeStrategyLastOnly emits only the final event from each branch
 	MergeStrategyLastOnly MergeStrategy = "last-only"
+	
+	// MergeStrategyReduce forwards events in arrival order and folds the
+	// branch DoneEvents into one using BarrierConfig.Reduce
+	MergeStrategyReduce MergeStrategy = "reduce"
 )
 
+// DoneReducer folds the DoneEvent of a branch into the accumulated DoneEvent
+type DoneReducer func(acc DoneEvent, next DoneEvent) DoneEvent
+
 // BarrierConfig configures synchronization behavior for a barrier stage
 type BarrierConfig struct {
-	// UpstreamCount is the number of branches to wait for
+	// UpstreamCount is the number of branches to wait for. Zero lets
+	// GraphBuilder.Build infer it from the barrier's incoming edges, counting
+	// an upstream fan-out once per branch; set it to override the inference.
 	UpstreamCount int
 	
 	// MergeStrategy defines how to combine events from branches
 	MergeStrategy MergeStrategy
+	
+	// Reduce consolidates branch DoneEvents when MergeStrategy is reduce.
+	// Other strategies consolidate them with MergeDone
+	Reduce DoneReducer
+
+	// Timeout bounds the wait for the remaining branches once the first one
+	// is done. When it expires the barrier emits what it has with a warning
+	// ServiceMessageEvent instead of failing. Zero waits indefinitely.
+	Timeout time.Duration
+}
+
+// MergeDone is the default DoneReducer. It sums the numeric metrics and usage
+// of both events, joins distinct non-empty FullText values with a newline and
+// marks the result interrupted if either branch was interrupted.
+func MergeDone(acc DoneEvent, next DoneEvent) DoneEvent {
+	acc.TokensUsed += next.TokensUsed
+	acc.ActionsCount += next.ActionsCount
+	acc.AudioDuration += next.AudioDuration
+	acc.Usage = MergeUsage(acc.Usage, next.Usage)
+	acc.Interrupted = acc.Interrupted || next.Interrupted
+	if acc.Provider == "" {
+		acc.Provider = next.Provider
+	}
+	acc.Latency = acc.Latency.Merge(next.Latency)
+
+	switch {
+	case next.FullText == "" || next.FullText == acc.FullText:
+	case acc.FullText == "":
+		acc.FullText = next.FullText
+	default:
+		acc.FullText += "\n" + next.FullText
+	}
+
+	return acc
 }
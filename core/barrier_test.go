@@ -0,0 +1,45 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMergeDoneUsage tests that usage summaries of both branches are summed,
+// overall and per provider, without modifying either
+func TestMergeDoneUsage(t *testing.T) {
+	llm := &UsageSummary{
+		UsageTotals: UsageTotals{InputTokens: 10, OutputTokens: 5, Cost: 0.02},
+		ByProvider:  map[string]UsageTotals{"openai": {InputTokens: 10, OutputTokens: 5, Cost: 0.02}},
+	}
+	tts := &UsageSummary{
+		UsageTotals: UsageTotals{Characters: 40, AudioSeconds: 2.5, Cost: 0.01},
+		ByProvider: map[string]UsageTotals{
+			"openai":     {Characters: 10, Cost: 0.005},
+			"elevenlabs": {Characters: 30, AudioSeconds: 2.5, Cost: 0.005},
+		},
+	}
+
+	merged := MergeDone(DoneEvent{Usage: llm}, DoneEvent{Usage: tts})
+	want := &UsageSummary{
+		UsageTotals: UsageTotals{InputTokens: 10, OutputTokens: 5, Characters: 40, AudioSeconds: 2.5, Cost: 0.03},
+		ByProvider: map[string]UsageTotals{
+			"openai":     {InputTokens: 10, OutputTokens: 5, Characters: 10, Cost: 0.025},
+			"elevenlabs": {Characters: 30, AudioSeconds: 2.5, Cost: 0.005},
+		},
+	}
+	if !reflect.DeepEqual(merged.Usage, want) {
+		t.Errorf("expected %+v, got %+v", want, merged.Usage)
+	}
+	if llm.Characters != 0 || llm.ByProvider["openai"].Characters != 0 {
+		t.Error("expected the branch summaries to be left unchanged")
+	}
+
+	// A branch without usage keeps the other's
+	if merged := MergeDone(DoneEvent{}, DoneEvent{Usage: tts}); !reflect.DeepEqual(merged.Usage, tts) {
+		t.Errorf("expected %+v, got %+v", tts, merged.Usage)
+	}
+	if merged := MergeDone(DoneEvent{}, DoneEvent{}); merged.Usage != nil {
+		t.Errorf("expected no usage, got %+v", merged.Usage)
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStageTimeout is matched by errors.Is for any stage timeout
+var ErrStageTimeout = errors.New("stage timed out")
+
+// StageTimeoutError is reported when a stage exceeds its configured deadline
+type StageTimeoutError struct {
+	Stage   string
+	Timeout time.Duration
+}
+
+func (e *StageTimeoutError) Error() string {
+	return fmt.Sprintf("stage %s timed out after %s", e.Stage, e.Timeout)
+}
+
+// Unwrap allows errors.Is(err, ErrStageTimeout)
+func (e *StageTimeoutError) Unwrap() error {
+	return ErrStageTimeout
+}
+
+// ErrorCode returns ErrCodeStageTimeout
+func (e *StageTimeoutError) ErrorCode() ErrCode {
+	return ErrCodeStageTimeout
+}
+
+// ErrCode classifies an error so clients can react to it programmatically,
+// e.g. back off after a rate limit or fall back to text when TTS fails. It is
+// sent to clients as the error payload's code.
+type ErrCode string
+
+const (
+	// ErrCodeInternal is an unclassified pipeline failure
+	ErrCodeInternal ErrCode = "PIPELINE_ERROR"
+
+	// ErrCodeStageTimeout is a stage exceeding its configured deadline
+	ErrCodeStageTimeout ErrCode = "STAGE_TIMEOUT"
+
+	// ErrCodeStagePanic is a stage that panicked
+	ErrCodeStagePanic ErrCode = "STAGE_PANIC"
+
+	// ErrCodeProviderUnavailable is a provider that couldn't be reached or
+	// reported a server-side failure
+	ErrCodeProviderUnavailable ErrCode = "PROVIDER_UNAVAILABLE"
+
+	// ErrCodeProviderError is a provider failing otherwise, e.g. rejecting
+	// the request
+	ErrCodeProviderError ErrCode = "PROVIDER_ERROR"
+
+	// Provider timeouts and rate limits, per service
+	ErrCodeSTTTimeout     ErrCode = "STT_TIMEOUT"
+	ErrCodeSTTRateLimited ErrCode = "STT_RATE_LIMITED"
+	ErrCodeLLMTimeout     ErrCode = "LLM_TIMEOUT"
+	ErrCodeLLMRateLimited ErrCode = "LLM_RATE_LIMITED"
+	ErrCodeTTSTimeout     ErrCode = "TTS_TIMEOUT"
+	ErrCodeTTSRateLimited ErrCode = "TTS_RATE_LIMITED"
+
+	// ErrCodeInvalidAudio is audio that couldn't be decoded or converted
+	ErrCodeInvalidAudio ErrCode = "INVALID_AUDIO"
+
+	// ErrCodeInvalidAction is LLM output whose actions couldn't be parsed or
+	// aren't permitted
+	ErrCodeInvalidAction ErrCode = "INVALID_ACTION"
+)
+
+// CodedError attaches an ErrCode to an error
+type CodedError struct {
+	Code ErrCode
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the classified error
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the attached code
+func (e *CodedError) ErrorCode() ErrCode {
+	return e.Code
+}
+
+// WithErrCode classifies err with code; a nil error stays nil
+func WithErrCode(err error, code ErrCode) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// ErrCodeOf returns the code of err, taken from the first error in its chain
+// with an ErrorCode method, or ErrCodeInternal if there is none
+func ErrCodeOf(err error) ErrCode {
+	var coded interface{ ErrorCode() ErrCode }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return ErrCodeInternal
+}
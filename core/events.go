@@ -1,5 +1,7 @@
 package core
 
+import "time"
+
 // Event represents any pipeline event
 type Event interface {
 	EventType() EventType
@@ -11,43 +13,237 @@ type StatusEvent struct {
 	Target  StatusTarget
 	Message string
 	Details map[string]any
+	Meta    EventMeta
 }
 
 func (e StatusEvent) EventType() EventType {
 	return EventTypeStatus
 }
 
+func (e StatusEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e StatusEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // STTEvent represents STT output
 type STTEvent struct {
 	Text       string
 	IsFinal    bool
 	Confidence float64
+	Words      []WordInfo // Word timings, nil if the provider doesn't report them
+	SpeakerID  string     // Speaker label from diarization, empty if unknown
+
+	// EndOfUtterance is set when the provider's endpointing detected that
+	// the speaker finished, see stages.TurnDetectorStage
+	EndOfUtterance bool
+
+	Meta EventMeta
+}
+
+// WordInfo is the timing of a single transcribed word
+type WordInfo struct {
+	Word       string
+	Start      float64 // Seconds from the start of the audio stream
+	End        float64 // Seconds from the start of the audio stream
+	Confidence float64
+	SpeakerID  string // Empty if diarization is off
 }
 
 func (e STTEvent) EventType() EventType {
 	return EventTypeSTT
 }
 
+func (e STTEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e STTEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // LLMEvent represents LLM output
 type LLMEvent struct {
 	Delta   string
 	Content string
+	Meta    EventMeta
 }
 
 func (e LLMEvent) EventType() EventType {
 	return EventTypeLLM
 }
 
+func (e LLMEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e LLMEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// RawTextEvent carries LLM output as the model wrote it, markdown and all,
+// next to the cleaned LLMEvents TextProcessorStage sends to TTS. Text
+// clients render it; route it to them with a fan-out filter.
+type RawTextEvent struct {
+	Delta   string
+	Content string
+	Meta    EventMeta
+}
+
+func (e RawTextEvent) EventType() EventType {
+	return EventTypeRawText
+}
+
+func (e RawTextEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e RawTextEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ActionResultEvent reports the outcome of an action the client was asked to
+// perform, from its action.complete message or a timeout
+type ActionResultEvent struct {
+	ActionID string
+	Success  bool
+	Result   any    // Data the client returned
+	Error    string // Why the action failed
+	TimedOut bool   // No completion arrived in time
+	Meta     EventMeta
+}
+
+func (e ActionResultEvent) EventType() EventType {
+	return EventTypeActionResult
+}
+
+func (e ActionResultEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ActionResultEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ActionBlockEvent carries a block of actions an LLM wrote next to its
+// spoken answer, split out of the stream by ActionSplitterStage for
+// ActionStage. Content is the block as written, JSON by convention.
+type ActionBlockEvent struct {
+	Content string
+	Meta    EventMeta
+}
+
+func (e ActionBlockEvent) EventType() EventType {
+	return EventTypeActionBlock
+}
+
+func (e ActionBlockEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ActionBlockEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ToolCallEvent is a server-side tool call requested by the LLM, executed by
+// ToolStage
+type ToolCallEvent struct {
+	ToolID   string // Identifies the call, matching its ToolResultEvent
+	ToolName string
+	Input    map[string]any
+	Meta     EventMeta
+}
+
+func (e ToolCallEvent) EventType() EventType {
+	return EventTypeToolCall
+}
+
+func (e ToolCallEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ToolCallEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ToolStartEvent reports that a tool call started executing
+type ToolStartEvent struct {
+	ToolID      string
+	ToolName    string
+	Description string // Human-readable description of the tool
+	Input       map[string]any
+	Meta        EventMeta
+}
+
+func (e ToolStartEvent) EventType() EventType {
+	return EventTypeToolStart
+}
+
+func (e ToolStartEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ToolStartEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ToolResultEvent reports the outcome of a tool call
+type ToolResultEvent struct {
+	ToolID   string
+	ToolName string
+	Success  bool
+	Output   any    // Value the tool returned
+	Error    string // Why the call failed
+	TimedOut bool   // The tool didn't finish in time
+	Meta     EventMeta
+}
+
+func (e ToolResultEvent) EventType() EventType {
+	return EventTypeToolResult
+}
+
+func (e ToolResultEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ToolResultEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // AudioEvent represents TTS audio output
 type AudioEvent struct {
-	Data   []byte
-	Format string
+	Data       []byte
+	Format     string
+	SampleRate int    // Samples per second, 0 if unknown
+	Channels   int    // Number of channels, 0 if unknown (treated as mono)
+	SeqNum     uint64 // Increases by one per chunk of a stream, starting at 1
+	Meta       EventMeta
 }
 
 func (e AudioEvent) EventType() EventType {
 	return EventTypeAudio
 }
 
+func (e AudioEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e AudioEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // ActionEvent represents an action to be executed by the client
 type ActionEvent struct {
 	ActionID   string
@@ -55,41 +251,341 @@ type ActionEvent struct {
 	Target     string
 	Data       map[string]any
 	Required   bool
+	Timeout    int // Milliseconds the client has to complete the action, 0 for none
+	Meta       EventMeta
 }
 
 func (e ActionEvent) EventType() EventType {
 	return EventTypeAction
 }
 
+func (e ActionEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ActionEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // ErrorEvent represents an error
 type ErrorEvent struct {
 	Error     error
 	Retryable bool
+
+	// Code classifies the error for clients; empty means ErrCodeOf(Error)
+	Code ErrCode
+
+	Meta EventMeta
+}
+
+// ErrCode returns the event's code, classifying its error if none is set
+func (e ErrorEvent) ErrCode() ErrCode {
+	if e.Code != "" {
+		return e.Code
+	}
+	return ErrCodeOf(e.Error)
 }
 
 func (e ErrorEvent) EventType() EventType {
 	return EventTypeError
 }
 
+func (e ErrorEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ErrorEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // DoneEvent signals pipeline completion
 type DoneEvent struct {
 	FullText      string
 	TokensUsed    int
 	AudioDuration float64
 	ActionsCount  int
+	Interrupted   bool           // True if the response was cut short by an InterruptEvent
+	Provider      string         // Name of the provider that served the stage, if any
+	Usage         *UsageSummary  // Per-turn usage and cost, set by the usage aggregator
+	Latency       LatencySummary // Per-turn latency marks, set by the latency aggregator
+	Meta          EventMeta
 }
 
 func (e DoneEvent) EventType() EventType {
 	return EventTypeDone
 }
 
+func (e DoneEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e DoneEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
 // ServiceMessageEvent represents a service message for user feedback
 type ServiceMessageEvent struct {
 	MessageType ServiceMessageType
 	Content     string
 	Localized   map[string]string // Language code -> localized message
+
+	// Key identifies the message in an i18n.Catalog, which renders Content
+	// and Localized from its templates and Params. Empty for free-form
+	// messages.
+	Key    string
+	Params map[string]string
+
+	Meta EventMeta
 }
 
 func (e ServiceMessageEvent) EventType() EventType {
 	return EventTypeServiceMessage
 }
+
+func (e ServiceMessageEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ServiceMessageEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// InterruptEvent signals barge-in: the user started talking over the bot.
+// Stages flush buffered output and cancel in-flight provider streams, then
+// finish the current turn gracefully without failing the pipeline.
+type InterruptEvent struct {
+	Reason string
+	Meta   EventMeta
+}
+
+func (e InterruptEvent) EventType() EventType {
+	return EventTypeInterrupt
+}
+
+func (e InterruptEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e InterruptEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// CitationEvent identifies a retrieved chunk that was used as context,
+// so clients can render the sources behind a response
+type CitationEvent struct {
+	DocumentID string
+	ChunkID    string
+	Title      string
+	URL        string
+	Score      float32
+	Excerpt    string // Leading part of the chunk content
+	Meta       EventMeta
+}
+
+func (e CitationEvent) EventType() EventType {
+	return EventTypeCitation
+}
+
+func (e CitationEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e CitationEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// UsageEvent reports the provider resources consumed by a stage during a turn
+type UsageEvent struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	AudioSeconds float64 // Audio transcribed or synthesized
+	Characters   int     // Characters synthesized
+	Meta         EventMeta
+}
+
+func (e UsageEvent) EventType() EventType {
+	return EventTypeUsage
+}
+
+func (e UsageEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e UsageEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// LanguageDetectedEvent reports the spoken language identified by STT, so
+// downstream stages can switch to localized prompts and voices
+type LanguageDetectedEvent struct {
+	Language   string // BCP 47 tag, e.g. "en" or "pt-BR"
+	Confidence float64
+	Meta       EventMeta
+}
+
+func (e LanguageDetectedEvent) EventType() EventType {
+	return EventTypeLanguage
+}
+
+func (e LanguageDetectedEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e LanguageDetectedEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ResponseCancelledEvent reports that a response was aborted at the
+// client's request. It ends the response in place of a DoneEvent.
+type ResponseCancelledEvent struct {
+	ResponseID string
+	Reason     string
+	Meta       EventMeta
+}
+
+func (e ResponseCancelledEvent) EventType() EventType {
+	return EventTypeCancelled
+}
+
+func (e ResponseCancelledEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ResponseCancelledEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ConnectionLostEvent reports that the client stopped responding to
+// heartbeats, or that its connection failed, while the pipeline was running
+type ConnectionLostEvent struct {
+	Reason   string
+	LastSeen time.Time // Last frame or pong received from the client
+	Meta     EventMeta
+}
+
+func (e ConnectionLostEvent) EventType() EventType {
+	return EventTypeConnectionLost
+}
+
+func (e ConnectionLostEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ConnectionLostEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// BatchEvent carries events grouped by a BatchStage, oldest first
+type BatchEvent struct {
+	Events []Event
+	Meta   EventMeta
+}
+
+func (e BatchEvent) EventType() EventType {
+	return EventTypeBatch
+}
+
+func (e BatchEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e BatchEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// LatencyMarkEvent reports a latency milestone of a turn, such as the
+// first token of the LLM response. Elapsed is measured from the moment the
+// stage started waiting for it, see LatencyMark.
+type LatencyMarkEvent struct {
+	Mark    LatencyMark
+	Elapsed time.Duration
+	Meta    EventMeta
+}
+
+func (e LatencyMarkEvent) EventType() EventType {
+	return EventTypeLatency
+}
+
+func (e LatencyMarkEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e LatencyMarkEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// ProviderPresets names the provider preset to switch each capability to.
+// Empty fields keep the current provider.
+type ProviderPresets struct {
+	LLM       string
+	STT       string
+	TTS       string
+	Embedding string
+}
+
+// ConfigUpdateEvent reconfigures a running session. Like interrupts it
+// bypasses the graph: the pipeline hands it to every stage implementing
+// ConfigSubscriber, and stages apply it from their next turn on. Zero fields
+// leave the corresponding setting unchanged.
+type ConfigUpdateEvent struct {
+	Language   string // BCP 47 tag, e.g. "en" or "pt-BR"
+	TTSEnabled *bool
+	Providers  ProviderPresets
+	Keywords   []string // Session vocabulary for STT to favor, nil leaves it unchanged
+	Meta       EventMeta
+}
+
+func (e ConfigUpdateEvent) EventType() EventType {
+	return EventTypeConfig
+}
+
+func (e ConfigUpdateEvent) Metadata() EventMeta {
+	return e.Meta
+}
+
+func (e ConfigUpdateEvent) WithMetadata(meta EventMeta) Event {
+	e.Meta = meta
+	return e
+}
+
+// Merge returns the update with the non-zero fields of next applied on top,
+// so updates received during a turn collapse into one
+func (e ConfigUpdateEvent) Merge(next ConfigUpdateEvent) ConfigUpdateEvent {
+	if next.Language != "" {
+		e.Language = next.Language
+	}
+	if next.TTSEnabled != nil {
+		e.TTSEnabled = next.TTSEnabled
+	}
+	if next.Providers.LLM != "" {
+		e.Providers.LLM = next.Providers.LLM
+	}
+	if next.Providers.STT != "" {
+		e.Providers.STT = next.Providers.STT
+	}
+	if next.Providers.TTS != "" {
+		e.Providers.TTS = next.Providers.TTS
+	}
+	if next.Providers.Embedding != "" {
+		e.Providers.Embedding = next.Providers.Embedding
+	}
+	if next.Keywords != nil {
+		e.Keywords = next.Keywords
+	}
+	e.Meta = next.Meta
+	return e
+}
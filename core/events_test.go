@@ -145,3 +145,44 @@ func TestPropertyActionTypeConstants(t *testing.T) {
 		}
 	})
 }
+
+// For any built-in event, StampMeta SHALL fill only empty metadata fields and
+// the event SHALL keep its concrete type.
+func TestPropertyStampMetaPreservesExistingFields(t *testing.T) {
+	rapid.Check(t, func(rt *rapid.T) {
+		origin := rapid.StringN(1, 10, 20).Draw(rt, "origin")
+		correlationID := rapid.StringN(1, 10, 20).Draw(rt, "correlationID")
+
+		events := []Event{
+			StatusEvent{},
+			STTEvent{},
+			LLMEvent{Meta: EventMeta{Origin: "llm"}},
+			AudioEvent{},
+			ActionEvent{},
+			ErrorEvent{},
+			DoneEvent{},
+			ServiceMessageEvent{},
+		}
+
+		for _, event := range events {
+			stamped := StampMeta(event, EventMeta{CorrelationID: correlationID, Origin: origin})
+
+			if stamped.EventType() != event.EventType() {
+				rt.Fatalf("StampMeta changed event type from %s to %s", event.EventType(), stamped.EventType())
+			}
+
+			meta := MetaOf(stamped)
+			if meta.CorrelationID != correlationID {
+				rt.Fatalf("CorrelationID not stamped on %s", event.EventType())
+			}
+
+			expectedOrigin := origin
+			if existing := MetaOf(event).Origin; existing != "" {
+				expectedOrigin = existing
+			}
+			if meta.Origin != expectedOrigin {
+				rt.Fatalf("expected origin %q on %s, got %q", expectedOrigin, event.EventType(), meta.Origin)
+			}
+		}
+	})
+}
@@ -9,6 +9,32 @@ const (
 	
 	// ErrorPolicyIsolated allows other branches to continue when one fails
 	ErrorPolicyIsolated ErrorPolicy = "isolated"
+
+	// ErrorPolicyRetryBranch restarts a failed branch stage with a fresh input
+	// channel, up to FanOutConfig.MaxRetries times, while other branches keep
+	// streaming. Events queued for the failed run are lost. A branch that
+	// runs out of retries fails in isolation.
+	ErrorPolicyRetryBranch ErrorPolicy = "retry-branch"
+)
+
+// DefaultMaxRetries is the number of restarts per branch for
+// ErrorPolicyRetryBranch unless FanOutConfig.MaxRetries is set
+const DefaultMaxRetries = 3
+
+// OverflowPolicy defines what the fan-out does when a branch's buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for the branch to make room, backpressuring all
+	// branches (default)
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDrop discards the new event
+	OverflowDrop OverflowPolicy = "drop"
+
+	// OverflowLatestOnly discards the oldest buffered event to make room for
+	// the new one
+	OverflowLatestOnly OverflowPolicy = "latest-only"
 )
 
 // BranchConfig defines a single fan-out branch
@@ -19,13 +45,30 @@ type BranchConfig struct {
 	// EventFilter specifies which event types to forward to this branch.
 	// Empty slice means forward all events.
 	EventFilter []EventType
+
+	// BufferSize is the capacity of the branch's input and output channels
+	// (default: FanOutConfig.BufferSize)
+	BufferSize int
+
+	// OverflowPolicy applies when the branch's input is full. Done and error
+	// events always block so a branch never misses the end of a turn.
+	OverflowPolicy OverflowPolicy
 }
 
 // FanOutConfig configures parallel routing behavior
 type FanOutConfig struct {
-	// ErrorPolicy determines behavior when a branch fails
+	// ErrorPolicy determines behavior when a branch fails, including by
+	// panicking
 	ErrorPolicy ErrorPolicy
 	
 	// Branches defines the downstream routing for each branch
 	Branches []BranchConfig
+
+	// BufferSize is the capacity of each branch's input and output channels
+	// (default: the pipeline's DefaultBufferSize)
+	BufferSize int
+
+	// MaxRetries is the number of restarts per branch with
+	// ErrorPolicyRetryBranch (default: DefaultMaxRetries)
+	MaxRetries int
 }
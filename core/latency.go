@@ -0,0 +1,38 @@
+package core
+
+import "time"
+
+// LatencyMark names a latency milestone of a turn
+type LatencyMark string
+
+const (
+	// LatencySTTFirstPartial is the first transcript, measured from the
+	// first audio chunk sent to the STT provider
+	LatencySTTFirstPartial LatencyMark = "stt_first_partial"
+
+	// LatencyLLMFirstToken is the first token of the response, measured
+	// from the end of the LLM stage's input
+	LatencyLLMFirstToken LatencyMark = "llm_first_token"
+
+	// LatencyTTSFirstAudio is the first synthesized audio, measured from the
+	// first text received by the TTS stage
+	LatencyTTSFirstAudio LatencyMark = "tts_first_audio"
+)
+
+// LatencySummary maps the latency marks reached during a turn to their
+// elapsed times
+type LatencySummary map[LatencyMark]time.Duration
+
+// Merge returns the summary with the marks of next it lacks added. The
+// first value of each mark is kept. The receiver may be modified.
+func (s LatencySummary) Merge(next LatencySummary) LatencySummary {
+	for mark, elapsed := range next {
+		if s == nil {
+			s = make(LatencySummary, len(next))
+		}
+		if _, ok := s[mark]; !ok {
+			s[mark] = elapsed
+		}
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// EventMeta is the metadata envelope carried by every built-in event.
+// It survives routing through fan-out and barrier nodes, so events produced
+// anywhere in a pipeline run can be correlated with each other.
+type EventMeta struct {
+	// CorrelationID is shared by all events of a single pipeline run
+	CorrelationID string
+
+	// Origin is the name of the stage that first emitted the event
+	Origin string
+
+	// Timestamp is when the event was first emitted
+	Timestamp time.Time
+
+	// Turn identifies the request/response exchange the event belongs to
+	Turn TurnContext
+}
+
+// TurnContext identifies a single turn of a session. Pipelines stamp the
+// turn found in the run's context (see WithTurn) on every event, so sinks
+// can tell which response an event belongs to.
+type TurnContext struct {
+	TurnID     string
+	ResponseID string
+	UserID     string
+}
+
+// IsZero reports whether no turn is set
+func (t TurnContext) IsZero() bool {
+	return t == TurnContext{}
+}
+
+// MetaCarrier is implemented by events that carry an EventMeta envelope.
+// All built-in events implement it; custom events may opt in.
+type MetaCarrier interface {
+	Metadata() EventMeta
+	WithMetadata(meta EventMeta) Event
+}
+
+// MetaOf returns the metadata of an event, or a zero EventMeta if the event
+// doesn't carry any
+func MetaOf(event Event) EventMeta {
+	if carrier, ok := event.(MetaCarrier); ok {
+		return carrier.Metadata()
+	}
+	return EventMeta{}
+}
+
+// StampMeta fills empty metadata fields of an event from meta, leaving fields
+// that are already set untouched. Events that don't implement MetaCarrier are
+// returned as-is.
+func StampMeta(event Event, meta EventMeta) Event {
+	carrier, ok := event.(MetaCarrier)
+	if !ok {
+		return event
+	}
+
+	current := carrier.Metadata()
+	if current.CorrelationID == "" {
+		current.CorrelationID = meta.CorrelationID
+	}
+	if current.Origin == "" {
+		current.Origin = meta.Origin
+	}
+	if current.Timestamp.IsZero() {
+		current.Timestamp = meta.Timestamp
+	}
+	if current.Turn.IsZero() {
+		current.Turn = meta.Turn
+	}
+	return carrier.WithMetadata(current)
+}
+
+// correlationIDKey is the context key for a caller-supplied correlation ID
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID to use for a
+// pipeline run
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID stored in ctx, if any
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// turnKey is the context key for the turn of a pipeline run
+type turnKey struct{}
+
+// WithTurn returns a context carrying the turn a pipeline run belongs to
+func WithTurn(ctx context.Context, turn TurnContext) context.Context {
+	return context.WithValue(ctx, turnKey{}, turn)
+}
+
+// TurnFromContext returns the turn stored in ctx, if any
+func TurnFromContext(ctx context.Context) (TurnContext, bool) {
+	turn, ok := ctx.Value(turnKey{}).(TurnContext)
+	return turn, ok && !turn.IsZero()
+}
+
+// NewCorrelationID generates a random correlation ID
+func NewCorrelationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -18,6 +18,13 @@ type Stage interface {
 	OutputTypes() []EventType
 }
 
+// ConfigSubscriber is implemented by stages that accept runtime
+// reconfiguration. ApplyConfig may be called concurrently with Process;
+// stages pick the update up at the start of their next turn.
+type ConfigSubscriber interface {
+	ApplyConfig(update ConfigUpdateEvent)
+}
+
 // PipelineOutput is a channel of events
 type PipelineOutput <-chan Event
 
@@ -12,6 +12,21 @@ const (
 	EventTypeError          EventType = "error"
 	EventTypeDone           EventType = "done"
 	EventTypeServiceMessage EventType = "service_message"
+	EventTypeInterrupt      EventType = "interrupt"
+	EventTypeCitation       EventType = "citation"
+	EventTypeUsage          EventType = "usage"
+	EventTypeLanguage       EventType = "language"
+	EventTypeConfig         EventType = "config"
+	EventTypeCancelled      EventType = "cancelled"
+	EventTypeConnectionLost EventType = "connection_lost"
+	EventTypeBatch          EventType = "batch"
+	EventTypeLatency        EventType = "latency"
+	EventTypeRawText        EventType = "raw_text"
+	EventTypeActionBlock    EventType = "action_block"
+	EventTypeActionResult   EventType = "action_result"
+	EventTypeToolCall       EventType = "tool_call"
+	EventTypeToolStart      EventType = "tool_start"
+	EventTypeToolResult     EventType = "tool_result"
 )
 
 // StatusType defines the current processing status
@@ -0,0 +1,58 @@
+package core
+
+// UsageTotals accumulates provider usage and its cost
+type UsageTotals struct {
+	InputTokens  int
+	OutputTokens int
+	AudioSeconds float64
+	Characters   int
+	Cost         float64
+}
+
+// Add adds the usage reported by an event with its cost to the totals
+func (t *UsageTotals) Add(event UsageEvent, cost float64) {
+	t.InputTokens += event.InputTokens
+	t.OutputTokens += event.OutputTokens
+	t.AudioSeconds += event.AudioSeconds
+	t.Characters += event.Characters
+	t.Cost += cost
+}
+
+// Merge adds other totals to the totals
+func (t *UsageTotals) Merge(other UsageTotals) {
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.AudioSeconds += other.AudioSeconds
+	t.Characters += other.Characters
+	t.Cost += other.Cost
+}
+
+// UsageSummary is the usage and cost of a single turn, overall and per provider
+type UsageSummary struct {
+	UsageTotals
+	ByProvider map[string]UsageTotals
+}
+
+// MergeUsage returns the sum of two summaries, either of which may be nil,
+// without modifying them
+func MergeUsage(a, b *UsageSummary) *UsageSummary {
+	if a == nil && b == nil {
+		return nil
+	}
+	merged := &UsageSummary{}
+	for _, summary := range []*UsageSummary{a, b} {
+		if summary == nil {
+			continue
+		}
+		merged.UsageTotals.Merge(summary.UsageTotals)
+		for provider, totals := range summary.ByProvider {
+			if merged.ByProvider == nil {
+				merged.ByProvider = make(map[string]UsageTotals)
+			}
+			sum := merged.ByProvider[provider]
+			sum.Merge(totals)
+			merged.ByProvider[provider] = sum
+		}
+	}
+	return merged
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// DeadLetterReason describes why an event was lost
+type DeadLetterReason string
+
+const (
+	// DeadLetterChannelFull means the downstream input had no room
+	DeadLetterChannelFull DeadLetterReason = "channel_full"
+	// DeadLetterInputClosed means the downstream stage no longer accepted input
+	DeadLetterInputClosed DeadLetterReason = "input_closed"
+	// DeadLetterStageFailed means the event was queued for a stage that failed
+	DeadLetterStageFailed DeadLetterReason = "stage_failed"
+)
+
+// DeadLetter is an event the pipeline failed to deliver, with the context
+// needed to diagnose the loss. The event keeps its metadata, so its
+// correlation ID and origin identify the run and the stage that emitted it.
+type DeadLetter struct {
+	Event  core.Event
+	Reason DeadLetterReason
+	From   string // Node that emitted the event
+	To     string // Node the event was meant for
+	Err    error  // Stage error for DeadLetterStageFailed
+	Time   time.Time
+}
+
+// String formats the dead letter for logging
+func (d DeadLetter) String() string {
+	s := fmt.Sprintf("%s event from %q to %q dropped: %s", d.Event.EventType(), d.From, d.To, d.Reason)
+	if d.Err != nil {
+		s += ": " + d.Err.Error()
+	}
+	return s
+}
+
+// DeadLetterHandler receives events the pipeline failed to deliver. It is
+// called synchronously on the routing path and must not block.
+type DeadLetterHandler func(DeadLetter)
+
+// DeadLetterChannel returns a handler that sends dead letters to ch. Dead
+// letters are discarded when ch is full, so routing is never held up.
+func DeadLetterChannel(ch chan<- DeadLetter) DeadLetterHandler {
+	return func(d DeadLetter) {
+		select {
+		case ch <- d:
+		default:
+		}
+	}
+}
+
+// deadLetter reports a lost event to the pipeline's handler, if any
+func (s *executionState) deadLetter(event core.Event, reason DeadLetterReason, from, to string, err error) {
+	if s.deadLetters == nil {
+		return
+	}
+	s.deadLetters(DeadLetter{
+		Event:  event,
+		Reason: reason,
+		From:   from,
+		To:     to,
+		Err:    err,
+		Time:   time.Now(),
+	})
+}
+
+// drainFailed reports the events still queued for a failed stage. Upstream
+// routers may keep sending until the pipeline is cancelled, so it only takes
+// what is queued now.
+func (s *executionState) drainFailed(node string, ns *nodeState, err error) {
+	if s.deadLetters == nil {
+		return
+	}
+	for i := len(ns.input); i > 0; i-- {
+		select {
+		case event, ok := <-ns.input:
+			if !ok {
+				return
+			}
+			s.deadLetter(event, DeadLetterStageFailed, core.MetaOf(event).Origin, node, err)
+		default:
+			return
+		}
+	}
+}
+
+// discardInput reports every event sent to a stage that stopped without
+// failing the pipeline, until its input is closed, so upstream stages can
+// keep routing to it
+func (s *executionState) discardInput(node string, ns *nodeState, err error) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case event, ok := <-ns.input:
+				if !ok {
+					return
+				}
+				s.deadLetter(event, DeadLetterStageFailed, core.MetaOf(event).Origin, node, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// gatedMockStage doesn't read its input until released, then records it
+type gatedMockStage struct {
+	name    string
+	release chan struct{}
+	events  []core.Event
+}
+
+func (m *gatedMockStage) Name() string {
+	return m.name
+}
+
+func (m *gatedMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	<-m.release
+	for event := range input {
+		m.events = append(m.events, event)
+	}
+	return nil
+}
+
+func (m *gatedMockStage) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+func (m *gatedMockStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// TestDeadLetterChannelFull tests that events dropped at a full input are
+// reported with their route
+func TestDeadLetterChannelFull(t *testing.T) {
+	deadLetters := make(chan DeadLetter, 1000)
+	sink := &gatedMockStage{name: "sink", release: make(chan struct{})}
+
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddStage("sink", sink).
+		Connect("source", "sink").
+		SetEntryNode("source").
+		AddExitNode("sink").
+		WithDeadLetterHandler(DeadLetterChannel(deadLetters)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 150)
+	for i := 0; i < 150; i++ {
+		input <- core.LLMEvent{Delta: "x"}
+	}
+	close(input)
+
+	output := pipeline.Execute(context.Background(), input)
+
+	// The sink's input holds 100 events, so the rest must be dropped
+	select {
+	case first := <-deadLetters:
+		deadLetters <- first
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a dead letter for the full sink input")
+	}
+	close(sink.release)
+	for range output {
+	}
+
+	dropped := pipeline.Metrics().Stages["source"].EventsDropped
+	if int64(len(deadLetters)) != dropped {
+		t.Errorf("expected one dead letter per dropped event, got %d for %d", len(deadLetters), dropped)
+	}
+	for len(deadLetters) > 0 {
+		d := <-deadLetters
+		if d.Reason != DeadLetterChannelFull || d.From != "source" || d.To != "sink" {
+			t.Fatalf("unexpected dead letter: %s", d)
+		}
+		if core.MetaOf(d.Event).CorrelationID == "" {
+			t.Errorf("expected dead letter event to keep its metadata")
+		}
+	}
+}
+
+// TestDeadLetterStageFailed tests that events queued for a failed stage are
+// reported with the stage error
+func TestDeadLetterStageFailed(t *testing.T) {
+	deadLetters := make(chan DeadLetter, 10)
+
+	pipeline, err := NewBuilder().
+		AddStage("failing", &FailingMockStage{name: "failing", delay: 50 * time.Millisecond}).
+		SetEntryNode("failing").
+		AddExitNode("failing").
+		WithDeadLetterHandler(DeadLetterChannel(deadLetters)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.LLMEvent{Delta: "b"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	for range pipeline.Execute(context.Background(), input) {
+	}
+
+	if len(deadLetters) != 3 {
+		t.Fatalf("expected 3 dead letters, got %d", len(deadLetters))
+	}
+	d := <-deadLetters
+	if d.Reason != DeadLetterStageFailed || d.To != "failing" || d.Err == nil {
+		t.Errorf("unexpected dead letter: %s", d)
+	}
+}
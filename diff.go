@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns a hash of the graph's topology: its nodes and their
+// stage types, edges and their filters, and the entry and exit nodes. Equal
+// topologies have equal fingerprints, so deployments can tell whether a
+// pipeline changed between releases; DiffGraphs reports how.
+func (pg *PipelineGraph) Fingerprint() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "entry %s\n", pg.entryNode)
+	for _, exit := range pg.sortedExits() {
+		fmt.Fprintf(&b, "exit %s\n", exit)
+	}
+	for _, name := range pg.sortedNodeNames() {
+		fmt.Fprintf(&b, "node %s %s\n", name, nodeKind(pg.nodes[name]))
+	}
+	for _, edge := range pg.edgeLabels() {
+		fmt.Fprintf(&b, "edge %s\n", edge)
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// GraphDiff describes how one graph's topology differs from another's. All
+// lists are sorted.
+type GraphDiff struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	ChangedNodes []NodeChange // Nodes whose stage type or kind changed
+
+	AddedEdges     []string // "from -> to"
+	RemovedEdges   []string
+	ChangedFilters []FilterChange
+
+	EntryBefore, EntryAfter string // Set only when the entry node changed
+	AddedExits              []string
+	RemovedExits            []string
+}
+
+// NodeChange describes a node present in both graphs that changed
+type NodeChange struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// FilterChange describes an edge present in both graphs whose event type
+// filter or predicate changed. Filters are rendered as in ExportDOT edge
+// labels; empty means all events.
+type FilterChange struct {
+	Edge   string
+	Before string
+	After  string
+}
+
+// DiffGraphs reports how graph b differs from graph a
+func DiffGraphs(a, b *PipelineGraph) GraphDiff {
+	var diff GraphDiff
+
+	for _, name := range b.sortedNodeNames() {
+		before, ok := a.nodes[name]
+		switch {
+		case !ok:
+			diff.AddedNodes = append(diff.AddedNodes, name)
+		case nodeKind(before) != nodeKind(b.nodes[name]):
+			diff.ChangedNodes = append(diff.ChangedNodes, NodeChange{
+				Name:   name,
+				Before: nodeKind(before),
+				After:  nodeKind(b.nodes[name]),
+			})
+		}
+	}
+	for _, name := range a.sortedNodeNames() {
+		if _, ok := b.nodes[name]; !ok {
+			diff.RemovedNodes = append(diff.RemovedNodes, name)
+		}
+	}
+
+	edgesA, edgesB := a.edgeFilters(), b.edgeFilters()
+	for _, edge := range sortedKeys(edgesB) {
+		before, ok := edgesA[edge]
+		switch {
+		case !ok:
+			diff.AddedEdges = append(diff.AddedEdges, edge)
+		case before != edgesB[edge]:
+			diff.ChangedFilters = append(diff.ChangedFilters, FilterChange{Edge: edge, Before: before, After: edgesB[edge]})
+		}
+	}
+	for _, edge := range sortedKeys(edgesA) {
+		if _, ok := edgesB[edge]; !ok {
+			diff.RemovedEdges = append(diff.RemovedEdges, edge)
+		}
+	}
+
+	if a.entryNode != b.entryNode {
+		diff.EntryBefore, diff.EntryAfter = a.entryNode, b.entryNode
+	}
+	exitsA, exitsB := a.exitSet(), b.exitSet()
+	for _, exit := range b.sortedExits() {
+		if !exitsA[exit] {
+			diff.AddedExits = append(diff.AddedExits, exit)
+		}
+	}
+	for _, exit := range a.sortedExits() {
+		if !exitsB[exit] {
+			diff.RemovedExits = append(diff.RemovedExits, exit)
+		}
+	}
+
+	return diff
+}
+
+// Empty reports whether the graphs have the same topology
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ChangedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && len(d.ChangedFilters) == 0 &&
+		d.EntryBefore == d.EntryAfter && len(d.AddedExits) == 0 && len(d.RemovedExits) == 0
+}
+
+// String renders the diff one change per line, e.g. for deployment logs
+func (d GraphDiff) String() string {
+	var b strings.Builder
+	for _, name := range d.AddedNodes {
+		fmt.Fprintf(&b, "+ node %s\n", name)
+	}
+	for _, name := range d.RemovedNodes {
+		fmt.Fprintf(&b, "- node %s\n", name)
+	}
+	for _, change := range d.ChangedNodes {
+		fmt.Fprintf(&b, "~ node %s: %s -> %s\n", change.Name, change.Before, change.After)
+	}
+	for _, edge := range d.AddedEdges {
+		fmt.Fprintf(&b, "+ edge %s\n", edge)
+	}
+	for _, edge := range d.RemovedEdges {
+		fmt.Fprintf(&b, "- edge %s\n", edge)
+	}
+	for _, change := range d.ChangedFilters {
+		fmt.Fprintf(&b, "~ edge %s: [%s] -> [%s]\n", change.Edge, change.Before, change.After)
+	}
+	if d.EntryBefore != d.EntryAfter {
+		fmt.Fprintf(&b, "~ entry %s -> %s\n", d.EntryBefore, d.EntryAfter)
+	}
+	for _, exit := range d.AddedExits {
+		fmt.Fprintf(&b, "+ exit %s\n", exit)
+	}
+	for _, exit := range d.RemovedExits {
+		fmt.Fprintf(&b, "- exit %s\n", exit)
+	}
+	return b.String()
+}
+
+// nodeKind describes what a node runs: the stage's Go type and name, or the
+// fan-out or barrier and its settings
+func nodeKind(node *graphNode) string {
+	if fanOut := fanOutConfig(node); fanOut != nil {
+		branches := make([]string, 0, len(fanOut.Branches))
+		for _, branch := range fanOut.Branches {
+			branches = append(branches, fmt.Sprintf("%T(%s)", branch.Stage, branch.Stage.Name()))
+		}
+		return fmt.Sprintf("fan-out [%s]", strings.Join(branches, ", "))
+	}
+	if barrier := barrierConfig(node); barrier != nil {
+		return fmt.Sprintf("barrier (%d, %s)", barrier.UpstreamCount, barrier.MergeStrategy)
+	}
+	if node.stage != nil {
+		return fmt.Sprintf("%T(%s)", node.stage, node.stage.Name())
+	}
+	return "empty"
+}
+
+// edgeFilters maps each edge, "from -> to", to its ExportDOT label
+func (pg *PipelineGraph) edgeFilters() map[string]string {
+	edges := make(map[string]string)
+	for _, node := range pg.nodes {
+		for _, edge := range node.outputs {
+			edges[node.name+" -> "+edge.to.name] = edgeLabel(edge)
+		}
+	}
+	return edges
+}
+
+// edgeLabels lists every edge with its filter in a stable order
+func (pg *PipelineGraph) edgeLabels() []string {
+	edges := pg.edgeFilters()
+	labels := make([]string, 0, len(edges))
+	for _, edge := range sortedKeys(edges) {
+		labels = append(labels, fmt.Sprintf("%s [%s]", edge, edges[edge]))
+	}
+	return labels
+}
+
+// sortedExits returns the exit node names in a stable order
+func (pg *PipelineGraph) sortedExits() []string {
+	exits := append([]string(nil), pg.exitNodes...)
+	sort.Strings(exits)
+	return exits
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	done := []core.EventType{core.EventTypeLLM, core.EventTypeDone}
+	build := func(extra bool, filter ...core.EventType) *PipelineGraph {
+		b := NewBuilder().
+			AddStage("stt", &MockStage{name: "stt", outputTypes: done}).
+			AddStage("llm", &MockStage{name: "llm", outputTypes: done}).
+			Connect("stt", "llm", filter...).
+			SetEntryNode("stt").
+			AddExitNode("llm")
+		if extra {
+			b.AddStage("log", &CollectingMockStage{name: "log"}).
+				Connect("stt", "log").
+				AddExitNode("log")
+		}
+		p, err := b.Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return p.Graph()
+	}
+
+	a := build(false)
+	if a.Fingerprint() != build(false).Fingerprint() {
+		t.Error("equal topologies should have equal fingerprints")
+	}
+	if diff := DiffGraphs(a, build(false)); !diff.Empty() {
+		t.Errorf("expected no diff, got %s", diff)
+	}
+
+	b := build(true, core.EventTypeLLM)
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("different topologies should have different fingerprints")
+	}
+
+	diff := DiffGraphs(a, b)
+	if diff.Empty() {
+		t.Fatal("expected a diff")
+	}
+	want := strings.Join([]string{
+		"+ node log",
+		"+ edge stt -> log",
+		"~ edge stt -> llm: [] -> [llm]",
+		"+ exit log",
+	}, "\n") + "\n"
+	if diff.String() != want {
+		t.Errorf("expected diff\n%s\ngot\n%s", want, diff)
+	}
+
+	reverse := DiffGraphs(b, a)
+	if len(reverse.RemovedNodes) != 1 || len(reverse.RemovedEdges) != 1 || len(reverse.RemovedExits) != 1 {
+		t.Errorf("unexpected reverse diff %s", reverse)
+	}
+}
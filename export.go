@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExportDOT renders the graph in Graphviz DOT format. Fan-out nodes are drawn
+// as diamonds, barriers as hexagons, exit nodes with a double border, and the
+// entry node is fed by a start point. Edge labels list event type filters.
+func (pg *PipelineGraph) ExportDOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	exits := pg.exitSet()
+	for _, name := range pg.sortedNodeNames() {
+		node := pg.nodes[name]
+		attrs := []string{"label=" + dotQuote(nodeLabel(node, "\n"))}
+		switch {
+		case node.fanOut != nil:
+			attrs = append(attrs, "shape=diamond")
+		case node.barrier != nil:
+			attrs = append(attrs, "shape=hexagon")
+		}
+		if exits[name] {
+			attrs = append(attrs, "peripheries=2")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+	}
+
+	if pg.entryNode != "" {
+		b.WriteString("\t\"__entry\" [shape=point];\n")
+		fmt.Fprintf(&b, "\t\"__entry\" -> %s;\n", dotQuote(pg.entryNode))
+	}
+
+	for _, name := range pg.sortedNodeNames() {
+		for _, edge := range pg.nodes[name].outputs {
+			fmt.Fprintf(&b, "\t%s -> %s", dotQuote(name), dotQuote(edge.to.name))
+			if label := edgeLabel(edge); label != "" {
+				fmt.Fprintf(&b, " [label=%s]", dotQuote(label))
+			}
+			b.WriteString(";\n")
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportMermaid renders the graph as a Mermaid flowchart using the same
+// conventions as ExportDOT: fan-out nodes as rhombi, barriers as hexagons,
+// exit nodes as stadiums, and a start circle pointing at the entry node.
+func (pg *PipelineGraph) ExportMermaid() string {
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	// Node names may contain characters Mermaid doesn't accept in IDs
+	names := pg.sortedNodeNames()
+	ids := make(map[string]string, len(names))
+	for i, name := range names {
+		ids[name] = fmt.Sprintf("n%d", i)
+	}
+
+	exits := pg.exitSet()
+	for _, name := range names {
+		node := pg.nodes[name]
+		label := mermaidQuote(nodeLabel(node, "<br/>"))
+		switch {
+		case node.fanOut != nil:
+			fmt.Fprintf(&b, "    %s{%s}\n", ids[name], label)
+		case node.barrier != nil:
+			fmt.Fprintf(&b, "    %s{{%s}}\n", ids[name], label)
+		case exits[name]:
+			fmt.Fprintf(&b, "    %s([%s])\n", ids[name], label)
+		default:
+			fmt.Fprintf(&b, "    %s[%s]\n", ids[name], label)
+		}
+	}
+
+	if id, ok := ids[pg.entryNode]; ok {
+		fmt.Fprintf(&b, "    entry((start)) --> %s\n", id)
+	}
+
+	for _, name := range names {
+		for _, edge := range pg.nodes[name].outputs {
+			if label := edgeLabel(edge); label != "" {
+				fmt.Fprintf(&b, "    %s -->|%s| %s\n", ids[name], mermaidQuote(label), ids[edge.to.name])
+			} else {
+				fmt.Fprintf(&b, "    %s --> %s\n", ids[name], ids[edge.to.name])
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// sortedNodeNames returns node names in a stable order
+func (pg *PipelineGraph) sortedNodeNames() []string {
+	names := make([]string, 0, len(pg.nodes))
+	for name := range pg.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exitSet returns the exit node names as a set
+func (pg *PipelineGraph) exitSet() map[string]bool {
+	exits := make(map[string]bool, len(pg.exitNodes))
+	for _, name := range pg.exitNodes {
+		exits[name] = true
+	}
+	return exits
+}
+
+// nodeLabel describes a node: its name plus the stage or synthetic node kind
+func nodeLabel(node *graphNode, separator string) string {
+	switch {
+	case node.fanOut != nil:
+		return fmt.Sprintf("%s%sfan-out (%d branches)", node.name, separator, len(node.fanOut.Branches))
+	case node.barrier != nil:
+		return fmt.Sprintf("%s%sbarrier (%d)", node.name, separator, node.barrier.UpstreamCount)
+	case node.stage != nil && node.stage.Name() != node.name:
+		return node.name + separator + node.stage.Name()
+	default:
+		return node.name
+	}
+}
+
+// edgeLabel lists an edge's event type filter and marks predicate filters
+func edgeLabel(edge *graphEdge) string {
+	types := make([]string, 0, len(edge.eventFilter))
+	for eventType := range edge.eventFilter {
+		types = append(types, string(eventType))
+	}
+	sort.Strings(types)
+
+	label := strings.Join(types, ", ")
+	if edge.predicate != nil {
+		if label != "" {
+			label += " "
+		}
+		label += "[predicate]"
+	}
+	return label
+}
+
+// dotQuote quotes a DOT identifier or label
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidQuote quotes a Mermaid label
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// newExportTestPipeline builds a small graph with filters and synthetic nodes
+func newExportTestPipeline(t *testing.T) *Pipeline {
+	pipeline, err := NewBuilder().
+		AddStage("stt", &MockStage{name: "stt"}).
+		AddFanOut("split", core.FanOutConfig{
+			Branches: []core.BranchConfig{{Stage: &MockStage{name: "a"}}, {Stage: &MockStage{name: "b"}}},
+		}).
+		AddBarrier("join", core.BarrierConfig{UpstreamCount: 1}).
+		AddStage("sink", &MockStage{name: "websocket_sink"}).
+		Connect("stt", "split", core.EventTypeSTT, core.EventTypeDone).
+		Connect("split", "join").
+		ConnectIf("join", "sink", func(event core.Event) bool { return true }).
+		SetEntryNode("stt").
+		AddExitNode("sink").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return pipeline
+}
+
+// TestExportDOT tests the Graphviz rendering of nodes, edges, and markers
+func TestExportDOT(t *testing.T) {
+	dot := newExportTestPipeline(t).Graph().ExportDOT()
+
+	for _, want := range []string{
+		"digraph pipeline {",
+		`"__entry" -> "stt";`,
+		`"split" [label="split\nfan-out (2 branches)", shape=diamond];`,
+		`"join" [label="join\nbarrier (1)", shape=hexagon];`,
+		`"sink" [label="sink\nwebsocket_sink", peripheries=2];`,
+		`"stt" -> "split" [label="done, stt"];`,
+		`"join" -> "sink" [label="[predicate]"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+}
+
+// TestExportMermaid tests the Mermaid rendering of nodes, edges, and markers
+func TestExportMermaid(t *testing.T) {
+	mermaid := newExportTestPipeline(t).Graph().ExportMermaid()
+
+	// Node IDs are assigned in name order: join, sink, split, stt
+	for _, want := range []string{
+		"flowchart LR",
+		"entry((start)) --> n3",
+		`n0{{"join<br/>barrier (1)"}}`,
+		`n1(["sink<br/>websocket_sink"])`,
+		`n2{"split<br/>fan-out (2 branches)"}`,
+		`n3 -->|"done, stt"| n2`,
+		"n2 --> n0",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+}
@@ -2,95 +2,264 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/creastat/pipeline/core"
 )
 
 // FanOutRouter routes events from a single input to multiple downstream branches
-// with support for event filtering and configurable error handling policies
+// with support for event filtering and configurable error handling policies.
+// Branches can be added and removed while events are being routed.
 type FanOutRouter struct {
-	config  *core.FanOutConfig
-	inputs  []chan core.Event
-	outputs []chan core.Event
-	wg      sync.WaitGroup
-	ctx     context.Context
-	cancel  context.CancelFunc
+	config *core.FanOutConfig
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards the branch list and the state of the running route
+	mu       sync.Mutex
+	branches []*fanOutBranch
+	nextID   int
+	run      *fanOutRun
+	finished bool // The distributor has stopped, so branches can't be added
+}
+
+// fanOutBranch is a branch and its channels
+type fanOutBranch struct {
+	id      int
+	config  core.BranchConfig
+	input   chan core.Event
+	output  chan core.Event
+	dropped atomic.Int64
+
+	// removed is closed when the branch is removed, and stale when its stage
+	// is restarted, releasing a distributor blocked on its input
+	removed     chan struct{}
+	stale       chan struct{}
+	removeOnce  sync.Once
+	sendMu      sync.Mutex // Held while sending to input, so closing it is safe
+	inputClosed bool       // Guarded by sendMu
+}
+
+// fanOutRun is the state of a Route call that branches added later join
+type fanOutRun struct {
+	ctx      context.Context
+	branchWg *sync.WaitGroup
+	mergeWg  *sync.WaitGroup
+	output   chan<- core.Event // Merged branch output, nil for Route
+	errs     []error           // Guarded by FanOutRouter.mu
 }
 
 // NewFanOutRouter creates a new fan-out router with the given configuration
 func NewFanOutRouter(config *core.FanOutConfig) *FanOutRouter {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Initialize input and output channels for each branch
-	inputs := make([]chan core.Event, len(config.Branches))
-	outputs := make([]chan core.Event, len(config.Branches))
+	fr := &FanOutRouter{
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, branch := range config.Branches {
+		fr.branches = append(fr.branches, fr.newBranch(branch))
+	}
+	return fr
+}
 
-	for i := range config.Branches {
-		inputs[i] = make(chan core.Event, 100)
-		outputs[i] = make(chan core.Event, 100)
+// newBranch creates a branch with the next ID; fr.mu must be held once the
+// router is shared
+func (fr *FanOutRouter) newBranch(config core.BranchConfig) *fanOutBranch {
+	size := fr.config.BufferSize
+	if config.BufferSize > 0 {
+		size = config.BufferSize
+	}
+	if size == 0 {
+		size = DefaultBufferSize
 	}
 
-	return &FanOutRouter{
+	b := &fanOutBranch{
+		id:      fr.nextID,
 		config:  config,
-		inputs:  inputs,
-		outputs: outputs,
-		ctx:     ctx,
-		cancel:  cancel,
+		input:   make(chan core.Event, size),
+		output:  make(chan core.Event, size),
+		removed: make(chan struct{}),
+		stale:   make(chan struct{}),
+	}
+	fr.nextID++
+	return b
+}
+
+// AddBranch adds a branch and returns its ID. If events are being routed the
+// branch starts immediately and receives events from then on. Branches can't
+// be added once the input has ended.
+func (fr *FanOutRouter) AddBranch(config core.BranchConfig) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.finished {
+		return 0, fmt.Errorf("fan-out has finished routing")
+	}
+
+	b := fr.newBranch(config)
+	fr.branches = append(fr.branches, b)
+	if fr.run != nil {
+		fr.startBranch(b)
+	}
+	return b.id, nil
+}
+
+// RemoveBranch detaches a branch. Its input is closed so the branch stage
+// finishes with the events it already has; output it emits is still merged.
+func (fr *FanOutRouter) RemoveBranch(id int) error {
+	fr.mu.Lock()
+	var removed *fanOutBranch
+	for i, b := range fr.branches {
+		if b.id == id {
+			removed = b
+			fr.branches = append(fr.branches[:i:i], fr.branches[i+1:]...)
+			break
+		}
+	}
+	fr.mu.Unlock()
+
+	if removed == nil {
+		return fmt.Errorf("fan-out branch %d does not exist", id)
+	}
+	removed.removeOnce.Do(func() { close(removed.removed) })
+	removed.closeInput()
+	return nil
+}
+
+// resetInput gives the branch a fresh input channel for a restarted stage,
+// abandoning events queued for the failed run. Only the branch's own
+// goroutine replaces the input, so it may read it without the lock.
+func (b *fanOutBranch) resetInput() {
+	// Release a distributor blocked on the failed run's input
+	close(b.stale)
+
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+
+	b.input = make(chan core.Event, cap(b.input))
+	b.stale = make(chan struct{})
+	if b.inputClosed {
+		close(b.input)
+	}
+}
+
+// reset gives the branch new channels for another route
+func (b *fanOutBranch) reset() {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+
+	b.input = make(chan core.Event, cap(b.input))
+	b.output = make(chan core.Event, cap(b.output))
+	b.stale = make(chan struct{})
+	b.inputClosed = false
+}
+
+// closeInput closes the branch input exactly once
+func (b *fanOutBranch) closeInput() {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+
+	if !b.inputClosed {
+		b.inputClosed = true
+		close(b.input)
 	}
 }
 
 // Route distributes events from the input channel to all downstream branches
 // according to the configured error policy and event filters
 func (fr *FanOutRouter) Route(ctx context.Context, input <-chan core.Event) error {
+	return fr.route(ctx, input, nil)
+}
+
+// route distributes events to the branches and, if output is set, merges the
+// branches' output into it
+func (fr *FanOutRouter) route(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
 	// Create a merged context that respects both the router's context and the provided context
 	mergedCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Track all branch goroutines
-	var branchWg sync.WaitGroup
-	errorChan := make(chan error, len(fr.config.Branches))
+	// Track all branch goroutines. The distributor counts as one so the count
+	// can't reach zero while branches may still be added.
+	run := &fanOutRun{
+		ctx:      mergedCtx,
+		branchWg: &sync.WaitGroup{},
+		mergeWg:  &sync.WaitGroup{},
+		output:   output,
+	}
+	run.branchWg.Add(1)
 
 	// Start all branch processors
-	for i, branch := range fr.config.Branches {
-		branchWg.Add(1)
-		go fr.processBranch(mergedCtx, i, branch, &branchWg, errorChan)
+	fr.mu.Lock()
+	if fr.finished {
+		// Run again, e.g. by a reused pipeline, the branches start afresh
+		for _, b := range fr.branches {
+			b.reset()
+		}
+		fr.finished = false
 	}
+	fr.run = run
+	for _, b := range fr.branches {
+		fr.startBranch(b)
+	}
+	fr.mu.Unlock()
 
 	// Start the event distributor
 	fr.wg.Add(1)
 	go func() {
 		defer fr.wg.Done()
-		fr.distributeEvents(mergedCtx, input, errorChan)
+		defer run.branchWg.Done()
+		fr.distributeEvents(mergedCtx, input)
 	}()
 
-	// Wait for all branches to complete
-	branchWg.Wait()
+	// Wait for all branches to complete, then for their output to be merged
+	run.branchWg.Wait()
+	run.mergeWg.Wait()
 
-	// Close error channel and collect errors
-	close(errorChan)
-	var errors []error
-	for err := range errorChan {
-		if err != nil {
-			errors = append(errors, err)
-		}
-	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.run = nil
 
 	// Return first error if any occurred
-	if len(errors) > 0 {
-		return errors[0]
+	if len(run.errs) > 0 {
+		return run.errs[0]
 	}
 
 	return nil
 }
 
+// startBranch runs a branch stage in the current route; fr.mu must be held
+func (fr *FanOutRouter) startBranch(b *fanOutBranch) {
+	run := fr.run
+	run.branchWg.Add(1)
+	go fr.processBranch(run, b)
+
+	if run.output != nil {
+		run.mergeWg.Add(1)
+		go func() {
+			defer run.mergeWg.Done()
+			mergeBranch(run.ctx, b, run.output)
+		}()
+	}
+}
+
 // distributeEvents reads from the input channel and forwards events to all branches
 // according to their event filters
-func (fr *FanOutRouter) distributeEvents(ctx context.Context, input <-chan core.Event, errorChan chan<- error) {
+func (fr *FanOutRouter) distributeEvents(ctx context.Context, input <-chan core.Event) {
 	defer func() {
 		// Close all input channels when distribution is complete
-		for _, ch := range fr.inputs {
-			close(ch)
+		fr.mu.Lock()
+		fr.finished = true
+		branches := append([]*fanOutBranch(nil), fr.branches...)
+		fr.mu.Unlock()
+
+		for _, b := range branches {
+			b.closeInput()
 		}
 	}()
 
@@ -104,46 +273,126 @@ func (fr *FanOutRouter) distributeEvents(ctx context.Context, input <-chan core.
 				return
 			}
 
+			fr.mu.Lock()
+			branches := append([]*fanOutBranch(nil), fr.branches...)
+			fr.mu.Unlock()
+
 			// Forward event to each branch according to its filter
-			for i, branch := range fr.config.Branches {
+			for _, b := range branches {
 				// Check if this branch should receive this event type
-				if !fr.shouldForwardEvent(branch, event) {
+				if !fr.shouldForwardEvent(b.config, event) {
 					continue
 				}
 
-				// Send event to branch input (non-blocking with context check)
-				select {
-				case <-ctx.Done():
+				if !b.send(ctx, event) {
 					return
-				case fr.inputs[i] <- event:
-					// Event sent successfully
 				}
 			}
 		}
 	}
 }
 
-// processBranch processes events for a single downstream branch
-func (fr *FanOutRouter) processBranch(ctx context.Context, branchIndex int, branch core.BranchConfig, wg *sync.WaitGroup, errorChan chan<- error) {
-	defer wg.Done()
+// send delivers an event to the branch input according to the branch's
+// overflow policy. It returns false if the context was cancelled; events for
+// a removed branch are discarded.
+func (b *fanOutBranch) send(ctx context.Context, event core.Event) bool {
+	policy := b.config.OverflowPolicy
+	switch event.(type) {
+	case core.DoneEvent, core.ErrorEvent:
+		policy = core.OverflowBlock
+	}
+
+	for {
+		if done, ok := b.sendOnce(ctx, event, policy); done {
+			return ok
+		}
+		// The branch was restarted while we waited, so try its new input
+	}
+}
 
-	// Execute the branch stage
-	err := branch.Stage.Process(ctx, fr.inputs[branchIndex], fr.outputs[branchIndex])
+// sendOnce tries to deliver an event to the current branch input. It isn't
+// done if the branch is restarted while it waits for room.
+func (b *fanOutBranch) sendOnce(ctx context.Context, event core.Event, policy core.OverflowPolicy) (done, ok bool) {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
 
-	if err != nil {
-		// Send error to error channel
+	if b.inputClosed {
+		return true, true
+	}
+
+	for {
 		select {
-		case errorChan <- err:
+		case <-ctx.Done():
+			return true, false
+		case b.input <- event:
+			return true, true
 		default:
-			// Error channel full, continue
 		}
 
+		switch policy {
+		case core.OverflowDrop:
+			b.dropped.Add(1)
+			return true, true
+
+		case core.OverflowLatestOnly:
+			// The distributor is the only sender, so after discarding the
+			// oldest event the next attempt finds room unless the branch
+			// drained the buffer first, in which case it also succeeds
+			select {
+			case <-b.input:
+				b.dropped.Add(1)
+			default:
+			}
+
+		default:
+			select {
+			case <-ctx.Done():
+				return true, false
+			case <-b.removed:
+				return true, true
+			case <-b.stale:
+				return false, true
+			case b.input <- event:
+				return true, true
+			}
+		}
+	}
+}
+
+// processBranch processes events for a single downstream branch
+func (fr *FanOutRouter) processBranch(run *fanOutRun, b *fanOutBranch) {
+	defer run.branchWg.Done()
+
+	maxRetries := 0
+	if fr.config.ErrorPolicy == core.ErrorPolicyRetryBranch {
+		maxRetries = fr.config.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = core.DefaultMaxRetries
+		}
+	}
+
+	// Execute the branch stage, restarting it on failure if the policy allows
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = callStage(run.ctx, b.config.Stage.Name(), b.config.Stage, b.input, b.output)
+		if err == nil || attempt >= maxRetries || run.ctx.Err() != nil {
+			break
+		}
+		b.resetInput()
+	}
+
+	if err != nil {
+		// Record the error for Route to return
+		fr.mu.Lock()
+		run.errs = append(run.errs, err)
+		fr.mu.Unlock()
+
 		// Handle error according to policy
-		fr.handleBranchError(ctx, err)
+		fr.handleBranchError(run.ctx, err)
 	}
 
 	// Close the output channel for this branch
-	close(fr.outputs[branchIndex])
+	close(b.output)
 }
 
 // handleBranchError handles errors according to the configured error policy
@@ -152,7 +401,8 @@ func (fr *FanOutRouter) handleBranchError(ctx context.Context, err error) {
 		// Cancel all branches
 		fr.cancel()
 	}
-	// For ErrorPolicyIsolated, we don't cancel - other branches continue
+	// For ErrorPolicyIsolated and exhausted ErrorPolicyRetryBranch, we don't
+	// cancel - other branches continue
 }
 
 // shouldForwardEvent checks if an event should be forwarded to a branch
@@ -177,13 +427,42 @@ func (fr *FanOutRouter) shouldForwardEvent(branch core.BranchConfig, event core.
 // GetOutputs returns the output channels for all branches
 // Each output channel receives events that passed the branch's filter
 func (fr *FanOutRouter) GetOutputs() []<-chan core.Event {
-	outputs := make([]<-chan core.Event, len(fr.outputs))
-	for i, ch := range fr.outputs {
-		outputs[i] = ch
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	outputs := make([]<-chan core.Event, len(fr.branches))
+	for i, b := range fr.branches {
+		outputs[i] = b.output
 	}
 	return outputs
 }
 
+// Branches returns the configuration of the current branches
+func (fr *FanOutRouter) Branches() []core.BranchConfig {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	branches := make([]core.BranchConfig, len(fr.branches))
+	for i, b := range fr.branches {
+		branches[i] = b.config
+	}
+	return branches
+}
+
+// Dropped returns the number of events a branch missed due to its overflow
+// policy, or 0 if the branch was removed
+func (fr *FanOutRouter) Dropped(id int) int64 {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for _, b := range fr.branches {
+		if b.id == id {
+			return b.dropped.Load()
+		}
+	}
+	return 0
+}
+
 // Cancel cancels the fan-out router and all its branches
 func (fr *FanOutRouter) Cancel() {
 	fr.cancel()
@@ -218,45 +497,63 @@ func (fs *FanOutStage) Name() string {
 // Process implements the Stage interface
 // It routes events from input to multiple downstream branches
 func (fs *FanOutStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
-	// Route events to all branches
-	err := fs.router.Route(ctx, input)
+	// Route events to all branches, merging their outputs back to the single
+	// output channel as they arrive
+	return fs.router.route(ctx, input, output)
+}
 
-	// Merge outputs from all branches back to the single output channel
-	fs.mergeOutputs(ctx, output)
+// AddBranch adds a branch at runtime, e.g. a debugging sink, and returns its
+// ID for RemoveBranch
+func (fs *FanOutStage) AddBranch(config core.BranchConfig) (int, error) {
+	return fs.router.AddBranch(config)
+}
 
-	return err
+// RemoveBranch detaches a branch added with AddBranch or configured up front,
+// whose IDs are the branch indexes
+func (fs *FanOutStage) RemoveBranch(id int) error {
+	return fs.router.RemoveBranch(id)
 }
 
-// mergeOutputs merges events from all branch outputs into a single output channel
-func (fs *FanOutStage) mergeOutputs(ctx context.Context, output chan<- core.Event) {
-	var wg sync.WaitGroup
+// FanOut returns the stage running a fan-out node, whether added with
+// AddFanOut or as a FanOutStage, to add and remove branches at runtime
+func (p *Pipeline) FanOut(name string) (*FanOutStage, bool) {
+	node := p.graph.GetNode(name)
+	if node == nil {
+		return nil, false
+	}
 
-	// Start a goroutine for each branch output
-	for _, branchOutput := range fs.router.GetOutputs() {
-		wg.Add(1)
-		go func(ch <-chan core.Event) {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case event, ok := <-ch:
-					if !ok {
-						return
-					}
-					select {
-					case <-ctx.Done():
-						return
-					case output <- event:
-						// Event sent successfully
-					}
-				}
+	// Running stages are swapped with mu held, so read the stage under it
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fanOut, ok := node.runnable().(*FanOutStage)
+	return fanOut, ok
+}
+
+// mergeBranch forwards a branch's output to the merged output channel
+func mergeBranch(ctx context.Context, b *fanOutBranch, output chan<- core.Event) {
+	origin := b.config.Stage.Name()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-b.output:
+			if !ok {
+				return
+			}
+			// Record the branch stage as the origin of events it emitted
+			event = core.StampMeta(event, core.EventMeta{
+				Origin:    origin,
+				Timestamp: time.Now(),
+			})
+			select {
+			case <-ctx.Done():
+				return
+			case output <- event:
+				// Event sent successfully
 			}
-		}(branchOutput)
+		}
 	}
-
-	// Wait for all branch outputs to be consumed
-	wg.Wait()
 }
 
 // InputTypes returns the input event types this stage accepts
@@ -270,7 +567,7 @@ func (fs *FanOutStage) OutputTypes() []core.EventType {
 	// Collect all output types from all branches
 	outputTypes := make(map[core.EventType]bool)
 
-	for _, branch := range fs.config.Branches {
+	for _, branch := range fs.router.Branches() {
 		for _, outputType := range branch.Stage.OutputTypes() {
 			outputTypes[outputType] = true
 		}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -42,6 +43,248 @@ func TestFanOutBasicRouting(t *testing.T) {
 	}
 }
 
+// TestFanOutOverflowPolicies tests that a slow branch with a drop or
+// latest-only policy doesn't hold up the distributor
+func TestFanOutOverflowPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy core.OverflowPolicy
+		want   []string // Deltas the slow branch receives
+	}{
+		{core.OverflowDrop, []string{"0", "1"}},
+		{core.OverflowLatestOnly, []string{"18", "19"}},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			slow := &gatedMockStage{name: "slow", release: make(chan struct{})}
+			fast := &CollectingMockStage{name: "fast"}
+
+			router := NewFanOutRouter(&core.FanOutConfig{
+				ErrorPolicy: core.ErrorPolicyIsolated,
+				Branches: []core.BranchConfig{
+					{Stage: slow, BufferSize: 2, OverflowPolicy: tc.policy},
+					{Stage: fast},
+				},
+			})
+
+			input := make(chan core.Event)
+			routed := make(chan error, 1)
+			go func() {
+				routed <- router.Route(context.Background(), input)
+			}()
+
+			// With the block policy these sends would stall after the slow
+			// branch's buffer filled up
+			for i := 0; i < 20; i++ {
+				select {
+				case input <- core.LLMEvent{Delta: fmt.Sprint(i)}:
+				case <-time.After(time.Second):
+					t.Fatalf("distributor blocked by the slow branch at event %d", i)
+				}
+			}
+			input <- core.DoneEvent{}
+			close(input)
+			close(slow.release)
+
+			if err := <-routed; err != nil {
+				t.Fatalf("routing failed: %v", err)
+			}
+
+			if len(fast.events) != 21 {
+				t.Errorf("expected the fast branch to receive all 21 events, got %d", len(fast.events))
+			}
+			if router.Dropped(0) != 18 {
+				t.Errorf("expected 18 dropped events, got %d", router.Dropped(0))
+			}
+
+			var deltas []string
+			for _, event := range slow.events {
+				if llm, ok := event.(core.LLMEvent); ok {
+					deltas = append(deltas, llm.Delta)
+				}
+			}
+			if fmt.Sprint(deltas) != fmt.Sprint(tc.want) {
+				t.Errorf("expected slow branch to receive %v, got %v", tc.want, deltas)
+			}
+			if _, ok := slow.events[len(slow.events)-1].(core.DoneEvent); !ok {
+				t.Error("expected the slow branch to receive the DoneEvent")
+			}
+		})
+	}
+}
+
+// TestFanOutAddRemoveBranch tests attaching and detaching branches while
+// events are being routed
+func TestFanOutAddRemoveBranch(t *testing.T) {
+	early := &CollectingMockStage{name: "early"}
+	late := &CollectingMockStage{name: "late"}
+
+	stage := NewFanOutStage("fanout", &core.FanOutConfig{
+		ErrorPolicy: core.ErrorPolicyIsolated,
+		Branches:    []core.BranchConfig{{Stage: early}},
+	})
+
+	input := make(chan core.Event)
+	output := make(chan core.Event, 10)
+	processed := make(chan error, 1)
+	go func() {
+		processed <- stage.Process(context.Background(), input, output)
+	}()
+
+	// waitFor polls until a branch has collected n events
+	waitFor := func(m *CollectingMockStage, n int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			m.mu.Lock()
+			got := len(m.events)
+			m.mu.Unlock()
+			if got >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("branch %s collected %d events, expected %d", m.name, got, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	input <- core.LLMEvent{Delta: "1"}
+	waitFor(early, 1)
+
+	id, err := stage.AddBranch(core.BranchConfig{Stage: late})
+	if err != nil {
+		t.Fatalf("AddBranch failed: %v", err)
+	}
+	input <- core.LLMEvent{Delta: "2"}
+	waitFor(early, 2)
+	waitFor(late, 1)
+
+	if err := stage.RemoveBranch(0); err != nil {
+		t.Fatalf("RemoveBranch failed: %v", err)
+	}
+	input <- core.LLMEvent{Delta: "3"}
+	close(input)
+
+	if err := <-processed; err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	if len(early.events) != 2 || len(late.events) != 2 {
+		t.Errorf("expected 2 events per branch, got %d early and %d late", len(early.events), len(late.events))
+	}
+	if delta := late.events[1].(core.LLMEvent).Delta; delta != "3" {
+		t.Errorf("expected the added branch to receive event 3, got %q", delta)
+	}
+	if len(output) != 4 {
+		t.Errorf("expected 4 merged events, got %d", len(output))
+	}
+
+	if err := stage.RemoveBranch(id + 1); err == nil {
+		t.Error("expected error removing an unknown branch")
+	}
+	if _, err := stage.AddBranch(core.BranchConfig{Stage: late}); err == nil {
+		t.Error("expected error adding a branch after routing finished")
+	}
+}
+
+// flakyMockStage fails its first runs, then collects its input
+type flakyMockStage struct {
+	name     string
+	failures int
+	ready    chan struct{} // Closed when a run doesn't fail
+	runs     int
+	events   []core.Event
+}
+
+func (m *flakyMockStage) Name() string {
+	return m.name
+}
+
+func (m *flakyMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	m.runs++
+	if m.runs <= m.failures {
+		return errors.New("stage failed")
+	}
+	close(m.ready)
+	for event := range input {
+		m.events = append(m.events, event)
+	}
+	return nil
+}
+
+func (m *flakyMockStage) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+func (m *flakyMockStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// TestFanOutRetryBranch tests that a failed branch is restarted while the
+// other branches keep receiving events
+func TestFanOutRetryBranch(t *testing.T) {
+	flaky := &flakyMockStage{name: "flaky", failures: 2, ready: make(chan struct{})}
+	steady := &CollectingMockStage{name: "steady"}
+
+	router := NewFanOutRouter(&core.FanOutConfig{
+		ErrorPolicy: core.ErrorPolicyRetryBranch,
+		Branches:    []core.BranchConfig{{Stage: flaky}, {Stage: steady}},
+	})
+
+	input := make(chan core.Event, 10)
+	routed := make(chan error, 1)
+	go func() {
+		routed <- router.Route(context.Background(), input)
+	}()
+
+	select {
+	case <-flaky.ready:
+	case <-time.After(time.Second):
+		t.Fatal("flaky branch was not restarted")
+	}
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := <-routed; err != nil {
+		t.Fatalf("expected the retried branch to recover, got %v", err)
+	}
+	if flaky.runs != 3 {
+		t.Errorf("expected 3 runs of the flaky branch, got %d", flaky.runs)
+	}
+	if len(flaky.events) != 2 || len(steady.events) != 2 {
+		t.Errorf("expected 2 events per branch, got %d and %d", len(flaky.events), len(steady.events))
+	}
+}
+
+// TestFanOutRetryBranchExhausted tests that a branch that keeps failing
+// gives up after MaxRetries without cancelling the others
+func TestFanOutRetryBranchExhausted(t *testing.T) {
+	flaky := &flakyMockStage{name: "flaky", failures: 5, ready: make(chan struct{})}
+	steady := &CollectingMockStage{name: "steady"}
+
+	router := NewFanOutRouter(&core.FanOutConfig{
+		ErrorPolicy: core.ErrorPolicyRetryBranch,
+		MaxRetries:  1,
+		Branches:    []core.BranchConfig{{Stage: flaky}, {Stage: steady}},
+	})
+
+	input := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := router.Route(context.Background(), input); err == nil {
+		t.Fatal("expected error once retries are exhausted")
+	}
+	if flaky.runs != 2 {
+		t.Errorf("expected 2 runs of the flaky branch, got %d", flaky.runs)
+	}
+	if len(steady.events) != 2 {
+		t.Errorf("expected the steady branch to receive 2 events, got %d", len(steady.events))
+	}
+}
+
 // TestFanOutEventFiltering tests that event filters work correctly
 func TestFanOutEventFiltering(t *testing.T) {
 	stage1 := &MockStage{name: "stage1"}
@@ -412,3 +655,65 @@ func (m *CollectingMockStage) InputTypes() []core.EventType {
 func (m *CollectingMockStage) OutputTypes() []core.EventType {
 	return []core.EventType{}
 }
+
+// TestPipelineFanOut tests that a fan-out added with AddFanOut keeps its
+// stage across runs, and that Pipeline.FanOut adds branches to the running
+// one
+func TestPipelineFanOut(t *testing.T) {
+	first := &CollectingMockStage{name: "first"}
+	p, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddFanOut("fanout", core.FanOutConfig{Branches: []core.BranchConfig{{Stage: first}}}).
+		Connect("source", "fanout").
+		SetEntryNode("source").
+		AddExitNode("fanout").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	fanOut, ok := p.FanOut("fanout")
+	if !ok {
+		t.Fatal("expected the fan-out node's stage")
+	}
+	if _, ok := p.FanOut("source"); ok {
+		t.Error("expected no fan-out stage for another node")
+	}
+
+	received := func(m *CollectingMockStage) int {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.events)
+	}
+
+	// The first run ends the fan-out's routing
+	input := make(chan core.Event, 2)
+	input <- core.LLMEvent{Delta: "1"}
+	input <- core.DoneEvent{}
+	close(input)
+	for range p.Execute(context.Background(), input) {
+	}
+
+	// The second routes again, to a branch added while it runs
+	input = make(chan core.Event)
+	output := p.Execute(context.Background(), input)
+	input <- core.LLMEvent{Delta: "2"}
+	deadline := time.Now().Add(time.Second)
+	for received(first) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the second run to reach the branch, got %d events", received(first))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	late := &CollectingMockStage{name: "late"}
+	if _, err := fanOut.AddBranch(core.BranchConfig{Stage: late}); err != nil {
+		t.Fatalf("AddBranch failed: %v", err)
+	}
+	input <- core.DoneEvent{}
+	close(input)
+	for range output {
+	}
+
+	if received(first) != 4 || received(late) != 1 {
+		t.Errorf("expected 4 events on the first branch and 1 on the added one, got %d and %d", received(first), received(late))
+	}
+}
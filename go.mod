@@ -8,6 +8,7 @@ require (
 	github.com/creastat/storage v0.0.2
 	github.com/gorilla/websocket v1.5.3
 	github.com/stretchr/testify v1.11.1
+	gopkg.in/yaml.v3 v3.0.1
 	pgregory.net/rapid v1.1.0
 )
 
@@ -22,5 +23,4 @@ require (
 	github.com/stretchr/objx v0.5.3 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -2,6 +2,8 @@ package pipeline
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/creastat/pipeline/core"
 )
 
@@ -36,6 +38,23 @@ type graphNode struct {
 	
 	// barrier configuration if this node synchronizes multiple branches
 	barrier *core.BarrierConfig
+
+	// built is the stage running a fan-out or barrier node without a stage
+	built core.Stage
+	
+	// timeout is the deadline for the stage's Process call, zero means none
+	timeout time.Duration
+
+	// panicPolicy is what happens when the stage panics, empty means
+	// PanicPropagate
+	panicPolicy PanicPolicy
+
+	// taps observe copies of the events this node emits
+	taps []TapFunc
+
+	// bufferSize is the capacity of the node's input and output channels,
+	// zero means the pipeline default
+	bufferSize int
 }
 
 // graphEdge represents a directed edge in the pipeline graph
@@ -49,8 +68,20 @@ type graphEdge struct {
 	// eventFilter maps event types to whether they should be forwarded
 	// nil means forward all events
 	eventFilter map[core.EventType]bool
+	
+	// predicate optionally filters events by payload after the type filter
+	// nil means no payload filtering
+	predicate EdgePredicate
+
+	// bufferSize is the minimum input capacity of the destination node,
+	// zero means no minimum
+	bufferSize int
 }
 
+// EdgePredicate decides whether an event is forwarded along an edge based on
+// its payload, e.g. only final STTEvents
+type EdgePredicate func(event core.Event) bool
+
 // NewPipelineGraph creates a new empty pipeline graph
 func NewPipelineGraph() *PipelineGraph {
 	return &PipelineGraph{
@@ -65,7 +96,7 @@ func (pg *PipelineGraph) AddNode(name string, stage core.Stage, fanOut *core.Fan
 		return fmt.Errorf("node %q already exists in graph", name)
 	}
 	
-	pg.nodes[name] = &graphNode{
+	node := &graphNode{
 		name:    name,
 		stage:   stage,
 		outputs: make([]*graphEdge, 0),
@@ -73,12 +104,26 @@ func (pg *PipelineGraph) AddNode(name string, stage core.Stage, fanOut *core.Fan
 		fanOut:  fanOut,
 		barrier: barrier,
 	}
-	
+	switch {
+	case stage != nil:
+	case fanOut != nil:
+		node.built = NewFanOutStage(name, fanOut)
+	case barrier != nil:
+		node.built = NewBarrierStage(name, barrier)
+	}
+	pg.nodes[name] = node
+
 	return nil
 }
 
 // AddEdge adds a directed edge from source to destination with optional event filtering
 func (pg *PipelineGraph) AddEdge(fromName, toName string, eventFilter []core.EventType) error {
+	return pg.AddPredicateEdge(fromName, toName, eventFilter, nil)
+}
+
+// AddPredicateEdge adds a directed edge that forwards only events matching both
+// the optional event type filter and the optional predicate
+func (pg *PipelineGraph) AddPredicateEdge(fromName, toName string, eventFilter []core.EventType, predicate EdgePredicate) error {
 	fromNode, exists := pg.nodes[fromName]
 	if !exists {
 		return fmt.Errorf("source node %q does not exist", fromName)
@@ -102,6 +147,7 @@ func (pg *PipelineGraph) AddEdge(fromName, toName string, eventFilter []core.Eve
 		from:        fromNode,
 		to:          toNode,
 		eventFilter: filterMap,
+		predicate:   predicate,
 	}
 	
 	fromNode.outputs = append(fromNode.outputs, edge)
@@ -110,6 +156,84 @@ func (pg *PipelineGraph) AddEdge(fromName, toName string, eventFilter []core.Eve
 	return nil
 }
 
+// SetStageTimeout sets the deadline for a node's stage execution
+func (pg *PipelineGraph) SetStageTimeout(name string, timeout time.Duration) error {
+	node, exists := pg.nodes[name]
+	if !exists {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("timeout for node %q must not be negative", name)
+	}
+	node.timeout = timeout
+	return nil
+}
+
+// SetPanicPolicy sets what happens when a node's stage panics
+func (pg *PipelineGraph) SetPanicPolicy(name string, policy PanicPolicy) error {
+	node, exists := pg.nodes[name]
+	if !exists {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+	if !policy.valid() {
+		return fmt.Errorf("unknown panic policy %q for node %q", policy, name)
+	}
+	node.panicPolicy = policy
+	return nil
+}
+
+// SetBufferSize sets the capacity of a node's input and output channels
+func (pg *PipelineGraph) SetBufferSize(name string, size int) error {
+	node, exists := pg.nodes[name]
+	if !exists {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+	if size < 0 {
+		return fmt.Errorf("buffer size for node %q must not be negative", name)
+	}
+	node.bufferSize = size
+	return nil
+}
+
+// SetEdgeBufferSize sets the buffer of the edges from one node to another.
+// Edges share their destination's input channel, so this is a lower bound on
+// the destination's input capacity.
+func (pg *PipelineGraph) SetEdgeBufferSize(fromName, toName string, size int) error {
+	from, exists := pg.nodes[fromName]
+	if !exists {
+		return fmt.Errorf("node %q does not exist", fromName)
+	}
+	if size < 0 {
+		return fmt.Errorf("buffer size for edge from %q to %q must not be negative", fromName, toName)
+	}
+	found := false
+	for _, edge := range from.outputs {
+		if edge.to.name == toName {
+			edge.bufferSize = size
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no edge from %q to %q", fromName, toName)
+	}
+	return nil
+}
+
+// AddTap attaches an observer to a node's output. Taps receive copies of the
+// node's events without affecting routing or backpressure; a tap that falls
+// behind misses events.
+func (pg *PipelineGraph) AddTap(name string, fn TapFunc) error {
+	node, exists := pg.nodes[name]
+	if !exists {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("tap for node %q must not be nil", name)
+	}
+	node.taps = append(node.taps, fn)
+	return nil
+}
+
 // SetEntryNode sets the entry point for the pipeline
 func (pg *PipelineGraph) SetEntryNode(name string) error {
 	if _, exists := pg.nodes[name]; !exists {
@@ -191,6 +315,49 @@ func (n *graphNode) Barrier() *core.BarrierConfig {
 	return n.barrier
 }
 
+// runnable returns the stage that runs the node: its own, or for a node added
+// with AddFanOut or AddBarrier the stage built from its configuration, kept
+// across runs
+func (n *graphNode) runnable() core.Stage {
+	if n.stage != nil {
+		return n.stage
+	}
+	return n.built
+}
+
+// Timeout returns the stage deadline, zero if none is set
+func (n *graphNode) Timeout() time.Duration {
+	return n.timeout
+}
+
+// PanicPolicy returns what happens when the stage panics
+func (n *graphNode) PanicPolicy() PanicPolicy {
+	if n.panicPolicy == "" {
+		return PanicPropagate
+	}
+	return n.panicPolicy
+}
+
+// OutputBufferSize returns the capacity of the node's output channel
+func (n *graphNode) OutputBufferSize(defaultSize int) int {
+	if n.bufferSize > 0 {
+		return n.bufferSize
+	}
+	return defaultSize
+}
+
+// InputBufferSize returns the capacity of the node's input channel: its own
+// size, raised to the largest buffer of its incoming edges
+func (n *graphNode) InputBufferSize(defaultSize int) int {
+	size := n.OutputBufferSize(defaultSize)
+	for _, edge := range n.inputs {
+		if edge.bufferSize > size {
+			size = edge.bufferSize
+		}
+	}
+	return size
+}
+
 // graphEdge methods
 
 // From returns the source node
@@ -214,6 +381,20 @@ func (e *graphEdge) ShouldForwardEvent(eventType core.EventType) bool {
 	return e.eventFilter[eventType]
 }
 
+// ShouldForward checks if an event should be forwarded on this edge, applying
+// the event type filter first and then the predicate
+func (e *graphEdge) ShouldForward(event core.Event) bool {
+	if !e.ShouldForwardEvent(event.EventType()) {
+		return false
+	}
+	return e.predicate == nil || e.predicate(event)
+}
+
+// Predicate returns the payload predicate, if any
+func (e *graphEdge) Predicate() EdgePredicate {
+	return e.predicate
+}
+
 // EventFilter returns the event filter map
 func (e *graphEdge) EventFilter() map[core.EventType]bool {
 	return e.eventFilter
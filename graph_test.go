@@ -137,6 +137,70 @@ func TestGraphEdgeNoFilter(t *testing.T) {
 	}
 }
 
+// TestGraphEdgePredicate tests edges that filter on event payload
+func TestGraphEdgePredicate(t *testing.T) {
+	graph := NewPipelineGraph()
+
+	graph.AddNode("stage1", &MockStage{name: "stage1"}, nil, nil)
+	graph.AddNode("stage2", &MockStage{name: "stage2"}, nil, nil)
+
+	// Only final transcripts pass
+	finalOnly := func(event core.Event) bool {
+		stt, ok := event.(core.STTEvent)
+		return ok && stt.IsFinal
+	}
+	err := graph.AddPredicateEdge("stage1", "stage2", []core.EventType{core.EventTypeSTT}, finalOnly)
+	if err != nil {
+		t.Fatalf("failed to add edge: %v", err)
+	}
+
+	edge := graph.GetNode("stage1").Outputs()[0]
+	if !edge.ShouldForward(core.STTEvent{Text: "hi", IsFinal: true}) {
+		t.Error("edge should forward final STT events")
+	}
+	if edge.ShouldForward(core.STTEvent{Text: "h", IsFinal: false}) {
+		t.Error("edge should not forward interim STT events")
+	}
+	if edge.ShouldForward(core.LLMEvent{Delta: "hi"}) {
+		t.Error("edge should not forward LLM events")
+	}
+}
+
+// TestPipelineConnectIf tests that the runtime routes on edge predicates
+func TestPipelineConnectIf(t *testing.T) {
+	sink := &CollectingMockStage{name: "sink"}
+
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddStage("sink", sink).
+		ConnectIf("source", "sink", func(event core.Event) bool {
+			llm, ok := event.(core.LLMEvent)
+			return !ok || len(llm.Delta) > 3
+		}).
+		SetEntryNode("source").
+		AddExitNode("sink").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "hi"}
+	input <- core.LLMEvent{Delta: "hello"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	for range pipeline.Execute(context.Background(), input) {
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected 2 events at sink, got %d", len(sink.events))
+	}
+	if llm, ok := sink.events[0].(core.LLMEvent); !ok || llm.Delta != "hello" {
+		t.Errorf("expected long LLM event to pass, got %v", sink.events[0])
+	}
+}
+
 // TestGraphEdgeInvalidNode tests edge creation with non-existent nodes
 func TestGraphEdgeInvalidNode(t *testing.T) {
 	graph := NewPipelineGraph()
@@ -382,3 +446,157 @@ func TestTypeCompatibilityWithFilter(t *testing.T) {
 		t.Fatalf("compatible types with filter should pass: %v", err)
 	}
 }
+
+// TestDoneContractValidation tests that every path to an exit must carry a
+// DoneEvent
+func TestDoneContractValidation(t *testing.T) {
+	build := func(filter []core.EventType, bOutputs []core.EventType) error {
+		graph := NewPipelineGraph()
+		graph.AddNode("A", &MockStage{name: "A", outputTypes: []core.EventType{core.EventTypeSTT}}, nil, nil)
+		graph.AddNode("B", &MockStage{name: "B", outputTypes: bOutputs}, nil, nil)
+		graph.AddNode("C", &MockStage{name: "C", outputTypes: []core.EventType{core.EventTypeError}}, nil, nil)
+		graph.AddEdge("A", "B", nil)
+		graph.AddEdge("B", "C", filter)
+		graph.SetEntryNode("A")
+		graph.AddExitNode("C")
+		return ValidateGraph(graph)
+	}
+
+	if err := build(nil, []core.EventType{core.EventTypeLLM, core.EventTypeDone}); err != nil {
+		t.Errorf("path through a Done-producing stage should pass: %v", err)
+	}
+	if err := build(nil, []core.EventType{}); err != nil {
+		t.Errorf("stages producing all types should pass: %v", err)
+	}
+	if err := build(nil, []core.EventType{core.EventTypeLLM}); err == nil {
+		t.Error("expected error when no stage produces a DoneEvent")
+	}
+	if err := build([]core.EventType{core.EventTypeLLM}, []core.EventType{core.EventTypeLLM, core.EventTypeDone}); err == nil {
+		t.Error("expected error when an edge filters DoneEvents out")
+	}
+
+	// A barrier passes status updates on from the entry, and emits the
+	// DoneEvent it joins on that path too
+	join := func(bOutputs []core.EventType) error {
+		graph := NewPipelineGraph()
+		graph.AddNode("A", &MockStage{name: "A", outputTypes: []core.EventType{core.EventTypeStatus, core.EventTypeLLM}}, nil, nil)
+		graph.AddNode("B", &MockStage{name: "B", outputTypes: bOutputs}, nil, nil)
+		graph.AddNode("join", nil, nil, &core.BarrierConfig{UpstreamCount: 1})
+		graph.AddEdge("A", "B", []core.EventType{core.EventTypeLLM})
+		graph.AddEdge("A", "join", []core.EventType{core.EventTypeStatus})
+		graph.AddEdge("B", "join", nil)
+		graph.SetEntryNode("A")
+		graph.AddExitNode("join")
+		return ValidateGraph(graph)
+	}
+	if err := join([]core.EventType{core.EventTypeLLM, core.EventTypeDone}); err != nil {
+		t.Errorf("path through a barrier joining DoneEvents should pass: %v", err)
+	}
+	if err := join([]core.EventType{core.EventTypeLLM}); err == nil {
+		t.Error("expected error when no upstream sends the barrier a DoneEvent")
+	}
+}
+
+// TestGraphWarningsBarrierUpstreams tests the barrier in-degree warning
+func TestGraphWarningsBarrierUpstreams(t *testing.T) {
+	done := []core.EventType{core.EventTypeDone}
+	build := func(upstreamCount int) *Pipeline {
+		p, err := NewBuilder().
+			AddStage("source", &MockStage{name: "source", outputTypes: done}).
+			AddFanOut("fanout", core.FanOutConfig{Branches: []core.BranchConfig{
+				{Stage: &MockStage{name: "b1", outputTypes: done}},
+				{Stage: &MockStage{name: "b2", outputTypes: done}},
+			}}).
+			AddBarrier("barrier", core.BarrierConfig{UpstreamCount: upstreamCount}).
+			Connect("source", "fanout").
+			Connect("fanout", "barrier").
+			SetEntryNode("source").
+			AddExitNode("barrier").
+			Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return p
+	}
+
+	if warnings := build(2).Warnings(); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	if warnings := build(3).Warnings(); len(warnings) != 1 {
+		t.Errorf("expected a barrier warning, got %v", warnings)
+	}
+}
+
+// TestValidateGraphDetailed tests that the report separates errors from
+// warnings
+func TestValidateGraphDetailed(t *testing.T) {
+	done := []core.EventType{core.EventTypeLLM, core.EventTypeDone}
+	graph := NewPipelineGraph()
+	graph.AddNode("A", &MockStage{name: "A", outputTypes: done}, nil, nil)
+	graph.AddNode("B", &MockStage{name: "B", outputTypes: done}, nil, nil)
+	graph.AddNode("C", &MockStage{name: "C", outputTypes: done}, nil, nil)
+	graph.AddEdge("A", "B", nil)
+	graph.AddEdge("A", "C", []core.EventType{core.EventTypeSTT})
+	graph.SetEntryNode("A")
+	graph.AddExitNode("B")
+	graph.AddExitNode("B")
+
+	report := ValidateGraphDetailed(graph)
+	if err := report.Err(); err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+
+	// C is a dead end, its edge forwards nothing and B is declared twice
+	if len(report.Warnings) != 3 {
+		t.Fatalf("expected 3 warnings, got %v", report.Warnings)
+	}
+	for _, warning := range report.Warnings {
+		if warning.Message != "graph validation warning" {
+			t.Errorf("unexpected warning %v", warning)
+		}
+	}
+
+	graph.AddNode("D", &MockStage{name: "D"}, nil, nil)
+	report = ValidateGraphDetailed(graph)
+	if len(report.Errors) != 1 || report.Err() == nil {
+		t.Errorf("expected an unreachable stage error, got %v", report.Errors)
+	}
+}
+
+// TestBuilderInfersBarrierUpstreams tests that a barrier without an
+// UpstreamCount gets its in-degree
+func TestBuilderInfersBarrierUpstreams(t *testing.T) {
+	done := []core.EventType{core.EventTypeDone}
+	inferred := &core.BarrierConfig{}
+	explicit := &core.BarrierConfig{UpstreamCount: 1}
+
+	p, err := NewBuilder().
+		AddStage("source", &MockStage{name: "source", outputTypes: done}).
+		AddStage("a", &MockStage{name: "a", outputTypes: done}).
+		AddStage("b", &MockStage{name: "b", outputTypes: done}).
+		AddStage("join", NewBarrierStage("join", inferred)).
+		AddStage("override", NewBarrierStage("override", explicit)).
+		Connect("source", "a").
+		Connect("source", "b").
+		Connect("a", "join").
+		Connect("b", "join").
+		Connect("a", "override").
+		Connect("b", "override").
+		SetEntryNode("source").
+		AddExitNode("join").
+		AddExitNode("override").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if inferred.UpstreamCount != 2 {
+		t.Errorf("expected inferred UpstreamCount 2, got %d", inferred.UpstreamCount)
+	}
+	if explicit.UpstreamCount != 1 {
+		t.Errorf("expected explicit UpstreamCount kept, got %d", explicit.UpstreamCount)
+	}
+	if warnings := p.Warnings(); len(warnings) != 1 {
+		t.Errorf("expected a warning for the override, got %v", warnings)
+	}
+}
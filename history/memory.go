@@ -0,0 +1,100 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps conversations in process memory
+type MemoryStore struct {
+	mu            sync.Mutex
+	conversations map[string]*memoryConversation
+}
+
+type memoryConversation struct {
+	messages []Message
+	version  int // Bumped whenever older messages are replaced
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[string]*memoryConversation),
+	}
+}
+
+// Append implements Store
+func (s *MemoryStore) Append(ctx context.Context, conversationID string, messages ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.conversations[conversationID]
+	if c == nil {
+		c = &memoryConversation{}
+		s.conversations[conversationID] = c
+	}
+	for _, msg := range messages {
+		if msg.CreatedAt.IsZero() {
+			msg.CreatedAt = time.Now()
+		}
+		c.messages = append(c.messages, msg)
+	}
+	return nil
+}
+
+// LoadRecent implements Store
+func (s *MemoryStore) LoadRecent(ctx context.Context, conversationID string, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.conversations[conversationID]
+	if c == nil {
+		return nil, nil
+	}
+	messages := c.messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return append([]Message(nil), messages...), nil
+}
+
+// Summarize implements Store
+func (s *MemoryStore) Summarize(ctx context.Context, conversationID string, keep int, summarize SummarizeFunc) error {
+	s.mu.Lock()
+	c := s.conversations[conversationID]
+	if c == nil || len(c.messages) <= keep {
+		s.mu.Unlock()
+		return nil
+	}
+	split := len(c.messages) - keep
+	older := append([]Message(nil), c.messages[:split]...)
+	version := c.version
+	s.mu.Unlock()
+
+	// Summarize without holding the lock; the hook may call an LLM
+	summary, err := summarize(ctx, older)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Messages only grow at the end, so the older ones are still in place
+	// unless they were replaced or cleared meanwhile
+	if s.conversations[conversationID] != c || c.version != version {
+		return nil
+	}
+	c.messages = append([]Message{{Role: RoleSystem, Content: summary, CreatedAt: time.Now()}}, c.messages[split:]...)
+	c.version++
+	return nil
+}
+
+// Clear implements Store
+func (s *MemoryStore) Clear(ctx context.Context, conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conversations, conversationID)
+	return nil
+}
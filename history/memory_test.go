@@ -0,0 +1,116 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testStore runs the Store contract against a store
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if err := store.Append(ctx, "c1",
+		Message{Role: RoleUser, Content: "one", TurnID: "t1"},
+		Message{Role: RoleAssistant, Content: "two", TurnID: "t1", ResponseID: "r1", Tokens: 3},
+	); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Append(ctx, "c1", Message{Role: RoleUser, Content: "three"}, Message{Role: RoleAssistant, Content: "four"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Append(ctx, "c2", Message{Role: RoleUser, Content: "other"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	all, err := store.LoadRecent(ctx, "c1", 0)
+	if err != nil {
+		t.Fatalf("LoadRecent failed: %v", err)
+	}
+	if contents(all) != "one two three four" {
+		t.Fatalf("unexpected messages %q", contents(all))
+	}
+	if m := all[1]; m.Role != RoleAssistant || m.TurnID != "t1" || m.ResponseID != "r1" || m.Tokens != 3 || m.CreatedAt.IsZero() {
+		t.Errorf("unexpected message %+v", m)
+	}
+
+	recent, err := store.LoadRecent(ctx, "c1", 3)
+	if err != nil || contents(recent) != "two three four" {
+		t.Errorf("expected the last 3 messages, got %q, %v", contents(recent), err)
+	}
+
+	// Summarize everything but the last two messages
+	var summarized []Message
+	err = store.Summarize(ctx, "c1", 2, func(ctx context.Context, messages []Message) (string, error) {
+		summarized = messages
+		return "summary", nil
+	})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if contents(summarized) != "one two" {
+		t.Errorf("expected the older messages to be summarized, got %q", contents(summarized))
+	}
+	all, _ = store.LoadRecent(ctx, "c1", 0)
+	if contents(all) != "summary three four" || all[0].Role != RoleSystem {
+		t.Errorf("expected the summary before the kept messages, got %+v", all)
+	}
+
+	// Failing hooks leave the conversation alone
+	boom := errors.New("boom")
+	if err := store.Summarize(ctx, "c1", 1, func(context.Context, []Message) (string, error) { return "", boom }); !errors.Is(err, boom) {
+		t.Errorf("expected the hook's error, got %v", err)
+	}
+	if all, _ := store.LoadRecent(ctx, "c1", 0); len(all) != 3 {
+		t.Errorf("expected 3 messages after a failed summary, got %d", len(all))
+	}
+
+	// Appends after a summary keep their order
+	store.Append(ctx, "c1", Message{Role: RoleUser, Content: "five"})
+	if all, _ := store.LoadRecent(ctx, "c1", 0); contents(all) != "summary three four five" {
+		t.Errorf("unexpected messages after summary %q", contents(all))
+	}
+
+	if err := store.Clear(ctx, "c1"); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if all, _ := store.LoadRecent(ctx, "c1", 0); len(all) != 0 {
+		t.Errorf("expected no messages after Clear, got %d", len(all))
+	}
+	if other, _ := store.LoadRecent(ctx, "c2", 0); contents(other) != "other" {
+		t.Errorf("expected other conversations untouched, got %q", contents(other))
+	}
+}
+
+func contents(messages []Message) string {
+	parts := make([]string, len(messages))
+	for i, msg := range messages {
+		parts[i] = msg.Content
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// TestMemoryStoreSummarizeConflict tests that a summary is dropped when the
+// conversation is cleared while the hook runs
+func TestMemoryStoreSummarizeConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Append(ctx, "c1", Message{Content: "one"}, Message{Content: "two"})
+
+	err := store.Summarize(ctx, "c1", 1, func(ctx context.Context, messages []Message) (string, error) {
+		store.Clear(ctx, "c1")
+		store.Append(ctx, "c1", Message{Content: "fresh"})
+		return "stale summary", nil
+	})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if all, _ := store.LoadRecent(ctx, "c1", 0); contents(all) != "fresh" {
+		t.Errorf("expected the stale summary to be dropped, got %q", contents(all))
+	}
+}
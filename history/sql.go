@@ -0,0 +1,236 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Placeholder styles of SQL drivers
+const (
+	PlaceholderQuestion = "?" // MySQL, SQLite
+	PlaceholderDollar   = "$" // PostgreSQL
+)
+
+const defaultSQLTable = "conversation_messages"
+
+// SQLStoreConfig holds SQL store configuration
+type SQLStoreConfig struct {
+	DB *sql.DB
+
+	// Table holds the messages (default: conversation_messages), see
+	// CreateTable for its schema
+	Table string
+
+	// Placeholder is the driver's bind parameter style (default: "?")
+	Placeholder string
+}
+
+// SQLStore keeps conversations in a SQL database through database/sql.
+// Messages are ordered by a per-conversation sequence number; concurrent
+// appends to the same conversation from several processes may fail on the
+// primary key and should be retried.
+type SQLStore struct {
+	config SQLStoreConfig
+}
+
+// NewSQLStore creates a store on an existing database handle
+func NewSQLStore(config SQLStoreConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = defaultSQLTable
+	}
+	if config.Placeholder == "" {
+		config.Placeholder = PlaceholderQuestion
+	}
+	return &SQLStore{
+		config: config,
+	}
+}
+
+// CreateTable creates the messages table if it doesn't exist
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := s.config.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.config.Table+` (
+	conversation_id VARCHAR(255) NOT NULL,
+	seq BIGINT NOT NULL,
+	role VARCHAR(32) NOT NULL,
+	content TEXT NOT NULL,
+	turn_id VARCHAR(255) NOT NULL,
+	response_id VARCHAR(255) NOT NULL,
+	tokens INTEGER NOT NULL,
+	created_at BIGINT NOT NULL,
+	PRIMARY KEY (conversation_id, seq)
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create table %s: %w", s.config.Table, err)
+	}
+	return nil
+}
+
+// Append implements Store
+func (s *SQLStore) Append(ctx context.Context, conversationID string, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var last int64
+		err := tx.QueryRowContext(ctx, s.query(`SELECT COALESCE(MAX(seq), 0) FROM %s WHERE conversation_id = ?`), conversationID).Scan(&last)
+		if err != nil {
+			return fmt.Errorf("failed to read last message: %w", err)
+		}
+		for i, msg := range messages {
+			if err := s.insert(ctx, tx, conversationID, last+int64(i)+1, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadRecent implements Store
+func (s *SQLStore) LoadRecent(ctx context.Context, conversationID string, limit int) ([]Message, error) {
+	query := `SELECT seq, role, content, turn_id, response_id, tokens, created_at FROM %s WHERE conversation_id = ? ORDER BY seq DESC`
+	args := []any{conversationID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	_, messages, err := s.load(ctx, s.query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows come newest first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// Summarize implements Store
+func (s *SQLStore) Summarize(ctx context.Context, conversationID string, keep int, summarize SummarizeFunc) error {
+	query := s.query(`SELECT seq, role, content, turn_id, response_id, tokens, created_at FROM %s WHERE conversation_id = ? ORDER BY seq`)
+	seqs, messages, err := s.load(ctx, query, conversationID)
+	if err != nil {
+		return err
+	}
+	if len(messages) <= keep {
+		return nil
+	}
+	split := len(messages) - keep
+
+	// Summarize outside the transaction; the hook may call an LLM
+	summary, err := summarize(ctx, messages[:split])
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		// The summary takes the slot of the newest summarized message, so the
+		// order is kept without renumbering
+		result, err := tx.ExecContext(ctx, s.query(`DELETE FROM %s WHERE conversation_id = ? AND seq <= ?`), conversationID, seqs[split-1])
+		if err != nil {
+			return fmt.Errorf("failed to delete summarized messages: %w", err)
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted != int64(split) {
+			// Replaced or cleared meanwhile
+			return errSummarizeConflict
+		}
+		return s.insert(ctx, tx, conversationID, seqs[split-1], Message{Role: RoleSystem, Content: summary})
+	})
+}
+
+// Clear implements Store
+func (s *SQLStore) Clear(ctx context.Context, conversationID string) error {
+	if _, err := s.config.DB.ExecContext(ctx, s.query(`DELETE FROM %s WHERE conversation_id = ?`), conversationID); err != nil {
+		return fmt.Errorf("failed to clear conversation: %w", err)
+	}
+	return nil
+}
+
+// errSummarizeConflict rolls back a summary whose messages changed meanwhile
+var errSummarizeConflict = errors.New("summarized messages changed")
+
+// inTx runs fn in a transaction, committing if it succeeds
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errSummarizeConflict) {
+			return nil
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// insert writes a single message
+func (s *SQLStore) insert(ctx context.Context, tx *sql.Tx, conversationID string, seq int64, msg Message) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	_, err := tx.ExecContext(ctx,
+		s.query(`INSERT INTO %s (conversation_id, seq, role, content, turn_id, response_id, tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		conversationID, seq, msg.Role, msg.Content, msg.TurnID, msg.ResponseID, msg.Tokens, msg.CreatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to insert message: %w", err)
+	}
+	return nil
+}
+
+// load reads messages and their sequence numbers
+func (s *SQLStore) load(ctx context.Context, query string, args ...any) ([]int64, []Message, error) {
+	rows, err := s.config.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	defer rows.Close()
+
+	var seqs []int64
+	var messages []Message
+	for rows.Next() {
+		var seq, createdAt int64
+		var msg Message
+		if err := rows.Scan(&seq, &msg.Role, &msg.Content, &msg.TurnID, &msg.ResponseID, &msg.Tokens, &createdAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		msg.CreatedAt = time.UnixMilli(createdAt)
+		seqs = append(seqs, seq)
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	return seqs, messages, nil
+}
+
+// query fills in the table name and rewrites ? placeholders to the
+// driver's style
+func (s *SQLStore) query(query string) string {
+	query = fmt.Sprintf(query, s.config.Table)
+	if s.config.Placeholder != PlaceholderDollar {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
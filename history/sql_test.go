@@ -0,0 +1,189 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql driver that understands exactly the statements
+// SQLStore issues, backed by a slice of rows
+type fakeDB struct {
+	mu      sync.Mutex
+	rows    []fakeRow
+	queries []string
+}
+
+type fakeRow struct {
+	conversationID string
+	seq            int64
+	values         []driver.Value // role, content, turn_id, response_id, tokens, created_at
+}
+
+var fakeDrivers sync.Map
+
+func init() {
+	sql.Register("history-fake", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDrivers.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake database %q", name)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{}
+	fakeDrivers.Store(t.Name(), fake)
+	db, err := sql.Open("history-fake", t.Name())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+type fakeConn struct {
+	db       *fakeDB
+	snapshot []fakeRow
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.snapshot = append([]fakeRow(nil), c.db.rows...)
+	c.db.mu.Unlock()
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error { return nil }
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	c.db.rows = c.snapshot
+	c.db.mu.Unlock()
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "INSERT INTO"):
+		values := make([]driver.Value, 0, 6)
+		for _, arg := range args[2:] {
+			values = append(values, arg.Value)
+		}
+		db.rows = append(db.rows, fakeRow{conversationID: args[0].Value.(string), seq: args[1].Value.(int64), values: values})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM"):
+		var kept []fakeRow
+		for _, row := range db.rows {
+			if row.conversationID == args[0].Value && (len(args) == 1 || row.seq <= args[1].Value.(int64)) {
+				continue
+			}
+			kept = append(kept, row)
+		}
+		deleted := len(db.rows) - len(kept)
+		db.rows = kept
+		return driver.RowsAffected(deleted), nil
+	}
+	return nil, fmt.Errorf("unexpected statement %q", query)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+
+	var matched []fakeRow
+	for _, row := range db.rows {
+		if row.conversationID == args[0].Value {
+			matched = append(matched, row)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq < matched[j].seq })
+
+	if strings.HasPrefix(query, "SELECT COALESCE(MAX(seq), 0)") {
+		var last int64
+		if len(matched) > 0 {
+			last = matched[len(matched)-1].seq
+		}
+		return &fakeRows{columns: []string{"max"}, values: [][]driver.Value{{last}}}, nil
+	}
+
+	if strings.Contains(query, "ORDER BY seq DESC") {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+		if len(args) > 1 && int(args[1].Value.(int64)) < len(matched) {
+			matched = matched[:args[1].Value.(int64)]
+		}
+	}
+
+	rows := &fakeRows{columns: []string{"seq", "role", "content", "turn_id", "response_id", "tokens", "created_at"}}
+	for _, row := range matched {
+		rows.values = append(rows.values, append([]driver.Value{row.seq}, row.values...))
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	db, _ := openFakeDB(t)
+	store := NewSQLStore(SQLStoreConfig{DB: db})
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestSQLStorePlaceholders(t *testing.T) {
+	db, fake := openFakeDB(t)
+	store := NewSQLStore(SQLStoreConfig{DB: db, Table: "chat", Placeholder: PlaceholderDollar})
+
+	if _, err := store.LoadRecent(context.Background(), "c1", 5); err != nil {
+		t.Fatalf("LoadRecent failed: %v", err)
+	}
+	want := "SELECT seq, role, content, turn_id, response_id, tokens, created_at FROM chat WHERE conversation_id = $1 ORDER BY seq DESC LIMIT $2"
+	if len(fake.queries) != 1 || fake.queries[0] != want {
+		t.Errorf("expected query %q, got %q", want, fake.queries)
+	}
+}
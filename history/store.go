@@ -0,0 +1,48 @@
+// Package history stores conversations so that the stages recording them
+// and the stages reading them share one source of truth
+package history
+
+import (
+	"context"
+	"time"
+)
+
+// Message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleSystem    = "system" // Summaries of earlier messages
+)
+
+// Message is a stored conversation message
+type Message struct {
+	Role       string
+	Content    string
+	TurnID     string
+	ResponseID string
+	Tokens     int
+	CreatedAt  time.Time
+}
+
+// SummarizeFunc compresses messages into the content of a single summary
+// message
+type SummarizeFunc func(ctx context.Context, messages []Message) (string, error)
+
+// Store persists conversations by ID. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Append adds messages to the end of a conversation
+	Append(ctx context.Context, conversationID string, messages ...Message) error
+
+	// LoadRecent returns the last limit messages of a conversation, oldest
+	// first; limit <= 0 returns all
+	LoadRecent(ctx context.Context, conversationID string, limit int) ([]Message, error)
+
+	// Summarize replaces all but the keep most recent messages with a single
+	// system message produced by summarize. It does nothing if the older
+	// messages change while summarize runs.
+	Summarize(ctx context.Context, conversationID string, keep int, summarize SummarizeFunc) error
+
+	// Clear deletes a conversation
+	Clear(ctx context.Context, conversationID string) error
+}
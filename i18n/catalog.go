@@ -0,0 +1,152 @@
+// Package i18n localizes the service messages stages send to users. Stages
+// emit ServiceMessageEvents with a message key and parameters; a Catalog of
+// per-locale templates resolves them into text, in a LocalizeStage or when
+// sinks encode the message.
+package i18n
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// DefaultLocale is the locale Catalogs fall back to unless configured
+// otherwise
+const DefaultLocale = "en"
+
+// Catalog holds message templates by key and locale. Templates reference
+// parameters as {name}. A Catalog is safe for concurrent use, so it can be
+// reloaded while sessions use it.
+type Catalog struct {
+	mu        sync.RWMutex
+	fallback  string
+	templates map[string]map[string]string // Key -> locale -> template
+}
+
+// NewCatalog creates an empty catalog that falls back to the given locale
+// when a message has no template for the requested one (default:
+// DefaultLocale)
+func NewCatalog(fallback string) *Catalog {
+	if fallback == "" {
+		fallback = DefaultLocale
+	}
+	return &Catalog{
+		fallback:  fallback,
+		templates: make(map[string]map[string]string),
+	}
+}
+
+// Set adds or replaces the template of a message in a locale
+func (c *Catalog) Set(key, locale, template string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.templates[key] == nil {
+		c.templates[key] = make(map[string]string)
+	}
+	c.templates[key][locale] = template
+}
+
+// Load adds the loader's templates to the catalog, replacing existing ones
+// for the same key and locale
+func (c *Catalog) Load(loader Loader) error {
+	templates, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	for key, locales := range templates {
+		for locale, template := range locales {
+			c.Set(key, locale, template)
+		}
+	}
+	return nil
+}
+
+// Has reports whether the catalog has any template for a message
+func (c *Catalog) Has(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.templates[key]) > 0
+}
+
+// Locales returns the locales a message has templates for, sorted
+func (c *Catalog) Locales(key string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := make([]string, 0, len(c.templates[key]))
+	for locale := range c.templates[key] {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Resolve renders a message in a locale, trying the full tag before its
+// primary subtag ("pt-BR", then "pt") and then the fallback locale. It
+// reports false if the message has no template in any of them.
+func (c *Catalog) Resolve(key, locale string, params map[string]string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := c.templates[key]
+	for _, candidate := range candidateLocales(locale, c.fallback) {
+		if template, ok := locales[candidate]; ok {
+			return render(template, params), true
+		}
+	}
+	return "", false
+}
+
+// Localize resolves a keyed service message: Content is rendered in the
+// given locale, or the fallback locale, and Localized in every locale the
+// catalog has, keeping translations the event already carries. Messages
+// without a key, or with a key the catalog doesn't know, are returned
+// unchanged.
+func (c *Catalog) Localize(event core.ServiceMessageEvent, locale string) core.ServiceMessageEvent {
+	if event.Key == "" || !c.Has(event.Key) {
+		return event
+	}
+
+	if content, ok := c.Resolve(event.Key, locale, event.Params); ok {
+		event.Content = content
+	}
+
+	localized := make(map[string]string, len(event.Localized))
+	for _, l := range c.Locales(event.Key) {
+		localized[l], _ = c.Resolve(event.Key, l, event.Params)
+	}
+	for l, text := range event.Localized {
+		localized[l] = text
+	}
+	event.Localized = localized
+	return event
+}
+
+// candidateLocales lists the locales to try for a requested one, in order
+func candidateLocales(locale, fallback string) []string {
+	var candidates []string
+	if locale != "" {
+		candidates = append(candidates, locale)
+		if primary, _, found := strings.Cut(locale, "-"); found {
+			candidates = append(candidates, primary)
+		}
+	}
+	return append(candidates, fallback)
+}
+
+// render substitutes {name} references in a template with params. Unknown
+// references are left as they are.
+func render(template string, params map[string]string) string {
+	if len(params) == 0 {
+		return template
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
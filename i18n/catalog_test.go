@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestCatalogResolve(t *testing.T) {
+	c := NewCatalog("")
+	c.Set("greeting", "en", "Hello {name}")
+	c.Set("greeting", "pt", "Olá {name}")
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"pt", "Olá Ana"},
+		{"pt-BR", "Olá Ana"},
+		{"de", "Hello Ana"},
+		{"", "Hello Ana"},
+	}
+	for _, tt := range tests {
+		if got, ok := c.Resolve("greeting", tt.locale, map[string]string{"name": "Ana"}); !ok || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+	if _, ok := c.Resolve("missing", "en", nil); ok {
+		t.Error("expected an unknown key not to resolve")
+	}
+}
+
+func TestCatalogLocalize(t *testing.T) {
+	c := Default()
+	c.Set(MsgVoiceUnavailable, "de", "Meine Stimme funktioniert gerade nicht.")
+
+	event := c.Localize(NewMessage(core.ServiceMessageWarning, MsgVoiceUnavailable, nil), "de")
+	if event.Content != "Meine Stimme funktioniert gerade nicht." {
+		t.Errorf("unexpected content %q", event.Content)
+	}
+	if got := []string{event.Localized["en"], event.Localized["ru"]}; got[0] == "" || got[1] == "" {
+		t.Errorf("expected every locale, got %v", event.Localized)
+	}
+
+	free := core.ServiceMessageEvent{Content: "free-form"}
+	if got := c.Localize(free, "de"); !reflect.DeepEqual(got, free) {
+		t.Errorf("expected messages without a key unchanged, got %+v", got)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	event := NewMessage(core.ServiceMessageWarning, MsgLoopLimit, map[string]string{"loop": "agent", "iterations": "5"})
+	if event.Content != "loop agent stopped after 5 iterations" || event.Key != MsgLoopLimit {
+		t.Errorf("unexpected message %+v", event)
+	}
+}
+
+func TestFSLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.json":    {Data: []byte(`{"stt.not_understood": "Say again?"}`)},
+		"pt-BR.yaml": {Data: []byte("stt.not_understood: Pode repetir?\n")},
+		"README.md":  {Data: []byte("ignored")},
+	}
+
+	c := NewCatalog("en")
+	if err := c.Load(FSLoader(fsys)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, _ := c.Resolve(MsgNotUnderstood, "pt-BR", nil); got != "Pode repetir?" {
+		t.Errorf("unexpected pt-BR template %q", got)
+	}
+	if got := c.Locales(MsgNotUnderstood); !reflect.DeepEqual(got, []string{"en", "pt-BR"}) {
+		t.Errorf("unexpected locales %v", got)
+	}
+
+	fsys["de.json"] = &fstest.MapFile{Data: []byte("not json")}
+	if err := NewCatalog("en").Load(FSLoader(fsys)); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+}
@@ -0,0 +1,72 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Loader supplies catalog templates, by message key and then locale, e.g.
+// from files, a database or a translation service
+type Loader interface {
+	Load() (map[string]map[string]string, error)
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func() (map[string]map[string]string, error)
+
+// Load calls f
+func (f LoaderFunc) Load() (map[string]map[string]string, error) {
+	return f()
+}
+
+// FSLoader loads one file per locale from the root of fsys, named after the
+// locale: "en.json", "pt-BR.yaml". Each file maps message keys to templates;
+// files of other types are ignored.
+func FSLoader(fsys fs.FS) Loader {
+	return LoaderFunc(func() (map[string]map[string]string, error) {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list message files: %w", err)
+		}
+
+		templates := make(map[string]map[string]string)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			ext := path.Ext(entry.Name())
+			var unmarshal func([]byte, any) error
+			switch ext {
+			case ".json":
+				unmarshal = json.Unmarshal
+			case ".yaml", ".yml":
+				unmarshal = yaml.Unmarshal
+			default:
+				continue
+			}
+
+			data, err := fs.ReadFile(fsys, entry.Name())
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+			}
+			var messages map[string]string
+			if err := unmarshal(data, &messages); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+			}
+
+			locale := strings.TrimSuffix(entry.Name(), ext)
+			for key, template := range messages {
+				if templates[key] == nil {
+					templates[key] = make(map[string]string)
+				}
+				templates[key][locale] = template
+			}
+		}
+		return templates, nil
+	})
+}
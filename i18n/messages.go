@@ -0,0 +1,92 @@
+package i18n
+
+import "github.com/creastat/pipeline/core"
+
+// Keys of the service messages built-in stages send
+const (
+	// MsgTranscriptionFailed asks the user to repeat after an STT failure
+	MsgTranscriptionFailed = "stt.transcription_failed"
+
+	// MsgNotUnderstood asks the user to repeat when nothing was transcribed
+	MsgNotUnderstood = "stt.not_understood"
+
+	// MsgLowConfidence asks the user to repeat when the transcript was too
+	// uncertain to answer. Params: text, confidence.
+	MsgLowConfidence = "stt.low_confidence"
+
+	// MsgVoiceUnavailable tells the user TTS failed and the reply continues
+	// as text
+	MsgVoiceUnavailable = "tts.voice_unavailable"
+
+	// MsgBarrierTimeout warns that a barrier stopped waiting for branches.
+	// Params: barrier, timeout, missing, total.
+	MsgBarrierTimeout = "pipeline.barrier_timeout"
+
+	// MsgLoopLimit warns that a loop reached its iteration limit. Params:
+	// loop, iterations.
+	MsgLoopLimit = "pipeline.loop_limit"
+
+	// MsgProfanityBlocked tells the user the profanity filter blocked a
+	// message
+	MsgProfanityBlocked = "filter.profanity_blocked"
+)
+
+// builtin holds the templates of the built-in messages
+var builtin = map[string]map[string]string{
+	MsgTranscriptionFailed: {
+		"en": "Error transcribing audio. Please try again.",
+		"es": "Error al transcribir audio. Por favor, intenta de nuevo.",
+		"fr": "Erreur lors de la transcription audio. Veuillez réessayer.",
+	},
+	MsgNotUnderstood: {
+		"en": "Could not understand your input. Please try again.",
+		"es": "No pude entender tu entrada. Por favor, intenta de nuevo.",
+		"fr": "Je n'ai pas pu comprendre votre entrée. Veuillez réessayer.",
+	},
+	MsgLowConfidence: {
+		"en": "Sorry, I'm not sure I heard that right. Could you say it again?",
+		"es": "Perdona, no estoy seguro de haberte entendido bien. ¿Puedes repetirlo?",
+		"fr": "Désolé, je ne suis pas sûr d'avoir bien entendu. Pouvez-vous répéter ?",
+	},
+	MsgVoiceUnavailable: {
+		"en": "I'm having trouble with my voice right now, but I can still chat via text.",
+		"ru": "У меня возникли проблемы с голосом, но я всё ещё могу общаться текстом.",
+	},
+	MsgBarrierTimeout: {
+		"en": "barrier {barrier} timed out after {timeout} waiting for {missing} of {total} branches",
+	},
+	MsgLoopLimit: {
+		"en": "loop {loop} stopped after {iterations} iterations",
+	},
+	MsgProfanityBlocked: {
+		"en": "That message was blocked by the profanity filter. Please rephrase it.",
+		"es": "El filtro de lenguaje ofensivo bloqueó ese mensaje. Por favor, reformúlalo.",
+		"fr": "Ce message a été bloqué par le filtre de grossièretés. Veuillez le reformuler.",
+	},
+}
+
+// defaultCatalog renders Content for NewMessage
+var defaultCatalog = Default()
+
+// Default returns a new catalog with the templates of the built-in
+// messages, to extend with Set or Load
+func Default() *Catalog {
+	c := NewCatalog(DefaultLocale)
+	c.Load(LoaderFunc(func() (map[string]map[string]string, error) {
+		return builtin, nil
+	}))
+	return c
+}
+
+// NewMessage creates a keyed service message. Content is rendered in
+// DefaultLocale from the built-in templates, for consumers that don't
+// localize; Localized is left for a Catalog to fill.
+func NewMessage(messageType core.ServiceMessageType, key string, params map[string]string) core.ServiceMessageEvent {
+	content, _ := defaultCatalog.Resolve(key, DefaultLocale, params)
+	return core.ServiceMessageEvent{
+		MessageType: messageType,
+		Content:     content,
+		Key:         key,
+		Params:      params,
+	}
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestPipelineBroadcastsInterrupt tests that an InterruptEvent reaches every
+// stage regardless of edge filters and is reported on the pipeline output
+func TestPipelineBroadcastsInterrupt(t *testing.T) {
+	first := &CollectingMockStage{name: "first"}
+	second := &CollectingMockStage{name: "second"}
+
+	pipeline, err := NewBuilder().
+		AddStage("first", first).
+		AddStage("second", second).
+		Connect("first", "second", core.EventTypeSTT).
+		SetEntryNode("first").
+		AddExitNode("second").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	input := make(chan core.Event, 2)
+	input <- core.STTEvent{Text: "hello"}
+	input <- core.InterruptEvent{Reason: "user_speech"}
+	close(input)
+
+	var interrupts int
+	for event := range pipeline.Execute(ctx, input) {
+		if _, ok := event.(core.InterruptEvent); ok {
+			interrupts++
+		}
+	}
+
+	if interrupts == 0 {
+		t.Error("expected interrupt on pipeline output")
+	}
+
+	for _, stage := range []*CollectingMockStage{first, second} {
+		var received int
+		for _, event := range stage.events {
+			if e, ok := event.(core.InterruptEvent); ok {
+				received++
+				if e.Meta.CorrelationID == "" {
+					t.Errorf("stage %s: interrupt missing correlation ID", stage.name)
+				}
+			}
+		}
+		if received != 1 {
+			t.Errorf("stage %s: expected 1 interrupt, got %d", stage.name, received)
+		}
+	}
+}
+
+// TestPipelineInterruptDoesNotBlockCancel tests that an Interrupt waiting
+// for the caller to read the output doesn't block other pipeline methods
+func TestPipelineInterruptDoesNotBlockCancel(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("only", &CollectingMockStage{name: "only"}).
+		SetEntryNode("only").
+		AddExitNode("only").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	input := make(chan core.Event)
+	defer close(input)
+	output := pipeline.Execute(ctx, input) // Not read until cancelled
+
+	interrupted := make(chan struct{})
+	go func() {
+		defer close(interrupted)
+		for i := 0; i < 1000; i++ {
+			pipeline.Interrupt("flood")
+		}
+	}()
+	time.Sleep(50 * time.Millisecond) // Let the output fill up
+
+	cancelled := make(chan struct{})
+	go func() {
+		pipeline.Cancel()
+		close(cancelled)
+	}()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Cancel blocked behind an Interrupt waiting on the output")
+	}
+
+	<-interrupted
+	for range output {
+	}
+}
+
+// TestPipelineInterruptNotRunning tests that Interrupt is a no-op when idle
+func TestPipelineInterruptNotRunning(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("only", &CollectingMockStage{name: "only"}).
+		SetEntryNode("only").
+		AddExitNode("only").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	pipeline.Interrupt("idle")
+}
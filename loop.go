@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// DefaultLoopMaxIterations bounds a loop whose config sets no limit
+const DefaultLoopMaxIterations = 5
+
+// LoopNext decides whether a loop runs another iteration. It receives the
+// 1-based number of the iteration that just finished and the events its body
+// emitted, and returns the input of the next iteration, e.g. tool results
+// for the LLM. Returning no events ends the loop.
+type LoopNext func(iteration int, outputs []core.Event) []core.Event
+
+// LoopConfig configures an iterative subgraph
+type LoopConfig struct {
+	// Body is the subgraph run once per iteration, e.g. LLM -> tool. It runs
+	// once at a time, so it must not be used elsewhere.
+	Body *Pipeline
+
+	// Next feeds each iteration's output back into the body
+	Next LoopNext
+
+	// MaxIterations guards against runaway loops (default:
+	// DefaultLoopMaxIterations). When it is reached the loop ends with a
+	// warning ServiceMessageEvent.
+	MaxIterations int
+}
+
+// LoopStage runs an iterative subgraph, modeling cycles such as
+// LLM -> tool -> LLM that graphs reject. Each turn of input, the events up
+// to and including a DoneEvent, runs the body once; while Next returns
+// events they run the body again, up to MaxIterations. Body output streams
+// downstream as it arrives, except DoneEvents: the turn ends with one
+// DoneEvent folding those of every iteration with core.MergeDone.
+type LoopStage struct {
+	name   string
+	config LoopConfig
+}
+
+// NewLoopStage creates a new loop stage
+func NewLoopStage(name string, config LoopConfig) *LoopStage {
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = DefaultLoopMaxIterations
+	}
+	return &LoopStage{
+		name:   name,
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (ls *LoopStage) Name() string {
+	return ls.name
+}
+
+// InputTypes returns the event types the body's entry node accepts
+func (ls *LoopStage) InputTypes() []core.EventType {
+	return AsStage(ls.config.Body).InputTypes()
+}
+
+// OutputTypes returns the event types the body produces, plus the loop's
+// warning and DoneEvent
+func (ls *LoopStage) OutputTypes() []core.EventType {
+	types := AsStage(ls.config.Body).OutputTypes()
+	if len(types) == 0 {
+		return types
+	}
+	return append(types, core.EventTypeServiceMessage, core.EventTypeDone)
+}
+
+// Process implements the Stage interface
+func (ls *LoopStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for {
+		// Wait for the first event of the next turn
+		var first core.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+			first = event
+		}
+
+		open, err := ls.runTurn(ctx, first, input, output)
+		if err != nil || !open {
+			return err
+		}
+	}
+}
+
+// runTurn runs the iterations of one turn, starting with its first event
+// and the rest of the turn read from input. It reports whether input is
+// still open.
+func (ls *LoopStage) runTurn(ctx context.Context, first core.Event, input <-chan core.Event, output chan<- core.Event) (bool, error) {
+	// The first iteration streams the turn from input
+	turn := make(chan core.Event)
+	inputOpen := make(chan bool, 1)
+	go func() {
+		defer close(turn)
+		event, ok := first, true
+		for ok {
+			select {
+			case <-ctx.Done():
+				inputOpen <- false
+				return
+			case turn <- event:
+			}
+			if event.EventType() == core.EventTypeDone {
+				inputOpen <- true
+				return
+			}
+			select {
+			case <-ctx.Done():
+				inputOpen <- false
+				return
+			case event, ok = <-input:
+			}
+		}
+		inputOpen <- false
+	}()
+
+	var done core.DoneEvent
+	var body <-chan core.Event = turn
+	for iteration := 1; ; iteration++ {
+		outputs, err := ls.runIteration(ctx, body, output, &done)
+		if iteration == 1 {
+			// Drain the rest of a turn the body stopped reading
+			for range turn {
+			}
+		}
+		if err != nil {
+			return false, fmt.Errorf("loop %s iteration %d: %w", ls.name, iteration, err)
+		}
+
+		next := ls.config.Next(iteration, outputs)
+		if len(next) == 0 {
+			break
+		}
+		if iteration == ls.config.MaxIterations {
+			warning := i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgLoopLimit, map[string]string{
+				"loop":       ls.name,
+				"iterations": strconv.Itoa(iteration),
+			})
+			if err := sendEvent(ctx, output, warning); err != nil {
+				return false, err
+			}
+			break
+		}
+
+		// Later iterations read the events Next returned, ended by a
+		// DoneEvent like any turn
+		if next[len(next)-1].EventType() != core.EventTypeDone {
+			next = append(next, core.DoneEvent{})
+		}
+		queued := make(chan core.Event, len(next))
+		for _, event := range next {
+			queued <- event
+		}
+		close(queued)
+		body = queued
+	}
+
+	if err := sendEvent(ctx, output, done); err != nil {
+		return false, err
+	}
+	return <-inputOpen, nil
+}
+
+// runIteration runs the body once over in, forwarding its output except
+// DoneEvents, which are folded into done. It returns everything the body
+// emitted.
+func (ls *LoopStage) runIteration(ctx context.Context, in <-chan core.Event, output chan<- core.Event, done *core.DoneEvent) ([]core.Event, error) {
+	bodyOutput := make(chan core.Event)
+	result := make(chan error, 1)
+	go func() {
+		result <- ls.config.Body.run(ctx, in, bodyOutput)
+		close(bodyOutput)
+	}()
+
+	var outputs []core.Event
+	for event := range bodyOutput {
+		outputs = append(outputs, event)
+		if doneEvent, ok := event.(core.DoneEvent); ok {
+			*done = core.MergeDone(*done, doneEvent)
+			continue
+		}
+		if err := sendEvent(ctx, output, event); err != nil {
+			// Unblock the body so it can stop
+			for range bodyOutput {
+			}
+			return nil, err
+		}
+	}
+	return outputs, <-result
+}
+
+// sendEvent writes an event to output unless ctx is cancelled
+func sendEvent(ctx context.Context, output chan<- core.Event, event core.Event) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case output <- event:
+		return nil
+	}
+}
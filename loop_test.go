@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// toolCallingMockStage asks for a tool when it sees a question and answers
+// when it sees a tool result, ending each turn with a DoneEvent
+type toolCallingMockStage struct{}
+
+func (toolCallingMockStage) Name() string                  { return "llm" }
+func (toolCallingMockStage) InputTypes() []core.EventType  { return []core.EventType{} }
+func (toolCallingMockStage) OutputTypes() []core.EventType { return []core.EventType{} }
+func (toolCallingMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		switch e := event.(type) {
+		case core.LLMEvent:
+			if e.Content == "tool result" {
+				output <- core.LLMEvent{Delta: "answer", Content: "answer"}
+			} else {
+				output <- core.ActionEvent{ActionID: "lookup"}
+			}
+		case core.DoneEvent:
+			output <- core.DoneEvent{TokensUsed: 10}
+		}
+	}
+	return nil
+}
+
+func newToolLoop(t *testing.T, maxIterations int, next LoopNext) *Pipeline {
+	t.Helper()
+	body, err := Linear(toolCallingMockStage{})
+	if err != nil {
+		t.Fatalf("Linear failed: %v", err)
+	}
+	p, err := NewBuilder().
+		AddLoop("agent", LoopConfig{Body: body, Next: next, MaxIterations: maxIterations}).
+		SetEntryNode("agent").
+		AddExitNode("agent").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return p
+}
+
+func runLoop(p *Pipeline, turns int) []core.Event {
+	input := make(chan core.Event, 2*turns)
+	for i := 0; i < turns; i++ {
+		input <- core.LLMEvent{Delta: "question", Content: "question"}
+		input <- core.DoneEvent{}
+	}
+	close(input)
+
+	var out []core.Event
+	for event := range p.Execute(context.Background(), input) {
+		out = append(out, event)
+	}
+	return out
+}
+
+// TestLoopStage tests that tool calls are fed back into the body until it
+// answers
+func TestLoopStage(t *testing.T) {
+	p := newToolLoop(t, 0, func(iteration int, outputs []core.Event) []core.Event {
+		for _, event := range outputs {
+			if _, ok := event.(core.ActionEvent); ok {
+				return []core.Event{core.LLMEvent{Content: "tool result"}}
+			}
+		}
+		return nil
+	})
+
+	out := runLoop(p, 2)
+	var types []core.EventType
+	for _, event := range out {
+		types = append(types, event.EventType())
+	}
+	want := []core.EventType{
+		core.EventTypeAction, core.EventTypeLLM, core.EventTypeDone,
+		core.EventTypeAction, core.EventTypeLLM, core.EventTypeDone,
+	}
+	if len(types) != len(want) {
+		t.Fatalf("expected %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, types)
+		}
+	}
+	if done := out[2].(core.DoneEvent); done.TokensUsed != 20 {
+		t.Errorf("expected DoneEvents of both iterations merged, got %d tokens", done.TokensUsed)
+	}
+}
+
+// TestLoopStageMaxIterations tests the runaway loop guard
+func TestLoopStageMaxIterations(t *testing.T) {
+	iterations := 0
+	p := newToolLoop(t, 3, func(iteration int, outputs []core.Event) []core.Event {
+		iterations = iteration
+		return []core.Event{core.LLMEvent{Content: "again"}}
+	})
+
+	out := runLoop(p, 1)
+	if iterations != 3 {
+		t.Errorf("expected 3 iterations, got %d", iterations)
+	}
+	if len(out) < 2 {
+		t.Fatalf("expected a warning and a DoneEvent, got %v", out)
+	}
+	if warning, ok := out[len(out)-2].(core.ServiceMessageEvent); !ok || warning.MessageType != core.ServiceMessageWarning {
+		t.Errorf("expected a warning, got %v", out[len(out)-2])
+	}
+	if _, ok := out[len(out)-1].(core.DoneEvent); !ok {
+		t.Errorf("expected the loop to end with a DoneEvent, got %v", out[len(out)-1])
+	}
+}
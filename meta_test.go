@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestPipelineStampsEventMeta tests that events carry the run's correlation ID
+// and the name of the stage that first emitted them
+func TestPipelineStampsEventMeta(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("first", &CollectingMockStage{name: "first"}).
+		AddStage("second", &CollectingMockStage{name: "second"}).
+		Connect("first", "second").
+		SetEntryNode("first").
+		AddExitNode("second").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ctx = core.WithCorrelationID(ctx, "turn-1")
+
+	input := make(chan core.Event, 2)
+	input <- core.STTEvent{Text: "hello"}
+	input <- core.DoneEvent{Meta: core.EventMeta{Origin: "client"}}
+	close(input)
+
+	var events []core.Event
+	for event := range pipeline.Execute(ctx, input) {
+		events = append(events, event)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	for _, event := range events {
+		meta := core.MetaOf(event)
+		if meta.CorrelationID != "turn-1" {
+			t.Errorf("expected correlation ID 'turn-1', got %q", meta.CorrelationID)
+		}
+		if meta.Timestamp.IsZero() {
+			t.Error("expected timestamp to be set")
+		}
+	}
+
+	if origin := core.MetaOf(events[0]).Origin; origin != "first" {
+		t.Errorf("expected origin 'first', got %q", origin)
+	}
+	if origin := core.MetaOf(events[1]).Origin; origin != "client" {
+		t.Errorf("expected pre-set origin 'client' to be kept, got %q", origin)
+	}
+}
+
+// TestPipelineStampsTurn tests that every event carries the turn from the
+// run's context, including events emitted by later stages
+func TestPipelineStampsTurn(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("first", &CollectingMockStage{name: "first"}).
+		AddStage("second", &CollectingMockStage{name: "second"}).
+		Connect("first", "second").
+		SetEntryNode("first").
+		AddExitNode("second").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	turn := core.TurnContext{TurnID: "t1", ResponseID: "r1", UserID: "u1"}
+	ctx = core.WithTurn(ctx, turn)
+
+	input := make(chan core.Event, 1)
+	input <- core.STTEvent{Text: "hello"}
+	close(input)
+
+	var events int
+	for event := range pipeline.Execute(ctx, input) {
+		events++
+		if got := core.MetaOf(event).Turn; got != turn {
+			t.Errorf("expected turn %+v, got %+v", turn, got)
+		}
+	}
+	if events != 1 {
+		t.Fatalf("expected 1 event, got %d", events)
+	}
+}
@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the histogram upper bounds in seconds, matching the
+// Prometheus client defaults
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics is a point-in-time snapshot of a pipeline's runtime metrics.
+// Counters and histograms are cumulative over all runs of the pipeline.
+type Metrics struct {
+	Stages map[string]StageMetrics
+}
+
+// StageMetrics holds the metrics of a single graph node
+type StageMetrics struct {
+	EventsIn      int64 // Events delivered to the stage's input
+	EventsOut     int64 // Events emitted by the stage
+	EventsDropped int64 // Output events dropped because a downstream input was full or closed
+	Errors        int64 // Runs that ended with an error or panic
+	Panics        int64 // Stage panics, including those isolated or restarted
+	Runs          int64 // Completed runs
+
+	QueueDepth    int // Events waiting in the stage's input, 0 when not running
+	QueueCapacity int
+
+	// FirstOutputLatency is the time from the stage's first input event (or
+	// its start, for sources) to its first output event, per run
+	FirstOutputLatency Histogram
+
+	// Duration is the wall time of each run
+	Duration Histogram
+}
+
+// Histogram is a snapshot of a latency distribution in seconds
+type Histogram struct {
+	Buckets []float64 // Upper bounds
+	Counts  []uint64  // Cumulative count of observations per bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Mean returns the mean observation in seconds, or 0 without observations
+func (h Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// histogram accumulates observations into latencyBuckets
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(latencyBuckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (h *histogram) snapshot() Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Histogram{
+		Buckets: latencyBuckets,
+		Counts:  append([]uint64(nil), h.counts...),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+}
+
+// stageMetrics is the live, cumulative counterpart of StageMetrics
+type stageMetrics struct {
+	eventsIn      atomic.Int64
+	eventsOut     atomic.Int64
+	eventsDropped atomic.Int64
+	errors        atomic.Int64
+	panics        atomic.Int64
+	runs          atomic.Int64
+
+	firstOutputLatency *histogram
+	duration           *histogram
+}
+
+// pipelineMetrics holds the metrics of every node of a pipeline's graph
+type pipelineMetrics struct {
+	mu     sync.Mutex
+	stages map[string]*stageMetrics
+}
+
+func newPipelineMetrics() *pipelineMetrics {
+	return &pipelineMetrics{stages: make(map[string]*stageMetrics)}
+}
+
+// stage returns the metrics of a node, registering it on first use
+func (m *pipelineMetrics) stage(name string) *stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sm, ok := m.stages[name]
+	if !ok {
+		sm = &stageMetrics{
+			firstOutputLatency: newHistogram(),
+			duration:           newHistogram(),
+		}
+		m.stages[name] = sm
+	}
+	return sm
+}
+
+// all returns the metrics of every registered node by name
+func (m *pipelineMetrics) all() map[string]*stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stages := make(map[string]*stageMetrics, len(m.stages))
+	for name, sm := range m.stages {
+		stages[name] = sm
+	}
+	return stages
+}
+
+// Metrics returns a snapshot of the pipeline's runtime metrics
+func (p *Pipeline) Metrics() Metrics {
+	p.mu.Lock()
+	state := p.state
+	p.mu.Unlock()
+
+	// Nodes register when a run starts, so list the graph's nodes up front
+	for _, node := range p.graph.AllNodes() {
+		p.metrics.stage(node.Name())
+	}
+
+	stages := p.metrics.all()
+	snapshot := Metrics{Stages: make(map[string]StageMetrics, len(stages))}
+	for name, m := range stages {
+		stage := StageMetrics{
+			EventsIn:           m.eventsIn.Load(),
+			EventsOut:          m.eventsOut.Load(),
+			EventsDropped:      m.eventsDropped.Load(),
+			Errors:             m.errors.Load(),
+			Panics:             m.panics.Load(),
+			Runs:               m.runs.Load(),
+			FirstOutputLatency: m.firstOutputLatency.snapshot(),
+			Duration:           m.duration.snapshot(),
+		}
+		if state != nil {
+			if ns, ok := state.nodeStates[name]; ok {
+				stage.QueueDepth = len(ns.input)
+				stage.QueueCapacity = cap(ns.input)
+			}
+		}
+		snapshot.Stages[name] = stage
+	}
+	return snapshot
+}
+
+// WritePrometheus writes the pipeline's metrics in the Prometheus text
+// exposition format. A prometheus.Collector can be built on Metrics instead
+// when the client library is in use.
+func (p *Pipeline) WritePrometheus(w io.Writer) error {
+	metrics := p.Metrics()
+
+	names := make([]string, 0, len(metrics.Stages))
+	for name := range metrics.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	counters := []struct {
+		name, help string
+		value      func(StageMetrics) int64
+	}{
+		{"pipeline_stage_events_in_total", "Events delivered to the stage input.", func(s StageMetrics) int64 { return s.EventsIn }},
+		{"pipeline_stage_events_out_total", "Events emitted by the stage.", func(s StageMetrics) int64 { return s.EventsOut }},
+		{"pipeline_stage_events_dropped_total", "Stage output events dropped by a full or closed downstream input.", func(s StageMetrics) int64 { return s.EventsDropped }},
+		{"pipeline_stage_errors_total", "Stage runs that ended with an error.", func(s StageMetrics) int64 { return s.Errors }},
+		{"pipeline_stage_panics_total", "Stage panics, including isolated and restarted ones.", func(s StageMetrics) int64 { return s.Panics }},
+		{"pipeline_stage_runs_total", "Completed stage runs.", func(s StageMetrics) int64 { return s.Runs }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{stage=%q} %d\n", c.name, name, c.value(metrics.Stages[name]))
+		}
+	}
+
+	b.WriteString("# HELP pipeline_stage_queue_depth Events waiting in the stage input.\n# TYPE pipeline_stage_queue_depth gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "pipeline_stage_queue_depth{stage=%q} %d\n", name, metrics.Stages[name].QueueDepth)
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(StageMetrics) Histogram
+	}{
+		{"pipeline_stage_first_output_seconds", "Time from the first stage input to the first stage output.", func(s StageMetrics) Histogram { return s.FirstOutputLatency }},
+		{"pipeline_stage_duration_seconds", "Wall time of stage runs.", func(s StageMetrics) Histogram { return s.Duration }},
+	}
+	for _, h := range histograms {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, name := range names {
+			hist := h.value(metrics.Stages[name])
+			for i, bound := range hist.Buckets {
+				fmt.Fprintf(&b, "%s_bucket{stage=%q,le=\"%g\"} %d\n", h.name, name, bound, hist.Counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket{stage=%q,le=\"+Inf\"} %d\n", h.name, name, hist.Count)
+			fmt.Fprintf(&b, "%s_sum{stage=%q} %g\n", h.name, name, hist.Sum)
+			fmt.Fprintf(&b, "%s_count{stage=%q} %d\n", h.name, name, hist.Count)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// MetricsHandler returns an HTTP handler serving the pipeline's metrics for
+// Prometheus to scrape
+func (p *Pipeline) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		p.WritePrometheus(w)
+	})
+}
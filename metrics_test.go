@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestPipelineMetrics tests that a run records per-stage counters, latency
+// histograms and errors
+func TestPipelineMetrics(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddStage("sink", &CollectingMockStage{name: "sink"}).
+		Connect("source", "sink").
+		SetEntryNode("source").
+		AddExitNode("sink").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.LLMEvent{Delta: "b"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	for range pipeline.Execute(context.Background(), input) {
+	}
+
+	metrics := pipeline.Metrics()
+	source := metrics.Stages["source"]
+	if source.EventsIn != 3 || source.EventsOut != 3 || source.Runs != 1 || source.Errors != 0 {
+		t.Errorf("unexpected source metrics: %+v", source)
+	}
+	if source.FirstOutputLatency.Count != 1 || source.Duration.Count != 1 {
+		t.Errorf("expected one latency and duration observation, got %d and %d", source.FirstOutputLatency.Count, source.Duration.Count)
+	}
+
+	if sink := metrics.Stages["sink"]; sink.EventsIn != 3 || sink.EventsDropped != 0 {
+		t.Errorf("unexpected sink metrics: %+v", sink)
+	}
+
+	recorder := httptest.NewRecorder()
+	pipeline.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`pipeline_stage_events_out_total{stage="source"} 3`,
+		`pipeline_stage_errors_total{stage="sink"} 0`,
+		`pipeline_stage_duration_seconds_count{stage="source"} 1`,
+		`# TYPE pipeline_stage_queue_depth gauge`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in exposition:\n%s", want, body)
+		}
+	}
+}
+
+// TestPipelineMetricsCountsErrors tests that failed runs are counted
+func TestPipelineMetricsCountsErrors(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("failing", &FailingMockStage{name: "failing"}).
+		SetEntryNode("failing").
+		AddExitNode("failing").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	close(input)
+	for range pipeline.Execute(context.Background(), input) {
+	}
+
+	if failing := pipeline.Metrics().Stages["failing"]; failing.Errors != 1 || failing.Runs != 1 {
+		t.Errorf("expected the failed run to be counted, got %+v", failing)
+	}
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// PanicPolicy defines what happens when a node's stage panics
+type PanicPolicy string
+
+const (
+	// PanicPropagate fails the pipeline: the panic is reported as an
+	// ErrorEvent and returned by Execute, and every stage is cancelled
+	// (default)
+	PanicPropagate PanicPolicy = "propagate"
+
+	// PanicIsolate stops only the panicking stage, e.g. an analytics tap whose
+	// crash shouldn't cut off the voice response. Its output ends, the rest of
+	// its input is dead-lettered and the other stages continue. The panic is
+	// recorded in the stage's span and metrics but not sent downstream.
+	PanicIsolate PanicPolicy = "isolate"
+
+	// PanicRestart runs the stage again on the rest of its input, up to
+	// DefaultPanicRestarts times per run. The event the stage was handling is
+	// lost. A stage that keeps panicking is then isolated.
+	PanicRestart PanicPolicy = "restart"
+)
+
+// DefaultPanicRestarts is the number of times a stage with PanicRestart is
+// restarted in one pipeline run
+const DefaultPanicRestarts = 3
+
+// valid reports whether the policy is known; empty means PanicPropagate
+func (p PanicPolicy) valid() bool {
+	switch p {
+	case "", PanicPropagate, PanicIsolate, PanicRestart:
+		return true
+	}
+	return false
+}
+
+// StagePanicError is reported when a stage panics
+type StagePanicError struct {
+	Stage string
+	Value any    // The value passed to panic
+	Stack string // The panicking goroutine's stack trace
+}
+
+func (e *StagePanicError) Error() string {
+	return fmt.Sprintf("stage %s panicked: %v\nStack trace:\n%s", e.Stage, e.Value, e.Stack)
+}
+
+// ErrorCode returns core.ErrCodeStagePanic
+func (e *StagePanicError) ErrorCode() core.ErrCode {
+	return core.ErrCodeStagePanic
+}
+
+// callStage runs a stage's Process, returning a panic as a StagePanicError
+func callStage(ctx context.Context, name string, stage core.Stage, input <-chan core.Event, output chan<- core.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 4096)
+			n := runtime.Stack(buf, false)
+			err = &StagePanicError{Stage: name, Value: r, Stack: string(buf[:n])}
+		}
+	}()
+	return stage.Process(ctx, input, output)
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// panickingMockStage forwards its input but panics on the events numbered in
+// panicOn, counted across restarts
+type panickingMockStage struct {
+	name    string
+	panicOn map[int]bool
+	seen    atomic.Int64
+}
+
+func (m *panickingMockStage) Name() string                  { return m.name }
+func (m *panickingMockStage) InputTypes() []core.EventType  { return []core.EventType{} }
+func (m *panickingMockStage) OutputTypes() []core.EventType { return []core.EventType{} }
+func (m *panickingMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		if m.panicOn[int(m.seen.Add(1))] {
+			panic("analytics crashed")
+		}
+		output <- event
+	}
+	return nil
+}
+
+// runPanicPipeline runs source -> voice and source -> analytics, both exits,
+// and returns the output events
+func runPanicPipeline(t *testing.T, analytics *panickingMockStage, policy PanicPolicy, deadLetters DeadLetterHandler) (*Pipeline, []core.Event) {
+	t.Helper()
+	b := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddStage("voice", &CollectingMockStage{name: "voice"}).
+		AddStage("analytics", analytics).
+		Connect("source", "voice").
+		Connect("source", "analytics").
+		SetEntryNode("source").
+		AddExitNode("voice").
+		AddExitNode("analytics").
+		WithDeadLetterHandler(deadLetters)
+	if policy != "" {
+		b.WithPanicPolicy("analytics", policy)
+	}
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 4)
+	for i := 0; i < 3; i++ {
+		input <- core.LLMEvent{Delta: "hi"}
+	}
+	input <- core.DoneEvent{}
+	close(input)
+
+	var out []core.Event
+	for event := range p.Execute(context.Background(), input) {
+		out = append(out, event)
+	}
+	return p, out
+}
+
+// TestPanicPolicyPropagate tests that by default a panic fails the pipeline
+func TestPanicPolicyPropagate(t *testing.T) {
+	p, err := NewBuilder().
+		AddStage("analytics", &panickingMockStage{name: "analytics", panicOn: map[int]bool{1: true}}).
+		SetEntryNode("analytics").
+		AddExitNode("analytics").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 1)
+	input <- core.DoneEvent{}
+	close(input)
+
+	err = p.run(context.Background(), input, make(chan core.Event, 10))
+	var panicErr *StagePanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a StagePanicError, got %v", err)
+	}
+	if panicErr.Stage != "analytics" || panicErr.Value != "analytics crashed" {
+		t.Errorf("unexpected panic error %+v", panicErr)
+	}
+}
+
+// TestPanicPolicyIsolate tests that an isolated panic only stops its stage
+func TestPanicPolicyIsolate(t *testing.T) {
+	var mu sync.Mutex
+	var dead []DeadLetter
+	p, out := runPanicPipeline(t, &panickingMockStage{name: "analytics", panicOn: map[int]bool{1: true}}, PanicIsolate, func(dl DeadLetter) {
+		mu.Lock()
+		dead = append(dead, dl)
+		mu.Unlock()
+	})
+
+	if len(out) != 4 {
+		t.Errorf("expected only the voice response, got %v", out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The event the stage panicked on is gone; the rest are dead-lettered
+	if len(dead) != 3 {
+		t.Errorf("expected 3 dead letters, got %v", dead)
+	}
+	for _, dl := range dead {
+		if dl.Reason != DeadLetterStageFailed || dl.To != "analytics" {
+			t.Errorf("unexpected dead letter %+v", dl)
+		}
+	}
+
+	if m := p.Metrics().Stages["analytics"]; m.Panics != 1 || m.Errors != 1 || m.EventsOut != 0 {
+		t.Errorf("expected the panic to be counted, got %+v", m)
+	}
+}
+
+// TestPanicPolicyRestart tests that a restarted stage handles the rest of its
+// input, and is isolated once it runs out of restarts
+func TestPanicPolicyRestart(t *testing.T) {
+	p, out := runPanicPipeline(t, &panickingMockStage{name: "analytics", panicOn: map[int]bool{1: true}}, PanicRestart, nil)
+	if len(out) != 7 {
+		t.Errorf("expected the restarted stage to forward the other events, got %v", out)
+	}
+	if m := p.Metrics().Stages["analytics"]; m.Panics != 1 || m.Errors != 0 || m.EventsOut != 3 {
+		t.Errorf("expected one recovered panic, got %+v", m)
+	}
+
+	always := map[int]bool{1: true, 2: true, 3: true, 4: true}
+	p, out = runPanicPipeline(t, &panickingMockStage{name: "analytics", panicOn: always}, PanicRestart, nil)
+	if len(out) != 4 {
+		t.Errorf("expected the stage to be isolated, got %v", out)
+	}
+	if m := p.Metrics().Stages["analytics"]; m.Panics != DefaultPanicRestarts+1 || m.Errors != 1 {
+		t.Errorf("expected every panic counted, got %+v", m)
+	}
+}
+
+// TestPanicPolicyUnknown tests that Build rejects unknown policies
+func TestPanicPolicyUnknown(t *testing.T) {
+	_, err := NewBuilder().
+		AddStage("a", &CollectingMockStage{name: "a"}).
+		SetEntryNode("a").
+		AddExitNode("a").
+		WithPanicPolicy("a", "ignore").
+		Build()
+	if err == nil {
+		t.Error("expected an error for an unknown panic policy")
+	}
+}
@@ -2,84 +2,193 @@ package pipeline
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/creastat/pipeline/core"
 )
 
+// DefaultBufferSize is the capacity of the channels between stages and of the
+// pipeline output unless configured otherwise
+const DefaultBufferSize = 100
+
 // Pipeline represents a composable processing pipeline with graph-based execution
 type Pipeline struct {
 	graph  *PipelineGraph
+	tracer Tracer
 	mu     sync.Mutex
 	ctx    context.Context
 	cancel context.CancelFunc
+	state  *executionState
+
+	metrics     *pipelineMetrics
+	deadLetters DeadLetterHandler
+	bufferSize  int
+	warnings    []ValidationError
+
+	// replacements holds stages waiting to be swapped in at a turn boundary,
+	// guarded by mu
+	replacements map[string]core.Stage
 }
 
 // NewPipeline creates a new pipeline from a validated graph
 func NewPipeline(graph *PipelineGraph) *Pipeline {
 	return &Pipeline{
-		graph: graph,
+		graph:      graph,
+		metrics:    newPipelineMetrics(),
+		bufferSize: DefaultBufferSize,
 	}
 }
 
+// Graph returns the pipeline's compiled graph
+func (p *Pipeline) Graph() *PipelineGraph {
+	return p.graph
+}
+
+// Warnings returns the validation warnings found when the pipeline was built
+func (p *Pipeline) Warnings() []ValidationError {
+	return p.warnings
+}
+
+// SetTracer sets the tracer used to create pipeline and stage spans
+func (p *Pipeline) SetTracer(tracer Tracer) {
+	p.tracer = tracer
+}
+
+// SetBufferSize sets the default capacity of the channels between stages and
+// of the pipeline output. It applies from the next Execute.
+func (p *Pipeline) SetBufferSize(size int) {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	p.bufferSize = size
+}
+
+// SetDeadLetterHandler sets the handler that receives events the pipeline
+// drops or fails to deliver
+func (p *Pipeline) SetDeadLetterHandler(handler DeadLetterHandler) {
+	p.deadLetters = handler
+}
+
 // Execute processes the pipeline DAG starting from the entry node
 // Returns a channel of events from all exit nodes
 func (p *Pipeline) Execute(ctx context.Context, input <-chan core.Event) core.PipelineOutput {
-	outputChan := make(chan core.Event, 100)
+	outputChan := make(chan core.Event, p.bufferSize)
 
 	go func() {
 		defer close(outputChan)
 
-		// Create a cancellable context
-		pipelineCtx, cancel := context.WithCancel(ctx)
+		// Error already emitted by executeGraph
+		p.run(ctx, input, outputChan)
+	}()
+
+	return outputChan
+}
+
+// run executes the graph with a context that Cancel can cancel, writing exit
+// node events to output
+func (p *Pipeline) run(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	// Create a cancellable context
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mu.Lock()
+	p.ctx = pipelineCtx
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	defer func() {
 		p.mu.Lock()
-		p.ctx = pipelineCtx
-		p.cancel = cancel
+		p.ctx = nil
+		p.cancel = nil
 		p.mu.Unlock()
+	}()
 
-		defer func() {
-			p.mu.Lock()
-			p.ctx = nil
-			p.cancel = nil
-			p.mu.Unlock()
-		}()
+	// Execute the graph
+	return p.executeGraph(pipelineCtx, input, output)
+}
+
+// executeGraph executes the pipeline DAG with proper synchronization and error handling
+func (p *Pipeline) executeGraph(ctx context.Context, input <-chan core.Event, output chan<- core.Event) (err error) {
+	tracer := p.tracer
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
 
-		// Execute the graph
-		if err := p.executeGraph(pipelineCtx, input, outputChan); err != nil {
-			// Error already emitted by executeGraph
-			return
+	// Create a pipeline-level span that all stage spans are linked to
+	ctx, pipelineSpan := tracer.Start(ctx, "pipeline.execute", Attr(AttrPipelineStages, len(p.graph.AllNodes())))
+	defer func() {
+		if err != nil {
+			pipelineSpan.RecordError(err)
 		}
+		pipelineSpan.End()
 	}()
 
-	return outputChan
-}
+	// Detach the execution state only after cancel has run, so an in-flight
+	// Interrupt can't block on the output channel forever, and wait for it
+	// before the output is closed
+	defer func() {
+		p.mu.Lock()
+		state := p.state
+		p.state = nil
+		p.mu.Unlock()
+		if state != nil {
+			state.broadcasts.Wait()
+		}
+	}()
 
-// executeGraph executes the pipeline DAG with proper synchronization and error handling
-func (p *Pipeline) executeGraph(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
 	// Create execution state with cancellation support
 	pipelineCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// All events of this run share a correlation ID, taken from the context
+	// when the caller supplies one
+	correlationID, ok := core.CorrelationIDFromContext(ctx)
+	if !ok {
+		correlationID = core.NewCorrelationID()
+		// Stages see the ID too, so nested pipelines share it
+		pipelineCtx = core.WithCorrelationID(pipelineCtx, correlationID)
+	}
+
+	// Events also carry the caller's turn, if any
+	turn, _ := core.TurnFromContext(ctx)
+
 	state := &executionState{
-		ctx:        pipelineCtx,
-		cancel:     cancel,
-		nodeStates: make(map[string]*nodeState),
-		wg:         sync.WaitGroup{},
-		mu:         sync.Mutex{},
-		errorChan:  make(chan error, len(p.graph.AllNodes())),
+		ctx:           pipelineCtx,
+		cancel:        cancel,
+		correlationID: correlationID,
+		turn:          turn,
+		tracer:        tracer,
+		deadLetters:   p.deadLetters,
+		output:        output,
+		exitNodes:     make(map[string]bool),
+		nodeStates:    make(map[string]*nodeState),
+		wg:            sync.WaitGroup{},
+		mu:            sync.Mutex{},
+		errorChan:     make(chan error, len(p.graph.AllNodes())),
+	}
+
+	for _, exitNode := range p.graph.GetExitNodes() {
+		state.exitNodes[exitNode.Name()] = true
 	}
 
 	// Initialize node states for all nodes in the graph
 	for _, node := range p.graph.AllNodes() {
 		state.nodeStates[node.Name()] = &nodeState{
-			input:  make(chan core.Event, 100),
-			output: make(chan core.Event, 100),
-			done:   make(chan struct{}),
+			input:   make(chan core.Event, node.InputBufferSize(p.bufferSize)),
+			output:  make(chan core.Event, node.OutputBufferSize(p.bufferSize)),
+			done:    make(chan struct{}),
+			metrics: p.metrics.stage(node.Name()),
 		}
 	}
 
+	// Publish the state once complete, Interrupt reads it without the lock
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+
 	// Start all stages
 	for _, node := range p.graph.AllNodes() {
 		state.wg.Add(1)
@@ -92,38 +201,50 @@ func (p *Pipeline) executeGraph(ctx context.Context, input <-chan core.Event, ou
 		state.wg.Add(1)
 		go func() {
 			defer state.wg.Done()
-			defer close(state.nodeStates[entryNode.Name()].input)
-			for event := range input {
+			entryState := state.nodeStates[entryNode.Name()]
+			defer state.closeInput(entryState)
+			for {
+				var event core.Event
 				select {
 				case <-pipelineCtx.Done():
 					return
-				case state.nodeStates[entryNode.Name()].input <- event:
+				case e, ok := <-input:
+					if !ok {
+						return
+					}
+					event = e
+				}
+
+				event = core.StampMeta(event, core.EventMeta{
+					CorrelationID: state.correlationID,
+					Timestamp:     time.Now(),
+					Turn:          state.turn,
+				})
+
+				// Interrupts bypass the graph and reach every stage at once
+				if interrupt, ok := event.(core.InterruptEvent); ok {
+					state.broadcastInterrupt(interrupt)
+					continue
+				}
+
+				// Config updates bypass the graph too, stages subscribe to them
+				if update, ok := event.(core.ConfigUpdateEvent); ok {
+					p.UpdateConfig(update)
+					continue
+				}
+
+				select {
+				case <-pipelineCtx.Done():
+					return
+				case entryState.input <- event:
+					entryState.countIn()
 				}
 			}
 		}()
 	}
 
-	// Collect output from exit nodes
-	exitNodes := p.graph.GetExitNodes()
-	state.wg.Add(1)
-	go func() {
-		defer state.wg.Done()
-		var wg sync.WaitGroup
-		for _, exitNode := range exitNodes {
-			wg.Add(1)
-			go func(node *graphNode) {
-				defer wg.Done()
-				for event := range state.nodeStates[node.Name()].output {
-					select {
-					case <-pipelineCtx.Done():
-						return
-					case output <- event:
-					}
-				}
-			}(exitNode)
-		}
-		wg.Wait()
-	}()
+	// Output from exit nodes is forwarded by routeOutputsStreaming, which is
+	// the only reader of each node's output channel
 
 	// Wait for all stages to complete
 	state.wg.Wait()
@@ -144,6 +265,24 @@ func (p *Pipeline) runStage(node *graphNode, state *executionState) {
 	defer state.wg.Done()
 
 	nodeState := state.nodeStates[node.Name()]
+	nodeState.started = time.Now()
+	defer func() {
+		nodeState.metrics.runs.Add(1)
+		nodeState.metrics.duration.observe(time.Since(nodeState.started))
+	}()
+
+	// Create a stage span linked to the pipeline span; it is ended by the
+	// router once all of the stage's output has been routed
+	stageCtx, span := state.tracer.Start(state.ctx, "pipeline.stage "+node.Name(), Attr(AttrStageName, node.Name()))
+	nodeState.span = span
+
+	// Enforce the stage deadline, if any
+	timeout := node.Timeout()
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		stageCtx, cancelTimeout = context.WithTimeout(stageCtx, timeout)
+		defer cancelTimeout()
+	}
 
 	// Start a goroutine to route output events as they arrive
 	state.wg.Add(1)
@@ -153,20 +292,22 @@ func (p *Pipeline) runStage(node *graphNode, state *executionState) {
 	}()
 
 	defer close(nodeState.output)
-	defer close(nodeState.done)
 
-	// Recover from panics
+	// Recover from panics outside the stage's Process, which callStage
+	// already turns into errors
 	defer func() {
 		if r := recover(); r != nil {
-			// Log panic with stack trace
 			buf := make([]byte, 4096)
 			n := runtime.Stack(buf, false)
-			stackTrace := string(buf[:n])
-
-			err := fmt.Errorf("stage %s panicked: %v\nStack trace:\n%s", node.Name(), r, stackTrace)
+			err := &StagePanicError{Stage: node.Name(), Value: r, Stack: string(buf[:n])}
+			span.RecordError(err)
+			span.SetAttributes(Attr(AttrStageError, true))
+			nodeState.metrics.errors.Add(1)
+			state.drainFailed(node.Name(), nodeState, err)
 			errEvent := core.ErrorEvent{
 				Error:     err,
 				Retryable: false,
+				Code:      core.ErrCodeStagePanic,
 			}
 			// Try to send error event before cancelling
 			select {
@@ -183,13 +324,40 @@ func (p *Pipeline) runStage(node *graphNode, state *executionState) {
 	}()
 
 	// Execute the stage
-	err := node.Stage().Process(state.ctx, nodeState.input, nodeState.output)
+	err := p.processStage(stageCtx, node, state)
+
+	// Stop only this stage unless the panic should fail the pipeline
+	var panicErr *StagePanicError
+	if errors.As(err, &panicErr) && node.PanicPolicy() != PanicPropagate {
+		span.RecordError(err)
+		span.SetAttributes(Attr(AttrStageError, true))
+		nodeState.metrics.panics.Add(1)
+		nodeState.metrics.errors.Add(1)
+		state.discardInput(node.Name(), nodeState, err)
+		return
+	}
 
 	if err != nil {
+		// Report the stage's own deadline as a typed, retryable timeout
+		retryable := false
+		if timeout > 0 && errors.Is(stageCtx.Err(), context.DeadlineExceeded) && state.ctx.Err() == nil {
+			err = &core.StageTimeoutError{Stage: node.Name(), Timeout: timeout}
+			retryable = true
+		}
+
+		span.RecordError(err)
+		span.SetAttributes(Attr(AttrStageError, true))
+		if errors.As(err, &panicErr) {
+			nodeState.metrics.panics.Add(1)
+		}
+		nodeState.metrics.errors.Add(1)
+		state.drainFailed(node.Name(), nodeState, err)
+
 		// Emit error event
 		errEvent := core.ErrorEvent{
 			Error:     err,
-			Retryable: false,
+			Retryable: retryable,
+			Code:      core.ErrCodeOf(err),
 		}
 		select {
 		case <-state.ctx.Done():
@@ -210,14 +378,63 @@ func (p *Pipeline) runStage(node *graphNode, state *executionState) {
 func (p *Pipeline) routeOutputsStreaming(node *graphNode, state *executionState) {
 	nodeState := state.nodeStates[node.Name()]
 
+	defer func() {
+		nodeState.span.SetAttributes(
+			Attr(AttrEventsIn, nodeState.eventsIn.Load()),
+			Attr(AttrEventsOut, nodeState.eventsOut.Load()),
+			Attr(AttrEventsDropped, nodeState.eventsDropped.Load()),
+		)
+		nodeState.span.End()
+	}()
+
+	isExit := state.exitNodes[node.Name()]
+
+	// Taps get their own goroutines so they never block routing
+	taps := make([]chan<- core.Event, len(node.taps))
+	for i, fn := range node.taps {
+		taps[i] = startTap(fn, &state.wg)
+	}
+	defer func() {
+		for _, tap := range taps {
+			close(tap)
+		}
+	}()
+
 	// Route events as they arrive
 	for event := range nodeState.output {
+		if nodeState.eventsOut.Add(1) == 1 {
+			nodeState.metrics.firstOutputLatency.observe(time.Since(nodeState.firstInputTime()))
+		}
+		nodeState.metrics.eventsOut.Add(1)
+
+		// Stamp the envelope once, at the stage that first emitted the event;
+		// pass-through stages keep the original origin and timestamp
+		event = core.StampMeta(event, core.EventMeta{
+			CorrelationID: state.correlationID,
+			Origin:        node.Name(),
+			Timestamp:     time.Now(),
+			Turn:          state.turn,
+		})
+
+		for _, tap := range taps {
+			offerTap(tap, event)
+		}
+
+		// Exit nodes also deliver their events to the pipeline output
+		if isExit {
+			select {
+			case <-state.ctx.Done():
+				return
+			case state.output <- event:
+			}
+		}
+
 		for _, edge := range node.Outputs() {
 			downstreamNode := edge.To()
 			downstreamState := state.nodeStates[downstreamNode.Name()]
 
-			// Check if event should be forwarded based on filter
-			shouldForward := edge.ShouldForwardEvent(event.EventType())
+			// Check if event should be forwarded based on type filter and predicate
+			shouldForward := edge.ShouldForward(event)
 
 			if !shouldForward {
 				continue
@@ -226,13 +443,22 @@ func (p *Pipeline) routeOutputsStreaming(node *graphNode, state *executionState)
 			select {
 			case <-state.ctx.Done():
 				return
-			case downstreamState.input <- event:
 			default:
+			}
+
+			if reason := state.trySend(downstreamState, event); reason != "" {
 				// Channel is full or closed, skip this event
+				nodeState.eventsDropped.Add(1)
+				nodeState.metrics.eventsDropped.Add(1)
+				state.deadLetter(event, reason, node.Name(), downstreamNode.Name(), nil)
 			}
 		}
 	}
 
+	// Only now is all of this node's output delivered; a downstream input
+	// closed any earlier could still miss events routed from here
+	close(nodeState.done)
+
 	// Close input channels for downstream nodes that have no more inputs
 	for _, edge := range node.Outputs() {
 		downstreamNode := edge.To()
@@ -251,19 +477,82 @@ func (p *Pipeline) routeOutputsStreaming(node *graphNode, state *executionState)
 		}
 
 		if allUpstreamDone {
-			// Safely close the channel - use a sync.Once pattern to prevent double-close
-			state.mu.Lock()
-			select {
-			case <-downstreamState.done:
-				// Already closed, skip
-			default:
-				close(downstreamState.input)
-			}
-			state.mu.Unlock()
+			state.closeInput(downstreamState)
 		}
 	}
 }
 
+// trySend delivers an event to a node's input without blocking.
+// It returns why the event was dropped, or "" if it was delivered.
+func (s *executionState) trySend(ns *nodeState, event core.Event) DeadLetterReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ns.inputClosed {
+		return DeadLetterInputClosed
+	}
+
+	select {
+	case ns.input <- event:
+		ns.countIn()
+		return ""
+	default:
+		return DeadLetterChannelFull
+	}
+}
+
+// closeInput closes a node's input channel exactly once
+func (s *executionState) closeInput(ns *nodeState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ns.inputClosed {
+		ns.inputClosed = true
+		close(ns.input)
+	}
+}
+
+// broadcastInterrupt delivers an interrupt to every stage that is still
+// accepting input, ignoring edge filters, and reports it on the pipeline
+// output so callers can stop playback on their side
+func (s *executionState) broadcastInterrupt(event core.InterruptEvent) {
+	for _, ns := range s.nodeStates {
+		s.trySend(ns, event)
+	}
+
+	select {
+	case <-s.ctx.Done():
+	case s.output <- event:
+	}
+}
+
+// Interrupt signals barge-in to a running pipeline. Stages cancel in-flight
+// provider streams and flush buffered output, then finish the current turn
+// without failing the pipeline. It is a no-op if the pipeline isn't running.
+func (p *Pipeline) Interrupt(reason string) {
+	// The broadcast waits for the caller to read the output, so it must not
+	// hold the lock Swap, Reconfigure and Cancel need
+	p.mu.Lock()
+	state := p.state
+	if state != nil {
+		state.broadcasts.Add(1)
+	}
+	p.mu.Unlock()
+	if state == nil {
+		return
+	}
+	defer state.broadcasts.Done()
+
+	state.broadcastInterrupt(core.InterruptEvent{
+		Reason: reason,
+		Meta: core.EventMeta{
+			CorrelationID: state.correlationID,
+			Timestamp:     time.Now(),
+			Turn:          state.turn,
+		},
+	})
+}
+
 // Cancel cancels the pipeline execution
 func (p *Pipeline) Cancel() {
 	p.mu.Lock()
@@ -276,17 +565,54 @@ func (p *Pipeline) Cancel() {
 
 // executionState tracks runtime state during pipeline execution
 type executionState struct {
-	ctx        context.Context
-	cancel     context.CancelFunc
-	nodeStates map[string]*nodeState
-	wg         sync.WaitGroup
-	mu         sync.Mutex
-	errorChan  chan error
+	ctx           context.Context
+	cancel        context.CancelFunc
+	correlationID string
+	turn          core.TurnContext
+	tracer        Tracer
+	deadLetters   DeadLetterHandler
+	output        chan<- core.Event
+	exitNodes     map[string]bool
+	nodeStates    map[string]*nodeState
+	wg            sync.WaitGroup
+	broadcasts    sync.WaitGroup // Interrupts in flight, waited for before the output closes
+	mu            sync.Mutex
+	errorChan     chan error
 }
 
 // nodeState tracks the state of a single node during execution
 type nodeState struct {
 	input  chan core.Event
 	output chan core.Event
-	done   chan struct{}
+	done   chan struct{} // Closed once all of the node's output has been routed
+	span   Span
+
+	// inputClosed is guarded by executionState.mu
+	inputClosed bool
+
+	eventsIn      atomic.Int64
+	eventsOut     atomic.Int64
+	eventsDropped atomic.Int64
+
+	// Cumulative metrics shared by all runs of the pipeline
+	metrics    *stageMetrics
+	started    time.Time
+	firstInput atomic.Int64 // Unix nanoseconds of the first input event
+}
+
+// countIn records an event delivered to the node's input
+func (ns *nodeState) countIn() {
+	if ns.eventsIn.Add(1) == 1 {
+		ns.firstInput.Store(time.Now().UnixNano())
+	}
+	ns.metrics.eventsIn.Add(1)
+}
+
+// firstInputTime returns when the node received its first input, or when
+// it started if it has had none, as for sources
+func (ns *nodeState) firstInputTime() time.Time {
+	if nanos := ns.firstInput.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return ns.started
 }
@@ -0,0 +1,79 @@
+package pipelinetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// DefaultTimeout bounds RunStage so a hanging stage fails the test instead of
+// blocking it
+const DefaultTimeout = 5 * time.Second
+
+// RunStage runs a single stage on the given input events and returns
+// everything it emitted. The test fails if the stage returns an error or
+// does not finish within DefaultTimeout.
+func RunStage(t testing.TB, stage core.Stage, inputs ...core.Event) []core.Event {
+	t.Helper()
+
+	input := make(chan core.Event, len(inputs))
+	for _, event := range inputs {
+		input <- event
+	}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	// The runner owns output channels, so close it on the stage's behalf
+	output := make(chan core.Event)
+	collector := NewCollector()
+	collector.Drain(output)
+
+	err := stage.Process(ctx, input, output)
+	close(output)
+	collector.WaitClosed(DefaultTimeout)
+
+	if ctx.Err() != nil {
+		t.Fatalf("stage %q did not finish within %v", stage.Name(), DefaultTimeout)
+	}
+	if err != nil {
+		t.Fatalf("stage %q failed: %v", stage.Name(), err)
+	}
+	return collector.Events()
+}
+
+// ExpectEventSequence checks that events contain the given types in order.
+// Other events may appear in between, so tests stay stable when stages add
+// status or usage events.
+func ExpectEventSequence(t testing.TB, events []core.Event, types ...core.EventType) {
+	t.Helper()
+
+	next := 0
+	for _, event := range events {
+		if next < len(types) && event.EventType() == types[next] {
+			next++
+		}
+	}
+	if next < len(types) {
+		got := make([]core.EventType, len(events))
+		for i, event := range events {
+			got[i] = event.EventType()
+		}
+		t.Errorf("expected event sequence %v, missing %q from position %d in %v", types, types[next], next, got)
+	}
+}
+
+// ExpectDoneWithin waits for the collector to record a DoneEvent and returns
+// it, failing the test if none arrives within d
+func ExpectDoneWithin(t testing.TB, c *Collector, d time.Duration) core.DoneEvent {
+	t.Helper()
+
+	event, ok := c.WaitFor(core.EventTypeDone, d)
+	if !ok {
+		t.Fatalf("expected DoneEvent within %v, got %v", d, c.Types())
+	}
+	return event.(core.DoneEvent)
+}
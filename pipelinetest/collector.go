@@ -0,0 +1,150 @@
+package pipelinetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// Collector records events, either as a pass-through stage placed in a graph
+// or by draining a pipeline's output with Drain. It is safe for concurrent use.
+type Collector struct {
+	mu      sync.Mutex
+	events  []core.Event
+	changed chan struct{} // Closed and replaced whenever an event arrives or input ends
+	closed  bool
+}
+
+// NewCollector creates an empty collector
+func NewCollector() *Collector {
+	return &Collector{changed: make(chan struct{})}
+}
+
+// Name returns the stage name
+func (c *Collector) Name() string {
+	return "collector"
+}
+
+// InputTypes returns the event types this stage accepts
+func (c *Collector) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (c *Collector) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface, recording and forwarding events
+func (c *Collector) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	defer c.close()
+	for event := range input {
+		c.add(event)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
+
+// Drain records every event from a pipeline output channel in the background
+func (c *Collector) Drain(output <-chan core.Event) {
+	go func() {
+		defer c.close()
+		for event := range output {
+			c.add(event)
+		}
+	}()
+}
+
+// Events returns the events recorded so far
+func (c *Collector) Events() []core.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]core.Event(nil), c.events...)
+}
+
+// Types returns the types of the events recorded so far
+func (c *Collector) Types() []core.EventType {
+	events := c.Events()
+	types := make([]core.EventType, len(events))
+	for i, event := range events {
+		types[i] = event.EventType()
+	}
+	return types
+}
+
+// WaitFor blocks until an event of the given type is recorded, returning the
+// first such event. It returns false if the timeout expires or the input ends
+// without one.
+func (c *Collector) WaitFor(eventType core.EventType, timeout time.Duration) (core.Event, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		for _, event := range c.events {
+			if event.EventType() == eventType {
+				c.mu.Unlock()
+				return event, true
+			}
+		}
+		closed, changed := c.closed, c.changed
+		c.mu.Unlock()
+
+		if closed {
+			return nil, false
+		}
+		select {
+		case <-timer.C:
+			return nil, false
+		case <-changed:
+		}
+	}
+}
+
+// WaitClosed blocks until the collected input ends or the timeout expires,
+// and reports whether it ended
+func (c *Collector) WaitClosed(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		closed, changed := c.closed, c.changed
+		c.mu.Unlock()
+
+		if closed {
+			return true
+		}
+		select {
+		case <-timer.C:
+			return false
+		case <-changed:
+		}
+	}
+}
+
+func (c *Collector) add(event core.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+	c.notify()
+}
+
+func (c *Collector) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.notify()
+}
+
+// notify wakes all waiters; c.mu must be held
+func (c *Collector) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
@@ -0,0 +1,67 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/pipelinetest"
+	"github.com/creastat/pipeline/stages"
+)
+
+func TestVoicePipelineWithFakes(t *testing.T) {
+	stt := pipelinetest.NewFakeSTT("What are your opening hours?")
+	llm := pipelinetest.NewFakeLLM("We open ", "at nine.")
+	tts := pipelinetest.NewFakeTTS()
+
+	p, err := pipeline.NewBuilder().
+		AddStage("stt", stages.NewSTTStage(stages.STTStageConfig{Provider: stt, Encoding: "pcm", SampleRate: 16000})).
+		AddStage("llm", stages.NewLLMStage(stages.LLMStageConfig{Provider: llm})).
+		AddStage("tts", stages.NewTTSStage(stages.TTSStageConfig{Provider: tts, Encoding: "pcm"})).
+		Connect("stt", "llm", core.EventTypeLLM, core.EventTypeDone).
+		Connect("llm", "tts").
+		SetEntryNode("stt").
+		AddExitNode("tts").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 1)
+	input <- core.AudioEvent{Data: []byte{0, 0, 0, 0}, Format: "pcm"}
+	close(input)
+
+	collector := pipelinetest.NewCollector()
+	collector.Drain(p.Execute(context.Background(), input))
+
+	done := pipelinetest.ExpectDoneWithin(t, collector, 2*time.Second)
+	if done.Provider != "fake-tts" {
+		t.Errorf("expected DoneEvent from the TTS provider, got %+v", done)
+	}
+	pipelinetest.ExpectEventSequence(t, collector.Events(), core.EventTypeAudio, core.EventTypeDone)
+
+	if got := llm.Requests(); len(got) != 1 || got[0].Messages[0].Content != "What are your opening hours?" {
+		t.Errorf("unexpected LLM requests: %+v", got)
+	}
+	if texts := tts.Texts(); len(texts) != 2 || texts[0] != "We open " || texts[1] != "at nine." {
+		t.Errorf("unexpected synthesized texts: %q", texts)
+	}
+	if len(stt.Audio()) != 4 {
+		t.Errorf("expected the audio to reach the STT provider, got %d bytes", len(stt.Audio()))
+	}
+}
+
+func TestRunStageAndSequence(t *testing.T) {
+	llm := pipelinetest.NewFakeLLM("Hi", " there")
+	llm.Delay = 10 * time.Millisecond
+
+	events := pipelinetest.RunStage(t, stages.NewLLMStage(stages.LLMStageConfig{Provider: llm}),
+		core.LLMEvent{Delta: "hello"},
+		core.DoneEvent{},
+	)
+
+	pipelinetest.ExpectEventSequence(t, events,
+		core.EventTypeStatus, core.EventTypeLLM, core.EventTypeLLM, core.EventTypeDone)
+}
@@ -0,0 +1,343 @@
+package pipelinetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+// fakeProvider implements the lifecycle part of providers.Provider
+type fakeProvider struct {
+	name         string
+	providerType providers.ProviderType
+	capability   providers.Capability
+}
+
+func (p fakeProvider) Name() string                 { return p.name }
+func (p fakeProvider) Type() providers.ProviderType { return p.providerType }
+func (p fakeProvider) Initialize(ctx context.Context, config providers.ProviderConfig) error {
+	return nil
+}
+func (p fakeProvider) Close() error                          { return nil }
+func (p fakeProvider) HealthCheck(ctx context.Context) error { return nil }
+func (p fakeProvider) Capabilities() []providers.Capability {
+	return []providers.Capability{p.capability}
+}
+func (p fakeProvider) SupportsCapability(capability providers.Capability) bool {
+	return capability == p.capability
+}
+
+// wait sleeps for d, returning early with the context error on cancellation
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// FakeSTT is an STT provider that plays back a scripted transcript
+type FakeSTT struct {
+	fakeProvider
+	Chunks []providers.STTChunk // Returned by Receive in order, followed by a Done chunk
+	Delay  time.Duration        // Wait before each chunk
+	Err    error                // Returned by StreamTranscribe when set
+
+	mu       sync.Mutex
+	requests []providers.STTRequest
+	audio    []byte
+}
+
+// NewFakeSTT creates a fake STT provider that transcribes any audio as the
+// given final utterances
+func NewFakeSTT(utterances ...string) *FakeSTT {
+	p := &FakeSTT{fakeProvider: fakeProvider{"fake-stt", "fake", providers.CapabilitySTT}}
+	for _, text := range utterances {
+		p.Chunks = append(p.Chunks, providers.STTChunk{Text: text, IsFinal: true, Confidence: 1})
+	}
+	return p
+}
+
+// Transcribe implements providers.STTProvider
+func (p *FakeSTT) Transcribe(ctx context.Context, req providers.STTRequest) (*providers.STTResponse, error) {
+	p.recordRequest(req)
+	p.recordAudio(req.Audio)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	var texts []string
+	for _, chunk := range p.Chunks {
+		if chunk.IsFinal {
+			texts = append(texts, chunk.Text)
+		}
+	}
+	return &providers.STTResponse{Text: strings.Join(texts, " ")}, nil
+}
+
+// StreamTranscribe implements providers.STTProvider
+func (p *FakeSTT) StreamTranscribe(ctx context.Context, req providers.STTRequest) (providers.STTStream, error) {
+	p.recordRequest(req)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return &fakeSTTStream{provider: p}, nil
+}
+
+// Requests returns the requests the provider received
+func (p *FakeSTT) Requests() []providers.STTRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]providers.STTRequest(nil), p.requests...)
+}
+
+// Audio returns all audio sent to the provider
+func (p *FakeSTT) Audio() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]byte(nil), p.audio...)
+}
+
+func (p *FakeSTT) recordRequest(req providers.STTRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+}
+
+func (p *FakeSTT) recordAudio(audio []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.audio = append(p.audio, audio...)
+}
+
+type fakeSTTStream struct {
+	provider *FakeSTT
+	next     int
+}
+
+func (s *fakeSTTStream) Send(ctx context.Context, audio []byte) error {
+	s.provider.recordAudio(audio)
+	return nil
+}
+
+func (s *fakeSTTStream) Receive(ctx context.Context) (*providers.STTChunk, error) {
+	if err := wait(ctx, s.provider.Delay); err != nil {
+		return nil, err
+	}
+	if s.next >= len(s.provider.Chunks) {
+		return &providers.STTChunk{Done: true}, nil
+	}
+	chunk := s.provider.Chunks[s.next]
+	s.next++
+	return &chunk, nil
+}
+
+func (s *fakeSTTStream) Close() error {
+	return nil
+}
+
+// FakeLLM is an LLM provider that streams a scripted response
+type FakeLLM struct {
+	fakeProvider
+	Chunks []string         // Streamed in order; ChatCompletion returns them joined
+	Delay  time.Duration    // Wait before each chunk
+	Err    error            // Returned by ChatCompletion and StreamChatCompletion when set
+	Usage  *providers.Usage // Reported by ChatCompletion, and by the stream once done
+
+	// ToolCalls are reported by the stream once done, like the calls of a
+	// model using the request's tools
+	ToolCalls []core.ToolCallEvent
+
+	mu       sync.Mutex
+	requests []providers.ChatRequest
+}
+
+// NewFakeLLM creates a fake LLM provider streaming the given chunks
+func NewFakeLLM(chunks ...string) *FakeLLM {
+	return &FakeLLM{
+		fakeProvider: fakeProvider{"fake-llm", "fake", providers.CapabilityLLM},
+		Chunks:       chunks,
+	}
+}
+
+// ChatCompletion implements providers.LLMProvider
+func (p *FakeLLM) ChatCompletion(ctx context.Context, req providers.ChatRequest) (*providers.ChatResponse, error) {
+	p.record(req)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return &providers.ChatResponse{Content: strings.Join(p.Chunks, ""), Usage: p.Usage}, nil
+}
+
+// StreamChatCompletion implements providers.LLMProvider
+func (p *FakeLLM) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	p.record(req)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return &fakeChatStream{provider: p}, nil
+}
+
+// Requests returns the requests the provider received
+func (p *FakeLLM) Requests() []providers.ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]providers.ChatRequest(nil), p.requests...)
+}
+
+func (p *FakeLLM) record(req providers.ChatRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+}
+
+type fakeChatStream struct {
+	provider *FakeLLM
+	next     int
+	done     bool
+}
+
+func (s *fakeChatStream) Receive(ctx context.Context) (*providers.ChatChunk, error) {
+	if err := wait(ctx, s.provider.Delay); err != nil {
+		return nil, err
+	}
+	if s.next >= len(s.provider.Chunks) {
+		s.done = true
+		return &providers.ChatChunk{Done: true, FinishReason: "stop"}, nil
+	}
+	chunk := s.provider.Chunks[s.next]
+	s.next++
+	return &providers.ChatChunk{Content: chunk}, nil
+}
+
+// Usage reports the provider's Usage once the stream is done, like providers
+// sending usage with their last chunk
+func (s *fakeChatStream) Usage() *providers.Usage {
+	if !s.done {
+		return nil
+	}
+	return s.provider.Usage
+}
+
+// ToolCalls reports the provider's ToolCalls once the stream is done
+func (s *fakeChatStream) ToolCalls() []core.ToolCallEvent {
+	if !s.done {
+		return nil
+	}
+	return s.provider.ToolCalls
+}
+
+func (s *fakeChatStream) Close() error {
+	return nil
+}
+
+// FakeTTS is a TTS provider that "synthesizes" each text as its own bytes,
+// so tests can assert which text was spoken in which order
+type FakeTTS struct {
+	fakeProvider
+	Delay time.Duration // Wait before each audio chunk
+	Err   error         // Returned by Synthesize and StreamSynthesize when set
+
+	mu    sync.Mutex
+	texts []string
+}
+
+// NewFakeTTS creates a fake TTS provider
+func NewFakeTTS() *FakeTTS {
+	return &FakeTTS{fakeProvider: fakeProvider{"fake-tts", "fake", providers.CapabilityTTS}}
+}
+
+// Synthesize implements providers.TTSProvider
+func (p *FakeTTS) Synthesize(ctx context.Context, req providers.TTSRequest) (*providers.TTSResponse, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	if err := wait(ctx, p.Delay); err != nil {
+		return nil, err
+	}
+	p.record(req.Text)
+	return &providers.TTSResponse{Audio: []byte(req.Text)}, nil
+}
+
+// StreamSynthesize implements providers.TTSProvider
+func (p *FakeTTS) StreamSynthesize(ctx context.Context, req providers.TTSRequest) (providers.TTSStream, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return &fakeTTSStream{provider: p, texts: make(chan string, 1024), finished: make(chan struct{})}, nil
+}
+
+// Texts returns the texts the provider synthesized
+func (p *FakeTTS) Texts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.texts...)
+}
+
+func (p *FakeTTS) record(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.texts = append(p.texts, text)
+}
+
+// fakeTTSStream returns one audio chunk per sent text and finishes once
+// Finish or Close is called and all text is synthesized
+type fakeTTSStream struct {
+	provider *FakeTTS
+	texts    chan string
+	finished chan struct{}
+	once     sync.Once
+}
+
+func (s *fakeTTSStream) Send(ctx context.Context, text string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.finished:
+		return errors.New("stream finished")
+	case s.texts <- text:
+		return nil
+	}
+}
+
+func (s *fakeTTSStream) Receive(ctx context.Context) (*providers.TTSChunk, error) {
+	var text string
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case text = <-s.texts:
+	case <-s.finished:
+		// Synthesize text sent before Finish
+		select {
+		case text = <-s.texts:
+		default:
+			return &providers.TTSChunk{Done: true}, nil
+		}
+	}
+
+	if err := wait(ctx, s.provider.Delay); err != nil {
+		return nil, err
+	}
+	s.provider.record(text)
+	return &providers.TTSChunk{Audio: []byte(text)}, nil
+}
+
+// Finish signals that no more text will be sent
+func (s *fakeTTSStream) Finish(ctx context.Context) error {
+	s.once.Do(func() { close(s.finished) })
+	return nil
+}
+
+func (s *fakeTTSStream) Close() error {
+	return s.Finish(context.Background())
+}
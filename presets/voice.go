@@ -0,0 +1,97 @@
+// Package presets builds pipelines for common topologies, so applications
+// only supply providers and a sink instead of wiring every node by hand.
+package presets
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/stages"
+)
+
+// Node names of the VoiceAssistant graph, for metrics lookups and
+// Pipeline.Graph inspection
+const (
+	NodeSTT     = "stt"
+	NodeRAG     = "rag"
+	NodeLLM     = "llm"
+	NodeFanOut  = "fanout"
+	NodeTTS     = "tts"  // Fan-out branch
+	NodeText    = "text" // Fan-out branch
+	NodeBarrier = "barrier"
+	NodeSink    = "sink"
+)
+
+// VoiceAssistantConfig configures the VoiceAssistant preset
+type VoiceAssistantConfig struct {
+	STT stages.STTStageConfig
+	LLM stages.LLMStageConfig
+	TTS stages.TTSStageConfig
+
+	// RAG adds retrieval between STT and LLM when set
+	RAG *stages.RAGStageConfig
+
+	// Sink delivers the response to the client, e.g. a WebSocketSink or
+	// SSESink. It is the exit node of the pipeline.
+	Sink core.Stage
+
+	// BarrierTimeout bounds the wait for the audio branch once the text
+	// branch is done (default: no limit)
+	BarrierTimeout time.Duration
+}
+
+// VoiceAssistant builds the standard voice topology:
+//
+//	stt → [rag →] llm → fanout(tts, text) → barrier → sink
+//
+// The text branch passes the LLM output through for text clients. The
+// barrier joins the audio and text branches into a single DoneEvent, and
+// passes transcripts and status updates from stt on to the sink as they
+// arrive, so the sink only sees the barrier's DoneEvent. Pipeline.FanOut
+// with NodeFanOut returns the fan-out, to add branches such as a recorder.
+func VoiceAssistant(cfg VoiceAssistantConfig) (*pipeline.Pipeline, error) {
+	if cfg.Sink == nil {
+		return nil, fmt.Errorf("voice assistant preset requires a sink")
+	}
+
+	b := pipeline.NewBuilder().
+		AddStage(NodeSTT, stages.NewSTTStage(cfg.STT)).
+		AddStage(NodeLLM, stages.NewLLMStage(cfg.LLM)).
+		AddFanOut(NodeFanOut, core.FanOutConfig{
+			ErrorPolicy: core.ErrorPolicyCancelAll,
+			Branches: []core.BranchConfig{
+				{
+					Stage:       stages.NewTTSStage(cfg.TTS),
+					EventFilter: []core.EventType{core.EventTypeLLM, core.EventTypeLanguage, core.EventTypeDone},
+				},
+				{Stage: pipeline.NewTapStage(NodeText, func(core.Event) {})},
+			},
+		}).
+		AddBarrier(NodeBarrier, core.BarrierConfig{
+			UpstreamCount: 2,
+			MergeStrategy: core.MergeStrategyCollect,
+			Timeout:       cfg.BarrierTimeout,
+		}).
+		AddStage(NodeSink, cfg.Sink)
+
+	// The query goes through retrieval first when it is configured
+	query := []core.EventType{core.EventTypeLLM, core.EventTypeLanguage, core.EventTypeDone}
+	if cfg.RAG != nil {
+		b.AddStage(NodeRAG, stages.NewRAGStage(*cfg.RAG)).
+			Connect(NodeSTT, NodeRAG, query...).
+			Connect(NodeRAG, NodeLLM)
+	} else {
+		b.Connect(NodeSTT, NodeLLM, query...)
+	}
+
+	return b.
+		Connect(NodeSTT, NodeBarrier, core.EventTypeSTT, core.EventTypeStatus).
+		Connect(NodeLLM, NodeFanOut).
+		Connect(NodeFanOut, NodeBarrier).
+		Connect(NodeBarrier, NodeSink).
+		SetEntryNode(NodeSTT).
+		AddExitNode(NodeSink).
+		Build()
+}
@@ -0,0 +1,125 @@
+package presets_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/pipelinetest"
+	"github.com/creastat/pipeline/presets"
+	"github.com/creastat/pipeline/stages"
+)
+
+func TestVoiceAssistant(t *testing.T) {
+	llm := pipelinetest.NewFakeLLM("We open ", "at nine.")
+	tts := pipelinetest.NewFakeTTS()
+	sink := pipelinetest.NewCollector()
+
+	p, err := presets.VoiceAssistant(presets.VoiceAssistantConfig{
+		STT:  stages.STTStageConfig{Provider: pipelinetest.NewFakeSTT("When do you open?"), Encoding: "pcm", SampleRate: 16000},
+		LLM:  stages.LLMStageConfig{Provider: llm},
+		TTS:  stages.TTSStageConfig{Provider: tts, Encoding: "pcm"},
+		Sink: sink,
+	})
+	if err != nil {
+		t.Fatalf("VoiceAssistant failed: %v", err)
+	}
+	if _, ok := p.FanOut(presets.NodeFanOut); !ok {
+		t.Error("expected the fan-out to be reachable for runtime branches")
+	}
+
+	input := make(chan core.Event, 1)
+	input <- core.AudioEvent{Data: []byte{0, 0, 0, 0}, Format: "pcm"}
+	close(input)
+
+	output := pipelinetest.NewCollector()
+	output.Drain(p.Execute(context.Background(), input))
+	if !output.WaitClosed(2 * time.Second) {
+		t.Fatalf("pipeline did not finish, got %v", output.Types())
+	}
+
+	events := sink.Events()
+	pipelinetest.ExpectEventSequence(t, events, core.EventTypeSTT, core.EventTypeLLM, core.EventTypeDone)
+
+	var audio, done int
+	for _, event := range events {
+		switch event.(type) {
+		case core.AudioEvent:
+			audio++
+		case core.DoneEvent:
+			done++
+		}
+	}
+	if audio == 0 {
+		t.Error("expected audio to reach the sink")
+	}
+	if done != 1 {
+		t.Errorf("expected the barrier to merge branch DoneEvents into one, got %d", done)
+	}
+}
+
+// TestVoiceAssistantConfigUpdate tests that a config update reaches the TTS
+// stage in the fan-out
+func TestVoiceAssistantConfigUpdate(t *testing.T) {
+	sink := pipelinetest.NewCollector()
+	p, err := presets.VoiceAssistant(presets.VoiceAssistantConfig{
+		STT:  stages.STTStageConfig{Provider: pipelinetest.NewFakeSTT("When do you open?"), Encoding: "pcm", SampleRate: 16000},
+		LLM:  stages.LLMStageConfig{Provider: pipelinetest.NewFakeLLM("We open ", "at nine.")},
+		TTS:  stages.TTSStageConfig{Provider: pipelinetest.NewFakeTTS(), Encoding: "pcm"},
+		Sink: sink,
+	})
+	if err != nil {
+		t.Fatalf("VoiceAssistant failed: %v", err)
+	}
+
+	disabled := false
+	p.UpdateConfig(core.ConfigUpdateEvent{TTSEnabled: &disabled})
+
+	input := make(chan core.Event, 1)
+	input <- core.AudioEvent{Data: []byte{0, 0, 0, 0}, Format: "pcm"}
+	close(input)
+
+	output := pipelinetest.NewCollector()
+	output.Drain(p.Execute(context.Background(), input))
+	if !output.WaitClosed(2 * time.Second) {
+		t.Fatalf("pipeline did not finish, got %v", output.Types())
+	}
+
+	events := sink.Events()
+	pipelinetest.ExpectEventSequence(t, events, core.EventTypeSTT, core.EventTypeLLM, core.EventTypeDone)
+	for _, event := range events {
+		if _, ok := event.(core.AudioEvent); ok {
+			t.Fatal("expected no audio with TTS turned off")
+		}
+	}
+}
+
+// TestVoiceAssistantClientSinks tests that the preset builds with the sinks
+// sending to clients, whose DoneEvents must come through the barrier
+func TestVoiceAssistantClientSinks(t *testing.T) {
+	sinks := map[string]core.Stage{
+		"websocket": stages.NewWebSocketSink(stages.WebSocketSinkConfig{SessionID: "s1"}),
+		"sse":       stages.NewSSESink(stages.SSESinkConfig{Writer: httptest.NewRecorder(), SessionID: "s1"}),
+	}
+	for name, sink := range sinks {
+		t.Run(name, func(t *testing.T) {
+			_, err := presets.VoiceAssistant(presets.VoiceAssistantConfig{
+				STT:  stages.STTStageConfig{Provider: pipelinetest.NewFakeSTT("Hi"), Encoding: "pcm", SampleRate: 16000},
+				LLM:  stages.LLMStageConfig{Provider: pipelinetest.NewFakeLLM("Hello")},
+				TTS:  stages.TTSStageConfig{Provider: pipelinetest.NewFakeTTS(), Encoding: "pcm"},
+				Sink: sink,
+			})
+			if err != nil {
+				t.Errorf("VoiceAssistant failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestVoiceAssistantRequiresSink(t *testing.T) {
+	if _, err := presets.VoiceAssistant(presets.VoiceAssistantConfig{}); err == nil {
+		t.Error("expected error without a sink")
+	}
+}
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// Codec serializes protocol messages on the wire. JSON is the default; the
+// binary codecs carry audio and other byte payloads without base64, which
+// roughly halves the bandwidth of audio streams. Each connection picks its
+// codec, e.g. from the negotiated WebSocket subprotocol.
+type Codec interface {
+	// Name identifies the codec in negotiation, e.g. "json"
+	Name() string
+
+	// Binary reports whether encoded messages go in binary frames
+	Binary() bool
+
+	// Marshal encodes a server-to-client message
+	Marshal(msg *OutputMessage) ([]byte, error)
+
+	// Unmarshal decodes a client-to-server message with a typed payload,
+	// as DecodeInput does for JSON
+	Unmarshal(data []byte) (*InputMessage, error)
+}
+
+// Built-in codecs
+var (
+	JSONCodec        Codec = jsonCodec{}
+	MessagePackCodec Codec = msgpackCodec{}
+	ProtobufCodec    Codec = protobufCodec{}
+)
+
+// CodecByName returns the built-in codec with the given name: "json",
+// "msgpack" or "protobuf"
+func CodecByName(name string) (Codec, error) {
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, ProtobufCodec} {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+// EncodeEvent converts a pipeline event to an output message and encodes it.
+// It returns nil data for events without a protocol message.
+func EncodeEvent(codec Codec, event core.Event, sessionID, replyTo string) ([]byte, *OutputMessage, error) {
+	msg := EventToMessage(event, sessionID, replyTo)
+	if msg == nil {
+		return nil, nil, nil
+	}
+	data, err := codec.Marshal(msg)
+	return data, msg, err
+}
+
+// jsonCodec encodes messages as JSON text frames
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Binary() bool { return false }
+
+func (jsonCodec) Marshal(msg *OutputMessage) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte) (*InputMessage, error) {
+	return DecodeInput(data)
+}
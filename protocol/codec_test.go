@@ -0,0 +1,297 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// TestCodecsRoundTripInput tests that every codec decodes client messages
+// into the same typed payloads as DecodeInput
+func TestCodecsRoundTripInput(t *testing.T) {
+	enabled := false
+	messages := []*InputMessage{
+		{Type: InputText, ID: "m1", SessionID: "s1", Timestamp: 42, Payload: TextInputPayload{Text: "hello", SourceID: "docs", Context: map[string]any{"page": "pricing"}}},
+		{Type: InputAudio, ID: "m2", SessionID: "s1", Payload: AudioInputPayload{Data: []byte{0, 1, 2, 0xff}, Format: "pcm", SampleRate: 16000}},
+		{Type: InputConfig, ID: "m3", SessionID: "s1", Payload: ConfigPayload{Language: "de", TTSEnabled: &enabled, Providers: ProviderPresets{LLM: "fast"}, Keywords: []string{"Creastat", "Ana Lucía"}}},
+		{Type: InputCancel, ID: "m4", SessionID: "s1", Payload: CancelPayload{Reason: "user", ResponseID: "r1"}},
+		{Type: InputEnd, ID: "m5", SessionID: "s1"},
+		{Type: InputHello, ID: "m6", SessionID: "s1", Payload: HelloPayload{Version: 1, Features: []Feature{FeatureActions}}},
+		{Type: InputHeartbeat, ID: "m7", SessionID: "s1"},
+		{Type: InputResume, ID: "m8", SessionID: "s1", Payload: ResumePayload{SessionID: "s1", LastSeq: 300}},
+		{Type: InputAck, ID: "m9", SessionID: "s1", Payload: AckPayload{MessageID: "msg_1"}},
+	}
+
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, ProtobufCodec} {
+		for _, want := range messages {
+			data, err := encodeInput(codec, want)
+			if err != nil {
+				t.Fatalf("%s: encoding %s failed: %v", codec.Name(), want.Type, err)
+			}
+			got, err := codec.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("%s: decoding %s failed: %v", codec.Name(), want.Type, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %#v, got %#v", codec.Name(), want, got)
+			}
+		}
+	}
+}
+
+// TestCodecsRejectUnknownInput tests that binary codecs report unknown
+// message types like DecodeInput does
+func TestCodecsRejectUnknownInput(t *testing.T) {
+	msg := &InputMessage{Type: "input.video", ID: "m1"}
+	for _, codec := range []Codec{MessagePackCodec, ProtobufCodec} {
+		data, err := encodeInput(codec, msg)
+		if err != nil {
+			t.Fatalf("%s: encoding failed: %v", codec.Name(), err)
+		}
+		if _, err := codec.Unmarshal(data); !errors.Is(err, ErrUnknownInputType) {
+			t.Errorf("%s: expected ErrUnknownInputType, got %v", codec.Name(), err)
+		}
+	}
+}
+
+// TestBinaryCodecsCarryRawAudio tests that binary codecs send audio without
+// base64 and decode back to the same message
+func TestBinaryCodecsCarryRawAudio(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 1024)
+	msg := EventToMessage(core.AudioEvent{Data: audio, Format: "pcm", SampleRate: 24000, SeqNum: 7}, "s1", "r1")
+
+	jsonData, err := JSONCodec.Marshal(msg)
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+
+	for _, codec := range []Codec{MessagePackCodec, ProtobufCodec} {
+		data, err := codec.Marshal(msg)
+		if err != nil {
+			t.Fatalf("%s: %v", codec.Name(), err)
+		}
+		if !bytes.Contains(data, audio) {
+			t.Errorf("%s: expected raw audio bytes in the frame", codec.Name())
+		}
+		// JSON's base64 adds a third to the audio, binary codecs only a header
+		if len(data) > len(audio)+256 || len(data) >= len(jsonData) {
+			t.Errorf("%s: expected a frame close to the %d audio bytes (JSON: %d), got %d", codec.Name(), len(audio), len(jsonData), len(data))
+		}
+	}
+
+	d := msgpackDecoder{data: must(MessagePackCodec.Marshal(msg))}
+	value, err := d.decode()
+	if err != nil {
+		t.Fatalf("msgpack decode: %v", err)
+	}
+	fields := value.(map[string]any)
+	payload := fields["payload"].(map[string]any)
+	if fields["type"] != string(OutputStreamAudio) || !bytes.Equal(payload["data"].([]byte), audio) || payload["seq"] != int64(7) {
+		t.Errorf("unexpected msgpack message %v", fields)
+	}
+
+	var gotType string
+	var gotAudio []byte
+	err = pbFields(must(ProtobufCodec.Marshal(msg)), func(f pbField) error {
+		switch f.num {
+		case 1:
+			gotType = string(f.data)
+		case 13:
+			return pbFields(f.data, func(f pbField) error {
+				if f.num == 1 {
+					gotAudio = f.data
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil || gotType != string(OutputStreamAudio) || !bytes.Equal(gotAudio, audio) {
+		t.Errorf("unexpected protobuf message: type %q, %d audio bytes, err %v", gotType, len(gotAudio), err)
+	}
+}
+
+// TestCodecsEncodeAllEvents tests that every event with a protocol message
+// encodes with every codec
+func TestCodecsEncodeAllEvents(t *testing.T) {
+	events := []core.Event{
+		core.StatusEvent{Status: core.StatusThinking, Target: core.StatusTargetBot, Details: map[string]any{"step": 1}},
+		core.STTEvent{Text: "hi", IsFinal: true, Words: []core.WordInfo{{Word: "hi", End: 0.4}}},
+		core.LLMEvent{Delta: "Hel", Content: "Hel"},
+		core.RawTextEvent{Delta: "**Hel**", Content: "**Hel**"},
+		core.ActionEvent{ActionID: "a1", Data: map[string]any{"url": "/pricing"}},
+		core.ToolStartEvent{ToolID: "t1", ToolName: "weather", Input: map[string]any{"city": "Oslo"}},
+		core.ToolResultEvent{ToolID: "t1", Success: true, Output: map[string]any{"celsius": 4}},
+		core.ErrorEvent{Error: errors.New("boom")},
+		core.DoneEvent{FullText: "Hello", Usage: &core.UsageSummary{UsageTotals: core.UsageTotals{InputTokens: 3, Cost: 0.01}}},
+		core.CitationEvent{DocumentID: "d1", Score: 0.9},
+		core.LanguageDetectedEvent{Language: "en", Confidence: 0.8},
+		core.ServiceMessageEvent{MessageType: core.ServiceMessageWarning, Content: "hi", Localized: map[string]string{"en": "hi"}},
+		core.ResponseCancelledEvent{ResponseID: "r1", Reason: "user"},
+		core.BatchEvent{Events: []core.Event{core.STTEvent{Text: "h"}, core.LLMEvent{Delta: "i"}}},
+	}
+
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, ProtobufCodec} {
+		for _, event := range events {
+			data, msg, err := EncodeEvent(codec, event, "s1", "r1")
+			if msg == nil {
+				t.Fatalf("no message for %T", event)
+			}
+			if err != nil || len(data) == 0 {
+				t.Errorf("%s: encoding %T failed: %v", codec.Name(), event, err)
+			}
+		}
+	}
+}
+
+// TestErrorEventCodes tests that error messages carry the event's ErrCode
+func TestErrorEventCodes(t *testing.T) {
+	tests := []struct {
+		event core.ErrorEvent
+		want  string
+	}{
+		{core.ErrorEvent{Error: errors.New("boom")}, "PIPELINE_ERROR"},
+		{core.ErrorEvent{Error: &core.StageTimeoutError{Stage: "llm", Timeout: time.Second}}, "STAGE_TIMEOUT"},
+		{core.ErrorEvent{Error: fmt.Errorf("start: %w", core.WithErrCode(errors.New("503"), core.ErrCodeProviderUnavailable))}, "PROVIDER_UNAVAILABLE"},
+		{core.ErrorEvent{Error: errors.New("slow down"), Code: core.ErrCodeLLMRateLimited}, "LLM_RATE_LIMITED"},
+	}
+
+	for _, tt := range tests {
+		msg := EventToMessage(tt.event, "s1", "r1")
+		if payload := msg.Payload.(ErrorPayload); payload.Code != tt.want {
+			t.Errorf("%v: expected code %s, got %s", tt.event.Error, tt.want, payload.Code)
+		}
+	}
+}
+
+// TestServiceMessageLocalized tests that keyed service messages are resolved
+// through the message catalog
+func TestServiceMessageLocalized(t *testing.T) {
+	msg := EventToMessage(i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgVoiceUnavailable, nil), "s1", "r1")
+	payload := msg.Payload.(ServiceMessagePayload)
+	if payload.Key != i18n.MsgVoiceUnavailable || payload.Localized["ru"] == "" || payload.Content == "" {
+		t.Errorf("expected a localized message, got %+v", payload)
+	}
+
+	custom := i18n.NewCatalog("en")
+	custom.Set(i18n.MsgVoiceUnavailable, "en", "Voice is down.")
+	SetMessageCatalog(custom)
+	defer SetMessageCatalog(nil)
+	msg = EventToMessage(core.ServiceMessageEvent{Key: i18n.MsgVoiceUnavailable}, "s1", "r1")
+	if payload := msg.Payload.(ServiceMessagePayload); payload.Content != "Voice is down." {
+		t.Errorf("expected the custom catalog, got %+v", payload)
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		codec, err := CodecByName(name)
+		if err != nil || codec.Name() != name {
+			t.Errorf("expected codec %q, got %v, %v", name, codec, err)
+		}
+	}
+	if _, err := CodecByName("xml"); err == nil {
+		t.Error("expected error for unknown codec")
+	}
+}
+
+// encodeInput encodes a client message the way a client using codec would
+func encodeInput(codec Codec, msg *InputMessage) ([]byte, error) {
+	switch codec {
+	case MessagePackCodec:
+		var e msgpackEncoder
+		err := e.encode(reflect.ValueOf(msg))
+		return e.buf, err
+	case ProtobufCodec:
+		return encodeInputProtobuf(msg)
+	default:
+		return json.Marshal(msg)
+	}
+}
+
+func encodeInputProtobuf(msg *InputMessage) ([]byte, error) {
+	var e pbEncoder
+	e.string(1, string(msg.Type))
+	e.string(2, msg.ID)
+	e.string(3, msg.SessionID)
+	e.int(4, msg.Timestamp)
+
+	var err error
+	switch p := msg.Payload.(type) {
+	case TextInputPayload:
+		err = e.message(10, func(e *pbEncoder) error {
+			e.string(1, p.Text)
+			e.string(2, p.SourceID)
+			return e.json(3, p.Context)
+		})
+	case AudioInputPayload:
+		err = e.message(11, func(e *pbEncoder) error {
+			e.bytes(1, p.Data)
+			e.string(2, p.Format)
+			e.int(3, int64(p.SampleRate))
+			return nil
+		})
+	case ConfigPayload:
+		err = e.message(12, func(e *pbEncoder) error {
+			e.string(1, p.Language)
+			if p.TTSEnabled != nil {
+				e.tag(2, pbVarint)
+				if *p.TTSEnabled {
+					e.varint(1)
+				} else {
+					e.varint(0)
+				}
+			}
+			if err := e.message(3, func(e *pbEncoder) error {
+				e.string(1, p.Providers.LLM)
+				e.string(2, p.Providers.STT)
+				e.string(3, p.Providers.TTS)
+				e.string(4, p.Providers.Embedding)
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, keyword := range p.Keywords {
+				e.string(4, keyword)
+			}
+			return nil
+		})
+	case CancelPayload:
+		err = e.message(13, func(e *pbEncoder) error {
+			e.string(1, p.Reason)
+			e.string(2, p.ResponseID)
+			return nil
+		})
+	case HelloPayload:
+		err = e.message(15, func(e *pbEncoder) error {
+			encodeHello(e, p)
+			return nil
+		})
+	case AckPayload:
+		err = e.message(17, func(e *pbEncoder) error {
+			e.string(1, p.MessageID)
+			return nil
+		})
+	case ResumePayload:
+		err = e.message(16, func(e *pbEncoder) error {
+			e.string(1, p.SessionID)
+			e.uint(2, p.LastSeq)
+			return nil
+		})
+	}
+	return e.buf, err
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
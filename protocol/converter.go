@@ -9,10 +9,12 @@ import (
 // EventToMessage converts a pipeline event to an output message
 func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage {
 	msg := &OutputMessage{
-		ID:        generateMessageID(),
-		SessionID: sessionID,
-		ReplyTo:   replyTo,
-		Timestamp: time.Now().UnixMilli(),
+		Version:       ProtocolVersion,
+		ID:            NewMessageID(),
+		SessionID:     sessionID,
+		ReplyTo:       replyTo,
+		CorrelationID: core.MetaOf(event).CorrelationID,
+		Timestamp:     time.Now().UnixMilli(),
 	}
 
 	switch e := event.(type) {
@@ -27,11 +29,22 @@ func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage
 
 	case core.STTEvent:
 		msg.Type = OutputStreamSTT
-		msg.Payload = STTStreamPayload{
+		payload := STTStreamPayload{
 			Text:       e.Text,
 			IsFinal:    e.IsFinal,
 			Confidence: e.Confidence,
+			Speaker:    e.SpeakerID,
+		}
+		for _, word := range e.Words {
+			payload.Words = append(payload.Words, WordPayload{
+				Word:       word.Word,
+				Start:      word.Start,
+				End:        word.End,
+				Confidence: word.Confidence,
+				Speaker:    word.SpeakerID,
+			})
 		}
+		msg.Payload = payload
 
 	case core.LLMEvent:
 		msg.Type = OutputStreamLLM
@@ -40,11 +53,23 @@ func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage
 			Content: e.Content,
 		}
 
+	case core.RawTextEvent:
+		// Clients get the raw text the way they'd get it without a text
+		// processor
+		msg.Type = OutputStreamLLM
+		msg.Payload = LLMStreamPayload{
+			Delta:   e.Delta,
+			Content: e.Content,
+		}
+
 	case core.AudioEvent:
 		msg.Type = OutputStreamAudio
 		msg.Payload = AudioStreamPayload{
-			Data:   e.Data,
-			Format: e.Format,
+			Data:       e.Data,
+			Format:     e.Format,
+			SampleRate: e.SampleRate,
+			Channels:   e.Channels,
+			SeqNum:     e.SeqNum,
 		}
 
 	case core.ActionEvent:
@@ -55,6 +80,25 @@ func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage
 			Target:     e.Target,
 			Data:       e.Data,
 			Required:   e.Required,
+			Timeout:    e.Timeout,
+		}
+
+	case core.ToolStartEvent:
+		msg.Type = OutputToolStart
+		msg.Payload = ToolStartPayload{
+			ToolID:      e.ToolID,
+			ToolName:    e.ToolName,
+			Description: e.Description,
+			Input:       e.Input,
+		}
+
+	case core.ToolResultEvent:
+		msg.Type = OutputToolResult
+		msg.Payload = ToolResultPayload{
+			ToolID:  e.ToolID,
+			Success: e.Success,
+			Output:  e.Output,
+			Error:   e.Error,
 		}
 
 	case core.ErrorEvent:
@@ -64,28 +108,85 @@ func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage
 			errMsg = e.Error.Error()
 		}
 		msg.Payload = ErrorPayload{
-			Code:      "PIPELINE_ERROR",
+			Code:      string(e.ErrCode()),
 			Message:   errMsg,
 			Retryable: e.Retryable,
 		}
 
 	case core.DoneEvent:
 		msg.Type = OutputResponseEnd
-		msg.Payload = ResponseEndPayload{
+		payload := ResponseEndPayload{
 			ResponseID:    replyTo,
 			FullText:      e.FullText,
 			TokensUsed:    e.TokensUsed,
 			AudioDuration: e.AudioDuration,
 			ActionsCount:  e.ActionsCount,
+			Interrupted:   e.Interrupted,
+		}
+		if e.Usage != nil {
+			payload.Usage = &UsagePayload{
+				InputTokens:  e.Usage.InputTokens,
+				OutputTokens: e.Usage.OutputTokens,
+				AudioSeconds: e.Usage.AudioSeconds,
+				Characters:   e.Usage.Characters,
+				Cost:         e.Usage.Cost,
+			}
+		}
+		msg.Payload = payload
+
+	case core.ResponseCancelledEvent:
+		msg.Type = OutputResponseCancelled
+		responseID := e.ResponseID
+		if responseID == "" {
+			responseID = replyTo
+		}
+		msg.Payload = ResponseCancelledPayload{
+			ResponseID: responseID,
+			Reason:     e.Reason,
+		}
+
+	case core.CitationEvent:
+		msg.Type = OutputCitation
+		msg.Payload = CitationPayload{
+			DocumentID: e.DocumentID,
+			ChunkID:    e.ChunkID,
+			Title:      e.Title,
+			URL:        e.URL,
+			Score:      e.Score,
+			Excerpt:    e.Excerpt,
+		}
+
+	case core.LanguageDetectedEvent:
+		msg.Type = OutputLanguage
+		msg.Payload = LanguagePayload{
+			Language:   e.Language,
+			Confidence: e.Confidence,
 		}
 
 	case core.ServiceMessageEvent:
 		msg.Type = OutputServiceMessage
+		if e.Localized == nil {
+			e = catalog().Localize(e, "")
+		}
 		msg.Payload = ServiceMessagePayload{
 			MessageType: string(e.MessageType),
 			Content:     e.Content,
 			Localized:   e.Localized,
+			Key:         e.Key,
+		}
+
+	case core.BatchEvent:
+		var messages []*OutputMessage
+		for _, event := range e.Events {
+			if inner := EventToMessage(event, sessionID, replyTo); inner != nil {
+				messages = append(messages, inner)
+			}
+		}
+		if len(messages) == 0 {
+			return nil
 		}
+		msg.Type = OutputStreamBatch
+		msg.Payload = BatchPayload{Messages: messages}
 
 	default:
 		// Unknown event type, skip
@@ -95,11 +196,62 @@ func EventToMessage(event core.Event, sessionID, replyTo string) *OutputMessage
 	return msg
 }
 
+// MessageToEvents converts a decoded input message to pipeline events.
+// A text message is a complete user turn, so it is followed by a DoneEvent;
+// control.cancel becomes an InterruptEvent and action.complete an
+// ActionResultEvent. Other types yield no events.
+func MessageToEvents(msg *InputMessage) []core.Event {
+	switch p := msg.Payload.(type) {
+	case TextInputPayload:
+		return []core.Event{
+			core.LLMEvent{Delta: p.Text, Content: p.Text},
+			core.DoneEvent{},
+		}
+
+	case AudioInputPayload:
+		return []core.Event{core.AudioEvent{Data: p.Data, Format: p.Format, SampleRate: p.SampleRate}}
+
+	case CancelPayload:
+		reason := p.Reason
+		if reason == "" {
+			reason = "client_cancel"
+		}
+		return []core.Event{core.InterruptEvent{Reason: reason}}
+
+	case ConfigPayload:
+		return []core.Event{core.ConfigUpdateEvent{
+			Language:   p.Language,
+			TTSEnabled: p.TTSEnabled,
+			Providers: core.ProviderPresets{
+				LLM:       p.Providers.LLM,
+				STT:       p.Providers.STT,
+				TTS:       p.Providers.TTS,
+				Embedding: p.Providers.Embedding,
+			},
+			Keywords: p.Keywords,
+		}}
+
+	case ActionCompletePayload:
+		return []core.Event{core.ActionResultEvent{
+			ActionID: p.ActionID,
+			Success:  p.Success,
+			Result:   p.Result,
+			Error:    p.Error,
+		}}
+	}
+
+	if msg.Type == InputEnd {
+		return []core.Event{core.DoneEvent{}}
+	}
+	return nil
+}
+
 // NewResponseAudioStartMessage creates a response.audio_start message
 func NewResponseAudioStartMessage(sessionID, replyTo, responseID, encoding string, sampleRate int) *OutputMessage {
 	return &OutputMessage{
 		Type:      OutputResponseAudioStart,
-		ID:        generateMessageID(),
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
 		SessionID: sessionID,
 		ReplyTo:   replyTo,
 		Payload: ResponseAudioStartPayload{
@@ -115,7 +267,8 @@ func NewResponseAudioStartMessage(sessionID, replyTo, responseID, encoding strin
 func NewResponseAudioEndMessage(sessionID, replyTo, responseID string, duration float64) *OutputMessage {
 	return &OutputMessage{
 		Type:      OutputResponseAudioEnd,
-		ID:        generateMessageID(),
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
 		SessionID: sessionID,
 		ReplyTo:   replyTo,
 		Payload: ResponseAudioEndPayload{
@@ -130,7 +283,8 @@ func NewResponseAudioEndMessage(sessionID, replyTo, responseID string, duration
 func NewResponseStartMessage(sessionID, replyTo, responseID string, sources []string) *OutputMessage {
 	return &OutputMessage{
 		Type:      OutputResponseStart,
-		ID:        generateMessageID(),
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
 		SessionID: sessionID,
 		ReplyTo:   replyTo,
 		Payload: ResponseStartPayload{
@@ -141,11 +295,28 @@ func NewResponseStartMessage(sessionID, replyTo, responseID string, sources []st
 	}
 }
 
+// NewResponseCancelledMessage creates a response.cancelled message
+func NewResponseCancelledMessage(sessionID, replyTo, responseID, reason string) *OutputMessage {
+	return &OutputMessage{
+		Type:      OutputResponseCancelled,
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
+		SessionID: sessionID,
+		ReplyTo:   replyTo,
+		Payload: ResponseCancelledPayload{
+			ResponseID: responseID,
+			Reason:     reason,
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
 // NewStatusMessage creates a status message
 func NewStatusMessage(sessionID string, status StatusType, target StatusTarget, message string) *OutputMessage {
 	return &OutputMessage{
 		Type:      OutputStatus,
-		ID:        generateMessageID(),
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
 		SessionID: sessionID,
 		Payload: StatusPayload{
 			Status:  status,
@@ -156,11 +327,23 @@ func NewStatusMessage(sessionID string, status StatusType, target StatusTarget,
 	}
 }
 
+// NewHeartbeatMessage creates a session.heartbeat message
+func NewHeartbeatMessage(sessionID string) *OutputMessage {
+	return &OutputMessage{
+		Type:      OutputHeartbeat,
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
+		SessionID: sessionID,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
 // NewErrorMessage creates an error message
 func NewErrorMessage(sessionID, replyTo, code, message string, retryable bool, details any) *OutputMessage {
 	return &OutputMessage{
 		Type:      OutputError,
-		ID:        generateMessageID(),
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
 		SessionID: sessionID,
 		ReplyTo:   replyTo,
 		Payload: ErrorPayload{
@@ -234,8 +417,3 @@ func mapActionType(a core.ActionType) ActionType {
 		return ActionCustom
 	}
 }
-
-// generateMessageID generates a unique message ID
-func generateMessageID() string {
-	return "msg-" + time.Now().Format("20060102150405.000000")
-}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Protocol versions. OutputMessage.Version reports the version a payload
+// follows; payload changes that old clients can't ignore bump ProtocolVersion.
+const (
+	ProtocolVersion    = 1 // Version spoken by this package
+	MinProtocolVersion = 1 // Oldest client version still accepted
+)
+
+// ErrUnsupportedVersion is returned when a client's protocol version is too old
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Feature is an optional protocol capability negotiated in session.hello
+type Feature string
+
+const (
+	FeatureBinaryAudio Feature = "binary_audio" // Audio in raw binary frames instead of stream.audio messages
+	FeatureInterimSTT  Feature = "interim_stt"  // Non-final stream.stt transcripts
+	FeatureActions     Feature = "actions"      // action.request messages
+)
+
+// AllFeatures lists the features this package implements
+var AllFeatures = []Feature{FeatureBinaryAudio, FeatureInterimSTT, FeatureActions}
+
+// HelloPayload for session.hello, sent by the client first and answered by
+// the server with the negotiated version and features
+type HelloPayload struct {
+	Version  int       `json:"version"`
+	Features []Feature `json:"features,omitempty"`
+}
+
+// Capabilities is the outcome of the session.hello handshake. The zero value
+// stands for a client that skipped the handshake: it gets the current
+// version and every feature, as before versioning.
+type Capabilities struct {
+	Version  int
+	Features []Feature
+}
+
+// Negotiate settles the version and features for a client's hello: the
+// lower of both versions and the features both sides support
+func Negotiate(client HelloPayload, supported []Feature) (Capabilities, error) {
+	if client.Version < MinProtocolVersion {
+		return Capabilities{}, fmt.Errorf("%w: client speaks %d, minimum is %d", ErrUnsupportedVersion, client.Version, MinProtocolVersion)
+	}
+
+	caps := Capabilities{Version: min(client.Version, ProtocolVersion)}
+	for _, feature := range client.Features {
+		if slices.Contains(supported, feature) && !slices.Contains(caps.Features, feature) {
+			caps.Features = append(caps.Features, feature)
+		}
+	}
+	return caps, nil
+}
+
+// Negotiated reports whether the capabilities come from a handshake
+func (c Capabilities) Negotiated() bool {
+	return c.Version != 0
+}
+
+// Has reports whether a feature may be used with the client
+func (c Capabilities) Has(feature Feature) bool {
+	return !c.Negotiated() || slices.Contains(c.Features, feature)
+}
+
+// MessageVersion returns the version to stamp on output messages
+func (c Capabilities) MessageVersion() int {
+	if !c.Negotiated() {
+		return ProtocolVersion
+	}
+	return c.Version
+}
+
+// NewHelloMessage creates the server's session.hello reply
+func NewHelloMessage(sessionID, replyTo string, caps Capabilities) *OutputMessage {
+	return &OutputMessage{
+		Type:      OutputHello,
+		Version:   caps.MessageVersion(),
+		ID:        NewMessageID(),
+		SessionID: sessionID,
+		ReplyTo:   replyTo,
+		Payload: HelloPayload{
+			Version:  caps.MessageVersion(),
+			Features: caps.Features,
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
@@ -0,0 +1,47 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	caps, err := Negotiate(HelloPayload{
+		Version:  ProtocolVersion + 1,
+		Features: []Feature{FeatureInterimSTT, "video", FeatureInterimSTT},
+	}, AllFeatures)
+	if err != nil {
+		t.Fatalf("Negotiate failed: %v", err)
+	}
+	if caps.Version != ProtocolVersion {
+		t.Errorf("expected version %d, got %d", ProtocolVersion, caps.Version)
+	}
+	if !reflect.DeepEqual(caps.Features, []Feature{FeatureInterimSTT}) {
+		t.Errorf("expected only interim STT, got %v", caps.Features)
+	}
+	if caps.Has(FeatureBinaryAudio) || !caps.Has(FeatureInterimSTT) {
+		t.Errorf("unexpected features %v", caps.Features)
+	}
+
+	if _, err := Negotiate(HelloPayload{Version: MinProtocolVersion - 1}, AllFeatures); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	// Clients that skip the handshake keep every feature
+	var legacy Capabilities
+	if !legacy.Has(FeatureBinaryAudio) || legacy.MessageVersion() != ProtocolVersion {
+		t.Error("expected zero capabilities to allow everything at the current version")
+	}
+}
+
+func TestDecodeHello(t *testing.T) {
+	msg, err := DecodeInput([]byte(`{"type":"session.hello","id":"h1","payload":{"version":1,"features":["actions"]}}`))
+	if err != nil {
+		t.Fatalf("DecodeInput failed: %v", err)
+	}
+	hello, ok := msg.Payload.(HelloPayload)
+	if !ok || hello.Version != 1 || len(hello.Features) != 1 || hello.Features[0] != FeatureActions {
+		t.Errorf("unexpected hello %#v", msg.Payload)
+	}
+}
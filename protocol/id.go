@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator creates message IDs. Implementations must be safe for
+// concurrent use and must not repeat IDs.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface
+type IDGeneratorFunc func() string
+
+// NewID calls f
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+var idGenerator atomic.Pointer[IDGenerator]
+
+// SetIDGenerator replaces the generator used for every message ID, e.g. to
+// use the host's UUIDs. Nil restores the default ULID generator.
+func SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		idGenerator.Store(nil)
+		return
+	}
+	idGenerator.Store(&g)
+}
+
+// NewMessageID returns a new message ID from the configured generator
+func NewMessageID() string {
+	if g := idGenerator.Load(); g != nil {
+		return (*g).NewID()
+	}
+	return defaultULIDs.NewID()
+}
+
+var defaultULIDs = NewULIDGenerator()
+
+// ULIDGenerator creates ULIDs: 26 character IDs of a millisecond timestamp
+// and 80 random bits that sort by creation time. IDs created within the
+// same millisecond increment the random part, so they stay unique and
+// ordered.
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewULIDGenerator creates a ULID generator
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+// NewID returns the next ULID
+func (g *ULIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond, or the clock went back: stay ordered
+		ms = g.lastMs
+		if !g.increment() {
+			// The random part overflowed, borrow the next millisecond
+			ms++
+			g.lastMs = ms
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.entropy[:])
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], g.entropy[:])
+	return encodeULID(id)
+}
+
+// increment adds one to the random part, reporting false on overflow
+func (g *ULIDGenerator) increment() bool {
+	for i := len(g.entropy) - 1; i >= 0; i-- {
+		g.entropy[i]++
+		if g.entropy[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// encodeULID encodes 128 bits as 26 Crockford base32 characters
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestULIDGenerator(t *testing.T) {
+	g := NewULIDGenerator()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				id := g.NewID()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate ID %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// IDs from one generator sort by creation
+	prev := g.NewID()
+	for i := 0; i < 1000; i++ {
+		id := g.NewID()
+		if len(id) != 26 || id <= prev {
+			t.Fatalf("expected %s to sort after %s", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	SetIDGenerator(IDGeneratorFunc(func() string { return "host-id" }))
+	defer SetIDGenerator(nil)
+
+	if msg := NewStatusMessage("s1", StatusListening, StatusTargetUser, ""); msg.ID != "host-id" {
+		t.Errorf("expected the host's ID, got %q", msg.ID)
+	}
+
+	SetIDGenerator(nil)
+	if id := NewMessageID(); len(id) != 26 {
+		t.Errorf("expected a ULID after reset, got %q", id)
+	}
+}
@@ -9,10 +9,20 @@ const (
 	InputAudio InputMessageType = "input.audio" // Audio chunk from user
 	InputEnd   InputMessageType = "input.end"   // End of audio stream
 
+	// Handshake
+	InputHello  InputMessageType = "session.hello"  // Protocol version and features, sent first
+	InputResume InputMessageType = "session.resume" // Replay messages missed while disconnected
+
+	// Keepalive
+	InputHeartbeat InputMessageType = "session.heartbeat" // Client is alive, no payload
+
 	// Control
 	InputCancel InputMessageType = "control.cancel" // Cancel current operation
 	InputConfig InputMessageType = "control.config" // Update session config
 
+	// Flow control
+	InputAck InputMessageType = "output.ack" // Client received server messages up to an ID
+
 	// Action response
 	InputActionComplete InputMessageType = "action.complete" // Client confirms action completed
 )
@@ -40,11 +50,18 @@ type AudioInputPayload struct {
 	SampleRate int    `json:"sampleRate"` // e.g., 16000
 }
 
+// AckPayload for output.ack. Acks are cumulative: acknowledging a message
+// acknowledges every message sent before it.
+type AckPayload struct {
+	MessageID string `json:"messageId"` // ID of the last message received
+}
+
 // ConfigPayload for control.config
 type ConfigPayload struct {
 	Language   string          `json:"language,omitempty"`
 	TTSEnabled *bool           `json:"ttsEnabled,omitempty"`
 	Providers  ProviderPresets `json:"providers,omitempty"`
+	Keywords   []string        `json:"keywords,omitempty"` // STT vocabulary, e.g. product and user names; replaces the session's
 }
 
 // ProviderPresets holds preset names for each capability
@@ -55,6 +72,12 @@ type ProviderPresets struct {
 	Embedding string `json:"embedding,omitempty"`
 }
 
+// CancelPayload for control.cancel
+type CancelPayload struct {
+	Reason     string `json:"reason,omitempty"`     // Why the client cancelled
+	ResponseID string `json:"responseId,omitempty"` // Response to cancel, empty for the current one
+}
+
 // ActionCompletePayload for action.complete (client → server)
 type ActionCompletePayload struct {
 	ActionID string `json:"actionId"`
@@ -0,0 +1,26 @@
+package protocol
+
+import (
+	"sync/atomic"
+
+	"github.com/creastat/pipeline/i18n"
+)
+
+var messageCatalog atomic.Pointer[i18n.Catalog]
+
+// SetMessageCatalog replaces the catalog that resolves keyed service
+// messages a LocalizeStage hasn't resolved when they are converted to
+// output messages, e.g. to add locales. Nil restores i18n.Default().
+func SetMessageCatalog(c *i18n.Catalog) {
+	messageCatalog.Store(c)
+}
+
+var defaultCatalog = i18n.Default()
+
+// catalog returns the configured message catalog
+func catalog() *i18n.Catalog {
+	if c := messageCatalog.Load(); c != nil {
+		return c
+	}
+	return defaultCatalog
+}
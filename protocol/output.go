@@ -8,9 +8,11 @@ const (
 	OutputStatus OutputMessageType = "status" // Status change notification
 
 	// Streaming content
-	OutputStreamSTT   OutputMessageType = "stream.stt"   // STT transcription chunk
-	OutputStreamLLM   OutputMessageType = "stream.llm"   // LLM response chunk
-	OutputStreamAudio OutputMessageType = "stream.audio" // TTS audio chunk
+	OutputStreamSTT   OutputMessageType = "stream.stt"      // STT transcription chunk
+	OutputStreamLLM   OutputMessageType = "stream.llm"      // LLM response chunk
+	OutputStreamAudio OutputMessageType = "stream.audio"    // TTS audio chunk
+	OutputLanguage    OutputMessageType = "stream.language" // Spoken language detected
+	OutputStreamBatch OutputMessageType = "stream.batch"    // Several messages in one frame
 
 	// Actions (client-executable commands)
 	OutputActionRequest OutputMessageType = "action.request" // Server requests client action
@@ -19,33 +21,64 @@ const (
 	OutputToolStart  OutputMessageType = "tool.start"  // Tool execution started
 	OutputToolResult OutputMessageType = "tool.result" // Tool execution result
 
+	// Sources
+	OutputCitation OutputMessageType = "response.citation" // RAG source used for the response
+
 	// Lifecycle
 	OutputResponseStart      OutputMessageType = "response.start"       // Response generation started
 	OutputResponseAudioStart OutputMessageType = "response.audio_start" // Audio stream started
 	OutputResponseAudioEnd   OutputMessageType = "response.audio_end"   // Audio stream ended
 	OutputResponseEnd        OutputMessageType = "response.end"         // Response complete
+	OutputResponseCancelled  OutputMessageType = "response.cancelled"   // Response aborted by control.cancel
 
 	// Service messages
 	OutputServiceMessage OutputMessageType = "service.message" // Service message for user feedback
 
+	// Handshake
+	OutputHello   OutputMessageType = "session.hello"   // Server's reply to the client's session.hello
+	OutputResumed OutputMessageType = "session.resumed" // Precedes the messages replayed for session.resume
+
+	// Keepalive
+	OutputHeartbeat OutputMessageType = "session.heartbeat" // Server is alive, no payload
+
 	// Errors
 	OutputError OutputMessageType = "error"
 )
 
 // OutputMessage represents a message to client
 type OutputMessage struct {
-	Type      OutputMessageType `json:"type"`
-	ID        string            `json:"id"`                // Server-generated message ID
-	SessionID string            `json:"sessionId"`         // Session identifier
-	ReplyTo   string            `json:"replyTo,omitempty"` // ID of input message
-	Payload   any               `json:"payload"`
-	Timestamp int64             `json:"timestamp"`
+	Type          OutputMessageType `json:"type"`
+	Version       int               `json:"version,omitempty"`       // Protocol version the payload follows
+	ID            string            `json:"id"`                      // Server-generated message ID
+	SessionID     string            `json:"sessionId"`               // Session identifier
+	ReplyTo       string            `json:"replyTo,omitempty"`       // ID of input message
+	CorrelationID string            `json:"correlationId,omitempty"` // Pipeline run that produced the message
+	Seq           uint64            `json:"seq,omitempty"`           // Position in the session's ReplayBuffer, if resumable
+	Payload       any               `json:"payload"`
+	Timestamp     int64             `json:"timestamp"`
 }
 
 // STTStreamPayload for stream.stt
 type STTStreamPayload struct {
-	Text       string  `json:"text"`
-	IsFinal    bool    `json:"isFinal"`
+	Text       string        `json:"text"`
+	IsFinal    bool          `json:"isFinal"`
+	Confidence float64       `json:"confidence,omitempty"`
+	Words      []WordPayload `json:"words,omitempty"`   // Word timings for highlighting
+	Speaker    string        `json:"speaker,omitempty"` // Speaker label from diarization
+}
+
+// WordPayload is a transcribed word with its timing in seconds
+type WordPayload struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Speaker    string  `json:"speaker,omitempty"`
+}
+
+// LanguagePayload for stream.language
+type LanguagePayload struct {
+	Language   string  `json:"language"`
 	Confidence float64 `json:"confidence,omitempty"`
 }
 
@@ -58,8 +91,11 @@ type LLMStreamPayload struct {
 // AudioStreamPayload for stream.audio
 // Note: Audio is typically sent as raw binary WebSocket message
 type AudioStreamPayload struct {
-	Data   []byte `json:"data,omitempty"` // Audio data (if sent as JSON)
-	Format string `json:"format"`         // Audio format
+	Data       []byte `json:"data,omitempty"`       // Audio data (if sent as JSON)
+	Format     string `json:"format"`               // Audio format
+	SampleRate int    `json:"sampleRate,omitempty"` // e.g. 24000
+	Channels   int    `json:"channels,omitempty"`   // e.g. 1
+	SeqNum     uint64 `json:"seq,omitempty"`        // Chunk sequence number for gap detection
 }
 
 // ActionRequestPayload for action.request
@@ -112,11 +148,33 @@ type ResponseStartPayload struct {
 
 // ResponseEndPayload for response.end
 type ResponseEndPayload struct {
-	ResponseID    string  `json:"responseId"`
-	FullText      string  `json:"fullText"` // Complete response text
-	TokensUsed    int     `json:"tokensUsed,omitempty"`
-	AudioDuration float64 `json:"audioDuration,omitempty"` // TTS duration in seconds
-	ActionsCount  int     `json:"actionsCount,omitempty"`  // Number of actions executed
+	ResponseID    string        `json:"responseId"`
+	FullText      string        `json:"fullText"` // Complete response text
+	TokensUsed    int           `json:"tokensUsed,omitempty"`
+	AudioDuration float64       `json:"audioDuration,omitempty"` // TTS duration in seconds
+	ActionsCount  int           `json:"actionsCount,omitempty"`  // Number of actions executed
+	Interrupted   bool          `json:"interrupted,omitempty"`   // Response was cut short by barge-in
+	Usage         *UsagePayload `json:"usage,omitempty"`         // Per-turn usage and cost
+}
+
+// BatchPayload for stream.batch
+type BatchPayload struct {
+	Messages []*OutputMessage `json:"messages"`
+}
+
+// ResponseCancelledPayload for response.cancelled
+type ResponseCancelledPayload struct {
+	ResponseID string `json:"responseId"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// UsagePayload reports provider usage and cost in response.end
+type UsagePayload struct {
+	InputTokens  int     `json:"inputTokens,omitempty"`
+	OutputTokens int     `json:"outputTokens,omitempty"`
+	AudioSeconds float64 `json:"audioSeconds,omitempty"`
+	Characters   int     `json:"characters,omitempty"`
+	Cost         float64 `json:"cost"`
 }
 
 // ResponseAudioStartPayload for response.audio_start
@@ -132,11 +190,22 @@ type ResponseAudioEndPayload struct {
 	Duration   float64 `json:"duration"` // Duration in seconds
 }
 
+// CitationPayload for response.citation
+type CitationPayload struct {
+	DocumentID string  `json:"documentId"`
+	ChunkID    string  `json:"chunkId,omitempty"`
+	Title      string  `json:"title,omitempty"`
+	URL        string  `json:"url,omitempty"`
+	Score      float32 `json:"score"`
+	Excerpt    string  `json:"excerpt,omitempty"`
+}
+
 // ServiceMessagePayload for service.message
 type ServiceMessagePayload struct {
 	MessageType string            `json:"messageType"` // retry_request, info, warning
 	Content     string            `json:"content"`
 	Localized   map[string]string `json:"localized,omitempty"` // Language code -> localized message
+	Key         string            `json:"key,omitempty"`       // Catalog key, for clients with their own translations
 }
 
 // ErrorPayload for error messages
@@ -0,0 +1,601 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// protobufCodec encodes messages as protobuf binary frames following
+// pipeline.proto. The schema is small and stable, so it is encoded by hand
+// instead of through generated code.
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Binary() bool { return true }
+
+// Protobuf wire types
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+var errProtobufShort = errors.New("protobuf: unexpected end of data")
+
+func (protobufCodec) Marshal(msg *OutputMessage) ([]byte, error) {
+	var e pbEncoder
+	e.string(1, string(msg.Type))
+	e.string(2, msg.ID)
+	e.string(3, msg.SessionID)
+	e.string(4, msg.ReplyTo)
+	e.string(5, msg.CorrelationID)
+	e.int(6, msg.Timestamp)
+	e.int(7, int64(msg.Version))
+	e.uint(8, msg.Seq)
+
+	payload := msg.Payload
+	if v := reflect.ValueOf(payload); v.Kind() == reflect.Pointer && !v.IsNil() {
+		payload = v.Elem().Interface()
+	}
+
+	var err error
+	switch p := payload.(type) {
+	case nil:
+	case StatusPayload:
+		err = e.message(10, func(e *pbEncoder) error {
+			e.string(1, string(p.Status))
+			e.string(2, string(p.Target))
+			e.string(3, p.Message)
+			return e.json(4, p.Details)
+		})
+	case STTStreamPayload:
+		err = e.message(11, func(e *pbEncoder) error {
+			e.string(1, p.Text)
+			e.bool(2, p.IsFinal)
+			e.double(3, p.Confidence)
+			for _, word := range p.Words {
+				e.message(4, func(e *pbEncoder) error {
+					e.string(1, word.Word)
+					e.double(2, word.Start)
+					e.double(3, word.End)
+					e.double(4, word.Confidence)
+					e.string(5, word.Speaker)
+					return nil
+				})
+			}
+			e.string(5, p.Speaker)
+			return nil
+		})
+	case LLMStreamPayload:
+		err = e.message(12, func(e *pbEncoder) error {
+			e.string(1, p.Delta)
+			e.string(2, p.Content)
+			return nil
+		})
+	case AudioStreamPayload:
+		err = e.message(13, func(e *pbEncoder) error {
+			e.bytes(1, p.Data)
+			e.string(2, p.Format)
+			e.int(3, int64(p.SampleRate))
+			e.int(4, int64(p.Channels))
+			e.uint(5, p.SeqNum)
+			return nil
+		})
+	case LanguagePayload:
+		err = e.message(14, func(e *pbEncoder) error {
+			e.string(1, p.Language)
+			e.double(2, p.Confidence)
+			return nil
+		})
+	case ActionRequestPayload:
+		err = e.message(15, func(e *pbEncoder) error {
+			e.string(1, p.ActionID)
+			e.string(2, string(p.ActionType))
+			e.string(3, p.Target)
+			if err := e.json(4, p.Data); err != nil {
+				return err
+			}
+			e.bool(5, p.Required)
+			e.int(6, int64(p.Timeout))
+			return nil
+		})
+	case ToolStartPayload:
+		err = e.message(16, func(e *pbEncoder) error {
+			e.string(1, p.ToolID)
+			e.string(2, p.ToolName)
+			e.string(3, p.Description)
+			return e.json(4, p.Input)
+		})
+	case ToolResultPayload:
+		err = e.message(17, func(e *pbEncoder) error {
+			e.string(1, p.ToolID)
+			e.bool(2, p.Success)
+			if err := e.json(3, p.Output); err != nil {
+				return err
+			}
+			e.string(4, p.Error)
+			return nil
+		})
+	case CitationPayload:
+		err = e.message(18, func(e *pbEncoder) error {
+			e.string(1, p.DocumentID)
+			e.string(2, p.ChunkID)
+			e.string(3, p.Title)
+			e.string(4, p.URL)
+			e.float(5, p.Score)
+			e.string(6, p.Excerpt)
+			return nil
+		})
+	case ResponseStartPayload:
+		err = e.message(19, func(e *pbEncoder) error {
+			e.string(1, p.ResponseID)
+			for _, source := range p.Sources {
+				e.tag(2, pbBytes)
+				e.varint(uint64(len(source)))
+				e.buf = append(e.buf, source...)
+			}
+			return nil
+		})
+	case ResponseAudioStartPayload:
+		err = e.message(20, func(e *pbEncoder) error {
+			e.string(1, p.ResponseID)
+			e.string(2, p.Encoding)
+			e.int(3, int64(p.SampleRate))
+			return nil
+		})
+	case ResponseAudioEndPayload:
+		err = e.message(21, func(e *pbEncoder) error {
+			e.string(1, p.ResponseID)
+			e.double(2, p.Duration)
+			return nil
+		})
+	case ResponseEndPayload:
+		err = e.message(22, func(e *pbEncoder) error {
+			e.string(1, p.ResponseID)
+			e.string(2, p.FullText)
+			e.int(3, int64(p.TokensUsed))
+			e.double(4, p.AudioDuration)
+			e.int(5, int64(p.ActionsCount))
+			e.bool(6, p.Interrupted)
+			if p.Usage != nil {
+				e.message(7, func(e *pbEncoder) error {
+					e.int(1, int64(p.Usage.InputTokens))
+					e.int(2, int64(p.Usage.OutputTokens))
+					e.double(3, p.Usage.AudioSeconds)
+					e.int(4, int64(p.Usage.Characters))
+					e.double(5, p.Usage.Cost)
+					return nil
+				})
+			}
+			return nil
+		})
+	case ResponseCancelledPayload:
+		err = e.message(23, func(e *pbEncoder) error {
+			e.string(1, p.ResponseID)
+			e.string(2, p.Reason)
+			return nil
+		})
+	case ServiceMessagePayload:
+		err = e.message(24, func(e *pbEncoder) error {
+			e.string(1, p.MessageType)
+			e.string(2, p.Content)
+			for language, text := range p.Localized {
+				e.message(3, func(e *pbEncoder) error {
+					e.string(1, language)
+					e.string(2, text)
+					return nil
+				})
+			}
+			e.string(4, p.Key)
+			return nil
+		})
+	case ErrorPayload:
+		err = e.message(25, func(e *pbEncoder) error {
+			e.string(1, p.Code)
+			e.string(2, p.Message)
+			e.bool(3, p.Retryable)
+			return e.json(4, p.Details)
+		})
+	case HelloPayload:
+		err = e.message(26, func(e *pbEncoder) error {
+			encodeHello(e, p)
+			return nil
+		})
+	case BatchPayload:
+		err = e.message(28, func(e *pbEncoder) error {
+			for _, inner := range p.Messages {
+				data, err := protobufCodec{}.Marshal(inner)
+				if err != nil {
+					return err
+				}
+				e.bytes(1, data)
+			}
+			return nil
+		})
+	case ResumedPayload:
+		err = e.message(27, func(e *pbEncoder) error {
+			e.uint(1, p.LastSeq)
+			e.int(2, int64(p.Replayed))
+			e.bool(3, p.Complete)
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("protobuf: unsupported payload %T for %s", msg.Payload, msg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (protobufCodec) Unmarshal(data []byte) (*InputMessage, error) {
+	msg := &InputMessage{}
+	err := pbFields(data, func(f pbField) error {
+		var err error
+		switch f.num {
+		case 1:
+			msg.Type = InputMessageType(f.data)
+		case 2:
+			msg.ID = string(f.data)
+		case 3:
+			msg.SessionID = string(f.data)
+		case 4:
+			msg.Timestamp = int64(f.varint)
+		case 10:
+			msg.Payload, err = decodeTextInput(f.data)
+		case 11:
+			msg.Payload, err = decodeAudioInput(f.data)
+		case 12:
+			msg.Payload, err = decodeConfig(f.data)
+		case 13:
+			msg.Payload, err = decodeCancel(f.data)
+		case 14:
+			msg.Payload, err = decodeActionComplete(f.data)
+		case 15:
+			msg.Payload, err = decodeHello(f.data)
+		case 16:
+			msg.Payload, err = decodeResume(f.data)
+		case 17:
+			msg.Payload, err = decodeAck(f.data)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode input message: %w", err)
+	}
+
+	// Match DecodeInput: the payload is typed by the message type, and
+	// missing payloads decode as empty ones
+	var empty any
+	switch msg.Type {
+	case InputHello:
+		empty = HelloPayload{}
+	case InputResume:
+		empty = ResumePayload{}
+	case InputText:
+		empty = TextInputPayload{}
+	case InputAudio:
+		empty = AudioInputPayload{}
+	case InputConfig:
+		empty = ConfigPayload{}
+	case InputCancel:
+		empty = CancelPayload{}
+	case InputActionComplete:
+		empty = ActionCompletePayload{}
+	case InputAck:
+		empty = AckPayload{}
+	case InputEnd, InputHeartbeat:
+		if msg.Payload != nil {
+			return nil, fmt.Errorf("failed to decode %s payload: unexpected %T", msg.Type, msg.Payload)
+		}
+		return msg, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownInputType, msg.Type)
+	}
+	if msg.Payload == nil {
+		msg.Payload = empty
+	} else if reflect.TypeOf(msg.Payload) != reflect.TypeOf(empty) {
+		return nil, fmt.Errorf("failed to decode %s payload: unexpected %T", msg.Type, msg.Payload)
+	}
+	return msg, nil
+}
+
+func encodeHello(e *pbEncoder, p HelloPayload) {
+	e.int(1, int64(p.Version))
+	for _, feature := range p.Features {
+		e.tag(2, pbBytes)
+		e.varint(uint64(len(feature)))
+		e.buf = append(e.buf, feature...)
+	}
+}
+
+func decodeHello(data []byte) (HelloPayload, error) {
+	var p HelloPayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.Version = int(int64(f.varint))
+		case 2:
+			p.Features = append(p.Features, Feature(f.data))
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeResume(data []byte) (ResumePayload, error) {
+	var p ResumePayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.SessionID = string(f.data)
+		case 2:
+			p.LastSeq = f.varint
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeAck(data []byte) (AckPayload, error) {
+	var p AckPayload
+	err := pbFields(data, func(f pbField) error {
+		if f.num == 1 {
+			p.MessageID = string(f.data)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeTextInput(data []byte) (TextInputPayload, error) {
+	var p TextInputPayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.Text = string(f.data)
+		case 2:
+			p.SourceID = string(f.data)
+		case 3:
+			return json.Unmarshal(f.data, &p.Context)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeAudioInput(data []byte) (AudioInputPayload, error) {
+	var p AudioInputPayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.Data = append([]byte(nil), f.data...)
+		case 2:
+			p.Format = string(f.data)
+		case 3:
+			p.SampleRate = int(int64(f.varint))
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeConfig(data []byte) (ConfigPayload, error) {
+	var p ConfigPayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.Language = string(f.data)
+		case 2:
+			enabled := f.varint != 0
+			p.TTSEnabled = &enabled
+		case 3:
+			return pbFields(f.data, func(f pbField) error {
+				switch f.num {
+				case 1:
+					p.Providers.LLM = string(f.data)
+				case 2:
+					p.Providers.STT = string(f.data)
+				case 3:
+					p.Providers.TTS = string(f.data)
+				case 4:
+					p.Providers.Embedding = string(f.data)
+				}
+				return nil
+			})
+		case 4:
+			p.Keywords = append(p.Keywords, string(f.data))
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeCancel(data []byte) (CancelPayload, error) {
+	var p CancelPayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.Reason = string(f.data)
+		case 2:
+			p.ResponseID = string(f.data)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeActionComplete(data []byte) (ActionCompletePayload, error) {
+	var p ActionCompletePayload
+	err := pbFields(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			p.ActionID = string(f.data)
+		case 2:
+			p.Success = f.varint != 0
+		case 3:
+			return json.Unmarshal(f.data, &p.Result)
+		case 4:
+			p.Error = string(f.data)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// pbEncoder appends protobuf fields, omitting proto3 default values
+type pbEncoder struct {
+	buf []byte
+}
+
+func (e *pbEncoder) varint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *pbEncoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *pbEncoder) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *pbEncoder) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *pbEncoder) bool(field int, v bool) {
+	if v {
+		e.tag(field, pbVarint)
+		e.varint(1)
+	}
+}
+
+func (e *pbEncoder) int(field int, v int64) {
+	if v != 0 {
+		e.tag(field, pbVarint)
+		e.varint(uint64(v))
+	}
+}
+
+func (e *pbEncoder) uint(field int, v uint64) {
+	if v != 0 {
+		e.tag(field, pbVarint)
+		e.varint(v)
+	}
+}
+
+func (e *pbEncoder) double(field int, v float64) {
+	if v != 0 {
+		e.tag(field, pbFixed64)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+	}
+}
+
+func (e *pbEncoder) float(field int, v float32) {
+	if v != 0 {
+		e.tag(field, pbFixed32)
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+	}
+}
+
+// json encodes a free-form value as JSON bytes, omitting nil values
+func (e *pbEncoder) json(field int, v any) error {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice || rv.Kind() == reflect.Pointer) && rv.IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("protobuf: field %d: %w", field, err)
+	}
+	e.bytes(field, data)
+	return nil
+}
+
+// message encodes an embedded message. It is written even when empty, so
+// oneof members stay set.
+func (e *pbEncoder) message(field int, encode func(e *pbEncoder) error) error {
+	var nested pbEncoder
+	if err := encode(&nested); err != nil {
+		return err
+	}
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(nested.buf)))
+	e.buf = append(e.buf, nested.buf...)
+	return nil
+}
+
+// pbField is a decoded field: varint holds varint and fixed values, data
+// holds length-delimited values
+type pbField struct {
+	num    int
+	varint uint64
+	data   []byte
+}
+
+// pbFields calls fn for each field of a message; callers ignore field
+// numbers they don't know
+func pbFields(data []byte, fn func(f pbField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtobufShort
+		}
+		data = data[n:]
+
+		f := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case pbVarint:
+			f.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errProtobufShort
+			}
+			data = data[n:]
+		case pbFixed64:
+			if len(data) < 8 {
+				return errProtobufShort
+			}
+			f.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case pbFixed32:
+			if len(data) < 4 {
+				return errProtobufShort
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case pbBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errProtobufShort
+			}
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", key&7)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
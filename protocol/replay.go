@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultReplaySize = 256
+
+// ResumePayload for session.resume, sent by a reconnecting client after
+// session.hello
+type ResumePayload struct {
+	SessionID string `json:"sessionId"` // Session to resume
+	LastSeq   uint64 `json:"lastSeq"`   // Seq of the last message the client received
+}
+
+// ResumedPayload for session.resumed, sent before the replayed messages
+type ResumedPayload struct {
+	LastSeq  uint64 `json:"lastSeq"`  // Seq of the last message sent before the reconnect
+	Replayed int    `json:"replayed"` // Number of messages that follow
+	Complete bool   `json:"complete"` // False if some missed messages were already evicted
+}
+
+// ReplayBuffer keeps the most recent messages of a session so a client that
+// reconnects can receive what it missed. It outlives the connection: keep
+// one per session and hand it to each new WebSocketSink.
+type ReplayBuffer struct {
+	mu       sync.Mutex
+	size     int
+	messages []*OutputMessage
+	lastSeq  uint64
+}
+
+// NewReplayBuffer creates a buffer holding up to size messages (default 256)
+func NewReplayBuffer(size int) *ReplayBuffer {
+	if size <= 0 {
+		size = defaultReplaySize
+	}
+	return &ReplayBuffer{
+		size:     size,
+		messages: make([]*OutputMessage, 0, size),
+	}
+}
+
+// Append assigns the message the next sequence number and stores a copy,
+// evicting the oldest message when the buffer is full. The caller may go on
+// to change its message, e.g. to stamp the version.
+func (b *ReplayBuffer) Append(msg *OutputMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastSeq++
+	msg.Seq = b.lastSeq
+	stored := *msg
+	if len(b.messages) == b.size {
+		copy(b.messages, b.messages[1:])
+		b.messages = b.messages[:len(b.messages)-1]
+	}
+	b.messages = append(b.messages, &stored)
+}
+
+// Since returns the messages after lastSeq, oldest first. complete is false
+// when messages the client missed were already evicted, or when lastSeq is
+// ahead of the buffer, e.g. after a server restart.
+func (b *ReplayBuffer) Since(lastSeq uint64) (messages []*OutputMessage, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastSeq > b.lastSeq {
+		return nil, false
+	}
+	if len(b.messages) == 0 {
+		return nil, true
+	}
+
+	oldest := b.messages[0].Seq
+	start := 0
+	if lastSeq >= oldest {
+		start = int(lastSeq - oldest + 1)
+	}
+	return append([]*OutputMessage(nil), b.messages[start:]...), lastSeq+1 >= oldest
+}
+
+// LastSeq returns the sequence number of the last appended message
+func (b *ReplayBuffer) LastSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastSeq
+}
+
+// NewResumedMessage creates the session.resumed message that precedes the
+// replayed messages
+func NewResumedMessage(sessionID, replyTo string, lastSeq uint64, replayed int, complete bool) *OutputMessage {
+	return &OutputMessage{
+		Type:      OutputResumed,
+		Version:   ProtocolVersion,
+		ID:        NewMessageID(),
+		SessionID: sessionID,
+		ReplyTo:   replyTo,
+		Payload: ResumedPayload{
+			LastSeq:  lastSeq,
+			Replayed: replayed,
+			Complete: complete,
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
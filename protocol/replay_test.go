@@ -0,0 +1,67 @@
+package protocol
+
+import "testing"
+
+func TestReplayBuffer(t *testing.T) {
+	b := NewReplayBuffer(3)
+	for i := 0; i < 5; i++ {
+		b.Append(&OutputMessage{Type: OutputStreamLLM})
+	}
+	if b.LastSeq() != 5 {
+		t.Fatalf("expected last seq 5, got %d", b.LastSeq())
+	}
+
+	missed, complete := b.Since(3)
+	if !complete || len(missed) != 2 || missed[0].Seq != 4 || missed[1].Seq != 5 {
+		t.Errorf("expected seqs 4 and 5, got %d messages, complete %v", len(missed), complete)
+	}
+
+	// Seqs 1 and 2 were evicted
+	missed, complete = b.Since(0)
+	if complete || len(missed) != 3 || missed[0].Seq != 3 {
+		t.Errorf("expected an incomplete replay from seq 3, got %d messages, complete %v", len(missed), complete)
+	}
+
+	if missed, complete := b.Since(5); !complete || len(missed) != 0 {
+		t.Errorf("expected nothing to replay, got %d messages, complete %v", len(missed), complete)
+	}
+
+	// A client ahead of the buffer resumes against a different server
+	if _, complete := b.Since(9); complete {
+		t.Error("expected an incomplete replay for an unknown seq")
+	}
+}
+
+func TestDecodeResume(t *testing.T) {
+	msg, err := DecodeInput([]byte(`{"type":"session.resume","id":"r1","payload":{"sessionId":"s1","lastSeq":42}}`))
+	if err != nil {
+		t.Fatalf("DecodeInput failed: %v", err)
+	}
+	if resume, ok := msg.Payload.(ResumePayload); !ok || resume.SessionID != "s1" || resume.LastSeq != 42 {
+		t.Errorf("unexpected resume %#v", msg.Payload)
+	}
+}
+
+func TestCodecsEncodeResumed(t *testing.T) {
+	msg := NewResumedMessage("s1", "r1", 42, 2, true)
+	msg.Seq = 7
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, ProtobufCodec} {
+		if data, err := codec.Marshal(msg); err != nil || len(data) == 0 {
+			t.Errorf("%s: encoding session.resumed failed: %v", codec.Name(), err)
+		}
+	}
+}
+
+// TestReplayBufferStoresCopy tests that changing a message after Append
+// doesn't change the stored one
+func TestReplayBufferStoresCopy(t *testing.T) {
+	b := NewReplayBuffer(3)
+	msg := &OutputMessage{Type: OutputStreamLLM, ID: "m1"}
+	b.Append(msg)
+	msg.Version = 99
+
+	missed, _ := b.Since(0)
+	if len(missed) != 1 || missed[0] == msg || missed[0].Version != 0 || missed[0].ID != "m1" || missed[0].Seq != 1 {
+		t.Errorf("expected a copy of the appended message, got %#v", missed)
+	}
+}
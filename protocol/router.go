@@ -0,0 +1,185 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// ErrUnknownInputType is returned for input messages with an unsupported type
+var ErrUnknownInputType = errors.New("unknown input message type")
+
+// InputHandler handles a decoded input message
+type InputHandler func(ctx context.Context, msg *InputMessage) error
+
+// DecodeInput parses a JSON text frame into an InputMessage whose Payload is
+// the typed payload for its message type (e.g. TextInputPayload)
+func DecodeInput(data []byte) (*InputMessage, error) {
+	var envelope struct {
+		InputMessage
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode input message: %w", err)
+	}
+
+	msg := envelope.InputMessage
+
+	var payload any
+	switch msg.Type {
+	case InputHello:
+		payload = &HelloPayload{}
+	case InputResume:
+		payload = &ResumePayload{}
+	case InputText:
+		payload = &TextInputPayload{}
+	case InputAudio:
+		payload = &AudioInputPayload{}
+	case InputConfig:
+		payload = &ConfigPayload{}
+	case InputCancel:
+		payload = &CancelPayload{}
+	case InputActionComplete:
+		payload = &ActionCompletePayload{}
+	case InputAck:
+		payload = &AckPayload{}
+	case InputEnd, InputHeartbeat:
+		// No payload
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownInputType, msg.Type)
+	}
+
+	if payload != nil && len(envelope.Payload) > 0 && string(envelope.Payload) != "null" {
+		if err := json.Unmarshal(envelope.Payload, payload); err != nil {
+			return nil, fmt.Errorf("failed to decode %s payload: %w", msg.Type, err)
+		}
+	}
+
+	// Store payloads by value, matching how OutputMessage payloads are built
+	switch p := payload.(type) {
+	case *HelloPayload:
+		msg.Payload = *p
+	case *ResumePayload:
+		msg.Payload = *p
+	case *TextInputPayload:
+		msg.Payload = *p
+	case *AudioInputPayload:
+		msg.Payload = *p
+	case *ConfigPayload:
+		msg.Payload = *p
+	case *CancelPayload:
+		msg.Payload = *p
+	case *ActionCompletePayload:
+		msg.Payload = *p
+	case *AckPayload:
+		msg.Payload = *p
+	}
+
+	return &msg, nil
+}
+
+// InputRouter decodes client frames and dispatches them to handlers
+// registered per message type
+type InputRouter struct {
+	mu       sync.RWMutex
+	handlers map[InputMessageType]InputHandler
+	fallback InputHandler
+
+	// BinaryFormat and BinarySampleRate describe raw binary audio frames
+	BinaryFormat     string
+	BinarySampleRate int
+}
+
+// NewInputRouter creates an input router with no handlers
+func NewInputRouter() *InputRouter {
+	return &InputRouter{
+		handlers: make(map[InputMessageType]InputHandler),
+	}
+}
+
+// Handle registers the handler for a message type, replacing any existing one
+func (r *InputRouter) Handle(msgType InputMessageType, handler InputHandler) *InputRouter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[msgType] = handler
+	return r
+}
+
+// HandleDefault registers the handler for message types without a handler
+func (r *InputRouter) HandleDefault(handler InputHandler) *InputRouter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = handler
+	return r
+}
+
+// Dispatch decodes a JSON text frame and hands it to its handler
+func (r *InputRouter) Dispatch(ctx context.Context, data []byte) error {
+	msg, err := DecodeInput(data)
+	if err != nil {
+		return err
+	}
+	return r.DispatchMessage(ctx, msg)
+}
+
+// DispatchWith decodes a frame with codec and hands it to its handler
+func (r *InputRouter) DispatchWith(ctx context.Context, codec Codec, data []byte) error {
+	msg, err := codec.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return r.DispatchMessage(ctx, msg)
+}
+
+// DispatchBinary treats a binary frame as an input.audio chunk
+func (r *InputRouter) DispatchBinary(ctx context.Context, sessionID string, data []byte) error {
+	return r.DispatchMessage(ctx, &InputMessage{
+		Type:      InputAudio,
+		SessionID: sessionID,
+		Payload: AudioInputPayload{
+			Data:       data,
+			Format:     r.BinaryFormat,
+			SampleRate: r.BinarySampleRate,
+		},
+	})
+}
+
+// DispatchMessage hands a decoded message to its handler. Messages without
+// a handler are ignored unless a default handler is registered.
+func (r *InputRouter) DispatchMessage(ctx context.Context, msg *InputMessage) error {
+	r.mu.RLock()
+	handler, exists := r.handlers[msg.Type]
+	if !exists {
+		handler = r.fallback
+	}
+	r.mu.RUnlock()
+
+	if handler == nil {
+		return nil
+	}
+	return handler(ctx, msg)
+}
+
+// FeedPipeline registers handlers that convert text, audio, end, config, and
+// cancel messages into core events and send them to a pipeline entry channel
+func (r *InputRouter) FeedPipeline(entry chan<- core.Event) *InputRouter {
+	feed := func(ctx context.Context, msg *InputMessage) error {
+		for _, event := range MessageToEvents(msg) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case entry <- event:
+			}
+		}
+		return nil
+	}
+
+	for _, msgType := range []InputMessageType{InputText, InputAudio, InputEnd, InputConfig, InputCancel} {
+		r.Handle(msgType, feed)
+	}
+	return r
+}
@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"github.com/creastat/pipeline/core"
+)
+
+// UpdateConfig reconfigures the pipeline's stages, e.g. when the client
+// switches language or turns TTS off. The update is handed to every stage
+// implementing core.ConfigSubscriber, including stages waiting to replace a
+// node and, through their fan-outs and nested pipelines, branch stages, and
+// takes effect from each stage's next turn. A ConfigUpdateEvent
+// sent on the pipeline input is applied the same way.
+func (p *Pipeline) UpdateConfig(update core.ConfigUpdateEvent) {
+	p.mu.Lock()
+	subscribers := make([]core.ConfigSubscriber, 0, len(p.graph.AllNodes()))
+	for _, node := range p.graph.AllNodes() {
+		if subscriber, ok := node.runnable().(core.ConfigSubscriber); ok {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+	for _, stage := range p.replacements {
+		if subscriber, ok := stage.(core.ConfigSubscriber); ok {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+	p.mu.Unlock()
+
+	// Subscribers may forward to nested pipelines, so call them unlocked
+	for _, subscriber := range subscribers {
+		subscriber.ApplyConfig(update)
+	}
+}
+
+// ApplyConfig forwards a configuration update to the wrapped pipeline
+func (ps *PipelineStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	ps.pipeline.UpdateConfig(update)
+}
+
+// ApplyConfig forwards a configuration update to the stages of all current
+// branches
+func (fs *FanOutStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	for _, branch := range fs.router.Branches() {
+		if subscriber, ok := branch.Stage.(core.ConfigSubscriber); ok {
+			subscriber.ApplyConfig(update)
+		}
+	}
+}
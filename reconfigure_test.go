@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// languageMockStage prefixes LLM deltas with the language of the last config
+// update and forwards other events
+type languageMockStage struct {
+	mu       sync.Mutex
+	language string
+}
+
+func (m *languageMockStage) Name() string {
+	return "language"
+}
+
+func (m *languageMockStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if update.Language != "" {
+		m.language = update.Language
+	}
+}
+
+func (m *languageMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		if llm, ok := event.(core.LLMEvent); ok {
+			m.mu.Lock()
+			event = core.LLMEvent{Delta: m.language + ":" + llm.Delta}
+			m.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
+
+func (m *languageMockStage) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+func (m *languageMockStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// TestConfigUpdateFromInput tests that a ConfigUpdateEvent on the pipeline
+// input reaches subscribed stages without being routed through the graph
+func TestConfigUpdateFromInput(t *testing.T) {
+	stage := &languageMockStage{language: "en"}
+	pipeline, err := NewBuilder().
+		AddStage("llm", stage).
+		AddStage("wrapped", AsStage(mustLinear(t, &languageMockStage{language: "en"}))).
+		Connect("llm", "wrapped").
+		SetEntryNode("llm").
+		AddExitNode("wrapped").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	output := pipeline.Execute(context.Background(), input)
+
+	expectDelta := func(want string) {
+		t.Helper()
+		select {
+		case event := <-output:
+			if llm, ok := event.(core.LLMEvent); !ok || llm.Delta != want {
+				t.Errorf("expected delta %q, got %#v", want, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for output")
+		}
+	}
+
+	input <- core.LLMEvent{Delta: "1"}
+	expectDelta("en:en:1")
+
+	input <- core.ConfigUpdateEvent{Language: "de"}
+	input <- core.LLMEvent{Delta: "2"}
+	expectDelta("de:de:2")
+
+	close(input)
+	for event := range output {
+		if _, ok := event.(core.ConfigUpdateEvent); ok {
+			t.Errorf("config update should not reach the output")
+		}
+	}
+}
+
+// TestUpdateConfigNotRunning tests that updates reach stages between runs
+func TestUpdateConfigNotRunning(t *testing.T) {
+	stage := &languageMockStage{language: "en"}
+	pipeline := mustLinear(t, stage)
+
+	pipeline.UpdateConfig(core.ConfigUpdateEvent{Language: "fr"})
+
+	stage.mu.Lock()
+	defer stage.mu.Unlock()
+	if stage.language != "fr" {
+		t.Errorf("expected language fr, got %q", stage.language)
+	}
+}
+
+// TestUpdateConfigFanOutBranches tests that updates reach the branches of a
+// fan-out added with AddFanOut, and nested pipelines within them
+func TestUpdateConfigFanOutBranches(t *testing.T) {
+	branch := &languageMockStage{language: "en"}
+	nested := &languageMockStage{language: "en"}
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddFanOut("fanout", core.FanOutConfig{Branches: []core.BranchConfig{
+			{Stage: branch},
+			{Stage: AsStage(mustLinear(t, nested))},
+		}}).
+		Connect("source", "fanout").
+		SetEntryNode("source").
+		AddExitNode("fanout").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	pipeline.UpdateConfig(core.ConfigUpdateEvent{Language: "fr"})
+
+	for _, stage := range []*languageMockStage{branch, nested} {
+		stage.mu.Lock()
+		if stage.language != "fr" {
+			t.Errorf("expected language fr, got %q", stage.language)
+		}
+		stage.mu.Unlock()
+	}
+}
+
+func mustLinear(t *testing.T, stages ...core.Stage) *Pipeline {
+	t.Helper()
+	p, err := Linear(stages...)
+	if err != nil {
+		t.Fatalf("Linear failed: %v", err)
+	}
+	return p
+}
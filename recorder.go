@@ -0,0 +1,315 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// RecordedEvent is a single line of a JSONL recording
+type RecordedEvent struct {
+	Offset time.Duration   `json:"offset"` // Time since the first recorded event
+	Time   time.Time       `json:"time"`
+	Type   core.EventType  `json:"type"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// recordedError is the serialized form of ErrorEvent, whose error value has
+// no JSON representation of its own
+type recordedError struct {
+	Error     string
+	Retryable bool
+	Code      core.ErrCode
+	Meta      core.EventMeta
+}
+
+// Recorder is a pass-through stage that writes every event it sees, with
+// timestamps, as JSONL. Place it anywhere in a graph to capture the traffic
+// on that edge; Replayer plays the recording back.
+type Recorder struct {
+	w      io.Writer
+	closer io.Closer
+	now    func() time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewRecorder creates a recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, now: time.Now}
+}
+
+// NewFileRecorder creates a recorder writing to a new file at path.
+// Close the recorder to close the file.
+func NewFileRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+// Name returns the stage name
+func (r *Recorder) Name() string {
+	return "recorder"
+}
+
+// InputTypes returns the event types this stage accepts
+func (r *Recorder) InputTypes() []core.EventType {
+	// Recorder accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (r *Recorder) OutputTypes() []core.EventType {
+	// Recorder passes everything through
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface. Write failures never interrupt the
+// pipeline: recording stops and the error is reported by Err.
+func (r *Recorder) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	var start time.Time
+	for event := range input {
+		if r.Err() == nil {
+			now := r.now()
+			if start.IsZero() {
+				start = now
+			}
+			if err := r.write(event, now, now.Sub(start)); err != nil {
+				r.mu.Lock()
+				r.err = err
+				r.mu.Unlock()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
+
+// write encodes a single event as a JSONL line
+func (r *Recorder) write(event core.Event, now time.Time, offset time.Duration) error {
+	var payload any = event
+	if e, ok := event.(core.ErrorEvent); ok {
+		re := recordedError{Retryable: e.Retryable, Code: e.ErrCode(), Meta: e.Meta}
+		if e.Error != nil {
+			re.Error = e.Error.Error()
+		}
+		payload = re
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+	}
+	line, err := json.Marshal(RecordedEvent{Offset: offset, Time: now, Type: event.EventType(), Event: raw})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// Err returns the first error that stopped the recording, if any
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the file of a recorder created with NewFileRecorder
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Replayer is a source stage that emits the events of a recording made by
+// Recorder. Its input is ignored.
+type Replayer struct {
+	r      io.Reader
+	closer io.Closer
+	scale  float64
+}
+
+// NewReplayer creates a replayer reading a recording from r. Scale multiplies
+// the recorded delays between events: 1 replays with the original timing, 0.5
+// twice as fast, and 0 emits all events without delay.
+func NewReplayer(r io.Reader, scale float64) *Replayer {
+	return &Replayer{r: r, scale: scale}
+}
+
+// NewFileReplayer creates a replayer reading the recording at path.
+// Close the replayer to close the file.
+func NewFileReplayer(path string, scale float64) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	r := NewReplayer(f, scale)
+	r.closer = f
+	return r, nil
+}
+
+// Name returns the stage name
+func (r *Replayer) Name() string {
+	return "replayer"
+}
+
+// InputTypes returns the event types this stage accepts
+func (r *Replayer) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (r *Replayer) OutputTypes() []core.EventType {
+	// Recordings may contain any event type
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface. Events of types unknown to this
+// package, such as custom application events, are skipped.
+func (r *Replayer) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	scanner := bufio.NewScanner(r.r)
+	// Audio events make for long lines
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	start := time.Now()
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var recorded RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return fmt.Errorf("invalid recording at line %d: %w", line, err)
+		}
+		event, err := DecodeRecordedEvent(recorded)
+		if err != nil {
+			return fmt.Errorf("invalid recording at line %d: %w", line, err)
+		}
+		if event == nil {
+			continue
+		}
+
+		if r.scale > 0 {
+			due := start.Add(time.Duration(float64(recorded.Offset) * r.scale))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file of a replayer created with NewFileReplayer
+func (r *Replayer) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// DecodeRecordedEvent returns the event stored in a recording line. It
+// returns a nil event for event types unknown to this package.
+func DecodeRecordedEvent(recorded RecordedEvent) (core.Event, error) {
+	var event core.Event
+	var err error
+
+	switch recorded.Type {
+	case core.EventTypeStatus:
+		event, err = decodeEvent[core.StatusEvent](recorded.Event)
+	case core.EventTypeSTT:
+		event, err = decodeEvent[core.STTEvent](recorded.Event)
+	case core.EventTypeLLM:
+		event, err = decodeEvent[core.LLMEvent](recorded.Event)
+	case core.EventTypeAudio:
+		event, err = decodeEvent[core.AudioEvent](recorded.Event)
+	case core.EventTypeAction:
+		event, err = decodeEvent[core.ActionEvent](recorded.Event)
+	case core.EventTypeDone:
+		event, err = decodeEvent[core.DoneEvent](recorded.Event)
+	case core.EventTypeServiceMessage:
+		event, err = decodeEvent[core.ServiceMessageEvent](recorded.Event)
+	case core.EventTypeInterrupt:
+		event, err = decodeEvent[core.InterruptEvent](recorded.Event)
+	case core.EventTypeCitation:
+		event, err = decodeEvent[core.CitationEvent](recorded.Event)
+	case core.EventTypeUsage:
+		event, err = decodeEvent[core.UsageEvent](recorded.Event)
+	case core.EventTypeLanguage:
+		event, err = decodeEvent[core.LanguageDetectedEvent](recorded.Event)
+	case core.EventTypeLatency:
+		event, err = decodeEvent[core.LatencyMarkEvent](recorded.Event)
+	case core.EventTypeRawText:
+		event, err = decodeEvent[core.RawTextEvent](recorded.Event)
+	case core.EventTypeActionBlock:
+		event, err = decodeEvent[core.ActionBlockEvent](recorded.Event)
+	case core.EventTypeActionResult:
+		event, err = decodeEvent[core.ActionResultEvent](recorded.Event)
+	case core.EventTypeToolCall:
+		event, err = decodeEvent[core.ToolCallEvent](recorded.Event)
+	case core.EventTypeToolStart:
+		event, err = decodeEvent[core.ToolStartEvent](recorded.Event)
+	case core.EventTypeToolResult:
+		event, err = decodeEvent[core.ToolResultEvent](recorded.Event)
+	case core.EventTypeError:
+		var re recordedError
+		if err = json.Unmarshal(recorded.Event, &re); err == nil {
+			event = core.ErrorEvent{Error: errors.New(re.Error), Retryable: re.Retryable, Code: re.Code, Meta: re.Meta}
+		}
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", recorded.Type, err)
+	}
+	return event, nil
+}
+
+// decodeEvent unmarshals a recorded event into its concrete type
+func decodeEvent[E core.Event](raw json.RawMessage) (core.Event, error) {
+	var event E
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
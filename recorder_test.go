@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestRecorderReplayRoundTrip tests that a replayed recording reproduces the
+// recorded events and that the recorder passes them through unchanged
+func TestRecorderReplayRoundTrip(t *testing.T) {
+	events := []core.Event{
+		core.StatusEvent{Status: core.StatusListening, Target: core.StatusTargetUser},
+		core.AudioEvent{Data: []byte{1, 2, 3}, Format: "pcm", SampleRate: 16000, SeqNum: 1},
+		core.STTEvent{Text: "hello", IsFinal: true, Words: []core.WordInfo{{Word: "hello", End: 0.4}}},
+		core.ActionEvent{ActionID: "a1", Data: map[string]any{"url": "/pricing"}},
+		core.ErrorEvent{Error: errors.New("provider timeout"), Retryable: true},
+		core.DoneEvent{FullText: "hi", Meta: core.EventMeta{CorrelationID: "run-1", Origin: "llm"}},
+	}
+
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+
+	input := make(chan core.Event, len(events))
+	passed := make(chan core.Event, len(events))
+	for _, event := range events {
+		input <- event
+	}
+	close(input)
+
+	if err := recorder.Process(context.Background(), input, passed); err != nil {
+		t.Fatalf("recorder failed: %v", err)
+	}
+	close(passed)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("recording failed: %v", err)
+	}
+
+	i := 0
+	for event := range passed {
+		if !reflect.DeepEqual(event, events[i]) {
+			t.Errorf("recorder changed event %d: %+v", i, event)
+		}
+		i++
+	}
+
+	if lines := strings.Count(recording.String(), "\n"); lines != len(events) {
+		t.Fatalf("expected %d JSONL lines, got %d", len(events), lines)
+	}
+
+	replayer := NewReplayer(&recording, 0)
+	replayed := make(chan core.Event, len(events))
+	if err := replayer.Process(context.Background(), nil, replayed); err != nil {
+		t.Fatalf("replayer failed: %v", err)
+	}
+	close(replayed)
+
+	i = 0
+	for event := range replayed {
+		want := events[i]
+		if e, ok := want.(core.ErrorEvent); ok {
+			got, ok := event.(core.ErrorEvent)
+			if !ok || got.Error.Error() != e.Error.Error() || !got.Retryable {
+				t.Errorf("unexpected replayed error event: %+v", event)
+			}
+		} else if !reflect.DeepEqual(event, want) {
+			t.Errorf("replayed event %d differs:\n got: %+v\nwant: %+v", i, event, want)
+		}
+		i++
+	}
+	if i != len(events) {
+		t.Errorf("expected %d replayed events, got %d", len(events), i)
+	}
+}
+
+// TestReplayerScalesTiming tests that the replayer waits the recorded delays
+// multiplied by the scale
+func TestReplayerScalesTiming(t *testing.T) {
+	recording := `{"offset":0,"type":"llm","event":{"Delta":"a"}}
+{"offset":200000000,"type":"llm","event":{"Delta":"b"}}
+{"offset":200000000,"type":"custom","event":{}}
+`
+
+	output := make(chan core.Event, 3)
+	start := time.Now()
+	if err := NewReplayer(strings.NewReader(recording), 0.5).Process(context.Background(), nil, output); err != nil {
+		t.Fatalf("replayer failed: %v", err)
+	}
+	close(output)
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected ~100ms replay with half-speed scaling, took %v", elapsed)
+	}
+	if len(output) != 2 {
+		t.Errorf("expected unknown event types to be skipped, got %d events", len(output))
+	}
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// Route maps events accepted by Match to a named downstream branch
+type Route struct {
+	// Branch is the name of the downstream node that receives matching events
+	Branch string
+
+	// Match decides whether an event belongs to this branch
+	Match EdgePredicate
+}
+
+// RouterStage routes each event to the first branch whose predicate matches,
+// like a switch statement over event content. Events that match no route go
+// to the default branch, or are dropped if there is none. DoneEvents and
+// ErrorEvents are delivered to every branch so each one can complete.
+//
+// The stage itself passes events through; routing happens on its outgoing
+// edges, which GraphBuilder.AddRouter wires up with the stage's predicates.
+type RouterStage struct {
+	name          string
+	routes        []Route
+	defaultBranch string
+}
+
+// NewRouterStage creates a router with ordered routes and an optional default branch
+func NewRouterStage(name string, routes []Route, defaultBranch string) *RouterStage {
+	return &RouterStage{
+		name:          name,
+		routes:        routes,
+		defaultBranch: defaultBranch,
+	}
+}
+
+// Name returns the stage name
+func (rs *RouterStage) Name() string {
+	return rs.name
+}
+
+// Process implements the Stage interface
+// It forwards all events; branch selection is applied by the outgoing edges
+func (rs *RouterStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
+
+// Select returns the branch an event is routed to, or "" if it is dropped
+func (rs *RouterStage) Select(event core.Event) string {
+	for _, route := range rs.routes {
+		if route.Match != nil && route.Match(event) {
+			return route.Branch
+		}
+	}
+	return rs.defaultBranch
+}
+
+// Branch returns the edge predicate for a branch
+func (rs *RouterStage) Branch(branch string) EdgePredicate {
+	return func(event core.Event) bool {
+		switch event.(type) {
+		case core.DoneEvent, core.ErrorEvent:
+			return true
+		}
+		return rs.Select(event) == branch
+	}
+}
+
+// Branches returns the distinct branch names in route order, default last
+func (rs *RouterStage) Branches() []string {
+	seen := make(map[string]bool)
+	var branches []string
+	for _, route := range rs.routes {
+		if !seen[route.Branch] {
+			seen[route.Branch] = true
+			branches = append(branches, route.Branch)
+		}
+	}
+	if rs.defaultBranch != "" && !seen[rs.defaultBranch] {
+		branches = append(branches, rs.defaultBranch)
+	}
+	return branches
+}
+
+// InputTypes returns the input event types this stage accepts
+func (rs *RouterStage) InputTypes() []core.EventType {
+	// Router accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (rs *RouterStage) OutputTypes() []core.EventType {
+	// Router passes through all event types it receives
+	return []core.EventType{
+		core.EventTypeStatus,
+		core.EventTypeSTT,
+		core.EventTypeLLM,
+		core.EventTypeAudio,
+		core.EventTypeAction,
+		core.EventTypeError,
+		core.EventTypeDone,
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestRouterStageRoutesByContent tests switch-style routing on event payloads
+func TestRouterStageRoutesByContent(t *testing.T) {
+	actions := &CollectingMockStage{name: "actions"}
+	tts := &CollectingMockStage{name: "tts"}
+
+	isAction := func(event core.Event) bool {
+		llm, ok := event.(core.LLMEvent)
+		return ok && strings.HasPrefix(llm.Delta, "[action]")
+	}
+
+	pipeline, err := NewBuilder().
+		AddRouter("router", []Route{{Branch: "actions", Match: isAction}}, "tts").
+		AddStage("actions", actions).
+		AddStage("tts", tts).
+		SetEntryNode("router").
+		AddExitNode("actions").
+		AddExitNode("tts").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "Hello"}
+	input <- core.LLMEvent{Delta: "[action] navigate"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	for range pipeline.Execute(ctx, input) {
+	}
+
+	if len(actions.events) != 2 || actions.events[0].(core.LLMEvent).Delta != "[action] navigate" {
+		t.Errorf("unexpected events at actions branch: %v", actions.events)
+	}
+	if len(tts.events) != 2 || tts.events[0].(core.LLMEvent).Delta != "Hello" {
+		t.Errorf("unexpected events at tts branch: %v", tts.events)
+	}
+	for _, branch := range []*CollectingMockStage{actions, tts} {
+		if _, ok := branch.events[len(branch.events)-1].(core.DoneEvent); !ok {
+			t.Errorf("branch %s did not receive DoneEvent", branch.name)
+		}
+	}
+}
+
+// TestRouterStageSelect tests route ordering and the default branch
+func TestRouterStageSelect(t *testing.T) {
+	router := NewRouterStage("router", []Route{
+		{Branch: "final", Match: func(e core.Event) bool { stt, ok := e.(core.STTEvent); return ok && stt.IsFinal }},
+		{Branch: "stt", Match: func(e core.Event) bool { _, ok := e.(core.STTEvent); return ok }},
+	}, "")
+
+	if got := router.Select(core.STTEvent{IsFinal: true}); got != "final" {
+		t.Errorf("expected first matching route, got %q", got)
+	}
+	if got := router.Select(core.STTEvent{}); got != "stt" {
+		t.Errorf("expected stt route, got %q", got)
+	}
+	if got := router.Select(core.LLMEvent{}); got != "" {
+		t.Errorf("expected unmatched event to be dropped, got %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrResponseCancelled is the cause of a response context cancelled through
+// a CancelRegistry
+var ErrResponseCancelled = errors.New("response cancelled")
+
+// cancelCause records why a response was cancelled
+type cancelCause struct {
+	reason string
+}
+
+func (c *cancelCause) Error() string {
+	if c.reason == "" {
+		return ErrResponseCancelled.Error()
+	}
+	return ErrResponseCancelled.Error() + ": " + c.reason
+}
+
+func (c *cancelCause) Unwrap() error {
+	return ErrResponseCancelled
+}
+
+// CancelReason reports whether ctx was cancelled through a CancelRegistry and
+// the reason given
+func CancelReason(ctx context.Context) (string, bool) {
+	var cause *cancelCause
+	if errors.As(context.Cause(ctx), &cause) {
+		return cause.reason, true
+	}
+	return "", false
+}
+
+// CancelRegistry tracks in-flight responses by ResponseID, so a client can
+// abort one response without tearing down the session or other responses
+type CancelRegistry struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+// NewCancelRegistry creates an empty registry
+func NewCancelRegistry() *CancelRegistry {
+	return &CancelRegistry{
+		cancels: make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// Register derives the context a response runs under. Call release when the
+// response finishes to remove it from the registry.
+func (r *CancelRegistry) Register(ctx context.Context, responseID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	r.mu.Lock()
+	r.cancels[responseID] = cancel
+	r.mu.Unlock()
+
+	release := func() {
+		r.mu.Lock()
+		delete(r.cancels, responseID)
+		r.mu.Unlock()
+		cancel(context.Canceled)
+	}
+	return ctx, release
+}
+
+// Cancel aborts a response. It returns false if the response isn't in flight.
+func (r *CancelRegistry) Cancel(responseID, reason string) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[responseID]
+	delete(r.cancels, responseID)
+	r.mu.Unlock()
+
+	if ok {
+		cancel(&cancelCause{reason: reason})
+	}
+	return ok
+}
+
+// Active returns the IDs of the responses in flight
+func (r *CancelRegistry) Active() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(r.cancels))
+	for id := range r.cancels {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"sync"
+)
+
+// Manager keeps track of active sessions by ID
+type Manager struct {
+	config   Config
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewManager creates a session manager; every session it creates uses config
+func NewManager(config Config) *Manager {
+	return &Manager{
+		config:   config,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// GetOrCreate returns the session with the given ID, creating it if needed
+func (m *Manager) GetOrCreate(id string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, exists := m.sessions[id]; exists {
+		return session
+	}
+	session := New(id, m.config)
+	m.sessions[id] = session
+	return session
+}
+
+// Get returns the session with the given ID
+func (m *Manager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[id]
+	return session, exists
+}
+
+// Close closes and removes the session with the given ID
+func (m *Manager) Close(id string) {
+	m.mu.Lock()
+	session, exists := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if exists {
+		session.Close()
+	}
+}
+
+// CloseAll closes and removes all sessions
+func (m *Manager) CloseAll() {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
+// Len returns the number of active sessions
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
@@ -0,0 +1,309 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// Turn identifies a single request/response exchange within a session
+type Turn struct {
+	SessionID  string
+	TurnID     string
+	ResponseID string
+	Number     int
+}
+
+// PipelineFactory builds the pipeline for a turn. Events carry the turn's
+// identifiers in their metadata (core.TurnContext); stages that need them
+// up front can take them from the Turn.
+type PipelineFactory func(ctx context.Context, turn Turn) (*pipeline.Pipeline, error)
+
+// Config holds session configuration
+type Config struct {
+	// Factory builds pipelines for turns
+	Factory PipelineFactory
+
+	// ReusePipeline builds the pipeline once, for the first turn, and reuses
+	// it for later turns. Only enable this if all stages are safe to rerun.
+	ReusePipeline bool
+
+	// NewID generates response IDs (default: core.NewCorrelationID)
+	NewID func() string
+
+	// UserID identifies the session's user on every event's turn metadata
+	UserID string
+
+	Logger telemetry.Logger
+}
+
+// Session owns the pipeline of a single client session and runs one turn at
+// a time. Starting a new turn cancels the turn in progress.
+type Session struct {
+	id        string
+	config    Config
+	responses *CancelRegistry
+
+	mu       sync.Mutex
+	pipeline *pipeline.Pipeline
+	turns    int
+	current  *activeTurn
+	closed   bool
+}
+
+// activeTurn tracks a running turn
+type activeTurn struct {
+	turn   Turn
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// turnKey is the context key for the current turn
+type turnKey struct{}
+
+// TurnFromContext returns the turn a pipeline run belongs to, if any
+func TurnFromContext(ctx context.Context) (Turn, bool) {
+	turn, ok := ctx.Value(turnKey{}).(Turn)
+	return turn, ok
+}
+
+// New creates a session
+func New(id string, config Config) *Session {
+	if config.NewID == nil {
+		config.NewID = core.NewCorrelationID
+	}
+	return &Session{
+		id:        id,
+		config:    config,
+		responses: NewCancelRegistry(),
+	}
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// StartTurn cancels the turn in progress, if any, and runs a new turn with
+// the given input. With ReusePipeline the new turn starts once the cancelled
+// one has stopped. The returned channel closes when the turn finishes or is
+// cancelled. All events of the turn share its TurnID as correlation ID.
+func (s *Session) StartTurn(ctx context.Context, input <-chan core.Event) (Turn, core.PipelineOutput, error) {
+	logger := s.config.Logger.WithModule("session")
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return Turn{}, nil, fmt.Errorf("session %s is closed", s.id)
+	}
+
+	previous := s.current
+	if previous != nil {
+		logger.Info("Cancelling previous turn", telemetry.String("session_id", s.id), telemetry.String("turn_id", previous.turn.TurnID))
+		previous.cancel()
+		s.current = nil
+	}
+
+	s.turns++
+	turn := Turn{
+		SessionID:  s.id,
+		TurnID:     fmt.Sprintf("%s-%d", s.id, s.turns),
+		ResponseID: s.config.NewID(),
+		Number:     s.turns,
+	}
+
+	turnCtx, cancel := s.responses.Register(ctx, turn.ResponseID)
+	turnCtx = context.WithValue(turnCtx, turnKey{}, turn)
+	turnCtx = core.WithCorrelationID(turnCtx, turn.TurnID)
+	turnCtx = core.WithTurn(turnCtx, s.turnContext(turn))
+
+	p := s.pipeline
+	reused := p != nil && s.config.ReusePipeline
+	if !reused {
+		var err error
+		p, err = s.config.Factory(turnCtx, turn)
+		if err != nil {
+			s.mu.Unlock()
+			cancel()
+			return Turn{}, nil, fmt.Errorf("failed to build pipeline for turn %s: %w", turn.TurnID, err)
+		}
+		s.pipeline = p
+	}
+
+	active := &activeTurn{
+		turn:   turn,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	s.current = active
+	s.mu.Unlock()
+
+	// A reused pipeline runs one turn at a time, so the new run starts once
+	// the previous one has cleaned up. A turn cancelled meanwhile doesn't run,
+	// but still ends after the previous one.
+	run := true
+	if reused && previous != nil {
+		select {
+		case <-previous.done:
+		case <-turnCtx.Done():
+			run = false
+		}
+	}
+
+	var events core.PipelineOutput
+	if run {
+		logger.Info("Starting turn", telemetry.String("session_id", s.id), telemetry.String("turn_id", turn.TurnID), telemetry.String("response_id", turn.ResponseID))
+		events = p.Execute(turnCtx, input)
+	} else {
+		events = closeAfter(previous.done)
+	}
+
+	output := make(chan core.Event, 100)
+	go func() {
+		defer close(active.done)
+		defer close(output)
+		defer s.finishTurn(active)
+
+	forward:
+		for event := range events {
+			select {
+			case <-turnCtx.Done():
+				// Drain so the pipeline can shut down
+				for range events {
+				}
+				break forward
+			case output <- event:
+			}
+		}
+
+		// A response cancelled by the client ends with response.cancelled
+		if reason, ok := CancelReason(turnCtx); ok {
+			select {
+			case <-ctx.Done():
+			case output <- core.ResponseCancelledEvent{
+				ResponseID: turn.ResponseID,
+				Reason:     reason,
+				Meta: core.EventMeta{
+					CorrelationID: turn.TurnID,
+					Timestamp:     time.Now(),
+					Turn:          s.turnContext(turn),
+				},
+			}:
+			}
+		}
+	}()
+
+	return turn, output, nil
+}
+
+// closeAfter returns an output without events that closes once done does
+func closeAfter(done <-chan struct{}) core.PipelineOutput {
+	output := make(chan core.Event)
+	go func() {
+		defer close(output)
+		<-done
+	}()
+	return output
+}
+
+// turnContext returns the identifiers of a turn that events carry
+func (s *Session) turnContext(turn Turn) core.TurnContext {
+	return core.TurnContext{
+		TurnID:     turn.TurnID,
+		ResponseID: turn.ResponseID,
+		UserID:     s.config.UserID,
+	}
+}
+
+// finishTurn clears the current turn if it is still the given one
+func (s *Session) finishTurn(active *activeTurn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active.cancel()
+	if s.current == active {
+		s.current = nil
+	}
+}
+
+// CurrentTurn returns the turn in progress, if any
+func (s *Session) CurrentTurn() (Turn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		return Turn{}, false
+	}
+	return s.current.turn, true
+}
+
+// Interrupt signals barge-in to the turn in progress without cancelling it,
+// letting stages finish the turn gracefully
+func (s *Session) Interrupt(reason string) {
+	s.mu.Lock()
+	p := s.pipeline
+	running := s.current != nil
+	s.mu.Unlock()
+
+	if running && p != nil {
+		p.Interrupt(reason)
+	}
+}
+
+// CancelTurn cancels the turn in progress and waits for it to stop
+func (s *Session) CancelTurn() {
+	s.mu.Lock()
+	active := s.current
+	s.mu.Unlock()
+
+	if active == nil {
+		return
+	}
+	active.cancel()
+	<-active.done
+}
+
+// CancelResponse aborts the response with the given ID, or the current one if
+// responseID is empty, and ends its output with a ResponseCancelledEvent. The
+// session stays open for further turns. It returns false if the response
+// isn't in flight.
+func (s *Session) CancelResponse(responseID, reason string) bool {
+	if responseID == "" {
+		turn, ok := s.CurrentTurn()
+		if !ok {
+			return false
+		}
+		responseID = turn.ResponseID
+	}
+	return s.responses.Cancel(responseID, reason)
+}
+
+// HandleCancel is a protocol.InputHandler for control.cancel messages, to be
+// registered in place of the barge-in handler of InputRouter.FeedPipeline
+// when cancels should abort the response instead of interrupting it
+func (s *Session) HandleCancel(ctx context.Context, msg *protocol.InputMessage) error {
+	payload, _ := msg.Payload.(protocol.CancelPayload)
+	reason := payload.Reason
+	if reason == "" {
+		reason = "client_cancel"
+	}
+	if !s.CancelResponse(payload.ResponseID, reason) {
+		s.config.Logger.WithModule("session").Debug("No response to cancel", telemetry.String("session_id", s.id), telemetry.String("response_id", payload.ResponseID))
+	}
+	return nil
+}
+
+// Close cancels the turn in progress and rejects further turns
+func (s *Session) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.CancelTurn()
+}
@@ -0,0 +1,305 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// echoStage forwards its input until the input closes or ctx is cancelled
+type echoStage struct{}
+
+func (echoStage) Name() string { return "echo" }
+
+func (echoStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+			output <- event
+		}
+	}
+}
+
+func (echoStage) InputTypes() []core.EventType  { return []core.EventType{} }
+func (echoStage) OutputTypes() []core.EventType { return []core.EventType{} }
+
+// newEchoFactory returns a factory that counts how often it builds a pipeline
+func newEchoFactory(builds *int) PipelineFactory {
+	return func(ctx context.Context, turn Turn) (*pipeline.Pipeline, error) {
+		*builds++
+		return pipeline.NewBuilder().
+			AddStage("echo", echoStage{}).
+			SetEntryNode("echo").
+			AddExitNode("echo").
+			Build()
+	}
+}
+
+func TestSessionTurnLifecycle(t *testing.T) {
+	var builds int
+	session := New("s1", Config{Factory: newEchoFactory(&builds)})
+
+	input := make(chan core.Event, 1)
+	input <- core.STTEvent{Text: "hello"}
+	close(input)
+
+	turn, output, err := session.StartTurn(context.Background(), input)
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+	if turn.TurnID != "s1-1" || turn.Number != 1 || turn.ResponseID == "" {
+		t.Errorf("unexpected turn %+v", turn)
+	}
+
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+	if len(events) != 1 || core.MetaOf(events[0]).CorrelationID != turn.TurnID {
+		t.Errorf("expected one event correlated with the turn, got %v", events)
+	}
+	if meta := core.MetaOf(events[0]); meta.Turn.TurnID != turn.TurnID || meta.Turn.ResponseID != turn.ResponseID {
+		t.Errorf("expected the event to carry the turn, got %+v", meta.Turn)
+	}
+
+	if _, running := session.CurrentTurn(); running {
+		t.Error("expected no turn in progress after completion")
+	}
+}
+
+func TestSessionNewTurnCancelsPrevious(t *testing.T) {
+	var builds int
+	session := New("s1", Config{Factory: newEchoFactory(&builds)})
+
+	// First turn's input never closes, so it runs until cancelled
+	first, firstOutput, err := session.StartTurn(context.Background(), make(chan core.Event))
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+
+	second, _, err := session.StartTurn(context.Background(), make(chan core.Event))
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+	if second.TurnID == first.TurnID {
+		t.Error("expected a new turn ID")
+	}
+
+	select {
+	case <-drain(firstOutput):
+	case <-time.After(2 * time.Second):
+		t.Fatal("previous turn was not cancelled")
+	}
+
+	if current, _ := session.CurrentTurn(); current.TurnID != second.TurnID {
+		t.Errorf("expected current turn %s, got %s", second.TurnID, current.TurnID)
+	}
+	if builds != 2 {
+		t.Errorf("expected pipeline to be rebuilt per turn, got %d builds", builds)
+	}
+
+	session.Close()
+	if _, _, err := session.StartTurn(context.Background(), make(chan core.Event)); err == nil {
+		t.Error("expected error starting a turn on a closed session")
+	}
+}
+
+func TestSessionReusePipeline(t *testing.T) {
+	var builds int
+	session := New("s1", Config{Factory: newEchoFactory(&builds), ReusePipeline: true})
+
+	for i := 0; i < 3; i++ {
+		input := make(chan core.Event)
+		close(input)
+		_, output, err := session.StartTurn(context.Background(), input)
+		if err != nil {
+			t.Fatalf("StartTurn failed: %v", err)
+		}
+		<-drain(output)
+	}
+
+	if builds != 1 {
+		t.Errorf("expected pipeline to be built once, got %d builds", builds)
+	}
+}
+
+// streamStage streams text until interrupted, then ends the turn. Cancelled,
+// it takes a while to stop, like a stage closing a provider connection.
+type streamStage struct{}
+
+func (streamStage) Name() string { return "stream" }
+
+func (streamStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			time.Sleep(50 * time.Millisecond)
+			return ctx.Err()
+		case event := <-input:
+			if _, ok := event.(core.InterruptEvent); ok {
+				output <- core.DoneEvent{Interrupted: true}
+				return nil
+			}
+		case <-ticker.C:
+			select {
+			case <-ctx.Done():
+			case output <- core.LLMEvent{Delta: "."}:
+			}
+		}
+	}
+}
+
+func (streamStage) InputTypes() []core.EventType  { return []core.EventType{} }
+func (streamStage) OutputTypes() []core.EventType { return []core.EventType{} }
+
+// TestSessionReusePipelineInterrupt tests that a turn started while the
+// previous one is still streaming owns the reused pipeline once it runs
+func TestSessionReusePipelineInterrupt(t *testing.T) {
+	session := New("s1", Config{
+		ReusePipeline: true,
+		Factory: func(ctx context.Context, turn Turn) (*pipeline.Pipeline, error) {
+			return pipeline.NewBuilder().
+				AddStage("stream", streamStage{}).
+				SetEntryNode("stream").
+				AddExitNode("stream").
+				Build()
+		},
+	})
+
+	_, first, err := session.StartTurn(context.Background(), make(chan core.Event))
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+	<-first
+	drain(first)
+
+	_, second, err := session.StartTurn(context.Background(), make(chan core.Event))
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+
+	// Stream past the first turn's cleanup before interrupting
+	streaming := time.After(100 * time.Millisecond)
+	for waiting := true; waiting; {
+		select {
+		case _, ok := <-second:
+			if !ok {
+				t.Fatal("expected the second turn to stream")
+			}
+		case <-streaming:
+			waiting = false
+		}
+	}
+
+	session.Interrupt("barge-in")
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-second:
+			if !ok {
+				t.Fatal("second turn ended without being interrupted")
+			}
+			if done, ok := event.(core.DoneEvent); ok && done.Interrupted {
+				return
+			}
+		case <-timeout:
+			t.Fatal("interrupt did not reach the second turn")
+		}
+	}
+}
+
+func TestSessionCancelResponse(t *testing.T) {
+	var builds int
+	session := New("s1", Config{Factory: newEchoFactory(&builds)})
+
+	turn, output, err := session.StartTurn(context.Background(), make(chan core.Event))
+	if err != nil {
+		t.Fatalf("StartTurn failed: %v", err)
+	}
+
+	if session.CancelResponse("other", "user") {
+		t.Error("expected cancelling an unknown response to fail")
+	}
+
+	msg := &protocol.InputMessage{
+		Type:    protocol.InputCancel,
+		Payload: protocol.CancelPayload{ResponseID: turn.ResponseID, Reason: "user"},
+	}
+	if err := session.HandleCancel(context.Background(), msg); err != nil {
+		t.Fatalf("HandleCancel failed: %v", err)
+	}
+
+	var last core.Event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range output {
+			last = event
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelled response did not finish")
+	}
+
+	cancelled, ok := last.(core.ResponseCancelledEvent)
+	if !ok || cancelled.ResponseID != turn.ResponseID || cancelled.Reason != "user" {
+		t.Errorf("expected response to end with a cancellation, got %#v", last)
+	}
+
+	// The session outlives the cancelled response
+	input := make(chan core.Event)
+	close(input)
+	_, output, err = session.StartTurn(context.Background(), input)
+	if err != nil {
+		t.Fatalf("StartTurn after cancel failed: %v", err)
+	}
+	<-drain(output)
+}
+
+func TestManagerSessions(t *testing.T) {
+	var builds int
+	manager := NewManager(Config{Factory: newEchoFactory(&builds)})
+
+	a := manager.GetOrCreate("a")
+	if manager.GetOrCreate("a") != a {
+		t.Error("expected the same session for the same ID")
+	}
+	manager.GetOrCreate("b")
+	if manager.Len() != 2 {
+		t.Errorf("expected 2 sessions, got %d", manager.Len())
+	}
+
+	manager.Close("a")
+	if _, exists := manager.Get("a"); exists {
+		t.Error("expected session to be removed")
+	}
+
+	manager.CloseAll()
+	if manager.Len() != 0 {
+		t.Errorf("expected no sessions, got %d", manager.Len())
+	}
+}
+
+// drain consumes a channel and signals when it closes
+func drain(output core.PipelineOutput) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range output {
+		}
+	}()
+	return done
+}
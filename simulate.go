@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"sort"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// SimulationResult reports where event types travel through a pipeline
+type SimulationResult struct {
+	// Reached maps each event type to the nodes it reaches, sorted by name.
+	// Fan-out branches are named "<fan-out>/<branch stage>".
+	Reached map[core.EventType][]string
+
+	// Output lists the event types the pipeline would emit from its exit
+	// nodes, sorted
+	Output []core.EventType
+}
+
+// Simulate runs events through the pipeline's topology without running any
+// stage, to verify edge filters and routing before connecting real
+// providers. Each stage is replaced by a stub that turns every event it
+// receives into one event of each of its declared output types, or passes
+// the event on if it declares none. Edge predicates are evaluated for the
+// original events as they are passed on; events a stub produces have no
+// payload, so predicates are assumed to forward them.
+func (p *Pipeline) Simulate(events []core.Event) SimulationResult {
+	sim := &simulation{
+		graph:   p.graph,
+		reached: make(map[core.EventType]map[string]bool),
+		output:  make(map[core.EventType]bool),
+		visited: make(map[simulationVisit]bool),
+	}
+	for _, event := range events {
+		sim.deliver(p.graph.GetEntryNode(), event.EventType(), event)
+	}
+
+	result := SimulationResult{Reached: make(map[core.EventType][]string, len(sim.reached))}
+	for eventType, nodes := range sim.reached {
+		names := make([]string, 0, len(nodes))
+		for name := range nodes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result.Reached[eventType] = names
+	}
+	for eventType := range sim.output {
+		result.Output = append(result.Output, eventType)
+	}
+	sort.Slice(result.Output, func(i, j int) bool { return result.Output[i] < result.Output[j] })
+	return result
+}
+
+// simulationVisit identifies a delivery already simulated. Only stub events
+// are deduplicated; original events may take different routes.
+type simulationVisit struct {
+	node      string
+	eventType core.EventType
+}
+
+// simulation holds the state of a Simulate run
+type simulation struct {
+	graph   *PipelineGraph
+	reached map[core.EventType]map[string]bool
+	output  map[core.EventType]bool
+	visited map[simulationVisit]bool
+}
+
+// reach records that eventType reached the named node
+func (s *simulation) reach(eventType core.EventType, name string) {
+	if s.reached[eventType] == nil {
+		s.reached[eventType] = make(map[string]bool)
+	}
+	s.reached[eventType][name] = true
+}
+
+// deliver simulates a node receiving an event of eventType. event is the
+// original event when it is passed on unchanged, nil for stub events.
+func (s *simulation) deliver(node *graphNode, eventType core.EventType, event core.Event) {
+	if node == nil {
+		return
+	}
+	if event == nil {
+		visit := simulationVisit{node.Name(), eventType}
+		if s.visited[visit] {
+			return
+		}
+		s.visited[visit] = true
+	}
+	s.reach(eventType, node.Name())
+
+	// Work out what the node emits for this event
+	type emitted struct {
+		eventType core.EventType
+		event     core.Event
+	}
+	var outputs []emitted
+	switch {
+	case node.FanOut() != nil:
+		for _, branch := range node.FanOut().Branches {
+			if !branchAccepts(branch, eventType) {
+				continue
+			}
+			s.reach(eventType, node.Name()+"/"+branch.Stage.Name())
+			if len(branch.Stage.OutputTypes()) == 0 {
+				outputs = append(outputs, emitted{eventType, event})
+				continue
+			}
+			for _, outType := range branch.Stage.OutputTypes() {
+				outputs = append(outputs, emitted{outType, nil})
+			}
+		}
+	case node.Stage() == nil:
+		// Barriers pass events on
+		outputs = append(outputs, emitted{eventType, event})
+	case len(node.Stage().OutputTypes()) == 0:
+		outputs = append(outputs, emitted{eventType, event})
+	default:
+		for _, outType := range node.Stage().OutputTypes() {
+			outputs = append(outputs, emitted{outType, nil})
+		}
+	}
+
+	isExit := isExitNode(s.graph, node)
+	for _, out := range outputs {
+		if isExit {
+			s.output[out.eventType] = true
+		}
+		for _, edge := range node.Outputs() {
+			if !edge.ShouldForwardEvent(out.eventType) {
+				continue
+			}
+			if out.event != nil && edge.Predicate() != nil && !edge.Predicate()(out.event) {
+				continue
+			}
+			s.deliver(edge.To(), out.eventType, out.event)
+		}
+	}
+}
+
+// branchAccepts reports whether a fan-out branch's filter passes eventType
+func branchAccepts(branch core.BranchConfig, eventType core.EventType) bool {
+	if len(branch.EventFilter) == 0 {
+		return true
+	}
+	for _, t := range branch.EventFilter {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestPipelineSimulate(t *testing.T) {
+	isFinal := func(event core.Event) bool {
+		stt, ok := event.(core.STTEvent)
+		return ok && stt.IsFinal
+	}
+
+	p, err := NewBuilder().
+		AddStage("source", &MockStage{name: "source"}).
+		AddStage("finals", &MockStage{name: "finals"}).
+		AddStage("llm", &MockStage{name: "llm", outputTypes: []core.EventType{core.EventTypeLLM, core.EventTypeDone}}).
+		AddFanOut("fanout", core.FanOutConfig{Branches: []core.BranchConfig{
+			{Stage: &MockStage{name: "tts", outputTypes: []core.EventType{core.EventTypeAudio, core.EventTypeDone}}, EventFilter: []core.EventType{core.EventTypeLLM}},
+			{Stage: &MockStage{name: "log"}},
+		}}).
+		ConnectIf("source", "finals", isFinal).
+		Connect("source", "llm", core.EventTypeSTT).
+		Connect("llm", "fanout").
+		SetEntryNode("source").
+		AddExitNode("finals").
+		AddExitNode("fanout").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	result := p.Simulate([]core.Event{core.STTEvent{Text: "hel"}})
+	if got := result.Reached[core.EventTypeSTT]; !reflect.DeepEqual(got, []string{"llm", "source"}) {
+		t.Errorf("interim STT reached %v", got)
+	}
+	if got := result.Reached[core.EventTypeLLM]; !reflect.DeepEqual(got, []string{"fanout", "fanout/log", "fanout/tts"}) {
+		t.Errorf("LLM reached %v", got)
+	}
+	if got := result.Reached[core.EventTypeDone]; !reflect.DeepEqual(got, []string{"fanout", "fanout/log"}) {
+		t.Errorf("done reached %v", got)
+	}
+	want := []core.EventType{core.EventTypeAudio, core.EventTypeDone, core.EventTypeLLM}
+	if !reflect.DeepEqual(result.Output, want) {
+		t.Errorf("expected output %v, got %v", want, result.Output)
+	}
+
+	result = p.Simulate([]core.Event{core.STTEvent{Text: "hello", IsFinal: true}})
+	if got := result.Reached[core.EventTypeSTT]; !reflect.DeepEqual(got, []string{"finals", "llm", "source"}) {
+		t.Errorf("final STT reached %v", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/creastat/pipeline/core"
 )
@@ -13,6 +14,19 @@ import (
 type ActionStageConfig struct {
 	// Actions can be pre-defined or parsed from LLM output
 	Actions []ActionRequestPayload
+
+	// Results receives the client's action.complete messages. With it, the
+	// stage waits after each Required action for its completion and emits
+	// an ActionResultEvent before going on with the next action.
+	Results *ActionResults
+
+	// Timeout is how long to wait for a Required action that sets no
+	// timeout of its own. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// Policy restricts the actions sent to the client. Rejected actions are
+	// reported as ErrorEvents instead. Nil permits every action.
+	Policy *ActionPolicy
 }
 
 // ActionRequestPayload represents an action to be executed by the client
@@ -22,7 +36,7 @@ type ActionRequestPayload struct {
 	Target     string          `json:"target,omitempty"`
 	Data       map[string]any  `json:"data,omitempty"`
 	Required   bool            `json:"required"`
-	Timeout    int             `json:"timeout,omitempty"`
+	Timeout    int             `json:"timeout,omitempty"` // Milliseconds
 }
 
 // ActionStage represents an action execution stage
@@ -32,6 +46,9 @@ type ActionStage struct {
 
 // NewActionStage creates a new action stage
 func NewActionStage(config ActionStageConfig) *ActionStage {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultActionTimeout
+	}
 	return &ActionStage{
 		config: config,
 	}
@@ -44,16 +61,18 @@ func (s *ActionStage) Name() string {
 
 // InputTypes returns the event types this stage accepts
 func (s *ActionStage) InputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeLLM}
+	return []core.EventType{core.EventTypeLLM, core.EventTypeActionBlock}
 }
 
 // OutputTypes returns the event types this stage produces
 func (s *ActionStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeAction, core.EventTypeStatus, core.EventTypeDone}
+	return []core.EventType{core.EventTypeAction, core.EventTypeActionResult, core.EventTypeStatus, core.EventTypeError, core.EventTypeDone}
 }
 
 // Process implements the Stage interface
-// It reads LLM output, parses action commands, and emits ActionEvents
+// It reads LLM output, parses action commands, and emits ActionEvents.
+// Action blocks split out by ActionSplitterStage are parsed as JSON; without
+// any, actions are searched for in the LLM text.
 func (s *ActionStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
 
 	// Emit executing status
@@ -63,22 +82,45 @@ func (s *ActionStage) Process(ctx context.Context, input <-chan core.Event, outp
 		Message: "Executing actions...",
 	}
 
-	// Collect all LLM output to parse for actions
+	// Collect all LLM output and action blocks to parse for actions
 	var fullText string
+	var blocks []string
 	for event := range input {
-		if llmEvent, ok := event.(core.LLMEvent); ok {
-			fullText += llmEvent.Delta
+		switch e := event.(type) {
+		case core.LLMEvent:
+			fullText += e.Delta
+		case core.ActionBlockEvent:
+			blocks = append(blocks, e.Content)
 		}
 	}
 
-	// Parse actions from LLM output
-	actions, err := s.parseActions(fullText)
-	if err != nil {
-		output <- core.ErrorEvent{
-			Error:     fmt.Errorf("failed to parse actions from LLM output: %w", err),
-			Retryable: false,
+	var actions []ActionRequestPayload
+	if len(blocks) > 0 {
+		for _, block := range blocks {
+			parsed, err := parseActionBlock(block)
+			if err != nil {
+				// Skip the malformed block, others may still be valid
+				output <- core.ErrorEvent{
+					Error:     fmt.Errorf("failed to parse action block: %w", err),
+					Retryable: false,
+					Code:      core.ErrCodeInvalidAction,
+				}
+				continue
+			}
+			actions = append(actions, parsed...)
+		}
+	} else {
+		// Parse actions from LLM output
+		parsed, err := s.parseActions(fullText)
+		if err != nil {
+			output <- core.ErrorEvent{
+				Error:     fmt.Errorf("failed to parse actions from LLM output: %w", err),
+				Retryable: false,
+				Code:      core.ErrCodeInvalidAction,
+			}
+			return err
 		}
-		return err
+		actions = parsed
 	}
 
 	// If no actions were parsed, use pre-configured actions
@@ -93,16 +135,47 @@ func (s *ActionStage) Process(ctx context.Context, input <-chan core.Event, outp
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			// Emit action event
-			output <- core.ActionEvent{
-				ActionID:   action.ActionID,
-				ActionType: action.ActionType,
-				Target:     action.Target,
-				Data:       action.Data,
-				Required:   action.Required,
+		}
+
+		if s.config.Policy != nil {
+			if err := s.config.Policy.Check(action); err != nil {
+				output <- core.ErrorEvent{
+					Error:     fmt.Errorf("rejected action %s: %w", action.ActionID, err),
+					Retryable: false,
+					Code:      core.ErrCodeInvalidAction,
+				}
+				continue
 			}
-			actionsCount++
 		}
+
+		// Wait for required actions before going on, listening before the
+		// client can answer
+		var results <-chan core.ActionResultEvent
+		release := func() {}
+		if action.Required && s.config.Results != nil {
+			results, release = s.config.Results.expect(action.ActionID)
+		}
+
+		// Emit action event
+		output <- core.ActionEvent{
+			ActionID:   action.ActionID,
+			ActionType: action.ActionType,
+			Target:     action.Target,
+			Data:       action.Data,
+			Required:   action.Required,
+			Timeout:    action.Timeout,
+		}
+		actionsCount++
+
+		if results == nil {
+			continue
+		}
+		result, err := s.awaitResult(ctx, action, results)
+		release()
+		if err != nil {
+			return err
+		}
+		output <- result
 	}
 
 	// Emit done event with action count
@@ -113,6 +186,35 @@ func (s *ActionStage) Process(ctx context.Context, input <-chan core.Event, outp
 	return nil
 }
 
+// parseActionBlock parses an action block: an {"actions": [...]} object, an
+// array of actions or a single action
+func parseActionBlock(block string) ([]ActionRequestPayload, error) {
+	block = strings.TrimSpace(block)
+	if strings.HasPrefix(block, "[") {
+		var actions []ActionRequestPayload
+		if err := json.Unmarshal([]byte(block), &actions); err != nil {
+			return nil, err
+		}
+		return actions, nil
+	}
+
+	var wrapped struct {
+		Actions *[]ActionRequestPayload `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(block), &wrapped); err != nil {
+		return nil, err
+	}
+	if wrapped.Actions != nil {
+		return *wrapped.Actions, nil
+	}
+
+	var action ActionRequestPayload
+	if err := json.Unmarshal([]byte(block), &action); err != nil {
+		return nil, err
+	}
+	return []ActionRequestPayload{action}, nil
+}
+
 // parseActions attempts to parse action commands from LLM output
 // It looks for JSON structures containing action definitions
 func (s *ActionStage) parseActions(text string) ([]ActionRequestPayload, error) {
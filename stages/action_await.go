@@ -0,0 +1,102 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// defaultActionTimeout is how long ActionStage waits for a required action
+// that sets no timeout of its own
+const defaultActionTimeout = 30 * time.Second
+
+// ActionResults hands the client's action.complete messages to the
+// ActionStage waiting for them. Share one per session between the input
+// router, see Handler, and the stage. It is safe for concurrent use.
+type ActionResults struct {
+	mu      sync.Mutex
+	waiters map[string]chan core.ActionResultEvent
+}
+
+// NewActionResults creates an empty registry
+func NewActionResults() *ActionResults {
+	return &ActionResults{
+		waiters: make(map[string]chan core.ActionResultEvent),
+	}
+}
+
+// Complete delivers the result of an action, reporting whether a stage was
+// waiting for it
+func (r *ActionResults) Complete(result core.ActionResultEvent) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	waiter, ok := r.waiters[result.ActionID]
+	if !ok {
+		return false
+	}
+	delete(r.waiters, result.ActionID)
+	waiter <- result
+	return true
+}
+
+// Handler returns an input handler delivering action.complete messages:
+//
+//	router.Handle(protocol.InputActionComplete, results.Handler())
+func (r *ActionResults) Handler() protocol.InputHandler {
+	return func(ctx context.Context, msg *protocol.InputMessage) error {
+		for _, event := range protocol.MessageToEvents(msg) {
+			if result, ok := event.(core.ActionResultEvent); ok {
+				r.Complete(result)
+			}
+		}
+		return nil
+	}
+}
+
+// expect registers interest in an action's result before the action is
+// sent, so a fast client can't complete it unseen. Call release when done.
+func (r *ActionResults) expect(actionID string) (<-chan core.ActionResultEvent, func()) {
+	waiter := make(chan core.ActionResultEvent, 1)
+
+	r.mu.Lock()
+	r.waiters[actionID] = waiter
+	r.mu.Unlock()
+
+	release := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.waiters[actionID] == waiter {
+			delete(r.waiters, actionID)
+		}
+	}
+	return waiter, release
+}
+
+// awaitResult waits for the result of a required action until its timeout
+func (s *ActionStage) awaitResult(ctx context.Context, action ActionRequestPayload, results <-chan core.ActionResultEvent) (core.ActionResultEvent, error) {
+	timeout := s.config.Timeout
+	if action.Timeout > 0 {
+		timeout = time.Duration(action.Timeout) * time.Millisecond
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return core.ActionResultEvent{}, ctx.Err()
+	case result := <-results:
+		return result, nil
+	case <-timer.C:
+		return core.ActionResultEvent{
+			ActionID: action.ActionID,
+			Error:    fmt.Sprintf("action not completed within %s", timeout),
+			TimedOut: true,
+		}, nil
+	}
+}
@@ -0,0 +1,214 @@
+package stages
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// ActionPolicy restricts the actions ActionStage sends to the client, so an
+// LLM steered by injected instructions can't make it navigate or click
+// anywhere. Build one per session to scope it to the user.
+type ActionPolicy struct {
+	// Allowed maps each permitted action type to its rule. Actions of other
+	// types are rejected.
+	Allowed map[core.ActionType]ActionRule
+}
+
+// ActionRule restricts the targets and data of one action type
+type ActionRule struct {
+	// Targets lists the permitted targets. A trailing "*" matches by prefix,
+	// "/docs/*" or "https://example.com/*". Empty permits any target.
+	Targets []string
+
+	// Schema validates the action's Data; nil accepts any data
+	Schema *DataSchema
+}
+
+// Check returns why an action is not permitted, or nil
+func (p *ActionPolicy) Check(action ActionRequestPayload) error {
+	rule, ok := p.Allowed[action.ActionType]
+	if !ok {
+		return fmt.Errorf("action type %q is not allowed", action.ActionType)
+	}
+	if len(rule.Targets) > 0 && !slices.ContainsFunc(rule.Targets, func(allowed string) bool {
+		return matchTarget(allowed, action.Target)
+	}) {
+		return fmt.Errorf("target %q is not allowed for %s actions", action.Target, action.ActionType)
+	}
+	if rule.Schema != nil {
+		data := any(action.Data)
+		if action.Data == nil {
+			data = map[string]any{}
+		}
+		if err := rule.Schema.Validate(data); err != nil {
+			return fmt.Errorf("invalid %s action data: %w", action.ActionType, err)
+		}
+	}
+	return nil
+}
+
+// matchTarget reports whether target matches an allowed target pattern
+func matchTarget(allowed, target string) bool {
+	if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+		return strings.HasPrefix(target, prefix)
+	}
+	return target == allowed
+}
+
+// DataSchema is the subset of JSON Schema used to validate action data. It
+// unmarshals from a JSON Schema document using these keywords.
+type DataSchema struct {
+	Type                 string                 `json:"type,omitempty"` // "object", "array", "string", "number", "integer", "boolean" or "null"
+	Properties           map[string]*DataSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"` // Defaults to true
+	Items                *DataSchema            `json:"items,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+}
+
+// Validate checks a value decoded from JSON, or built from Go maps, slices
+// and scalars, against the schema
+func (d *DataSchema) Validate(value any) error {
+	return d.validate("data", value)
+}
+
+// validate checks value at path
+func (d *DataSchema) validate(path string, value any) error {
+	if d.Type != "" && !hasSchemaType(value, d.Type) {
+		return fmt.Errorf("%s: expected %s, got %T", path, d.Type, value)
+	}
+	if len(d.Enum) > 0 && !slices.ContainsFunc(d.Enum, func(allowed any) bool { return sameJSONValue(allowed, value) }) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, d.Enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return d.validateString(path, v)
+	case map[string]any:
+		return d.validateObject(path, v)
+	case []any:
+		if d.Items != nil {
+			for i, item := range v {
+				if err := d.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		if number, ok := toFloat(value); ok {
+			if d.Minimum != nil && number < *d.Minimum {
+				return fmt.Errorf("%s: %v is less than %v", path, number, *d.Minimum)
+			}
+			if d.Maximum != nil && number > *d.Maximum {
+				return fmt.Errorf("%s: %v is greater than %v", path, number, *d.Maximum)
+			}
+		}
+	}
+	return nil
+}
+
+// validateString checks a string's length and pattern
+func (d *DataSchema) validateString(path, value string) error {
+	length := len([]rune(value))
+	if d.MinLength != nil && length < *d.MinLength {
+		return fmt.Errorf("%s: shorter than %d characters", path, *d.MinLength)
+	}
+	if d.MaxLength != nil && length > *d.MaxLength {
+		return fmt.Errorf("%s: longer than %d characters", path, *d.MaxLength)
+	}
+	if d.Pattern != "" {
+		pattern, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", path, d.Pattern, err)
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("%s: %q does not match %q", path, value, d.Pattern)
+		}
+	}
+	return nil
+}
+
+// validateObject checks an object's required and additional properties and
+// each property's value
+func (d *DataSchema) validateObject(path string, value map[string]any) error {
+	for _, name := range d.Required {
+		if _, ok := value[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+	for name, property := range value {
+		schema, ok := d.Properties[name]
+		if !ok {
+			if d.AdditionalProperties != nil && !*d.AdditionalProperties {
+				return fmt.Errorf("%s: property %q is not allowed", path, name)
+			}
+			continue
+		}
+		if err := schema.validate(path+"."+name, property); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasSchemaType reports whether value is of a JSON Schema type
+func hasSchemaType(value any, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		number, ok := toFloat(value)
+		return ok && number == float64(int64(number))
+	}
+	return false
+}
+
+// toFloat converts a numeric value to float64
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// sameJSONValue compares scalars, treating all numeric types alike
+func sameJSONValue(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return a == b
+}
@@ -0,0 +1,113 @@
+package stages
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestActionPolicy tests that the allowlist and data schemas reject actions
+// outside the permitted types, targets and data
+func TestActionPolicy(t *testing.T) {
+	var schema DataSchema
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["fields"],
+		"additionalProperties": false,
+		"properties": {
+			"fields": {
+				"type": "object",
+				"properties": {
+					"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+					"plan": {"enum": ["free", "pro"]},
+					"seats": {"type": "integer", "minimum": 1, "maximum": 50}
+				}
+			}
+		}
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	policy := &ActionPolicy{Allowed: map[core.ActionType]ActionRule{
+		core.ActionNavigate: {Targets: []string{"/docs/*", "/pricing"}},
+		core.ActionFillForm: {Targets: []string{"#signup"}, Schema: &schema},
+	}}
+
+	tests := []struct {
+		name   string
+		action ActionRequestPayload
+		err    string
+	}{
+		{"allowed target", ActionRequestPayload{ActionType: core.ActionNavigate, Target: "/pricing"}, ""},
+		{"allowed prefix", ActionRequestPayload{ActionType: core.ActionNavigate, Target: "/docs/setup"}, ""},
+		{"other site", ActionRequestPayload{ActionType: core.ActionNavigate, Target: "https://evil.example/docs/"}, "not allowed"},
+		{"other type", ActionRequestPayload{ActionType: core.ActionClick, Target: "#buy"}, `"click" is not allowed`},
+		{"valid data", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup", Data: map[string]any{
+			"fields": map[string]any{"email": "a@b.c", "plan": "pro", "seats": float64(3)},
+		}}, ""},
+		{"missing data", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup"}, `missing required property "fields"`},
+		{"extra property", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup", Data: map[string]any{
+			"fields": map[string]any{}, "redirect": "https://evil.example",
+		}}, `property "redirect" is not allowed`},
+		{"pattern", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup", Data: map[string]any{
+			"fields": map[string]any{"email": "nobody"},
+		}}, "data.fields.email"},
+		{"enum", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup", Data: map[string]any{
+			"fields": map[string]any{"plan": "enterprise"},
+		}}, "not one of"},
+		{"maximum", ActionRequestPayload{ActionType: core.ActionFillForm, Target: "#signup", Data: map[string]any{
+			"fields": map[string]any{"seats": 500},
+		}}, "greater than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.action)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("expected the action to be allowed, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestActionStagePolicy tests that rejected actions are reported as errors
+// and not sent to the client
+func TestActionStagePolicy(t *testing.T) {
+	stage := NewActionStage(ActionStageConfig{
+		Policy: &ActionPolicy{Allowed: map[core.ActionType]ActionRule{
+			core.ActionNavigate: {Targets: []string{"/pricing"}},
+		}},
+	})
+
+	results := runTurn(t, stage,
+		core.ActionBlockEvent{Content: `[
+			{"actionId": "a1", "actionType": "navigate", "target": "https://evil.example"},
+			{"actionId": "a2", "actionType": "navigate", "target": "/pricing"}
+		]`},
+		core.DoneEvent{},
+	)
+
+	var got []string
+	for _, event := range results {
+		switch e := event.(type) {
+		case core.ActionEvent:
+			got = append(got, "action "+e.ActionID)
+		case core.ErrorEvent:
+			got = append(got, "error "+string(e.Code))
+		case core.DoneEvent:
+			if e.ActionsCount != 1 {
+				t.Errorf("expected 1 action counted, got %d", e.ActionsCount)
+			}
+		}
+	}
+	if expected := []string{"error " + string(core.ErrCodeInvalidAction), "action a2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,225 @@
+package stages
+
+import (
+	"context"
+	"strings"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// DefaultActionFence is the info string of the fenced block LLMs are asked
+// to write actions in, "```actions"
+const DefaultActionFence = "actions"
+
+// codeFence opens and closes fenced blocks
+const codeFence = "```"
+
+// ActionSplitterConfig holds configuration for ActionSplitterStage
+type ActionSplitterConfig struct {
+	// Fence is the info string marking the action block, DefaultActionFence
+	// by default. Other fenced blocks are left in the prose.
+	Fence string
+
+	Logger telemetry.Logger
+}
+
+// ActionSplitterStage separates the action block an LLM writes in its answer
+// from the prose around it. Placed after the LLM stage, it streams the prose
+// on as LLMEvents, holding back only what may start a fence, and sends each
+// action block as an ActionBlockEvent once it closes. The DoneEvent's full
+// text is the prose alone.
+//
+// Route ActionBlockEvents to ActionStage and LLMEvents to the text and TTS
+// stages with edge filters, so actions are never spoken:
+//
+//	graph.AddEdge("splitter", "text", []core.EventType{core.EventTypeLLM, core.EventTypeDone})
+//	graph.AddEdge("splitter", "action", []core.EventType{core.EventTypeActionBlock, core.EventTypeDone})
+type ActionSplitterStage struct {
+	config ActionSplitterConfig
+}
+
+// NewActionSplitterStage creates a new action splitter stage
+func NewActionSplitterStage(config ActionSplitterConfig) *ActionSplitterStage {
+	if config.Fence == "" {
+		config.Fence = DefaultActionFence
+	}
+	return &ActionSplitterStage{config: config}
+}
+
+// Name returns the stage name
+func (s *ActionSplitterStage) Name() string {
+	return "action_splitter"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *ActionSplitterStage) InputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeLLM}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *ActionSplitterStage) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeLLM, core.EventTypeActionBlock, core.EventTypeDone}
+}
+
+// Process implements the Stage interface
+func (s *ActionSplitterStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+	splitter := &actionSplitter{open: codeFence + s.config.Fence}
+	var prose string
+	var meta core.EventMeta // Metadata of the latest LLM event
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	// emit sends the prose and the closed action blocks in stream order
+	emit := func(parts []splitPart) error {
+		for _, part := range parts {
+			if part.block {
+				logger.Debug("Split action block", telemetry.String("block", part.text))
+				if err := send(core.ActionBlockEvent{Content: part.text, Meta: meta}); err != nil {
+					return err
+				}
+				continue
+			}
+			prose += part.text
+			if err := send(core.LLMEvent{Delta: part.text, Content: prose, Meta: meta}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		var event core.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next, ok := <-input:
+			if !ok {
+				return emit(splitter.flush())
+			}
+			event = next
+		}
+
+		switch e := event.(type) {
+		case core.LLMEvent:
+			meta = e.Meta
+			if err := emit(splitter.write(e.Delta)); err != nil {
+				return err
+			}
+			continue
+
+		case core.DoneEvent:
+			if err := emit(splitter.flush()); err != nil {
+				return err
+			}
+			e.FullText = prose
+			prose = ""
+			event = e
+		}
+
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// splitPart is prose or an action block
+type splitPart struct {
+	text  string
+	block bool
+}
+
+// actionSplitter splits streamed text into prose and action blocks
+type actionSplitter struct {
+	open    string          // Marker opening an action block
+	held    string          // End of the text that may start a marker
+	inBlock bool            // Inside an action block
+	block   strings.Builder // Action block so far
+}
+
+// write consumes a chunk of the stream, returning the prose ready to pass on
+// and the action blocks it closes, in order
+func (sp *actionSplitter) write(text string) []splitPart {
+	text = sp.held + text
+	sp.held = ""
+
+	var parts []splitPart
+	addProse := func(prose string) {
+		if prose != "" {
+			parts = append(parts, splitPart{text: prose})
+		}
+	}
+	for text != "" {
+		marker := sp.open
+		if sp.inBlock {
+			marker = codeFence
+		}
+
+		i := strings.Index(text, marker)
+		if i < 0 {
+			keep := partialSuffix(text, marker)
+			sp.held = text[len(text)-keep:]
+			text = text[:len(text)-keep]
+			if sp.inBlock {
+				sp.block.WriteString(text)
+			} else {
+				addProse(text)
+			}
+			break
+		}
+
+		if sp.inBlock {
+			sp.block.WriteString(text[:i])
+			if block := strings.TrimSpace(sp.block.String()); block != "" {
+				parts = append(parts, splitPart{text: block, block: true})
+			}
+			sp.block.Reset()
+		} else {
+			addProse(text[:i])
+		}
+		sp.inBlock = !sp.inBlock
+		text = text[i+len(marker):]
+	}
+	return parts
+}
+
+// flush returns the held text at the end of the stream. A block the LLM
+// didn't close is still returned.
+func (sp *actionSplitter) flush() []splitPart {
+	held := sp.held
+	sp.held = ""
+	if !sp.inBlock {
+		if held == "" {
+			return nil
+		}
+		return []splitPart{{text: held}}
+	}
+
+	sp.block.WriteString(held)
+	block := strings.TrimSpace(sp.block.String())
+	sp.block.Reset()
+	sp.inBlock = false
+	if block == "" {
+		return nil
+	}
+	return []splitPart{{text: block, block: true}}
+}
+
+// partialSuffix returns the length of the longest end of text that is the
+// start of marker
+func partialSuffix(text, marker string) int {
+	for n := min(len(marker)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
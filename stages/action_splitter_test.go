@@ -0,0 +1,95 @@
+package stages
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestActionSplitterStage tests that an action block split across deltas is
+// separated from the prose around it
+func TestActionSplitterStage(t *testing.T) {
+	stage := NewActionSplitterStage(ActionSplitterConfig{Logger: testLogger()})
+	block := `{"actions": [{"actionId": "a1", "actionType": "navigate", "target": "/pricing"}]}`
+
+	results := runTurn(t, stage,
+		core.LLMEvent{Delta: "Sure, opening pricing. ``"},
+		core.LLMEvent{Delta: "`act"},
+		core.LLMEvent{Delta: "ions\n" + block + "\n`"},
+		core.LLMEvent{Delta: "``\nAnything else? ```python\nx = 1\n```"},
+		core.DoneEvent{FullText: "raw"},
+	)
+
+	want := []core.Event{
+		core.LLMEvent{Delta: "Sure, opening pricing. ", Content: "Sure, opening pricing. "},
+		core.ActionBlockEvent{Content: block},
+		core.LLMEvent{
+			Delta:   "\nAnything else? ```python\nx = 1\n",
+			Content: "Sure, opening pricing. \nAnything else? ```python\nx = 1\n",
+		},
+		// Held back until the turn ends as it might have opened an action block
+		core.LLMEvent{Delta: "```", Content: "Sure, opening pricing. \nAnything else? ```python\nx = 1\n```"},
+		core.DoneEvent{FullText: "Sure, opening pricing. \nAnything else? ```python\nx = 1\n```"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+}
+
+// TestActionSplitterUnclosedBlock tests that a block the LLM didn't close is
+// still sent at the end of the turn
+func TestActionSplitterUnclosedBlock(t *testing.T) {
+	stage := NewActionSplitterStage(ActionSplitterConfig{Fence: "json", Logger: testLogger()})
+
+	results := runTurn(t, stage,
+		core.LLMEvent{Delta: "Done.\n```json\n[{\"actionId\": \"a1\"}]"},
+		core.DoneEvent{},
+	)
+
+	want := []core.Event{
+		core.LLMEvent{Delta: "Done.\n", Content: "Done.\n"},
+		core.ActionBlockEvent{Content: `[{"actionId": "a1"}]`},
+		core.DoneEvent{FullText: "Done.\n"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+}
+
+// TestActionStageActionBlocks tests that action blocks are parsed instead of
+// the LLM text, skipping malformed ones
+func TestActionStageActionBlocks(t *testing.T) {
+	stage := NewActionStage(ActionStageConfig{})
+
+	results := runTurn(t, stage,
+		core.LLMEvent{Delta: `Ignored [{"actionId": "scraped"}]`},
+		core.ActionBlockEvent{Content: `{"actions": [{"actionId": "a1", "actionType": "navigate", "target": "/pricing"}]}`},
+		core.ActionBlockEvent{Content: `{"actionId": "a2", "actionType": "click", "target": "#buy"}`},
+		core.ActionBlockEvent{Content: `{"actionId": `},
+		core.DoneEvent{},
+	)
+
+	var actions []string
+	var errors int
+	var done core.DoneEvent
+	for _, event := range results {
+		switch e := event.(type) {
+		case core.ActionEvent:
+			actions = append(actions, e.ActionID+" "+string(e.ActionType)+" "+e.Target)
+		case core.ErrorEvent:
+			errors++
+		case core.DoneEvent:
+			done = e
+		}
+	}
+	if expected := []string{"a1 navigate /pricing", "a2 click #buy"}; !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected actions %v, got %v", expected, actions)
+	}
+	if errors != 1 {
+		t.Errorf("expected 1 error for the malformed block, got %d", errors)
+	}
+	if done.ActionsCount != 2 {
+		t.Errorf("expected 2 actions counted, got %d", done.ActionsCount)
+	}
+}
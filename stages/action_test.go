@@ -2,10 +2,13 @@ package stages
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
 	"pgregory.net/rapid"
 )
 
@@ -102,3 +105,62 @@ func TestPropertyActionEventEmission(t *testing.T) {
 		}
 	})
 }
+
+// TestActionStageAwaitsRequiredActions tests that required actions wait for
+// the client's action.complete message or their timeout
+func TestActionStageAwaitsRequiredActions(t *testing.T) {
+	results := NewActionResults()
+	handle := results.Handler()
+	stage := NewActionStage(ActionStageConfig{
+		Actions: []ActionRequestPayload{
+			{ActionID: "a1", ActionType: core.ActionFillForm, Required: true},
+			{ActionID: "a2", ActionType: core.ActionClick, Required: true, Timeout: 20},
+			{ActionID: "a3", ActionType: core.ActionNavigate},
+		},
+		Results: results,
+	})
+
+	input := make(chan core.Event)
+	close(input)
+	output := make(chan core.Event)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() {
+		defer close(output)
+		stage.Process(ctx, input, output)
+	}()
+
+	var got []string
+	for event := range output {
+		switch e := event.(type) {
+		case core.ActionEvent:
+			got = append(got, "action "+e.ActionID)
+			if e.ActionID == "a1" {
+				// The client answers before the stage reads its result
+				handle(ctx, &protocol.InputMessage{
+					Type:    protocol.InputActionComplete,
+					Payload: protocol.ActionCompletePayload{ActionID: "a1", Success: true, Result: "sent"},
+				})
+			}
+		case core.ActionResultEvent:
+			got = append(got, fmt.Sprintf("result %s success=%v timed_out=%v result=%v", e.ActionID, e.Success, e.TimedOut, e.Result))
+		case core.DoneEvent:
+			got = append(got, fmt.Sprintf("done %d", e.ActionsCount))
+		}
+	}
+
+	expected := []string{
+		"action a1",
+		"result a1 success=true timed_out=false result=sent",
+		"action a2",
+		"result a2 success=false timed_out=true result=<nil>",
+		"action a3",
+		"done 3",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if results.Complete(core.ActionResultEvent{ActionID: "a2"}) {
+		t.Error("expected a late result to find no waiting stage")
+	}
+}
@@ -0,0 +1,391 @@
+package stages
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// WAV format codes for the encodings the file stages support
+const (
+	wavFormatPCM   = 1
+	wavFormatMulaw = 7
+)
+
+// wavHeaderSize is the size of the canonical 44-byte header AudioFileSink
+// writes
+const wavHeaderSize = 44
+
+// wavFormat describes the audio in a WAV file
+type wavFormat struct {
+	Encoding   string
+	SampleRate int
+	Channels   int
+}
+
+// readWAVHeader reads RIFF chunks up to the start of the data chunk. Only
+// 16-bit PCM and 8-bit μ-law are supported, matching AudioTranscodeStage.
+func readWAVHeader(r io.Reader) (wavFormat, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return wavFormat{}, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return wavFormat{}, errors.New("not a WAV file")
+	}
+
+	var format wavFormat
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return wavFormat{}, fmt.Errorf("WAV file has no data chunk: %w", err)
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return wavFormat{}, fmt.Errorf("failed to read WAV format: %w", err)
+			}
+			if len(fmtChunk) < 16 {
+				return wavFormat{}, errors.New("WAV format chunk too short")
+			}
+			code := binary.LittleEndian.Uint16(fmtChunk[0:2])
+			bits := binary.LittleEndian.Uint16(fmtChunk[14:16])
+			switch {
+			case code == wavFormatPCM && bits == 16:
+				format.Encoding = AudioEncodingPCM
+			case code == wavFormatMulaw && bits == 8:
+				format.Encoding = AudioEncodingMulaw
+			default:
+				return wavFormat{}, fmt.Errorf("unsupported WAV encoding %d with %d bits per sample", code, bits)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+
+		case "data":
+			if format.Encoding == "" {
+				return wavFormat{}, errors.New("WAV data chunk before format chunk")
+			}
+			return format, nil
+
+		default:
+			// Skip chunks we don't need, e.g. LIST; chunks are word aligned
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return wavFormat{}, fmt.Errorf("failed to skip WAV chunk %q: %w", id, err)
+			}
+		}
+	}
+}
+
+// writeWAVHeader writes a canonical WAV header for dataSize bytes of audio
+func writeWAVHeader(w io.Writer, format wavFormat, dataSize uint32) error {
+	code, bits := uint16(wavFormatPCM), uint16(16)
+	if format.Encoding == AudioEncodingMulaw {
+		code, bits = wavFormatMulaw, 8
+	}
+	blockAlign := uint16(format.Channels) * bits / 8
+
+	header := make([]byte, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, 36+dataSize)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, code)
+	header = binary.LittleEndian.AppendUint16(header, uint16(format.Channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(format.SampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(format.SampleRate)*uint32(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, blockAlign)
+	header = binary.LittleEndian.AppendUint16(header, bits)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, dataSize)
+
+	_, err := w.Write(header)
+	return err
+}
+
+// WAVFileSourceConfig holds WAV file source configuration
+type WAVFileSourceConfig struct {
+	Path string
+
+	// Encoding, SampleRate and Channels describe headerless raw audio files.
+	// Files starting with a RIFF header use the header instead.
+	Encoding   string
+	SampleRate int
+	Channels   int
+
+	// ChunkDuration is the length of audio per AudioEvent (default: 20ms)
+	ChunkDuration time.Duration
+
+	// RealTime paces chunks at playback rate, as a live microphone would
+	// send them. Otherwise the file is streamed as fast as the pipeline
+	// accepts it.
+	RealTime bool
+
+	Logger telemetry.Logger
+}
+
+// WAVFileSource streams a WAV or raw PCM file as AudioEvents, for offline
+// batch processing and regression tests. The stream ends when the file
+// does.
+type WAVFileSource struct {
+	config WAVFileSourceConfig
+}
+
+// NewWAVFileSource creates a new WAV file source stage
+func NewWAVFileSource(config WAVFileSourceConfig) *WAVFileSource {
+	if config.ChunkDuration <= 0 {
+		config.ChunkDuration = 20 * time.Millisecond
+	}
+	return &WAVFileSource{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *WAVFileSource) Name() string {
+	return "wav_file_source"
+}
+
+// InputTypes returns the input event types this stage accepts
+func (s *WAVFileSource) InputTypes() []core.EventType {
+	// WAV file source is an entry stage, it reads from the file only
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (s *WAVFileSource) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeAudio}
+}
+
+// Process implements the Stage interface
+func (s *WAVFileSource) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	file, err := os.Open(s.config.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	format := wavFormat{
+		Encoding:   s.config.Encoding,
+		SampleRate: s.config.SampleRate,
+		Channels:   s.config.Channels,
+	}
+	if magic, err := reader.Peek(4); err == nil && string(magic) == "RIFF" {
+		if format, err = readWAVHeader(reader); err != nil {
+			return fmt.Errorf("%s: %w", s.config.Path, err)
+		}
+	}
+	if format.Encoding == "" {
+		format.Encoding = AudioEncodingPCM
+	}
+	if format.Channels == 0 {
+		format.Channels = 1
+	}
+	if format.SampleRate <= 0 {
+		return fmt.Errorf("%s: sample rate unknown for raw audio", s.config.Path)
+	}
+
+	frameSize := format.Channels * 2
+	if format.Encoding == AudioEncodingMulaw {
+		frameSize = format.Channels
+	}
+	frames := int(s.config.ChunkDuration.Seconds() * float64(format.SampleRate))
+	chunk := make([]byte, max(frames, 1)*frameSize)
+
+	logger.Info("Streaming audio file",
+		telemetry.String("path", s.config.Path),
+		telemetry.String("encoding", format.Encoding),
+		telemetry.Int("sample_rate", format.SampleRate))
+
+	var ticker *time.Ticker
+	if s.config.RealTime {
+		ticker = time.NewTicker(s.config.ChunkDuration)
+		defer ticker.Stop()
+	}
+
+	for seq := uint64(1); ; seq++ {
+		n, err := io.ReadFull(reader, chunk)
+		// Drop a trailing partial frame
+		n -= n % frameSize
+		if n > 0 {
+			if ticker != nil && seq > 1 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+				}
+			}
+
+			event := core.AudioEvent{
+				Data:       append([]byte(nil), chunk[:n]...),
+				Format:     format.Encoding,
+				SampleRate: format.SampleRate,
+				Channels:   format.Channels,
+				SeqNum:     seq,
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- event:
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audio file: %w", err)
+		}
+	}
+}
+
+// AudioFileSinkConfig holds audio file sink configuration
+type AudioFileSinkConfig struct {
+	Path       string
+	SampleRate int // Sample rate when audio events carry none (default: 24000)
+	Logger     telemetry.Logger
+}
+
+// AudioFileSink writes the AudioEvents it receives to a WAV file, for
+// offline batch processing and regression tests. The file takes the
+// encoding, sample rate and channels of the first chunk; chunks in a
+// different format are skipped. Other events are ignored.
+type AudioFileSink struct {
+	config AudioFileSinkConfig
+}
+
+// NewAudioFileSink creates a new audio file sink stage
+func NewAudioFileSink(config AudioFileSinkConfig) *AudioFileSink {
+	if config.SampleRate == 0 {
+		config.SampleRate = 24000
+	}
+	return &AudioFileSink{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *AudioFileSink) Name() string {
+	return "audio_file_sink"
+}
+
+// InputTypes returns the input event types this stage accepts
+func (s *AudioFileSink) InputTypes() []core.EventType {
+	// Audio file sink accepts all event types, writing only audio
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (s *AudioFileSink) OutputTypes() []core.EventType {
+	// Audio file sink is a terminal stage
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+// The WAV header is written with the final size once the input closes or
+// the context is cancelled.
+func (s *AudioFileSink) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) (err error) {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	file, err := os.Create(s.config.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+
+	var format wavFormat
+	var dataSize uint32
+	defer func() {
+		if format.Encoding == "" {
+			format = wavFormat{Encoding: AudioEncodingPCM, SampleRate: s.config.SampleRate, Channels: 1}
+		}
+		closeErr := s.finish(file, writer, format, dataSize)
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	// Reserve room for the header, rewritten with sizes by finish
+	if _, err := writer.Write(make([]byte, wavHeaderSize)); err != nil {
+		return fmt.Errorf("failed to write audio file: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-input:
+			if !ok {
+				logger.Info("Audio file written", telemetry.String("path", s.config.Path), telemetry.Int("bytes", int(dataSize)))
+				return nil
+			}
+
+			audio, ok := event.(core.AudioEvent)
+			if !ok {
+				continue
+			}
+
+			chunkFormat := wavFormat{Encoding: audio.Format, SampleRate: audio.SampleRate, Channels: audio.Channels}
+			if chunkFormat.Encoding == "" {
+				chunkFormat.Encoding = AudioEncodingPCM
+			}
+			if chunkFormat.SampleRate == 0 {
+				chunkFormat.SampleRate = s.config.SampleRate
+			}
+			if chunkFormat.Channels == 0 {
+				chunkFormat.Channels = 1
+			}
+
+			if format.Encoding == "" {
+				if chunkFormat.Encoding != AudioEncodingPCM && chunkFormat.Encoding != AudioEncodingMulaw {
+					return fmt.Errorf("unsupported audio encoding for WAV: %s", chunkFormat.Encoding)
+				}
+				format = chunkFormat
+			}
+			if chunkFormat != format {
+				logger.Warn("Skipping audio chunk in a different format",
+					telemetry.String("encoding", chunkFormat.Encoding),
+					telemetry.Int("sample_rate", chunkFormat.SampleRate))
+				continue
+			}
+
+			if _, err := writer.Write(audio.Data); err != nil {
+				return fmt.Errorf("failed to write audio file: %w", err)
+			}
+			dataSize += uint32(len(audio.Data))
+		}
+	}
+}
+
+// finish flushes buffered audio, writes the final header and closes the file
+func (s *AudioFileSink) finish(file *os.File, writer *bufio.Writer, format wavFormat, dataSize uint32) error {
+	err := writer.Flush()
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = writeWAVHeader(file, format, dataSize)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to finish audio file: %w", err)
+	}
+	return nil
+}
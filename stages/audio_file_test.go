@@ -0,0 +1,111 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func collectAudio(t *testing.T, source *WAVFileSource) []core.AudioEvent {
+	t.Helper()
+	output := make(chan core.Event, 64)
+	if err := source.Process(context.Background(), nil, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+	var chunks []core.AudioEvent
+	for event := range output {
+		chunks = append(chunks, event.(core.AudioEvent))
+	}
+	return chunks
+}
+
+func TestAudioFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	sink := NewAudioFileSink(AudioFileSinkConfig{Path: path, Logger: testLogger()})
+
+	// 100ms of 8kHz PCM in two chunks, with a non-audio event between
+	pcm := make([]byte, 1600)
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	input := make(chan core.Event, 4)
+	input <- core.AudioEvent{Data: pcm[:800], Format: AudioEncodingPCM, SampleRate: 8000}
+	input <- core.LLMEvent{Delta: "ignored"}
+	input <- core.AudioEvent{Data: pcm[800:], Format: AudioEncodingPCM, SampleRate: 8000}
+	input <- core.AudioEvent{Data: []byte{1, 2}, Format: AudioEncodingPCM, SampleRate: 16000} // Skipped
+	close(input)
+
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("sink Process failed: %v", err)
+	}
+
+	source := NewWAVFileSource(WAVFileSourceConfig{Path: path, ChunkDuration: 40 * time.Millisecond, Logger: testLogger()})
+	chunks := collectAudio(t, source)
+
+	// 40ms chunks: 640, 640 and the remaining 320 bytes
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	var data []byte
+	for i, chunk := range chunks {
+		if chunk.Format != AudioEncodingPCM || chunk.SampleRate != 8000 || chunk.Channels != 1 || chunk.SeqNum != uint64(i+1) {
+			t.Errorf("chunk %d: unexpected format %+v", i, chunk)
+		}
+		data = append(data, chunk.Data...)
+	}
+	if !bytes.Equal(data, pcm) {
+		t.Error("audio read back differs from audio written")
+	}
+}
+
+func TestWAVFileSource_RawRealTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.pcm")
+	// 100ms of 16kHz PCM plus a partial trailing frame
+	if err := os.WriteFile(path, make([]byte, 3201), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewWAVFileSource(WAVFileSourceConfig{
+		Path:          path,
+		SampleRate:    16000,
+		ChunkDuration: 25 * time.Millisecond,
+		RealTime:      true,
+		Logger:        testLogger(),
+	})
+
+	start := time.Now()
+	chunks := collectAudio(t, source)
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("expected real-time pacing over ~75ms, took %v", elapsed)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if len(chunk.Data) != 800 {
+			t.Errorf("expected 800-byte chunks, got %d", len(chunk.Data))
+		}
+	}
+}
+
+func TestWAVFileSource_RejectsUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "float.wav")
+	var buf bytes.Buffer
+	writeWAVHeader(&buf, wavFormat{Encoding: AudioEncodingPCM, SampleRate: 8000, Channels: 1}, 0)
+	header := buf.Bytes()
+	header[20] = 3 // IEEE float
+	if err := os.WriteFile(path, header, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewWAVFileSource(WAVFileSourceConfig{Path: path, Logger: testLogger()})
+	if err := source.Process(context.Background(), nil, make(chan core.Event, 1)); err == nil {
+		t.Error("expected an error for float WAV")
+	}
+}
@@ -0,0 +1,305 @@
+package stages
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// Audio encodings understood by AudioTranscodeStage
+const (
+	AudioEncodingPCM   = "pcm"   // 16-bit signed little-endian linear PCM
+	AudioEncodingMulaw = "mulaw" // G.711 μ-law
+	AudioEncodingOpus  = "opus"  // Requires a codec in AudioTranscodeStageConfig.Codecs
+)
+
+// AudioCodec converts between an encoded audio format and 16-bit PCM samples.
+// Codecs may keep state between calls (e.g. an Opus decoder); a codec instance
+// must not be shared between concurrently running stages.
+type AudioCodec interface {
+	Decode(data []byte) ([]int16, error)
+	Encode(samples []int16) ([]byte, error)
+}
+
+// AudioTranscodeStageConfig holds audio transcoding stage configuration
+type AudioTranscodeStageConfig struct {
+	InputEncoding    string                // Defaults to the AudioEvent's Format
+	InputSampleRate  int                   // Defaults to the AudioEvent's SampleRate
+	OutputEncoding   string                // Defaults to the input encoding
+	OutputSampleRate int                   // Defaults to the input sample rate
+	Codecs           map[string]AudioCodec // Additional codecs, e.g. "opus"
+	Logger           telemetry.Logger
+}
+
+// AudioTranscodeStage converts AudioEvents between sample rates and encodings
+// so providers with different native formats can be chained
+type AudioTranscodeStage struct {
+	config AudioTranscodeStageConfig
+}
+
+// NewAudioTranscodeStage creates a new audio transcoding stage
+func NewAudioTranscodeStage(config AudioTranscodeStageConfig) *AudioTranscodeStage {
+	return &AudioTranscodeStage{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *AudioTranscodeStage) Name() string {
+	return "audio_transcode"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *AudioTranscodeStage) InputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeAudio}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *AudioTranscodeStage) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeAudio, core.EventTypeError}
+}
+
+// Process implements the Stage interface
+// It transcodes AudioEvents and passes all other events through unchanged
+func (s *AudioTranscodeStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	// Codecs are created per run because the built-in ones carry partial frames
+	var decoder, encoder AudioCodec
+	var resampler *linearResampler
+	var outputEncoding string
+	var outputSampleRate int
+	var seqNum uint64 // Output chunks are renumbered since empty chunks are dropped
+
+	for event := range input {
+		audioEvent, ok := event.(core.AudioEvent)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- event:
+			}
+			continue
+		}
+
+		if decoder == nil {
+			inputEncoding := s.config.InputEncoding
+			if inputEncoding == "" {
+				inputEncoding = audioEvent.Format
+			}
+			outputEncoding = s.config.OutputEncoding
+			if outputEncoding == "" {
+				outputEncoding = inputEncoding
+			}
+
+			var err error
+			if decoder, err = s.codec(inputEncoding); err != nil {
+				return err
+			}
+			if encoder, err = s.codec(outputEncoding); err != nil {
+				return err
+			}
+			inputSampleRate := s.config.InputSampleRate
+			if inputSampleRate == 0 {
+				inputSampleRate = audioEvent.SampleRate
+			}
+			outputSampleRate = s.config.OutputSampleRate
+			if outputSampleRate == 0 {
+				outputSampleRate = inputSampleRate
+			}
+			resampler = newLinearResampler(inputSampleRate, outputSampleRate)
+
+			logger.Info("Transcoding audio",
+				telemetry.String("input_encoding", inputEncoding),
+				telemetry.Int("input_sample_rate", inputSampleRate),
+				telemetry.String("output_encoding", outputEncoding),
+				telemetry.Int("output_sample_rate", outputSampleRate))
+		}
+
+		samples, err := decoder.Decode(audioEvent.Data)
+		if err == nil {
+			var data []byte
+			data, err = encoder.Encode(resampler.process(samples))
+			audioEvent.Data = data
+		}
+		if err != nil {
+			logger.Warn("Failed to transcode audio chunk", telemetry.Err(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- core.ErrorEvent{
+				Error:     fmt.Errorf("failed to transcode audio: %w", err),
+				Retryable: false,
+				Code:      core.ErrCodeInvalidAudio,
+			}:
+			}
+			continue
+		}
+
+		if len(audioEvent.Data) == 0 {
+			continue
+		}
+		seqNum++
+		audioEvent.Format = outputEncoding
+		audioEvent.SampleRate = outputSampleRate
+		audioEvent.SeqNum = seqNum
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- audioEvent:
+		}
+	}
+
+	return nil
+}
+
+// codec returns the codec for an encoding, preferring configured codecs
+func (s *AudioTranscodeStage) codec(encoding string) (AudioCodec, error) {
+	if codec, ok := s.config.Codecs[encoding]; ok {
+		return codec, nil
+	}
+
+	switch encoding {
+	case AudioEncodingPCM, "pcm16", "linear16", "pcm_s16le":
+		return &pcm16Codec{}, nil
+	case AudioEncodingMulaw, "ulaw", "pcm_mulaw":
+		return mulawCodec{}, nil
+	default:
+		return nil, fmt.Errorf("no codec registered for audio encoding %q", encoding)
+	}
+}
+
+// pcm16Codec handles 16-bit little-endian PCM, carrying an odd trailing byte
+// over to the next chunk
+type pcm16Codec struct {
+	pending []byte
+}
+
+func (c *pcm16Codec) Decode(data []byte) ([]int16, error) {
+	if len(c.pending) > 0 {
+		data = append(c.pending, data...)
+		c.pending = nil
+	}
+	if len(data)%2 == 1 {
+		c.pending = []byte{data[len(data)-1]}
+		data = data[:len(data)-1]
+	}
+
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return samples, nil
+}
+
+func (c *pcm16Codec) Encode(samples []int16) ([]byte, error) {
+	data := make([]byte, 2*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	return data, nil
+}
+
+// mulawCodec implements G.711 μ-law
+type mulawCodec struct{}
+
+const (
+	mulawBias = 0x84
+	mulawClip = 32635
+)
+
+func (mulawCodec) Decode(data []byte) ([]int16, error) {
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = mulawDecode(b)
+	}
+	return samples, nil
+}
+
+func (mulawCodec) Encode(samples []int16) ([]byte, error) {
+	data := make([]byte, len(samples))
+	for i, sample := range samples {
+		data[i] = mulawEncode(sample)
+	}
+	return data, nil
+}
+
+func mulawEncode(sample int16) byte {
+	value := int(sample)
+	sign := 0
+	if value < 0 {
+		value = -value
+		sign = 0x80
+	}
+	if value > mulawClip {
+		value = mulawClip
+	}
+	value += mulawBias
+
+	exponent := 7
+	for mask := 0x4000; value&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (value >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func mulawDecode(b byte) int16 {
+	b = ^b
+	sign := b & 0x80
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b & 0x0F)
+	value := ((mantissa << 3) + mulawBias) << exponent
+	value -= mulawBias
+	if sign != 0 {
+		return int16(-value)
+	}
+	return int16(value)
+}
+
+// linearResampler converts sample rates with linear interpolation, keeping
+// the last sample and fractional position so chunk boundaries stay continuous
+type linearResampler struct {
+	step    float64 // Input samples advanced per output sample
+	pos     float64 // Position of the next output sample relative to last
+	last    int16
+	hasLast bool
+}
+
+func newLinearResampler(inputRate, outputRate int) *linearResampler {
+	if inputRate <= 0 || outputRate <= 0 || inputRate == outputRate {
+		return nil
+	}
+	return &linearResampler{step: float64(inputRate) / float64(outputRate)}
+}
+
+func (r *linearResampler) process(samples []int16) []int16 {
+	if r == nil || len(samples) == 0 {
+		return samples
+	}
+
+	buf := samples
+	if r.hasLast {
+		buf = append([]int16{r.last}, samples...)
+	}
+
+	out := make([]int16, 0, int(float64(len(buf))/r.step)+1)
+	for r.pos+1 < float64(len(buf)) {
+		i := int(r.pos)
+		frac := r.pos - float64(i)
+		value := float64(buf[i])*(1-frac) + float64(buf[i+1])*frac
+		out = append(out, int16(math.Round(value)))
+		r.pos += r.step
+	}
+
+	// Rebase so the last input sample becomes index 0 of the next chunk
+	r.pos -= float64(len(buf) - 1)
+	r.last = buf[len(buf)-1]
+	r.hasLast = true
+	return out
+}
@@ -0,0 +1,98 @@
+package stages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"pgregory.net/rapid"
+)
+
+// For any 16-bit sample, μ-law encoding followed by decoding SHALL stay within
+// the quantization error of the segment the sample falls into
+func TestPropertyMulawRoundTrip(t *testing.T) {
+	rapid.Check(t, func(rt *rapid.T) {
+		sample := int16(rapid.IntRange(-mulawClip, mulawClip).Draw(rt, "sample"))
+
+		decoded := mulawDecode(mulawEncode(sample))
+
+		diff := int(decoded) - int(sample)
+		if diff < 0 {
+			diff = -diff
+		}
+		magnitude := int(sample)
+		if magnitude < 0 {
+			magnitude = -magnitude
+		}
+		if diff > magnitude/16+8 {
+			rt.Fatalf("sample %d decoded to %d", sample, decoded)
+		}
+	})
+}
+
+func TestAudioTranscodeStage_PCMToMulawDownsample(t *testing.T) {
+	stage := NewAudioTranscodeStage(AudioTranscodeStageConfig{
+		InputSampleRate:  16000,
+		OutputEncoding:   AudioEncodingMulaw,
+		OutputSampleRate: 8000,
+	})
+
+	// 320 samples of 16 kHz PCM, split across two chunks with an odd boundary
+	pcm, _ := (&pcm16Codec{}).Encode(make([]int16, 320))
+	input := make(chan core.Event, 4)
+	output := make(chan core.Event, 4)
+	input <- core.AudioEvent{Data: pcm[:201], Format: AudioEncodingPCM}
+	input <- core.AudioEvent{Data: pcm[201:], Format: AudioEncodingPCM}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var bytes int
+	var seqNum uint64
+	var done bool
+	for event := range output {
+		switch e := event.(type) {
+		case core.AudioEvent:
+			if e.Format != AudioEncodingMulaw {
+				t.Errorf("expected mulaw format, got %q", e.Format)
+			}
+			if e.SampleRate != 8000 {
+				t.Errorf("expected 8000 Hz sample rate, got %d", e.SampleRate)
+			}
+			seqNum++
+			if e.SeqNum != seqNum {
+				t.Errorf("expected sequence number %d, got %d", seqNum, e.SeqNum)
+			}
+			bytes += len(e.Data)
+		case core.DoneEvent:
+			done = true
+		}
+	}
+
+	// Halving the rate yields ~160 one-byte μ-law samples
+	if bytes < 158 || bytes > 160 {
+		t.Errorf("expected ~160 output bytes, got %d", bytes)
+	}
+	if !done {
+		t.Error("expected DoneEvent to pass through")
+	}
+}
+
+func TestAudioTranscodeStage_UnknownEncoding(t *testing.T) {
+	stage := NewAudioTranscodeStage(AudioTranscodeStageConfig{
+		InputEncoding:  AudioEncodingPCM,
+		OutputEncoding: AudioEncodingOpus,
+	})
+
+	input := make(chan core.Event, 1)
+	input <- core.AudioEvent{Data: []byte{0, 0}}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, make(chan core.Event, 1)); err == nil {
+		t.Error("expected error for opus without a registered codec")
+	}
+}
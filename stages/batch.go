@@ -0,0 +1,152 @@
+package stages
+
+import (
+	"context"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// BatchStageConfig holds configuration for BatchStage
+type BatchStageConfig struct {
+	// Match selects the events to batch; others pass straight through after
+	// the pending batch is flushed. Nil batches every event. Audio should not
+	// be batched, as sinks send it as binary frames.
+	Match func(core.Event) bool
+
+	// MaxSize flushes a batch once it holds this many events. Zero means no
+	// size limit.
+	MaxSize int
+
+	// Window flushes a batch this long after its first event. Zero means no
+	// time limit.
+	Window time.Duration
+
+	// Merge turns a full batch into the events sent downstream. Defaults to
+	// a single BatchEvent; BatchLatest keeps only the newest event.
+	Merge func([]core.Event) []core.Event
+}
+
+// BatchStage groups events by count or time window and emits each group
+// downstream, reducing per-message overhead on chatty streams such as STT
+// interims or analytics. A batch is flushed when it reaches MaxSize, when
+// its Window expires, when a non-matching event arrives, and when the input
+// closes, so event order is preserved.
+type BatchStage struct {
+	config BatchStageConfig
+}
+
+// NewBatchStage creates a new batch stage
+func NewBatchStage(config BatchStageConfig) *BatchStage {
+	if config.Match == nil {
+		config.Match = func(core.Event) bool { return true }
+	}
+	if config.Merge == nil {
+		config.Merge = BatchEvents
+	}
+	return &BatchStage{config: config}
+}
+
+// BatchEvents merges events into one BatchEvent carrying the metadata of
+// the newest event
+func BatchEvents(events []core.Event) []core.Event {
+	return []core.Event{core.BatchEvent{
+		Events: events,
+		Meta:   core.MetaOf(events[len(events)-1]),
+	}}
+}
+
+// BatchLatest merges events by keeping only the newest, which coalesces
+// superseding events such as STT interims to at most one per window
+func BatchLatest(events []core.Event) []core.Event {
+	return events[len(events)-1:]
+}
+
+// Name returns the stage name
+func (s *BatchStage) Name() string {
+	return "batch"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *BatchStage) InputTypes() []core.EventType {
+	// Batch stage accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *BatchStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *BatchStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	var pending []core.Event
+	var timer *time.Timer
+	var expired <-chan time.Time
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	flush := func() error {
+		if timer != nil {
+			timer.Stop()
+			timer, expired = nil, nil
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		batch := pending
+		pending = nil
+		for _, event := range s.config.Merge(batch) {
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-expired:
+			timer, expired = nil, nil
+			if err := flush(); err != nil {
+				return err
+			}
+
+		case event, ok := <-input:
+			if !ok {
+				return flush()
+			}
+
+			if !s.config.Match(event) {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := send(event); err != nil {
+					return err
+				}
+				continue
+			}
+
+			pending = append(pending, event)
+			if len(pending) == 1 && s.config.Window > 0 {
+				timer = time.NewTimer(s.config.Window)
+				expired = timer.C
+			}
+			if s.config.MaxSize > 0 && len(pending) >= s.config.MaxSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
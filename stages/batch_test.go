@@ -0,0 +1,102 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func runBatch(t *testing.T, stage *BatchStage, input chan core.Event) []core.Event {
+	t.Helper()
+	output := make(chan core.Event, 16)
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestBatchStage_MaxSize(t *testing.T) {
+	stage := NewBatchStage(BatchStageConfig{
+		Match:   func(event core.Event) bool { return event.EventType() == core.EventTypeLLM },
+		MaxSize: 2,
+	})
+
+	input := make(chan core.Event, 5)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.LLMEvent{Delta: "b"}
+	input <- core.LLMEvent{Delta: "c"}
+	input <- core.DoneEvent{FullText: "abc"}
+	input <- core.LLMEvent{Delta: "d"}
+	close(input)
+
+	events := runBatch(t, stage, input)
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %v", len(events), events)
+	}
+	sizes := []int{2, 1, -1, 1}
+	for i, event := range events {
+		batch, ok := event.(core.BatchEvent)
+		if sizes[i] < 0 {
+			if _, done := event.(core.DoneEvent); !done {
+				t.Errorf("event %d: expected done to pass through, got %T", i, event)
+			}
+			continue
+		}
+		if !ok || len(batch.Events) != sizes[i] {
+			t.Errorf("event %d: expected batch of %d, got %v", i, sizes[i], event)
+		}
+	}
+}
+
+func TestBatchStage_WindowCoalescesInterims(t *testing.T) {
+	stage := NewBatchStage(BatchStageConfig{
+		Match: func(event core.Event) bool {
+			stt, ok := event.(core.STTEvent)
+			return ok && !stt.IsFinal
+		},
+		Window: 20 * time.Millisecond,
+		Merge:  BatchLatest,
+	})
+
+	input := make(chan core.Event)
+	output := make(chan core.Event, 16)
+	done := make(chan error, 1)
+	go func() { done <- stage.Process(context.Background(), input, output) }()
+
+	input <- core.STTEvent{Text: "h"}
+	input <- core.STTEvent{Text: "he"}
+	input <- core.STTEvent{Text: "hel"}
+
+	select {
+	case event := <-output:
+		if stt, ok := event.(core.STTEvent); !ok || stt.Text != "hel" {
+			t.Errorf("expected latest interim, got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("window did not flush")
+	}
+
+	input <- core.STTEvent{Text: "hello"}
+	input <- core.STTEvent{Text: "hello", IsFinal: true}
+	close(input)
+	if err := <-done; err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var texts []string
+	for event := range output {
+		stt := event.(core.STTEvent)
+		texts = append(texts, stt.Text)
+	}
+	if len(texts) != 2 || texts[0] != "hello" || texts[1] != "hello" {
+		t.Errorf("expected flushed interim then final, got %v", texts)
+	}
+}
@@ -0,0 +1,188 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// Broker publishes and consumes messages on named topics. Adapt a Kafka,
+// NATS or other client to it; the broker stages depend on no client
+// library. Messages are protocol messages encoded with the stage's codec.
+type Broker interface {
+	// Publish sends data to topic. Key groups messages that must stay in
+	// order, e.g. a Kafka partition key or NATS subject suffix; the broker
+	// stages use the session ID.
+	Publish(ctx context.Context, topic, key string, data []byte) error
+
+	// Subscribe returns the messages arriving on topic. The channel closes
+	// when ctx is cancelled or the subscription ends.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
+
+// BrokerSinkConfig holds broker sink configuration
+type BrokerSinkConfig struct {
+	Broker     Broker
+	Topic      string
+	SessionID  string
+	ResponseID string         // ID for events without a turn, see core.TurnContext
+	Codec      protocol.Codec // Encoding of published messages (default: JSON)
+	Logger     telemetry.Logger
+}
+
+// BrokerSink publishes pipeline events to a broker topic as protocol output
+// messages, so any number of consumers can follow a session asynchronously.
+// Audio is published as stream.audio messages. Publishing is at most once:
+// failures are logged and the event dropped, leaving retries to the Broker.
+type BrokerSink struct {
+	config BrokerSinkConfig
+}
+
+// NewBrokerSink creates a new broker sink stage
+func NewBrokerSink(config BrokerSinkConfig) *BrokerSink {
+	if config.Codec == nil {
+		config.Codec = protocol.JSONCodec
+	}
+	return &BrokerSink{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (bs *BrokerSink) Name() string {
+	return "broker_sink"
+}
+
+// Process implements the Stage interface
+// It publishes every event with a protocol message until the input closes
+func (bs *BrokerSink) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := bs.config.Logger.WithModule(bs.Name())
+	logger.Info("Starting broker sink stage", telemetry.String("session_id", bs.config.SessionID), telemetry.String("topic", bs.config.Topic))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-input:
+			if !ok {
+				logger.Info("Broker sink input channel closed", telemetry.String("session_id", bs.config.SessionID))
+				return nil
+			}
+
+			responseID := responseIDOf(event, bs.config.ResponseID)
+			data, msg, err := protocol.EncodeEvent(bs.config.Codec, event, bs.config.SessionID, responseID)
+			if msg == nil {
+				continue
+			}
+			if err != nil {
+				logger.Warn("Failed to encode event", telemetry.Err(err), telemetry.String("type", string(msg.Type)))
+				continue
+			}
+
+			if err := bs.config.Broker.Publish(ctx, bs.config.Topic, bs.config.SessionID, data); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				logger.Error("Failed to publish event", telemetry.Err(err), telemetry.String("session_id", bs.config.SessionID), telemetry.String("type", string(msg.Type)))
+			}
+		}
+	}
+}
+
+// InputTypes returns the input event types this stage accepts
+func (bs *BrokerSink) InputTypes() []core.EventType {
+	// Broker sink accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (bs *BrokerSink) OutputTypes() []core.EventType {
+	// Broker sink is a terminal stage
+	return []core.EventType{}
+}
+
+// BrokerSourceConfig holds broker source configuration
+type BrokerSourceConfig struct {
+	Broker    Broker
+	Topic     string
+	SessionID string
+	Codec     protocol.Codec // Encoding of consumed messages (default: JSON)
+	Logger    telemetry.Logger
+}
+
+// BrokerSource consumes protocol input messages from a broker topic and
+// emits the corresponding pipeline events, like WebSocketSource does for a
+// connection
+type BrokerSource struct {
+	config BrokerSourceConfig
+}
+
+// NewBrokerSource creates a new broker source stage
+func NewBrokerSource(config BrokerSourceConfig) *BrokerSource {
+	if config.Codec == nil {
+		config.Codec = protocol.JSONCodec
+	}
+	return &BrokerSource{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (bs *BrokerSource) Name() string {
+	return "broker_source"
+}
+
+// Process implements the Stage interface
+// It dispatches messages from the topic until the subscription ends or the
+// context is cancelled
+func (bs *BrokerSource) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := bs.config.Logger.WithModule(bs.Name())
+	logger.Info("Starting broker source stage", telemetry.String("session_id", bs.config.SessionID), telemetry.String("topic", bs.config.Topic))
+
+	messages, err := bs.config.Broker.Subscribe(ctx, bs.config.Topic)
+	if err != nil {
+		return err
+	}
+
+	router := protocol.NewInputRouter().FeedPipeline(output)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case data, ok := <-messages:
+			if !ok {
+				logger.Info("Broker subscription ended", telemetry.String("session_id", bs.config.SessionID))
+				return nil
+			}
+
+			if err := router.DispatchWith(ctx, bs.config.Codec, data); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				// Log malformed messages but keep consuming - don't fail the pipeline
+				logger.Warn("Failed to handle broker message", telemetry.Err(err), telemetry.String("session_id", bs.config.SessionID))
+			}
+		}
+	}
+}
+
+// InputTypes returns the input event types this stage accepts
+func (bs *BrokerSource) InputTypes() []core.EventType {
+	// Broker source is an entry stage, it reads from the topic only
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (bs *BrokerSource) OutputTypes() []core.EventType {
+	return []core.EventType{
+		core.EventTypeLLM,
+		core.EventTypeAudio,
+		core.EventTypeInterrupt,
+		core.EventTypeDone,
+	}
+}
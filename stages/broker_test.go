@@ -0,0 +1,101 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// fakeBroker records published messages and serves a fixed subscription
+type fakeBroker struct {
+	mu        sync.Mutex
+	published map[string][][]byte
+	keys      []string
+	incoming  chan []byte
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, topic, key string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.published == nil {
+		b.published = make(map[string][][]byte)
+	}
+	b.published[topic] = append(b.published[topic], data)
+	b.keys = append(b.keys, key)
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	return b.incoming, nil
+}
+
+func TestBrokerSink_PublishesMessages(t *testing.T) {
+	broker := &fakeBroker{}
+	sink := NewBrokerSink(BrokerSinkConfig{
+		Broker:    broker,
+		Topic:     "sessions.out",
+		SessionID: "s1",
+		Logger:    testLogger(),
+	})
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "Hi", Content: "Hi"}
+	input <- core.InterruptEvent{} // No protocol message, not published
+	input <- core.DoneEvent{FullText: "Hi"}
+	close(input)
+
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	published := broker.published["sessions.out"]
+	if len(published) != 2 {
+		t.Fatalf("expected 2 published messages, got %d", len(published))
+	}
+	var msg protocol.OutputMessage
+	if err := json.Unmarshal(published[0], &msg); err != nil {
+		t.Fatalf("published message is not JSON: %v", err)
+	}
+	if msg.Type != protocol.OutputStreamLLM || msg.SessionID != "s1" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	for _, key := range broker.keys {
+		if key != "s1" {
+			t.Errorf("expected session ID key, got %q", key)
+		}
+	}
+}
+
+func TestBrokerSource_DispatchesMessages(t *testing.T) {
+	broker := &fakeBroker{incoming: make(chan []byte, 3)}
+	source := NewBrokerSource(BrokerSourceConfig{
+		Broker:    broker,
+		Topic:     "sessions.in",
+		SessionID: "s1",
+		Logger:    testLogger(),
+	})
+
+	broker.incoming <- []byte(`{"type":"input.text","sessionId":"s1","payload":{"text":"hello"}}`)
+	broker.incoming <- []byte(`not json`)
+	close(broker.incoming)
+
+	output := make(chan core.Event, 4)
+	if err := source.Process(context.Background(), nil, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var texts []string
+	for event := range output {
+		if llm, ok := event.(core.LLMEvent); ok {
+			texts = append(texts, llm.Content)
+		}
+	}
+	if len(texts) != 1 || texts[0] != "hello" {
+		t.Errorf("expected one text event, got %v", texts)
+	}
+}
@@ -0,0 +1,192 @@
+package stages
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EmojiMode selects how TextProcessorStage handles emoji and emoticons,
+// which many TTS providers read out as codepoint names
+type EmojiMode string
+
+const (
+	EmojiKeep      EmojiMode = ""          // Pass them to TTS unchanged
+	EmojiStrip     EmojiMode = "strip"     // Remove them
+	EmojiVerbalize EmojiMode = "verbalize" // Replace them by names, "😂" as "laughing emoji"
+)
+
+// emojiNames names common emoji and emoticons for EmojiVerbalize. Emoji
+// without a name are removed.
+var emojiNames = map[string]string{
+	"😂": "laughing emoji",
+	"🤣": "laughing emoji",
+	"😀": "grinning emoji",
+	"😃": "grinning emoji",
+	"😄": "grinning emoji",
+	"😁": "grinning emoji",
+	"😊": "smiling emoji",
+	"🙂": "smiling emoji",
+	"😉": "winking emoji",
+	"😍": "heart eyes emoji",
+	"😘": "kiss emoji",
+	"😎": "cool emoji",
+	"🤔": "thinking emoji",
+	"😢": "crying emoji",
+	"😭": "crying emoji",
+	"😞": "sad emoji",
+	"🙁": "sad emoji",
+	"😡": "angry emoji",
+	"😮": "surprised emoji",
+	"😱": "screaming emoji",
+	"😅": "relieved emoji",
+	"🙄": "eye roll emoji",
+	"🥳": "party emoji",
+	"🎉": "party emoji",
+	"👍": "thumbs up emoji",
+	"👎": "thumbs down emoji",
+	"👏": "clapping emoji",
+	"🙏": "praying emoji",
+	"👋": "waving emoji",
+	"👌": "OK emoji",
+	"💪": "strong emoji",
+	"👀": "eyes emoji",
+	"❤": "heart emoji",
+	"💔": "broken heart emoji",
+	"🔥": "fire emoji",
+	"✨": "sparkles emoji",
+	"⭐": "star emoji",
+	"🚀": "rocket emoji",
+	"💯": "hundred points emoji",
+	"✅": "check mark emoji",
+	"✔": "check mark emoji",
+	"❌": "cross mark emoji",
+	"⚠": "warning emoji",
+	"💡": "light bulb emoji",
+	"☀": "sun emoji",
+	"🌧": "rain emoji",
+	"☕": "coffee emoji",
+	"🍕": "pizza emoji",
+	"🎂": "birthday cake emoji",
+	"🎁": "gift emoji",
+
+	":)":  "smiling emoji",
+	":-)": "smiling emoji",
+	":(":  "sad emoji",
+	":-(": "sad emoji",
+	";)":  "winking emoji",
+	";-)": "winking emoji",
+	":D":  "laughing emoji",
+	":-D": "laughing emoji",
+	":P":  "tongue out emoji",
+	":-P": "tongue out emoji",
+	":'(": "crying emoji",
+	":O":  "surprised emoji",
+	"<3":  "heart emoji",
+}
+
+// emoticonPattern matches the emoticons in emojiNames
+var emoticonPattern = regexp.MustCompile(`:'\(|[:;]-?[)(DPO]|<3`)
+
+// isEmojiRune reports whether r is part of an emoji: a pictograph, a flag's
+// regional indicator, or a modifier joining or styling one
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Pictographs, emoticons, flags, skin tones
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+	case r >= 0x2B00 && r <= 0x2BFF: // Stars, arrows
+	case r == 0x200D || r == 0x20E3: // Zero width joiner, keycap
+	case r >= 0xFE00 && r <= 0xFE0F: // Variation selectors
+	case r >= 0xE0020 && r <= 0xE007F: // Tags
+	default:
+		return false
+	}
+	return true
+}
+
+// handleEmoji strips or verbalizes the emoji and emoticons in text.
+// Repeated emoji are named once.
+func handleEmoji(text string, mode EmojiMode, names map[string]string) string {
+	if mode == EmojiKeep {
+		return text
+	}
+
+	var out []byte
+	lastName := ""
+	for i := 0; i < len(text); {
+		// Emoji are runs of emoji runes, e.g. joined family members
+		end := i
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isEmojiRune(r) {
+				break
+			}
+			end += size
+		}
+		if end == i {
+			if loc := emoticonPattern.FindStringIndex(text[i:]); loc != nil && loc[0] == 0 && standalone(text, i, i+loc[1]) {
+				end = i + loc[1]
+			}
+		}
+		if end == i {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if !unicode.IsSpace(r) {
+				lastName = ""
+			}
+			out = append(out, text[i:i+size]...)
+			i += size
+			continue
+		}
+
+		if mode == EmojiVerbalize {
+			if name := emojiName(text[i:end], names); name != "" && name != lastName {
+				out = append(out, ' ')
+				out = append(out, name...)
+				out = append(out, ' ')
+				lastName = name
+			}
+		}
+		i = end
+
+		// Punctuation after the emoji attaches to the text before it
+		if next, _ := utf8.DecodeRuneInString(text[i:]); unicode.IsPunct(next) {
+			out = bytes.TrimRightFunc(out, unicode.IsSpace)
+		}
+	}
+
+	// Tidy the spaces around removed emoji
+	return strings.Join(strings.Fields(string(out)), " ")
+}
+
+// emojiName looks up an emoji, ignoring variation selectors and falling back
+// to its first rune, e.g. a thumbs up without its skin tone
+func emojiName(emoji string, names map[string]string) string {
+	if name, ok := names[emoji]; ok {
+		return name
+	}
+	plain := strings.Map(func(r rune) rune {
+		if r >= 0xFE00 && r <= 0xFE0F {
+			return -1
+		}
+		return r
+	}, emoji)
+	if name, ok := names[plain]; ok {
+		return name
+	}
+	first, size := utf8.DecodeRuneInString(plain)
+	if first == utf8.RuneError || size == len(plain) {
+		return ""
+	}
+	return names[string(first)]
+}
+
+// standalone reports whether text[start:end] is surrounded by spaces,
+// punctuation or the ends of text, so "x:D" isn't an emoticon
+func standalone(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return (start == 0 || unicode.IsSpace(before)) &&
+		(end == len(text) || unicode.IsSpace(after) || unicode.IsPunct(after))
+}
@@ -0,0 +1,69 @@
+package stages
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestHandleEmoji tests stripping and naming emoji and emoticons
+func TestHandleEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		mode EmojiMode
+		in   string
+		want string
+	}{
+		{"keep", EmojiKeep, "Great job 🎉", "Great job 🎉"},
+		{"strip", EmojiStrip, "Great job 🎉! See you :)", "Great job! See you"},
+		{"strip flag and family", EmojiStrip, "Hola 🇪🇸 from 👨‍👩‍👧 us", "Hola from us"},
+		{"verbalize", EmojiVerbalize, "That's funny 😂", "That's funny laughing emoji"},
+		{"verbalize repeated", EmojiVerbalize, "😂😂😂 stop", "laughing emoji stop"},
+		{"verbalize variation selector", EmojiVerbalize, "I ❤️ it", "I heart emoji it"},
+		{"verbalize skin tone", EmojiVerbalize, "Nice 👍🏽", "Nice thumbs up emoji"},
+		{"verbalize emoticon", EmojiVerbalize, "Thanks :) bye", "Thanks smiling emoji bye"},
+		{"unknown emoji stripped", EmojiVerbalize, "A 🦩 bird", "A bird"},
+		{"not an emoticon", EmojiVerbalize, "Note:D is a drive, f(x:)", "Note:D is a drive, f(x:)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := handleEmoji(tt.in, tt.mode, emojiNames); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTextProcessorEmoji tests emoji handling in the stage, with custom names
+func TestTextProcessorEmoji(t *testing.T) {
+	stage := NewTextProcessorStage(TextProcessorStageConfig{
+		Emoji:      EmojiVerbalize,
+		EmojiNames: map[string]string{"🎉": "confetti", "🔥": ""},
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "You did it 🎉!"}
+	input <- core.LLMEvent{Delta: " This is 🔥 😂."}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []string
+	for event := range output {
+		if llmEvent, ok := event.(core.LLMEvent); ok {
+			results = append(results, llmEvent.Delta)
+		}
+	}
+	want := []string{"You did it confetti!", "This is laughing emoji."}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %q, want %q", results, want)
+	}
+}
@@ -0,0 +1,48 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/creastat/infra/telemetry"
+)
+
+// namedProvider is the part of the provider interfaces used for fallback logging
+type namedProvider interface {
+	Name() string
+}
+
+// providerChain returns the primary provider followed by its fallbacks
+func providerChain[P any](primary P, fallbacks []P) []P {
+	chain := make([]P, 0, len(fallbacks)+1)
+	chain = append(chain, primary)
+	return append(chain, fallbacks...)
+}
+
+// withFallback starts a stream on each provider of the chain in order until
+// one succeeds, applying the retry policy to every provider. It returns the
+// index of the provider that served the stream, or the last error if all fail.
+func withFallback[P namedProvider, T any](ctx context.Context, chain []P, policy *RetryPolicy, logger telemetry.Logger, start func(ctx context.Context, provider P) (T, error)) (T, int, error) {
+	var result T
+	err := fmt.Errorf("no provider configured")
+
+	for i, provider := range chain {
+		result, err = withRetry(ctx, policy, logger, func(ctx context.Context) (T, error) {
+			return start(ctx, provider)
+		})
+		if err == nil {
+			if i > 0 {
+				logger.Info("Provider fallback served the request", telemetry.String("provider", provider.Name()), telemetry.Int("fallback", i))
+			}
+			return result, i, nil
+		}
+		if ctx.Err() != nil {
+			return result, i, err
+		}
+		if i < len(chain)-1 {
+			logger.Warn("Provider failed, falling back to next provider", telemetry.String("provider", provider.Name()), telemetry.Err(err))
+		}
+	}
+
+	return result, len(chain) - 1, err
+}
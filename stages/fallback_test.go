@@ -0,0 +1,128 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+// TestLLMStageFallsBackOnStartFailure tests that the next provider serves the
+// request when the primary cannot open a stream
+func TestLLMStageFallsBackOnStartFailure(t *testing.T) {
+	primary := &TestFlakyLLMProvider{failures: 100}
+	stage := NewLLMStage(LLMStageConfig{
+		Provider:  primary,
+		Fallbacks: []providers.LLMProvider{&TestNamedLLMProvider{name: "backup", responseText: "Hello there"}},
+	})
+
+	done := runLLMFallback(t, stage)
+
+	if primary.attempts != 1 {
+		t.Errorf("expected 1 attempt on the primary without retry policy, got %d", primary.attempts)
+	}
+	if done.FullText != "Hello there" || done.Provider != "backup" {
+		t.Errorf("unexpected DoneEvent: %+v", done)
+	}
+}
+
+// TestLLMStageFallsBackMidStream tests that a stream failing before any
+// output is replaced by the next provider without surfacing an error
+func TestLLMStageFallsBackMidStream(t *testing.T) {
+	stage := NewLLMStage(LLMStageConfig{
+		Provider:  &TestBrokenStreamLLMProvider{},
+		Fallbacks: []providers.LLMProvider{&TestNamedLLMProvider{name: "backup", responseText: "Recovered"}},
+	})
+
+	done := runLLMFallback(t, stage)
+
+	if done.FullText != "Recovered" || done.Provider != "backup" {
+		t.Errorf("unexpected DoneEvent: %+v", done)
+	}
+}
+
+// runLLMFallback runs the stage on a single input and returns its DoneEvent,
+// failing the test on any error event
+func runLLMFallback(t *testing.T, stage *LLMStage) core.DoneEvent {
+	t.Helper()
+
+	input := make(chan core.Event, 1)
+	output := make(chan core.Event, 100)
+	input <- core.STTEvent{Text: "hi"}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var done *core.DoneEvent
+	for event := range output {
+		if e, ok := event.(core.ErrorEvent); ok {
+			t.Errorf("unexpected error event after fallback: %v", e.Error)
+		}
+		if e, ok := event.(core.DoneEvent); ok {
+			done = &e
+		}
+	}
+	if done == nil {
+		t.Fatal("missing DoneEvent")
+	}
+	return *done
+}
+
+// TestNamedLLMProvider is a streaming provider with a configurable name
+type TestNamedLLMProvider struct {
+	name         string
+	responseText string
+}
+
+func (m *TestNamedLLMProvider) Name() string                 { return m.name }
+func (m *TestNamedLLMProvider) Type() providers.ProviderType { return "test" }
+func (m *TestNamedLLMProvider) Initialize(ctx context.Context, config providers.ProviderConfig) error {
+	return nil
+}
+func (m *TestNamedLLMProvider) Close() error                          { return nil }
+func (m *TestNamedLLMProvider) HealthCheck(ctx context.Context) error { return nil }
+func (m *TestNamedLLMProvider) Capabilities() []providers.Capability {
+	return []providers.Capability{providers.CapabilityLLM}
+}
+func (m *TestNamedLLMProvider) SupportsCapability(capability providers.Capability) bool {
+	return capability == providers.CapabilityLLM
+}
+func (m *TestNamedLLMProvider) ChatCompletion(ctx context.Context, req providers.ChatRequest) (*providers.ChatResponse, error) {
+	return &providers.ChatResponse{Content: m.responseText}, nil
+}
+func (m *TestNamedLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	return &TestChatStream{responseText: m.responseText}, nil
+}
+
+// TestBrokenStreamLLMProvider opens a stream that fails on the first receive
+type TestBrokenStreamLLMProvider struct {
+	TestStreamingLLMProvider
+}
+
+func (m *TestBrokenStreamLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	return &TestBrokenChatStream{}, nil
+}
+
+// TestBrokenChatStream fails every receive
+type TestBrokenChatStream struct{}
+
+func (s *TestBrokenChatStream) Send(ctx context.Context, data []byte) error {
+	return nil
+}
+
+func (s *TestBrokenChatStream) Receive(ctx context.Context) (*providers.ChatChunk, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func (s *TestBrokenChatStream) Close() error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// FuncStage is a stage built from a function applied to every event, for
+// small transformations that don't need a Stage type of their own. Create
+// one with MapStage or FilterStage.
+type FuncStage struct {
+	name string
+	fn   func(core.Event) []core.Event
+}
+
+// MapStage creates a stage that replaces each event with the events fn
+// returns: none to drop it, several to expand it. The stage is named "map";
+// use Named when a pipeline has more than one.
+func MapStage(fn func(core.Event) []core.Event) *FuncStage {
+	return &FuncStage{
+		name: "map",
+		fn:   fn,
+	}
+}
+
+// FilterStage creates a stage that passes on only the events predicate
+// accepts. The stage is named "filter"; use Named when a pipeline has more
+// than one.
+func FilterStage(predicate func(core.Event) bool) *FuncStage {
+	return &FuncStage{
+		name: "filter",
+		fn: func(event core.Event) []core.Event {
+			if predicate(event) {
+				return []core.Event{event}
+			}
+			return nil
+		},
+	}
+}
+
+// Named sets the stage name
+func (s *FuncStage) Named(name string) *FuncStage {
+	s.name = name
+	return s
+}
+
+// Name returns the stage name
+func (s *FuncStage) Name() string {
+	return s.name
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *FuncStage) InputTypes() []core.EventType {
+	// Function stages accept all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *FuncStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *FuncStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for _, out := range s.fn(event) {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case output <- out:
+				}
+			}
+		}
+	}
+}
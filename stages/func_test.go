@@ -0,0 +1,52 @@
+package stages
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
+)
+
+func TestFuncStages(t *testing.T) {
+	upper := MapStage(func(event core.Event) []core.Event {
+		if llm, ok := event.(core.LLMEvent); ok {
+			llm.Delta = strings.ToUpper(llm.Delta)
+			return []core.Event{llm}
+		}
+		return []core.Event{event}
+	})
+	dropInterim := FilterStage(func(event core.Event) bool {
+		stt, ok := event.(core.STTEvent)
+		return !ok || stt.IsFinal
+	})
+	duplicate := MapStage(func(event core.Event) []core.Event {
+		return []core.Event{event, event}
+	}).Named("duplicate")
+
+	p, err := pipeline.Linear(upper, dropInterim, duplicate)
+	if err != nil {
+		t.Fatalf("Linear failed: %v", err)
+	}
+
+	input := make(chan core.Event, 3)
+	input <- core.STTEvent{Text: "hel"}
+	input <- core.STTEvent{Text: "hello", IsFinal: true}
+	input <- core.LLMEvent{Delta: "hi"}
+	close(input)
+
+	var got []string
+	for event := range p.Execute(context.Background(), input) {
+		switch e := event.(type) {
+		case core.STTEvent:
+			got = append(got, e.Text)
+		case core.LLMEvent:
+			got = append(got, e.Delta)
+		}
+	}
+
+	if strings.Join(got, ",") != "hello,hello,HI,HI" {
+		t.Errorf("unexpected events %v", got)
+	}
+}
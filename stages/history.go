@@ -2,27 +2,104 @@ package stages
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/history"
 )
 
+// HistoryRole identifies who produced a history entry
+type HistoryRole string
+
+const (
+	HistoryRoleUser      HistoryRole = "user"
+	HistoryRoleAssistant HistoryRole = "assistant"
+)
+
+// HistoryEntry is one side of a conversation turn
+type HistoryEntry struct {
+	Role         HistoryRole
+	Content      string
+	TurnID       string    // Turn the entry belongs to, or the run's correlation ID outside turns
+	ResponseID   string    // Response ID of the turn, if any
+	Timestamp    time.Time // When the transcript or response was completed
+	InputTokens  int       // Prompt tokens, assistant entries only
+	OutputTokens int       // Completion tokens, assistant entries only
+	Interrupted  bool      // Response was cut short by barge-in, assistant entries only
+}
+
+// HistoryWriter persists conversation history
+type HistoryWriter interface {
+	WriteHistory(ctx context.Context, entry HistoryEntry) error
+}
+
+// HistoryWriterFunc adapts a function to the HistoryWriter interface
+type HistoryWriterFunc func(ctx context.Context, entry HistoryEntry) error
+
+// WriteHistory calls f
+func (f HistoryWriterFunc) WriteHistory(ctx context.Context, entry HistoryEntry) error {
+	return f(ctx, entry)
+}
+
+// StoreHistoryWriter writes history entries to a conversation in a
+// history.Store. Pair it with a ConversationMemory on the same store and
+// conversation to feed the recorded history back to the LLM.
+type StoreHistoryWriter struct {
+	Store          history.Store
+	ConversationID string
+}
+
+// WriteHistory implements HistoryWriter
+func (w StoreHistoryWriter) WriteHistory(ctx context.Context, entry HistoryEntry) error {
+	return w.Store.Append(ctx, w.ConversationID, history.Message{
+		Role:       string(entry.Role),
+		Content:    entry.Content,
+		TurnID:     entry.TurnID,
+		ResponseID: entry.ResponseID,
+		Tokens:     entry.OutputTokens,
+		CreatedAt:  entry.Timestamp,
+	})
+}
+
 // HistorySaver is a function that saves the assistant's response
+//
+// Deprecated: use HistoryWriter, which also receives user turns
 type HistorySaver func(ctx context.Context, content string) error
 
 // HistoryStageConfig holds configuration for HistoryStage
 type HistoryStageConfig struct {
-	Saver  HistorySaver
+	Writer HistoryWriter
+
+	// Saver receives assistant responses when Writer is nil
+	//
+	// Deprecated: use Writer
+	Saver HistorySaver
+
 	Logger telemetry.Logger
 }
 
-// HistoryStage intercepts the DoneEvent to save the conversation history
+// HistoryStage records the conversation while passing all events through.
+// Final STT transcripts become user entries when the turn's input ends, and
+// the FullText of a DoneEvent becomes the assistant entry. The LLM stage
+// consumes transcripts, so place the stage where both sides pass, e.g. on a
+// pipeline tap, or run one before and one after the LLM with a shared writer.
 type HistoryStage struct {
 	config HistoryStageConfig
 }
 
 // NewHistoryStage creates a new HistoryStage
 func NewHistoryStage(config HistoryStageConfig) *HistoryStage {
+	if config.Writer == nil && config.Saver != nil {
+		saver := config.Saver
+		config.Writer = HistoryWriterFunc(func(ctx context.Context, entry HistoryEntry) error {
+			if entry.Role != HistoryRoleAssistant {
+				return nil
+			}
+			return saver(ctx, entry.Content)
+		})
+	}
 	return &HistoryStage{
 		config: config,
 	}
@@ -36,12 +113,12 @@ func (s *HistoryStage) Name() string {
 // InputTypes returns the event types this stage accepts
 func (s *HistoryStage) InputTypes() []core.EventType {
 	// accepts all events effectively, but technically we only care about passthrough + done
-	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone, core.EventTypeAudio, core.EventTypeError}
+	return []core.EventType{core.EventTypeSTT, core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone, core.EventTypeAudio, core.EventTypeError}
 }
 
 // OutputTypes returns the event types this stage produces
 func (s *HistoryStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone, core.EventTypeAudio, core.EventTypeError}
+	return []core.EventType{core.EventTypeSTT, core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone, core.EventTypeAudio, core.EventTypeError}
 }
 
 // Process implements the Stage interface
@@ -50,6 +127,9 @@ func (s *HistoryStage) Process(ctx context.Context, input <-chan core.Event, out
 
 	logger.Debug("HistoryStage started")
 
+	var transcript []string
+	var transcriptMeta core.EventMeta
+
 	for event := range input {
 		// Pass through all events
 		select {
@@ -58,23 +138,68 @@ func (s *HistoryStage) Process(ctx context.Context, input <-chan core.Event, out
 		case output <- event:
 		}
 
-		// Intercept DoneEvent for saving
-		if doneEvent, ok := event.(core.DoneEvent); ok {
-			if doneEvent.FullText == "" {
-				continue
+		switch e := event.(type) {
+		case core.STTEvent:
+			if e.IsFinal && strings.TrimSpace(e.Text) != "" {
+				transcript = append(transcript, strings.TrimSpace(e.Text))
+				transcriptMeta = e.Meta
 			}
 
-			logger.Debug("Saving history", telemetry.Int("content_length", len(doneEvent.FullText)))
+		case core.DoneEvent:
+			// The user's input ends with the turn's first DoneEvent
+			if len(transcript) > 0 {
+				s.write(ctx, logger, newHistoryEntry(HistoryRoleUser, strings.Join(transcript, " "), transcriptMeta))
+				transcript = nil
+			}
 
-			// Execute saver
-			if err := s.config.Saver(ctx, doneEvent.FullText); err != nil {
-				logger.Error("Failed to save history", telemetry.Err(err))
-				// We don't stop the pipeline on save error, just log it
-			} else {
-				logger.Debug("History saved successfully")
+			if e.FullText == "" {
+				continue
 			}
+
+			entry := newHistoryEntry(HistoryRoleAssistant, e.FullText, e.Meta)
+			entry.OutputTokens = e.TokensUsed
+			if e.Usage != nil {
+				entry.InputTokens = e.Usage.InputTokens
+				entry.OutputTokens = e.Usage.OutputTokens
+			}
+			entry.Interrupted = e.Interrupted
+			s.write(ctx, logger, entry)
 		}
 	}
 
 	return nil
 }
+
+// newHistoryEntry creates an entry identified by the event's turn
+func newHistoryEntry(role HistoryRole, content string, meta core.EventMeta) HistoryEntry {
+	entry := HistoryEntry{
+		Role:       role,
+		Content:    content,
+		TurnID:     meta.Turn.TurnID,
+		ResponseID: meta.Turn.ResponseID,
+		Timestamp:  meta.Timestamp,
+	}
+	if entry.TurnID == "" {
+		entry.TurnID = meta.CorrelationID
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	return entry
+}
+
+// write saves an entry, logging failures without stopping the pipeline
+func (s *HistoryStage) write(ctx context.Context, logger telemetry.Logger, entry HistoryEntry) {
+	if s.config.Writer == nil {
+		return
+	}
+
+	logger.Debug("Saving history", telemetry.String("role", string(entry.Role)), telemetry.Int("content_length", len(entry.Content)))
+
+	if err := s.config.Writer.WriteHistory(ctx, entry); err != nil {
+		logger.Error("Failed to save history", telemetry.Err(err), telemetry.String("role", string(entry.Role)))
+		// We don't stop the pipeline on save error, just log it
+	} else {
+		logger.Debug("History saved successfully")
+	}
+}
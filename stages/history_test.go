@@ -0,0 +1,76 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestHistoryStage_RecordsBothTurns(t *testing.T) {
+	var entries []HistoryEntry
+	stage := NewHistoryStage(HistoryStageConfig{
+		Writer: HistoryWriterFunc(func(ctx context.Context, entry HistoryEntry) error {
+			entries = append(entries, entry)
+			return nil
+		}),
+		Logger: testLogger(),
+	})
+
+	turn := core.EventMeta{
+		Timestamp: time.Unix(100, 0),
+		Turn:      core.TurnContext{TurnID: "t1", ResponseID: "r1"},
+	}
+
+	input := make(chan core.Event, 6)
+	input <- core.STTEvent{Text: "what is", Meta: turn}
+	input <- core.STTEvent{Text: "what is", IsFinal: true, Meta: turn}
+	input <- core.STTEvent{Text: "the price", IsFinal: true, Meta: turn}
+	input <- core.LLMEvent{Delta: "Ten", Content: "Ten", Meta: turn}
+	input <- core.DoneEvent{FullText: "Ten euros.", TokensUsed: 4, Meta: turn, Usage: &core.UsageSummary{UsageTotals: core.UsageTotals{InputTokens: 20, OutputTokens: 5}}}
+	close(input)
+
+	output := make(chan core.Event, 6)
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(output) != 5 {
+		t.Errorf("expected all 5 events passed through, got %d", len(output))
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected user and assistant entries, got %+v", entries)
+	}
+	user, assistant := entries[0], entries[1]
+	if user.Role != HistoryRoleUser || user.Content != "what is the price" || user.TurnID != "t1" || !user.Timestamp.Equal(turn.Timestamp) {
+		t.Errorf("unexpected user entry %+v", user)
+	}
+	if assistant.Role != HistoryRoleAssistant || assistant.Content != "Ten euros." || assistant.ResponseID != "r1" ||
+		assistant.InputTokens != 20 || assistant.OutputTokens != 5 {
+		t.Errorf("unexpected assistant entry %+v", assistant)
+	}
+}
+
+func TestHistoryStage_SaverReceivesAssistantOnly(t *testing.T) {
+	var saved []string
+	stage := NewHistoryStage(HistoryStageConfig{
+		Saver: func(ctx context.Context, content string) error {
+			saved = append(saved, content)
+			return nil
+		},
+		Logger: testLogger(),
+	})
+
+	input := make(chan core.Event, 2)
+	input <- core.STTEvent{Text: "hi", IsFinal: true}
+	input <- core.DoneEvent{FullText: "Hello!"}
+	close(input)
+	if err := stage.Process(context.Background(), input, make(chan core.Event, 2)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if len(saved) != 1 || saved[0] != "Hello!" {
+		t.Errorf("expected only the response saved, got %v", saved)
+	}
+}
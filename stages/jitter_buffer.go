@@ -0,0 +1,121 @@
+package stages
+
+import (
+	"context"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// JitterBufferStageConfig holds configuration for JitterBufferStage
+type JitterBufferStageConfig struct {
+	// Lead is how far ahead of real-time playback audio may run, the amount
+	// the client is expected to buffer. Defaults to 200ms.
+	Lead time.Duration
+
+	// Encoding and SampleRate describe audio whose events don't carry them.
+	// Chunks whose duration can't be derived, such as compressed audio, are
+	// released at once.
+	Encoding   string
+	SampleRate int
+}
+
+// JitterBufferStage releases AudioEvents at real-time playback rate instead
+// of as fast as TTS produces them, so clients with small buffers are
+// neither overwhelmed nor starved. Each chunk's duration is derived from
+// its size, format and sample rate; a chunk is held until the audio sent
+// before it is within Lead of finishing playback. Other events pass
+// through in order.
+//
+// The stage applies backpressure while it waits, so upstream stages slow
+// down rather than buffering unbounded audio here.
+type JitterBufferStage struct {
+	config JitterBufferStageConfig
+}
+
+// NewJitterBufferStage creates a new jitter buffer stage
+func NewJitterBufferStage(config JitterBufferStageConfig) *JitterBufferStage {
+	if config.Lead <= 0 {
+		config.Lead = 200 * time.Millisecond
+	}
+	return &JitterBufferStage{config: config}
+}
+
+// Name returns the stage name
+func (s *JitterBufferStage) Name() string {
+	return "jitter_buffer"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *JitterBufferStage) InputTypes() []core.EventType {
+	// Jitter buffer accepts all event types, pacing only audio
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *JitterBufferStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// chunkDuration returns the playback duration of an audio chunk, or zero if
+// it can't be derived
+func (s *JitterBufferStage) chunkDuration(event core.AudioEvent) time.Duration {
+	encoding := event.Format
+	if encoding == "" {
+		encoding = s.config.Encoding
+	}
+	sampleRate := event.SampleRate
+	if sampleRate == 0 {
+		sampleRate = s.config.SampleRate
+	}
+	seconds := audioSeconds(int64(len(event.Data)), encoding, sampleRate)
+	if event.Channels > 1 {
+		seconds /= float64(event.Channels)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Process implements the Stage interface
+func (s *JitterBufferStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	// playedUntil is when the audio released so far finishes playing
+	var playedUntil time.Time
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+
+			if audio, ok := event.(core.AudioEvent); ok {
+				now := time.Now()
+				// The client ran dry; playback restarts from now
+				if playedUntil.Before(now) {
+					playedUntil = now
+				}
+				if wait := playedUntil.Sub(now) - s.config.Lead; wait > 0 {
+					timer.Reset(wait)
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-timer.C:
+					}
+				}
+				playedUntil = playedUntil.Add(s.chunkDuration(audio))
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- event:
+			}
+		}
+	}
+}
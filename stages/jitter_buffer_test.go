@@ -0,0 +1,60 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestJitterBufferStage_PacesAudio(t *testing.T) {
+	stage := NewJitterBufferStage(JitterBufferStageConfig{
+		Lead:       50 * time.Millisecond,
+		Encoding:   AudioEncodingPCM,
+		SampleRate: 16000,
+	})
+
+	// Five 100ms chunks of 16kHz PCM
+	input := make(chan core.Event, 6)
+	for i := 0; i < 5; i++ {
+		input <- core.AudioEvent{Data: make([]byte, 3200), SeqNum: uint64(i + 1)}
+	}
+	input <- core.DoneEvent{}
+	close(input)
+
+	output := make(chan core.Event, 6)
+	start := time.Now()
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	elapsed := time.Since(start)
+	close(output)
+
+	// The last chunk may start 50ms before the first four finish playing
+	if elapsed < 340*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected audio paced over ~350ms, took %v", elapsed)
+	}
+	if len(output) != 6 {
+		t.Errorf("expected all 6 events, got %d", len(output))
+	}
+}
+
+func TestJitterBufferStage_PassesUnknownDuration(t *testing.T) {
+	stage := NewJitterBufferStage(JitterBufferStageConfig{})
+
+	input := make(chan core.Event, 3)
+	for i := 0; i < 3; i++ {
+		input <- core.AudioEvent{Data: make([]byte, 32000), Format: "mp3", SampleRate: 16000}
+	}
+	close(input)
+
+	output := make(chan core.Event, 3)
+	start := time.Now()
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected compressed audio released at once, took %v", elapsed)
+	}
+}
@@ -0,0 +1,45 @@
+package stages
+
+import (
+	"context"
+	"strings"
+)
+
+// STTLanguageStream is implemented by STT streams that identify the spoken
+// language. It describes the chunk last returned by Receive and returns an
+// empty language when none has been identified yet.
+type STTLanguageStream interface {
+	DetectedLanguage() (language string, confidence float64)
+}
+
+// LanguageDetector identifies the language of a transcript. STTStage uses it
+// on final transcripts when the provider stream doesn't report the language.
+type LanguageDetector interface {
+	Detect(ctx context.Context, text string) (language string, confidence float64, err error)
+}
+
+// LanguageDetectorFunc adapts a function to the LanguageDetector interface
+type LanguageDetectorFunc func(ctx context.Context, text string) (string, float64, error)
+
+// Detect implements LanguageDetector
+func (f LanguageDetectorFunc) Detect(ctx context.Context, text string) (string, float64, error) {
+	return f(ctx, text)
+}
+
+// localized returns the value configured for a language, trying the full tag
+// before its primary subtag ("pt-BR", then "pt")
+func localized[V any](values map[string]V, language string) (V, bool) {
+	var zero V
+	if language == "" || len(values) == 0 {
+		return zero, false
+	}
+	if value, ok := values[language]; ok {
+		return value, true
+	}
+	primary, _, found := strings.Cut(language, "-")
+	if !found {
+		return zero, false
+	}
+	value, ok := values[primary]
+	return value, ok
+}
@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// LatencyStageConfig holds latency aggregator configuration
+type LatencyStageConfig struct {
+	ForwardMarks bool // Also pass LatencyMarkEvents downstream
+	Logger       telemetry.Logger
+}
+
+// LatencyStage collects the LatencyMarkEvents of built-in stages (first
+// transcript, first LLM token, first TTS audio) and attaches the turn's
+// latency summary to each DoneEvent it forwards. All other events pass
+// through unchanged.
+type LatencyStage struct {
+	config LatencyStageConfig
+}
+
+// NewLatencyStage creates a new latency aggregator stage
+func NewLatencyStage(config LatencyStageConfig) *LatencyStage {
+	return &LatencyStage{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *LatencyStage) Name() string {
+	return "latency"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *LatencyStage) InputTypes() []core.EventType {
+	// Latency aggregator accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *LatencyStage) OutputTypes() []core.EventType {
+	// Latency aggregator passes everything through
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *LatencyStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+	var summary core.LatencySummary
+
+	for event := range input {
+		switch e := event.(type) {
+		case core.LatencyMarkEvent:
+			summary = summary.Merge(core.LatencySummary{e.Mark: e.Elapsed})
+			if !s.config.ForwardMarks {
+				continue
+			}
+
+		case core.DoneEvent:
+			e.Latency = summary.Merge(e.Latency)
+			summary = nil
+			event = e
+			for mark, elapsed := range e.Latency {
+				logger.Info("Turn latency",
+					telemetry.String("mark", string(mark)),
+					telemetry.Int("elapsed_ms", int(elapsed.Milliseconds())))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestLatencyStageAttachesSummary tests that latency marks are consumed and
+// summarized on the DoneEvent of each turn
+func TestLatencyStageAttachesSummary(t *testing.T) {
+	stage := NewLatencyStage(LatencyStageConfig{})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LatencyMarkEvent{Mark: core.LatencyLLMFirstToken, Elapsed: 300 * time.Millisecond}
+	input <- core.LLMEvent{Delta: "hi"}
+	input <- core.LatencyMarkEvent{Mark: core.LatencyTTSFirstAudio, Elapsed: 200 * time.Millisecond}
+	input <- core.DoneEvent{FullText: "hi"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events without LatencyMarkEvents, got %d", len(events))
+	}
+	first := events[1].(core.DoneEvent)
+	if first.Latency[core.LatencyLLMFirstToken] != 300*time.Millisecond || first.Latency[core.LatencyTTSFirstAudio] != 200*time.Millisecond {
+		t.Errorf("unexpected latency summary: %v", first.Latency)
+	}
+
+	// The next turn starts from zero
+	if second := events[2].(core.DoneEvent); len(second.Latency) != 0 {
+		t.Errorf("expected no latency for the next turn, got %v", second.Latency)
+	}
+}
+
+// TestLLMStageFirstTokenMark tests that the LLM stage reports its first
+// token latency once per turn
+func TestLLMStageFirstTokenMark(t *testing.T) {
+	stage := NewLLMStage(LLMStageConfig{
+		Provider: &TestStreamingLLMProvider{responseText: "Hello there, how are you"},
+		Logger:   testLogger(),
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 100)
+	input <- core.LLMEvent{Delta: "hi"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	marks := 0
+	for event := range output {
+		if mark, ok := event.(core.LatencyMarkEvent); ok {
+			marks++
+			if mark.Mark != core.LatencyLLMFirstToken || mark.Elapsed <= 0 {
+				t.Errorf("unexpected latency mark: %+v", mark)
+			}
+		}
+	}
+	if marks != 1 {
+		t.Errorf("expected one latency mark, got %d", marks)
+	}
+}
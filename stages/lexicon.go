@@ -0,0 +1,177 @@
+package stages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lexicon holds user-supplied rewrites applied to text before synthesis.
+// Entries match whole words only.
+type Lexicon struct {
+	// Abbreviations map abbreviations to their spoken expansions, e.g.
+	// "approx." to "approximately". They are matched case-sensitively,
+	// override the built-in English ones and don't end sentences.
+	Abbreviations map[string]string `json:"abbreviations" yaml:"abbreviations"`
+
+	// Pronunciations map words a voice gets wrong to respellings it reads
+	// correctly, e.g. "nginx" to "engine x". They are matched ignoring case.
+	Pronunciations map[string]string `json:"pronunciations" yaml:"pronunciations"`
+}
+
+// ParseLexicon parses a lexicon from JSON or YAML
+func ParseLexicon(data []byte) (*Lexicon, error) {
+	var lexicon Lexicon
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &lexicon); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON lexicon: %w", err)
+		}
+	} else {
+		if err := yaml.Unmarshal(trimmed, &lexicon); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML lexicon: %w", err)
+		}
+	}
+
+	return &lexicon, nil
+}
+
+// LoadLexiconFile reads a lexicon from disk
+func LoadLexiconFile(path string) (*Lexicon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lexicon %q: %w", path, err)
+	}
+	return ParseLexicon(data)
+}
+
+// englishAbbreviationExpansions are the abbreviations ExpandAbbreviations
+// expands unless a Lexicon overrides them
+var englishAbbreviationExpansions = map[string]string{
+	"U.S.":  "United States",
+	"U.K.":  "United Kingdom",
+	"e.g.":  "for example",
+	"i.e.":  "that is",
+	"Dr.":   "Doctor",
+	"Mr.":   "Mister",
+	"Mrs.":  "Misses",
+	"Ms.":   "Miss",
+	"Prof.": "Professor",
+	"St.":   "Street",
+	"Ave.":  "Avenue",
+	"Blvd.": "Boulevard",
+	"etc.":  "et cetera",
+	"vs.":   "versus",
+	"No.":   "Number",
+	"Inc.":  "Incorporated",
+	"Ltd.":  "Limited",
+	"Co.":   "Company",
+	"Corp.": "Corporation",
+	"Jan.":  "January",
+	"Feb.":  "February",
+	"Mar.":  "March",
+	"Apr.":  "April",
+	"Aug.":  "August",
+	"Sept.": "September",
+	"Oct.":  "October",
+	"Nov.":  "November",
+	"Dec.":  "December",
+	"Mon.":  "Monday",
+	"Tue.":  "Tuesday",
+	"Wed.":  "Wednesday",
+	"Thu.":  "Thursday",
+	"Fri.":  "Friday",
+	"Sat.":  "Saturday",
+	"Sun.":  "Sunday",
+}
+
+// wordReplacer replaces whole words from a table
+type wordReplacer struct {
+	pattern      *regexp.Regexp
+	replacements map[string]string // By word, lowercased when matching ignores case
+	ignoreCase   bool
+}
+
+// newWordReplacer compiles a replacer for a table, or returns nil for an
+// empty one
+func newWordReplacer(replacements map[string]string, ignoreCase bool) *wordReplacer {
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	r := &wordReplacer{replacements: make(map[string]string, len(replacements)), ignoreCase: ignoreCase}
+	words := make([]string, 0, len(replacements))
+	for word, replacement := range replacements {
+		if ignoreCase {
+			word = strings.ToLower(word)
+		}
+		r.replacements[word] = replacement
+		words = append(words, regexp.QuoteMeta(word))
+	}
+	// Longer words first, so "U.S.A." wins over "U.S."
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+
+	flags := ""
+	if ignoreCase {
+		flags = "(?i)"
+	}
+	r.pattern = regexp.MustCompile(flags + strings.Join(words, "|"))
+	return r
+}
+
+// replace rewrites the whole words of text found in the table
+func (r *wordReplacer) replace(text string) string {
+	if r == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range r.pattern.FindAllStringIndex(text, -1) {
+		word := text[loc[0]:loc[1]]
+		if !wholeWord(text, loc[0], loc[1]) {
+			continue
+		}
+		if r.ignoreCase {
+			word = strings.ToLower(word)
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(r.replacements[word])
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// endsWith reports whether text ends with a word of the table
+func (r *wordReplacer) endsWith(text string) bool {
+	if r == nil {
+		return false
+	}
+	for word := range r.replacements {
+		if strings.HasSuffix(text, word) && wholeWord(text, len(text)-len(word), len(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wholeWord reports whether text[start:end] is not part of a longer word:
+// no letter or digit touches either end
+func wholeWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	first, _ := utf8.DecodeRuneInString(text[start:end])
+	last, _ := utf8.DecodeLastRuneInString(text[start:end])
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	return !(isWord(first) && isWord(before)) && !(isWord(last) && isWord(after))
+}
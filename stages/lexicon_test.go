@@ -0,0 +1,81 @@
+package stages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestLoadLexiconFile tests loading JSON and YAML lexicons
+func TestLoadLexiconFile(t *testing.T) {
+	want := &Lexicon{
+		Abbreviations:  map[string]string{"approx.": "approximately"},
+		Pronunciations: map[string]string{"nginx": "engine x"},
+	}
+	files := map[string]string{
+		"lexicon.json": `{"abbreviations": {"approx.": "approximately"}, "pronunciations": {"nginx": "engine x"}}`,
+		"lexicon.yaml": "abbreviations:\n  approx.: approximately\npronunciations:\n  nginx: engine x\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		lexicon, err := LoadLexiconFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(lexicon, want) {
+			t.Errorf("%s: got %+v, want %+v", name, lexicon, want)
+		}
+	}
+
+	if _, err := LoadLexiconFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+// TestTextProcessorLexicon tests that lexicon entries replace whole words
+// and that its abbreviations don't end sentences
+func TestTextProcessorLexicon(t *testing.T) {
+	stage := NewTextProcessorStage(TextProcessorStageConfig{
+		ExpandAbbreviations: true,
+		Lexicon: &Lexicon{
+			Abbreviations:  map[string]string{"approx.": "approximately", "Dr.": "Doctor"},
+			Pronunciations: map[string]string{"nginx": "engine x"},
+		},
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "It takes approx."}
+	input <- core.LLMEvent{Delta: " 5 minutes to set up Nginx for Dr. Who."}
+	input <- core.LLMEvent{Delta: " Mr. Smith agrees, but not the nginxes."}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []string
+	for event := range output {
+		if llmEvent, ok := event.(core.LLMEvent); ok {
+			results = append(results, llmEvent.Delta)
+		}
+	}
+	want := []string{
+		"It takes approximately 5 minutes to set up engine x for Doctor Who.",
+		"Mister Smith agrees, but not the nginxes.",
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %q, want %q", results, want)
+	}
+}
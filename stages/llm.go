@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
@@ -13,18 +14,58 @@ import (
 // LLMStageConfig holds LLM stage configuration
 type LLMStageConfig struct {
 	Provider            providers.LLMProvider
+	Fallbacks           []providers.LLMProvider          // Tried in order when the provider fails before producing output
+	Presets             map[string]providers.LLMProvider // Providers selectable by preset name with ConfigUpdateEvent
 	Model               string
 	Temperature         *float64
 	MaxTokens           *int
+	TopP                *float64
+	FrequencyPenalty    *float64 // Passed as the "frequency_penalty" option
+	PresencePenalty     *float64 // Passed as the "presence_penalty" option
+	Stop                []string // Stop sequences, passed as the "stop" option
+	Seed                *int     // Passed as the "seed" option for reproducible sampling
 	SystemPrompt        string
-	Context             string // RAG context
+	LocalizedPrompts    map[string]string // System prompt per language, selected by LanguageDetectedEvent
+	PromptProvider      PromptProvider    // Supplies the system prompt per turn, takes precedence over the static prompts
+	Language            string            // Session language selecting LocalizedPrompts until one is detected
+	Context             string            // RAG context
 	ConversationHistory []providers.Message
-	Logger              telemetry.Logger
+	HistoryProvider     ConversationHistoryProvider // Loads history per turn, takes precedence over ConversationHistory
+	Retry               *RetryPolicy                // Retries for starting the provider stream, nil disables
+	Limiter             *RateLimiter                // Rate limits shared across pipelines, nil disables
+
+	// CountTokens estimates tokens when the provider reports no usage, e.g.
+	// with the model's tokenizer (default: ~4 chars per token)
+	CountTokens func(text string) int
+
+	// Response budgets keep voice answers short: once the response reaches
+	// MaxResponseWords or MaxResponseChars it ends at the next sentence end,
+	// or SentenceWait later (default: 2 seconds) if none comes. Once
+	// ResponseTimeout has passed since the input ended it ends where it is,
+	// even while the provider stalls. The stage then stops reading the
+	// provider stream and finishes the turn as usual. Zero disables each
+	// budget.
+	MaxResponseWords int
+	MaxResponseChars int
+	ResponseTimeout  time.Duration
+	SentenceWait     time.Duration
+
+	// Speculation starts the completion from a confident interim transcript
+	// before the turn's input ends, see SpeculationConfig. Nil disables it.
+	Speculation *SpeculationConfig
+
+	// Tools are the server-side tools offered to the model, passed as the
+	// "tools" option. The calls it makes are emitted as ToolCallEvents for
+	// ToolStage when the stream supports StreamToolCaller.
+	Tools []ToolDefinition
+
+	Logger telemetry.Logger
 }
 
 // LLMStage represents an LLM processing stage
 type LLMStage struct {
-	config LLMStageConfig
+	config  LLMStageConfig
+	updates pendingConfig
 }
 
 // NewLLMStage creates a new LLM stage
@@ -46,18 +87,27 @@ func (s *LLMStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the event types this stage produces
 func (s *LLMStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone}
+	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeCitation, core.EventTypeLanguage, core.EventTypeUsage, core.EventTypeLatency, core.EventTypeToolCall, core.EventTypeDone}
 }
 
 // Process implements the Stage interface
 // It reads text from the input channel and streams LLM responses to the output channel
 func (s *LLMStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	s.applyPendingConfig()
+
 	logger := s.config.Logger.WithModule(s.Name())
 
 	logger.Info("LLMStage started processing")
 
 	// Collect all input text
 	var fullText string
+	language := s.config.Language // Detected spoken language, the session language until one is detected
+	var spec *speculation         // Completion started from an interim transcript
+	defer func() {
+		if spec != nil {
+			spec.discard()
+		}
+	}()
 	eventCount := 0
 	for event := range input {
 		eventCount++
@@ -66,11 +116,26 @@ func (s *LLMStage) Process(ctx context.Context, input <-chan core.Event, output
 			fullText += e.Delta
 			logger.Debug("Received text input message", telemetry.String("text", e.Delta))
 		case core.STTEvent:
+			if s.config.Speculation != nil {
+				spec = s.speculate(ctx, spec, fullText, e, language, logger)
+				break
+			}
 			fullText += e.Text
 			logger.Debug("Received STT input message", telemetry.String("text", e.Text))
+		case core.CitationEvent, core.UsageEvent, core.LatencyMarkEvent:
+			// Sources, upstream usage and latency are for downstream consumers, pass them through
+			output <- e
+		case core.LanguageDetectedEvent:
+			language = e.Language
+			output <- e
 		case core.ErrorEvent:
 			// Log error from upstream but don't propagate - continue processing with what we have
 			logger.Warn("Received error from upstream", telemetry.Err(e.Error))
+		case core.InterruptEvent:
+			// Barge-in before generation started: drop the collected input
+			logger.Info("Interrupted before generation", telemetry.String("reason", e.Reason))
+			output <- core.DoneEvent{Interrupted: true}
+			return nil
 		case core.DoneEvent:
 			logger.Info("Received DoneEvent from upstream, finishing input collection")
 			// Break the loop to start processing immediately
@@ -91,6 +156,28 @@ EndCollection:
 		return nil
 	}
 
+	// Keep the speculative completion if it answers the same question
+	if spec != nil {
+		if spec.language == language && s.config.Speculation.Matches(spec.text, trimmedText) {
+			logger.Info("Final transcript matches speculation, using speculative completion")
+			adopted := spec
+			spec = nil
+			return adopted.adopt(ctx, input, output)
+		}
+		logger.Info("Final transcript differs from speculation, restarting completion",
+			telemetry.String("speculated", spec.text), telemetry.String("final", trimmedText))
+		spec.discard()
+		spec = nil
+	}
+
+	return s.respond(ctx, input, output, language, trimmedText, logger)
+}
+
+// respond streams the completion for the user's text, ending with a
+// DoneEvent. An InterruptEvent on input cancels it.
+func (s *LLMStage) respond(ctx context.Context, input <-chan core.Event, output chan<- core.Event, language, trimmedText string, logger telemetry.Logger) error {
+	started := time.Now()
+
 	// Emit thinking status only after receiving the complete input
 	// Use a buffered send to ensure it's queued before we start streaming
 	select {
@@ -108,16 +195,26 @@ EndCollection:
 	messages := []providers.Message{}
 
 	// Add system prompt first (always at index 0)
-	if s.config.SystemPrompt != "" {
+	systemPrompt := s.systemPrompt(ctx, language, trimmedText, logger)
+	if systemPrompt != "" {
 		messages = append(messages, providers.Message{
 			Role:    "system",
-			Content: s.config.SystemPrompt,
+			Content: systemPrompt,
 		})
 	}
 
 	// Add conversation history if provided
-	if len(s.config.ConversationHistory) > 0 {
-		messages = append(messages, s.config.ConversationHistory...)
+	history := s.config.ConversationHistory
+	if s.config.HistoryProvider != nil {
+		loaded, err := s.config.HistoryProvider.RecentMessages(ctx, 0)
+		if err != nil {
+			logger.Warn("Failed to load conversation history", telemetry.Err(err))
+		} else {
+			history = loaded
+		}
+	}
+	if len(history) > 0 {
+		messages = append(messages, history...)
 	}
 
 	// Add context if provided (RAG context)
@@ -140,11 +237,40 @@ EndCollection:
 		Messages:    messages,
 		Temperature: s.config.Temperature,
 		MaxTokens:   s.config.MaxTokens,
+		TopP:        s.config.TopP,
+		Options:     s.requestOptions(),
 	}
 
-	// Stream chat completion
-	stream, err := s.config.Provider.StreamChatCompletion(ctx, req)
+	// Watch for barge-in while streaming; an interrupt cancels the provider stream
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	interrupted := watchInterrupt(streamCtx, input, cancelStream)
+
+	// The budget cancels the stream too, when its time runs out
+	budget := s.newResponseBudget(language, started, cancelStream)
+	defer budget.stop()
+
+	// Stream chat completion, falling back along the provider chain
+	chain := providerChain(s.config.Provider, s.config.Fallbacks)
+	startStream := func(ctx context.Context, provider providers.LLMProvider) (providers.ChatStream, error) {
+		return provider.StreamChatCompletion(ctx, req)
+	}
+	startStream, releaseStream := limitStart(s.config.Limiter, s.config.Model, startStream)
+	stream, served, err := withFallback(streamCtx, chain, s.config.Retry, logger, startStream)
 	if err != nil {
+		if isInterrupted(interrupted) {
+			output <- core.DoneEvent{Interrupted: true}
+			return nil
+		}
+		if budget.timedOut() {
+			logger.Info("LLM response budget ran out before the stream started")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- core.DoneEvent{}:
+			}
+			return nil
+		}
 		logger.Error("Failed to start LLM stream", telemetry.Err(err))
 		select {
 		case <-ctx.Done():
@@ -152,6 +278,7 @@ EndCollection:
 		case output <- core.ErrorEvent{
 			Error:     fmt.Errorf("failed to start LLM stream: %w", err),
 			Retryable: true,
+			Code:      classifyProviderError(err, core.ErrCodeLLMTimeout, core.ErrCodeLLMRateLimited),
 		}:
 		}
 		// Send done event and return without error to allow pipeline to continue
@@ -161,7 +288,11 @@ EndCollection:
 		}
 		return nil
 	}
-	defer stream.Close()
+	defer func() {
+		stream.Close()
+		releaseStream(stream)
+	}()
+	provider := chain[served].Name()
 
 	// Process stream and emit events
 	var fullResponse string
@@ -169,7 +300,37 @@ EndCollection:
 	chunkCount := 0
 
 	for {
-		chunk, err := stream.Receive(ctx)
+		chunk, err := stream.Receive(streamCtx)
+		if isInterrupted(interrupted) {
+			logger.Info("LLM stream interrupted", telemetry.Int("chunks_received", chunkCount))
+			tokensUsed = s.emitUsage(output, stream, provider, messages, fullResponse)
+			output <- core.DoneEvent{
+				FullText:    fullResponse,
+				TokensUsed:  tokensUsed,
+				Interrupted: true,
+				Provider:    provider,
+			}
+			return nil
+		}
+		if err != nil && chunkCount == 0 && served < len(chain)-1 && streamCtx.Err() == nil {
+			// Nothing reached the client yet, so the next provider can take over transparently
+			logger.Warn("LLM stream failed before output, falling back", telemetry.String("provider", provider), telemetry.Err(err))
+			next, offset, fallbackErr := withFallback(streamCtx, chain[served+1:], s.config.Retry, logger, startStream)
+			if fallbackErr == nil {
+				stream.Close()
+				releaseStream(stream)
+				stream = next
+				served += 1 + offset
+				provider = chain[served].Name()
+				continue
+			}
+			err = fallbackErr
+		}
+		if budget.timedOut() {
+			// The stream was cancelled mid-response, which ends as it is
+			logger.Info("LLM response budget ran out, ending response", telemetry.Int("chunks_received", chunkCount))
+			break
+		}
 		if err != nil {
 			logger.Error("Error receiving LLM chunk", telemetry.Err(err), telemetry.Int("chunks_received", chunkCount))
 			select {
@@ -178,12 +339,15 @@ EndCollection:
 			case output <- core.ErrorEvent{
 				Error:     fmt.Errorf("error receiving LLM chunk: %w", err),
 				Retryable: false,
+				Code:      classifyProviderError(err, core.ErrCodeLLMTimeout, core.ErrCodeLLMRateLimited),
 			}:
 			}
 			// Send done event with partial response and return without error to allow pipeline to continue
+			tokensUsed = s.emitUsage(output, stream, provider, messages, fullResponse)
 			output <- core.DoneEvent{
 				FullText:   fullResponse,
 				TokensUsed: tokensUsed,
+				Provider:   provider,
 			}
 			return nil
 		}
@@ -199,7 +363,14 @@ EndCollection:
 		}
 
 		chunkCount++
-		fullResponse += chunk.Content
+		delta, finished := budget.take(fullResponse, chunk.Content)
+		fullResponse += delta
+		if chunkCount == 1 {
+			output <- core.LatencyMarkEvent{
+				Mark:    core.LatencyLLMFirstToken,
+				Elapsed: time.Since(started),
+			}
+		}
 		logger.Trace("Received LLM chunk", telemetry.String("content", chunk.Content), telemetry.Int("chunk_number", chunkCount))
 
 		// Emit LLM event for each non-empty chunk from the provider
@@ -207,18 +378,151 @@ EndCollection:
 		case <-ctx.Done():
 			return ctx.Err()
 		case output <- core.LLMEvent{
-			Delta:   chunk.Content,
+			Delta:   delta,
 			Content: fullResponse,
 		}:
 		}
+
+		if finished {
+			// Stop reading; closing the stream asks the provider to stop
+			logger.Info("LLM response budget reached, ending response", telemetry.Int("chunks_received", chunkCount))
+			break
+		}
+	}
+
+	if err := s.emitToolCalls(ctx, output, stream, logger); err != nil {
+		return err
 	}
 
 	// Emit done event with final response
+	tokensUsed = s.emitUsage(output, stream, provider, messages, fullResponse)
 	logger.Info("Emitting done event", telemetry.String("full_response", fullResponse), telemetry.Int("tokens_used", tokensUsed))
 	output <- core.DoneEvent{
 		FullText:   fullResponse,
 		TokensUsed: tokensUsed,
+		Provider:   provider,
 	}
 
 	return nil
 }
+
+// requestOptions returns the generation parameters ChatRequest has no
+// field for, under the option names providers share
+func (s *LLMStage) requestOptions() map[string]any {
+	options := make(map[string]any)
+	if s.config.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *s.config.FrequencyPenalty
+	}
+	if s.config.PresencePenalty != nil {
+		options["presence_penalty"] = *s.config.PresencePenalty
+	}
+	if len(s.config.Stop) > 0 {
+		options["stop"] = s.config.Stop
+	}
+	if s.config.Seed != nil {
+		options["seed"] = *s.config.Seed
+	}
+	if len(s.config.Tools) > 0 {
+		options["tools"] = s.config.Tools
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// StreamUsageReporter is implemented by chat streams of providers that report
+// token usage, usually with their last chunk. Usage returns nil until the
+// usage is known.
+type StreamUsageReporter interface {
+	Usage() *providers.Usage
+}
+
+// StreamToolCaller is implemented by chat streams of providers that support
+// tool calling. ToolCalls returns the calls the model made, known once the
+// stream is done.
+type StreamToolCaller interface {
+	ToolCalls() []core.ToolCallEvent
+}
+
+// emitToolCalls sends the tool calls the model made for ToolStage
+func (s *LLMStage) emitToolCalls(ctx context.Context, output chan<- core.Event, stream providers.ChatStream, logger telemetry.Logger) error {
+	caller, ok := stream.(StreamToolCaller)
+	if !ok {
+		return nil
+	}
+	for _, call := range caller.ToolCalls() {
+		logger.Info("LLM called tool", telemetry.String("tool", call.ToolName), telemetry.String("tool_id", call.ToolID))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- call:
+		}
+	}
+	return nil
+}
+
+// emitUsage reports the tokens consumed by a request and returns their total.
+// The usage reported by the stream is used when available, otherwise tokens
+// are estimated from the text.
+func (s *LLMStage) emitUsage(output chan<- core.Event, stream providers.ChatStream, provider string, messages []providers.Message, response string) int {
+	var inputTokens, outputTokens int
+	if reporter, ok := stream.(StreamUsageReporter); ok {
+		if usage := reporter.Usage(); usage != nil {
+			inputTokens, outputTokens = usage.InputTokens, usage.OutputTokens
+		}
+	}
+
+	if inputTokens == 0 && outputTokens == 0 {
+		count := s.config.CountTokens
+		if count == nil {
+			count = estimateTokens
+		}
+		for _, msg := range messages {
+			inputTokens += count(msg.Content)
+		}
+		outputTokens = count(response)
+	}
+
+	output <- core.UsageEvent{
+		Provider:     provider,
+		Model:        s.config.Model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+	}
+	return inputTokens + outputTokens
+}
+
+// watchInterrupt consumes the remaining input until an InterruptEvent arrives,
+// then calls cancel and closes the returned channel. It stops when ctx is done.
+func watchInterrupt(ctx context.Context, input <-chan core.Event, cancel context.CancelFunc) <-chan struct{} {
+	interrupted := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-input:
+				if !ok {
+					return
+				}
+				if _, ok := event.(core.InterruptEvent); ok {
+					close(interrupted)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return interrupted
+}
+
+// isInterrupted reports whether an interrupt channel has been closed
+func isInterrupted(interrupted <-chan struct{}) bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
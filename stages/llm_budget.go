@@ -0,0 +1,115 @@
+package stages
+
+import (
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultSentenceWait bounds the wait for a sentence end once a response has
+// used up its length budget
+const defaultSentenceWait = 2 * time.Second
+
+// responseBudget ends a streamed response at the first sentence end after
+// it has used up its length budget, and cancels its stream once its time
+// budget, or the wait for that sentence end, runs out
+type responseBudget struct {
+	maxWords     int
+	maxChars     int
+	sentenceWait time.Duration
+	segmenter    Segmenter
+	cancel       func() // Cancels the provider stream
+
+	words  int
+	chars  int
+	inWord bool
+	spent  bool
+
+	deadline time.Time   // Zero while no time budget applies
+	timer    *time.Timer // Fires at deadline
+	expired  atomic.Bool
+}
+
+// newResponseBudget creates the budget of a response started at started,
+// whose stream cancel stops
+func (s *LLMStage) newResponseBudget(language string, started time.Time, cancel func()) *responseBudget {
+	budget := &responseBudget{
+		maxWords:     s.config.MaxResponseWords,
+		maxChars:     s.config.MaxResponseChars,
+		sentenceWait: s.config.SentenceWait,
+		segmenter:    segmenterFor(nil, language),
+		cancel:       cancel,
+	}
+	if budget.sentenceWait <= 0 {
+		budget.sentenceWait = defaultSentenceWait
+	}
+	if s.config.ResponseTimeout > 0 {
+		budget.endBy(started.Add(s.config.ResponseTimeout))
+	}
+	return budget
+}
+
+// endBy cancels the stream at deadline, unless it is due earlier already
+func (b *responseBudget) endBy(deadline time.Time) {
+	if !b.deadline.IsZero() && !deadline.Before(b.deadline) {
+		return
+	}
+	b.deadline = deadline
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.AfterFunc(time.Until(deadline), func() {
+		b.expired.Store(true)
+		b.cancel()
+	})
+}
+
+// timedOut reports whether the budget cancelled the stream
+func (b *responseBudget) timedOut() bool {
+	return b.expired.Load()
+}
+
+// stop releases the budget's timer
+func (b *responseBudget) stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// take returns the part of a chunk to emit after the response so far, and
+// whether the response ends with it
+func (b *responseBudget) take(response, chunk string) (string, bool) {
+	if b.maxWords <= 0 && b.maxChars <= 0 {
+		return chunk, false
+	}
+
+	for i, r := range chunk {
+		space := unicode.IsSpace(r)
+		if !b.spent {
+			b.chars++
+			if !space && !b.inWord {
+				b.words++
+			}
+			b.inWord = !space
+			b.spent = (b.maxWords > 0 && b.words >= b.maxWords) || (b.maxChars > 0 && b.chars >= b.maxChars)
+			if b.spent {
+				b.endBy(time.Now().Add(b.sentenceWait))
+			}
+		}
+		if !b.spent || space {
+			continue
+		}
+
+		// Sentences end before whitespace or, as far as is known, at the
+		// end of the chunk
+		end := i + utf8.RuneLen(r)
+		if next, _ := utf8.DecodeRuneInString(chunk[end:]); end < len(chunk) && !unicode.IsSpace(next) {
+			continue
+		}
+		if b.segmenter.SentenceEnd(response + chunk[:end]) {
+			return chunk[:end], true
+		}
+	}
+	return chunk, false
+}
@@ -0,0 +1,51 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// PromptRequest describes the turn a system prompt is requested for
+type PromptRequest struct {
+	Language string           // Session language, or the detected one
+	Text     string           // The user's message
+	Turn     core.TurnContext // Turn and user, when the run carries them
+}
+
+// PromptProvider supplies the system prompt at the start of each turn, e.g.
+// a per-user persona, instructions loaded from a database or a prompt variant
+// under A/B test. An empty prompt falls back to LocalizedPrompts and
+// SystemPrompt.
+type PromptProvider interface {
+	SystemPrompt(ctx context.Context, req PromptRequest) (string, error)
+}
+
+// PromptProviderFunc adapts a function to the PromptProvider interface
+type PromptProviderFunc func(ctx context.Context, req PromptRequest) (string, error)
+
+// SystemPrompt implements PromptProvider
+func (f PromptProviderFunc) SystemPrompt(ctx context.Context, req PromptRequest) (string, error) {
+	return f(ctx, req)
+}
+
+// systemPrompt returns the system prompt for a turn: the PromptProvider's,
+// then the localized one, then SystemPrompt. A failing provider falls back.
+func (s *LLMStage) systemPrompt(ctx context.Context, language, text string, logger telemetry.Logger) string {
+	if s.config.PromptProvider != nil {
+		turn, _ := core.TurnFromContext(ctx)
+		prompt, err := s.config.PromptProvider.SystemPrompt(ctx, PromptRequest{Language: language, Text: text, Turn: turn})
+		if err != nil {
+			logger.Warn("Prompt provider failed, using configured system prompt", telemetry.Err(err))
+		} else if prompt != "" {
+			return prompt
+		}
+	}
+
+	if prompt, ok := localized(s.config.LocalizedPrompts, language); ok {
+		logger.Info("Using localized system prompt", telemetry.String("language", language))
+		return prompt
+	}
+	return s.config.SystemPrompt
+}
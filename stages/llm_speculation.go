@@ -0,0 +1,176 @@
+package stages
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// DefaultSpeculationConfidence is the interim transcript confidence
+// speculation requires unless configured otherwise
+const DefaultSpeculationConfidence = 0.8
+
+// speculationBuffer is how many events a speculative completion produces
+// before it waits for the final transcript
+const speculationBuffer = 256
+
+// SpeculationConfig configures speculative completions. With speculation the
+// LLM stage starts a completion from an interim STTEvent, the query so far
+// plus the interim text, while the user finishes speaking. Its output is held
+// until the turn's DoneEvent: if the final query matches, the held output is
+// sent at once and the completion continues; otherwise it is cancelled and a
+// new completion starts from the final query. A later interim transcript that
+// no longer matches restarts the speculation.
+//
+// STTEvents then only drive speculation and the query is taken from
+// LLMEvents, as STTStage emits each final transcript both ways, so interim
+// transcripts must be routed to the LLM stage too.
+type SpeculationConfig struct {
+	// MinConfidence is the interim transcript confidence needed to speculate
+	// (default: DefaultSpeculationConfidence). Transcripts from providers
+	// that report no confidence are never used.
+	MinConfidence float64
+
+	// Match reports whether the final query asks the same as the speculated
+	// one. The default compares them ignoring case, punctuation and spacing.
+	Match func(speculated, final string) bool
+}
+
+// Matches reports whether a speculated query matches the final one
+func (c *SpeculationConfig) Matches(speculated, final string) bool {
+	if c.Match != nil {
+		return c.Match(speculated, final)
+	}
+	return normalizeTranscript(speculated) == normalizeTranscript(final)
+}
+
+// minConfidence returns the configured or default confidence threshold
+func (c *SpeculationConfig) minConfidence() float64 {
+	if c.MinConfidence > 0 {
+		return c.MinConfidence
+	}
+	return DefaultSpeculationConfidence
+}
+
+// normalizeTranscript lowercases text and drops punctuation and repeated
+// spaces, which transcripts often change when they become final
+func normalizeTranscript(text string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if word == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// speculation is a completion running ahead of the final transcript
+type speculation struct {
+	text     string
+	language string
+	started  time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	input  chan core.Event // The rest of the turn's input, once adopted
+	output chan core.Event // Held output
+	result chan error
+}
+
+// speculate starts, keeps or restarts a speculative completion for an
+// interim transcript following the final query text so far
+func (s *LLMStage) speculate(ctx context.Context, current *speculation, query string, e core.STTEvent, language string, logger telemetry.Logger) *speculation {
+	if e.IsFinal || e.Confidence < s.config.Speculation.minConfidence() {
+		return current
+	}
+	text := strings.TrimSpace(query + e.Text)
+	if text == "" {
+		return current
+	}
+	if current != nil {
+		if current.language == language && s.config.Speculation.Matches(current.text, text) {
+			return current
+		}
+		current.discard()
+	}
+
+	logger.Debug("Starting speculative completion", telemetry.String("text", text), telemetry.Float64("confidence", e.Confidence))
+	specCtx, cancel := context.WithCancel(ctx)
+	spec := &speculation{
+		text:     text,
+		language: language,
+		started:  time.Now(),
+		ctx:      specCtx,
+		cancel:   cancel,
+		input:    make(chan core.Event),
+		output:   make(chan core.Event, speculationBuffer),
+		result:   make(chan error, 1),
+	}
+	go func() {
+		spec.result <- s.respond(specCtx, spec.input, spec.output, language, text, logger)
+		close(spec.output)
+	}()
+	return spec
+}
+
+// discard cancels the completion and drops its output
+func (sp *speculation) discard() {
+	sp.cancel()
+	go func() {
+		for range sp.output {
+		}
+	}()
+}
+
+// adopt sends the completion's held output and streams the rest, relaying
+// the turn's remaining input to it so it sees interrupts. Latency marks are
+// rebased to the end of the turn's input, so a token that arrived before it
+// reports no latency.
+func (sp *speculation) adopt(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	defer sp.cancel()
+	ahead := time.Since(sp.started)
+
+	go func() {
+		for {
+			select {
+			case <-sp.ctx.Done():
+				return
+			case event, ok := <-input:
+				if !ok {
+					close(sp.input)
+					return
+				}
+				select {
+				case <-sp.ctx.Done():
+					return
+				case sp.input <- event:
+				}
+			}
+		}
+	}()
+
+	for event := range sp.output {
+		if mark, ok := event.(core.LatencyMarkEvent); ok {
+			mark.Elapsed = max(mark.Elapsed-ahead, 0)
+			event = mark
+		}
+		select {
+		case <-ctx.Done():
+			sp.discard()
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return <-sp.result
+}
@@ -2,11 +2,14 @@ package stages
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/pipelinetest"
 	providers "github.com/creastat/providers/core"
 	"pgregory.net/rapid"
 )
@@ -104,6 +107,364 @@ func TestPropertyLLMTextPipelineOutput(t *testing.T) {
 	})
 }
 
+// TestLLMStageInterrupt tests that an InterruptEvent cancels the in-flight
+// stream and finishes the turn with a partial, interrupted DoneEvent
+func TestLLMStageInterrupt(t *testing.T) {
+	stage := NewLLMStage(LLMStageConfig{
+		Provider: &TestBlockingLLMProvider{},
+		Model:    "gpt-4",
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 100)
+	input <- core.STTEvent{Text: "tell me a long story"}
+	input <- core.DoneEvent{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(output)
+		errChan <- stage.Process(ctx, input, output)
+	}()
+
+	var doneEvent *core.DoneEvent
+	for event := range output {
+		switch e := event.(type) {
+		case core.LLMEvent:
+			// First chunk arrived, the user barges in
+			input <- core.InterruptEvent{Reason: "user_speech"}
+		case core.DoneEvent:
+			doneEvent = &e
+		}
+	}
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("expected graceful finish, got %v", err)
+	}
+	if doneEvent == nil || !doneEvent.Interrupted {
+		t.Fatalf("expected interrupted DoneEvent, got %+v", doneEvent)
+	}
+	if doneEvent.FullText != "Once" {
+		t.Errorf("expected partial response %q, got %q", "Once", doneEvent.FullText)
+	}
+}
+
+// TestLLMStageSpeculation tests that a completion started from an interim
+// transcript is kept when the final transcript matches and restarted when it
+// differs
+func TestLLMStageSpeculation(t *testing.T) {
+	tests := []struct {
+		name     string
+		final    string
+		requests []string
+	}{
+		{"match", "What are your hours?", []string{"what are your hours"}},
+		{"mismatch", "What are your prices?", []string{"what are your hours", "What are your prices?"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &TestQueryingLLMProvider{
+				TestStreamingLLMProvider: TestStreamingLLMProvider{responseText: "We are open"},
+				requests:                 make(chan string, 4),
+			}
+			stage := NewLLMStage(LLMStageConfig{
+				Provider:    provider,
+				Speculation: &SpeculationConfig{},
+			})
+
+			input := make(chan core.Event, 10)
+			output := make(chan core.Event, 100)
+			input <- core.STTEvent{Text: "what are your hours", Confidence: 0.5}
+			input <- core.STTEvent{Text: "what are your hours", Confidence: 0.9}
+
+			errChan := make(chan error, 1)
+			go func() {
+				defer close(output)
+				errChan <- stage.Process(context.Background(), input, output)
+			}()
+
+			var requests []string
+			select {
+			case request := <-provider.requests:
+				requests = append(requests, request)
+			case <-time.After(time.Second):
+				t.Fatal("expected a speculative request before the turn ended")
+			}
+
+			input <- core.STTEvent{Text: tt.final, IsFinal: true, Confidence: 0.95}
+			input <- core.LLMEvent{Delta: tt.final}
+			input <- core.DoneEvent{}
+
+			var done *core.DoneEvent
+			for event := range output {
+				if e, ok := event.(core.DoneEvent); ok {
+					done = &e
+				}
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+			if done == nil || done.FullText != "We are open" {
+				t.Errorf("expected the completion, got %+v", done)
+			}
+
+			close(provider.requests)
+			for request := range provider.requests {
+				requests = append(requests, request)
+			}
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("expected requests %q, got %q", tt.requests, requests)
+			}
+		})
+	}
+}
+
+// TestLLMStageUsage tests that usage reported by the stream is used, and
+// that tokens are otherwise counted with the configured tokenizer
+func TestLLMStageUsage(t *testing.T) {
+	usageOf := func(events []core.Event) (core.UsageEvent, core.DoneEvent) {
+		var usage core.UsageEvent
+		var done core.DoneEvent
+		for _, event := range events {
+			switch e := event.(type) {
+			case core.UsageEvent:
+				usage = e
+			case core.DoneEvent:
+				done = e
+			}
+		}
+		return usage, done
+	}
+
+	llm := pipelinetest.NewFakeLLM("Hello ", "there")
+	llm.Usage = &providers.Usage{InputTokens: 42, OutputTokens: 7, TotalTokens: 49}
+	stage := NewLLMStage(LLMStageConfig{Provider: llm, Model: "m", Logger: testLogger()})
+
+	usage, done := usageOf(runTurn(t, stage, core.LLMEvent{Delta: "hi"}, core.DoneEvent{}))
+	if usage.InputTokens != 42 || usage.OutputTokens != 7 || done.TokensUsed != 49 {
+		t.Errorf("expected reported usage 42+7, got %+v and %d tokens used", usage, done.TokensUsed)
+	}
+
+	llm.Usage = nil
+	stage = NewLLMStage(LLMStageConfig{
+		Provider:     llm,
+		SystemPrompt: "Be brief",
+		CountTokens:  func(text string) int { return len(strings.Fields(text)) },
+		Logger:       testLogger(),
+	})
+	usage, done = usageOf(runTurn(t, stage, core.LLMEvent{Delta: "hi"}, core.DoneEvent{}))
+	if usage.InputTokens != 3 || usage.OutputTokens != 2 || done.TokensUsed != 5 {
+		t.Errorf("expected counted usage 3+2, got %+v and %d tokens used", usage, done.TokensUsed)
+	}
+}
+
+// TestLLMStageGenerationParameters tests that sampling parameters reach the
+// chat request
+func TestLLMStageGenerationParameters(t *testing.T) {
+	topP, frequency, presence, seed := 0.9, 0.5, -0.2, 7
+	llm := pipelinetest.NewFakeLLM("ok")
+	stage := NewLLMStage(LLMStageConfig{
+		Provider:         llm,
+		TopP:             &topP,
+		FrequencyPenalty: &frequency,
+		PresencePenalty:  &presence,
+		Stop:             []string{"\nUser:"},
+		Seed:             &seed,
+		Logger:           testLogger(),
+	})
+	runTurn(t, stage, core.LLMEvent{Delta: "hi"}, core.DoneEvent{})
+
+	requests := llm.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	if requests[0].TopP == nil || *requests[0].TopP != topP {
+		t.Errorf("expected top_p %v, got %v", topP, requests[0].TopP)
+	}
+	expected := map[string]any{
+		"frequency_penalty": 0.5,
+		"presence_penalty":  -0.2,
+		"stop":              []string{"\nUser:"},
+		"seed":              7,
+	}
+	if !reflect.DeepEqual(requests[0].Options, expected) {
+		t.Errorf("expected options %v, got %v", expected, requests[0].Options)
+	}
+
+	stage = NewLLMStage(LLMStageConfig{Provider: llm, Logger: testLogger()})
+	runTurn(t, stage, core.LLMEvent{Delta: "hi"}, core.DoneEvent{})
+	if options := llm.Requests()[1].Options; options != nil {
+		t.Errorf("expected no options by default, got %v", options)
+	}
+}
+
+// TestLLMStagePromptProvider tests that the prompt provider's system prompt
+// is used per turn, falling back to the configured one when it fails
+func TestLLMStagePromptProvider(t *testing.T) {
+	var requests []PromptRequest
+	fail := false
+	llm := pipelinetest.NewFakeLLM("ok")
+	stage := NewLLMStage(LLMStageConfig{
+		Provider:     llm,
+		SystemPrompt: "You are a helpful assistant",
+		Language:     "fr",
+		PromptProvider: PromptProviderFunc(func(ctx context.Context, req PromptRequest) (string, error) {
+			requests = append(requests, req)
+			if fail {
+				return "", fmt.Errorf("prompt store unavailable")
+			}
+			return "You are Ada, talking to " + req.Turn.UserID, nil
+		}),
+		Logger: testLogger(),
+	})
+
+	ctx := core.WithTurn(context.Background(), core.TurnContext{TurnID: "t1", UserID: "u42"})
+	input := make(chan core.Event, 2)
+	input <- core.LLMEvent{Delta: "hello"}
+	input <- core.DoneEvent{}
+	close(input)
+	output := make(chan core.Event, 20)
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	expected := PromptRequest{Language: "fr", Text: "hello", Turn: core.TurnContext{TurnID: "t1", UserID: "u42"}}
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0], expected) {
+		t.Errorf("expected prompt request %+v, got %+v", expected, requests)
+	}
+	if prompt := llm.Requests()[0].Messages[0].Content; prompt != "You are Ada, talking to u42" {
+		t.Errorf("expected provided system prompt, got %q", prompt)
+	}
+
+	fail = true
+	runTurn(t, stage, core.LLMEvent{Delta: "hello"}, core.DoneEvent{})
+	if prompt := llm.Requests()[1].Messages[0].Content; prompt != "You are a helpful assistant" {
+		t.Errorf("expected configured system prompt after failure, got %q", prompt)
+	}
+}
+
+// TestLLMStageResponseBudget tests that a response over its length budget
+// ends at the next sentence end
+func TestLLMStageResponseBudget(t *testing.T) {
+	llm := pipelinetest.NewFakeLLM("First sentence is here. ", "Second one ", "goes on. Third ", "sentence.")
+	tests := []struct {
+		name   string
+		config LLMStageConfig
+		deltas []string
+	}{
+		{"words", LLMStageConfig{MaxResponseWords: 6}, []string{"First sentence is here. ", "Second one ", "goes on."}},
+		{"chars", LLMStageConfig{MaxResponseChars: 10}, []string{"First sentence is here."}},
+		{"unlimited", LLMStageConfig{}, []string{"First sentence is here. ", "Second one ", "goes on. Third ", "sentence."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Provider = llm
+			tt.config.Logger = testLogger()
+			events := runTurn(t, NewLLMStage(tt.config), core.LLMEvent{Delta: "hi"}, core.DoneEvent{})
+
+			var deltas []string
+			var done core.DoneEvent
+			for _, event := range events {
+				switch e := event.(type) {
+				case core.LLMEvent:
+					deltas = append(deltas, e.Delta)
+				case core.DoneEvent:
+					done = e
+				}
+			}
+			if !reflect.DeepEqual(deltas, tt.deltas) {
+				t.Errorf("expected deltas %q, got %q", tt.deltas, deltas)
+			}
+			if done.FullText != strings.Join(tt.deltas, "") {
+				t.Errorf("expected full text %q, got %q", strings.Join(tt.deltas, ""), done.FullText)
+			}
+		})
+	}
+}
+
+// TestLLMStageResponseTimeout tests that a stalled response ends once its
+// time budget, or the wait for a sentence end, runs out
+func TestLLMStageResponseTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LLMStageConfig
+	}{
+		{"time", LLMStageConfig{ResponseTimeout: 20 * time.Millisecond}},
+		{"sentence wait", LLMStageConfig{MaxResponseWords: 1, SentenceWait: 20 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Provider = &TestBlockingLLMProvider{}
+			tt.config.Logger = testLogger()
+			events := runTurn(t, NewLLMStage(tt.config), core.LLMEvent{Delta: "hi"}, core.DoneEvent{})
+
+			var deltas []string
+			var done *core.DoneEvent
+			for _, event := range events {
+				switch e := event.(type) {
+				case core.LLMEvent:
+					deltas = append(deltas, e.Delta)
+				case core.ErrorEvent:
+					t.Errorf("unexpected error %v", e.Error)
+				case core.DoneEvent:
+					done = &e
+				}
+			}
+			if expected := []string{"Once"}; !reflect.DeepEqual(deltas, expected) {
+				t.Errorf("expected deltas %q, got %q", expected, deltas)
+			}
+			if done == nil || done.FullText != "Once" || done.Interrupted {
+				t.Errorf("expected a DoneEvent with the partial response, got %+v", done)
+			}
+		})
+	}
+}
+
+// TestQueryingLLMProvider reports the user message of each request
+type TestQueryingLLMProvider struct {
+	TestStreamingLLMProvider
+	requests chan string
+}
+
+func (m *TestQueryingLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	m.requests <- req.Messages[len(req.Messages)-1].Content
+	return m.TestStreamingLLMProvider.StreamChatCompletion(ctx, req)
+}
+
+// TestBlockingLLMProvider streams one chunk and then blocks until cancelled
+type TestBlockingLLMProvider struct {
+	TestStreamingLLMProvider
+}
+
+func (m *TestBlockingLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	return &TestBlockingChatStream{}, nil
+}
+
+// TestBlockingChatStream returns a single chunk, then waits for cancellation
+type TestBlockingChatStream struct {
+	sent bool
+}
+
+func (s *TestBlockingChatStream) Send(ctx context.Context, data []byte) error {
+	return nil
+}
+
+func (s *TestBlockingChatStream) Receive(ctx context.Context) (*providers.ChatChunk, error) {
+	if !s.sent {
+		s.sent = true
+		return &providers.ChatChunk{Content: "Once"}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *TestBlockingChatStream) Close() error {
+	return nil
+}
+
 // TestStreamingLLMProvider provides streaming responses for testing
 type TestStreamingLLMProvider struct {
 	responseText string
@@ -123,7 +484,7 @@ func (m *TestStreamingLLMProvider) SupportsCapability(capability providers.Capab
 	return capability == providers.CapabilityLLM
 }
 func (m *TestStreamingLLMProvider) ChatCompletion(ctx context.Context, req providers.ChatRequest) (*providers.ChatResponse, error) {
-	return nil, nil
+	return &providers.ChatResponse{Content: m.responseText}, nil
 }
 func (m *TestStreamingLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
 	return &TestChatStream{
@@ -0,0 +1,81 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// LocalizeStageConfig holds configuration for LocalizeStage
+type LocalizeStageConfig struct {
+	// Catalog resolves message keys (default: i18n.Default())
+	Catalog *i18n.Catalog
+
+	// Language is the session language Content is rendered in until a
+	// LanguageDetectedEvent switches it. Empty uses the catalog's fallback.
+	Language string
+}
+
+// LocalizeStage resolves keyed ServiceMessageEvents through a message
+// catalog, rendering Content in the session language and Localized in every
+// locale the catalog has. Other events, and messages already localized, pass
+// through unchanged. Without this stage, sinks resolve keyed messages in the
+// catalog's fallback language, see protocol.SetMessageCatalog.
+type LocalizeStage struct {
+	config LocalizeStageConfig
+}
+
+// NewLocalizeStage creates a new localization stage
+func NewLocalizeStage(config LocalizeStageConfig) *LocalizeStage {
+	if config.Catalog == nil {
+		config.Catalog = i18n.Default()
+	}
+	return &LocalizeStage{config: config}
+}
+
+// Name returns the stage name
+func (s *LocalizeStage) Name() string {
+	return "localize"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *LocalizeStage) InputTypes() []core.EventType {
+	// Localize stage accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *LocalizeStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *LocalizeStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	language := s.config.Language
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+
+			switch e := event.(type) {
+			case core.LanguageDetectedEvent:
+				language = e.Language
+			case core.ServiceMessageEvent:
+				if e.Localized == nil {
+					event = s.config.Catalog.Localize(e, language)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case output <- event:
+			}
+		}
+	}
+}
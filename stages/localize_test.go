@@ -0,0 +1,47 @@
+package stages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// TestLocalizeStage tests that keyed messages follow the detected language
+func TestLocalizeStage(t *testing.T) {
+	stage := NewLocalizeStage(LocalizeStageConfig{Language: "es"})
+
+	input := make(chan core.Event, 4)
+	output := make(chan core.Event, 4)
+	input <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgNotUnderstood, nil)
+	input <- core.LanguageDetectedEvent{Language: "fr-CA"}
+	input <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgNotUnderstood, nil)
+	input <- core.ServiceMessageEvent{Content: "free-form"}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var contents []string
+	for event := range output {
+		if e, ok := event.(core.ServiceMessageEvent); ok {
+			contents = append(contents, e.Content)
+		}
+	}
+	want := []string{
+		"No pude entender tu entrada. Por favor, intenta de nuevo.",
+		"Je n'ai pas pu comprendre votre entrée. Veuillez réessayer.",
+		"free-form",
+	}
+	if len(contents) != len(want) {
+		t.Fatalf("expected %v, got %v", want, contents)
+	}
+	for i := range want {
+		if contents[i] != want[i] {
+			t.Errorf("message %d: expected %q, got %q", i, want[i], contents[i])
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package stages
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TableMode selects how TextProcessorStage speaks markdown tables
+type TableMode string
+
+const (
+	TableRead TableMode = ""     // Read each row as "Header: cell, Header: cell."
+	TableSkip TableMode = "skip" // Say TablePlaceholder instead
+)
+
+// DefaultTablePlaceholder is said instead of a table with TableSkip
+const DefaultTablePlaceholder = "There's a table in the written answer."
+
+// lineKind classifies a markdown line by its start
+type lineKind int
+
+const (
+	lineUndecided lineKind = iota // Too little of the line has arrived
+	linePlain
+	lineItem  // Bullet or numbered list item
+	lineTable // Table row
+	lineFence // Code fence
+)
+
+var (
+	tableSeparatorRegex = regexp.MustCompile(`^:?-+:?$`)
+	horizontalRuleRegex = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+)
+
+// markdownFlattener rewrites streamed markdown tables and lists as prose. It
+// works line by line: list items become sentences as they stream, table rows
+// are held until complete and read with their column headers, and other lines
+// pass through untouched. Code blocks are left alone.
+type markdownFlattener struct {
+	tables      TableMode
+	placeholder string
+
+	line     strings.Builder // Held start of the current line
+	kind     lineKind
+	lastRune rune // Last non-space rune of the current list item
+	inCode   bool
+
+	inTable       bool
+	header        []string
+	headerPending bool // The first row arrived, its separator row hasn't
+}
+
+// newMarkdownFlattener creates a flattener
+func newMarkdownFlattener(tables TableMode, placeholder string) *markdownFlattener {
+	if placeholder == "" {
+		placeholder = DefaultTablePlaceholder
+	}
+	return &markdownFlattener{tables: tables, placeholder: placeholder}
+}
+
+// write consumes a chunk of the stream and returns the text ready to pass on
+func (f *markdownFlattener) write(text string) string {
+	var out strings.Builder
+	for {
+		line, rest, newline := strings.Cut(text, "\n")
+		f.feed(&out, line)
+		if !newline {
+			break
+		}
+		f.endLine(&out)
+		text = rest
+	}
+	return out.String()
+}
+
+// flush returns the held text at the end of the stream
+func (f *markdownFlattener) flush() string {
+	var out strings.Builder
+	if f.kind != lineUndecided || f.line.Len() > 0 {
+		f.endLine(&out)
+	}
+	f.endTable(&out)
+	return out.String()
+}
+
+// feed handles part of the current line
+func (f *markdownFlattener) feed(out *strings.Builder, text string) {
+	switch f.kind {
+	case linePlain:
+		out.WriteString(text)
+		return
+	case lineItem:
+		f.writeItem(out, text)
+		return
+	case lineTable, lineFence:
+		f.line.WriteString(text)
+		return
+	}
+
+	f.line.WriteString(text)
+	held := f.line.String()
+	kind, marker := f.classify(held)
+	switch kind {
+	case linePlain:
+		f.endTable(out)
+		out.WriteString(held)
+	case lineItem:
+		f.endTable(out)
+		f.writeItem(out, strings.TrimLeftFunc(held[marker:], unicode.IsSpace))
+	default:
+		f.kind = kind
+		return
+	}
+	f.kind = kind
+	f.line.Reset()
+}
+
+// classify decides what a line is from its start, returning the length of
+// a list item's marker
+func (f *markdownFlattener) classify(line string) (lineKind, int) {
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	indent := len(line) - len(trimmed)
+	if trimmed == "" {
+		return lineUndecided, 0
+	}
+
+	if strings.HasPrefix(trimmed, "```") {
+		return lineFence, 0
+	}
+	if strings.Trim(trimmed, "`") == "" {
+		return lineUndecided, 0
+	}
+	if f.inCode {
+		return linePlain, 0
+	}
+
+	first, size := utf8.DecodeRuneInString(trimmed)
+	switch {
+	case first == '|':
+		return lineTable, 0
+
+	case strings.ContainsRune("-*+_", first):
+		if size == len(trimmed) {
+			return lineUndecided, 0
+		}
+		next, _ := utf8.DecodeRuneInString(trimmed[size:])
+		if unicode.IsSpace(next) && first != '_' {
+			return lineItem, indent + size
+		}
+		// Maybe a horizontal rule, known once the line ends
+		if strings.Trim(trimmed, string(first)+" \t") == "" {
+			return lineUndecided, 0
+		}
+
+	case unicode.IsDigit(first):
+		// Numbered items, "1. " or "1) ", up to three digits so a year
+		// starting a line isn't mistaken for one
+		digits := len(trimmed) - len(strings.TrimLeftFunc(trimmed, unicode.IsDigit))
+		if digits > 3 {
+			break
+		}
+		rest := trimmed[digits:]
+		switch {
+		case rest == "":
+			return lineUndecided, 0
+		case rest[0] != '.' && rest[0] != ')':
+			break
+		case len(rest) == 1:
+			return lineUndecided, 0
+		case rest[1] == ' ' || rest[1] == '\t':
+			return lineItem, indent + digits + 1
+		}
+	}
+	return linePlain, 0
+}
+
+// writeItem writes part of a list item
+func (f *markdownFlattener) writeItem(out *strings.Builder, text string) {
+	if trimmed := strings.TrimRightFunc(text, unicode.IsSpace); trimmed != "" {
+		f.lastRune, _ = utf8.DecodeLastRuneInString(trimmed)
+	}
+	out.WriteString(text)
+}
+
+// endLine finishes the current line
+func (f *markdownFlattener) endLine(out *strings.Builder) {
+	held := f.line.String()
+	switch f.kind {
+	case lineUndecided:
+		if strings.TrimSpace(held) == "" {
+			f.endTable(out)
+		}
+		if !horizontalRuleRegex.MatchString(held) {
+			out.WriteString(held)
+		}
+	case lineItem:
+		// Each item is a sentence of its own
+		if f.lastRune != 0 && !strings.ContainsRune(".!?:;。！？", f.lastRune) {
+			out.WriteString(".")
+		}
+	case lineFence:
+		f.endTable(out)
+		f.inCode = !f.inCode
+		out.WriteString(held)
+	case lineTable:
+		f.tableRow(out, held)
+	}
+	out.WriteString("\n")
+
+	f.kind = lineUndecided
+	f.line.Reset()
+	f.lastRune = 0
+}
+
+// tableRow reads a table row, holding back the first until it is known to
+// be the header
+func (f *markdownFlattener) tableRow(out *strings.Builder, row string) {
+	row = strings.Trim(strings.TrimSpace(row), "|")
+	cells := strings.Split(row, "|")
+	separator := true
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+		separator = separator && tableSeparatorRegex.MatchString(cells[i])
+	}
+
+	if !f.inTable {
+		f.inTable = true
+		if f.tables == TableSkip {
+			out.WriteString(f.placeholder)
+			return
+		}
+		f.header = cells
+		f.headerPending = true
+		return
+	}
+	if f.tables == TableSkip {
+		return
+	}
+
+	if separator {
+		f.headerPending = false
+		return
+	}
+	if f.headerPending {
+		// No separator row, so the first row was data
+		out.WriteString(tableSentence(nil, f.header) + " ")
+		f.header = nil
+		f.headerPending = false
+	}
+	out.WriteString(tableSentence(f.header, cells))
+}
+
+// endTable finishes a table, reading a first row still held back
+func (f *markdownFlattener) endTable(out *strings.Builder) {
+	if f.headerPending {
+		out.WriteString(tableSentence(nil, f.header) + "\n")
+	}
+	f.inTable = false
+	f.header = nil
+	f.headerPending = false
+}
+
+// tableSentence reads a row as a sentence, naming each cell by its header
+func tableSentence(header, cells []string) string {
+	parts := make([]string, 0, len(cells))
+	for i, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		if i < len(header) && header[i] != "" {
+			cell = header[i] + ": " + cell
+		}
+		parts = append(parts, cell)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	sentence := strings.Join(parts, ", ")
+	if last, _ := utf8.DecodeLastRuneInString(sentence); !strings.ContainsRune(".!?", last) {
+		sentence += "."
+	}
+	return sentence
+}
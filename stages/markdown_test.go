@@ -0,0 +1,109 @@
+package stages
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestMarkdownFlattener tests flattening lists and tables, streamed a few
+// bytes at a time
+func TestMarkdownFlattener(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables TableMode
+		in     string
+		want   string
+	}{
+		{
+			name: "bullets",
+			in:   "You need:\n- flour\n* two eggs!\n  + milk\n",
+			want: "You need:\nflour.\ntwo eggs!\nmilk.\n",
+		},
+		{
+			name: "numbered",
+			in:   "1. Preheat the oven\n2) Mix\n2024. A year\n1.5 liters\n",
+			want: "Preheat the oven.\nMix.\n2024. A year\n1.5 liters\n",
+		},
+		{
+			name: "not lists",
+			in:   "-5 degrees\n**Bold** text\n---\nThe end",
+			want: "-5 degrees\n**Bold** text\n\nThe end\n",
+		},
+		{
+			name: "table",
+			in:   "Plans:\n| Plan | Price |\n|:---|---:|\n| Basic | $5 |\n| Pro | |\nThat's all.",
+			want: "Plans:\n\n\nPlan: Basic, Price: $5.\nPlan: Pro.\nThat's all.\n",
+		},
+		{
+			name: "table without header",
+			in:   "| a | b |\n| c | d |\n",
+			want: "\na, b. c, d.\n",
+		},
+		{
+			name: "header only at end",
+			in:   "| a | b |",
+			want: "\na, b.\n",
+		},
+		{
+			name:   "skipped table",
+			tables: TableSkip,
+			in:     "| Plan | Price |\n|---|---|\n| Basic | $5 |\nDone.",
+			want:   DefaultTablePlaceholder + "\n\n\nDone.\n",
+		},
+		{
+			name: "code block",
+			in:   "```\n- x\n| y |\n```\n- z\n",
+			want: "```\n- x\n| y |\n```\nz.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMarkdownFlattener(tt.tables, "")
+			var got strings.Builder
+			for in := tt.in; in != ""; {
+				n := min(3, len(in))
+				got.WriteString(f.write(in[:n]))
+				in = in[n:]
+			}
+			got.WriteString(f.flush())
+			if got.String() != tt.want {
+				t.Errorf("got %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestTextProcessorMarkdownLists tests that lists and tables reach TTS as
+// sentences
+func TestTextProcessorMarkdownLists(t *testing.T) {
+	stage := NewTextProcessorStage(TextProcessorStageConfig{StripMarkdown: true})
+
+	input := make(chan core.Event, 20)
+	output := make(chan core.Event, 20)
+	for _, delta := range []string{"Options", ":\n", "- **Basic", "** plan\n", "- Pro", " plan\n\n| Plan", " | Price |\n|---|---|\n", "| Basic | 5 |\n"} {
+		input <- core.LLMEvent{Delta: delta}
+	}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []string
+	for event := range output {
+		if llmEvent, ok := event.(core.LLMEvent); ok {
+			results = append(results, llmEvent.Delta)
+		}
+	}
+	want := []string{"Options:\nBasic plan.", "Pro plan.", "Plan: Basic, Price: 5."}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %q, want %q", results, want)
+	}
+}
@@ -0,0 +1,445 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/history"
+	providers "github.com/creastat/providers/core"
+)
+
+// MemoryTrimming selects how ConversationMemory enforces its token budget
+type MemoryTrimming string
+
+const (
+	// MemoryTrimOldest drops the oldest messages first
+	MemoryTrimOldest MemoryTrimming = "oldest"
+
+	// MemoryTrimSummarize folds the oldest messages into a summary message
+	MemoryTrimSummarize MemoryTrimming = "summarize"
+)
+
+// MemoryRole selects which side of a turn a MemoryStage records
+type MemoryRole string
+
+const (
+	// MemoryRoleUser records user input; place the stage before the LLM
+	MemoryRoleUser MemoryRole = "user"
+
+	// MemoryRoleAssistant records the response; place the stage after the LLM
+	MemoryRoleAssistant MemoryRole = "assistant"
+)
+
+// defaultSummaryPrompt instructs the LLM to compress earlier conversation turns
+const defaultSummaryPrompt = `Summarize the conversation below in a few sentences.
+Keep names, facts, decisions, and open questions. Keep the conversation's language.
+Reply with the summary only.`
+
+// summaryPrefix marks the synthetic message holding summarized history
+const summaryPrefix = "Summary of the earlier conversation: "
+
+// ConversationSummarizer compresses messages into a short summary
+type ConversationSummarizer interface {
+	Summarize(ctx context.Context, messages []providers.Message) (string, error)
+}
+
+// LLMConversationSummarizer summarizes history with an LLM
+type LLMConversationSummarizer struct {
+	Provider    providers.LLMProvider
+	Model       string
+	Prompt      string // Defaults to a built-in summary instruction
+	Temperature *float64
+}
+
+// Summarize implements ConversationSummarizer
+func (c *LLMConversationSummarizer) Summarize(ctx context.Context, messages []providers.Message) (string, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+
+	var transcript strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	resp, err := c.Provider.ChatCompletion(ctx, providers.ChatRequest{
+		Model: c.Model,
+		Messages: []providers.Message{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: transcript.String()},
+		},
+		Temperature: c.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	if resp == nil {
+		return "", fmt.Errorf("failed to summarize conversation: empty response")
+	}
+
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// ConversationMemoryConfig holds conversation memory configuration
+type ConversationMemoryConfig struct {
+	// MaxTokens is the history token budget; 0 disables trimming
+	MaxTokens int
+
+	// Trimming selects the trimming strategy (default: oldest)
+	Trimming MemoryTrimming
+
+	// Summarizer is required for summarize trimming; without it memory
+	// falls back to dropping the oldest messages
+	Summarizer ConversationSummarizer
+
+	// CountTokens estimates the tokens of a message (default: ~4 chars per token)
+	CountTokens func(text string) int
+
+	// Store keeps the conversation instead of process memory, so it can be
+	// shared with a HistoryStage or across processes. Stored messages are
+	// never dropped: the token budget then limits what RecentMessages
+	// returns, and summarize trimming replaces stored messages.
+	Store history.Store
+
+	// ConversationID identifies the conversation in Store
+	ConversationID string
+
+	Logger telemetry.Logger
+}
+
+// ConversationMemory stores the messages of a conversation within a token
+// budget. It is safe for concurrent use and implements
+// ConversationHistoryProvider, so it can feed LLMStage and RAG condensation.
+type ConversationMemory struct {
+	config ConversationMemoryConfig
+
+	mu          sync.Mutex
+	messages    []providers.Message
+	pendingUser string
+}
+
+// NewConversationMemory creates an empty conversation memory
+func NewConversationMemory(config ConversationMemoryConfig) *ConversationMemory {
+	if config.Trimming == "" {
+		config.Trimming = MemoryTrimOldest
+	}
+	if config.CountTokens == nil {
+		config.CountTokens = estimateTokens
+	}
+	return &ConversationMemory{
+		config: config,
+	}
+}
+
+// estimateTokens approximates token count as one token per four characters
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// RecentMessages implements ConversationHistoryProvider; limit <= 0 returns all
+func (m *ConversationMemory) RecentMessages(ctx context.Context, limit int) ([]providers.Message, error) {
+	if m.config.Store != nil {
+		return m.loadStored(ctx, limit)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := m.messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return append([]providers.Message(nil), messages...), nil
+}
+
+// Append adds messages and trims history to the token budget
+func (m *ConversationMemory) Append(ctx context.Context, messages ...providers.Message) {
+	if m.config.Store != nil {
+		m.appendStored(ctx, messages)
+		return
+	}
+
+	m.mu.Lock()
+	m.messages = append(m.messages, messages...)
+	m.mu.Unlock()
+
+	m.trim(ctx)
+}
+
+// Tokens returns the estimated token count of the stored history
+func (m *ConversationMemory) Tokens() int {
+	if m.config.Store != nil {
+		messages, _ := m.loadStored(context.Background(), 0)
+		total := 0
+		for _, msg := range messages {
+			total += m.config.CountTokens(msg.Content)
+		}
+		return total
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens(m.messages)
+}
+
+// Reset clears the stored history
+func (m *ConversationMemory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+	m.pendingUser = ""
+
+	if m.config.Store != nil {
+		if err := m.config.Store.Clear(context.Background(), m.config.ConversationID); err != nil {
+			m.config.Logger.WithModule("memory").Warn("Failed to clear stored history", telemetry.Err(err))
+		}
+	}
+}
+
+// appendStored adds messages to the store and summarizes it when the
+// conversation exceeds the token budget
+func (m *ConversationMemory) appendStored(ctx context.Context, messages []providers.Message) {
+	logger := m.config.Logger.WithModule("memory")
+
+	stored := make([]history.Message, len(messages))
+	for i, msg := range messages {
+		stored[i] = history.Message{Role: msg.Role, Content: msg.Content, Tokens: m.config.CountTokens(msg.Content)}
+	}
+	if err := m.config.Store.Append(ctx, m.config.ConversationID, stored...); err != nil {
+		logger.Warn("Failed to store history", telemetry.Err(err))
+		return
+	}
+
+	if m.config.MaxTokens <= 0 || m.config.Trimming != MemoryTrimSummarize || m.config.Summarizer == nil {
+		return
+	}
+
+	all, err := m.config.Store.LoadRecent(ctx, m.config.ConversationID, 0)
+	if err != nil {
+		logger.Warn("Failed to load history for summarization", telemetry.Err(err))
+		return
+	}
+	total := 0
+	for _, msg := range all {
+		total += m.config.CountTokens(msg.Content)
+	}
+	if total <= m.config.MaxTokens || len(all) < 2 {
+		return
+	}
+
+	// Like in-memory trimming, fold the older half into a summary
+	err = m.config.Store.Summarize(ctx, m.config.ConversationID, len(all)-len(all)/2, func(ctx context.Context, older []history.Message) (string, error) {
+		summary, err := m.config.Summarizer.Summarize(ctx, toProviderMessages(older))
+		if err != nil {
+			return "", err
+		}
+		return summaryPrefix + summary, nil
+	})
+	if err != nil {
+		logger.Warn("Failed to summarize stored history", telemetry.Err(err))
+	}
+}
+
+// loadStored reads recent messages from the store, dropping the oldest ones
+// beyond the token budget
+func (m *ConversationMemory) loadStored(ctx context.Context, limit int) ([]providers.Message, error) {
+	stored, err := m.config.Store.LoadRecent(ctx, m.config.ConversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	messages := toProviderMessages(stored)
+
+	if m.config.MaxTokens > 0 {
+		m.mu.Lock()
+		for len(messages) > 1 && m.tokens(messages) > m.config.MaxTokens {
+			messages = messages[1:]
+		}
+		m.mu.Unlock()
+	}
+	return messages, nil
+}
+
+// toProviderMessages converts stored messages to LLM messages
+func toProviderMessages(stored []history.Message) []providers.Message {
+	messages := make([]providers.Message, len(stored))
+	for i, msg := range stored {
+		messages[i] = providers.Message{Role: msg.Role, Content: msg.Content}
+	}
+	return messages
+}
+
+// setPendingUser records the user side of the turn in progress; it becomes
+// part of the history only once the turn completes
+func (m *ConversationMemory) setPendingUser(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pendingUser = text
+}
+
+// commitTurn appends the pending user message and the assistant response
+func (m *ConversationMemory) commitTurn(ctx context.Context, response string) {
+	m.mu.Lock()
+	user := m.pendingUser
+	m.pendingUser = ""
+	m.mu.Unlock()
+
+	var messages []providers.Message
+	if user != "" {
+		messages = append(messages, providers.Message{Role: "user", Content: user})
+	}
+	if response != "" {
+		messages = append(messages, providers.Message{Role: "assistant", Content: response})
+	}
+	if len(messages) > 0 {
+		m.Append(ctx, messages...)
+	}
+}
+
+// tokens counts the tokens of messages; the caller must hold m.mu
+func (m *ConversationMemory) tokens(messages []providers.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += m.config.CountTokens(msg.Content)
+	}
+	return total
+}
+
+// trim enforces the token budget using the configured strategy
+func (m *ConversationMemory) trim(ctx context.Context) {
+	if m.config.MaxTokens <= 0 {
+		return
+	}
+
+	logger := m.config.Logger.WithModule("memory")
+
+	if m.config.Trimming == MemoryTrimSummarize && m.config.Summarizer != nil {
+		if err := m.summarizeOldest(ctx); err != nil {
+			logger.Warn("Failed to summarize history, dropping oldest messages", telemetry.Err(err))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dropped := 0
+	for len(m.messages) > 1 && m.tokens(m.messages) > m.config.MaxTokens {
+		m.messages = m.messages[1:]
+		dropped++
+	}
+	if dropped > 0 {
+		logger.Debug("Trimmed conversation history", telemetry.Int("dropped", dropped), telemetry.Int("remaining", len(m.messages)))
+	}
+}
+
+// summarizeOldest replaces the older half of an over-budget history with a
+// single summary message
+func (m *ConversationMemory) summarizeOldest(ctx context.Context) error {
+	m.mu.Lock()
+	if m.tokens(m.messages) <= m.config.MaxTokens || len(m.messages) < 2 {
+		m.mu.Unlock()
+		return nil
+	}
+	split := len(m.messages) / 2
+	oldest := append([]providers.Message(nil), m.messages[:split]...)
+	m.mu.Unlock()
+
+	// Summarize without holding the lock; the LLM call may be slow
+	summary, err := m.config.Summarizer.Summarize(ctx, oldest)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// History only grows at the end, so the summarized prefix is still in place
+	// unless it was reset meanwhile
+	if len(m.messages) < split || m.messages[0] != oldest[0] {
+		return nil
+	}
+	m.messages = append([]providers.Message{{Role: "system", Content: summaryPrefix + summary}}, m.messages[split:]...)
+	return nil
+}
+
+// MemoryStageConfig holds memory stage configuration
+type MemoryStageConfig struct {
+	Memory *ConversationMemory
+	Role   MemoryRole
+	Logger telemetry.Logger
+}
+
+// MemoryStage records conversation turns into a ConversationMemory while
+// passing all events through. Use two stages sharing one memory: a user stage
+// before the LLM and an assistant stage after it. The turn is committed when
+// the assistant response completes, so the LLM never sees the current user
+// message twice.
+type MemoryStage struct {
+	config MemoryStageConfig
+}
+
+// NewMemoryStage creates a new memory stage
+func NewMemoryStage(config MemoryStageConfig) *MemoryStage {
+	return &MemoryStage{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *MemoryStage) Name() string {
+	return "memory"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *MemoryStage) InputTypes() []core.EventType {
+	// Memory stage passes through all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *MemoryStage) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeSTT, core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone, core.EventTypeAudio, core.EventTypeError}
+}
+
+// Process implements the Stage interface
+func (s *MemoryStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	var userText strings.Builder
+
+	for event := range input {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+
+		switch e := event.(type) {
+		case core.STTEvent:
+			if s.config.Role == MemoryRoleUser && e.IsFinal {
+				userText.WriteString(e.Text)
+			}
+		case core.LLMEvent:
+			if s.config.Role == MemoryRoleUser {
+				userText.WriteString(e.Delta)
+			}
+		case core.DoneEvent:
+			switch s.config.Role {
+			case MemoryRoleUser:
+				text := strings.TrimSpace(userText.String())
+				userText.Reset()
+				logger.Debug("Recorded user message", telemetry.Int("content_length", len(text)))
+				s.config.Memory.setPendingUser(text)
+			case MemoryRoleAssistant:
+				logger.Debug("Committing turn to memory", telemetry.Int("content_length", len(e.FullText)), telemetry.Bool("interrupted", e.Interrupted))
+				s.config.Memory.commitTurn(ctx, e.FullText)
+			}
+		}
+	}
+
+	return nil
+}
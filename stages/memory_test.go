@@ -0,0 +1,203 @@
+package stages
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/history"
+	providers "github.com/creastat/providers/core"
+)
+
+// runMemoryStage runs a memory stage over the given events
+func runMemoryStage(t *testing.T, stage *MemoryStage, events ...core.Event) {
+	input := make(chan core.Event, len(events))
+	output := make(chan core.Event, len(events))
+	for _, event := range events {
+		input <- event
+	}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(output) != len(events) {
+		t.Errorf("expected %d passed-through events, got %d", len(events), len(output))
+	}
+}
+
+func TestMemoryStage_RecordsTurns(t *testing.T) {
+	memory := NewConversationMemory(ConversationMemoryConfig{})
+	userStage := NewMemoryStage(MemoryStageConfig{Memory: memory, Role: MemoryRoleUser})
+	assistantStage := NewMemoryStage(MemoryStageConfig{Memory: memory, Role: MemoryRoleAssistant})
+
+	runMemoryStage(t, userStage,
+		core.STTEvent{Text: "What's the", IsFinal: false},
+		core.STTEvent{Text: "What's the price?", IsFinal: true},
+		core.DoneEvent{},
+	)
+
+	// The user message stays pending until the response completes
+	if history, _ := memory.RecentMessages(context.Background(), 0); len(history) != 0 {
+		t.Fatalf("expected empty history before the response, got %v", history)
+	}
+
+	runMemoryStage(t, assistantStage,
+		core.LLMEvent{Delta: "It costs $10."},
+		core.DoneEvent{FullText: "It costs $10."},
+	)
+
+	history, _ := memory.RecentMessages(context.Background(), 0)
+	if len(history) != 2 ||
+		history[0] != (providers.Message{Role: "user", Content: "What's the price?"}) ||
+		history[1] != (providers.Message{Role: "assistant", Content: "It costs $10."}) {
+		t.Errorf("unexpected history %v", history)
+	}
+}
+
+func TestConversationMemory_TrimOldest(t *testing.T) {
+	memory := NewConversationMemory(ConversationMemoryConfig{
+		MaxTokens:   10,
+		CountTokens: func(text string) int { return len(strings.Fields(text)) },
+	})
+
+	memory.Append(context.Background(),
+		providers.Message{Role: "user", Content: "one two three four"},
+		providers.Message{Role: "assistant", Content: "five six seven"},
+		providers.Message{Role: "user", Content: "eight nine ten eleven"},
+	)
+
+	history, _ := memory.RecentMessages(context.Background(), 0)
+	if len(history) != 2 || history[0].Content != "five six seven" {
+		t.Errorf("expected oldest message to be dropped, got %v", history)
+	}
+	if memory.Tokens() > 10 {
+		t.Errorf("history exceeds budget: %d tokens", memory.Tokens())
+	}
+}
+
+func TestConversationMemory_TrimSummarize(t *testing.T) {
+	memory := NewConversationMemory(ConversationMemoryConfig{
+		MaxTokens:   11,
+		Trimming:    MemoryTrimSummarize,
+		Summarizer:  &LLMConversationSummarizer{Provider: &TestStreamingLLMProvider{responseText: "asked price"}},
+		CountTokens: func(text string) int { return len(strings.Fields(text)) },
+	})
+
+	memory.Append(context.Background(),
+		providers.Message{Role: "user", Content: "one two three four five six"},
+		providers.Message{Role: "assistant", Content: "seven eight nine"},
+		providers.Message{Role: "user", Content: "ten eleven"},
+		providers.Message{Role: "assistant", Content: "twelve"},
+	)
+
+	history, _ := memory.RecentMessages(context.Background(), 0)
+	if len(history) != 3 || history[0].Role != "system" || !strings.HasSuffix(history[0].Content, "asked price") {
+		t.Errorf("expected oldest messages to be summarized, got %v", history)
+	}
+}
+
+func TestLLMStage_HistoryProvider(t *testing.T) {
+	memory := NewConversationMemory(ConversationMemoryConfig{})
+	memory.Append(context.Background(),
+		providers.Message{Role: "user", Content: "My name is Ada."},
+		providers.Message{Role: "assistant", Content: "Hi Ada!"},
+	)
+
+	provider := &TestRecordingLLMProvider{}
+	stage := NewLLMStage(LLMStageConfig{
+		Provider:        provider,
+		HistoryProvider: memory,
+	})
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 100)
+	input <- core.STTEvent{Text: "What's my name?"}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	messages := provider.request.Messages
+	if len(messages) != 3 || messages[0].Content != "My name is Ada." || messages[2].Content != "What's my name?" {
+		t.Errorf("expected history before the user message, got %v", messages)
+	}
+}
+
+// TestRecordingLLMProvider records the last streaming request
+type TestRecordingLLMProvider struct {
+	TestStreamingLLMProvider
+	request providers.ChatRequest
+}
+
+func (m *TestRecordingLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	m.request = req
+	return m.TestStreamingLLMProvider.StreamChatCompletion(ctx, req)
+}
+
+// TestConversationMemory_SharedStore tests that history recorded by a
+// HistoryStage into a store is what a store-backed memory hands the LLM
+func TestConversationMemory_SharedStore(t *testing.T) {
+	store := history.NewMemoryStore()
+	stage := NewHistoryStage(HistoryStageConfig{
+		Writer: StoreHistoryWriter{Store: store, ConversationID: "c1"},
+		Logger: testLogger(),
+	})
+	memory := NewConversationMemory(ConversationMemoryConfig{
+		Store:          store,
+		ConversationID: "c1",
+		MaxTokens:      4,
+		CountTokens:    func(text string) int { return len(strings.Fields(text)) },
+	})
+
+	input := make(chan core.Event, 4)
+	input <- core.STTEvent{Text: "how much is it", IsFinal: true}
+	input <- core.DoneEvent{FullText: "ten euros"}
+	close(input)
+	if err := stage.Process(context.Background(), input, make(chan core.Event, 4)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	// Both messages are stored, the budget only limits what the LLM gets
+	if stored, _ := store.LoadRecent(context.Background(), "c1", 0); len(stored) != 2 {
+		t.Fatalf("expected 2 stored messages, got %v", stored)
+	}
+	messages, err := memory.RecentMessages(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("RecentMessages failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0] != (providers.Message{Role: "assistant", Content: "ten euros"}) {
+		t.Errorf("expected only the response within budget, got %v", messages)
+	}
+
+	memory.Reset()
+	if stored, _ := store.LoadRecent(context.Background(), "c1", 0); len(stored) != 0 {
+		t.Errorf("expected Reset to clear the store, got %v", stored)
+	}
+}
+
+func TestConversationMemory_StoreSummarize(t *testing.T) {
+	store := history.NewMemoryStore()
+	memory := NewConversationMemory(ConversationMemoryConfig{
+		Store:          store,
+		ConversationID: "c1",
+		MaxTokens:      11,
+		Trimming:       MemoryTrimSummarize,
+		Summarizer:     &LLMConversationSummarizer{Provider: &TestStreamingLLMProvider{responseText: "asked price"}},
+		CountTokens:    func(text string) int { return len(strings.Fields(text)) },
+	})
+
+	memory.Append(context.Background(),
+		providers.Message{Role: "user", Content: "one two three four five six"},
+		providers.Message{Role: "assistant", Content: "seven eight nine"},
+		providers.Message{Role: "user", Content: "ten eleven"},
+		providers.Message{Role: "assistant", Content: "twelve"},
+	)
+
+	stored, _ := store.LoadRecent(context.Background(), "c1", 0)
+	if len(stored) != 3 || stored[0].Role != history.RoleSystem || !strings.HasSuffix(stored[0].Content, "asked price") {
+		t.Errorf("expected the store to hold the summary, got %v", stored)
+	}
+}
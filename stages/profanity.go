@@ -0,0 +1,382 @@
+package stages
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// ProfanityMode selects what ProfanityFilterStage does with denied words
+type ProfanityMode string
+
+const (
+	ProfanityMask  ProfanityMode = ""      // Keep the first letter and mask the rest, "d***"
+	ProfanityBlock ProfanityMode = "block" // Drop the rest of the turn
+)
+
+// ProfanityFilterConfig holds configuration for ProfanityFilterStage
+type ProfanityFilterConfig struct {
+	// Deny lists the words to filter by language ("pt-BR", then "pt"),
+	// matched as whole words ignoring case. A trailing "*" also matches
+	// longer words, "darn*" matches "darned". Words under the "" key are
+	// filtered in every language.
+	Deny map[string][]string
+
+	// Allow lists words never filtered although a Deny entry matches them,
+	// e.g. "darnell" for "darn*", by language like Deny
+	Allow map[string][]string
+
+	// Language selects the lists until a LanguageDetectedEvent switches them
+	Language string
+
+	Mode ProfanityMode
+
+	Logger telemetry.Logger
+}
+
+// ProfanityFilterStage filters denied words out of STT transcripts and LLM
+// output. Placed after the STT stage it screens what reaches the LLM, placed
+// after the LLM stage what reaches TTS and the client.
+//
+// In ProfanityMask mode denied words are masked in transcripts, deltas and
+// the DoneEvent's full text. A word split across LLM deltas is held back
+// until it is complete, only while it may still become a denied one.
+//
+// In ProfanityBlock mode a final transcript or LLM output with a denied word
+// drops it and the turn's remaining STT and LLM events, and sends a warning
+// service message instead. Interim transcripts with a denied word are dropped
+// without blocking the turn, the final one may differ.
+type ProfanityFilterStage struct {
+	config ProfanityFilterConfig
+	lists  map[string]*profanityList // By language, "" for all languages
+}
+
+// NewProfanityFilterStage creates a new profanity filter stage
+func NewProfanityFilterStage(config ProfanityFilterConfig) *ProfanityFilterStage {
+	languages := make(map[string]bool)
+	for language := range config.Deny {
+		languages[language] = true
+	}
+	for language := range config.Allow {
+		languages[language] = true
+	}
+
+	lists := make(map[string]*profanityList, len(languages))
+	for language := range languages {
+		list := newProfanityList()
+		list.add(config.Deny[""], config.Allow[""])
+		if language != "" {
+			list.add(config.Deny[language], config.Allow[language])
+		}
+		lists[language] = list
+	}
+	return &ProfanityFilterStage{config: config, lists: lists}
+}
+
+// Name returns the stage name
+func (s *ProfanityFilterStage) Name() string {
+	return "profanity_filter"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *ProfanityFilterStage) InputTypes() []core.EventType {
+	// Profanity filter passes all event types through
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *ProfanityFilterStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// listFor returns the lists for a language, or the ones for all languages
+func (s *ProfanityFilterStage) listFor(language string) *profanityList {
+	if list, ok := localized(s.lists, language); ok {
+		return list
+	}
+	return s.lists[""]
+}
+
+// Process implements the Stage interface
+func (s *ProfanityFilterStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+	list := s.listFor(s.config.Language)
+
+	blocked := false
+	held := ""                  // Start of a word split across LLM deltas
+	var heldMeta core.EventMeta // Metadata of the delta it came from
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	// block drops the rest of the turn
+	block := func(source, text string) error {
+		blocked = true
+		held = ""
+		logger.Warn("Blocked profanity", telemetry.String("source", source), telemetry.String("text", text))
+		return send(i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgProfanityBlocked, nil))
+	}
+
+	// flush sends the held start of a word
+	flush := func() error {
+		if held == "" {
+			return nil
+		}
+		text := held
+		held = ""
+		if s.config.Mode == ProfanityBlock && list.contains(text) {
+			return block("llm", text)
+		}
+		return send(core.LLMEvent{Delta: list.mask(text), Meta: heldMeta})
+	}
+
+	for {
+		var event core.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next, ok := <-input:
+			if !ok {
+				return flush()
+			}
+			event = next
+		}
+
+		if e, ok := event.(core.LLMEvent); ok {
+			if blocked {
+				continue
+			}
+			if list == nil {
+				if err := send(e); err != nil {
+					return err
+				}
+				continue
+			}
+
+			text := held + e.Delta
+			held = list.heldTail(text)
+			text = text[:len(text)-len(held)]
+			heldMeta = e.Meta
+
+			if s.config.Mode == ProfanityBlock {
+				if list.contains(text) || list.contains(e.Content) {
+					if err := block("llm", e.Content); err != nil {
+						return err
+					}
+					continue
+				}
+			} else {
+				e.Content = list.mask(e.Content)
+			}
+			e.Delta = list.mask(text)
+			if e.Delta == "" && e.Content == "" {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+
+		switch e := event.(type) {
+		case core.LanguageDetectedEvent:
+			list = s.listFor(e.Language)
+
+		case core.STTEvent:
+			if blocked {
+				continue
+			}
+			if list == nil || !list.contains(e.Text) {
+				break
+			}
+			if s.config.Mode == ProfanityBlock {
+				if !e.IsFinal {
+					continue
+				}
+				if err := block("stt", e.Text); err != nil {
+					return err
+				}
+				continue
+			}
+			e.Text = list.mask(e.Text)
+			if e.Words != nil {
+				words := make([]core.WordInfo, len(e.Words))
+				for i, word := range e.Words {
+					word.Word = list.mask(word.Word)
+					words[i] = word
+				}
+				e.Words = words
+			}
+			event = e
+
+		case core.DoneEvent:
+			if blocked {
+				e.FullText = ""
+			} else if list != nil {
+				e.FullText = list.mask(e.FullText)
+			}
+			blocked = false
+			event = e
+		}
+
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// profanityList matches denied words
+type profanityList struct {
+	words    map[string]bool // Lowercased
+	prefixes []string        // Lowercased, from entries ending in "*"
+	allowed  map[string]bool // Lowercased
+}
+
+// newProfanityList creates an empty list
+func newProfanityList() *profanityList {
+	return &profanityList{words: make(map[string]bool), allowed: make(map[string]bool)}
+}
+
+// add adds denied and allowed words
+func (l *profanityList) add(deny, allow []string) {
+	for _, word := range deny {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			if prefix != "" {
+				l.prefixes = append(l.prefixes, prefix)
+			}
+		} else if word != "" {
+			l.words[word] = true
+		}
+	}
+	for _, word := range allow {
+		l.allowed[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+}
+
+// denied reports whether a word is denied
+func (l *profanityList) denied(word string) bool {
+	word = strings.ToLower(word)
+	if l.allowed[word] {
+		return false
+	}
+	if l.words[word] {
+		return true
+	}
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether text has a denied word
+func (l *profanityList) contains(text string) bool {
+	found := false
+	eachWord(text, func(start, end int) {
+		found = found || l.denied(text[start:end])
+	})
+	return found
+}
+
+// mask masks the denied words of text, keeping their first letter
+func (l *profanityList) mask(text string) string {
+	var b strings.Builder
+	last := 0
+	eachWord(text, func(start, end int) {
+		word := text[start:end]
+		if !l.denied(word) {
+			return
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		b.WriteString(text[last:start])
+		b.WriteRune(first)
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(word[size:])))
+		last = end
+	})
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// heldTail returns the word text ends with if more of it may still arrive
+// and make it a denied word, or "" when text can be filtered as it is
+func (l *profanityList) heldTail(text string) string {
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isWordRune(r) {
+			break
+		}
+		start -= size
+	}
+	tail := strings.ToLower(text[start:])
+	if tail == "" {
+		return ""
+	}
+
+	for word := range l.words {
+		if strings.HasPrefix(word, tail) {
+			return text[start:]
+		}
+	}
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(prefix, tail) || strings.HasPrefix(tail, prefix) {
+			return text[start:]
+		}
+	}
+	return ""
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+// eachWord calls fn with the bounds of each word of text, without quoting
+// apostrophes
+func eachWord(text string, fn func(start, end int)) {
+	word := func(start, end int) {
+		for start < end && text[start] == '\'' {
+			start++
+		}
+		for end > start && text[end-1] == '\'' {
+			end--
+		}
+		if start < end {
+			fn(start, end)
+		}
+	}
+
+	start := -1
+	for i, r := range text {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			word(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		word(start, len(text))
+	}
+}
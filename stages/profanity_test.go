@@ -0,0 +1,114 @@
+package stages
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+)
+
+// runProfanityFilter runs the stage over events and returns its output
+func runProfanityFilter(t *testing.T, config ProfanityFilterConfig, events ...core.Event) []core.Event {
+	t.Helper()
+	config.Logger = testLogger()
+	stage := NewProfanityFilterStage(config)
+
+	input := make(chan core.Event, len(events))
+	output := make(chan core.Event, len(events)+10)
+	for _, event := range events {
+		input <- event
+	}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []core.Event
+	for event := range output {
+		results = append(results, event)
+	}
+	return results
+}
+
+// TestProfanityFilterMask tests masking transcripts and streamed deltas,
+// including words split across deltas and allowed words
+func TestProfanityFilterMask(t *testing.T) {
+	config := ProfanityFilterConfig{
+		Deny:     map[string][]string{"en": {"darn*", "heck"}},
+		Allow:    map[string][]string{"en": {"darnell"}},
+		Language: "en",
+	}
+	results := runProfanityFilter(t, config,
+		core.STTEvent{Text: "Darn it, Darnell", IsFinal: true, Words: []core.WordInfo{{Word: "Darn"}, {Word: "it"}, {Word: "Darnell"}}},
+		core.LLMEvent{Delta: "What the he"},
+		core.LLMEvent{Delta: "ck, 'darned' he"},
+		core.LLMEvent{Delta: "lp"},
+		core.DoneEvent{FullText: "What the heck, 'darned' help"},
+	)
+
+	want := []core.Event{
+		core.STTEvent{Text: "D*** it, Darnell", IsFinal: true, Words: []core.WordInfo{{Word: "D***"}, {Word: "it"}, {Word: "Darnell"}}},
+		core.LLMEvent{Delta: "What the "},
+		core.LLMEvent{Delta: "h***, 'd*****' "},
+		core.LLMEvent{Delta: "help"},
+		core.DoneEvent{FullText: "What the h***, 'd*****' help"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+}
+
+// TestProfanityFilterBlock tests that a denied word blocks the rest of the
+// turn and that the next turn passes again
+func TestProfanityFilterBlock(t *testing.T) {
+	config := ProfanityFilterConfig{
+		Deny: map[string][]string{"": {"heck"}},
+		Mode: ProfanityBlock,
+	}
+	results := runProfanityFilter(t, config,
+		core.STTEvent{Text: "what the heck"},
+		core.STTEvent{Text: "what the heck is this", IsFinal: true},
+		core.LLMEvent{Delta: "what the heck is this", Content: "what the heck is this"},
+		core.UsageEvent{Provider: "stt"},
+		core.DoneEvent{FullText: "what the heck is this"},
+		core.STTEvent{Text: "hello", IsFinal: true},
+		core.DoneEvent{FullText: "hello"},
+	)
+
+	want := []core.Event{
+		i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgProfanityBlocked, nil),
+		core.UsageEvent{Provider: "stt"},
+		core.DoneEvent{},
+		core.STTEvent{Text: "hello", IsFinal: true},
+		core.DoneEvent{FullText: "hello"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+}
+
+// TestProfanityFilterLanguage tests switching lists on language detection
+func TestProfanityFilterLanguage(t *testing.T) {
+	config := ProfanityFilterConfig{
+		Deny:     map[string][]string{"en": {"heck"}, "es": {"caramba"}},
+		Language: "en-US",
+	}
+	results := runProfanityFilter(t, config,
+		core.STTEvent{Text: "heck caramba", IsFinal: true},
+		core.LanguageDetectedEvent{Language: "es"},
+		core.STTEvent{Text: "heck caramba", IsFinal: true},
+	)
+
+	want := []core.Event{
+		core.STTEvent{Text: "h*** caramba", IsFinal: true},
+		core.LanguageDetectedEvent{Language: "es"},
+		core.STTEvent{Text: "heck c******", IsFinal: true},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %+v, want %+v", results, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
@@ -55,12 +56,122 @@ type RAGStageConfig struct {
 	// If provided, RAG stage will fetch document titles and URLs to add to the context.
 	MetadataProvider DocumentMetadataProvider
 
+	// VectorStores enables federated retrieval across several knowledge domains.
+	// When set, all stores are queried in parallel and their results are merged;
+	// VectorStore is then ignored.
+	VectorStores []VectorStoreSource
+
+	// ScoreNormalization controls how scores from VectorStores are normalized
+	// before weighting and merging. Defaults to ScoreNormalizationMinMax.
+	ScoreNormalization ScoreNormalization
+
+	// DeduplicationThreshold drops chunks whose word overlap (Jaccard similarity)
+	// with a higher-ranked chunk is at or above this value (0.0-1.0).
+	// Zero disables deduplication.
+	DeduplicationThreshold float32
+
+	// EnableMMR selects chunks with maximal marginal relevance so the assembled
+	// context covers diverse information instead of repeating the top hit.
+	EnableMMR bool
+
+	// MMRLambda trades relevance (1.0) against diversity (0.0). Nil defaults
+	// to 0.7.
+	MMRLambda *float32
+
+	// QueryCondenser optionally rewrites follow-up questions into standalone
+	// queries before embedding, using recent conversation history.
+	QueryCondenser QueryCondenser
+
+	// ConversationHistory is static history used for query condensation.
+	ConversationHistory []providers.Message
+
+	// HistoryProvider loads recent history at query time for condensation.
+	// Takes precedence over ConversationHistory if set.
+	HistoryProvider ConversationHistoryProvider
+
+	// CondenseHistoryTurns limits how many recent messages are used for
+	// condensation. Defaults to 6.
+	CondenseHistoryTurns int
+
+	// CandidatePoolSize is how many results to fetch before reranking,
+	// deduplication and MMR narrow them down to MaxChunks. Defaults to 3x MaxChunks.
+	CandidatePoolSize int
+
+	// QueryExpander optionally rewrites the query into alternative phrasings.
+	// All queries are searched in parallel and their results are fused with
+	// reciprocal rank fusion, improving recall for short voice queries.
+	QueryExpander QueryExpander
+
+	// ExpansionCount is how many alternative queries to generate. Defaults to 3.
+	ExpansionCount int
+
+	// MaxConcurrentSearches bounds how many expanded queries are searched at
+	// once. Their embeddings are generated in one request when the embedding
+	// provider implements BatchEmbeddingProvider. Defaults to 4.
+	MaxConcurrentSearches int
+
+	// RRFK is the reciprocal rank fusion constant. Defaults to 60.
+	RRFK int
+
+	// KeywordSearcher optionally adds keyword search, typically BM25 over a
+	// full-text index, alongside the vector search. Both run in parallel and
+	// their rankings are fused with reciprocal rank fusion, so exact part
+	// numbers and error codes are found even when their embeddings are not.
+	KeywordSearcher KeywordSearcher
+
+	// KeywordWeight scales the keyword ranking against the vector ranking
+	// in the fusion. Defaults to 1.
+	KeywordWeight float32
+
+	// Reranker optionally re-scores search results against the query before
+	// deduplication, MMR and truncation to MaxChunks.
+	Reranker Reranker
+
+	// StreamingRetrieval starts retrieval on the first query segment,
+	// usually the first final transcript, while the user may still be
+	// speaking, instead of after the DoneEvent that ends the query
+	StreamingRetrieval bool
+
+	// RefineRetrieval, with StreamingRetrieval, restarts retrieval with the
+	// query so far on every follow-up segment, so the context matches the
+	// whole query. Without it the first segment's results are used.
+	RefineRetrieval bool
+
+	// MaxContextTokens caps the tokens of the assembled context so the
+	// enriched prompt fits the LLM's context window. Chunks are kept by
+	// ChunkPriority and the first that doesn't fit is cut short; the rest
+	// are dropped. 0 disables the budget.
+	MaxContextTokens int
+
+	// CountTokens counts tokens for MaxContextTokens, e.g. with the LLM's
+	// tokenizer (default: ~4 chars per token)
+	CountTokens func(text string) int
+
+	// ChunkPriority orders chunks for MaxContextTokens, higher first, e.g.
+	// to favor some sources (default: the chunk's score)
+	ChunkPriority func(result vectorstore.SearchResult) float32
+
+	// CacheTTL enables caching the retrieved chunks by normalized query
+	// text, so a question repeated within the TTL skips the embedding and
+	// search round trips. Zero disables the cache.
+	CacheTTL time.Duration
+
+	// CacheSize is the maximum number of cached queries. Defaults to 64.
+	CacheSize int
+
+	// EmbeddingPresets are embedding providers selectable by preset name
+	// with a ConfigUpdateEvent. Presets must produce vectors compatible with
+	// the vector stores.
+	EmbeddingPresets map[string]providers.EmbeddingProvider
+
 	Logger telemetry.Logger
 }
 
 // RAGStage retrieves relevant context from a vector store.
 type RAGStage struct {
-	config RAGStageConfig
+	config  RAGStageConfig
+	updates pendingConfig
+	cache   *ragCache // nil when CacheTTL is unset
 }
 
 // NewRAGStage creates a new RAG stage.
@@ -71,7 +182,10 @@ func NewRAGStage(config RAGStageConfig) *RAGStage {
 	if config.Threshold <= 0 {
 		config.Threshold = 0.7
 	}
-	return &RAGStage{config: config}
+	if config.ScoreNormalization == "" {
+		config.ScoreNormalization = ScoreNormalizationMinMax
+	}
+	return &RAGStage{config: config, cache: newRAGCache(config.CacheTTL, config.CacheSize)}
 }
 
 // Name returns the stage name.
@@ -86,21 +200,42 @@ func (s *RAGStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the event types this stage produces
 func (s *RAGStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus}
+	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeCitation, core.EventTypeLanguage, core.EventTypeDone}
 }
 
 // Process implements the Stage interface.
 // It reads the query from input, retrieves context, and passes enriched input to output.
 func (s *RAGStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	s.applyPendingConfig()
+
 	logger := s.config.Logger.WithModule(s.Name())
 	logger.Info("RAGStage started processing")
 
-	// Collect query text from input
+	// Collect query text from input, retrieving as it arrives in streaming
+	// mode
 	var queryText string
+	var retrieval *ragRetrieval
+	defer func() {
+		if retrieval != nil {
+			retrieval.cancel()
+		}
+	}()
 	for event := range input {
 		if llmEvent, ok := event.(core.LLMEvent); ok {
 			queryText += llmEvent.Delta
 			logger.Debug("Received LLM event", telemetry.String("delta", llmEvent.Delta))
+			if s.config.StreamingRetrieval && queryText != "" && (retrieval == nil || s.config.RefineRetrieval) {
+				if retrieval == nil {
+					output <- searchingStatus()
+				} else {
+					retrieval.cancel()
+				}
+				logger.Debug("Starting streaming retrieval", telemetry.String("query", queryText))
+				retrieval = s.startRetrieval(ctx, queryText)
+			}
+		} else if languageEvent, ok := event.(core.LanguageDetectedEvent); ok {
+			// Downstream LLM and TTS stages localize on it
+			output <- languageEvent
 		} else if _, ok := event.(core.DoneEvent); ok {
 			// Stop collecting on DoneEvent
 			logger.Info("Received DoneEvent, finishing collection")
@@ -115,22 +250,26 @@ func (s *RAGStage) Process(ctx context.Context, input <-chan core.Event, output
 		return nil
 	}
 
-	// Emit searching status only when we actually have a query to search for
-	output <- core.StatusEvent{
-		Status:  core.StatusSearching,
-		Target:  core.StatusTargetBot,
-		Message: "Searching knowledge base...",
-	}
-
 	logger.Info("Collected query text", telemetry.String("query", queryText))
 
+	if retrieval == nil {
+		// Emit searching status only when we actually have a query to search for
+		output <- searchingStatus()
+		retrieval = s.startRetrieval(ctx, queryText)
+	}
+
 	// Build context
-	ragContext, err := s.buildContext(ctx, queryText)
+	ragContext, citations, err := retrieval.wait()
 	if err != nil {
 		// Log error but continue silently (no context)
 		logger.Error("RAG context building failed", telemetry.Err(err))
 	}
 
+	// Let the client render the sources behind the answer
+	for _, citation := range citations {
+		output <- citation
+	}
+
 	if ragContext != "" {
 		logger.Info("found context", telemetry.Int("context_length", len(ragContext)))
 	} else {
@@ -159,50 +298,38 @@ func (s *RAGStage) Process(ctx context.Context, input <-chan core.Event, output
 }
 
 // buildContext generates embedding and searches vector store.
-func (s *RAGStage) buildContext(ctx context.Context, query string) (string, error) {
+// It returns the formatted context and a citation for each chunk used.
+func (s *RAGStage) buildContext(ctx context.Context, query string) (string, []core.CitationEvent, error) {
 	// Skip if no vector store or embedding provider
-	if s.config.VectorStore == nil || s.config.EmbeddingProvider == nil {
-		return "", fmt.Errorf("vector store or embedding provider not configured")
+	if !s.hasVectorStore() || s.config.EmbeddingProvider == nil {
+		return "", nil, fmt.Errorf("vector store or embedding provider not configured")
 	}
 
-	// Generate embedding for query
-	embResp, err := s.config.EmbeddingProvider.GenerateEmbedding(ctx, providers.EmbeddingRequest{
-		Model: s.config.EmbeddingModel,
-		Text:  query,
-	})
+	results, err := s.retrieveCached(ctx, query)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate embedding: %w", err)
-	}
-
-	// Build search filter
-	filter := vectorstore.SearchFilter{
-		MinScore: s.config.Threshold,
-	}
-
-	// Use SourceIDs if provided, otherwise fall back to SourceID for backward compatibility
-	if len(s.config.SourceIDs) > 0 {
-		filter.SourceIDs = s.config.SourceIDs
-	} else if s.config.SourceID != "" {
-		filter.SourceID = s.config.SourceID
-	}
-
-	results, err := s.config.VectorStore.Search(ctx, embResp.Vector, filter, s.config.MaxChunks)
-	if err != nil {
-		return "", fmt.Errorf("vector search failed: %w", err)
+		return "", nil, err
 	}
 
 	if len(results) == 0 {
-		return "", nil
+		return "", nil, nil
 	}
 
 	// Format context from results
 	var contextParts []string
+	var citations []core.CitationEvent
+	var used []vectorstore.SearchResult
 	for _, result := range results {
 		if result.Content == "" {
 			continue
 		}
 
 		contextEntry := result.Content
+		citation := core.CitationEvent{
+			DocumentID: result.DocumentID,
+			ChunkID:    result.ID,
+			Score:      result.Score,
+			Excerpt:    citationExcerpt(result.Content),
+		}
 
 		// Enrich with document metadata if provider is available
 		if s.config.MetadataProvider != nil && result.DocumentID != "" {
@@ -214,11 +341,97 @@ func (s *RAGStage) buildContext(ctx context.Context, query string) (string, erro
 				if doc.URL != "" {
 					contextEntry = fmt.Sprintf("%s\n(Source: %s)", contextEntry, doc.URL)
 				}
+				citation.Title = doc.Title
+				citation.URL = doc.URL
 			}
 		}
 
 		contextParts = append(contextParts, contextEntry)
+		citations = append(citations, citation)
+		used = append(used, result)
+	}
+
+	contextParts, citations = s.fitContextBudget(contextParts, citations, used)
+	return strings.Join(contextParts, ragContextSeparator), citations, nil
+}
+
+// citationExcerptLength is the maximum number of runes in a citation excerpt
+const citationExcerptLength = 200
+
+// citationExcerpt returns the leading part of a chunk for display as a source.
+func citationExcerpt(content string) string {
+	content = strings.TrimSpace(content)
+	runes := []rune(content)
+	if len(runes) <= citationExcerptLength {
+		return content
+	}
+	return strings.TrimSpace(string(runes[:citationExcerptLength])) + "…"
+}
+
+// retrieve searches for a single query, adding keyword search when configured.
+func (s *RAGStage) retrieve(ctx context.Context, query string) ([]vectorstore.SearchResult, error) {
+	return s.retrieveWith(ctx, query, func() ([]vectorstore.SearchResult, error) {
+		vector, err := s.embed(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return s.search(ctx, vector)
+	})
+}
+
+// retrieveWith runs the vector search of a query, fused with its keyword
+// search when configured
+func (s *RAGStage) retrieveWith(ctx context.Context, query string, vectorSearch func() ([]vectorstore.SearchResult, error)) ([]vectorstore.SearchResult, error) {
+	if s.config.KeywordSearcher != nil {
+		return s.retrieveHybrid(ctx, query, vectorSearch)
+	}
+	return vectorSearch()
+}
+
+// embed generates the embedding of a single query
+func (s *RAGStage) embed(ctx context.Context, query string) ([]float32, error) {
+	embResp, err := s.config.EmbeddingProvider.GenerateEmbedding(ctx, providers.EmbeddingRequest{
+		Model: s.config.EmbeddingModel,
+		Text:  query,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	return embResp.Vector, nil
+}
+
+// hasVectorStore reports whether at least one vector store is configured.
+func (s *RAGStage) hasVectorStore() bool {
+	return s.config.VectorStore != nil || len(s.config.VectorStores) > 0
+}
+
+// search queries the configured vector store(s) with the query vector.
+func (s *RAGStage) search(ctx context.Context, vector []float32) ([]vectorstore.SearchResult, error) {
+	if len(s.config.VectorStores) > 0 {
+		return s.searchFederated(ctx, vector)
+	}
+
+	results, err := s.config.VectorStore.Search(ctx, vector, s.searchFilter(nil), s.candidateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("vector search failed: %w", err)
+	}
+	return results, nil
+}
+
+// searchFilter builds the search filter, preferring sourceIDs when provided.
+func (s *RAGStage) searchFilter(sourceIDs []string) vectorstore.SearchFilter {
+	filter := vectorstore.SearchFilter{
+		MinScore: s.config.Threshold,
+	}
+
+	// Use SourceIDs if provided, otherwise fall back to SourceID for backward compatibility
+	if len(sourceIDs) > 0 {
+		filter.SourceIDs = sourceIDs
+	} else if len(s.config.SourceIDs) > 0 {
+		filter.SourceIDs = s.config.SourceIDs
+	} else if s.config.SourceID != "" {
+		filter.SourceID = s.config.SourceID
 	}
 
-	return strings.Join(contextParts, "\n\n---\n\n"), nil
+	return filter
 }
@@ -0,0 +1,74 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/creastat/infra/telemetry"
+)
+
+// defaultMaxConcurrentSearches bounds the parallel searches of expanded queries
+const defaultMaxConcurrentSearches = 4
+
+// BatchEmbeddingProvider is implemented by embedding providers that embed
+// several texts in one request. RAGStage then embeds all expanded queries
+// with a single round trip. Vectors are returned in the order of req.Texts.
+type BatchEmbeddingProvider interface {
+	GenerateEmbeddings(ctx context.Context, req BatchEmbeddingRequest) ([][]float32, error)
+}
+
+// BatchEmbeddingRequest is an embedding request for several texts
+type BatchEmbeddingRequest struct {
+	Model string
+	Texts []string
+}
+
+// embedQueries generates the embeddings of several queries, in one request
+// when the provider supports batching. If the batch fails, or the provider
+// doesn't batch, each query is embedded separately, bounded like searches.
+func (s *RAGStage) embedQueries(ctx context.Context, queries []string) ([][]float32, []error) {
+	vectors := make([][]float32, len(queries))
+	errs := make([]error, len(queries))
+
+	if batcher, ok := s.config.EmbeddingProvider.(BatchEmbeddingProvider); ok {
+		batch, err := batcher.GenerateEmbeddings(ctx, BatchEmbeddingRequest{
+			Model: s.config.EmbeddingModel,
+			Texts: queries,
+		})
+		if err == nil && len(batch) != len(queries) {
+			err = fmt.Errorf("got %d embeddings for %d texts", len(batch), len(queries))
+		}
+		if err == nil {
+			return batch, errs
+		}
+		s.config.Logger.WithModule(s.Name()).Warn("Batch embedding failed, embedding queries separately", telemetry.Err(err))
+	}
+
+	s.forEachBounded(len(queries), func(i int) {
+		vectors[i], errs[i] = s.embed(ctx, queries[i])
+	})
+	return vectors, errs
+}
+
+// forEachBounded calls fn for 0..n-1 concurrently, at most
+// MaxConcurrentSearches at a time, and waits for all calls to return
+func (s *RAGStage) forEachBounded(n int, fn func(i int)) {
+	limit := s.config.MaxConcurrentSearches
+	if limit <= 0 {
+		limit = defaultMaxConcurrentSearches
+	}
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,124 @@
+package stages
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// ragContextSeparator separates chunks in the assembled context
+const ragContextSeparator = "\n\n---\n\n"
+
+// minTruncatedChunkTokens is the smallest part of a chunk worth keeping when
+// it is cut to fit the context budget
+const minTruncatedChunkTokens = 16
+
+// fitContextBudget drops and truncates context entries, each with its
+// citation and search result, so that joined they fit MaxContextTokens.
+// Entries are considered by priority: each one that fits is kept whole, and
+// the first that doesn't is cut at a word boundary to fill the rest of the
+// budget, which leaves out the lower priority ones. Kept entries stay in
+// their retrieval order.
+func (s *RAGStage) fitContextBudget(entries []string, citations []core.CitationEvent, results []vectorstore.SearchResult) ([]string, []core.CitationEvent) {
+	budget := s.config.MaxContextTokens
+	if budget <= 0 || len(entries) == 0 {
+		return entries, citations
+	}
+	count := s.config.CountTokens
+	if count == nil {
+		count = estimateTokens
+	}
+	if count(strings.Join(entries, ragContextSeparator)) <= budget {
+		return entries, citations
+	}
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	priority := s.config.ChunkPriority
+	if priority == nil {
+		priority = func(result vectorstore.SearchResult) float32 { return result.Score }
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return priority(results[order[a]]) > priority(results[order[b]])
+	})
+
+	separator := count(ragContextSeparator)
+	kept := make(map[int]string, len(entries))
+	remaining := budget
+	for _, i := range order {
+		cost := count(entries[i])
+		if len(kept) > 0 {
+			cost += separator
+		}
+		if cost <= remaining {
+			kept[i] = entries[i]
+			remaining -= cost
+			continue
+		}
+
+		if len(kept) > 0 {
+			remaining -= separator
+		}
+		if remaining >= minTruncatedChunkTokens {
+			if truncated := truncateToTokens(entries[i], remaining, count); truncated != "" {
+				kept[i] = truncated
+			}
+		}
+		break
+	}
+
+	fitted := make([]string, 0, len(kept))
+	var fittedCitations []core.CitationEvent
+	for i := range entries {
+		entry, ok := kept[i]
+		if !ok {
+			continue
+		}
+		fitted = append(fitted, entry)
+		fittedCitations = append(fittedCitations, citations[i])
+	}
+
+	s.config.Logger.WithModule(s.Name()).Debug("RAG context trimmed to token budget",
+		telemetry.Int("budget", budget),
+		telemetry.Int("chunks", len(entries)),
+		telemetry.Int("kept", len(fitted)))
+	return fitted, fittedCitations
+}
+
+// truncateToTokens returns the longest prefix of text ending at a word
+// boundary that, with an ellipsis, fits within limit tokens, or "" if none
+// does. The tokenizer may be arbitrary, so the cut is found by binary search.
+func truncateToTokens(text string, limit int, count func(string) int) string {
+	var cuts []int // Byte offsets of word ends
+	inWord := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if space && inWord {
+			cuts = append(cuts, i)
+		}
+		inWord = !space
+	}
+	if inWord {
+		cuts = append(cuts, len(text))
+	}
+
+	best := ""
+	low, high := 0, len(cuts)-1
+	for low <= high {
+		mid := (low + high) / 2
+		candidate := text[:cuts[mid]] + "…"
+		if count(candidate) <= limit {
+			best = candidate
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	return best
+}
@@ -0,0 +1,134 @@
+package stages
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// defaultRAGCacheSize is the number of queries the retrieval cache holds by default
+const defaultRAGCacheSize = 64
+
+// ragCache holds the selected results of recent queries, so a repeated
+// question skips embedding, search and reranking. Entries expire after the
+// TTL and the least recently used one is evicted when full. It is safe for
+// concurrent use by streaming retrievals.
+type ragCache struct {
+	ttl      time.Duration
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+}
+
+type ragCacheEntry struct {
+	query   string
+	results []vectorstore.SearchResult
+	expires time.Time
+}
+
+// newRAGCache creates a cache, or returns nil when ttl disables it
+func newRAGCache(ttl time.Duration, capacity int) *ragCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if capacity <= 0 {
+		capacity = defaultRAGCacheSize
+	}
+	return &ragCache{
+		ttl:      ttl,
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached results for a normalized query
+func (c *ragCache) get(query string) ([]vectorstore.SearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[query]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*ragCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, query)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.results, true
+}
+
+// put caches the results for a normalized query
+func (c *ragCache) put(query string, results []vectorstore.SearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.entries[query]; ok {
+		entry := element.Value.(*ragCacheEntry)
+		entry.results, entry.expires = results, expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[query] = c.order.PushFront(&ragCacheEntry{query: query, results: results, expires: expires})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*ragCacheEntry).query)
+	}
+}
+
+// clear drops all entries, e.g. when the embedding provider changes
+func (c *ragCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// normalizeQuery folds case, whitespace and punctuation so a repeated
+// question matches its cache entry however the transcript renders it
+func normalizeQuery(query string) string {
+	var words []string
+	eachWord(query, func(start, end int) {
+		words = append(words, strings.ToLower(query[start:end]))
+	})
+	return strings.Join(words, " ")
+}
+
+// retrieveCached returns the cached results for the query, or retrieves,
+// reranks and selects them and caches the outcome
+func (s *RAGStage) retrieveCached(ctx context.Context, query string) ([]vectorstore.SearchResult, error) {
+	key := normalizeQuery(query)
+	if s.cache != nil && key != "" {
+		if results, ok := s.cache.get(key); ok {
+			s.config.Logger.WithModule(s.Name()).Debug("RAG cache hit", telemetry.String("query", query))
+			return results, nil
+		}
+	}
+
+	results, err := s.retrieveExpanded(ctx, s.expandQuery(ctx, query))
+	if err != nil {
+		return nil, err
+	}
+	results = s.rerankResults(ctx, query, results)
+	results = s.selectResults(results)
+
+	if s.cache != nil && key != "" {
+		s.cache.put(key, results)
+	}
+	return results, nil
+}
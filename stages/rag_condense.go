@@ -0,0 +1,110 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/creastat/infra/telemetry"
+	providers "github.com/creastat/providers/core"
+)
+
+// defaultCondenseHistoryTurns is how many recent messages are used for condensation
+const defaultCondenseHistoryTurns = 6
+
+// defaultCondensePrompt instructs the LLM to rewrite a follow-up into a standalone query
+const defaultCondensePrompt = `Rewrite the user's latest message as a standalone search query.
+Resolve pronouns and omitted subjects using the conversation. Keep the user's language.
+If the message is already standalone, return it unchanged. Reply with the query only.`
+
+// QueryCondenser rewrites a follow-up question into a standalone retrieval query
+// using recent conversation history (e.g. "how much does it cost?" becomes
+// "how much does the Pro plan cost?").
+type QueryCondenser interface {
+	Condense(ctx context.Context, query string, history []providers.Message) (string, error)
+}
+
+// ConversationHistoryProvider loads recent conversation turns at query time.
+type ConversationHistoryProvider interface {
+	RecentMessages(ctx context.Context, limit int) ([]providers.Message, error)
+}
+
+// LLMQueryCondenser condenses queries with a (typically small, fast) LLM.
+type LLMQueryCondenser struct {
+	Provider    providers.LLMProvider
+	Model       string
+	Prompt      string // Defaults to a built-in rewrite instruction
+	Temperature *float64
+}
+
+// Condense implements QueryCondenser
+func (c *LLMQueryCondenser) Condense(ctx context.Context, query string, history []providers.Message) (string, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultCondensePrompt
+	}
+
+	var transcript strings.Builder
+	for _, msg := range history {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	resp, err := c.Provider.ChatCompletion(ctx, providers.ChatRequest{
+		Model: c.Model,
+		Messages: []providers.Message{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: fmt.Sprintf("Conversation:\n%s\nLatest message: %s", transcript.String(), query)},
+		},
+		Temperature: c.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to condense query: %w", err)
+	}
+	if resp == nil {
+		return "", fmt.Errorf("failed to condense query: empty response")
+	}
+
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// condenseQuery resolves the query against recent history when a condenser is
+// configured. Any failure falls back to the original query.
+func (s *RAGStage) condenseQuery(ctx context.Context, query string) string {
+	if s.config.QueryCondenser == nil {
+		return query
+	}
+
+	logger := s.config.Logger.WithModule(s.Name())
+
+	limit := s.config.CondenseHistoryTurns
+	if limit <= 0 {
+		limit = defaultCondenseHistoryTurns
+	}
+
+	history := s.config.ConversationHistory
+	if s.config.HistoryProvider != nil {
+		loaded, err := s.config.HistoryProvider.RecentMessages(ctx, limit)
+		if err != nil {
+			logger.Warn("Failed to load conversation history for condensation", telemetry.Err(err))
+		} else {
+			history = loaded
+		}
+	}
+
+	// Nothing to resolve against - the query is already standalone
+	if len(history) == 0 {
+		return query
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	condensed, err := s.config.QueryCondenser.Condense(ctx, query, history)
+	if err != nil || condensed == "" {
+		logger.Warn("Query condensation failed, using original query", telemetry.Err(err))
+		return query
+	}
+
+	logger.Info("Condensed query", telemetry.String("query", query), telemetry.String("condensed", condensed))
+	return condensed
+}
@@ -0,0 +1,152 @@
+package stages
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/creastat/storage/vectorstore"
+)
+
+// defaultMMRLambda balances relevance against diversity when MMR is enabled
+const defaultMMRLambda = 0.7
+
+// candidateLimit returns how many results to fetch from vector search.
+// Reranking, deduplication and MMR need a larger candidate pool than
+// MaxChunks to have something to choose from.
+func (s *RAGStage) candidateLimit() int {
+	if s.config.DeduplicationThreshold <= 0 && !s.config.EnableMMR && s.config.Reranker == nil {
+		return s.config.MaxChunks
+	}
+	if s.config.CandidatePoolSize > s.config.MaxChunks {
+		return s.config.CandidatePoolSize
+	}
+	return s.config.MaxChunks * 3
+}
+
+// selectResults applies deduplication and MMR diversity selection to the
+// ranked search results and truncates them to MaxChunks.
+func (s *RAGStage) selectResults(results []vectorstore.SearchResult) []vectorstore.SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	tokens := make([]map[string]struct{}, len(results))
+	for i, result := range results {
+		tokens[i] = tokenSet(result.Content)
+	}
+
+	if s.config.DeduplicationThreshold > 0 {
+		results, tokens = deduplicateResults(results, tokens, s.config.DeduplicationThreshold)
+	}
+
+	if s.config.EnableMMR {
+		lambda := float32(defaultMMRLambda)
+		if s.config.MMRLambda != nil {
+			lambda = *s.config.MMRLambda
+		}
+		return selectMMR(results, tokens, s.config.MaxChunks, lambda)
+	}
+
+	if len(results) > s.config.MaxChunks {
+		results = results[:s.config.MaxChunks]
+	}
+	return results
+}
+
+// deduplicateResults drops results whose content is near-identical to a
+// higher-ranked result. Results are assumed to be sorted by descending score.
+func deduplicateResults(results []vectorstore.SearchResult, tokens []map[string]struct{}, threshold float32) ([]vectorstore.SearchResult, []map[string]struct{}) {
+	keptResults := make([]vectorstore.SearchResult, 0, len(results))
+	keptTokens := make([]map[string]struct{}, 0, len(results))
+
+	for i, result := range results {
+		duplicate := false
+		for _, kept := range keptTokens {
+			if jaccardSimilarity(tokens[i], kept) >= threshold {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		keptResults = append(keptResults, result)
+		keptTokens = append(keptTokens, tokens[i])
+	}
+
+	return keptResults, keptTokens
+}
+
+// selectMMR picks up to limit results using maximal marginal relevance:
+// each step selects the candidate maximizing
+// lambda*relevance - (1-lambda)*max_similarity_to_selected.
+// Content similarity is measured with Jaccard similarity over word sets.
+func selectMMR(results []vectorstore.SearchResult, tokens []map[string]struct{}, limit int, lambda float32) []vectorstore.SearchResult {
+	if limit > len(results) {
+		limit = len(results)
+	}
+
+	selected := make([]int, 0, limit)
+	used := make([]bool, len(results))
+
+	for len(selected) < limit {
+		best := -1
+		var bestScore float32
+
+		for i := range results {
+			if used[i] {
+				continue
+			}
+
+			var maxSimilarity float32
+			for _, j := range selected {
+				maxSimilarity = max(maxSimilarity, jaccardSimilarity(tokens[i], tokens[j]))
+			}
+
+			score := lambda*results[i].Score - (1-lambda)*maxSimilarity
+			if best == -1 || score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+
+		used[best] = true
+		selected = append(selected, best)
+	}
+
+	picked := make([]vectorstore.SearchResult, len(selected))
+	for i, idx := range selected {
+		picked[i] = results[idx]
+	}
+	return picked
+}
+
+// tokenSet splits text into a set of lowercase word tokens
+func tokenSet(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return set
+}
+
+// jaccardSimilarity returns |a ∩ b| / |a ∪ b|, or 0 when both sets are empty
+func jaccardSimilarity(a, b map[string]struct{}) float32 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for word := range a {
+		if _, ok := b[word]; ok {
+			intersection++
+		}
+	}
+
+	union := len(a) + len(b) - intersection
+	return float32(intersection) / float32(union)
+}
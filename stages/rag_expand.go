@@ -0,0 +1,210 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/creastat/infra/telemetry"
+	providers "github.com/creastat/providers/core"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// defaultExpansionCount is how many alternative queries are generated
+const defaultExpansionCount = 3
+
+// defaultRRFK dampens the influence of top ranks in reciprocal rank fusion
+const defaultRRFK = 60
+
+// defaultExpandPrompt instructs the LLM to produce alternative search queries
+const defaultExpandPrompt = `Generate %d alternative search queries for the user's question.
+Use different wording and synonyms, and spell out what a short spoken question implies.
+Keep the user's language. Reply with one query per line and nothing else.`
+
+// QueryExpander rewrites a query into alternative phrasings so retrieval
+// can match chunks that use different wording than the user.
+type QueryExpander interface {
+	Expand(ctx context.Context, query string, n int) ([]string, error)
+}
+
+// LLMQueryExpander generates alternative queries with an LLM.
+type LLMQueryExpander struct {
+	Provider    providers.LLMProvider
+	Model       string
+	Prompt      string // Format string with a %d verb for the query count; defaults to a built-in instruction
+	Temperature *float64
+}
+
+// Expand implements QueryExpander
+func (e *LLMQueryExpander) Expand(ctx context.Context, query string, n int) ([]string, error) {
+	prompt := e.Prompt
+	if prompt == "" {
+		prompt = defaultExpandPrompt
+	}
+
+	resp, err := e.Provider.ChatCompletion(ctx, providers.ChatRequest{
+		Model: e.Model,
+		Messages: []providers.Message{
+			{Role: "system", Content: fmt.Sprintf(prompt, n)},
+			{Role: "user", Content: query},
+		},
+		Temperature: e.Temperature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %w", err)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("failed to expand query: empty response")
+	}
+
+	var queries []string
+	for _, line := range strings.Split(resp.Content, "\n") {
+		// Tolerate list markers the model adds despite the instruction
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.)"))
+		if line != "" {
+			queries = append(queries, line)
+		}
+		if len(queries) == n {
+			break
+		}
+	}
+
+	return queries, nil
+}
+
+// expandQuery returns the query followed by its distinct alternatives when an
+// expander is configured. Any failure falls back to the original query alone.
+func (s *RAGStage) expandQuery(ctx context.Context, query string) []string {
+	queries := []string{query}
+	if s.config.QueryExpander == nil {
+		return queries
+	}
+
+	logger := s.config.Logger.WithModule(s.Name())
+
+	n := s.config.ExpansionCount
+	if n <= 0 {
+		n = defaultExpansionCount
+	}
+
+	alternatives, err := s.config.QueryExpander.Expand(ctx, query, n)
+	if err != nil {
+		logger.Warn("Query expansion failed, using original query", telemetry.Err(err))
+		return queries
+	}
+
+	seen := map[string]bool{strings.ToLower(query): true}
+	for _, alternative := range alternatives {
+		key := strings.ToLower(strings.TrimSpace(alternative))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, alternative)
+	}
+
+	logger.Info("Expanded query", telemetry.String("query", query), telemetry.Int("queries", len(queries)))
+	return queries
+}
+
+// retrieveExpanded embeds all queries, runs retrieval for them in parallel
+// and fuses the ranked lists with reciprocal rank fusion. A failing query is
+// skipped; an error is returned only if every query fails.
+func (s *RAGStage) retrieveExpanded(ctx context.Context, queries []string) ([]vectorstore.SearchResult, error) {
+	if len(queries) == 1 {
+		return s.retrieve(ctx, queries[0])
+	}
+
+	logger := s.config.Logger.WithModule(s.Name())
+
+	vectors, embedErrs := s.embedQueries(ctx, queries)
+	lists := make([][]vectorstore.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+
+	s.forEachBounded(len(queries), func(i int) {
+		lists[i], errs[i] = s.retrieveWith(ctx, queries[i], func() ([]vectorstore.SearchResult, error) {
+			if embedErrs[i] != nil {
+				return nil, embedErrs[i]
+			}
+			return s.search(ctx, vectors[i])
+		})
+	})
+
+	var ranked [][]vectorstore.SearchResult
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			logger.Warn("Expanded query retrieval failed, skipping", telemetry.String("query", queries[i]), telemetry.Err(err))
+			continue
+		}
+		ranked = append(ranked, lists[i])
+	}
+
+	if len(ranked) == 0 {
+		return nil, firstErr
+	}
+
+	fused := fuseReciprocalRank(ranked, s.rrfK())
+	if limit := s.candidateLimit(); len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused, nil
+}
+
+// rrfK returns the configured RRF constant or the default
+func (s *RAGStage) rrfK() int {
+	if s.config.RRFK > 0 {
+		return s.config.RRFK
+	}
+	return defaultRRFK
+}
+
+// fuseReciprocalRank merges ranked result lists, scoring each chunk by the
+// sum of 1/(k+rank) over the lists it appears in. Results are identified by
+// ID, falling back to content for stores that don't set IDs.
+func fuseReciprocalRank(lists [][]vectorstore.SearchResult, k int) []vectorstore.SearchResult {
+	return fuseWeightedReciprocalRank(lists, nil, k)
+}
+
+// fuseWeightedReciprocalRank is fuseReciprocalRank with each list's
+// contributions scaled by its weight. Lists without a weight count fully.
+func fuseWeightedReciprocalRank(lists [][]vectorstore.SearchResult, weights []float32, k int) []vectorstore.SearchResult {
+	scores := make(map[string]float32)
+	first := make(map[string]vectorstore.SearchResult)
+	var order []string
+
+	for i, list := range lists {
+		weight := float32(1)
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		for rank, result := range list {
+			key := result.ID
+			if key == "" {
+				key = result.Content
+			}
+			if _, seen := first[key]; !seen {
+				first[key] = result
+				order = append(order, key)
+			}
+			scores[key] += weight / float32(k+rank+1)
+		}
+	}
+
+	fused := make([]vectorstore.SearchResult, len(order))
+	for i, key := range order {
+		result := first[key]
+		result.Score = scores[key]
+		fused[i] = result
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+
+	return fused
+}
@@ -0,0 +1,162 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// ScoreNormalization defines how raw similarity scores from different vector
+// stores are made comparable before merging.
+type ScoreNormalization string
+
+const (
+	// ScoreNormalizationMinMax rescales each store's scores to the 0.0-1.0 range
+	ScoreNormalizationMinMax ScoreNormalization = "minmax"
+
+	// ScoreNormalizationNone keeps raw scores as returned by each store
+	ScoreNormalizationNone ScoreNormalization = "none"
+)
+
+// MetadataKeyStore is the result metadata key holding the name of the
+// VectorStoreSource that produced a federated result.
+const MetadataKeyStore = "rag_store"
+
+// VectorStoreSource is a single knowledge domain queried during federated retrieval.
+// Several sources may share a VectorStore and select different collections
+// of it with SourceIDs and Metadata.
+type VectorStoreSource struct {
+	// Name identifies the knowledge domain (e.g. "docs", "tickets").
+	Name string
+
+	// VectorStore is the store backing this domain.
+	VectorStore vectorstore.VectorStore
+
+	// Weight scales normalized scores from this store. Zero means 1.0.
+	Weight float32
+
+	// SourceIDs filters results within this store.
+	// Falls back to RAGStageConfig.SourceIDs/SourceID when empty.
+	SourceIDs []string
+
+	// Metadata filters results within this store by metadata key-value
+	// pairs, e.g. only resolved support tickets.
+	Metadata map[string]any
+
+	// MaxChunks limits results fetched from this store.
+	// Falls back to the stage's candidate limit when zero.
+	MaxChunks int
+}
+
+// storeResults holds the outcome of searching a single store
+type storeResults struct {
+	source  VectorStoreSource
+	results []vectorstore.SearchResult
+	err     error
+}
+
+// searchFederated queries all configured stores in parallel, normalizes and
+// weights their scores, and merges them into a single ranked candidate list.
+// A failing store is skipped; an error is returned only if every store fails.
+func (s *RAGStage) searchFederated(ctx context.Context, vector []float32) ([]vectorstore.SearchResult, error) {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	outcomes := make([]storeResults, len(s.config.VectorStores))
+
+	var wg sync.WaitGroup
+	for i, source := range s.config.VectorStores {
+		wg.Add(1)
+		go func(i int, source VectorStoreSource) {
+			defer wg.Done()
+
+			limit := source.MaxChunks
+			if limit <= 0 {
+				limit = s.candidateLimit()
+			}
+
+			filter := s.searchFilter(source.SourceIDs)
+			filter.Metadata = source.Metadata
+
+			results, err := source.VectorStore.Search(ctx, vector, filter, limit)
+			outcomes[i] = storeResults{source: source, results: results, err: err}
+		}(i, source)
+	}
+	wg.Wait()
+
+	var merged []vectorstore.SearchResult
+	var firstErr error
+	failed := 0
+
+	for _, outcome := range outcomes {
+		if outcome.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = outcome.err
+			}
+			logger.Warn("Vector store search failed, skipping", telemetry.String("store", outcome.source.Name), telemetry.Err(outcome.err))
+			continue
+		}
+
+		logger.Debug("Vector store search completed", telemetry.String("store", outcome.source.Name), telemetry.Int("results", len(outcome.results)))
+		merged = append(merged, s.weightResults(outcome.source, outcome.results)...)
+	}
+
+	if failed == len(outcomes) {
+		return nil, fmt.Errorf("vector search failed: %w", firstErr)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+
+	if limit := s.candidateLimit(); len(merged) > limit {
+		merged = merged[:limit]
+	}
+
+	return merged, nil
+}
+
+// weightResults normalizes and weights a single store's results, tagging each
+// with the store name. The input slice is not modified.
+func (s *RAGStage) weightResults(source VectorStoreSource, results []vectorstore.SearchResult) []vectorstore.SearchResult {
+	weight := source.Weight
+	if weight == 0 {
+		weight = 1
+	}
+
+	minScore, maxScore := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+	for _, result := range results {
+		minScore = min(minScore, result.Score)
+		maxScore = max(maxScore, result.Score)
+	}
+
+	weighted := make([]vectorstore.SearchResult, len(results))
+	for i, result := range results {
+		score := result.Score
+		if s.config.ScoreNormalization == ScoreNormalizationMinMax {
+			if maxScore > minScore {
+				score = (score - minScore) / (maxScore - minScore)
+			} else {
+				// A single result (or identical scores) carries full relevance
+				score = 1
+			}
+		}
+
+		metadata := make(map[string]any, len(result.Metadata)+1)
+		for k, v := range result.Metadata {
+			metadata[k] = v
+		}
+		metadata[MetadataKeyStore] = source.Name
+
+		result.Score = score * weight
+		result.Metadata = metadata
+		weighted[i] = result
+	}
+
+	return weighted
+}
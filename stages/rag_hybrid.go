@@ -0,0 +1,92 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// KeywordSearcher searches chunks by the words of the query, typically with
+// BM25 over a full-text index. Only the rank order of the results is used, so
+// scores need not be comparable to vector similarity. The filter carries the
+// stage's source IDs; MinScore is left unset for the same reason.
+type KeywordSearcher interface {
+	SearchKeywords(ctx context.Context, query string, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error)
+}
+
+// KeywordSearcherFunc adapts a function to the KeywordSearcher interface.
+type KeywordSearcherFunc func(ctx context.Context, query string, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error)
+
+// SearchKeywords implements KeywordSearcher
+func (f KeywordSearcherFunc) SearchKeywords(ctx context.Context, query string, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+	return f(ctx, query, filter, limit)
+}
+
+// retrieveHybrid runs the vector and keyword searches in parallel and fuses
+// their rankings. If one of them fails the other's results are used alone.
+func (s *RAGStage) retrieveHybrid(ctx context.Context, query string, vectorSearch func() ([]vectorstore.SearchResult, error)) ([]vectorstore.SearchResult, error) {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	var vectorResults, keywordResults []vectorstore.SearchResult
+	var vectorErr, keywordErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		vectorResults, vectorErr = vectorSearch()
+	}()
+	go func() {
+		defer wg.Done()
+		keywordResults, keywordErr = s.searchKeywords(ctx, query)
+	}()
+	wg.Wait()
+
+	var lists [][]vectorstore.SearchResult
+	var weights []float32
+	if vectorErr != nil {
+		logger.Warn("Vector search failed, using keyword results", telemetry.Err(vectorErr))
+	} else {
+		lists = append(lists, vectorResults)
+		weights = append(weights, 1)
+	}
+	if keywordErr != nil {
+		logger.Warn("Keyword search failed, using vector results", telemetry.Err(keywordErr))
+	} else {
+		lists = append(lists, keywordResults)
+		weights = append(weights, s.keywordWeight())
+	}
+
+	if len(lists) == 0 {
+		return nil, vectorErr
+	}
+
+	fused := fuseWeightedReciprocalRank(lists, weights, s.rrfK())
+	if limit := s.candidateLimit(); len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused, nil
+}
+
+// searchKeywords queries the keyword searcher with the stage's source filter
+func (s *RAGStage) searchKeywords(ctx context.Context, query string) ([]vectorstore.SearchResult, error) {
+	filter := s.searchFilter(nil)
+	filter.MinScore = 0
+
+	results, err := s.config.KeywordSearcher.SearchKeywords(ctx, query, filter, s.candidateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	return results, nil
+}
+
+// keywordWeight returns the configured keyword weight or the default
+func (s *RAGStage) keywordWeight() float32 {
+	if s.config.KeywordWeight > 0 {
+		return s.config.KeywordWeight
+	}
+	return 1
+}
@@ -0,0 +1,47 @@
+package stages
+
+import (
+	"context"
+	"sort"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/storage/vectorstore"
+)
+
+// Reranker re-scores retrieved chunks against the query, typically with a
+// cross-encoder or a provider rerank endpoint. Implementations return the
+// results with Score replaced by the relevance score; order does not matter.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error)
+}
+
+// RerankerFunc adapts a function to the Reranker interface.
+type RerankerFunc func(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error)
+
+// Rerank implements Reranker
+func (f RerankerFunc) Rerank(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	return f(ctx, query, results)
+}
+
+// rerankResults re-scores and re-orders the results when a reranker is
+// configured. Any failure keeps the vector search order.
+func (s *RAGStage) rerankResults(ctx context.Context, query string, results []vectorstore.SearchResult) []vectorstore.SearchResult {
+	if s.config.Reranker == nil || len(results) == 0 {
+		return results
+	}
+
+	logger := s.config.Logger.WithModule(s.Name())
+
+	reranked, err := s.config.Reranker.Rerank(ctx, query, results)
+	if err != nil {
+		logger.Warn("Reranking failed, using vector search order", telemetry.Err(err))
+		return results
+	}
+
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+
+	logger.Debug("Reranked results", telemetry.Int("count", len(reranked)))
+	return reranked
+}
@@ -0,0 +1,46 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// ragRetrieval is a context build running in the background, so retrieval
+// can start before the whole query has arrived
+type ragRetrieval struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	context   string
+	citations []core.CitationEvent
+	err       error
+}
+
+// startRetrieval condenses the query and builds its context in the
+// background
+func (s *RAGStage) startRetrieval(ctx context.Context, query string) *ragRetrieval {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &ragRetrieval{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		// Resolve follow-up questions against the conversation before retrieval
+		r.context, r.citations, r.err = s.buildContext(ctx, s.condenseQuery(ctx, query))
+	}()
+	return r
+}
+
+// wait returns the retrieval's context and citations once it is done
+func (r *ragRetrieval) wait() (string, []core.CitationEvent, error) {
+	<-r.done
+	return r.context, r.citations, r.err
+}
+
+// searchingStatus tells the client the knowledge base is being searched
+func searchingStatus() core.StatusEvent {
+	return core.StatusEvent{
+		Status:  core.StatusSearching,
+		Target:  core.StatusTargetBot,
+		Message: "Searching knowledge base...",
+	}
+}
@@ -3,8 +3,16 @@ package stages
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/creastat/pipeline"
+	"github.com/creastat/pipeline/core"
 	providers "github.com/creastat/providers/core"
 	"github.com/creastat/storage/vectorstore"
 	"pgregory.net/rapid"
@@ -152,8 +160,609 @@ func TestPropertyRAGFallback(t *testing.T) {
 	})
 }
 
+// For any set of federated vector stores, results SHALL be normalized per store,
+// weighted, merged by score, and truncated to MaxChunks.
+func TestRAGFederatedSearch(t *testing.T) {
+	docs := &TestStaticVectorStore{Results: []vectorstore.SearchResult{
+		{ID: "docs_1", Score: 0.9, Content: "docs best"},
+		{ID: "docs_2", Score: 0.8, Content: "docs worst"},
+	}}
+	tickets := &TestStaticVectorStore{Results: []vectorstore.SearchResult{
+		{ID: "tickets_1", Score: 0.75, Content: "tickets best"},
+		{ID: "tickets_2", Score: 0.71, Content: "tickets worst"},
+	}}
+
+	stage := NewRAGStage(RAGStageConfig{
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		MaxChunks:         3,
+		VectorStores: []VectorStoreSource{
+			{Name: "docs", VectorStore: docs, Weight: 1.0},
+			{Name: "tickets", VectorStore: tickets, Weight: 0.5},
+		},
+	})
+
+	results, err := stage.search(context.Background(), []float32{0.1})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	expected := []string{"docs_1", "tickets_1", "docs_2"}
+	for i, id := range expected {
+		if results[i].ID != id {
+			t.Errorf("result %d: expected %s, got %s", i, id, results[i].ID)
+		}
+	}
+
+	if results[1].Metadata[MetadataKeyStore] != "tickets" {
+		t.Errorf("expected store metadata 'tickets', got %v", results[1].Metadata[MetadataKeyStore])
+	}
+}
+
+// TestRAGFederatedSearchCollections tests that sources sharing a store are
+// searched with their own collection filters
+func TestRAGFederatedSearchCollections(t *testing.T) {
+	store := &TestFilterRecordingVectorStore{}
+	stage := NewRAGStage(RAGStageConfig{
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		Threshold:         0.4,
+		VectorStores: []VectorStoreSource{
+			{Name: "docs", VectorStore: store, SourceIDs: []string{"docs"}},
+			{Name: "tickets", VectorStore: store, SourceIDs: []string{"tickets"}, Metadata: map[string]any{"status": "resolved"}, Weight: 0.5},
+		},
+	})
+
+	if _, err := stage.search(context.Background(), []float32{0.1}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	filters := store.Filters()
+	sort.Slice(filters, func(i, j int) bool { return filters[i].SourceIDs[0] < filters[j].SourceIDs[0] })
+	expected := []vectorstore.SearchFilter{
+		{SourceIDs: []string{"docs"}, MinScore: 0.4},
+		{SourceIDs: []string{"tickets"}, Metadata: map[string]any{"status": "resolved"}, MinScore: 0.4},
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Errorf("expected filters %+v, got %+v", expected, filters)
+	}
+}
+
+// TestRAGFederatedSearchPartialFailure tests that a failing store doesn't fail retrieval
+func TestRAGFederatedSearchPartialFailure(t *testing.T) {
+	stage := NewRAGStage(RAGStageConfig{
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		VectorStores: []VectorStoreSource{
+			{Name: "docs", VectorStore: &TestVectorStore{}},
+			{Name: "broken", VectorStore: &TestErrorVectorStore{}},
+		},
+	})
+
+	results, err := stage.search(context.Background(), []float32{0.1})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	stage = NewRAGStage(RAGStageConfig{
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		VectorStores: []VectorStoreSource{
+			{Name: "broken", VectorStore: &TestErrorVectorStore{}},
+		},
+	})
+
+	if _, err := stage.search(context.Background(), []float32{0.1}); err == nil {
+		t.Fatal("expected error when all stores fail")
+	}
+}
+
+// TestRAGDeduplicationAndMMR tests that near-duplicate chunks are dropped and
+// MMR prefers diverse chunks over repeated ones
+func TestRAGDeduplicationAndMMR(t *testing.T) {
+	results := []vectorstore.SearchResult{
+		{ID: "a", Score: 0.95, Content: "The Pro plan costs 20 dollars per month"},
+		{ID: "a_copy", Score: 0.94, Content: "The Pro plan costs 20 dollars per month."},
+		{ID: "a_similar", Score: 0.93, Content: "The Pro plan costs 20 dollars per month billed yearly"},
+		{ID: "b", Score: 0.80, Content: "Refunds are available within 30 days"},
+	}
+
+	stage := NewRAGStage(RAGStageConfig{
+		MaxChunks:              2,
+		DeduplicationThreshold: 0.95,
+	})
+	selected := stage.selectResults(results)
+	if len(selected) != 2 || selected[0].ID != "a" || selected[1].ID != "a_similar" {
+		t.Fatalf("unexpected dedup selection: %+v", selected)
+	}
+
+	lambda := float32(0.5)
+	stage = NewRAGStage(RAGStageConfig{
+		MaxChunks:              2,
+		DeduplicationThreshold: 0.95,
+		EnableMMR:              true,
+		MMRLambda:              &lambda,
+	})
+	selected = stage.selectResults(results)
+	if len(selected) != 2 || selected[0].ID != "a" || selected[1].ID != "b" {
+		t.Fatalf("unexpected MMR selection: %+v", selected)
+	}
+
+	if limit := stage.candidateLimit(); limit != 6 {
+		t.Errorf("expected candidate limit 6, got %d", limit)
+	}
+
+	// The default lambda keeps the relevant near-duplicate, pure diversity
+	// (0) the unrelated chunk
+	results = []vectorstore.SearchResult{
+		results[0],
+		results[2],
+		{ID: "c", Score: 0.10, Content: "Contact support by email"},
+	}
+	stage = NewRAGStage(RAGStageConfig{MaxChunks: 2, EnableMMR: true})
+	if selected = stage.selectResults(results); len(selected) != 2 || selected[1].ID != "a_similar" {
+		t.Errorf("unexpected default MMR selection: %+v", selected)
+	}
+	lambda = 0
+	stage = NewRAGStage(RAGStageConfig{MaxChunks: 2, EnableMMR: true, MMRLambda: &lambda})
+	if selected = stage.selectResults(results); len(selected) != 2 || selected[1].ID != "c" {
+		t.Errorf("unexpected pure diversity MMR selection: %+v", selected)
+	}
+}
+
+// TestRAGQueryCondensation tests that follow-up queries are rewritten using history
+func TestRAGQueryCondensation(t *testing.T) {
+	history := []providers.Message{
+		{Role: "user", Content: "Tell me about the Pro plan"},
+		{Role: "assistant", Content: "The Pro plan includes unlimited projects."},
+	}
+
+	stage := NewRAGStage(RAGStageConfig{
+		QueryCondenser: &LLMQueryCondenser{
+			Provider: &TestStreamingLLMProvider{responseText: " How much does the Pro plan cost? "},
+		},
+		ConversationHistory: history,
+	})
+
+	condensed := stage.condenseQuery(context.Background(), "how much does it cost?")
+	if condensed != "How much does the Pro plan cost?" {
+		t.Errorf("unexpected condensed query: %q", condensed)
+	}
+
+	// Without history there is nothing to resolve against
+	stage = NewRAGStage(RAGStageConfig{
+		QueryCondenser: &LLMQueryCondenser{
+			Provider: &TestStreamingLLMProvider{responseText: "rewritten"},
+		},
+	})
+	if got := stage.condenseQuery(context.Background(), "original"); got != "original" {
+		t.Errorf("expected original query without history, got %q", got)
+	}
+}
+
+// TestRAGReranking tests that reranker scores reorder results before truncation
+func TestRAGReranking(t *testing.T) {
+	results := []vectorstore.SearchResult{
+		{ID: "a", Score: 0.95, Content: "pricing overview"},
+		{ID: "b", Score: 0.90, Content: "refund policy"},
+		{ID: "c", Score: 0.85, Content: "refund window is 30 days"},
+	}
+
+	stage := NewRAGStage(RAGStageConfig{
+		MaxChunks: 2,
+		Reranker: RerankerFunc(func(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+			scores := map[string]float32{"a": 0.1, "b": 0.6, "c": 0.9}
+			for i := range results {
+				results[i].Score = scores[results[i].ID]
+			}
+			return results, nil
+		}),
+	})
+
+	if limit := stage.candidateLimit(); limit != 6 {
+		t.Errorf("expected candidate limit 6, got %d", limit)
+	}
+
+	selected := stage.selectResults(stage.rerankResults(context.Background(), "refunds", results))
+	expected := []string{"c", "b"}
+	if len(selected) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(selected))
+	}
+	for i, id := range expected {
+		if selected[i].ID != id {
+			t.Errorf("result %d: expected %s, got %s", i, id, selected[i].ID)
+		}
+	}
+
+	// A failing reranker keeps the vector search order
+	stage = NewRAGStage(RAGStageConfig{
+		Reranker: RerankerFunc(func(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+			return nil, fmt.Errorf("rerank unavailable")
+		}),
+	})
+	fallback := []vectorstore.SearchResult{{ID: "x", Score: 0.9}, {ID: "y", Score: 0.8}}
+	if got := stage.rerankResults(context.Background(), "q", fallback); got[0].ID != "x" {
+		t.Errorf("expected original order on failure, got %s first", got[0].ID)
+	}
+}
+
+// TestRAGCitations tests that a citation is emitted for each chunk used as context
+func TestRAGCitations(t *testing.T) {
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore:       &TestVectorStore{},
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		MetadataProvider: TestMetadataProvider{
+			"doc_1": {Title: "Pricing", URL: "https://example.com/pricing"},
+		},
+	})
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "how much is it?"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var citations []core.CitationEvent
+	for event := range output {
+		if citation, ok := event.(core.CitationEvent); ok {
+			citations = append(citations, citation)
+		}
+	}
+
+	if len(citations) != 1 {
+		t.Fatalf("expected 1 citation, got %d", len(citations))
+	}
+	citation := citations[0]
+	if citation.DocumentID != "doc_1" || citation.ChunkID != "result_1" {
+		t.Errorf("unexpected citation ids: %+v", citation)
+	}
+	if citation.Title != "Pricing" || citation.URL != "https://example.com/pricing" {
+		t.Errorf("expected document metadata on citation, got %+v", citation)
+	}
+	if citation.Excerpt != "This is test content" || citation.Score != 0.95 {
+		t.Errorf("unexpected citation excerpt or score: %+v", citation)
+	}
+
+	long := strings.Repeat("a", citationExcerptLength+10)
+	if excerpt := citationExcerpt(long); len([]rune(excerpt)) != citationExcerptLength+1 {
+		t.Errorf("expected excerpt truncated to %d runes plus ellipsis, got %d", citationExcerptLength, len([]rune(excerpt)))
+	}
+}
+
+// TestRAGContextBudget tests that the context is cut to the token budget,
+// keeping chunks by priority and truncating the first one that doesn't fit
+func TestRAGContextBudget(t *testing.T) {
+	words := func(word string, n int) string {
+		return strings.TrimSpace(strings.Repeat(word+" ", n))
+	}
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore: &TestStaticVectorStore{Results: []vectorstore.SearchResult{
+			{ID: "a", Score: 0.9, Content: words("alpha", 10)},
+			{ID: "c", Score: 0.85, Content: words("gamma", 30)},
+			{ID: "b", Score: 0.8, Content: words("beta", 20), SourceID: "faq"},
+		}},
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		MaxContextTokens:  60,
+		CountTokens:       func(text string) int { return len(strings.Fields(text)) },
+	})
+
+	got, citations, err := stage.buildContext(context.Background(), "question")
+	if err != nil {
+		t.Fatalf("buildContext failed: %v", err)
+	}
+	expected := strings.Join([]string{words("alpha", 10), words("gamma", 30), words("beta", 18) + "…"}, ragContextSeparator)
+	if got != expected {
+		t.Errorf("expected context %q, got %q", expected, got)
+	}
+	if len(citations) != 3 {
+		t.Errorf("expected 3 citations, got %d", len(citations))
+	}
+
+	// Favoring the FAQ keeps it whole and cuts the lowest priority chunk
+	stage.config.ChunkPriority = func(result vectorstore.SearchResult) float32 {
+		if result.SourceID == "faq" {
+			return 2
+		}
+		return result.Score
+	}
+	got, _, err = stage.buildContext(context.Background(), "question")
+	if err != nil {
+		t.Fatalf("buildContext failed: %v", err)
+	}
+	expected = strings.Join([]string{words("alpha", 10), words("gamma", 28) + "…", words("beta", 20)}, ragContextSeparator)
+	if got != expected {
+		t.Errorf("expected context %q, got %q", expected, got)
+	}
+}
+
+// TestRAGQueryExpansion tests that alternative queries are parsed, deduplicated
+// and their results fused with reciprocal rank fusion
+func TestRAGQueryExpansion(t *testing.T) {
+	stage := NewRAGStage(RAGStageConfig{
+		QueryExpander: &LLMQueryExpander{
+			Provider: &TestStreamingLLMProvider{responseText: "1. Pro plan price\n- pro plan price\n\n2. Cost of the Pro subscription"},
+		},
+	})
+
+	queries := stage.expandQuery(context.Background(), "pro price")
+	expected := []string{"pro price", "Pro plan price", "Cost of the Pro subscription"}
+	if len(queries) != len(expected) {
+		t.Fatalf("expected %d queries, got %d: %v", len(expected), len(queries), queries)
+	}
+	for i, query := range expected {
+		if queries[i] != query {
+			t.Errorf("query %d: expected %q, got %q", i, query, queries[i])
+		}
+	}
+
+	fused := fuseReciprocalRank([][]vectorstore.SearchResult{
+		{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+		{{ID: "b"}, {ID: "c"}},
+		{{ID: "c"}, {ID: "d"}},
+	}, 60)
+
+	// c appears in every list, b ranks high in two
+	order := []string{"c", "b", "a", "d"}
+	for i, id := range order {
+		if fused[i].ID != id {
+			t.Errorf("fused result %d: expected %s, got %s", i, id, fused[i].ID)
+		}
+	}
+}
+
+// TestRAGHybridRetrieval tests that keyword results are fused with vector
+// results, and that a failing keyword search falls back to the vector results
+func TestRAGHybridRetrieval(t *testing.T) {
+	var keywordFilter vectorstore.SearchFilter
+	keywords := KeywordSearcherFunc(func(ctx context.Context, query string, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+		keywordFilter = filter
+		return []vectorstore.SearchResult{{ID: "e42", Score: 12.5, Content: "Error E-42 means the filter is clogged"}, {ID: "b", Score: 3}}, nil
+	})
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore: &TestStaticVectorStore{Results: []vectorstore.SearchResult{
+			{ID: "a", Score: 0.9}, {ID: "b", Score: 0.8},
+		}},
+		EmbeddingProvider: &TestEmbeddingProvider{},
+		KeywordSearcher:   keywords,
+		KeywordWeight:     2,
+		SourceIDs:         []string{"manuals"},
+	})
+
+	results, err := stage.retrieve(context.Background(), "what is error E-42")
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	// b is found by both, e42 ranks first in the heavier keyword list
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	if expected := []string{"b", "e42", "a"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+	if expected := (vectorstore.SearchFilter{SourceIDs: []string{"manuals"}}); !reflect.DeepEqual(keywordFilter, expected) {
+		t.Errorf("expected keyword filter %+v, got %+v", expected, keywordFilter)
+	}
+
+	stage.config.KeywordSearcher = KeywordSearcherFunc(func(ctx context.Context, query string, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+		return nil, fmt.Errorf("index unavailable")
+	})
+	results, err = stage.retrieve(context.Background(), "what is error E-42")
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "a" {
+		t.Errorf("expected vector results, got %+v", results)
+	}
+}
+
+// TestRAGRetrievalCache tests that a repeated question is served from the
+// cache until its entry expires
+func TestRAGRetrievalCache(t *testing.T) {
+	embedder := &TestRecordingEmbeddingProvider{queries: make(chan string, 10)}
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore:       &TestVectorStore{},
+		EmbeddingProvider: embedder,
+		CacheTTL:          time.Minute,
+	})
+	now := time.Now()
+	stage.cache.now = func() time.Time { return now }
+
+	for _, query := range []string{"What's the Pro price?", "what's the  pro price", "Another question"} {
+		if _, _, err := stage.buildContext(context.Background(), query); err != nil {
+			t.Fatalf("buildContext failed: %v", err)
+		}
+	}
+	if len(embedder.queries) != 2 {
+		t.Errorf("expected 2 embeddings, got %d", len(embedder.queries))
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, _, err := stage.buildContext(context.Background(), "What's the Pro price?"); err != nil {
+		t.Fatalf("buildContext failed: %v", err)
+	}
+	if len(embedder.queries) != 3 {
+		t.Errorf("expected expired entry to be retrieved again, got %d embeddings", len(embedder.queries))
+	}
+}
+
+// TestRAGBatchEmbedding tests that expanded queries are embedded in one
+// batch request and searched at most MaxConcurrentSearches at a time
+func TestRAGBatchEmbedding(t *testing.T) {
+	embedder := &TestBatchEmbeddingProvider{}
+	store := &TestConcurrencyVectorStore{}
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore:           store,
+		EmbeddingProvider:     embedder,
+		MaxConcurrentSearches: 2,
+	})
+
+	queries := []string{"pro price", "Pro plan price", "Cost of Pro", "Pro subscription fee", "How much is Pro"}
+	if _, err := stage.retrieveExpanded(context.Background(), queries); err != nil {
+		t.Fatalf("retrieveExpanded failed: %v", err)
+	}
+
+	if embedder.batches != 1 || embedder.singles.Load() != 0 {
+		t.Errorf("expected 1 batch and no single embeddings, got %d batches and %d singles", embedder.batches, embedder.singles.Load())
+	}
+	if store.searches.Load() != int32(len(queries)) {
+		t.Errorf("expected %d searches, got %d", len(queries), store.searches.Load())
+	}
+	if peak := store.peak.Load(); peak > 2 {
+		t.Errorf("expected at most 2 concurrent searches, got %d", peak)
+	}
+
+	// A failing batch falls back to embedding each query
+	embedder.fail = true
+	if _, err := stage.retrieveExpanded(context.Background(), queries); err != nil {
+		t.Fatalf("retrieveExpanded failed: %v", err)
+	}
+	if embedder.singles.Load() != int32(len(queries)) {
+		t.Errorf("expected %d single embeddings after a failed batch, got %d", len(queries), embedder.singles.Load())
+	}
+}
+
+// TestRAGStreamingRetrieval tests that retrieval starts on the first query
+// segment, before the DoneEvent, and is refined by follow-up segments
+func TestRAGStreamingRetrieval(t *testing.T) {
+	for _, refine := range []bool{false, true} {
+		embedder := &TestRecordingEmbeddingProvider{queries: make(chan string, 4)}
+		stage := NewRAGStage(RAGStageConfig{
+			VectorStore:        &TestVectorStore{},
+			EmbeddingProvider:  embedder,
+			StreamingRetrieval: true,
+			RefineRetrieval:    refine,
+		})
+
+		input := make(chan core.Event)
+		output := make(chan core.Event, 10)
+		result := make(chan error, 1)
+		go func() { result <- stage.Process(context.Background(), input, output) }()
+
+		input <- core.LLMEvent{Delta: "how much "}
+		select {
+		case query := <-embedder.queries:
+			if query != "how much " {
+				t.Errorf("expected the first segment embedded, got %q", query)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("retrieval didn't start before the query ended")
+		}
+
+		input <- core.LLMEvent{Delta: "is pro?"}
+		input <- core.DoneEvent{}
+		close(input)
+		if err := <-result; err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		close(output)
+
+		refined := ""
+		select {
+		case refined = <-embedder.queries:
+		default:
+		}
+		if refine && refined != "how much is pro?" {
+			t.Errorf("expected retrieval refined with the whole query, got %q", refined)
+		}
+		if !refine && refined != "" {
+			t.Errorf("expected a single retrieval, also got %q", refined)
+		}
+
+		statuses := 0
+		var enriched string
+		for event := range output {
+			switch e := event.(type) {
+			case core.StatusEvent:
+				statuses++
+			case core.LLMEvent:
+				enriched = e.Content
+			}
+		}
+		if statuses != 1 || !strings.Contains(enriched, "This is test content") || !strings.HasSuffix(enriched, "Question: how much is pro?") {
+			t.Errorf("unexpected output: %d statuses, query %q", statuses, enriched)
+		}
+	}
+}
+
 // Test implementations
 
+// TestRecordingEmbeddingProvider reports each query it embeds
+type TestRecordingEmbeddingProvider struct {
+	TestEmbeddingProvider
+	queries chan string
+}
+
+func (p *TestRecordingEmbeddingProvider) GenerateEmbedding(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	p.queries <- req.Text
+	return p.TestEmbeddingProvider.GenerateEmbedding(ctx, req)
+}
+
+// TestBatchEmbeddingProvider counts batch and single embedding requests
+type TestBatchEmbeddingProvider struct {
+	TestEmbeddingProvider
+	batches int
+	singles atomic.Int32
+	fail    bool
+}
+
+func (p *TestBatchEmbeddingProvider) GenerateEmbedding(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	p.singles.Add(1)
+	return p.TestEmbeddingProvider.GenerateEmbedding(ctx, req)
+}
+
+func (p *TestBatchEmbeddingProvider) GenerateEmbeddings(ctx context.Context, req BatchEmbeddingRequest) ([][]float32, error) {
+	p.batches++
+	if p.fail {
+		return nil, fmt.Errorf("batch embedding unavailable")
+	}
+	vectors := make([][]float32, len(req.Texts))
+	for i := range vectors {
+		vectors[i] = []float32{0.1}
+	}
+	return vectors, nil
+}
+
+// TestConcurrencyVectorStore records the peak number of concurrent searches
+type TestConcurrencyVectorStore struct {
+	TestVectorStore
+	searches atomic.Int32
+	active   atomic.Int32
+	peak     atomic.Int32
+}
+
+func (s *TestConcurrencyVectorStore) Search(ctx context.Context, vector []float32, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+	s.searches.Add(1)
+	active := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if active <= peak || s.peak.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return s.TestVectorStore.Search(ctx, vector, filter, limit)
+}
+
+// TestMetadataProvider implements DocumentMetadataProvider from a static map
+type TestMetadataProvider map[string]DocumentMetadata
+
+func (p TestMetadataProvider) GetDocumentMetadata(ctx context.Context, documentID string) (*DocumentMetadata, error) {
+	if doc, ok := p[documentID]; ok {
+		return &doc, nil
+	}
+	return nil, nil
+}
+
 // TestVectorStore implements vectorstore.VectorStore for testing
 type TestVectorStore struct{}
 
@@ -176,6 +785,45 @@ func (s *TestVectorStore) Close() error {
 	return nil
 }
 
+// TestStaticVectorStore returns a fixed result set for testing
+type TestStaticVectorStore struct {
+	Results []vectorstore.SearchResult
+}
+
+func (s *TestStaticVectorStore) Search(ctx context.Context, vector []float32, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+	if limit > 0 && len(s.Results) > limit {
+		return s.Results[:limit], nil
+	}
+	return s.Results, nil
+}
+
+func (s *TestStaticVectorStore) Close() error {
+	return nil
+}
+
+// TestFilterRecordingVectorStore records the filters it is searched with
+type TestFilterRecordingVectorStore struct {
+	mu      sync.Mutex
+	filters []vectorstore.SearchFilter
+}
+
+func (s *TestFilterRecordingVectorStore) Search(ctx context.Context, vector []float32, filter vectorstore.SearchFilter, limit int) ([]vectorstore.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filters = append(s.filters, filter)
+	return nil, nil
+}
+
+func (s *TestFilterRecordingVectorStore) Filters() []vectorstore.SearchFilter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]vectorstore.SearchFilter(nil), s.filters...)
+}
+
+func (s *TestFilterRecordingVectorStore) Close() error {
+	return nil
+}
+
 // TestErrorVectorStore returns errors for testing fallback
 type TestErrorVectorStore struct{}
 
@@ -233,3 +881,15 @@ func (p *TestErrorEmbeddingProvider) SupportsCapability(capability providers.Cap
 func (p *TestErrorEmbeddingProvider) GenerateEmbedding(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
 	return nil, fmt.Errorf("embedding generation error")
 }
+
+// TestRAGStageDoneContract tests that a pipeline ending with RAG passes the
+// DoneEvent check, as RAG ends each turn with one
+func TestRAGStageDoneContract(t *testing.T) {
+	stage := NewRAGStage(RAGStageConfig{
+		VectorStore:       &TestVectorStore{},
+		EmbeddingProvider: &TestEmbeddingProvider{},
+	})
+	if _, err := pipeline.Linear(stage); err != nil {
+		t.Errorf("expected RAG to declare its DoneEvent, got %v", err)
+	}
+}
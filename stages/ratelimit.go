@@ -0,0 +1,231 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a RateLimiter refuses a request. It is
+// retryable and classified as the service's rate-limited error.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit configures the requests allowed to one provider or model
+type RateLimit struct {
+	// Rate is the sustained number of stream starts per second, 0 for no
+	// rate limit
+	Rate float64
+
+	// Burst is how many starts may happen at once above the rate
+	// (default: 1)
+	Burst int
+
+	// MaxConcurrent caps the streams open at once, 0 for no cap
+	MaxConcurrent int
+
+	// Reject fails a request over the limit at once with ErrRateLimited
+	// instead of queueing it
+	Reject bool
+
+	// MaxWait is the longest a queued request waits before failing with
+	// ErrRateLimited, 0 to wait as long as its context allows
+	MaxWait time.Duration
+}
+
+// RateLimiter keeps provider requests within their rate limits and
+// concurrency caps. Share one between the stages of every pipeline in the
+// process, like a TTSCache, so a burst of sessions queues or fails fast
+// instead of tripping the provider's own limits. It is safe for concurrent
+// use; a nil RateLimiter allows everything.
+type RateLimiter struct {
+	limits map[string]RateLimit
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// rateBucket is the token bucket and open streams of one limit
+type rateBucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+	active  int
+	freed   chan struct{} // Closed when a stream ends, then replaced
+}
+
+// NewRateLimiter creates a limiter with limits keyed by provider name, or by
+// "provider/model" to limit a model on its own. Providers without a limit
+// are not limited.
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// Acquire waits until a stream may be started on the provider's model, or
+// fails with ErrRateLimited according to its limit. Call release once the
+// stream is closed.
+func (r *RateLimiter) Acquire(ctx context.Context, provider, model string) (release func(), err error) {
+	key, bucket := r.bucket(provider, model)
+	if bucket == nil {
+		return func() {}, nil
+	}
+
+	var deadline <-chan time.Time
+	if bucket.limit.MaxWait > 0 && !bucket.limit.Reject {
+		timer := time.NewTimer(bucket.limit.MaxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		wait, freed, ok := r.take(bucket)
+		if ok {
+			return sync.OnceFunc(func() { r.release(bucket) }), nil
+		}
+		if bucket.limit.Reject {
+			return nil, fmt.Errorf("%s: %w", key, ErrRateLimited)
+		}
+
+		if err := waitForSlot(ctx, wait, freed, deadline); err != nil {
+			if errors.Is(err, ErrRateLimited) {
+				return nil, fmt.Errorf("%s: waited %s: %w", key, bucket.limit.MaxWait, err)
+			}
+			return nil, err
+		}
+	}
+}
+
+// waitForSlot waits for the next token, when wait is set, or for a stream
+// to end. It fails with ErrRateLimited once the deadline passes.
+func waitForSlot(ctx context.Context, wait time.Duration, freed <-chan struct{}, deadline <-chan time.Time) error {
+	var refill <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		refill = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-deadline:
+		return ErrRateLimited
+	case <-refill:
+	case <-freed:
+	}
+	return nil
+}
+
+// bucket returns the bucket limiting a provider's model, nil when it has no
+// limit
+func (r *RateLimiter) bucket(provider, model string) (string, *rateBucket) {
+	if r == nil {
+		return "", nil
+	}
+	key := provider + "/" + model
+	limit, ok := r.limits[key]
+	if !ok || model == "" {
+		key = provider
+		if limit, ok = r.limits[key]; !ok {
+			return "", nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		if limit.Burst <= 0 {
+			limit.Burst = 1
+		}
+		bucket = &rateBucket{
+			limit:   limit,
+			tokens:  float64(limit.Burst),
+			updated: r.now(),
+			freed:   make(chan struct{}),
+		}
+		r.buckets[key] = bucket
+	}
+	return key, bucket
+}
+
+// take starts a stream if the bucket allows it. Otherwise it returns how
+// long until the next token, 0 when waiting on the concurrency cap, and
+// the channel closed when a stream ends.
+func (r *RateLimiter) take(bucket *rateBucket) (time.Duration, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limit := bucket.limit
+	if limit.MaxConcurrent > 0 && bucket.active >= limit.MaxConcurrent {
+		return 0, bucket.freed, false
+	}
+	if limit.Rate > 0 {
+		now := r.now()
+		elapsed := now.Sub(bucket.updated).Seconds()
+		bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*limit.Rate)
+		bucket.updated = now
+		if bucket.tokens < 1 {
+			wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+			return max(wait, time.Millisecond), bucket.freed, false
+		}
+		bucket.tokens--
+	}
+	bucket.active++
+	return 0, nil, true
+}
+
+// release ends a stream, waking the requests waiting for a slot
+func (r *RateLimiter) release(bucket *rateBucket) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket.active--
+	close(bucket.freed)
+	bucket.freed = make(chan struct{})
+}
+
+// limitStart wraps a provider stream start with the limiter. Pass each
+// stream it started to the returned release once that stream is closed or
+// abandoned, to free its slot; other values are ignored.
+func limitStart[P namedProvider, T comparable](limiter *RateLimiter, model string, start func(ctx context.Context, provider P) (T, error)) (func(ctx context.Context, provider P) (T, error), func(T)) {
+	if limiter == nil {
+		return start, func(T) {}
+	}
+
+	var mu sync.Mutex
+	releases := make(map[T]func())
+	limited := func(ctx context.Context, provider P) (T, error) {
+		release, err := limiter.Acquire(ctx, provider.Name(), model)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		result, err := start(ctx, provider)
+		if err != nil {
+			release()
+			return result, err
+		}
+		mu.Lock()
+		releases[result] = release
+		mu.Unlock()
+		return result, nil
+	}
+	releaseStream := func(result T) {
+		mu.Lock()
+		release, ok := releases[result]
+		delete(releases, result)
+		mu.Unlock()
+		if ok {
+			release()
+		}
+	}
+	return limited, releaseStream
+}
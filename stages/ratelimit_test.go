@@ -0,0 +1,136 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/pipelinetest"
+	providers "github.com/creastat/providers/core"
+)
+
+// TestRateLimiter tests the token bucket, the concurrency cap and the
+// queue and reject policies
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(map[string]RateLimit{
+		"llm":         {Rate: 1, Burst: 2, Reject: true},
+		"llm/premium": {MaxConcurrent: 1, MaxWait: 20 * time.Millisecond},
+	})
+	limiter.now = func() time.Time { return now }
+
+	// The burst is spent, then requests fail until a token is refilled
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Acquire(ctx, "llm", "basic"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	_, err := limiter.Acquire(ctx, "llm", "basic")
+	if !errors.Is(err, ErrRateLimited) || !IsRetryableError(err) {
+		t.Errorf("expected a retryable ErrRateLimited, got %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := limiter.Acquire(ctx, "llm", ""); err != nil {
+		t.Errorf("expected a refilled token, got %v", err)
+	}
+
+	// Unlimited providers pass
+	if _, err := limiter.Acquire(ctx, "tts", ""); err != nil {
+		t.Errorf("expected an unlimited provider to pass, got %v", err)
+	}
+
+	// A model's own limit queues until a stream is released
+	release, err := limiter.Acquire(ctx, "llm", "premium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.Acquire(ctx, "llm", "premium"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected the queued request to give up after MaxWait, got %v", err)
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		release()
+		release() // Releasing twice frees one slot
+	}()
+	second, err := limiter.Acquire(ctx, "llm", "premium")
+	if err != nil {
+		t.Fatalf("expected the queued request to start once released, got %v", err)
+	}
+	defer second()
+	if _, err := limiter.Acquire(ctx, "llm", "premium"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected a double release to free one slot only, got %v", err)
+	}
+}
+
+// TestLimitStartRelease tests that each stream's slot is freed on its own
+// once the stream is released
+func TestLimitStartRelease(t *testing.T) {
+	ctx := context.Background()
+	llm := pipelinetest.NewFakeLLM("Hello")
+	limiter := NewRateLimiter(map[string]RateLimit{
+		llm.Name(): {MaxConcurrent: 1, Reject: true},
+	})
+	start, release := limitStart(limiter, "", func(ctx context.Context, provider providers.LLMProvider) (providers.ChatStream, error) {
+		return provider.StreamChatCompletion(ctx, providers.ChatRequest{})
+	})
+
+	first, err := start(ctx, llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := start(ctx, llm); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected the open stream to hold the slot, got %v", err)
+	}
+	release(first)
+	second, err := start(ctx, llm)
+	if err != nil {
+		t.Fatalf("expected the released stream's slot to be free, got %v", err)
+	}
+	release(first) // Releasing a stream twice doesn't free another's slot
+	if _, err := start(ctx, llm); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected the second stream to keep its slot, got %v", err)
+	}
+	release(second)
+}
+
+// TestLLMStageRateLimited tests that a rejected request ends the turn with a
+// retryable rate-limit error, and that the stream's slot is released
+func TestLLMStageRateLimited(t *testing.T) {
+	llm := pipelinetest.NewFakeLLM("Hello")
+	limiter := NewRateLimiter(map[string]RateLimit{
+		llm.Name(): {MaxConcurrent: 1, Reject: true},
+	})
+	stage := NewLLMStage(LLMStageConfig{Provider: llm, Limiter: limiter, Logger: testLogger()})
+
+	for turn := 0; turn < 2; turn++ {
+		results := runTurn(t, stage, core.LLMEvent{Delta: "Hi"}, core.DoneEvent{})
+		for _, event := range results {
+			if e, ok := event.(core.ErrorEvent); ok {
+				t.Fatalf("turn %d: unexpected error %v", turn, e.Error)
+			}
+		}
+	}
+
+	release, err := limiter.Acquire(context.Background(), llm.Name(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	results := runTurn(t, stage, core.LLMEvent{Delta: "Hi"}, core.DoneEvent{})
+	var rejected *core.ErrorEvent
+	for _, event := range results {
+		if e, ok := event.(core.ErrorEvent); ok {
+			rejected = &e
+		}
+	}
+	if rejected == nil || !rejected.Retryable || rejected.ErrCode() != core.ErrCodeLLMRateLimited {
+		t.Errorf("expected a retryable %s error, got %+v", core.ErrCodeLLMRateLimited, rejected)
+	}
+	if len(llm.Requests()) != 2 {
+		t.Errorf("expected the rejected turn not to reach the provider, got %d requests", len(llm.Requests()))
+	}
+}
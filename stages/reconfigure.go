@@ -0,0 +1,176 @@
+package stages
+
+import (
+	"context"
+	"sync"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// pendingConfig holds configuration updates received while a stage runs.
+// Stages take them at the start of their next turn, so a turn never switches
+// language or provider halfway through.
+type pendingConfig struct {
+	mu     sync.Mutex
+	update *core.ConfigUpdateEvent
+}
+
+// set queues an update, merging it with any update not yet taken
+func (c *pendingConfig) set(update core.ConfigUpdateEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.update != nil {
+		update = c.update.Merge(update)
+	}
+	c.update = &update
+}
+
+// take returns the queued update and clears it
+func (c *pendingConfig) take() (core.ConfigUpdateEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.update == nil {
+		return core.ConfigUpdateEvent{}, false
+	}
+	update := *c.update
+	c.update = nil
+	return update, true
+}
+
+// selectPreset returns the provider configured for a preset name, logging
+// and keeping current when the name is unknown
+func selectPreset[P any](presets map[string]P, name string, current P, logger telemetry.Logger) P {
+	if name == "" {
+		return current
+	}
+	provider, ok := presets[name]
+	if !ok {
+		logger.Warn("Unknown provider preset, keeping current provider", telemetry.String("preset", name))
+		return current
+	}
+	logger.Info("Switching provider preset", telemetry.String("preset", name))
+	return provider
+}
+
+// ApplyConfig queues a configuration update for the next turn
+func (s *STTStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	s.updates.set(update)
+}
+
+// applyPendingConfig applies the update queued by ApplyConfig, if any
+func (s *STTStage) applyPendingConfig() {
+	update, ok := s.updates.take()
+	if !ok {
+		return
+	}
+	logger := s.config.Logger.WithModule(s.Name())
+	if update.Language != "" {
+		s.config.Language = update.Language
+	}
+	if update.Keywords != nil {
+		s.sessionKeywords = update.Keywords
+	}
+	s.config.Provider = selectPreset(s.config.Presets, update.Providers.STT, s.config.Provider, logger)
+}
+
+// ApplyConfig queues a configuration update for the next turn
+func (s *LLMStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	s.updates.set(update)
+}
+
+// applyPendingConfig applies the update queued by ApplyConfig, if any
+func (s *LLMStage) applyPendingConfig() {
+	update, ok := s.updates.take()
+	if !ok {
+		return
+	}
+	logger := s.config.Logger.WithModule(s.Name())
+	if update.Language != "" {
+		s.config.Language = update.Language
+	}
+	s.config.Provider = selectPreset(s.config.Presets, update.Providers.LLM, s.config.Provider, logger)
+}
+
+// ApplyConfig queues a configuration update for the next turn
+func (s *TTSStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	s.updates.set(update)
+}
+
+// applyPendingConfig applies the update queued by ApplyConfig, if any
+func (s *TTSStage) applyPendingConfig() {
+	update, ok := s.updates.take()
+	if !ok {
+		return
+	}
+	logger := s.config.Logger.WithModule(s.Name())
+	if update.Language != "" {
+		s.config.Language, s.config.Voice = s.voiceFor(update.Language)
+	}
+	if update.TTSEnabled != nil {
+		s.config.Disabled = !*update.TTSEnabled
+	}
+	s.config.Provider = selectPreset(s.config.Presets, update.Providers.TTS, s.config.Provider, logger)
+}
+
+// skipTurn consumes a turn without synthesizing it while TTS is disabled,
+// still completing the turn so barriers and clients see it end
+func (s *TTSStage) skipTurn(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	done := core.DoneEvent{}
+	for event := range input {
+		switch e := event.(type) {
+		case core.UsageEvent, core.LatencyMarkEvent, core.LanguageDetectedEvent:
+			output <- e
+		case core.InterruptEvent:
+			done.Interrupted = true
+			goto Done
+		case core.DoneEvent:
+			goto Done
+		}
+	}
+
+Done:
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case output <- done:
+	}
+	return nil
+}
+
+// ApplyConfig queues a configuration update for the next turn
+func (s *RAGStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	s.updates.set(update)
+}
+
+// applyPendingConfig applies the update queued by ApplyConfig, if any
+func (s *RAGStage) applyPendingConfig() {
+	update, ok := s.updates.take()
+	if !ok {
+		return
+	}
+	logger := s.config.Logger.WithModule(s.Name())
+	s.config.EmbeddingProvider = selectPreset(s.config.EmbeddingPresets, update.Providers.Embedding, s.config.EmbeddingProvider, logger)
+	if update.Providers.Embedding != "" && s.cache != nil {
+		// Results of the previous embedding model are not reused
+		s.cache.clear()
+	}
+}
+
+// ApplyConfig queues a configuration update for the next turn
+func (s *TextProcessorStage) ApplyConfig(update core.ConfigUpdateEvent) {
+	s.updates.set(update)
+}
+
+// applyPendingConfig applies the update queued by ApplyConfig, if any
+func (s *TextProcessorStage) applyPendingConfig() {
+	update, ok := s.updates.take()
+	if !ok {
+		return
+	}
+	if update.Language != "" {
+		s.config.Language = update.Language
+	}
+}
@@ -0,0 +1,133 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+// runTurn runs one turn of a stage and collects its output
+func runTurn(t *testing.T, stage core.Stage, events ...core.Event) []core.Event {
+	t.Helper()
+
+	input := make(chan core.Event, len(events))
+	output := make(chan core.Event, 100)
+	for _, event := range events {
+		input <- event
+	}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var collected []core.Event
+	for event := range output {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+// TestLLMStageConfigUpdate tests that a provider preset switch applies from
+// the next turn and an unknown preset keeps the current provider
+func TestLLMStageConfigUpdate(t *testing.T) {
+	stage := NewLLMStage(LLMStageConfig{
+		Provider: &TestStreamingLLMProvider{responseText: "primary"},
+		Presets: map[string]providers.LLMProvider{
+			"alt": &TestStreamingLLMProvider{responseText: "alternative"},
+		},
+		Model:  "gpt-4",
+		Logger: testLogger(),
+	})
+
+	fullText := func(events []core.Event) string {
+		for _, event := range events {
+			if done, ok := event.(core.DoneEvent); ok {
+				return done.FullText
+			}
+		}
+		return ""
+	}
+
+	if got := fullText(runTurn(t, stage, core.STTEvent{Text: "hi"}, core.DoneEvent{})); got != "primary" {
+		t.Fatalf("expected primary response, got %q", got)
+	}
+
+	stage.ApplyConfig(core.ConfigUpdateEvent{Providers: core.ProviderPresets{LLM: "alt"}})
+	if got := fullText(runTurn(t, stage, core.STTEvent{Text: "hi"}, core.DoneEvent{})); got != "alternative" {
+		t.Fatalf("expected alternative response, got %q", got)
+	}
+
+	stage.ApplyConfig(core.ConfigUpdateEvent{Providers: core.ProviderPresets{LLM: "missing"}})
+	if got := fullText(runTurn(t, stage, core.STTEvent{Text: "hi"}, core.DoneEvent{})); got != "alternative" {
+		t.Fatalf("expected unknown preset to keep the provider, got %q", got)
+	}
+}
+
+// TestTTSStageDisabledByConfig tests that turning TTS off completes turns
+// without synthesis and turning it back on resumes synthesis
+func TestTTSStageDisabledByConfig(t *testing.T) {
+	stage := NewTTSStage(TTSStageConfig{
+		Provider: &TestStreamingTTSProvider{},
+		Voice:    "en-US-Neural2-C",
+		Language: "en",
+		Logger:   testLogger(),
+	})
+
+	countAudio := func(events []core.Event) (audio int, done bool) {
+		for _, event := range events {
+			switch event.(type) {
+			case core.AudioEvent:
+				audio++
+			case core.DoneEvent:
+				done = true
+			}
+		}
+		return audio, done
+	}
+
+	disabled := false
+	stage.ApplyConfig(core.ConfigUpdateEvent{TTSEnabled: &disabled})
+	audio, done := countAudio(runTurn(t, stage, core.LLMEvent{Delta: "Hello there."}, core.DoneEvent{}))
+	if audio != 0 || !done {
+		t.Fatalf("expected a completed turn without audio, got %d audio events, done=%v", audio, done)
+	}
+
+	enabled := true
+	stage.ApplyConfig(core.ConfigUpdateEvent{TTSEnabled: &enabled})
+	audio, _ = countAudio(runTurn(t, stage, core.LLMEvent{Delta: "Hello there."}, core.DoneEvent{}))
+	if audio == 0 {
+		t.Fatal("expected audio after re-enabling TTS")
+	}
+}
+
+// TestTTSStageLanguageUpdate tests that a language update selects the
+// language's voice for the next turn
+func TestTTSStageLanguageUpdate(t *testing.T) {
+	stage := NewTTSStage(TTSStageConfig{
+		Provider: &TestStreamingTTSProvider{},
+		Voice:    "en-US-Neural2-C",
+		Voices:   map[string]string{"de": "de-DE-Neural2-B"},
+		Language: "en",
+		Logger:   testLogger(),
+	})
+
+	stage.ApplyConfig(core.ConfigUpdateEvent{Language: "de-AT"})
+	stage.applyPendingConfig()
+
+	if stage.config.Language != "de-AT" || stage.config.Voice != "de-DE-Neural2-B" {
+		t.Errorf("expected de-AT with the German voice, got %q/%q", stage.config.Language, stage.config.Voice)
+	}
+}
+
+func testLogger() telemetry.Logger {
+	return telemetry.New(telemetry.Config{Level: "error"})
+}
@@ -0,0 +1,191 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// Default retry settings
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+	DefaultRetryMaxBackoff     = 2 * time.Second
+	DefaultRetryMultiplier     = 2.0
+	DefaultRetryJitter         = 0.2
+)
+
+// RetryPolicy configures retries of provider stream starts
+// (StreamTranscribe, StreamChatCompletion, StreamSynthesize)
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each attempt
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (0.2 = ±20%)
+	Jitter float64
+
+	// Retryable classifies errors; defaults to IsRetryableError
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns a policy suited to transient provider failures
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+		Multiplier:     DefaultRetryMultiplier,
+		Jitter:         DefaultRetryJitter,
+	}
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry)
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	for i := 1; i < retry; i++ {
+		delay *= multiplier
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// retryable reports whether err should be retried under this policy
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// IsRetryableError is the default classifier. It treats rate limits, timeouts,
+// and 5xx-style upstream failures as transient, and cancellation as final.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode()
+		return code == 408 || code == 429 || code >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"429", "rate limit", "too many requests", "502", "503", "504", "timeout", "temporarily unavailable", "connection reset"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyProviderError returns the ErrCode of a provider failure, using the
+// service's own codes for timeouts and rate limits. An error that already
+// carries a code keeps it.
+func classifyProviderError(err error, timeout, rateLimited core.ErrCode) core.ErrCode {
+	if code := core.ErrCodeOf(err); code != core.ErrCodeInternal {
+		return code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timeout
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode(); {
+		case code == 408 || code == 504:
+			return timeout
+		case code == 429:
+			return rateLimited
+		case code >= 500:
+			return core.ErrCodeProviderUnavailable
+		}
+		return core.ErrCodeProviderError
+	}
+
+	msg := strings.ToLower(err.Error())
+	classes := []struct {
+		code    core.ErrCode
+		markers []string
+	}{
+		{rateLimited, []string{"429", "rate limit", "too many requests"}},
+		{timeout, []string{"504", "timeout", "timed out"}},
+		{core.ErrCodeProviderUnavailable, []string{"502", "503", "unavailable", "connection refused", "connection reset", "no provider configured"}},
+	}
+	for _, class := range classes {
+		for _, marker := range class.markers {
+			if strings.Contains(msg, marker) {
+				return class.code
+			}
+		}
+	}
+	return core.ErrCodeProviderError
+}
+
+// withRetry runs start until it succeeds, fails with a non-retryable error,
+// or the policy's attempts are exhausted. A nil policy makes a single attempt.
+func withRetry[T any](ctx context.Context, policy *RetryPolicy, logger telemetry.Logger, start func(ctx context.Context) (T, error)) (T, error) {
+	result, err := start(ctx)
+	if err == nil || policy == nil {
+		return result, err
+	}
+
+	for attempt := 2; attempt <= policy.MaxAttempts; attempt++ {
+		if !policy.retryable(err) {
+			return result, err
+		}
+
+		backoff := policy.Backoff(attempt - 1)
+		logger.Warn("Provider stream failed to start, retrying",
+			telemetry.Err(err),
+			telemetry.Int("attempt", attempt),
+			telemetry.String("backoff", backoff.String()))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+
+		result, err = start(ctx)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	return result, err
+}
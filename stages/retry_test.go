@@ -0,0 +1,132 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+// TestIsRetryableError tests the default error classifier
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("status 429: too many requests"), true},
+		{errors.New("upstream returned 503"), true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("invalid api key"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryableError(tt.err); got != tt.want {
+			t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestClassifyProviderError tests the ErrCodes reported for provider failures
+func TestClassifyProviderError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want core.ErrCode
+	}{
+		{errors.New("status 429: too many requests"), core.ErrCodeLLMRateLimited},
+		{context.DeadlineExceeded, core.ErrCodeLLMTimeout},
+		{errors.New("dial tcp: i/o timeout"), core.ErrCodeLLMTimeout},
+		{errors.New("upstream returned 503"), core.ErrCodeProviderUnavailable},
+		{webhookStatusError{code: 502}, core.ErrCodeProviderUnavailable},
+		{errors.New("invalid api key"), core.ErrCodeProviderError},
+		{core.WithErrCode(errors.New("quota exceeded"), core.ErrCodeLLMRateLimited), core.ErrCodeLLMRateLimited},
+	}
+
+	for _, tt := range tests {
+		if got := classifyProviderError(tt.err, core.ErrCodeLLMTimeout, core.ErrCodeLLMRateLimited); got != tt.want {
+			t.Errorf("classifyProviderError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestRetryPolicyBackoff tests exponential growth capped by MaxBackoff
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	for i, expected := range want {
+		if got := policy.Backoff(i + 1); got != expected {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, expected)
+		}
+	}
+}
+
+// TestLLMStageRetriesStreamStart tests that a transient start failure is retried
+func TestLLMStageRetriesStreamStart(t *testing.T) {
+	provider := &TestFlakyLLMProvider{
+		TestStreamingLLMProvider: TestStreamingLLMProvider{responseText: "Hello there"},
+		failures:                 2,
+	}
+	stage := NewLLMStage(LLMStageConfig{
+		Provider: provider,
+		Model:    "gpt-4",
+		Retry: &RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 100)
+	input <- core.STTEvent{Text: "hi"}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var done *core.DoneEvent
+	for event := range output {
+		if _, ok := event.(core.ErrorEvent); ok {
+			t.Errorf("unexpected error event after successful retry")
+		}
+		if e, ok := event.(core.DoneEvent); ok {
+			done = &e
+		}
+	}
+
+	if provider.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", provider.attempts)
+	}
+	if done == nil || done.FullText != "Hello there" {
+		t.Errorf("unexpected DoneEvent: %+v", done)
+	}
+}
+
+// TestFlakyLLMProvider fails to start a stream a fixed number of times
+type TestFlakyLLMProvider struct {
+	TestStreamingLLMProvider
+	failures int
+	attempts int
+}
+
+func (m *TestFlakyLLMProvider) StreamChatCompletion(ctx context.Context, req providers.ChatRequest) (providers.ChatStream, error) {
+	m.attempts++
+	if m.attempts <= m.failures {
+		return nil, errors.New("status 429: rate limit exceeded")
+	}
+	return m.TestStreamingLLMProvider.StreamChatCompletion(ctx, req)
+}
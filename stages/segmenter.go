@@ -0,0 +1,103 @@
+package stages
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segmenter decides where sentences end in streamed text, so TTS is given
+// whole sentences. TextProcessorStage picks one by language.
+type Segmenter interface {
+	// SentenceEnd reports whether text ends with a complete sentence
+	SentenceEnd(text string) bool
+}
+
+// PunctuationSegmenter ends sentences at terminal punctuation, optionally
+// followed by closing quotes or brackets
+type PunctuationSegmenter struct {
+	// Terminators are the runes that end a sentence, e.g. ".!?"
+	Terminators string
+
+	// Abbreviations end in a terminator without ending the sentence,
+	// e.g. "Dr."
+	Abbreviations []string
+
+	// Openers maps inverted marks to the terminator that closes them, e.g.
+	// '¿' to '?'. A sentence doesn't end while one is open.
+	Openers map[rune]rune
+}
+
+// sentenceClosers may follow a terminator at the end of a sentence
+const sentenceClosers = `"')]”’»」』）`
+
+// SentenceEnd implements Segmenter
+func (p PunctuationSegmenter) SentenceEnd(text string) bool {
+	trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
+	trimmed = strings.TrimRight(trimmed, sentenceClosers)
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+	if trimmed == "" || !strings.ContainsRune(p.Terminators, last) {
+		return false
+	}
+
+	// A period right after a digit may be a decimal point, "$4." of "$4.50",
+	// until whitespace follows it
+	if before, _ := utf8.DecodeLastRuneInString(trimmed[:len(trimmed)-1]); last == '.' && unicode.IsDigit(before) && trimmed == text {
+		return false
+	}
+
+	for _, abbr := range p.Abbreviations {
+		if !strings.HasSuffix(trimmed, abbr) {
+			continue
+		}
+		// Only whole words count, "Co." doesn't match "Taco."
+		before, _ := utf8.DecodeLastRuneInString(trimmed[:len(trimmed)-len(abbr)])
+		if !unicode.IsLetter(before) {
+			return false
+		}
+	}
+
+	for opener, closer := range p.Openers {
+		if strings.LastIndex(trimmed, string(opener)) > strings.LastIndex(trimmed, string(closer)) {
+			return false
+		}
+	}
+	return true
+}
+
+// englishAbbreviations don't end English sentences
+var englishAbbreviations = []string{
+	"Dr.", "Mr.", "Mrs.", "Ms.", "Prof.", "St.", "Ave.", "Blvd.",
+	"etc.", "e.g.", "i.e.", "vs.", "No.", "Inc.", "Ltd.", "Co.", "Corp.",
+	"U.S.", "U.K.", "Jan.", "Feb.", "Mar.", "Apr.", "Aug.", "Sept.", "Oct.", "Nov.", "Dec.",
+	"Mon.", "Tue.", "Wed.", "Thu.", "Fri.", "Sat.", "Sun.",
+}
+
+// builtinSegmenters are the segmenters TextProcessorStage uses unless
+// configured otherwise, by language
+var builtinSegmenters = map[string]Segmenter{
+	"en": PunctuationSegmenter{Terminators: ".!?", Abbreviations: englishAbbreviations},
+	"es": PunctuationSegmenter{
+		Terminators:   ".!?",
+		Abbreviations: []string{"Sr.", "Sra.", "Srta.", "Dr.", "Dra.", "Ud.", "Uds.", "etc.", "p.ej.", "aprox.", "núm.", "pág."},
+		Openers:       map[rune]rune{'¿': '?', '¡': '!'},
+	},
+	"pt": PunctuationSegmenter{Terminators: ".!?", Abbreviations: []string{"Sr.", "Sra.", "Dr.", "Dra.", "etc.", "p.ex.", "pág.", "nº."}},
+	"fr": PunctuationSegmenter{Terminators: ".!?", Abbreviations: []string{"M.", "Mme.", "Mlle.", "Dr.", "etc.", "p.ex.", "av.", "boul."}},
+	"de": PunctuationSegmenter{Terminators: ".!?", Abbreviations: []string{"z.B.", "Dr.", "Nr.", "usw.", "bzw.", "d.h.", "ca.", "Str.", "Hr.", "Fr."}},
+	"zh": PunctuationSegmenter{Terminators: "。！？.!?"},
+	"ja": PunctuationSegmenter{Terminators: "。！？.!?"},
+	"ko": PunctuationSegmenter{Terminators: ".!?。！？"},
+}
+
+// segmenterFor returns the segmenter for a language: a configured one, else
+// a built-in one, else the English one
+func segmenterFor(configured map[string]Segmenter, language string) Segmenter {
+	if segmenter, ok := localized(configured, language); ok {
+		return segmenter
+	}
+	if segmenter, ok := localized(builtinSegmenters, language); ok {
+		return segmenter
+	}
+	return builtinSegmenters["en"]
+}
@@ -0,0 +1,78 @@
+package stages
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestSegmenterSentenceEnd tests the built-in segmenters
+func TestSegmenterSentenceEnd(t *testing.T) {
+	tests := []struct {
+		language string
+		text     string
+		want     bool
+	}{
+		{"en", "Hello world. ", true},
+		{"en", "Ask Dr.", false},
+		{"en", "I love tacos.", true},
+		{"en", "I ate a Taco.", true},
+		{"en", "It costs $4.", false},
+		{"en", "It costs $4. ", true},
+		{"en", `He said "yes."`, true},
+		{"en", "Hello world", false},
+		{"en-GB", "Is it?", true},
+		{"es", "Hola. ¿Cómo estás.", false},
+		{"es", "¿Cómo estás?", true},
+		{"es", "¡Qué bien!", true},
+		{"es", "Pregunte al Sr.", false},
+		{"zh", "你好。", true},
+		{"ja", "そうですか？", true},
+		{"ja", "「はい。」", true},
+		{"zh", "你好", false},
+		{"xx", "Unknown language.", true},
+	}
+
+	for _, tt := range tests {
+		segmenter := segmenterFor(nil, tt.language)
+		if got := segmenter.SentenceEnd(tt.text); got != tt.want {
+			t.Errorf("%s %q: got %v, want %v", tt.language, tt.text, got, tt.want)
+		}
+	}
+
+	custom := PunctuationSegmenter{Terminators: "|"}
+	if got := segmenterFor(map[string]Segmenter{"en": custom}, "en-US"); !reflect.DeepEqual(got, custom) {
+		t.Errorf("expected the configured segmenter, got %#v", got)
+	}
+}
+
+// TestTextProcessorSegmentsByLanguage tests that a detected language switches
+// the segmenter mid-turn
+func TestTextProcessorSegmentsByLanguage(t *testing.T) {
+	stage := NewTextProcessorStage(TextProcessorStageConfig{Language: "en"})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LanguageDetectedEvent{Language: "zh"}
+	input <- core.LLMEvent{Delta: "你好。"}
+	input <- core.LLMEvent{Delta: "再见。"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []string
+	for event := range output {
+		if llmEvent, ok := event.(core.LLMEvent); ok {
+			results = append(results, llmEvent.Delta)
+		}
+	}
+	if want := []string{"你好。", "再见。"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("got %q, want %q", results, want)
+	}
+}
@@ -0,0 +1,166 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// SSESinkConfig holds SSE sink configuration
+type SSESinkConfig struct {
+	Writer     http.ResponseWriter
+	SessionID  string
+	ResponseID string // ID for events without a turn, see core.TurnContext
+	SampleRate int    // Sample rate reported in response.audio_start when events carry none (default: 24000)
+	Logger     telemetry.Logger
+}
+
+// SSESink sends pipeline events to an HTTP client as Server-Sent Events.
+// Each protocol OutputMessage becomes one SSE event named after the message
+// type; audio chunks are sent as stream.audio messages with base64 data.
+type SSESink struct {
+	config        SSESinkConfig
+	audioStarted  bool
+	audioResponse string // Response ID of the open audio segment
+}
+
+// NewSSESink creates a new SSE sink stage
+func NewSSESink(config SSESinkConfig) *SSESink {
+	if config.SampleRate == 0 {
+		config.SampleRate = 24000
+	}
+	return &SSESink{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (ss *SSESink) Name() string {
+	return "sse_sink"
+}
+
+// Process implements the Stage interface
+// It reads events from the input channel and writes them to the HTTP response as SSE
+func (ss *SSESink) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := ss.config.Logger.WithModule(ss.Name())
+	logger.Info("Starting SSE sink stage", telemetry.String("session_id", ss.config.SessionID))
+
+	header := ss.config.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("SSE sink context cancelled", telemetry.String("session_id", ss.config.SessionID))
+			return ctx.Err()
+
+		case event, ok := <-input:
+			if !ok {
+				logger.Info("SSE sink input channel closed", telemetry.String("session_id", ss.config.SessionID))
+				return nil
+			}
+
+			if err := ss.handleEvent(event); err != nil {
+				logger.Error("Failed to write SSE event", telemetry.Err(err), telemetry.String("session_id", ss.config.SessionID))
+				// Client disconnected - gracefully drain input without failing pipeline
+				for range input {
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// handleEvent writes the SSE messages for a single pipeline event
+func (ss *SSESink) handleEvent(event core.Event) error {
+	responseID := responseIDOf(event, ss.config.ResponseID)
+
+	switch e := event.(type) {
+	case core.AudioEvent:
+		if !ss.audioStarted {
+			sampleRate := e.SampleRate
+			if sampleRate == 0 {
+				sampleRate = ss.config.SampleRate
+			}
+			startMsg := protocol.NewResponseAudioStartMessage(
+				ss.config.SessionID,
+				responseID,
+				responseID,
+				e.Format,
+				sampleRate,
+			)
+			if err := ss.writeMessage(startMsg); err != nil {
+				return err
+			}
+			ss.audioStarted = true
+			ss.audioResponse = responseID
+		}
+
+	case core.InterruptEvent:
+		return ss.endAudio()
+
+	case core.DoneEvent:
+		if err := ss.endAudio(); err != nil {
+			return err
+		}
+	}
+
+	msg := protocol.EventToMessage(event, ss.config.SessionID, responseID)
+	if msg == nil {
+		return nil
+	}
+	return ss.writeMessage(msg)
+}
+
+// endAudio sends response.audio_end if an audio stream is open
+func (ss *SSESink) endAudio() error {
+	if !ss.audioStarted {
+		return nil
+	}
+	ss.audioStarted = false
+	endMsg := protocol.NewResponseAudioEndMessage(
+		ss.config.SessionID,
+		ss.audioResponse,
+		ss.audioResponse,
+		0, // Duration not tracked here yet
+	)
+	return ss.writeMessage(endMsg)
+}
+
+// writeMessage writes a single SSE event and flushes it to the client
+func (ss *SSESink) writeMessage(msg *protocol.OutputMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		// Skip messages that can't be serialized - don't fail the pipeline
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(ss.config.Writer, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, data); err != nil {
+		return err
+	}
+
+	if flusher, ok := ss.config.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// InputTypes returns the input event types this stage accepts
+func (ss *SSESink) InputTypes() []core.EventType {
+	// SSE sink accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (ss *SSESink) OutputTypes() []core.EventType {
+	// SSE sink is a terminal stage, it only produces error events
+	return []core.EventType{core.EventTypeError}
+}
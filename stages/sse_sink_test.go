@@ -0,0 +1,124 @@
+package stages
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+func TestSSESink_WritesEvents(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	sink := NewSSESink(SSESinkConfig{
+		Writer:     recorder,
+		SessionID:  "test-session",
+		ResponseID: "resp-1",
+		Logger:     telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "Hi", Content: "Hi"}
+	input <- core.AudioEvent{Data: []byte{0x01, 0x02}, Format: "pcm"}
+	input <- core.DoneEvent{FullText: "Hi"}
+	close(input)
+
+	if err := sink.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("expected text/event-stream content type, got %q", got)
+	}
+
+	// Parse SSE frames
+	var types []string
+	var audio protocol.AudioStreamPayload
+	scanner := bufio.NewScanner(strings.NewReader(recorder.Body.String()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			types = append(types, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var msg struct {
+				Type    string          `json:"type"`
+				Payload json.RawMessage `json:"payload"`
+			}
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("invalid JSON data: %v", err)
+			}
+			if msg.Type == string(protocol.OutputStreamAudio) {
+				var raw struct {
+					Data string `json:"data"`
+				}
+				json.Unmarshal(msg.Payload, &raw)
+				decoded, err := base64.StdEncoding.DecodeString(raw.Data)
+				if err != nil {
+					t.Fatalf("audio data is not base64: %v", err)
+				}
+				audio.Data = decoded
+			}
+		}
+	}
+
+	expected := []string{"stream.llm", "response.audio_start", "stream.audio", "response.audio_end", "response.end"}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, types)
+	}
+	if len(audio.Data) != 2 || audio.Data[1] != 0x02 {
+		t.Errorf("unexpected audio payload %v", audio.Data)
+	}
+}
+
+// TestSSESink_TurnResponseIDs tests that events carry their own turn's
+// response ID and only events outside a turn fall back to config
+func TestSSESink_TurnResponseIDs(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sink := NewSSESink(SSESinkConfig{
+		Writer:     recorder,
+		SessionID:  "test-session",
+		ResponseID: "fallback",
+		Logger:     telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	turn := func(id string) core.EventMeta {
+		return core.EventMeta{Turn: core.TurnContext{TurnID: id, ResponseID: "resp-" + id}}
+	}
+
+	input := make(chan core.Event, 4)
+	input <- core.DoneEvent{FullText: "one", Meta: turn("1")}
+	input <- core.DoneEvent{FullText: "two", Meta: turn("2")}
+	input <- core.DoneEvent{FullText: "three"}
+	close(input)
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 4)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(strings.NewReader(recorder.Body.String()))
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var msg struct {
+				Payload protocol.ResponseEndPayload `json:"payload"`
+			}
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("invalid JSON data: %v", err)
+			}
+			ids = append(ids, msg.Payload.ResponseID)
+		}
+	}
+
+	want := []string{"resp-1", "resp-2", "fallback"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("expected response IDs %v, got %v", want, ids)
+	}
+}
@@ -0,0 +1,98 @@
+package stages
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SSMLConfig controls how text is rendered as SSML for TTS providers that
+// accept it
+type SSMLConfig struct {
+	CommaBreak    time.Duration // Pause after commas, semicolons and colons, 0 disables
+	SentenceBreak time.Duration // Pause after sentence-ending punctuation, 0 disables
+	Rate          string        // Prosody rate, e.g. "medium" or "110%"
+	Pitch         string        // Prosody pitch, e.g. "+2st"
+	SayAsNumbers  bool          // Read whole numbers as cardinals
+}
+
+var (
+	// ssmlTokenRegex matches the parts of text that get SSML markup: clock
+	// times, whole numbers (with optional thousands separators), decimals and
+	// punctuation
+	ssmlTokenRegex = regexp.MustCompile(`\d{1,2}:\d{2}\b|\d{1,3}(?:,\d{3})+|\d+(?:\.\d+)?|[,;:]|[.!?]+`)
+	ssmlTagRegex   = regexp.MustCompile(`<[^>]+>`)
+)
+
+// isSSML reports whether text is an SSML document
+func isSSML(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "<speak")
+}
+
+// toSSML renders plain text as an SSML document
+func toSSML(text string, config SSMLConfig) string {
+	var b strings.Builder
+	b.WriteString("<speak>")
+
+	prosody := config.Rate != "" || config.Pitch != ""
+	if prosody {
+		b.WriteString("<prosody")
+		if config.Rate != "" {
+			fmt.Fprintf(&b, ` rate="%s"`, html.EscapeString(config.Rate))
+		}
+		if config.Pitch != "" {
+			fmt.Fprintf(&b, ` pitch="%s"`, html.EscapeString(config.Pitch))
+		}
+		b.WriteString(">")
+	}
+
+	last := 0
+	for _, loc := range ssmlTokenRegex.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		token := text[loc[0]:loc[1]]
+		last = loc[1]
+
+		first, _ := utf8.DecodeRuneInString(token)
+		if unicode.IsDigit(first) {
+			// Decimals and times are left to the provider's own normalization
+			if config.SayAsNumbers && !strings.ContainsAny(token, ".:") {
+				fmt.Fprintf(&b, `<say-as interpret-as="cardinal">%s</say-as>`, strings.ReplaceAll(token, ",", ""))
+			} else {
+				b.WriteString(token)
+			}
+			continue
+		}
+
+		b.WriteString(token)
+
+		// Only pause at punctuation that ends a clause, not inside "3:00" or "a.m."
+		next, _ := utf8.DecodeRuneInString(text[last:])
+		if last < len(text) && !unicode.IsSpace(next) {
+			continue
+		}
+		pause := config.CommaBreak
+		if first == '.' || first == '!' || first == '?' {
+			pause = config.SentenceBreak
+		}
+		if pause > 0 {
+			fmt.Fprintf(&b, `<break time="%dms"/>`, pause.Milliseconds())
+		}
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	if prosody {
+		b.WriteString("</prosody>")
+	}
+	b.WriteString("</speak>")
+	return b.String()
+}
+
+// ssmlToText strips SSML markup, recovering the text to speak for providers
+// that only accept plain text
+func ssmlToText(text string) string {
+	return strings.TrimSpace(html.UnescapeString(ssmlTagRegex.ReplaceAllString(text, "")))
+}
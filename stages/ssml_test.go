@@ -0,0 +1,43 @@
+package stages
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToSSML(t *testing.T) {
+	config := SSMLConfig{
+		CommaBreak:    200 * time.Millisecond,
+		SentenceBreak: 400 * time.Millisecond,
+		Rate:          "110%",
+		SayAsNumbers:  true,
+	}
+
+	got := toSSML("We have 1,200 rooms & 3.5 stars, open at 9:30. Welcome!", config)
+	want := `<speak><prosody rate="110%">We have <say-as interpret-as="cardinal">1200</say-as> rooms &amp; 3.5 stars,<break time="200ms"/>` +
+		` open at 9:30.<break time="400ms"/>` +
+		` Welcome!<break time="400ms"/></prosody></speak>`
+	if got != want {
+		t.Errorf("unexpected SSML:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSSMLPlainTextFallback(t *testing.T) {
+	ssml := toSSML("Tom & Jerry, 2 cats.", SSMLConfig{CommaBreak: 100 * time.Millisecond, SayAsNumbers: true})
+	if !isSSML(ssml) {
+		t.Fatalf("expected SSML document, got %q", ssml)
+	}
+
+	stage := NewTTSStage(TTSStageConfig{})
+	if got := stage.prepareText(ssml); got != "Tom & Jerry, 2 cats." {
+		t.Errorf("expected plain text fallback, got %q", got)
+	}
+
+	stage = NewTTSStage(TTSStageConfig{SSML: &SSMLConfig{}})
+	if got := stage.prepareText(ssml); got != ssml {
+		t.Errorf("expected SSML to pass through unchanged, got %q", got)
+	}
+	if got := stage.prepareText("Hi"); got != "<speak>Hi</speak>" {
+		t.Errorf("expected plain text to be rendered as SSML, got %q", got)
+	}
+}
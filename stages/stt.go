@@ -3,25 +3,63 @@ package stages
 import (
 	"context"
 	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
 	providers "github.com/creastat/providers/core"
 )
 
 // STTStageConfig holds STT stage configuration
 type STTStageConfig struct {
 	Provider       providers.STTProvider
+	Fallbacks      []providers.STTProvider          // Tried in order when the provider fails to open a stream
+	Presets        map[string]providers.STTProvider // Providers selectable by preset name with ConfigUpdateEvent
 	Language       string
 	Encoding       string
 	SampleRate     int
 	InterimResults bool
+	WordTimestamps bool             // Ask the provider for word timings, see STTWordStream
+	Diarize        bool             // Ask the provider for speaker labels, see STTWordStream
+	DetectLanguage bool             // Identify the spoken language and emit LanguageDetectedEvent
+	Keywords       []STTKeyword     // Vocabulary to favor, along with the session's from ConfigUpdateEvent.Keywords
+	MinConfidence  float64          // Final transcripts below it aren't queried, see Process; 0 disables
+	Detector       LanguageDetector // Detects the language of final transcripts when the stream doesn't report it
+	Retry          *RetryPolicy     // Retries for starting the provider stream, nil disables
+	Limiter        *RateLimiter     // Rate limits shared across pipelines, nil disables
 	Logger         telemetry.Logger
 }
 
+// STTKeyword is a word or phrase the STT provider should favor, such as a
+// product or user name. Providers receive the phrases as the "keywords"
+// request option and the boosts, if any, as "keyword_boosts".
+type STTKeyword struct {
+	Phrase string
+	Boost  float64 // Provider-specific weight, 0 for the provider's default
+}
+
+// STTWordStream is implemented by STT streams that report word timings and
+// speaker labels. Both describe the chunk last returned by Receive.
+type STTWordStream interface {
+	Words() []core.WordInfo
+	SpeakerID() string
+}
+
+// STTEndpointStream is implemented by STT streams whose provider detects the
+// end of an utterance. It describes the chunk last returned by Receive.
+type STTEndpointStream interface {
+	EndOfUtterance() bool
+}
+
 // STTStage represents a speech-to-text processing stage
 type STTStage struct {
-	config STTStageConfig
+	config          STTStageConfig
+	updates         pendingConfig
+	sessionKeywords []string // Set by ConfigUpdateEvent.Keywords
 }
 
 // NewSTTStage creates a new STT stage
@@ -43,12 +81,52 @@ func (s *STTStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the event types this stage produces
 func (s *STTStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeSTT, core.EventTypeLLM, core.EventTypeStatus}
+	return []core.EventType{core.EventTypeSTT, core.EventTypeLLM, core.EventTypeStatus, core.EventTypeLanguage, core.EventTypeUsage, core.EventTypeLatency}
+}
+
+// keywords returns the configured and session keyword phrases without
+// duplicates, and the boosts of those that have one
+func (s *STTStage) keywords() ([]string, map[string]float64) {
+	var phrases []string
+	var boosts map[string]float64
+	seen := make(map[string]bool)
+	add := func(phrase string, boost float64) {
+		phrase = strings.TrimSpace(phrase)
+		if phrase == "" || seen[strings.ToLower(phrase)] {
+			return
+		}
+		seen[strings.ToLower(phrase)] = true
+		phrases = append(phrases, phrase)
+		if boost > 0 {
+			if boosts == nil {
+				boosts = make(map[string]float64)
+			}
+			boosts[phrase] = boost
+		}
+	}
+
+	for _, keyword := range s.config.Keywords {
+		add(keyword.Phrase, keyword.Boost)
+	}
+	for _, phrase := range s.sessionKeywords {
+		add(phrase, 0)
+	}
+	return phrases, boosts
 }
 
 // Process implements the Stage interface
 // It reads audio chunks from the input channel and streams transcription to the output channel
+//
+// Final transcripts with a confidence below MinConfidence are still emitted
+// as STTEvents but aren't sent on as the query. When a turn has no other
+// final transcript, the stage asks the user to repeat with an
+// i18n.MsgLowConfidence service message instead of querying RAG and the LLM
+// with a likely garbled transcript; route the message on its key to send it
+// to a clarification branch rather than the client. Transcripts without a
+// confidence (zero) are never gated.
 func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	s.applyPendingConfig()
+
 	logger := s.config.Logger.WithModule(s.Name())
 	logger.Info("Starting STT stage", telemetry.String("provider", s.config.Provider.Name()), telemetry.String("language", s.config.Language))
 	logger.Info("Emitting transcribing status")
@@ -69,36 +147,56 @@ func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output
 			"interim_results": s.config.InterimResults,
 		},
 	}
+	if s.config.WordTimestamps {
+		req.Options["word_timestamps"] = true
+	}
+	if s.config.Diarize {
+		req.Options["diarize"] = true
+	}
+	if s.config.DetectLanguage {
+		// Language is kept as a hint for providers that accept one alongside detection
+		req.Options["detect_language"] = true
+	}
+	if phrases, boosts := s.keywords(); len(phrases) > 0 {
+		req.Options["keywords"] = phrases
+		if len(boosts) > 0 {
+			req.Options["keyword_boosts"] = boosts
+		}
+	}
 
 	logger.Info("Starting STT stream", telemetry.String("encoding", s.config.Encoding), telemetry.Int("sample_rate", s.config.SampleRate))
 
-	// Start streaming transcription
-	stream, err := s.config.Provider.StreamTranscribe(ctx, req)
+	// Start streaming transcription, falling back along the provider chain
+	chain := providerChain(s.config.Provider, s.config.Fallbacks)
+	startStream, releaseStream := limitStart(s.config.Limiter, req.Model, func(ctx context.Context, provider providers.STTProvider) (providers.STTStream, error) {
+		return provider.StreamTranscribe(ctx, req)
+	})
+	stream, served, err := withFallback(ctx, chain, s.config.Retry, logger, startStream)
 	if err != nil {
 		logger.Error("Failed to start STT stream", telemetry.Err(err))
 		// Send user-friendly message instead of error
-		output <- core.ServiceMessageEvent{
-			MessageType: core.ServiceMessageRetryRequest,
-			Content:     "Error transcribing audio. Please try again.",
-			Localized: map[string]string{
-				"en": "Error transcribing audio. Please try again.",
-				"es": "Error al transcribir audio. Por favor, intenta de nuevo.",
-				"fr": "Erreur lors de la transcription audio. Veuillez réessayer.",
-			},
-		}
+		output <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgTranscriptionFailed, nil)
 		// Emit DoneEvent to properly close the pipeline
 		logger.Info("Emitting done event after STT stream start error")
 		output <- core.DoneEvent{}
 		return nil
 	}
-	defer stream.Close()
+	defer func() {
+		stream.Close()
+		releaseStream(stream)
+	}()
+	provider := chain[served].Name()
 
 	// Process input audio chunks and send to stream
+	var audioBytes atomic.Int64
+	var firstAudio atomic.Int64 // When the first chunk was sent, in Unix nanoseconds
 	go func() {
 		audioChunkCount := 0
 		for event := range input {
 			if audioEvent, ok := event.(core.AudioEvent); ok {
 				audioChunkCount++
+				audioBytes.Add(int64(len(audioEvent.Data)))
+				firstAudio.CompareAndSwap(0, time.Now().UnixNano())
 				logger.Debug("Sending audio chunk to STT provider", telemetry.Int("size", len(audioEvent.Data)), telemetry.Int("chunk_number", audioChunkCount))
 				err := stream.Send(ctx, audioEvent.Data)
 				if err != nil {
@@ -122,6 +220,9 @@ func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output
 	// Process stream and emit events
 	var fullTranscription string
 	chunkCount := 0
+	var detectedLanguage string // Last announced language
+	var gated core.STTEvent     // Last final transcript below MinConfidence
+	partialMarked := false      // Whether the first transcript latency was reported
 
 	for {
 		chunk, err := stream.Receive(ctx)
@@ -132,15 +233,7 @@ func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output
 			}
 			logger.Error("Error receiving STT chunk", telemetry.Err(err), telemetry.Int("chunks_received", chunkCount))
 			// Send user-friendly message instead of error
-			output <- core.ServiceMessageEvent{
-				MessageType: core.ServiceMessageRetryRequest,
-				Content:     "Error transcribing audio. Please try again.",
-				Localized: map[string]string{
-					"en": "Error transcribing audio. Please try again.",
-					"es": "Error al transcribir audio. Por favor, intenta de nuevo.",
-					"fr": "Erreur lors de la transcription audio. Veuillez réessayer.",
-				},
-			}
+			output <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgTranscriptionFailed, nil)
 			// Emit DoneEvent to properly close the pipeline
 			logger.Info("Emitting done event after STT error")
 			output <- core.DoneEvent{}
@@ -166,11 +259,48 @@ func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output
 
 		// Emit STT event for each chunk (interim and final)
 		logger.Debug("Emitting STT event", telemetry.String("text", chunk.Text), telemetry.Bool("is_final", chunk.IsFinal))
-		output <- core.STTEvent{
+		// Announce the language before the text so downstream stages can switch first
+		if s.config.DetectLanguage {
+			if language, confidence := s.detectLanguage(ctx, stream, chunk); language != "" && language != detectedLanguage {
+				detectedLanguage = language
+				logger.Info("Detected language", telemetry.String("language", language), telemetry.Float64("confidence", confidence))
+				output <- core.LanguageDetectedEvent{
+					Language:   language,
+					Confidence: confidence,
+				}
+			}
+		}
+
+		if started := firstAudio.Load(); !partialMarked && started != 0 {
+			partialMarked = true
+			output <- core.LatencyMarkEvent{
+				Mark:    core.LatencySTTFirstPartial,
+				Elapsed: time.Since(time.Unix(0, started)),
+			}
+		}
+
+		sttEvent := core.STTEvent{
 			Text:       chunk.Text,
 			IsFinal:    chunk.IsFinal,
 			Confidence: chunk.Confidence,
 		}
+		if wordStream, ok := stream.(STTWordStream); ok {
+			sttEvent.Words = wordStream.Words()
+			sttEvent.SpeakerID = wordStream.SpeakerID()
+		}
+		if endpointStream, ok := stream.(STTEndpointStream); ok {
+			sttEvent.EndOfUtterance = endpointStream.EndOfUtterance()
+		}
+		output <- sttEvent
+
+		if chunk.IsFinal && chunk.Confidence > 0 && chunk.Confidence < s.config.MinConfidence {
+			logger.Warn("Not querying low-confidence transcript",
+				telemetry.String("text", chunk.Text),
+				telemetry.Float64("confidence", chunk.Confidence),
+				telemetry.Float64("min_confidence", s.config.MinConfidence))
+			gated = sttEvent
+			continue
+		}
 
 		// If final, append to full transcription and emit LLM event immediately
 		if chunk.IsFinal {
@@ -188,29 +318,77 @@ func (s *STTStage) Process(ctx context.Context, input <-chan core.Event, output
 		}
 	}
 
+	output <- core.UsageEvent{
+		Provider:     provider,
+		AudioSeconds: audioSeconds(audioBytes.Load(), s.config.Encoding, s.config.SampleRate),
+	}
+
+	if fullTranscription == "" && gated.Text != "" {
+		output <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgLowConfidence, map[string]string{
+			"text":       gated.Text,
+			"confidence": strconv.FormatFloat(gated.Confidence, 'f', 2, 64),
+		})
+		logger.Info("Emitting done event after low-confidence transcription")
+		output <- core.DoneEvent{Provider: provider}
+		return nil
+	}
+
 	// Check if we got any transcription
 	if fullTranscription == "" {
 		logger.Warn("No transcription received from STT provider")
 		// Emit service message asking user to repeat
-		output <- core.ServiceMessageEvent{
-			MessageType: core.ServiceMessageRetryRequest,
-			Content:     "Could not understand your input. Please try again.",
-			Localized: map[string]string{
-				"en": "Could not understand your input. Please try again.",
-				"es": "No pude entender tu entrada. Por favor, intenta de nuevo.",
-				"fr": "Je n'ai pas pu comprendre votre entrée. Veuillez réessayer.",
-			},
-		}
+		output <- i18n.NewMessage(core.ServiceMessageRetryRequest, i18n.MsgNotUnderstood, nil)
 		// Emit DoneEvent to close the pipeline without any query text
 		// Downstream stages will handle the empty query gracefully
 		logger.Info("Emitting done event with no transcription")
-		output <- core.DoneEvent{}
+		output <- core.DoneEvent{Provider: provider}
 		return nil
 	}
 
 	// Emit DoneEvent to properly terminate the pipeline branch
 	logger.Info("Emitting done event", telemetry.String("full_transcription", fullTranscription))
-	output <- core.DoneEvent{}
+	output <- core.DoneEvent{Provider: provider}
 
 	return nil
 }
+
+// audioSeconds returns the duration of raw audio, or zero for compressed or
+// unknown encodings whose duration can't be derived from the size
+func audioSeconds(size int64, encoding string, sampleRate int) float64 {
+	if sampleRate <= 0 {
+		return 0
+	}
+
+	var bytesPerSample int
+	switch encoding {
+	case AudioEncodingPCM, "pcm16", "linear16", "pcm_s16le":
+		bytesPerSample = 2
+	case AudioEncodingMulaw, "ulaw", "pcm_mulaw":
+		bytesPerSample = 1
+	default:
+		return 0
+	}
+
+	return float64(size) / float64(bytesPerSample*sampleRate)
+}
+
+// detectLanguage returns the language of a chunk as reported by the stream,
+// or by the configured detector for final transcripts. It returns an empty
+// language when neither can tell.
+func (s *STTStage) detectLanguage(ctx context.Context, stream providers.STTStream, chunk *providers.STTChunk) (string, float64) {
+	if languageStream, ok := stream.(STTLanguageStream); ok {
+		if language, confidence := languageStream.DetectedLanguage(); language != "" {
+			return language, confidence
+		}
+	}
+	if s.config.Detector == nil || !chunk.IsFinal {
+		return "", 0
+	}
+
+	language, confidence, err := s.config.Detector.Detect(ctx, chunk.Text)
+	if err != nil {
+		s.config.Logger.WithModule(s.Name()).Warn("Language detection failed", telemetry.Err(err))
+		return "", 0
+	}
+	return language, confidence
+}
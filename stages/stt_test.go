@@ -3,11 +3,14 @@ package stages
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
+	"github.com/creastat/pipeline/pipelinetest"
 	providers "github.com/creastat/providers/core"
 	"pgregory.net/rapid"
 )
@@ -181,3 +184,200 @@ func (s *TestSTTStream) Receive(ctx context.Context) (*providers.STTChunk, error
 func (s *TestSTTStream) Close() error {
 	return nil
 }
+
+func TestSTTStageAttachesWordTimings(t *testing.T) {
+	provider := &TestWordSTTProvider{}
+	stage := NewSTTStage(STTStageConfig{
+		Provider:       provider,
+		Encoding:       "pcm",
+		SampleRate:     16000,
+		WordTimestamps: true,
+		Diarize:        true,
+	})
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 100)
+	input <- core.AudioEvent{Data: make([]byte, 320), Format: "pcm"}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	if provider.options["word_timestamps"] != true || provider.options["diarize"] != true {
+		t.Errorf("expected word timestamps and diarization to be requested, got %v", provider.options)
+	}
+
+	var sttEvents int
+	for event := range output {
+		e, ok := event.(core.STTEvent)
+		if !ok {
+			continue
+		}
+		sttEvents++
+		if e.SpeakerID != "speaker_0" || len(e.Words) != 1 || e.Words[0].Word != "Hello" || e.Words[0].End != 0.4 {
+			t.Errorf("unexpected word timings on STT event: %+v", e)
+		}
+	}
+	if sttEvents == 0 {
+		t.Error("expected STT events")
+	}
+}
+
+// TestWordSTTProvider opens streams that report word timings
+type TestWordSTTProvider struct {
+	TestStreamingSTTProvider
+	options map[string]any
+}
+
+func (m *TestWordSTTProvider) StreamTranscribe(ctx context.Context, req providers.STTRequest) (providers.STTStream, error) {
+	m.options = req.Options
+	return &TestWordSTTStream{}, nil
+}
+
+// TestWordSTTStream is a TestSTTStream implementing STTWordStream
+type TestWordSTTStream struct {
+	TestSTTStream
+}
+
+func (s *TestWordSTTStream) Words() []core.WordInfo {
+	return []core.WordInfo{{Word: "Hello", Start: 0.1, End: 0.4, Confidence: 0.9, SpeakerID: "speaker_0"}}
+}
+
+func (s *TestWordSTTStream) SpeakerID() string {
+	return "speaker_0"
+}
+
+func TestSTTStageDetectsLanguage(t *testing.T) {
+	var detected []string
+	stage := NewSTTStage(STTStageConfig{
+		Provider:       &TestStreamingSTTProvider{},
+		Encoding:       "pcm",
+		SampleRate:     16000,
+		DetectLanguage: true,
+		Detector: LanguageDetectorFunc(func(ctx context.Context, text string) (string, float64, error) {
+			detected = append(detected, text)
+			return "pt-BR", 0.9, nil
+		}),
+	})
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 100)
+	input <- core.AudioEvent{Data: make([]byte, 320), Format: "pcm"}
+	close(input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := stage.Process(ctx, input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	languageIndex, finalIndex := -1, -1
+	var i int
+	for event := range output {
+		switch e := event.(type) {
+		case core.LanguageDetectedEvent:
+			if languageIndex != -1 {
+				t.Error("expected the language to be announced once")
+			}
+			languageIndex = i
+			if e.Language != "pt-BR" || e.Confidence != 0.9 {
+				t.Errorf("unexpected language event: %+v", e)
+			}
+		case core.STTEvent:
+			if e.IsFinal {
+				finalIndex = i
+			}
+		}
+		i++
+	}
+
+	if len(detected) != 1 || detected[0] != "Hello world" {
+		t.Errorf("expected detection on the final transcript only, got %q", detected)
+	}
+	if languageIndex == -1 || languageIndex > finalIndex {
+		t.Errorf("expected the language event before the final transcript, got %d and %d", languageIndex, finalIndex)
+	}
+
+	if prompt, ok := localized(map[string]string{"pt": "Responda em português."}, "pt-BR"); !ok || prompt != "Responda em português." {
+		t.Errorf("expected the primary subtag to match, got %q, %v", prompt, ok)
+	}
+}
+
+// TestSTTStageKeywords tests that configured and session keywords are passed
+// to the provider, and that an empty session list clears the session's
+func TestSTTStageKeywords(t *testing.T) {
+	provider := &TestWordSTTProvider{}
+	stage := NewSTTStage(STTStageConfig{
+		Provider:   provider,
+		Encoding:   "pcm",
+		SampleRate: 16000,
+		Keywords:   []STTKeyword{{Phrase: "Creastat", Boost: 2}, {Phrase: "RAG"}},
+		Logger:     testLogger(),
+	})
+
+	audio := core.AudioEvent{Data: make([]byte, 320), Format: "pcm"}
+	runTurn(t, stage, audio)
+	if want := []string{"Creastat", "RAG"}; !reflect.DeepEqual(provider.options["keywords"], want) {
+		t.Errorf("expected keywords %v, got %v", want, provider.options["keywords"])
+	}
+	if want := map[string]float64{"Creastat": 2}; !reflect.DeepEqual(provider.options["keyword_boosts"], want) {
+		t.Errorf("expected boosts %v, got %v", want, provider.options["keyword_boosts"])
+	}
+
+	stage.ApplyConfig(core.ConfigUpdateEvent{Keywords: []string{"Ana Lucía", "creastat"}})
+	runTurn(t, stage, audio)
+	if want := []string{"Creastat", "RAG", "Ana Lucía"}; !reflect.DeepEqual(provider.options["keywords"], want) {
+		t.Errorf("expected session keywords added, got %v", provider.options["keywords"])
+	}
+
+	stage.ApplyConfig(core.ConfigUpdateEvent{Keywords: []string{}})
+	runTurn(t, stage, audio)
+	if want := []string{"Creastat", "RAG"}; !reflect.DeepEqual(provider.options["keywords"], want) {
+		t.Errorf("expected session keywords cleared, got %v", provider.options["keywords"])
+	}
+}
+
+// TestSTTStageMinConfidence tests that low-confidence final transcripts
+// aren't queried, and that a turn with only those asks the user to repeat
+func TestSTTStageMinConfidence(t *testing.T) {
+	provider := pipelinetest.NewFakeSTT()
+	stage := NewSTTStage(STTStageConfig{Provider: provider, MinConfidence: 0.6, Logger: testLogger()})
+	audio := core.AudioEvent{Data: make([]byte, 320), Format: "pcm"}
+
+	queries := func(events []core.Event) (texts []string, keys []string) {
+		for _, event := range events {
+			switch e := event.(type) {
+			case core.LLMEvent:
+				texts = append(texts, e.Delta)
+			case core.ServiceMessageEvent:
+				keys = append(keys, e.Key)
+			}
+		}
+		return texts, keys
+	}
+
+	provider.Chunks = []providers.STTChunk{
+		{Text: "book a flight", IsFinal: true, Confidence: 0.9},
+		{Text: "to blarg", IsFinal: true, Confidence: 0.3},
+		{Text: "tomorrow", IsFinal: true}, // No confidence reported
+	}
+	texts, keys := queries(runTurn(t, stage, audio))
+	if want := []string{"book a flight", "tomorrow"}; !reflect.DeepEqual(texts, want) || keys != nil {
+		t.Errorf("expected queries %v without messages, got %v and %v", want, texts, keys)
+	}
+
+	provider.Chunks = []providers.STTChunk{{Text: "mumble", IsFinal: true, Confidence: 0.2}}
+	events := runTurn(t, stage, audio)
+	texts, keys = queries(events)
+	if texts != nil || !reflect.DeepEqual(keys, []string{i18n.MsgLowConfidence}) {
+		t.Errorf("expected only a low confidence message, got %v and %v", texts, keys)
+	}
+	if _, ok := events[len(events)-1].(core.DoneEvent); !ok {
+		t.Errorf("expected the turn to end with a DoneEvent, got %T", events[len(events)-1])
+	}
+}
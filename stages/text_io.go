@@ -0,0 +1,186 @@
+package stages
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// TextReaderSourceConfig holds text reader source configuration
+type TextReaderSourceConfig struct {
+	Reader io.Reader // e.g. os.Stdin
+	Logger telemetry.Logger
+}
+
+// TextReaderSource reads user turns from an io.Reader, one per line, so a
+// pipeline can be driven from a terminal or a CI script without a network
+// transport. Each non-blank line becomes the events of an input.text
+// message; the stream ends at EOF.
+type TextReaderSource struct {
+	config TextReaderSourceConfig
+}
+
+// NewTextReaderSource creates a new text reader source stage
+func NewTextReaderSource(config TextReaderSourceConfig) *TextReaderSource {
+	return &TextReaderSource{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *TextReaderSource) Name() string {
+	return "text_reader_source"
+}
+
+// InputTypes returns the input event types this stage accepts
+func (s *TextReaderSource) InputTypes() []core.EventType {
+	// Text reader source is an entry stage, it reads from the reader only
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (s *TextReaderSource) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeLLM, core.EventTypeDone}
+}
+
+// Process implements the Stage interface
+func (s *TextReaderSource) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	// Reads block, so scan in the background and stop waiting on cancellation
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(s.config.Reader)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-scanErr:
+					if err != nil {
+						return fmt.Errorf("failed to read input: %w", err)
+					}
+				default:
+				}
+				logger.Info("Text input ended")
+				return nil
+			}
+
+			text := strings.TrimSpace(line)
+			if text == "" {
+				continue
+			}
+			msg := &protocol.InputMessage{Type: protocol.InputText, Payload: protocol.TextInputPayload{Text: text}}
+			for _, event := range protocol.MessageToEvents(msg) {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case output <- event:
+				}
+			}
+		}
+	}
+}
+
+// TextWriterSinkConfig holds text writer sink configuration
+type TextWriterSinkConfig struct {
+	Writer io.Writer // e.g. os.Stdout
+
+	// Prompt is written after each response, e.g. "> " for an interactive
+	// terminal. Empty writes none.
+	Prompt string
+
+	// Transcripts writes final STT results, prefixed with "you: ", for
+	// pipelines that take audio
+	Transcripts bool
+}
+
+// TextWriterSink writes responses to an io.Writer as plain text: LLM deltas
+// as they stream, a newline when a response is done, and errors and
+// service messages on lines of their own. Other events are ignored.
+type TextWriterSink struct {
+	config TextWriterSinkConfig
+}
+
+// NewTextWriterSink creates a new text writer sink stage
+func NewTextWriterSink(config TextWriterSinkConfig) *TextWriterSink {
+	return &TextWriterSink{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *TextWriterSink) Name() string {
+	return "text_writer_sink"
+}
+
+// InputTypes returns the input event types this stage accepts
+func (s *TextWriterSink) InputTypes() []core.EventType {
+	// Text writer sink accepts all event types, writing only text
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (s *TextWriterSink) OutputTypes() []core.EventType {
+	// Text writer sink is a terminal stage
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *TextWriterSink) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	if _, err := io.WriteString(s.config.Writer, s.config.Prompt); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+
+			var err error
+			switch e := event.(type) {
+			case core.LLMEvent:
+				_, err = io.WriteString(s.config.Writer, e.Delta)
+			case core.DoneEvent:
+				_, err = fmt.Fprintf(s.config.Writer, "\n%s", s.config.Prompt)
+			case core.STTEvent:
+				if s.config.Transcripts && e.IsFinal {
+					_, err = fmt.Fprintf(s.config.Writer, "you: %s\n", e.Text)
+				}
+			case core.ErrorEvent:
+				_, err = fmt.Fprintf(s.config.Writer, "error: %v\n", e.Error)
+			case core.ServiceMessageEvent:
+				_, err = fmt.Fprintf(s.config.Writer, "%s: %s\n", e.MessageType, e.Content)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestTextReaderSource(t *testing.T) {
+	source := NewTextReaderSource(TextReaderSourceConfig{
+		Reader: strings.NewReader("hello\n\n  how are you?  \n"),
+		Logger: testLogger(),
+	})
+
+	output := make(chan core.Event, 8)
+	if err := source.Process(context.Background(), nil, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var got []string
+	for event := range output {
+		switch e := event.(type) {
+		case core.LLMEvent:
+			got = append(got, e.Content)
+		case core.DoneEvent:
+			got = append(got, "<done>")
+		}
+	}
+	if strings.Join(got, "|") != "hello|<done>|how are you?|<done>" {
+		t.Errorf("unexpected events %v", got)
+	}
+}
+
+func TestTextWriterSink(t *testing.T) {
+	var out strings.Builder
+	sink := NewTextWriterSink(TextWriterSinkConfig{Writer: &out, Prompt: "> ", Transcripts: true})
+
+	input := make(chan core.Event, 8)
+	input <- core.STTEvent{Text: "hi", IsFinal: false}
+	input <- core.STTEvent{Text: "hi there", IsFinal: true}
+	input <- core.LLMEvent{Delta: "Hel"}
+	input <- core.LLMEvent{Delta: "lo"}
+	input <- core.AudioEvent{Data: []byte{1}}
+	input <- core.DoneEvent{FullText: "Hello"}
+	input <- core.ErrorEvent{Error: errors.New("boom")}
+	close(input)
+
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if want := "> you: hi there\nHello\n> error: boom\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
@@ -14,30 +16,114 @@ import (
 type TextProcessorStageConfig struct {
 	// StripCodeBlocks removes code blocks from text
 	StripCodeBlocks bool
-	// StripMarkdown removes markdown formatting
+	// StripMarkdown removes markdown formatting and reads lists and tables
+	// as prose
 	StripMarkdown bool
-	// ExpandAbbreviations expands common abbreviations
+	// Tables selects how StripMarkdown speaks tables (default: TableRead)
+	Tables TableMode
+	// TablePlaceholder is said instead of a table with TableSkip (default:
+	// DefaultTablePlaceholder)
+	TablePlaceholder string
+	// ExpandAbbreviations expands common English abbreviations
 	ExpandAbbreviations bool
+	// Lexicon adds abbreviation expansions and pronunciation respellings,
+	// e.g. loaded with LoadLexiconFile. Its abbreviations are expanded even
+	// without ExpandAbbreviations.
+	Lexicon *Lexicon
 	// ExpandSymbols expands symbols like & to "and"
 	ExpandSymbols bool
-	Logger        telemetry.Logger
+	// VerbalizeNumbers spells out digits, currencies, dates, times and units
+	// in the spoken language, "$4.50" as "four dollars fifty cents"
+	VerbalizeNumbers bool
+	// Emoji strips or names emoji and emoticons such as ":)", which many
+	// providers read out as codepoint names (default: EmojiKeep)
+	Emoji EmojiMode
+	// EmojiNames add to or override the built-in English emoji names used by
+	// EmojiVerbalize, e.g. "🎉" to "emoji de fiesta". An empty name strips
+	// the emoji.
+	EmojiNames map[string]string
+	// SSML renders each sentence as an SSML document, nil emits plain text
+	SSML *SSMLConfig
+	// ForwardRaw also sends each incoming delta untouched as a
+	// core.RawTextEvent, so text clients can render the markdown the cleaned
+	// LLMEvents lose. Route the two kinds to their sinks with a fan-out.
+	ForwardRaw bool
+
+	// Language selects the sentence segmenter and verbalizer until a
+	// LanguageDetectedEvent switches it
+	Language string
+	// Segmenters override the built-in segmenters by language ("pt-BR",
+	// then "pt"). Languages without one are segmented as English.
+	Segmenters map[string]Segmenter
+	// Verbalizers override the built-in number verbalizers by language, like
+	// Segmenters
+	Verbalizers map[string]Verbalizer
+
+	// MinChunkLength holds back sentences shorter than this many characters
+	// and merges them with the next, so TTS isn't called for every "Sure."
+	// Zero dispatches every sentence.
+	MinChunkLength int
+	// MaxChunkLength dispatches text without a sentence boundary once it
+	// grows past this many characters, split after the last clause
+	// punctuation or word. Zero waits for the sentence to end.
+	MaxChunkLength int
+	// FlushAfter dispatches the buffered words when no new tokens arrive for
+	// this long, so short clauses are synthesized early on slow LLMs. Zero
+	// disables it.
+	FlushAfter time.Duration
+
+	Logger telemetry.Logger
 }
 
 // TextProcessorStage sanitizes and buffers text for TTS consumption
 // It sits between LLM and TTS, handling:
-// - Markdown stripping (**, ##, ```)
+// - Markdown stripping (**, ##, ```), reading lists and tables as prose
 // - Code block removal
-// - Symbol/abbreviation expansion
-// - Sentence boundary detection
-// - Buffering into semantic chunks
+// - Symbol/abbreviation expansion and number verbalization
+// - Emoji stripping or naming
+// - Language-aware sentence boundary detection, see Segmenter
+// - Buffering into semantic chunks of configurable length
+// - Optional SSML rendering for providers that accept it
 type TextProcessorStage struct {
-	config TextProcessorStageConfig
+	config         TextProcessorStageConfig
+	updates        pendingConfig
+	abbreviations  *wordReplacer
+	pronunciations *wordReplacer
+	emojiNames     map[string]string
 }
 
 // NewTextProcessorStage creates a new text processor stage
 func NewTextProcessorStage(config TextProcessorStageConfig) *TextProcessorStage {
+	abbreviations := make(map[string]string)
+	if config.ExpandAbbreviations {
+		for abbr, expansion := range englishAbbreviationExpansions {
+			abbreviations[abbr] = expansion
+		}
+	}
+	var pronunciations map[string]string
+	if config.Lexicon != nil {
+		for abbr, expansion := range config.Lexicon.Abbreviations {
+			abbreviations[abbr] = expansion
+		}
+		pronunciations = config.Lexicon.Pronunciations
+	}
+
+	names := emojiNames
+	if len(config.EmojiNames) > 0 {
+		names = make(map[string]string, len(emojiNames)+len(config.EmojiNames))
+		for emoji, name := range emojiNames {
+			names[emoji] = name
+		}
+		for emoji, name := range config.EmojiNames {
+			names[emoji] = name
+		}
+	}
+
 	return &TextProcessorStage{
-		config: config,
+		config:         config,
+		abbreviations:  newWordReplacer(abbreviations, false),
+		pronunciations: newWordReplacer(pronunciations, true),
+		emojiNames:     names,
 	}
 }
 
@@ -53,7 +139,7 @@ func (s *TextProcessorStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the event types this stage produces
 func (s *TextProcessorStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeLLM, core.EventTypeStatus, core.EventTypeDone}
+	return []core.EventType{core.EventTypeLLM, core.EventTypeRawText, core.EventTypeStatus, core.EventTypeDone}
 }
 
 // Process implements the Stage interface
@@ -71,22 +157,93 @@ func (s *TextProcessorStage) Process(ctx context.Context, input <-chan core.Even
 	markdownLinkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^\)]+\)`)
 	htmlTagRegex := regexp.MustCompile(`<[^>]+>`)
 
-	// Sentence boundary detection
-	sentenceBoundaryRegex := regexp.MustCompile(`[.!?\n]`)
+	// Sentence boundary detection and verbalization follow the spoken language
+	s.applyPendingConfig()
+	segmenter := segmenterFor(s.config.Segmenters, s.config.Language)
+	verbalizer := verbalizerFor(s.config.Verbalizers, s.config.Language)
+
+	// emit normalizes and sends a chunk of text
+	emit := func(text, reason string) error {
+		text = handleEmoji(text, s.config.Emoji, s.emojiNames)
+		text = s.pronunciations.replace(text)
+		if s.config.VerbalizeNumbers {
+			text = verbalizer.Verbalize(text)
+		}
+		chunk := strings.TrimSpace(s.normalizeSentence(text))
+		if chunk == "" {
+			return nil
+		}
+		logger.Debug("Emitting processed text", telemetry.String("text", chunk), telemetry.String("reason", reason))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- core.LLMEvent{Delta: s.render(chunk)}:
+			return nil
+		}
+	}
+
+	// Lists and tables are flattened line by line before cleaning
+	var flattener *markdownFlattener
+	if s.config.StripMarkdown {
+		flattener = newMarkdownFlattener(s.config.Tables, s.config.TablePlaceholder)
+	}
+	clean := func(text string) string {
+		return s.cleanText(text, codeBlockRegex, inlineCodeRegex, markdownBoldRegex, markdownItalicRegex, markdownHeaderRegex, markdownLinkRegex, htmlTagRegex)
+	}
+	// flushLines releases a line the flattener still holds
+	flushLines := func() {
+		if flattener != nil {
+			buffer.WriteString(clean(flattener.flush()))
+		}
+	}
+
+	// Flush buffered words after a pause in the token stream
+	var idle <-chan time.Time
+	var timer *time.Timer
+	if s.config.FlushAfter > 0 {
+		timer = time.NewTimer(s.config.FlushAfter)
+		timer.Stop()
+		defer timer.Stop()
+	}
+
+	for {
+		var event core.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-idle:
+			idle = nil
+			// Keep a word that may still be arriving
+			text := buffer.String()
+			cut := strings.LastIndexFunc(text, unicode.IsSpace)
+			if cut <= 0 {
+				continue
+			}
+			buffer.Reset()
+			buffer.WriteString(text[cut:])
+			if err := emit(text[:cut], "idle"); err != nil {
+				return err
+			}
+			continue
+
+		case next, ok := <-input:
+			if !ok {
+				// Input closed without DoneEvent - flush buffer
+				flushLines()
+				return emit(buffer.String(), "input closed")
+			}
+			event = next
+		}
 
-	for event := range input {
 		// Forward DoneEvent immediately
 		if doneEvent, ok := event.(core.DoneEvent); ok {
 			logger.Info("text processor received DoneEvent, forwarding to TTS")
 
 			// Flush any remaining buffer first
-			if buffer.Len() > 0 {
-				normalizedText := s.normalizeSentence(buffer.String())
-				finalText := strings.TrimSpace(normalizedText)
-				if finalText != "" {
-					logger.Debug("emitting flushed text before DoneEvent", telemetry.String("text", finalText))
-					output <- core.LLMEvent{Delta: finalText}
-				}
+			flushLines()
+			if err := emit(buffer.String(), "done"); err != nil {
+				return err
 			}
 
 			// Forward DoneEvent to TTS
@@ -100,12 +257,37 @@ func (s *TextProcessorStage) Process(ctx context.Context, input <-chan core.Even
 			output <- statusEvent
 			continue
 		}
+		if usageEvent, ok := event.(core.UsageEvent); ok {
+			output <- usageEvent
+			continue
+		}
+		if markEvent, ok := event.(core.LatencyMarkEvent); ok {
+			output <- markEvent
+			continue
+		}
+		if languageEvent, ok := event.(core.LanguageDetectedEvent); ok {
+			segmenter = segmenterFor(s.config.Segmenters, languageEvent.Language)
+			verbalizer = verbalizerFor(s.config.Verbalizers, languageEvent.Language)
+			output <- languageEvent
+			continue
+		}
 
 		if llmEvent, ok := event.(core.LLMEvent); ok {
 			delta := llmEvent.Delta
+			if s.config.ForwardRaw && delta != "" {
+				raw := core.RawTextEvent{Delta: delta, Content: llmEvent.Content, Meta: llmEvent.Meta}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case output <- raw:
+				}
+			}
+			if flattener != nil {
+				delta = flattener.write(delta)
+			}
 
 			// Clean the token immediately
-			cleanedToken := s.cleanText(delta, codeBlockRegex, inlineCodeRegex, markdownBoldRegex, markdownItalicRegex, markdownHeaderRegex, markdownLinkRegex, htmlTagRegex)
+			cleanedToken := clean(delta)
 
 			// Skip only if the cleaned token is completely empty (not just whitespace)
 			if cleanedToken == "" {
@@ -118,39 +300,53 @@ func (s *TextProcessorStage) Process(ctx context.Context, input <-chan core.Even
 			// Check if buffer contains a sentence boundary
 			currentText := buffer.String()
 
-			// Look for sentence boundaries in the current buffer
-			if s.isSentenceComplete(currentText, sentenceBoundaryRegex) {
-				// Normalize and send the complete sentence
-				normalizedText := s.normalizeSentence(currentText)
-				finalSentence := strings.TrimRight(normalizedText, " \t\n\r")
-
-				if strings.TrimSpace(finalSentence) != "" {
-					logger.Debug("Emitting processed sentence", telemetry.String("text", finalSentence))
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					case output <- core.LLMEvent{
-						Delta: finalSentence,
-					}:
+			// Look for sentence boundaries in the current buffer, holding
+			// back sentences too short to synthesize on their own
+			if segmenter.SentenceEnd(currentText) && !s.endsWithAbbreviation(currentText) && s.longEnough(currentText) {
+				buffer.Reset()
+				if err := emit(currentText, "sentence"); err != nil {
+					return err
+				}
+			} else if s.config.MaxChunkLength > 0 && utf8.RuneCountInString(currentText) > s.config.MaxChunkLength {
+				if cut := clauseCut(currentText); cut > 0 {
+					buffer.Reset()
+					buffer.WriteString(currentText[cut:])
+					if err := emit(currentText[:cut], "max length"); err != nil {
+						return err
 					}
 				}
+			}
 
-				buffer.Reset()
+			if timer != nil {
+				timer.Reset(s.config.FlushAfter)
+				idle = timer.C
 			}
 		}
 	}
+}
 
-	// If we get here, input closed without DoneEvent - flush buffer
-	if buffer.Len() > 0 {
-		normalizedText := s.normalizeSentence(buffer.String())
-		finalText := strings.TrimSpace(normalizedText)
-		if finalText != "" {
-			logger.Debug("emitting flushed text on input close", telemetry.String("text", finalText))
-			output <- core.LLMEvent{Delta: finalText}
-		}
-	}
+// endsWithAbbreviation reports whether text ends with an abbreviation the
+// stage expands, whose period doesn't end the sentence
+func (s *TextProcessorStage) endsWithAbbreviation(text string) bool {
+	return s.abbreviations.endsWith(strings.TrimRightFunc(text, unicode.IsSpace))
+}
+
+// longEnough reports whether buffered text reaches MinChunkLength
+func (s *TextProcessorStage) longEnough(text string) bool {
+	return utf8.RuneCountInString(strings.TrimSpace(text)) >= s.config.MinChunkLength
+}
 
-	return nil
+// clauseCut returns where to split text that grew too long: after its last
+// clause punctuation, or else before its last word. It returns 0 when text
+// has neither.
+func clauseCut(text string) int {
+	if i := strings.LastIndexAny(text, ",;:"); i > 0 {
+		return i + 1
+	}
+	if i := strings.LastIndexFunc(strings.TrimRightFunc(text, unicode.IsSpace), unicode.IsSpace); i > 0 {
+		return i
+	}
+	return 0
 }
 
 // cleanText removes markdown, code blocks, and HTML from text
@@ -187,7 +383,15 @@ func (s *TextProcessorStage) cleanText(
 	return result
 }
 
-// normalizeSentence expands abbreviations and symbols
+// render returns the sentence as SSML when configured, or unchanged
+func (s *TextProcessorStage) render(text string) string {
+	if s.config.SSML == nil {
+		return text
+	}
+	return toSSML(text, *s.config.SSML)
+}
+
+// normalizeSentence expands symbols and abbreviations
 func (s *TextProcessorStage) normalizeSentence(text string) string {
 	result := text
 
@@ -197,108 +401,7 @@ func (s *TextProcessorStage) normalizeSentence(text string) string {
 		result = strings.ReplaceAll(result, "#", "number")
 	}
 
-	if s.config.ExpandAbbreviations {
-		result = s.expandAbbreviations(result)
-	}
-
-	return result
-}
-
-// expandAbbreviations expands common abbreviations for TTS
-func (s *TextProcessorStage) expandAbbreviations(text string) string {
-	// Common abbreviations that should be expanded for TTS
-	// Order matters - longer abbreviations should be replaced first to avoid partial matches
-	abbreviations := []struct {
-		abbr      string
-		expansion string
-	}{
-		{"U.S.", "United States"},
-		{"U.K.", "United Kingdom"},
-		{"e.g.", "for example"},
-		{"i.e.", "that is"},
-		{"Dr.", "Doctor"},
-		{"Mr.", "Mister"},
-		{"Mrs.", "Misses"},
-		{"Ms.", "Miss"},
-		{"Prof.", "Professor"},
-		{"St.", "Street"},
-		{"Ave.", "Avenue"},
-		{"Blvd.", "Boulevard"},
-		{"etc.", "et cetera"},
-		{"vs.", "versus"},
-		{"No.", "Number"},
-		{"Inc.", "Incorporated"},
-		{"Ltd.", "Limited"},
-		{"Co.", "Company"},
-		{"Corp.", "Corporation"},
-		{"Jan.", "January"},
-		{"Feb.", "February"},
-		{"Mar.", "March"},
-		{"Apr.", "April"},
-		{"Aug.", "August"},
-		{"Sept.", "September"},
-		{"Oct.", "October"},
-		{"Nov.", "November"},
-		{"Dec.", "December"},
-		{"Mon.", "Monday"},
-		{"Tue.", "Tuesday"},
-		{"Wed.", "Wednesday"},
-		{"Thu.", "Thursday"},
-		{"Fri.", "Friday"},
-		{"Sat.", "Saturday"},
-		{"Sun.", "Sunday"},
-	}
-
-	result := text
-	for _, pair := range abbreviations {
-		// Simple string replacement - abbreviations are typically followed by space or end of string
-		result = strings.ReplaceAll(result, pair.abbr, pair.expansion)
-	}
+	result = s.abbreviations.replace(result)
 
 	return result
 }
-
-// isSentenceComplete checks if text ends with a sentence boundary
-func (s *TextProcessorStage) isSentenceComplete(text string, boundaryRegex *regexp.Regexp) bool {
-	if len(text) == 0 {
-		return false
-	}
-
-	// Trim trailing whitespace to check the actual last character
-	trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
-	if len(trimmed) == 0 {
-		return false
-	}
-
-	lastChar := rune(trimmed[len(trimmed)-1])
-
-	// Check for sentence-ending punctuation
-	if lastChar != '.' && lastChar != '?' && lastChar != '!' && lastChar != '\n' {
-		return false
-	}
-
-	// Avoid false positives on abbreviations - only check the LAST period
-	if lastChar == '.' && s.endsWithAbbreviation(trimmed) {
-		return false
-	}
-
-	return true
-}
-
-// endsWithAbbreviation checks if text ends with a known abbreviation (not just contains one)
-func (s *TextProcessorStage) endsWithAbbreviation(text string) bool {
-	commonAbbreviations := []string{
-		"Dr.", "Mr.", "Mrs.", "Ms.", "Prof.", "St.", "Ave.", "Blvd.",
-		"etc.", "e.g.", "i.e.", "vs.", "No.", "Inc.", "Ltd.", "Co.", "Corp.",
-		"U.S.", "U.K.", "Jan.", "Feb.", "Mar.", "Apr.", "Aug.", "Sept.", "Oct.", "Nov.", "Dec.",
-		"Mon.", "Tue.", "Wed.", "Thu.", "Fri.", "Sat.", "Sun.",
-	}
-
-	for _, abbr := range commonAbbreviations {
-		if strings.HasSuffix(text, abbr) {
-			return true
-		}
-	}
-
-	return false
-}
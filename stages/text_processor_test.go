@@ -2,7 +2,9 @@ package stages
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/creastat/pipeline/core"
 )
@@ -358,3 +360,112 @@ func TestTextProcessorStage_SkipsEmptyDeltas(t *testing.T) {
 		t.Errorf("expected 1 LLM event (empty deltas skipped), got %d", llmCount)
 	}
 }
+
+// TestTextProcessorChunking tests the chunk length bounds and the idle flush
+func TestTextProcessorChunking(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TextProcessorStageConfig
+		inputs   []string
+		pause    time.Duration // Before the last input
+		expected []string
+	}{
+		{
+			name:     "short sentences merge",
+			config:   TextProcessorStageConfig{MinChunkLength: 10},
+			inputs:   []string{"Sure. ", "It opens at nine."},
+			expected: []string{"Sure. It opens at nine."},
+		},
+		{
+			name:     "long text splits at clause",
+			config:   TextProcessorStageConfig{MaxChunkLength: 20},
+			inputs:   []string{"Well, if you ask me", " then", " yes."},
+			expected: []string{"Well,", "if you ask me then yes."},
+		},
+		{
+			name:     "long text splits at word",
+			config:   TextProcessorStageConfig{MaxChunkLength: 10},
+			inputs:   []string{"one two three", " four."},
+			expected: []string{"one two", "three four."},
+		},
+		{
+			name:     "streamed numbers are verbalized whole",
+			config:   TextProcessorStageConfig{VerbalizeNumbers: true},
+			inputs:   []string{"It costs $4", ".", "50 today."},
+			expected: []string{"It costs four dollars fifty cents today."},
+		},
+		{
+			name:     "idle flush keeps the last word",
+			config:   TextProcessorStageConfig{FlushAfter: 20 * time.Millisecond},
+			inputs:   []string{"Let me check th", "at for you."},
+			pause:    200 * time.Millisecond,
+			expected: []string{"Let me check", "that for you."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := NewTextProcessorStage(tt.config)
+
+			input := make(chan core.Event, len(tt.inputs)+1)
+			output := make(chan core.Event, 100)
+			go func() {
+				for i, inp := range tt.inputs {
+					if i == len(tt.inputs)-1 {
+						time.Sleep(tt.pause)
+					}
+					input <- core.LLMEvent{Delta: inp}
+				}
+				input <- core.DoneEvent{}
+				close(input)
+			}()
+
+			if err := stage.Process(context.Background(), input, output); err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+			close(output)
+
+			var results []string
+			for event := range output {
+				if llmEvent, ok := event.(core.LLMEvent); ok {
+					results = append(results, llmEvent.Delta)
+				}
+			}
+			if !reflect.DeepEqual(results, tt.expected) {
+				t.Errorf("got %q, want %q", results, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTextProcessorForwardRaw tests that raw deltas are forwarded alongside
+// the cleaned sentences
+func TestTextProcessorForwardRaw(t *testing.T) {
+	stage := NewTextProcessorStage(TextProcessorStageConfig{StripMarkdown: true, ForwardRaw: true})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.LLMEvent{Delta: "It's **free**", Content: "It's **free**"}
+	input <- core.LLMEvent{Delta: ".", Content: "It's **free**."}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var results []core.Event
+	for event := range output {
+		results = append(results, event)
+	}
+	expected := []core.Event{
+		core.RawTextEvent{Delta: "It's **free**", Content: "It's **free**"},
+		core.RawTextEvent{Delta: ".", Content: "It's **free**."},
+		core.LLMEvent{Delta: "It's free."},
+		core.DoneEvent{},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got %+v, want %+v", results, expected)
+	}
+}
@@ -0,0 +1,170 @@
+package stages
+
+import (
+	"context"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// ThrottleStageConfig holds configuration for ThrottleStage
+type ThrottleStageConfig struct {
+	// Interval is the minimum time between two throttled events. Defaults to
+	// 100ms, at most 10 updates per second.
+	Interval time.Duration
+
+	// Match selects the events to throttle; others pass straight through.
+	// Defaults to interim STT events and LLM deltas.
+	Match func(core.Event) bool
+}
+
+// ThrottleStage rate-limits fast streams of superseding events, such as
+// interim transcripts and LLM deltas, so clients aren't flooded with
+// hundreds of messages per second. The first event goes out at once; events
+// arriving within Interval of the last one sent are held and merged, and
+// the merged event goes out when the interval ends. Interim STT events are
+// merged by keeping the latest, LLM deltas by concatenating them, so no
+// text is lost.
+//
+// A held event is flushed before any event it can't be merged with, and
+// when the input closes, so event order is preserved.
+type ThrottleStage struct {
+	config ThrottleStageConfig
+}
+
+// NewThrottleStage creates a new throttle stage
+func NewThrottleStage(config ThrottleStageConfig) *ThrottleStage {
+	if config.Interval <= 0 {
+		config.Interval = 100 * time.Millisecond
+	}
+	if config.Match == nil {
+		config.Match = isStreamingUpdate
+	}
+	return &ThrottleStage{config: config}
+}
+
+// isStreamingUpdate reports whether an event is an interim STT result or an
+// LLM delta
+func isStreamingUpdate(event core.Event) bool {
+	switch e := event.(type) {
+	case core.STTEvent:
+		return !e.IsFinal
+	case core.LLMEvent:
+		return true
+	}
+	return false
+}
+
+// mergeThrottled folds next into held, returning false if the two can't be
+// merged
+func mergeThrottled(held, next core.Event) (core.Event, bool) {
+	if held.EventType() != next.EventType() ||
+		core.MetaOf(held).Turn.ResponseID != core.MetaOf(next).Turn.ResponseID {
+		return nil, false
+	}
+	switch h := held.(type) {
+	case core.LLMEvent:
+		n := next.(core.LLMEvent)
+		n.Delta = h.Delta + n.Delta
+		return n, true
+	case core.STTEvent:
+		if n := next.(core.STTEvent); !n.IsFinal {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// Name returns the stage name
+func (s *ThrottleStage) Name() string {
+	return "throttle"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *ThrottleStage) InputTypes() []core.EventType {
+	// Throttle stage accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *ThrottleStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *ThrottleStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	var held core.Event
+	var lastSent time.Time
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	flush := func() error {
+		if held == nil {
+			return nil
+		}
+		timer.Stop()
+		event := held
+		held = nil
+		lastSent = time.Now()
+		return send(event)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+
+		case event, ok := <-input:
+			if !ok {
+				return flush()
+			}
+
+			if !s.config.Match(event) {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := send(event); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if held != nil {
+				if merged, ok := mergeThrottled(held, event); ok {
+					held = merged
+					continue
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+
+			if wait := s.config.Interval - time.Since(lastSent); wait > 0 {
+				held = event
+				timer.Reset(wait)
+				continue
+			}
+			lastSent = time.Now()
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
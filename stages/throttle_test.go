@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+func TestThrottleStage_MergesDeltas(t *testing.T) {
+	stage := NewThrottleStage(ThrottleStageConfig{Interval: time.Hour})
+
+	input := make(chan core.Event, 6)
+	input <- core.LLMEvent{Delta: "He", Content: "He"}
+	input <- core.LLMEvent{Delta: "l", Content: "Hel"}
+	input <- core.LLMEvent{Delta: "lo", Content: "Hello"}
+	input <- core.STTEvent{Text: "n"}
+	input <- core.STTEvent{Text: "next"}
+	input <- core.DoneEvent{FullText: "Hello"}
+	close(input)
+
+	output := make(chan core.Event, 8)
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %v", len(events), events)
+	}
+	if llm := events[0].(core.LLMEvent); llm.Delta != "He" {
+		t.Errorf("expected first delta sent at once, got %q", llm.Delta)
+	}
+	if llm := events[1].(core.LLMEvent); llm.Delta != "llo" || llm.Content != "Hello" {
+		t.Errorf("expected merged delta, got %+v", llm)
+	}
+	if stt := events[2].(core.STTEvent); stt.Text != "next" {
+		t.Errorf("expected latest interim, got %q", stt.Text)
+	}
+	if _, ok := events[3].(core.DoneEvent); !ok {
+		t.Errorf("expected done last, got %T", events[3])
+	}
+}
+
+func TestThrottleStage_FlushesAfterInterval(t *testing.T) {
+	stage := NewThrottleStage(ThrottleStageConfig{Interval: 20 * time.Millisecond})
+
+	input := make(chan core.Event)
+	output := make(chan core.Event, 8)
+	done := make(chan error, 1)
+	go func() { done <- stage.Process(context.Background(), input, output) }()
+
+	input <- core.STTEvent{Text: "h"}
+	input <- core.STTEvent{Text: "he"}
+	input <- core.STTEvent{Text: "hel"}
+
+	for _, want := range []string{"h", "hel"} {
+		select {
+		case event := <-output:
+			if stt := event.(core.STTEvent); stt.Text != want {
+				t.Errorf("expected %q, got %q", want, stt.Text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event for %q", want)
+		}
+	}
+
+	close(input)
+	if err := <-done; err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(output) != 0 {
+		t.Errorf("expected no further events, got %d", len(output))
+	}
+}
@@ -0,0 +1,281 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+// defaultToolTimeout bounds a tool call when neither the tool nor the stage
+// sets a timeout
+const defaultToolTimeout = 10 * time.Second
+
+// defaultMaxConcurrentTools bounds how many tool calls run at once
+const defaultMaxConcurrentTools = 4
+
+// ToolHandler executes a tool call with its validated input and returns the
+// output reported back to the LLM and the client
+type ToolHandler func(ctx context.Context, input map[string]any) (any, error)
+
+// Tool is a Go function the LLM can call on the server
+type Tool struct {
+	Name        string
+	Description string
+	Schema      *DataSchema // Validates the call's input, nil accepts any input
+	Handler     ToolHandler
+	Timeout     time.Duration // Overrides ToolStageConfig.Timeout for this tool
+}
+
+// ToolDefinition describes a tool to the model, see LLMStageConfig.Tools
+type ToolDefinition struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  *DataSchema `json:"parameters,omitempty"`
+}
+
+// ToolRegistry holds the tools a session may call. It is safe for concurrent
+// use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string // Registration order, for stable definitions
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]Tool),
+	}
+}
+
+// Register adds a tool under its name
+func (r *ToolRegistry) Register(tool Tool) error {
+	if tool.Name == "" {
+		return errors.New("tool has no name")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %q has no handler", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %q already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Lookup returns the tool registered under name
+func (r *ToolRegistry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Definitions describes the registered tools for LLMStageConfig.Tools
+func (r *ToolRegistry) Definitions() []ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		definitions = append(definitions, ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Schema,
+		})
+	}
+	return definitions
+}
+
+// ToolStageConfig holds configuration for ToolStage
+type ToolStageConfig struct {
+	Tools *ToolRegistry
+
+	// Timeout bounds each tool call unless the tool sets its own
+	// (default: 10 seconds)
+	Timeout time.Duration
+
+	// MaxConcurrent is how many tool calls run at once (default: 4)
+	MaxConcurrent int
+
+	Logger telemetry.Logger
+}
+
+// ToolStage executes the server-side tool calls LLMStage emits. Each call is
+// validated against its tool's schema and runs in parallel with the others
+// of the turn, reported by a ToolStartEvent and a ToolResultEvent, the
+// tool.start and tool.result messages. Unknown tools, invalid input, errors
+// and timeouts are reported as failed results, so the LLM can be told. The
+// turn's DoneEvent is passed on once all its calls have finished.
+//
+// Feed the results back to the LLM by running both in a LoopStage.
+type ToolStage struct {
+	config ToolStageConfig
+}
+
+// NewToolStage creates a new tool stage
+func NewToolStage(config ToolStageConfig) *ToolStage {
+	if config.Tools == nil {
+		config.Tools = NewToolRegistry()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultToolTimeout
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = defaultMaxConcurrentTools
+	}
+	return &ToolStage{config: config}
+}
+
+// Name returns the stage name
+func (s *ToolStage) Name() string {
+	return "tool"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *ToolStage) InputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeToolCall}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *ToolStage) OutputTypes() []core.EventType {
+	return []core.EventType{core.EventTypeToolStart, core.EventTypeToolResult, core.EventTypeDone}
+}
+
+// Process implements the Stage interface
+func (s *ToolStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+	slots := make(chan struct{}, s.config.MaxConcurrent)
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	for {
+		var event core.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next, ok := <-input:
+			if !ok {
+				return nil
+			}
+			event = next
+		}
+
+		switch e := event.(type) {
+		case core.ToolCallEvent:
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case slots <- struct{}{}:
+			}
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				defer func() { <-slots }()
+				s.execute(ctx, e, send, logger)
+			}()
+			continue
+
+		case core.DoneEvent:
+			// The turn ends once its calls have reported their results
+			running.Wait()
+		}
+
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// execute runs a tool call and sends its start and result
+func (s *ToolStage) execute(ctx context.Context, call core.ToolCallEvent, send func(core.Event) error, logger telemetry.Logger) {
+	result := core.ToolResultEvent{ToolID: call.ToolID, ToolName: call.ToolName, Meta: call.Meta}
+	fail := func(err error) {
+		logger.Warn("Tool call failed", telemetry.String("tool", call.ToolName), telemetry.String("tool_id", call.ToolID), telemetry.Err(err))
+		result.Error = err.Error()
+		send(result)
+	}
+
+	tool, ok := s.config.Tools.Lookup(call.ToolName)
+	if !ok {
+		fail(fmt.Errorf("unknown tool %q", call.ToolName))
+		return
+	}
+	if tool.Schema != nil {
+		input := any(call.Input)
+		if call.Input == nil {
+			input = map[string]any{}
+		}
+		if err := tool.Schema.Validate(input); err != nil {
+			fail(fmt.Errorf("invalid input: %w", err))
+			return
+		}
+	}
+
+	if err := send(core.ToolStartEvent{
+		ToolID:      call.ToolID,
+		ToolName:    call.ToolName,
+		Description: tool.Description,
+		Input:       call.Input,
+		Meta:        call.Meta,
+	}); err != nil {
+		return
+	}
+
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = s.config.Timeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Run the handler aside so one ignoring its context still times out
+	type outcome struct {
+		output any
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, err := tool.Handler(callCtx, call.Input)
+		done <- outcome{output, err}
+	}()
+
+	select {
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return
+		}
+		result.TimedOut = true
+		fail(fmt.Errorf("tool %q timed out after %s", call.ToolName, timeout))
+	case out := <-done:
+		if out.err != nil {
+			fail(out.err)
+			return
+		}
+		logger.Debug("Tool call finished", telemetry.String("tool", call.ToolName), telemetry.String("tool_id", call.ToolID))
+		result.Success = true
+		result.Output = out.output
+		send(result)
+	}
+}
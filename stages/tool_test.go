@@ -0,0 +1,146 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/pipelinetest"
+)
+
+// TestToolStage tests that tool calls run in parallel and that unknown
+// tools, invalid input, errors and timeouts are reported as failed results
+func TestToolStage(t *testing.T) {
+	// Both weather calls must be running for either to finish
+	var started sync.WaitGroup
+	started.Add(2)
+	tools := NewToolRegistry()
+	for _, tool := range []Tool{
+		{
+			Name:        "weather",
+			Description: "Current weather in a city",
+			Schema: &DataSchema{
+				Type:       "object",
+				Required:   []string{"city"},
+				Properties: map[string]*DataSchema{"city": {Type: "string"}},
+			},
+			Handler: func(ctx context.Context, input map[string]any) (any, error) {
+				started.Done()
+				started.Wait()
+				return input["city"].(string) + ": 4°C", nil
+			},
+		},
+		{
+			Name: "slow",
+			Handler: func(ctx context.Context, input map[string]any) (any, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			Timeout: 20 * time.Millisecond,
+		},
+		{
+			Name: "broken",
+			Handler: func(ctx context.Context, input map[string]any) (any, error) {
+				return nil, errors.New("backend down")
+			},
+		},
+	} {
+		if err := tools.Register(tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tools.Register(Tool{Name: "weather", Handler: func(context.Context, map[string]any) (any, error) { return nil, nil }}); err == nil {
+		t.Error("expected registering a tool twice to fail")
+	}
+
+	stage := NewToolStage(ToolStageConfig{Tools: tools, Logger: testLogger()})
+	results := runTurn(t, stage,
+		core.ToolCallEvent{ToolID: "t1", ToolName: "weather", Input: map[string]any{"city": "Oslo"}},
+		core.ToolCallEvent{ToolID: "t2", ToolName: "weather", Input: map[string]any{"city": "Rome"}},
+		core.ToolCallEvent{ToolID: "t3", ToolName: "weather", Input: map[string]any{"town": "Rome"}},
+		core.ToolCallEvent{ToolID: "t4", ToolName: "slow"},
+		core.ToolCallEvent{ToolID: "t5", ToolName: "broken"},
+		core.ToolCallEvent{ToolID: "t6", ToolName: "missing"},
+		core.DoneEvent{},
+	)
+
+	var starts, got []string
+	for i, event := range results {
+		switch e := event.(type) {
+		case core.ToolStartEvent:
+			starts = append(starts, e.ToolID)
+		case core.ToolResultEvent:
+			line := e.ToolID + " "
+			switch {
+			case e.Success:
+				line += "ok " + e.Output.(string)
+			case e.TimedOut:
+				line += "timed out"
+			default:
+				line += "failed " + e.Error
+			}
+			got = append(got, line)
+		case core.DoneEvent:
+			if i != len(results)-1 {
+				t.Error("expected the DoneEvent after every result")
+			}
+		}
+	}
+	sort.Strings(starts)
+	sort.Strings(got)
+
+	if expected := []string{"t1", "t2", "t4", "t5"}; !reflect.DeepEqual(starts, expected) {
+		t.Errorf("expected starts %v, got %v", expected, starts)
+	}
+	expected := []string{
+		"t1 ok Oslo: 4°C",
+		"t2 ok Rome: 4°C",
+		`t3 failed invalid input: data: missing required property "city"`,
+		"t4 timed out",
+		"t5 failed backend down",
+		`t6 failed unknown tool "missing"`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected results %q, got %q", expected, got)
+	}
+}
+
+// TestLLMStageToolCalls tests that the tools are offered to the model and
+// the calls it makes are emitted before the DoneEvent
+func TestLLMStageToolCalls(t *testing.T) {
+	tools := NewToolRegistry()
+	if err := tools.Register(Tool{
+		Name:    "weather",
+		Handler: func(context.Context, map[string]any) (any, error) { return nil, nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	llm := pipelinetest.NewFakeLLM("Let me check.")
+	llm.ToolCalls = []core.ToolCallEvent{{ToolID: "t1", ToolName: "weather", Input: map[string]any{"city": "Oslo"}}}
+	stage := NewLLMStage(LLMStageConfig{Provider: llm, Tools: tools.Definitions(), Logger: testLogger()})
+
+	results := runTurn(t, stage, core.LLMEvent{Delta: "Weather in Oslo?"}, core.DoneEvent{})
+
+	var order []string
+	for _, event := range results {
+		switch e := event.(type) {
+		case core.ToolCallEvent:
+			order = append(order, "call "+e.ToolName)
+		case core.DoneEvent:
+			order = append(order, "done")
+		}
+	}
+	if expected := []string{"call weather", "done"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+
+	options := llm.Requests()[0].Options
+	if definitions, ok := options["tools"].([]ToolDefinition); !ok || len(definitions) != 1 || definitions[0].Name != "weather" {
+		t.Errorf("expected the tools option to offer weather, got %v", options["tools"])
+	}
+}
@@ -5,29 +5,47 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/i18n"
 	providers "github.com/creastat/providers/core"
 )
 
 // TTSStageConfig holds TTS stage configuration
 type TTSStageConfig struct {
-	Provider providers.TTSProvider
-	Voice    string
-	Language string
-	Speed    *float64
-	Encoding string
-	Logger   telemetry.Logger
+	Provider   providers.TTSProvider
+	Fallbacks  []providers.TTSProvider          // Tried in order when the provider fails to open a stream
+	Presets    map[string]providers.TTSProvider // Providers selectable by preset name with ConfigUpdateEvent
+	Disabled   bool                             // Completes turns without synthesis, toggled by ConfigUpdateEvent.TTSEnabled
+	Voice      string
+	Voices     map[string]string // Voice per language, selected by LanguageDetectedEvent
+	Language   string
+	Speed      *float64
+	Encoding   string
+	SampleRate int          // Sample rate of the synthesized audio, reported on AudioEvents
+	Channels   int          // Channels of the synthesized audio, defaults to 1
+	SSML       *SSMLConfig  // Set when the provider accepts SSML; nil sends plain text
+	Cache      TTSCache     // Serves repeated phrases without the provider; see processCached
+	Retry      *RetryPolicy // Retries for starting the provider stream, nil disables
+	Limiter    *RateLimiter // Rate limits shared across pipelines, nil disables
+	Logger     telemetry.Logger
 }
 
 // TTSStage represents a text-to-speech processing stage
 type TTSStage struct {
-	config TTSStageConfig
+	config  TTSStageConfig
+	updates pendingConfig
 }
 
 // NewTTSStage creates a new TTS stage
 func NewTTSStage(config TTSStageConfig) *TTSStage {
+	if config.Channels == 0 {
+		config.Channels = 1
+	}
 	return &TTSStage{
 		config: config,
 	}
@@ -45,13 +63,21 @@ func (s *TTSStage) InputTypes() []core.EventType {
 
 // OutputTypes returns the event types this stage produces
 func (s *TTSStage) OutputTypes() []core.EventType {
-	return []core.EventType{core.EventTypeAudio, core.EventTypeStatus, core.EventTypeDone}
+	return []core.EventType{core.EventTypeAudio, core.EventTypeStatus, core.EventTypeLanguage, core.EventTypeUsage, core.EventTypeLatency, core.EventTypeDone}
 }
 
 // Process implements the Stage interface
 // Note: Text buffering and cleaning is handled by TextProcessorStage upstream.
 // This stage receives pre-processed, sentence-complete text and focuses solely on TTS synthesis.
 func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	s.applyPendingConfig()
+	if s.config.Disabled {
+		return s.skipTurn(ctx, input, output)
+	}
+	if s.config.Cache != nil {
+		return s.processCached(ctx, input, output)
+	}
+
 	logger := s.config.Logger.WithModule(s.Name())
 
 	// Channels for coordination
@@ -62,30 +88,40 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 	var wg sync.WaitGroup
 	var stream providers.TTSStream
 	var streamErr error
+	providerName := s.config.Provider.Name() // Set to the provider that served the stream
+	language, voice := s.config.Language, s.config.Voice
+	var characters atomic.Int64 // Characters sent for synthesis
+	var firstText atomic.Int64  // When the first text arrived, in Unix nanoseconds
 	var streamOnce sync.Once
 	streamReady := make(chan struct{})
 
+	// An interrupt cancels the provider stream and drops any queued text and audio
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	interrupted := make(chan struct{})
+
+	startStream, releaseStream := limitStart(s.config.Limiter, "", func(ctx context.Context, provider providers.TTSProvider) (providers.TTSStream, error) {
+		return provider.StreamSynthesize(ctx, providers.TTSRequest{
+			Voice:    voice,
+			Language: language,
+			Speed:    s.config.Speed,
+			Options:  s.requestOptions(),
+		})
+	})
+
 	// Helper to initialize stream safely
 	initStream := func() bool {
 		streamOnce.Do(func() {
-			logger.Info("Starting TTS stream", telemetry.String("provider", s.config.Provider.Name()), telemetry.String("language", s.config.Language), telemetry.String("voice", s.config.Voice))
-			stream, streamErr = s.config.Provider.StreamSynthesize(ctx, providers.TTSRequest{
-				Voice:    s.config.Voice,
-				Language: s.config.Language,
-				Speed:    s.config.Speed,
-			})
+			logger.Info("Starting TTS stream", telemetry.String("provider", providerName), telemetry.String("language", language), telemetry.String("voice", voice))
+			chain := providerChain(s.config.Provider, s.config.Fallbacks)
+			var served int
+			stream, served, streamErr = withFallback(streamCtx, chain, s.config.Retry, logger, startStream)
+			providerName = chain[served].Name()
 			if streamErr != nil {
-				logger.Error("Failed to start TTS stream", telemetry.Err(streamErr), telemetry.String("provider", s.config.Provider.Name()), telemetry.String("language", s.config.Language))
+				logger.Error("Failed to start TTS stream", telemetry.Err(streamErr), telemetry.String("provider", providerName), telemetry.String("language", language))
 
 				// Emit user-friendly service message instead of raw error
-				output <- core.ServiceMessageEvent{
-					MessageType: core.ServiceMessageWarning,
-					Content:     "I'm having trouble with my voice right now, but I can still chat via text.",
-					Localized: map[string]string{
-						"en": "I'm having trouble with my voice right now, but I can still chat via text.",
-						"ru": "У меня возникли проблемы с голосом, но я всё ещё могу общаться текстом.",
-					},
-				}
+				output <- voiceUnavailableMessage()
 
 				// Signal ready even on error so waiters can unblock and see the failure
 				close(streamReady)
@@ -115,21 +151,27 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 		defer func() {
 			// After all text is sent, call Finish() if supported
 			// This must be done BEFORE waiting for audio to avoid deadlock
+			if isInterrupted(interrupted) {
+				return
+			}
 			logger.Trace("Text sending complete, calling Finish() if supported")
 			if finisher, ok := stream.(interface{ Finish(context.Context) error }); ok {
-				logger.Trace("Stream supports Finish(), calling it now", telemetry.String("provider", s.config.Provider.Name()))
-				if err := finisher.Finish(ctx); err != nil {
+				logger.Trace("Stream supports Finish(), calling it now", telemetry.String("provider", providerName))
+				if err := finisher.Finish(streamCtx); err != nil {
 					logger.Error("Failed to finish TTS stream", telemetry.Err(err))
 				} else {
-					logger.Info("Successfully called Finish() on TTS provider", telemetry.String("provider", s.config.Provider.Name()))
+					logger.Info("Successfully called Finish() on TTS provider", telemetry.String("provider", providerName))
 				}
 			} else {
-				logger.Trace("Stream does not support Finish() (not Minimax)", telemetry.String("provider", s.config.Provider.Name()))
+				logger.Trace("Stream does not support Finish() (not Minimax)", telemetry.String("provider", providerName))
 			}
 		}()
 
 		for text := range textChan {
-			if err := stream.Send(ctx, text); err != nil {
+			if isInterrupted(interrupted) {
+				return
+			}
+			if err := stream.Send(streamCtx, text); err != nil {
 				logger.Error("Failed to send text to TTS stream", telemetry.Err(err))
 				select {
 				case errChan <- fmt.Errorf("failed to send text to TTS: %w", err):
@@ -137,7 +179,8 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 				}
 				return
 			}
-			logger.Trace("Sent text to TTS provider", telemetry.String("text", text), telemetry.String("provider", s.config.Provider.Name()))
+			characters.Add(int64(utf8.RuneCountInString(text)))
+			logger.Trace("Sent text to TTS provider", telemetry.String("text", text), telemetry.String("provider", providerName))
 		}
 		logger.Trace("Text channel closed, text-sending goroutine exiting")
 	}()
@@ -158,13 +201,20 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 		if stream == nil {
 			return
 		}
-		defer stream.Close()
+		defer func() {
+			stream.Close()
+			releaseStream(stream)
+		}()
 
 		var audioChunkCount int
+		var seqNum uint64
 		var firstChunkLogged bool
 
 		for {
-			chunk, err := stream.Receive(ctx)
+			chunk, err := stream.Receive(streamCtx)
+			if isInterrupted(interrupted) {
+				return
+			}
 			if err != nil {
 				// If the error is EOF or similar "done" error, treat it as success
 				if strings.Contains(err.Error(), "EOF") || strings.Contains(err.Error(), "stream closed") {
@@ -192,6 +242,7 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 			}
 
 			audioChunkCount++
+			seqNum++
 			if !firstChunkLogged {
 				logger.Debug("Received audio chunk and forwarding audio event", telemetry.Int("size", len(chunk.Audio)))
 				firstChunkLogged = true
@@ -200,11 +251,14 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 			}
 
 			select {
-			case <-ctx.Done():
+			case <-streamCtx.Done():
 				return
 			case audioChan <- core.AudioEvent{
-				Data:   chunk.Audio,
-				Format: s.config.Encoding,
+				Data:       chunk.Audio,
+				Format:     s.config.Encoding,
+				SampleRate: s.config.SampleRate,
+				Channels:   s.config.Channels,
+				SeqNum:     seqNum,
 			}:
 			}
 		}
@@ -219,12 +273,34 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 		hasSentStatus := false
 
 		for event := range input {
+			// Upstream usage and latency are for the aggregators downstream
+			if usageEvent, ok := event.(core.UsageEvent); ok {
+				output <- usageEvent
+				continue
+			}
+			if markEvent, ok := event.(core.LatencyMarkEvent); ok {
+				output <- markEvent
+				continue
+			}
+
+			// Switch language and voice until the stream starts; it can't change mid-stream
+			if languageEvent, ok := event.(core.LanguageDetectedEvent); ok {
+				if hasSentStatus {
+					logger.Warn("Language detected after synthesis started, keeping current voice", telemetry.String("language", languageEvent.Language))
+				} else {
+					language, voice = s.voiceFor(languageEvent.Language)
+				}
+				output <- languageEvent
+				continue
+			}
+
 			if llmEvent, ok := event.(core.LLMEvent); ok {
 				if strings.TrimSpace(llmEvent.Delta) == "" {
 					continue
 				}
 
 				// Initialize stream on first text chunk
+				firstText.CompareAndSwap(0, time.Now().UnixNano())
 				if !initStream() {
 					return
 				}
@@ -244,10 +320,21 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 				select {
 				case <-ctx.Done():
 					return
-				case textChan <- llmEvent.Delta:
+				case textChan <- s.prepareText(llmEvent.Delta):
 				}
 			}
 
+			// On barge-in, cancel synthesis and unblock the stream goroutines
+			if interrupt, ok := event.(core.InterruptEvent); ok {
+				logger.Info("TTS interrupted", telemetry.String("reason", interrupt.Reason))
+				close(interrupted)
+				cancelStream()
+				streamOnce.Do(func() {
+					close(streamReady)
+				})
+				return
+			}
+
 			// If we receive a DoneEvent, signal end of text and stop processing
 			if _, ok := event.(core.DoneEvent); ok {
 				logger.Info("Received DoneEvent, signaling end of text to TTS provider")
@@ -266,19 +353,20 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 		case <-ctx.Done():
 			return ctx.Err()
 
+		case <-interrupted:
+			// Queued audio is dropped; the sender and receiver exit on the cancelled stream
+			output <- core.DoneEvent{Interrupted: true}
+			return nil
+
 		case err := <-errChan:
+			if isInterrupted(interrupted) {
+				continue
+			}
 			if err != nil {
 				logger.Error("TTS error", telemetry.Err(err))
 
 				// Emit user-friendly service message
-				output <- core.ServiceMessageEvent{
-					MessageType: core.ServiceMessageWarning,
-					Content:     "I'm having trouble with my voice right now, but I can still chat via text.",
-					Localized: map[string]string{
-						"en": "I'm having trouble with my voice right now, but I can still chat via text.",
-						"ru": "У меня возникли проблемы с голосом, но я всё ещё могу общаться текстом.",
-					},
-				}
+				output <- voiceUnavailableMessage()
 
 				// Still emit DoneEvent to signal end of participation
 				output <- core.DoneEvent{}
@@ -292,6 +380,11 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 				// Audio channel closed, wait for all goroutines
 				wg.Wait()
 
+				if isInterrupted(interrupted) {
+					output <- core.DoneEvent{Interrupted: true}
+					return nil
+				}
+
 				// Check for any errors
 				select {
 				case err := <-errChan:
@@ -299,14 +392,7 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 						logger.Error("TTS error during cleanup", telemetry.Err(err))
 
 						// Emit user-friendly service message
-						output <- core.ServiceMessageEvent{
-							MessageType: core.ServiceMessageWarning,
-							Content:     "I'm having trouble with my voice right now, but I can still chat via text.",
-							Localized: map[string]string{
-								"en": "I'm having trouble with my voice right now, but I can still chat via text.",
-								"ru": "У меня возникли проблемы с голосом, но я всё ещё могу общаться текстом.",
-							},
-						}
+						output <- voiceUnavailableMessage()
 
 						// Still emit DoneEvent to signal end
 						output <- core.DoneEvent{}
@@ -315,17 +401,70 @@ func (s *TTSStage) Process(ctx context.Context, input <-chan core.Event, output
 				default:
 				}
 
+				if chars := characters.Load(); chars > 0 {
+					output <- core.UsageEvent{
+						Provider:   providerName,
+						Characters: int(chars),
+					}
+				}
+
 				// Emit done event (no service message for empty content - it's handled upstream)
 				logger.Info("Emitting done event")
 				output <- core.DoneEvent{
 					AudioDuration: 0,
+					Provider:      providerName,
 				}
 				return nil
 			}
 
-			if audioEvent, ok := event.(core.AudioEvent); ok {
+			if audioEvent, ok := event.(core.AudioEvent); ok && !isInterrupted(interrupted) {
+				if audioEvent.SeqNum == 1 {
+					output <- core.LatencyMarkEvent{
+						Mark:    core.LatencyTTSFirstAudio,
+						Elapsed: time.Since(time.Unix(0, firstText.Load())),
+					}
+				}
 				output <- audioEvent
 			}
 		}
 	}
 }
+
+// requestOptions returns the provider options for the stream request
+func (s *TTSStage) requestOptions() map[string]any {
+	if s.config.SSML == nil {
+		return nil
+	}
+	return map[string]any{"ssml": true}
+}
+
+// prepareText converts text to the format the provider accepts: plain text is
+// rendered as SSML when SSML is enabled, and SSML from upstream is stripped
+// back to plain text when it is not
+func (s *TTSStage) prepareText(text string) string {
+	if s.config.SSML == nil {
+		if isSSML(text) {
+			return ssmlToText(text)
+		}
+		return text
+	}
+	if isSSML(text) {
+		return text
+	}
+	return toSSML(text, *s.config.SSML)
+}
+
+// voiceUnavailableMessage returns the user-facing message emitted instead of
+// a raw error when synthesis fails
+func voiceUnavailableMessage() core.ServiceMessageEvent {
+	return i18n.NewMessage(core.ServiceMessageWarning, i18n.MsgVoiceUnavailable, nil)
+}
+
+// voiceFor returns the language and voice to synthesize a detected language
+// with, keeping the configured voice when none is set for the language
+func (s *TTSStage) voiceFor(detected string) (string, string) {
+	if voice, ok := localized(s.config.Voices, detected); ok {
+		return detected, voice
+	}
+	return detected, s.config.Voice
+}
@@ -0,0 +1,256 @@
+package stages
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+// defaultTTSCacheSize is the number of phrases an LRU cache holds by default
+const defaultTTSCacheSize = 256
+
+// TTSCacheKey identifies synthesized audio for a phrase. A cache should only
+// be shared between stages using the same provider, encoding and speed.
+type TTSCacheKey struct {
+	Voice    string
+	Language string
+	Text     string // Normalized phrase, see normalizePhrase
+}
+
+// TTSCache stores synthesized audio for repeated phrases
+type TTSCache interface {
+	Get(key TTSCacheKey) ([]byte, bool)
+	Put(key TTSCacheKey, audio []byte)
+}
+
+// LRUTTSCache is an in-memory TTSCache that evicts the least recently used
+// phrase when full. It is safe for concurrent use.
+type LRUTTSCache struct {
+	capacity int
+	mu       sync.Mutex
+	order    *list.List // Front is most recently used
+	entries  map[TTSCacheKey]*list.Element
+}
+
+type lruTTSEntry struct {
+	key   TTSCacheKey
+	audio []byte
+}
+
+// NewLRUTTSCache creates an in-memory cache holding up to capacity phrases
+func NewLRUTTSCache(capacity int) *LRUTTSCache {
+	if capacity <= 0 {
+		capacity = defaultTTSCacheSize
+	}
+	return &LRUTTSCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[TTSCacheKey]*list.Element),
+	}
+}
+
+// Get implements TTSCache
+func (c *LRUTTSCache) Get(key TTSCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruTTSEntry).audio, true
+}
+
+// Put implements TTSCache
+func (c *LRUTTSCache) Put(key TTSCacheKey, audio []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruTTSEntry).audio = audio
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruTTSEntry{key: key, audio: audio})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruTTSEntry).key)
+	}
+}
+
+// Len returns the number of cached phrases
+func (c *LRUTTSCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// normalizePhrase folds case and whitespace so trivially different
+// renderings of a phrase share a cache entry
+func normalizePhrase(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// processCached synthesizes each sentence separately so its audio can be
+// cached and replayed in order. Cache hits skip the provider entirely; misses
+// use one-shot synthesis, trading the streaming latency of long answers for
+// instant repeated phrases.
+func (s *TTSStage) processCached(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	synthCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	interrupted := make(chan struct{})
+	textChan := make(chan TTSCacheKey, 100) // Phrases with the voice to speak them in
+
+	// Goroutine: Collect sentences until DoneEvent, watching for barge-in
+	go func() {
+		defer close(textChan)
+
+		language, voice := s.config.Language, s.config.Voice
+		for event := range input {
+			switch e := event.(type) {
+			case core.UsageEvent, core.LatencyMarkEvent:
+				// Upstream usage and latency are for the aggregators downstream
+				output <- e
+
+			case core.LanguageDetectedEvent:
+				language, voice = s.voiceFor(e.Language)
+				output <- e
+
+			case core.LLMEvent:
+				if strings.TrimSpace(e.Delta) == "" {
+					continue
+				}
+				select {
+				case <-synthCtx.Done():
+					return
+				case textChan <- TTSCacheKey{Voice: voice, Language: language, Text: e.Delta}:
+				}
+
+			case core.InterruptEvent:
+				logger.Info("TTS interrupted", telemetry.String("reason", e.Reason))
+				close(interrupted)
+				cancel()
+				return
+
+			case core.DoneEvent:
+				logger.Info("Received DoneEvent, finishing cached synthesis")
+				return
+			}
+		}
+	}()
+
+	chain := providerChain(s.config.Provider, s.config.Fallbacks)
+	providerName := s.config.Provider.Name()
+	var characters int
+	var seqNum uint64
+	hasSentStatus := false
+	var firstText time.Time
+
+	for phrase := range textChan {
+		if isInterrupted(interrupted) {
+			break
+		}
+		if !hasSentStatus {
+			firstText = time.Now()
+			output <- core.StatusEvent{
+				Status:  core.StatusSpeaking,
+				Target:  core.StatusTargetBot,
+				Message: "Generating voice...",
+			}
+			hasSentStatus = true
+		}
+
+		text := s.prepareText(phrase.Text)
+		key := TTSCacheKey{
+			Voice:    phrase.Voice,
+			Language: phrase.Language,
+			Text:     normalizePhrase(text),
+		}
+
+		audio, ok := s.config.Cache.Get(key)
+		if ok {
+			logger.Debug("TTS cache hit", telemetry.String("text", text))
+		} else {
+			synthesize, release := limitStart(s.config.Limiter, "", func(ctx context.Context, provider providers.TTSProvider) (*providers.TTSResponse, error) {
+				return provider.Synthesize(ctx, providers.TTSRequest{
+					Text:     text,
+					Voice:    key.Voice,
+					Language: key.Language,
+					Speed:    s.config.Speed,
+					Options:  s.requestOptions(),
+				})
+			})
+			resp, served, err := withFallback(synthCtx, chain, s.config.Retry, logger, synthesize)
+			release(resp)
+			if isInterrupted(interrupted) {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				logger.Error("TTS synthesis failed", telemetry.Err(err), telemetry.String("provider", chain[served].Name()))
+				cancel()
+				output <- voiceUnavailableMessage()
+				output <- core.DoneEvent{}
+				return nil
+			}
+
+			providerName = chain[served].Name()
+			characters += utf8.RuneCountInString(text)
+			if resp == nil || len(resp.Audio) == 0 {
+				continue
+			}
+			audio = resp.Audio
+			s.config.Cache.Put(key, audio)
+		}
+
+		seqNum++
+		if seqNum == 1 {
+			output <- core.LatencyMarkEvent{
+				Mark:    core.LatencyTTSFirstAudio,
+				Elapsed: time.Since(firstText),
+			}
+		}
+		output <- core.AudioEvent{
+			Data:       audio,
+			Format:     s.config.Encoding,
+			SampleRate: s.config.SampleRate,
+			Channels:   s.config.Channels,
+			SeqNum:     seqNum,
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if isInterrupted(interrupted) {
+		output <- core.DoneEvent{Interrupted: true}
+		return nil
+	}
+
+	if characters > 0 {
+		output <- core.UsageEvent{
+			Provider:   providerName,
+			Characters: characters,
+		}
+	}
+
+	logger.Info("Emitting done event")
+	output <- core.DoneEvent{
+		Provider: providerName,
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	providers "github.com/creastat/providers/core"
+)
+
+func TestLRUTTSCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUTTSCache(2)
+	hello := TTSCacheKey{Voice: "v", Text: "hello."}
+	bye := TTSCacheKey{Voice: "v", Text: "bye."}
+	thanks := TTSCacheKey{Voice: "v", Text: "thanks."}
+
+	cache.Put(hello, []byte("hello"))
+	cache.Put(bye, []byte("bye"))
+	cache.Get(hello) // hello is now more recent than bye
+	cache.Put(thanks, []byte("thanks"))
+
+	if _, ok := cache.Get(bye); ok {
+		t.Error("expected least recently used phrase to be evicted")
+	}
+	if audio, ok := cache.Get(hello); !ok || string(audio) != "hello" {
+		t.Errorf("expected hello to stay cached, got %q, %v", audio, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached phrases, got %d", cache.Len())
+	}
+}
+
+func TestTTSStageServesRepeatedPhrasesFromCache(t *testing.T) {
+	provider := &TestOneShotTTSProvider{}
+	stage := NewTTSStage(TTSStageConfig{
+		Provider: provider,
+		Voice:    "alloy",
+		Cache:    NewLRUTTSCache(0),
+	})
+
+	for turn := 0; turn < 2; turn++ {
+		input := make(chan core.Event, 4)
+		output := make(chan core.Event, 20)
+		input <- core.LLMEvent{Delta: "Hello there!"}
+		input <- core.LLMEvent{Delta: "How can I help?"}
+		input <- core.DoneEvent{}
+		close(input)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := stage.Process(ctx, input, output)
+		cancel()
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		close(output)
+
+		var audio []string
+		var done bool
+		for event := range output {
+			switch e := event.(type) {
+			case core.AudioEvent:
+				audio = append(audio, string(e.Data))
+			case core.DoneEvent:
+				done = true
+			}
+		}
+		if len(audio) != 2 || audio[0] != "Hello there!" || audio[1] != "How can I help?" {
+			t.Errorf("turn %d: unexpected audio %q", turn, audio)
+		}
+		if !done {
+			t.Errorf("turn %d: missing DoneEvent", turn)
+		}
+	}
+
+	if provider.calls != 2 {
+		t.Errorf("expected the provider to synthesize each phrase once, got %d calls", provider.calls)
+	}
+}
+
+// TestOneShotTTSProvider synthesizes the request text as its own audio
+type TestOneShotTTSProvider struct {
+	TestStreamingTTSProvider
+	calls int
+}
+
+func (m *TestOneShotTTSProvider) Synthesize(ctx context.Context, req providers.TTSRequest) (*providers.TTSResponse, error) {
+	m.calls++
+	return &providers.TTSResponse{Audio: []byte(req.Text)}, nil
+}
@@ -0,0 +1,206 @@
+package stages
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+)
+
+const (
+	// DefaultTurnSilenceTimeout is how long the user must stay silent after
+	// a final transcript for the turn to end
+	DefaultTurnSilenceTimeout = 800 * time.Millisecond
+
+	// DefaultTurnCompleteTimeout is the shorter silence that ends the turn
+	// when the transcript looks like a complete utterance
+	DefaultTurnCompleteTimeout = 300 * time.Millisecond
+)
+
+// TurnDetectorConfig holds configuration for TurnDetectorStage
+type TurnDetectorConfig struct {
+	// SilenceTimeout ends the turn when no transcript arrives for this long
+	// after a final one (default: DefaultTurnSilenceTimeout)
+	SilenceTimeout time.Duration
+
+	// CompleteTimeout replaces SilenceTimeout when IsComplete reports that
+	// the transcript so far is a complete utterance (default:
+	// DefaultTurnCompleteTimeout)
+	CompleteTimeout time.Duration
+
+	// IsComplete is the semantic end-of-utterance heuristic. It receives
+	// the turn's final transcripts so far. The default reports text ending
+	// in sentence punctuation.
+	IsComplete func(text string) bool
+
+	// ProviderEndpointing ends the turn at once on a final STTEvent with
+	// EndOfUtterance set, trusting the STT provider's endpointing. The turn
+	// ends after the transcript's LLMEvent, or after CompleteTimeout if the
+	// LLMEvents aren't routed to the detector.
+	ProviderEndpointing bool
+
+	// OnTurnEnd is called with the turn's transcript when the detector ends
+	// the turn, e.g. to stop reading audio from the client
+	OnTurnEnd func(text string)
+
+	Logger telemetry.Logger
+}
+
+// TurnDetectorStage decides when the user's turn is over instead of relying
+// on the client to close the audio stream. It sits between the STT stage and
+// the LLM stage, passing events through, and ends the turn with a DoneEvent
+// once the user stops speaking: after SilenceTimeout without transcripts,
+// sooner when the transcript looks complete, or at once when the provider
+// reports the end of the utterance. Interim transcripts count as speech and
+// restart the wait.
+//
+// Transcripts and the STT stage's DoneEvent arriving after the turn ended are
+// dropped; other events still pass through.
+type TurnDetectorStage struct {
+	config TurnDetectorConfig
+}
+
+// NewTurnDetectorStage creates a new turn detector stage
+func NewTurnDetectorStage(config TurnDetectorConfig) *TurnDetectorStage {
+	if config.SilenceTimeout <= 0 {
+		config.SilenceTimeout = DefaultTurnSilenceTimeout
+	}
+	if config.CompleteTimeout <= 0 {
+		config.CompleteTimeout = DefaultTurnCompleteTimeout
+	}
+	if config.IsComplete == nil {
+		config.IsComplete = endsSentence
+	}
+	return &TurnDetectorStage{config: config}
+}
+
+// endsSentence reports whether text ends in sentence punctuation
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"')]`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!") ||
+		strings.HasSuffix(text, "。") || strings.HasSuffix(text, "？") || strings.HasSuffix(text, "！")
+}
+
+// Name returns the stage name
+func (s *TurnDetectorStage) Name() string {
+	return "turn_detector"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *TurnDetectorStage) InputTypes() []core.EventType {
+	// Turn detector passes all event types through
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *TurnDetectorStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *TurnDetectorStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+
+	var finals []string // Final transcripts of the turn
+	ended := false
+	endpointed := false // The provider detected the end of the utterance
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	send := func(event core.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+			return nil
+		}
+	}
+
+	// wait restarts the silence wait for the turn's transcript so far
+	wait := func() {
+		timer.Stop()
+		select {
+		case <-timer.C:
+		default:
+		}
+		if len(finals) == 0 {
+			return
+		}
+		timeout := s.config.SilenceTimeout
+		if endpointed || s.config.IsComplete(strings.Join(finals, " ")) {
+			timeout = s.config.CompleteTimeout
+		}
+		timer.Reset(timeout)
+	}
+
+	endTurn := func(reason string) error {
+		timer.Stop()
+		ended = true
+		text := strings.Join(finals, " ")
+		logger.Info("End of turn detected", telemetry.String("reason", reason), telemetry.String("text", text))
+		if s.config.OnTurnEnd != nil {
+			s.config.OnTurnEnd(text)
+		}
+		return send(core.DoneEvent{})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-timer.C:
+			if ended {
+				continue
+			}
+			if err := endTurn("silence"); err != nil {
+				return err
+			}
+
+		case event, ok := <-input:
+			if !ok {
+				return nil
+			}
+
+			if ended {
+				switch event.(type) {
+				case core.STTEvent, core.LLMEvent, core.DoneEvent:
+					continue
+				}
+				if err := send(event); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := send(event); err != nil {
+				return err
+			}
+			switch e := event.(type) {
+			case core.STTEvent:
+				if e.IsFinal {
+					finals = append(finals, e.Text)
+				}
+				endpointed = e.IsFinal && e.EndOfUtterance && s.config.ProviderEndpointing
+				wait()
+			case core.LLMEvent:
+				// STTStage follows each final transcript with its LLMEvent,
+				// which must reach the LLM before the turn ends
+				if endpointed {
+					if err := endTurn("endpointing"); err != nil {
+						return err
+					}
+				}
+			case core.DoneEvent:
+				// The audio stream ended before the user went silent
+				timer.Stop()
+				ended = true
+			}
+		}
+	}
+}
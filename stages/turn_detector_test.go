@@ -0,0 +1,97 @@
+package stages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestTurnDetectorStage tests that the turn ends after silence, sooner for
+// complete utterances, and at once on provider endpointing
+func TestTurnDetectorStage(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TurnDetectorConfig
+		final    core.STTEvent
+		maxDelay time.Duration
+	}{
+		{
+			name:     "silence",
+			config:   TurnDetectorConfig{SilenceTimeout: 100 * time.Millisecond, CompleteTimeout: time.Hour},
+			final:    core.STTEvent{Text: "what are your", IsFinal: true},
+			maxDelay: time.Second,
+		},
+		{
+			name:     "complete utterance",
+			config:   TurnDetectorConfig{SilenceTimeout: time.Hour, CompleteTimeout: 50 * time.Millisecond},
+			final:    core.STTEvent{Text: "What are your hours?", IsFinal: true},
+			maxDelay: time.Second,
+		},
+		{
+			name:     "provider endpointing",
+			config:   TurnDetectorConfig{SilenceTimeout: time.Hour, CompleteTimeout: time.Hour, ProviderEndpointing: true},
+			final:    core.STTEvent{Text: "what are your hours", IsFinal: true, EndOfUtterance: true},
+			maxDelay: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ended string
+			tt.config.OnTurnEnd = func(text string) { ended = text }
+			tt.config.Logger = testLogger()
+			stage := NewTurnDetectorStage(tt.config)
+
+			input := make(chan core.Event, 10)
+			output := make(chan core.Event, 10)
+			errChan := make(chan error, 1)
+			go func() {
+				defer close(output)
+				errChan <- stage.Process(context.Background(), input, output)
+			}()
+
+			input <- core.STTEvent{Text: "what are"}
+			input <- tt.final
+			input <- core.LLMEvent{Delta: tt.final.Text, Content: tt.final.Text}
+
+			var events []core.Event
+			deadline := time.After(tt.maxDelay)
+		Turn:
+			for {
+				select {
+				case event := <-output:
+					events = append(events, event)
+					if event.EventType() == core.EventTypeDone {
+						break Turn
+					}
+				case <-deadline:
+					t.Fatalf("turn did not end, got %v", events)
+				}
+			}
+			if len(events) != 4 {
+				t.Fatalf("expected the transcripts and a DoneEvent, got %v", events)
+			}
+			if ended != tt.final.Text {
+				t.Errorf("expected OnTurnEnd with %q, got %q", tt.final.Text, ended)
+			}
+
+			// Speech after the turn ended is dropped, other events pass
+			input <- core.STTEvent{Text: "and", IsFinal: true}
+			input <- core.UsageEvent{Provider: "stt"}
+			input <- core.DoneEvent{}
+			close(input)
+			var late []core.Event
+			for event := range output {
+				late = append(late, event)
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+			if len(late) != 1 || late[0].EventType() != core.EventTypeUsage {
+				t.Errorf("expected only the usage event after the turn, got %v", late)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package stages
+
+import (
+	"context"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/usage"
+)
+
+// UsageStageConfig holds usage aggregator configuration
+type UsageStageConfig struct {
+	Prices       usage.PriceTable
+	ForwardUsage bool // Also pass UsageEvents downstream
+	Logger       telemetry.Logger
+}
+
+// UsageStage collects UsageEvents from upstream stages and attaches the
+// per-turn usage and cost summary to each DoneEvent it forwards.
+// All other events pass through unchanged.
+type UsageStage struct {
+	config UsageStageConfig
+}
+
+// NewUsageStage creates a new usage aggregator stage
+func NewUsageStage(config UsageStageConfig) *UsageStage {
+	return &UsageStage{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (s *UsageStage) Name() string {
+	return "usage"
+}
+
+// InputTypes returns the event types this stage accepts
+func (s *UsageStage) InputTypes() []core.EventType {
+	// Usage aggregator accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (s *UsageStage) OutputTypes() []core.EventType {
+	// Usage aggregator passes everything through
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (s *UsageStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := s.config.Logger.WithModule(s.Name())
+	accumulator := usage.NewAccumulator(s.config.Prices)
+
+	for event := range input {
+		switch e := event.(type) {
+		case core.UsageEvent:
+			accumulator.Add(e)
+			if !s.config.ForwardUsage {
+				continue
+			}
+
+		case core.DoneEvent:
+			summary := accumulator.Summary()
+			accumulator.Reset()
+			e.Usage = &summary
+			event = e
+			logger.Info("Turn usage",
+				telemetry.Int("input_tokens", summary.InputTokens),
+				telemetry.Int("output_tokens", summary.OutputTokens),
+				telemetry.Float64("audio_seconds", summary.AudioSeconds),
+				telemetry.Int("characters", summary.Characters),
+				telemetry.Float64("cost", summary.Cost))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package stages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/usage"
+)
+
+// TestUsageStageAttachesSummary tests that usage is consumed and summarized
+// on the DoneEvent of each turn
+func TestUsageStageAttachesSummary(t *testing.T) {
+	stage := NewUsageStage(UsageStageConfig{
+		Prices: usage.PriceTable{"llm": {InputPerMillionTokens: 1_000_000}},
+	})
+
+	input := make(chan core.Event, 10)
+	output := make(chan core.Event, 10)
+	input <- core.UsageEvent{Provider: "llm", InputTokens: 2}
+	input <- core.LLMEvent{Delta: "hi"}
+	input <- core.DoneEvent{FullText: "hi"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	close(output)
+
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events without UsageEvents, got %d", len(events))
+	}
+	first, ok := events[1].(core.DoneEvent)
+	if !ok || first.Usage == nil {
+		t.Fatalf("expected DoneEvent with usage, got %#v", events[1])
+	}
+	if first.Usage.InputTokens != 2 || first.Usage.Cost != 2 {
+		t.Errorf("unexpected usage summary: %+v", first.Usage)
+	}
+
+	// The next turn starts from zero
+	second := events[2].(core.DoneEvent)
+	if second.Usage == nil || second.Usage.Cost != 0 {
+		t.Errorf("expected empty usage for the next turn, got %+v", second.Usage)
+	}
+}
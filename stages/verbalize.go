@@ -0,0 +1,283 @@
+package stages
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Verbalizer rewrites text for speech, e.g. digits as words, so TTS voices
+// read it naturally. TextProcessorStage picks one by language.
+type Verbalizer interface {
+	Verbalize(text string) string
+}
+
+// Currency names a currency's major and minor units for speech
+type Currency struct {
+	Major, MajorPlural string // "dollar", "dollars"
+	Minor, MinorPlural string // "cent", "cents"
+}
+
+// Unit names a unit of measure for speech
+type Unit struct {
+	Singular, Plural string
+}
+
+// NumberLocale holds the words and formats NumberVerbalizer uses for a
+// language
+type NumberLocale struct {
+	Cardinal func(n int64) string // 21 -> "twenty-one"
+	Ordinal  func(n int64) string // 21 -> "twenty-first"
+
+	// OrdinalPattern matches ordinal numerals with the number as its first
+	// group, e.g. `(\d+)(?:st|nd|rd|th)\b`
+	OrdinalPattern string
+
+	Date func(year, month, day int) string        // ISO dates, "2024-03-05"
+	Time func(hour, minute int, pm string) string // pm is "am", "pm" or empty
+
+	DecimalSeparator string // "." in "4.50"
+	GroupSeparator   string // "," in "1,000"
+	Point            string // Word for the decimal separator
+	Minus            string
+	Percent          string
+	And              string // Joins a currency's major and minor units, may be empty
+
+	// Apocope shortens a number before a noun, e.g. Spanish "uno" to "un"
+	// in "un euro". Nil leaves numbers unchanged.
+	Apocope func(words string) string
+
+	Currencies map[string]Currency // By symbol, e.g. "$"
+	Units      map[string]Unit     // By abbreviation, e.g. "km"
+}
+
+// NumberVerbalizer spells out numbers, currencies, percentages, ordinals,
+// ISO dates, times and units of measure in a locale's words, e.g. "$4.50"
+// as "four dollars fifty cents"
+type NumberVerbalizer struct {
+	locale NumberLocale
+
+	date, clock, currency, percent, ordinal, unit, number *regexp.Regexp
+}
+
+// NewNumberVerbalizer creates a verbalizer for a locale
+func NewNumberVerbalizer(locale NumberLocale) *NumberVerbalizer {
+	num := fmt.Sprintf(`\d{1,3}(?:%[1]s\d{3})+(?:%[2]s\d+)?|\d+(?:%[2]s\d+)?`,
+		regexp.QuoteMeta(locale.GroupSeparator), regexp.QuoteMeta(locale.DecimalSeparator))
+
+	symbols := make([]string, 0, len(locale.Currencies))
+	for symbol := range locale.Currencies {
+		symbols = append(symbols, regexp.QuoteMeta(symbol))
+	}
+	units := make([]string, 0, len(locale.Units))
+	for unit := range locale.Units {
+		units = append(units, regexp.QuoteMeta(unit))
+	}
+	// Longer abbreviations first, so "km/h" wins over "km"
+	sort.Slice(units, func(i, j int) bool { return len(units[i]) > len(units[j]) })
+
+	v := &NumberVerbalizer{
+		locale:  locale,
+		date:    regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`),
+		clock:   regexp.MustCompile(`(?i)\b(\d{1,2}):(\d{2})(?:\s?([ap])\.?m\.?\b)?`),
+		percent: regexp.MustCompile(`(-?)(` + num + `)\s?%`),
+		ordinal: regexp.MustCompile(locale.OrdinalPattern),
+		number:  regexp.MustCompile(`(-?)(` + num + `)`),
+	}
+	if len(symbols) > 0 {
+		sym := strings.Join(symbols, "|")
+		v.currency = regexp.MustCompile(`(` + sym + `)\s?(` + num + `)|(` + num + `)\s?(` + sym + `)`)
+	}
+	if len(units) > 0 {
+		v.unit = regexp.MustCompile(`(-?)(` + num + `)\s?(` + strings.Join(units, "|") + `)\b`)
+	}
+	return v
+}
+
+// Verbalize implements Verbalizer
+func (v *NumberVerbalizer) Verbalize(text string) string {
+	l := v.locale
+
+	text = replaceMatches(v.date, text, func(m []string, _ bool) string {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 || l.Date == nil {
+			return m[0]
+		}
+		return l.Date(year, month, day)
+	})
+
+	text = replaceMatches(v.clock, text, func(m []string, _ bool) string {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 || l.Time == nil {
+			return m[0]
+		}
+		pm := ""
+		if m[3] != "" {
+			pm = strings.ToLower(m[3]) + "m"
+		}
+		return l.Time(hour, minute, pm)
+	})
+
+	if v.currency != nil {
+		text = replaceMatches(v.currency, text, func(m []string, _ bool) string {
+			symbol, amount := m[1], m[2]
+			if symbol == "" {
+				symbol, amount = m[4], m[3]
+			}
+			return v.money(l.Currencies[symbol], amount)
+		})
+	}
+
+	text = replaceMatches(v.percent, text, func(m []string, signed bool) string {
+		return v.signed(m[1], m[2], signed) + " " + l.Percent
+	})
+
+	text = replaceMatches(v.ordinal, text, func(m []string, _ bool) string {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		return l.Ordinal(n)
+	})
+
+	if v.unit != nil {
+		text = replaceMatches(v.unit, text, func(m []string, signed bool) string {
+			unit := l.Units[m[3]]
+			name := unit.Plural
+			if m[2] == "1" {
+				name = unit.Singular
+			}
+			return v.beforeNoun(v.signed(m[1], m[2], signed)) + " " + name
+		})
+	}
+
+	return replaceMatches(v.number, text, func(m []string, signed bool) string {
+		return v.signed(m[1], m[2], signed)
+	})
+}
+
+// replaceMatches replaces the matches of re in text with the result of
+// replace, which receives the match and its groups, and whether a leading
+// "-" is a sign rather than a hyphen: the match starts the text or follows a
+// space or parenthesis
+func replaceMatches(re *regexp.Regexp, text string, replace func(m []string, signed bool) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		signed := loc[0] == 0 || strings.ContainsRune(" \t\n(", rune(text[loc[0]-1]))
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(replace(m, signed))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// money spells an amount of a currency, "4.50" as "four dollars fifty cents"
+func (v *NumberVerbalizer) money(currency Currency, amount string) string {
+	whole, fraction, _ := strings.Cut(strings.ReplaceAll(amount, v.locale.GroupSeparator, ""), v.locale.DecimalSeparator)
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return v.spell(amount) + " " + currency.MajorPlural
+	}
+	var minor int64
+	if fraction != "" {
+		// Cents are the first two decimals, "4.5" is four fifty
+		fraction = (fraction + "0")[:2]
+		minor, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+
+	var words []string
+	if major > 0 || minor == 0 {
+		words = append(words, v.beforeNoun(v.locale.Cardinal(major))+" "+plural(major, currency.Major, currency.MajorPlural))
+	}
+	if minor > 0 {
+		if len(words) > 0 && v.locale.And != "" {
+			words = append(words, v.locale.And)
+		}
+		words = append(words, v.beforeNoun(v.locale.Cardinal(minor))+" "+plural(minor, currency.Minor, currency.MinorPlural))
+	}
+	return strings.Join(words, " ")
+}
+
+// signed spells a number with an optional minus sign, keeping a hyphen
+// that isn't one, as in "5-10"
+func (v *NumberVerbalizer) signed(minus, number string, signed bool) string {
+	switch {
+	case minus == "":
+		return v.spell(number)
+	case signed:
+		return v.locale.Minus + " " + v.spell(number)
+	default:
+		return minus + v.spell(number)
+	}
+}
+
+// beforeNoun applies the locale's Apocope, if any
+func (v *NumberVerbalizer) beforeNoun(words string) string {
+	if v.locale.Apocope == nil {
+		return words
+	}
+	return v.locale.Apocope(words)
+}
+
+// spell spells a number in the locale's format, reading decimals digit by
+// digit and numbers too long for an int64 as digits
+func (v *NumberVerbalizer) spell(number string) string {
+	whole, fraction, hasFraction := strings.Cut(strings.ReplaceAll(number, v.locale.GroupSeparator, ""), v.locale.DecimalSeparator)
+	words := v.digits(whole)
+	if n, err := strconv.ParseInt(whole, 10, 64); err == nil {
+		words = v.locale.Cardinal(n)
+	}
+	if hasFraction {
+		words += " " + v.locale.Point + " " + v.digits(fraction)
+	}
+	return words
+}
+
+// digits spells each digit of a number
+func (v *NumberVerbalizer) digits(number string) string {
+	words := make([]string, 0, len(number))
+	for _, digit := range number {
+		words = append(words, v.locale.Cardinal(int64(digit-'0')))
+	}
+	return strings.Join(words, " ")
+}
+
+// plural returns the singular word for one, the plural otherwise
+func plural(n int64, singular, pluralWord string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralWord
+}
+
+// builtinVerbalizers are the verbalizers TextProcessorStage uses unless
+// configured otherwise, by language
+var builtinVerbalizers = map[string]Verbalizer{
+	"en": NewNumberVerbalizer(EnglishNumbers),
+	"es": NewNumberVerbalizer(SpanishNumbers),
+}
+
+// verbalizerFor returns the verbalizer for a language: a configured one,
+// else a built-in one, else the English one
+func verbalizerFor(configured map[string]Verbalizer, language string) Verbalizer {
+	if verbalizer, ok := localized(configured, language); ok {
+		return verbalizer
+	}
+	if verbalizer, ok := localized(builtinVerbalizers, language); ok {
+		return verbalizer
+	}
+	return builtinVerbalizers["en"]
+}
@@ -0,0 +1,306 @@
+package stages
+
+import "strings"
+
+// EnglishNumbers verbalizes numbers in English
+var EnglishNumbers = NumberLocale{
+	Cardinal:         englishCardinal,
+	Ordinal:          englishOrdinal,
+	OrdinalPattern:   `\b(\d+)(?:st|nd|rd|th)\b`,
+	Date:             englishDate,
+	Time:             englishTime,
+	DecimalSeparator: ".",
+	GroupSeparator:   ",",
+	Point:            "point",
+	Minus:            "minus",
+	Percent:          "percent",
+	Currencies: map[string]Currency{
+		"$": {"dollar", "dollars", "cent", "cents"},
+		"€": {"euro", "euros", "cent", "cents"},
+		"£": {"pound", "pounds", "penny", "pence"},
+	},
+	Units: map[string]Unit{
+		"km":   {"kilometer", "kilometers"},
+		"m":    {"meter", "meters"},
+		"cm":   {"centimeter", "centimeters"},
+		"mm":   {"millimeter", "millimeters"},
+		"mi":   {"mile", "miles"},
+		"ft":   {"foot", "feet"},
+		"kg":   {"kilogram", "kilograms"},
+		"g":    {"gram", "grams"},
+		"lb":   {"pound", "pounds"},
+		"lbs":  {"pound", "pounds"},
+		"oz":   {"ounce", "ounces"},
+		"l":    {"liter", "liters"},
+		"ml":   {"milliliter", "milliliters"},
+		"mph":  {"mile per hour", "miles per hour"},
+		"km/h": {"kilometer per hour", "kilometers per hour"},
+		"°C":   {"degree Celsius", "degrees Celsius"},
+		"°F":   {"degree Fahrenheit", "degrees Fahrenheit"},
+		"KB":   {"kilobyte", "kilobytes"},
+		"MB":   {"megabyte", "megabytes"},
+		"GB":   {"gigabyte", "gigabytes"},
+		"TB":   {"terabyte", "terabytes"},
+		"min":  {"minute", "minutes"},
+		"h":    {"hour", "hours"},
+	},
+}
+
+var (
+	englishOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishScales = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+	englishMonths = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+)
+
+// englishCardinal spells a number, 1234 as "one thousand two hundred
+// thirty-four"
+func englishCardinal(n int64) string {
+	if n < 0 {
+		return "minus " + englishCardinal(-n)
+	}
+	if n < 20 {
+		return englishOnes[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return englishTens[n/10]
+		}
+		return englishTens[n/10] + "-" + englishOnes[n%10]
+	}
+	if n < 1000 {
+		words := englishOnes[n/100] + " hundred"
+		if n%100 != 0 {
+			words += " " + englishCardinal(n%100)
+		}
+		return words
+	}
+
+	var groups []string
+	for scale := 0; n > 0; scale++ {
+		if group := n % 1000; group != 0 {
+			words := englishCardinal(group)
+			if englishScales[scale] != "" {
+				words += " " + englishScales[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		n /= 1000
+	}
+	return strings.Join(groups, " ")
+}
+
+// englishOrdinal spells an ordinal number, 21 as "twenty-first"
+func englishOrdinal(n int64) string {
+	words := englishCardinal(n)
+	cut := strings.LastIndexAny(words, " -") + 1
+	last := words[cut:]
+	switch last {
+	case "one":
+		last = "first"
+	case "two":
+		last = "second"
+	case "three":
+		last = "third"
+	case "five":
+		last = "fifth"
+	case "eight":
+		last = "eighth"
+	case "nine":
+		last = "ninth"
+	case "twelve":
+		last = "twelfth"
+	default:
+		if strings.HasSuffix(last, "y") {
+			last = strings.TrimSuffix(last, "y") + "ieth"
+		} else {
+			last += "th"
+		}
+	}
+	return words[:cut] + last
+}
+
+// englishYear spells a year the way it is said, 1999 as "nineteen
+// ninety-nine" and 2005 as "two thousand five"
+func englishYear(year int) string {
+	century, rest := year/100, year%100
+	switch {
+	case year < 1000 || year%1000 < 10 && year >= 2000:
+		return englishCardinal(int64(year))
+	case rest == 0:
+		return englishCardinal(int64(century)) + " hundred"
+	case rest < 10:
+		return englishCardinal(int64(century)) + " oh " + englishCardinal(int64(rest))
+	default:
+		return englishCardinal(int64(century)) + " " + englishCardinal(int64(rest))
+	}
+}
+
+// englishDate spells a date, 2024-03-05 as "March fifth, twenty twenty-four"
+func englishDate(year, month, day int) string {
+	return englishMonths[month-1] + " " + englishOrdinal(int64(day)) + ", " + englishYear(year)
+}
+
+// englishTime spells a time, 3:05 pm as "three oh five p m"
+func englishTime(hour, minute int, pm string) string {
+	words := englishCardinal(int64(hour))
+	switch {
+	case minute == 0 && pm == "":
+		words += " o'clock"
+	case minute == 0:
+	case minute < 10:
+		words += " oh " + englishCardinal(int64(minute))
+	default:
+		words += " " + englishCardinal(int64(minute))
+	}
+	if pm != "" {
+		words += " " + pm[:1] + " m"
+	}
+	return words
+}
+
+// SpanishNumbers verbalizes numbers in Spanish
+var SpanishNumbers = NumberLocale{
+	Cardinal:         spanishCardinal,
+	Ordinal:          spanishOrdinal,
+	OrdinalPattern:   `\b(\d+)[ºª°]`,
+	Date:             spanishDate,
+	Time:             spanishTime,
+	DecimalSeparator: ",",
+	GroupSeparator:   ".",
+	Point:            "coma",
+	Minus:            "menos",
+	Percent:          "por ciento",
+	And:              "con",
+	Apocope:          spanishApocope,
+	Currencies: map[string]Currency{
+		"$": {"dólar", "dólares", "centavo", "centavos"},
+		"€": {"euro", "euros", "céntimo", "céntimos"},
+		"£": {"libra", "libras", "penique", "peniques"},
+	},
+	Units: map[string]Unit{
+		"km":   {"kilómetro", "kilómetros"},
+		"m":    {"metro", "metros"},
+		"cm":   {"centímetro", "centímetros"},
+		"mm":   {"milímetro", "milímetros"},
+		"kg":   {"kilo", "kilos"},
+		"g":    {"gramo", "gramos"},
+		"l":    {"litro", "litros"},
+		"ml":   {"mililitro", "mililitros"},
+		"km/h": {"kilómetro por hora", "kilómetros por hora"},
+		"°C":   {"grado centígrado", "grados centígrados"},
+		"GB":   {"gigabyte", "gigabytes"},
+		"MB":   {"megabyte", "megabytes"},
+		"min":  {"minuto", "minutos"},
+		"h":    {"hora", "horas"},
+	},
+}
+
+var (
+	spanishOnes = []string{"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve",
+		"diez", "once", "doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve",
+		"veinte", "veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve"}
+	spanishTens     = []string{"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa"}
+	spanishHundreds = []string{"", "ciento", "doscientos", "trescientos", "cuatrocientos", "quinientos",
+		"seiscientos", "setecientos", "ochocientos", "novecientos"}
+	spanishOrdinals = []string{"", "primero", "segundo", "tercero", "cuarto", "quinto",
+		"sexto", "séptimo", "octavo", "noveno", "décimo"}
+	spanishMonths = []string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+		"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+)
+
+// spanishCardinal spells a number, 1234 as "mil doscientos treinta y
+// cuatro"
+func spanishCardinal(n int64) string {
+	switch {
+	case n < 0:
+		return "menos " + spanishCardinal(-n)
+	case n < 30:
+		return spanishOnes[n]
+	case n < 100:
+		if n%10 == 0 {
+			return spanishTens[n/10]
+		}
+		return spanishTens[n/10] + " y " + spanishOnes[n%10]
+	case n == 100:
+		return "cien"
+	case n < 1000:
+		words := spanishHundreds[n/100]
+		if n%100 != 0 {
+			words += " " + spanishCardinal(n%100)
+		}
+		return words
+	case n < 1_000_000:
+		words := "mil"
+		if n/1000 > 1 {
+			words = spanishApocope(spanishCardinal(n/1000)) + " mil"
+		}
+		if n%1000 != 0 {
+			words += " " + spanishCardinal(n%1000)
+		}
+		return words
+	default:
+		millions := n / 1_000_000
+		words := "un millón"
+		if millions > 1 {
+			words = spanishApocope(spanishCardinal(millions)) + " millones"
+		}
+		if n%1_000_000 != 0 {
+			words += " " + spanishCardinal(n%1_000_000)
+		}
+		return words
+	}
+}
+
+// spanishApocope shortens a trailing "uno" before a noun, "veintiuno" to
+// "veintiún" in "veintiún mil"
+func spanishApocope(words string) string {
+	switch {
+	case strings.HasSuffix(words, "veintiuno"):
+		return strings.TrimSuffix(words, "uno") + "ún"
+	case strings.HasSuffix(words, "uno"):
+		return strings.TrimSuffix(words, "o")
+	}
+	return words
+}
+
+// spanishOrdinal spells an ordinal number up to ten, and larger ones as
+// cardinals the way they are usually said
+func spanishOrdinal(n int64) string {
+	if n > 0 && n < int64(len(spanishOrdinals)) {
+		return spanishOrdinals[n]
+	}
+	return spanishCardinal(n)
+}
+
+// spanishDate spells a date, 2024-03-05 as "cinco de marzo de dos mil
+// veinticuatro"
+func spanishDate(year, month, day int) string {
+	dayWords := spanishCardinal(int64(day))
+	if day == 1 {
+		dayWords = "primero"
+	}
+	return dayWords + " de " + spanishMonths[month-1] + " de " + spanishCardinal(int64(year))
+}
+
+// spanishTime spells a time, 3:30 as "tres y treinta"
+func spanishTime(hour, minute int, pm string) string {
+	words := spanishCardinal(int64(hour))
+	if hour == 1 {
+		words = "una"
+	}
+	if minute == 0 {
+		words += " en punto"
+	} else {
+		words += " y " + spanishCardinal(int64(minute))
+	}
+	switch pm {
+	case "am":
+		words += " de la mañana"
+	case "pm":
+		words += " de la tarde"
+	}
+	return words
+}
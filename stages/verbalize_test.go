@@ -0,0 +1,33 @@
+package stages
+
+import "testing"
+
+// TestNumberVerbalizer tests the built-in English and Spanish verbalizers
+func TestNumberVerbalizer(t *testing.T) {
+	tests := []struct {
+		language string
+		text     string
+		want     string
+	}{
+		{"en", "It costs $4.50 today.", "It costs four dollars fifty cents today."},
+		{"en", "$1 or $0.99", "one dollar or ninety-nine cents"},
+		{"en", "We have 1,234,567 users", "We have one million two hundred thirty-four thousand five hundred sixty-seven users"},
+		{"en", "Up 12.5%", "Up twelve point five percent"},
+		{"en", "Meet on 2024-03-05 at 3:05 pm.", "Meet on March fifth, twenty twenty-four at three oh five p m."},
+		{"en", "Opens at 9:00", "Opens at nine o'clock"},
+		{"en", "The 21st floor", "The twenty-first floor"},
+		{"en", "Run 1 km at -3 °C", "Run one kilometer at minus three degrees Celsius"},
+		{"en", "Pages 5-10", "Pages five-ten"},
+		{"en-US", "About 2 GB", "About two gigabytes"},
+		{"es", "Cuesta 4,50 €.", "Cuesta cuatro euros con cincuenta céntimos."},
+		{"es", "Somos 21.000 personas", "Somos veintiún mil personas"},
+		{"es", "El 2024-03-01 a las 3:30", "El primero de marzo de dos mil veinticuatro a las tres y treinta"},
+		{"es", "Son 100 km y 1 km", "Son cien kilómetros y un kilómetro"},
+	}
+
+	for _, tt := range tests {
+		if got := verbalizerFor(nil, tt.language).Verbalize(tt.text); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.language, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+// Webhook request headers
+const (
+	WebhookSignatureHeader = "X-Pipeline-Signature" // "sha256=" and the hex HMAC of "<timestamp>.<body>"
+	WebhookTimestampHeader = "X-Pipeline-Timestamp" // Unix seconds when the request was signed
+)
+
+// WebhookSinkConfig holds webhook sink configuration
+type WebhookSinkConfig struct {
+	// URLs receive every selected event as a JSON protocol OutputMessage
+	URLs []string
+
+	// Events selects the event types to deliver. Defaults to done, action,
+	// error and service_message.
+	Events []core.EventType
+
+	// Secret signs each request with HMAC-SHA256, see
+	// VerifyWebhookSignature. Empty sends unsigned requests.
+	Secret string
+
+	// Headers are added to every request, e.g. for authentication
+	Headers map[string]string
+
+	// Retry retries failed deliveries (default: DefaultRetryPolicy). 408,
+	// 429 and 5xx responses and network errors are retried.
+	Retry *RetryPolicy
+
+	// QueueSize bounds events waiting for delivery (default: 64). Events
+	// arriving at a full queue are dropped, so slow receivers never stall
+	// the pipeline.
+	QueueSize int
+
+	Client     *http.Client // Defaults to a client with a 10s timeout
+	SessionID  string
+	ResponseID string // ID for events without a turn, see core.TurnContext
+	Logger     telemetry.Logger
+}
+
+// WebhookSink POSTs selected pipeline events to webhook URLs so external
+// systems can react to pipeline outcomes without a WebSocket client.
+// Deliveries run in the background in event order; when the input closes,
+// the sink waits for queued deliveries until its context is cancelled.
+type WebhookSink struct {
+	config WebhookSinkConfig
+	events map[core.EventType]bool
+}
+
+// NewWebhookSink creates a new webhook sink stage
+func NewWebhookSink(config WebhookSinkConfig) *WebhookSink {
+	if len(config.Events) == 0 {
+		config.Events = []core.EventType{
+			core.EventTypeDone,
+			core.EventTypeAction,
+			core.EventTypeError,
+			core.EventTypeServiceMessage,
+		}
+	}
+	if config.Retry == nil {
+		config.Retry = DefaultRetryPolicy()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 64
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	events := make(map[core.EventType]bool, len(config.Events))
+	for _, eventType := range config.Events {
+		events[eventType] = true
+	}
+	return &WebhookSink{
+		config: config,
+		events: events,
+	}
+}
+
+// Name returns the stage name
+func (ws *WebhookSink) Name() string {
+	return "webhook_sink"
+}
+
+// InputTypes returns the input event types this stage accepts
+func (ws *WebhookSink) InputTypes() []core.EventType {
+	// Webhook sink accepts all event types, delivering only selected ones
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (ws *WebhookSink) OutputTypes() []core.EventType {
+	// Webhook sink is a terminal stage
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (ws *WebhookSink) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := ws.config.Logger.WithModule(ws.Name())
+
+	queue := make(chan []byte, ws.config.QueueSize)
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		for body := range queue {
+			ws.deliver(ctx, logger, body)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(queue)
+			return ctx.Err()
+
+		case event, ok := <-input:
+			if !ok {
+				close(queue)
+				select {
+				case <-delivered:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if !ws.events[event.EventType()] {
+				continue
+			}
+			msg := protocol.EventToMessage(event, ws.config.SessionID, responseIDOf(event, ws.config.ResponseID))
+			if msg == nil {
+				continue
+			}
+			body, err := json.Marshal(msg)
+			if err != nil {
+				logger.Warn("Failed to encode webhook event", telemetry.Err(err), telemetry.String("type", string(msg.Type)))
+				continue
+			}
+
+			select {
+			case queue <- body:
+			default:
+				logger.Warn("Webhook queue full, dropping event", telemetry.String("type", string(msg.Type)), telemetry.String("session_id", ws.config.SessionID))
+			}
+		}
+	}
+}
+
+// deliver sends one event to every URL, retrying each under the policy
+func (ws *WebhookSink) deliver(ctx context.Context, logger telemetry.Logger, body []byte) {
+	for _, url := range ws.config.URLs {
+		err := ws.post(ctx, url, body)
+		for attempt := 2; err != nil && attempt <= ws.config.Retry.MaxAttempts && ws.config.Retry.retryable(err); attempt++ {
+			timer := time.NewTimer(ws.config.Retry.Backoff(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			err = ws.post(ctx, url, body)
+		}
+		if err != nil {
+			logger.Error("Webhook delivery failed", telemetry.Err(err), telemetry.String("url", url), telemetry.String("session_id", ws.config.SessionID))
+		}
+	}
+}
+
+// post makes a single signed delivery attempt
+func (ws *WebhookSink) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range ws.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if ws.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, signWebhook(ws.config.Secret, timestamp, body))
+	}
+
+	resp, err := ws.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return webhookStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// webhookStatusError reports a non-2xx response, classified as retryable
+// by IsRetryableError through StatusCode
+type webhookStatusError struct {
+	code int
+}
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.code)
+}
+
+func (e webhookStatusError) StatusCode() int {
+	return e.code
+}
+
+// signWebhook returns the signature header value for a request body
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature, the
+// X-Pipeline-Signature header, matches body and the X-Pipeline-Timestamp
+// header for secret. Receivers should also reject stale timestamps to
+// prevent replays.
+func VerifyWebhookSignature(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signWebhook(secret, timestamp, body)), []byte(signature))
+}
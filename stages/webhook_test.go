@@ -0,0 +1,97 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+)
+
+func TestWebhookSink_DeliversSignedEvents(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	var received []protocol.OutputMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature("s3cret", r.Header.Get(WebhookTimestampHeader), body, r.Header.Get(WebhookSignatureHeader)) {
+			t.Error("invalid webhook signature")
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("missing configured header")
+		}
+		var msg protocol.OutputMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received = append(received, msg)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(WebhookSinkConfig{
+		URLs:      []string{server.URL},
+		Secret:    "s3cret",
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		Retry:     &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		SessionID: "s1",
+		Logger:    testLogger(),
+	})
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "Hi"} // Not selected
+	input <- core.ActionEvent{ActionID: "a1"}
+	input <- core.DoneEvent{FullText: "Hi"}
+	close(input)
+
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts including a retry, got %d", attempts)
+	}
+	if len(received) != 2 || received[0].Type != protocol.OutputActionRequest || received[1].Type != protocol.OutputResponseEnd {
+		t.Errorf("unexpected deliveries %+v", received)
+	}
+}
+
+func TestWebhookSink_DoesNotRetryClientErrors(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(WebhookSinkConfig{
+		URLs:   []string{server.URL},
+		Retry:  &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		Logger: testLogger(),
+	})
+
+	input := make(chan core.Event, 1)
+	input <- core.DoneEvent{}
+	close(input)
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected a single attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,94 @@
+package stages
+
+import (
+	"context"
+	"sync"
+)
+
+// AckWindow limits the audio bytes sent to a client but not yet acknowledged
+// with output.ack. Share one window between the WebSocketSource, which
+// receives the acks, and the WebSocketSink, which waits for room before
+// sending audio.
+type AckWindow struct {
+	mu       sync.Mutex
+	maxBytes int
+	inFlight int
+	pending  []ackEntry
+	changed  chan struct{}
+}
+
+type ackEntry struct {
+	id   string
+	size int
+}
+
+// NewAckWindow creates a window allowing up to maxBytes of unacknowledged
+// audio. A single chunk larger than maxBytes is still sent once nothing is
+// in flight.
+func NewAckWindow(maxBytes int) *AckWindow {
+	return &AckWindow{
+		maxBytes: maxBytes,
+		changed:  make(chan struct{}),
+	}
+}
+
+// Acquire waits until size more bytes fit in the window and records them as
+// in flight under the message ID
+func (w *AckWindow) Acquire(ctx context.Context, id string, size int) error {
+	for {
+		w.mu.Lock()
+		if w.inFlight == 0 || w.inFlight+size <= w.maxBytes {
+			w.inFlight += size
+			w.pending = append(w.pending, ackEntry{id: id, size: size})
+			w.mu.Unlock()
+			return nil
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Ack releases the message with the given ID and every message acquired
+// before it. Unknown IDs, e.g. of non-audio messages, are ignored.
+func (w *AckWindow) Ack(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, entry := range w.pending {
+		if entry.id != id {
+			continue
+		}
+		for _, acked := range w.pending[:i+1] {
+			w.inFlight -= acked.size
+		}
+		w.pending = w.pending[i+1:]
+		close(w.changed)
+		w.changed = make(chan struct{})
+		return
+	}
+}
+
+// Reset releases everything in flight, e.g. when the client reconnects and
+// will never ack what the old connection sent
+func (w *AckWindow) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.inFlight = 0
+	w.pending = nil
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// InFlight returns the unacknowledged audio bytes
+func (w *AckWindow) InFlight() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.inFlight
+}
@@ -0,0 +1,139 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+	"github.com/gorilla/websocket"
+)
+
+func TestAckWindow(t *testing.T) {
+	w := NewAckWindow(10)
+	ctx := context.Background()
+
+	if err := w.Acquire(ctx, "m1", 6); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := w.Acquire(ctx, "m2", 4); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// The window is full until an ack frees room
+	acquired := make(chan error, 1)
+	go func() { acquired <- w.Acquire(ctx, "m3", 5) }()
+	select {
+	case <-acquired:
+		t.Fatal("expected Acquire to wait for an ack")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	w.Ack("unknown")
+	w.Ack("m1")
+	if err := <-acquired; err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if w.InFlight() != 9 {
+		t.Errorf("expected 9 bytes in flight, got %d", w.InFlight())
+	}
+
+	// Acks are cumulative
+	w.Ack("m3")
+	if w.InFlight() != 0 {
+		t.Errorf("expected nothing in flight, got %d", w.InFlight())
+	}
+
+	// Oversized chunks still go out when nothing is in flight
+	if err := w.Acquire(ctx, "m4", 50); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := w.Acquire(cancelled, "m5", 1); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	w.Reset()
+	if w.InFlight() != 0 {
+		t.Errorf("expected Reset to clear the window, got %d", w.InFlight())
+	}
+}
+
+// TestWebSocketFlowControl tests that the sink holds audio back until the
+// client acks, with the acks arriving through the source
+func TestWebSocketFlowControl(t *testing.T) {
+	received := make(chan protocol.OutputMessage, 8)
+	acks := make(chan string, 1)
+	conn := dialPeer(t, func(c *websocket.Conn) {
+		go func() {
+			for id := range acks {
+				c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type":"output.ack","payload":{"messageId":%q}}`, id)))
+			}
+		}()
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg protocol.OutputMessage
+			json.Unmarshal(data, &msg)
+			received <- msg
+		}
+	})
+	defer close(acks)
+
+	logger := telemetry.New(telemetry.Config{Level: "error"})
+	window := NewAckWindow(8)
+	writer := NewWebSocketWriter(conn, WebSocketWriterConfig{Logger: logger})
+	defer writer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := NewWebSocketSource(WebSocketSourceConfig{Conn: conn, SessionID: "s1", Acks: window, Logger: logger})
+	go source.Process(ctx, make(chan core.Event), make(chan core.Event, 1))
+
+	input := make(chan core.Event, 2)
+	input <- core.AudioEvent{Data: make([]byte, 6), Format: "pcm"}
+	input <- core.AudioEvent{Data: make([]byte, 6), Format: "pcm"}
+	close(input)
+	sink := NewWebSocketSink(WebSocketSinkConfig{Conn: conn, SessionID: "s1", Writer: writer, FlowControl: window, Logger: logger})
+	done := make(chan error, 1)
+	go func() { done <- sink.Process(ctx, input, make(chan core.Event, 1)) }()
+
+	next := func() protocol.OutputMessage {
+		select {
+		case msg := <-received:
+			return msg
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a message")
+			return protocol.OutputMessage{}
+		}
+	}
+
+	if msg := next(); msg.Type != protocol.OutputResponseAudioStart {
+		t.Fatalf("expected audio start, got %s", msg.Type)
+	}
+	first := next()
+	if first.Type != protocol.OutputStreamAudio {
+		t.Fatalf("expected stream.audio, got %s", first.Type)
+	}
+
+	select {
+	case msg := <-received:
+		t.Fatalf("expected the second chunk to wait for an ack, got %s", msg.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	acks <- first.ID
+	if msg := next(); msg.Type != protocol.OutputStreamAudio {
+		t.Fatalf("expected stream.audio after the ack, got %s", msg.Type)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("sink failed: %v", err)
+	}
+}
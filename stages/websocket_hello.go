@@ -0,0 +1,101 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/creastat/pipeline/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// AcceptHello performs the session.hello handshake on a new connection: it
+// reads the client's hello, negotiates the version and features against
+// supported and replies with the server's hello. Run it before starting the
+// source and sink, and pass the result to WebSocketSinkConfig.Capabilities.
+// A nil codec means JSON. Clients with an unsupported version get an error
+// message before the handshake fails.
+func AcceptHello(ctx context.Context, conn *websocket.Conn, codec protocol.Codec, sessionID string, supported []protocol.Feature) (protocol.Capabilities, error) {
+	if codec == nil {
+		codec = protocol.JSONCodec
+	}
+
+	msg, err := readHandshake(ctx, conn, codec, protocol.InputHello)
+	if err != nil {
+		return protocol.Capabilities{}, err
+	}
+
+	caps, err := protocol.Negotiate(msg.Payload.(protocol.HelloPayload), supported)
+	if err != nil {
+		writeHandshake(conn, codec, protocol.NewErrorMessage(sessionID, msg.ID, "UNSUPPORTED_VERSION", err.Error(), false, nil))
+		return protocol.Capabilities{}, err
+	}
+
+	if err := writeHandshake(conn, codec, protocol.NewHelloMessage(sessionID, msg.ID, caps)); err != nil {
+		return protocol.Capabilities{}, fmt.Errorf("failed to send session.hello: %w", err)
+	}
+	return caps, nil
+}
+
+// AcceptResume reads the session.resume a reconnecting client sends after
+// session.hello. Its payload is a protocol.ResumePayload: look up the
+// session's ReplayBuffer by its SessionID and pass both to
+// WebSocketSinkConfig to replay what the client missed. A nil codec means
+// JSON.
+func AcceptResume(ctx context.Context, conn *websocket.Conn, codec protocol.Codec) (*protocol.InputMessage, error) {
+	if codec == nil {
+		codec = protocol.JSONCodec
+	}
+	return readHandshake(ctx, conn, codec, protocol.InputResume)
+}
+
+// readHandshake reads the next frame and requires it to be a message of the
+// given type
+func readHandshake(ctx context.Context, conn *websocket.Conn, codec protocol.Codec, want protocol.InputMessageType) (*protocol.InputMessage, error) {
+	// ReadMessage blocks, so unblock it with a read deadline on cancellation
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	mt, data, err := conn.ReadMessage()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", want, err)
+	}
+
+	var msg *protocol.InputMessage
+	if mt == websocket.BinaryMessage && codec.Binary() {
+		msg, err = codec.Unmarshal(data)
+	} else {
+		msg, err = protocol.DecodeInput(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.Type != want {
+		return nil, fmt.Errorf("expected %s, got %s", want, msg.Type)
+	}
+	return msg, nil
+}
+
+// writeHandshake writes a message directly to the connection, before any
+// WebSocketWriter owns it
+func writeHandshake(conn *websocket.Conn, codec protocol.Codec, msg *protocol.OutputMessage) error {
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	messageType := websocket.TextMessage
+	if codec.Binary() {
+		messageType = websocket.BinaryMessage
+	}
+	return conn.WriteMessage(messageType, data)
+}
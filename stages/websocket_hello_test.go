@@ -0,0 +1,249 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// TestAcceptHelloLimitsSink tests that the sink only sends what the client
+// negotiated: no interim transcripts, and audio as stream.audio messages
+// instead of raw binary frames
+func TestAcceptHelloLimitsSink(t *testing.T) {
+	serverErr := make(chan error, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer c.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		caps, err := AcceptHello(ctx, c, nil, "s1", protocol.AllFeatures)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+
+		sink := NewWebSocketSink(WebSocketSinkConfig{
+			Conn:         c,
+			SessionID:    "s1",
+			Capabilities: caps,
+			Logger:       telemetry.New(telemetry.Config{Level: "error"}),
+		})
+		input := make(chan core.Event, 4)
+		input <- core.STTEvent{Text: "hel"}
+		input <- core.STTEvent{Text: "hello", IsFinal: true}
+		input <- core.AudioEvent{Data: []byte{1, 2, 3}, Format: "pcm"}
+		input <- core.ActionEvent{ActionID: "a1"}
+		close(input)
+		serverErr <- sink.Process(ctx, input, make(chan core.Event, 4))
+
+		// Keep the connection open until the client has read everything
+		c.ReadMessage()
+	}))
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	hello := `{"type":"session.hello","id":"h1","payload":{"version":1,"features":["actions"]}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(hello)); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var types []protocol.OutputMessageType
+	for len(types) < 5 {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read after %v: %v", types, err)
+		}
+		if mt != websocket.TextMessage {
+			t.Fatalf("expected only text frames, got a binary frame after %v", types)
+		}
+		var msg struct {
+			Type    protocol.OutputMessageType `json:"type"`
+			Version int                        `json:"version"`
+			ReplyTo string                     `json:"replyTo"`
+			Payload protocol.HelloPayload      `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+		if msg.Version != protocol.ProtocolVersion {
+			t.Errorf("expected version %d on %s, got %d", protocol.ProtocolVersion, msg.Type, msg.Version)
+		}
+		if msg.Type == protocol.OutputHello && (msg.ReplyTo != "h1" || len(msg.Payload.Features) != 1) {
+			t.Errorf("unexpected hello reply %s", data)
+		}
+		types = append(types, msg.Type)
+	}
+
+	want := []protocol.OutputMessageType{
+		protocol.OutputHello,
+		protocol.OutputStreamSTT,
+		protocol.OutputResponseAudioStart,
+		protocol.OutputStreamAudio,
+		protocol.OutputActionRequest,
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, types)
+		}
+	}
+
+	if err := <-serverErr; err != nil {
+		t.Fatalf("server failed: %v", err)
+	}
+}
+
+// TestAcceptHelloRejectsOldVersion tests that clients below the minimum
+// version get an error message and the handshake fails
+func TestAcceptHelloRejectsOldVersion(t *testing.T) {
+	serverErr := make(chan error, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer c.Close()
+		_, err = AcceptHello(context.Background(), c, nil, "s1", protocol.AllFeatures)
+		serverErr <- err
+	}))
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"session.hello","id":"h1","payload":{"version":0}}`))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if !strings.Contains(string(data), `"type":"error"`) {
+		t.Errorf("expected an error message, got %s", data)
+	}
+	if err := <-serverErr; err == nil {
+		t.Error("expected the handshake to fail")
+	}
+}
+
+// TestWebSocketSinkResume tests that a client reconnecting with
+// session.resume receives the messages it missed before new ones
+func TestWebSocketSinkResume(t *testing.T) {
+	replay := protocol.NewReplayBuffer(16)
+	logger := telemetry.New(telemetry.Config{Level: "error"})
+
+	// First connection: the client only reads the first message
+	first := make(chan []byte, 4)
+	conn := dialPeer(t, func(c *websocket.Conn) {
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			first <- data
+		}
+	})
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "a", Content: "a"}
+	input <- core.LLMEvent{Delta: "b", Content: "ab"}
+	input <- core.LLMEvent{Delta: "c", Content: "abc"}
+	close(input)
+	sink := NewWebSocketSink(WebSocketSinkConfig{Conn: conn, SessionID: "s1", Replay: replay, Logger: logger})
+	if err := sink.Process(context.Background(), input, make(chan core.Event, 1)); err != nil {
+		t.Fatalf("first sink failed: %v", err)
+	}
+	var seen protocol.OutputMessage
+	if err := json.Unmarshal(<-first, &seen); err != nil || seen.Seq != 1 {
+		t.Fatalf("expected the first message to carry seq 1, got %+v, %v", seen, err)
+	}
+
+	// Second connection: resume after seq 1 and receive seqs 2, 3 and a new one
+	serverErr := make(chan error, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer c.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resume, err := AcceptResume(ctx, c, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		input := make(chan core.Event, 1)
+		input <- core.LLMEvent{Delta: "d", Content: "abcd"}
+		close(input)
+		sink := NewWebSocketSink(WebSocketSinkConfig{Conn: c, SessionID: "s1", Replay: replay, Resume: resume, Logger: logger})
+		serverErr <- sink.Process(ctx, input, make(chan core.Event, 1))
+		c.ReadMessage()
+	}))
+	defer s.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	client.WriteMessage(websocket.TextMessage, []byte(`{"type":"session.resume","id":"r1","payload":{"sessionId":"s1","lastSeq":1}}`))
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msgs []protocol.OutputMessage
+	for len(msgs) < 4 {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read after %d messages: %v", len(msgs), err)
+		}
+		var msg protocol.OutputMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	resumed, _ := msgs[0].Payload.(map[string]any)
+	if msgs[0].Type != protocol.OutputResumed || msgs[0].ReplyTo != "r1" || resumed["replayed"] != float64(2) || resumed["complete"] != true {
+		t.Errorf("unexpected session.resumed %+v", msgs[0])
+	}
+	for i, want := range []uint64{2, 3, 4} {
+		if msgs[i+1].Seq != want {
+			t.Errorf("expected message %d to carry seq %d, got %d", i+1, want, msgs[i+1].Seq)
+		}
+		if msgs[i+1].ID == "" {
+			t.Errorf("expected message %d to keep its ID", i+1)
+		}
+	}
+
+	if err := <-serverErr; err != nil {
+		t.Fatalf("server failed: %v", err)
+	}
+}
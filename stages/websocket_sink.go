@@ -2,7 +2,7 @@ package stages
 
 import (
 	"context"
-	"encoding/json"
+	"time"
 
 	"github.com/creastat/infra/telemetry"
 	"github.com/creastat/pipeline/core"
@@ -14,18 +14,66 @@ import (
 type WebSocketSinkConfig struct {
 	Conn       *websocket.Conn
 	SessionID  string
-	ResponseID string // ID to correlate response.start and response.end
+	ResponseID string // ID for events without a turn, see core.TurnContext
+	SampleRate int    // Sample rate reported in response.audio_start when events carry none (default: 24000)
 	Logger     telemetry.Logger
+
+	// Codec encodes messages for the connection (default: protocol.JSONCodec).
+	// With a binary codec every message, audio included, is sent as an
+	// encoded binary frame instead of JSON text and raw audio frames.
+	Codec protocol.Codec
+
+	// Capabilities negotiated with the client in session.hello, see
+	// AcceptHello. The zero value sends everything, for clients that skip
+	// the handshake.
+	Capabilities protocol.Capabilities
+
+	// Writer serializes writes when other goroutines also write to Conn.
+	// If nil, the sink creates its own writer for the run.
+	Writer *WebSocketWriter
+
+	// QueueSize and WriteTimeout configure the writer created by the sink
+	QueueSize    int
+	WriteTimeout time.Duration
+
+	// Replay records every message sent, heartbeats aside, so a client that
+	// reconnects can resume. Resumable sinks send audio as stream.audio
+	// messages, since raw frames can't carry a sequence number.
+	Replay *protocol.ReplayBuffer
+
+	// Resume is the client's session.resume on this connection, see
+	// AcceptResume. The sink first replays the messages in Replay that the
+	// client missed.
+	Resume *protocol.InputMessage
+
+	// FlowControl limits unacknowledged audio: the sink waits for the
+	// client's output.ack before sending more once the window is full. Audio
+	// is then sent as stream.audio messages, whose IDs clients ack, and is
+	// never dropped for a slow consumer. Pass the same window to
+	// WebSocketSourceConfig.Acks.
+	FlowControl *AckWindow
+
+	// HeartbeatInterval sends a session.heartbeat message at this interval
+	// so clients can detect a dead server. Zero disables heartbeats.
+	HeartbeatInterval time.Duration
 }
 
 // WebSocketSink sends pipeline events to a WebSocket connection
 type WebSocketSink struct {
-	config       WebSocketSinkConfig
-	audioStarted bool
+	config        WebSocketSinkConfig
+	writer        *WebSocketWriter
+	audioStarted  bool
+	audioResponse string // Response ID of the open audio segment
 }
 
 // NewWebSocketSink creates a new WebSocket sink stage
 func NewWebSocketSink(config WebSocketSinkConfig) *WebSocketSink {
+	if config.SampleRate == 0 {
+		config.SampleRate = 24000
+	}
+	if config.Codec == nil {
+		config.Codec = protocol.JSONCodec
+	}
 	return &WebSocketSink{
 		config: config,
 	}
@@ -42,42 +90,90 @@ func (ws *WebSocketSink) Process(ctx context.Context, input <-chan core.Event, o
 	logger := ws.config.Logger.WithModule(ws.Name())
 	logger.Info("Starting WebSocket sink stage", telemetry.String("session_id", ws.config.SessionID))
 
+	ws.writer = ws.config.Writer
+	if ws.writer == nil {
+		ws.writer = NewWebSocketWriter(ws.config.Conn, WebSocketWriterConfig{
+			QueueSize:    ws.config.QueueSize,
+			WriteTimeout: ws.config.WriteTimeout,
+			Logger:       ws.config.Logger,
+		})
+		defer ws.writer.Close()
+	}
+
+	if ws.config.Resume != nil {
+		if err := ws.replay(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("Failed to replay messages", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
+			for range input {
+				// Drain remaining events
+			}
+			return nil
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if ws.config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(ws.config.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("WebSocket sink context cancelled", telemetry.String("session_id", ws.config.SessionID))
 			return ctx.Err()
 
+		case <-heartbeat:
+			if err := ws.sendHeartbeat(ctx); err != nil {
+				// The writer failed and event writes will report it, stop heartbeats
+				logger.Debug("Failed to send heartbeat", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
+				heartbeat = nil
+			}
+
 		case event, ok := <-input:
 			if !ok {
 				logger.Info("WebSocket sink input channel closed", telemetry.String("session_id", ws.config.SessionID))
 				return nil
 			}
 
+			// Skip messages for features the client didn't negotiate
+			if !ws.wants(event) {
+				continue
+			}
+			if batch, ok := event.(core.BatchEvent); ok {
+				event = ws.filterBatch(batch)
+			}
+
+			// Events carry their turn's response ID, config covers events without
+			responseID := responseIDOf(event, ws.config.ResponseID)
+
 			// Special handling for AudioEvent to send only binary
 			if audioEvent, ok := event.(core.AudioEvent); ok {
 				// Send audio start message if this is the first chunk
 				if !ws.audioStarted {
-					// Default to PCM 24kHz if not specified (should be passed from config, but for now hardcoded or inferred)
-					// Ideally this info comes from the event or stage config.
-					// For now, we'll assume 24kHz PCM as per common defaults, or extract from event if possible.
-					// The AudioEvent struct has Format, but not SampleRate.
-					// We'll send what we have.
+					// Prefer the sample rate carried by the event, falling back to config
+					sampleRate := audioEvent.SampleRate
+					if sampleRate == 0 {
+						sampleRate = ws.config.SampleRate
+					}
 					startMsg := protocol.NewResponseAudioStartMessage(
 						ws.config.SessionID,
-						ws.config.ResponseID,
-						ws.config.ResponseID,
+						responseID,
+						responseID,
 						audioEvent.Format,
-						24000, // TODO: Get this from config/event
+						sampleRate,
 					)
-					if data, err := json.Marshal(startMsg); err == nil {
-						ws.config.Conn.WriteMessage(websocket.TextMessage, data)
+					if err := ws.send(ctx, startMsg); err == nil {
 						logger.Info("Sent audio start message", telemetry.String("session_id", ws.config.SessionID))
 					}
 					ws.audioStarted = true
+					ws.audioResponse = responseID
 				}
 
-				if err := ws.config.Conn.WriteMessage(websocket.BinaryMessage, audioEvent.Data); err != nil {
+				if err := ws.writeAudio(ctx, audioEvent); err != nil {
 					logger.Error("Failed to send audio to WebSocket", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
 					// WebSocket connection closed or failed - gracefully drain input without failing pipeline
 					for range input {
@@ -89,17 +185,33 @@ func (ws *WebSocketSink) Process(ctx context.Context, input <-chan core.Event, o
 				continue
 			}
 
+			// On barge-in, close any open audio segment so the client stops playback
+			if _, ok := event.(core.InterruptEvent); ok {
+				if ws.audioStarted {
+					endMsg := protocol.NewResponseAudioEndMessage(
+						ws.config.SessionID,
+						ws.audioResponse,
+						ws.audioResponse,
+						0,
+					)
+					if err := ws.send(ctx, endMsg); err == nil {
+						logger.Debug("Sent audio end message on interrupt", telemetry.String("session_id", ws.config.SessionID))
+					}
+					ws.audioStarted = false
+				}
+				continue
+			}
+
 			// Check for DoneEvent to send audio end if audio was started
 			if doneEvent, ok := event.(core.DoneEvent); ok {
 				if ws.audioStarted {
 					endMsg := protocol.NewResponseAudioEndMessage(
 						ws.config.SessionID,
-						ws.config.ResponseID,
-						ws.config.ResponseID,
+						ws.audioResponse,
+						ws.audioResponse,
 						0, // Duration not tracked here yet
 					)
-					if data, err := json.Marshal(endMsg); err == nil {
-						ws.config.Conn.WriteMessage(websocket.TextMessage, data)
+					if err := ws.send(ctx, endMsg); err == nil {
 						logger.Debug("Sent audio end message", telemetry.String("session_id", ws.config.SessionID))
 					}
 					ws.audioStarted = false
@@ -108,11 +220,9 @@ func (ws *WebSocketSink) Process(ctx context.Context, input <-chan core.Event, o
 				// Forward DoneEvent to client
 				logger.Debug("Forwarding DoneEvent to client", telemetry.String("session_id", ws.config.SessionID), telemetry.Float64("audio_duration", doneEvent.AudioDuration))
 				// Convert event to protocol message
-				msg := protocol.EventToMessage(event, ws.config.SessionID, ws.config.ResponseID)
+				msg := protocol.EventToMessage(event, ws.config.SessionID, responseID)
 				if msg != nil {
-					data, err := json.Marshal(msg)
-					if err == nil {
-						ws.config.Conn.WriteMessage(websocket.TextMessage, data)
+					if err := ws.send(ctx, msg); err == nil {
 						logger.Debug("Sent event to WebSocket", telemetry.String("type", string(msg.Type)), telemetry.String("session_id", ws.config.SessionID))
 					}
 				}
@@ -120,22 +230,26 @@ func (ws *WebSocketSink) Process(ctx context.Context, input <-chan core.Event, o
 			}
 
 			// Convert event to protocol message
-			msg := protocol.EventToMessage(event, ws.config.SessionID, ws.config.ResponseID)
+			msg := protocol.EventToMessage(event, ws.config.SessionID, responseID)
 			if msg == nil {
 				logger.Debug("Skipping unknown event type", telemetry.String("session_id", ws.config.SessionID))
 				continue
 			}
 
-			// Serialize message to JSON
-			data, err := json.Marshal(msg)
+			// Serialize message with the connection's codec
+			ws.sequence(msg)
+			data, err := ws.encode(msg)
 			if err != nil {
 				logger.Error("Failed to marshal message", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID), telemetry.String("event_type", string(msg.Type)))
 				// Log error but continue processing - don't fail the pipeline
 				continue
 			}
 
-			// Send JSON message to WebSocket
-			if err := ws.config.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			// Send message to WebSocket
+			if err := ws.write(ctx, data); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				logger.Error("Failed to send message to WebSocket", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID), telemetry.String("event_type", string(msg.Type)))
 				// WebSocket connection closed or failed - gracefully drain input without failing pipeline
 				// This allows upstream stages to complete their work
@@ -150,6 +264,158 @@ func (ws *WebSocketSink) Process(ctx context.Context, input <-chan core.Event, o
 	}
 }
 
+// wants reports whether the client negotiated the feature an event needs
+func (ws *WebSocketSink) wants(event core.Event) bool {
+	switch e := event.(type) {
+	case core.STTEvent:
+		return e.IsFinal || ws.config.Capabilities.Has(protocol.FeatureInterimSTT)
+	case core.ActionEvent:
+		return ws.config.Capabilities.Has(protocol.FeatureActions)
+	case core.BatchEvent:
+		for _, inner := range e.Events {
+			if ws.wants(inner) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// filterBatch drops batched events the client didn't negotiate
+func (ws *WebSocketSink) filterBatch(batch core.BatchEvent) core.BatchEvent {
+	events := make([]core.Event, 0, len(batch.Events))
+	for _, inner := range batch.Events {
+		if ws.wants(inner) {
+			events = append(events, inner)
+		}
+	}
+	batch.Events = events
+	return batch
+}
+
+// responseIDOf returns the response ID of the event's turn, or fallback for
+// events outside a turn
+func responseIDOf(event core.Event, fallback string) string {
+	if id := core.MetaOf(event).Turn.ResponseID; id != "" {
+		return id
+	}
+	return fallback
+}
+
+// encode stamps a message with the negotiated version, and an ID if it has
+// none, and encodes it with the sink's codec
+func (ws *WebSocketSink) encode(msg *protocol.OutputMessage) ([]byte, error) {
+	if msg.ID == "" {
+		msg.ID = protocol.NewMessageID()
+	}
+	msg.Version = ws.config.Capabilities.MessageVersion()
+	return ws.config.Codec.Marshal(msg)
+}
+
+// sequence records a message in the replay buffer, if the sink is resumable.
+// The ID is set first so the replayed copy keeps it.
+func (ws *WebSocketSink) sequence(msg *protocol.OutputMessage) {
+	if ws.config.Replay == nil {
+		return
+	}
+	if msg.ID == "" {
+		msg.ID = protocol.NewMessageID()
+	}
+	ws.config.Replay.Append(msg)
+}
+
+// send records a message for replay, encodes it and queues it
+func (ws *WebSocketSink) send(ctx context.Context, msg *protocol.OutputMessage) error {
+	ws.sequence(msg)
+	data, err := ws.encode(msg)
+	if err != nil {
+		return err
+	}
+	return ws.write(ctx, data)
+}
+
+// sendHeartbeat queues a session.heartbeat, which is never replayed
+func (ws *WebSocketSink) sendHeartbeat(ctx context.Context) error {
+	data, err := ws.encode(protocol.NewHeartbeatMessage(ws.config.SessionID))
+	if err != nil {
+		return err
+	}
+	return ws.write(ctx, data)
+}
+
+// replay sends session.resumed followed by the messages the client missed.
+// Messages are copied before encoding, the buffer may outlive this
+// connection's codec and capabilities.
+func (ws *WebSocketSink) replay(ctx context.Context) error {
+	resume, _ := ws.config.Resume.Payload.(protocol.ResumePayload)
+
+	var missed []*protocol.OutputMessage
+	var lastSeq uint64
+	complete := false
+	if ws.config.Replay != nil {
+		missed, complete = ws.config.Replay.Since(resume.LastSeq)
+		lastSeq = ws.config.Replay.LastSeq()
+	}
+
+	resumed := protocol.NewResumedMessage(ws.config.SessionID, ws.config.Resume.ID, lastSeq, len(missed), complete)
+	data, err := ws.encode(resumed)
+	if err != nil {
+		return err
+	}
+	if err := ws.write(ctx, data); err != nil {
+		return err
+	}
+
+	for _, msg := range missed {
+		replayed := *msg
+		data, err := ws.encode(&replayed)
+		if err != nil {
+			return err
+		}
+		if err := ws.write(ctx, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write queues an encoded message in the frame type of the sink's codec
+func (ws *WebSocketSink) write(ctx context.Context, data []byte) error {
+	if ws.config.Codec.Binary() {
+		return ws.writer.WriteFrame(ctx, websocket.BinaryMessage, data)
+	}
+	return ws.writer.WriteText(ctx, data)
+}
+
+// writeAudio queues an audio chunk. Binary frames are dropped for a slow
+// consumer. JSON connections get the raw audio unless the client declined
+// binary audio or the sink is resumable or flow controlled; binary codecs
+// wrap it in stream.audio, since a raw frame couldn't be told apart from an
+// encoded message.
+func (ws *WebSocketSink) writeAudio(ctx context.Context, event core.AudioEvent) error {
+	binaryAudio := ws.config.Capabilities.Has(protocol.FeatureBinaryAudio) && ws.config.Replay == nil && ws.config.FlowControl == nil
+	if !ws.config.Codec.Binary() && binaryAudio {
+		return ws.writer.WriteBinary(event.Data)
+	}
+	msg := protocol.EventToMessage(event, ws.config.SessionID, responseIDOf(event, ws.config.ResponseID))
+	ws.sequence(msg)
+	data, err := ws.encode(msg)
+	if err != nil {
+		return err
+	}
+	if ws.config.FlowControl != nil {
+		if err := ws.config.FlowControl.Acquire(ctx, msg.ID, len(event.Data)); err != nil {
+			return err
+		}
+		return ws.write(ctx, data)
+	}
+	if ws.config.Codec.Binary() {
+		return ws.writer.WriteBinary(data)
+	}
+	return ws.writer.WriteText(ctx, data)
+}
+
 // InputTypes returns the input event types this stage accepts
 func (ws *WebSocketSink) InputTypes() []core.EventType {
 	// WebSocket sink accepts all event types
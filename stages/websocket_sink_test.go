@@ -180,3 +180,40 @@ func TestWebSocketSink_AudioEvent(t *testing.T) {
 		t.Error("Should receive response.audio_end message")
 	}
 }
+
+func TestWebSocketSink_Heartbeat(t *testing.T) {
+	received := make(chan []byte, 4)
+	conn := dialPeer(t, func(c *websocket.Conn) {
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- data
+		}
+	})
+
+	sink := NewWebSocketSink(WebSocketSinkConfig{
+		Conn:              conn,
+		SessionID:         "test-session",
+		HeartbeatInterval: 10 * time.Millisecond,
+		Logger:            telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Process(ctx, make(chan core.Event), make(chan core.Event))
+
+	select {
+	case data := <-received:
+		var msg protocol.OutputMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+		if msg.Type != protocol.OutputHeartbeat || msg.SessionID != "test-session" {
+			t.Errorf("expected session.heartbeat, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat received")
+	}
+}
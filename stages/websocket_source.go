@@ -0,0 +1,205 @@
+package stages
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/creastat/pipeline/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketSourceConfig holds WebSocket source configuration
+type WebSocketSourceConfig struct {
+	Conn       *websocket.Conn
+	SessionID  string
+	Format     string // Format of raw binary audio frames, e.g. "pcm"
+	SampleRate int    // Sample rate of raw binary audio frames
+	Logger     telemetry.Logger
+
+	// Codec decodes binary frames when it is a binary codec, e.g. the one
+	// negotiated for the connection. Otherwise binary frames are raw audio.
+	// Text frames are always JSON.
+	Codec protocol.Codec
+
+	// PingInterval sends WebSocket ping frames at this interval. Zero
+	// disables pings.
+	PingInterval time.Duration
+
+	// IdleTimeout is how long the client may stay silent, sending neither
+	// frames nor pongs, before the source emits a ConnectionLostEvent and
+	// stops. Defaults to twice PingInterval; zero without pings disables it.
+	IdleTimeout time.Duration
+
+	// Acks receives the client's output.ack messages for the sink's flow
+	// control, see WebSocketSinkConfig.FlowControl
+	Acks *AckWindow
+}
+
+// WebSocketSource reads client messages from a WebSocket connection and
+// emits the corresponding pipeline events
+type WebSocketSource struct {
+	config WebSocketSourceConfig
+}
+
+// NewWebSocketSource creates a new WebSocket source stage
+func NewWebSocketSource(config WebSocketSourceConfig) *WebSocketSource {
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = 2 * config.PingInterval
+	}
+	return &WebSocketSource{
+		config: config,
+	}
+}
+
+// Name returns the stage name
+func (ws *WebSocketSource) Name() string {
+	return "websocket_source"
+}
+
+// Process implements the Stage interface
+// It reads frames from the WebSocket connection until the client disconnects
+// or the context is cancelled. Text frames are decoded as protocol input
+// messages, binary frames are treated as audio chunks.
+func (ws *WebSocketSource) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	logger := ws.config.Logger.WithModule(ws.Name())
+	logger.Info("Starting WebSocket source stage", telemetry.String("session_id", ws.config.SessionID))
+
+	router := protocol.NewInputRouter().FeedPipeline(output)
+	router.BinaryFormat = ws.config.Format
+	router.BinarySampleRate = ws.config.SampleRate
+	if ws.config.Acks != nil {
+		router.Handle(protocol.InputAck, func(ctx context.Context, msg *protocol.InputMessage) error {
+			ws.config.Acks.Ack(msg.Payload.(protocol.AckPayload).MessageID)
+			return nil
+		})
+	}
+
+	// ReadMessage blocks, so unblock it with a read deadline on cancellation
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.config.Conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	if ws.config.PingInterval > 0 {
+		go ws.ping(stop, logger)
+	}
+
+	// Any frame or pong proves the client is alive
+	lastSeen := time.Now()
+	ws.config.Conn.SetPongHandler(func(string) error {
+		lastSeen = time.Now()
+		ws.extendDeadline(ctx)
+		return nil
+	})
+
+	for {
+		ws.extendDeadline(ctx)
+		mt, data, err := ws.config.Conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Info("WebSocket source context cancelled", telemetry.String("session_id", ws.config.SessionID))
+				return ctx.Err()
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				logger.Warn("WebSocket client went silent", telemetry.String("session_id", ws.config.SessionID), telemetry.Float64("idle_seconds", time.Since(lastSeen).Seconds()))
+				return ws.connectionLost(ctx, output, lastSeen)
+			}
+			// Client disconnected - end the stream without failing the pipeline
+			logger.Info("WebSocket source connection closed", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
+			return nil
+		}
+		lastSeen = time.Now()
+
+		switch mt {
+		case websocket.BinaryMessage:
+			if ws.config.Codec != nil && ws.config.Codec.Binary() {
+				err = router.DispatchWith(ctx, ws.config.Codec, data)
+			} else {
+				err = router.DispatchBinary(ctx, ws.config.SessionID, data)
+			}
+		case websocket.TextMessage:
+			err = router.Dispatch(ctx, data)
+		default:
+			continue
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Log malformed messages but keep reading - don't fail the pipeline
+			logger.Warn("Failed to handle client message", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
+			continue
+		}
+
+		logger.Debug("Received client message", telemetry.Int("size", len(data)), telemetry.String("session_id", ws.config.SessionID))
+	}
+}
+
+// extendDeadline gives the client another IdleTimeout to send something. A
+// cancelled context keeps the expired deadline set by Process, so the read
+// still unblocks.
+func (ws *WebSocketSource) extendDeadline(ctx context.Context) {
+	if ws.config.IdleTimeout <= 0 {
+		return
+	}
+	ws.config.Conn.SetReadDeadline(time.Now().Add(ws.config.IdleTimeout))
+	if ctx.Err() != nil {
+		ws.config.Conn.SetReadDeadline(time.Now())
+	}
+}
+
+// ping sends ping frames until stop is closed. Control frames may be written
+// concurrently with the sink's writer.
+func (ws *WebSocketSource) ping(stop <-chan struct{}, logger telemetry.Logger) {
+	ticker := time.NewTicker(ws.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := ws.config.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ws.config.PingInterval)); err != nil {
+				// The read side notices the dead connection
+				logger.Debug("Failed to send ping", telemetry.Err(err), telemetry.String("session_id", ws.config.SessionID))
+				return
+			}
+		}
+	}
+}
+
+// connectionLost emits a ConnectionLostEvent and ends the stream
+func (ws *WebSocketSource) connectionLost(ctx context.Context, output chan<- core.Event, lastSeen time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case output <- core.ConnectionLostEvent{Reason: "heartbeat_timeout", LastSeen: lastSeen}:
+		return nil
+	}
+}
+
+// InputTypes returns the input event types this stage accepts
+func (ws *WebSocketSource) InputTypes() []core.EventType {
+	// WebSocket source is an entry stage, it reads from the connection only
+	return []core.EventType{}
+}
+
+// OutputTypes returns the output event types this stage produces
+func (ws *WebSocketSource) OutputTypes() []core.EventType {
+	return []core.EventType{
+		core.EventTypeLLM,
+		core.EventTypeAudio,
+		core.EventTypeInterrupt,
+		core.EventTypeDone,
+		core.EventTypeConnectionLost,
+	}
+}
@@ -0,0 +1,207 @@
+package stages
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/creastat/pipeline/core"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketSource_ClientMessages(t *testing.T) {
+	// Server plays the client role and writes input frames
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.WriteMessage(websocket.TextMessage, []byte(`{"type":"input.text","payload":{"text":"hello"}}`))
+		c.WriteMessage(websocket.BinaryMessage, []byte{0x01, 0x02})
+		c.WriteMessage(websocket.TextMessage, []byte(`{"type":"bogus"}`))
+		c.WriteMessage(websocket.TextMessage, []byte(`{"type":"control.cancel","payload":{"reason":"stop"}}`))
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	source := NewWebSocketSource(WebSocketSourceConfig{
+		Conn:       conn,
+		SessionID:  "test-session",
+		Format:     "pcm",
+		SampleRate: 16000,
+		Logger:     telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	input := make(chan core.Event)
+	output := make(chan core.Event, 10)
+
+	if err := source.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("source process failed: %v", err)
+	}
+	close(output)
+
+	var events []core.Event
+	for event := range output {
+		events = append(events, event)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %v", len(events), events)
+	}
+	if llm, ok := events[0].(core.LLMEvent); !ok || llm.Content != "hello" {
+		t.Errorf("expected LLMEvent with text, got %#v", events[0])
+	}
+	if _, ok := events[1].(core.DoneEvent); !ok {
+		t.Errorf("expected DoneEvent, got %#v", events[1])
+	}
+	if audio, ok := events[2].(core.AudioEvent); !ok || audio.Format != "pcm" || len(audio.Data) != 2 {
+		t.Errorf("expected pcm AudioEvent, got %#v", events[2])
+	}
+	if interrupt, ok := events[3].(core.InterruptEvent); !ok || interrupt.Reason != "stop" {
+		t.Errorf("expected InterruptEvent, got %#v", events[3])
+	}
+}
+
+func TestWebSocketSource_ContextCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		// Keep the connection open without sending anything
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				break
+			}
+		}
+	}))
+	defer s.Close()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	source := NewWebSocketSource(WebSocketSourceConfig{
+		Conn:      conn,
+		SessionID: "test-session",
+		Logger:    telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- source.Process(ctx, make(chan core.Event), make(chan core.Event, 1))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("source did not stop after cancellation")
+	}
+}
+
+// dialPeer connects to a test server whose handler plays the client
+func dialPeer(t *testing.T, peer func(c *websocket.Conn)) *websocket.Conn {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		peer(c)
+	}))
+	t.Cleanup(s.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebSocketSource_ConnectionLost(t *testing.T) {
+	// The peer never reads, so it never answers pings
+	release := make(chan struct{})
+	defer close(release)
+	conn := dialPeer(t, func(c *websocket.Conn) { <-release })
+
+	source := NewWebSocketSource(WebSocketSourceConfig{
+		Conn:         conn,
+		SessionID:    "test-session",
+		PingInterval: 20 * time.Millisecond,
+		Logger:       telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	output := make(chan core.Event, 1)
+	start := time.Now()
+	if err := source.Process(context.Background(), make(chan core.Event), output); err != nil {
+		t.Fatalf("source process failed: %v", err)
+	}
+
+	select {
+	case event := <-output:
+		lost, ok := event.(core.ConnectionLostEvent)
+		if !ok || lost.Reason != "heartbeat_timeout" || lost.LastSeen.Before(start) {
+			t.Errorf("expected ConnectionLostEvent, got %#v", event)
+		}
+	default:
+		t.Fatal("expected ConnectionLostEvent")
+	}
+}
+
+func TestWebSocketSource_PongsKeepAlive(t *testing.T) {
+	// Reading makes the peer answer pings with pongs
+	conn := dialPeer(t, func(c *websocket.Conn) {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	source := NewWebSocketSource(WebSocketSourceConfig{
+		Conn:         conn,
+		SessionID:    "test-session",
+		PingInterval: 10 * time.Millisecond,
+		IdleTimeout:  50 * time.Millisecond,
+		Logger:       telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	output := make(chan core.Event, 1)
+	if err := source.Process(ctx, make(chan core.Event), output); err != context.DeadlineExceeded {
+		t.Errorf("expected the source to run until the deadline, got %v", err)
+	}
+	if len(output) != 0 {
+		t.Errorf("expected no events, got %#v", <-output)
+	}
+}
@@ -0,0 +1,191 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/gorilla/websocket"
+)
+
+// ErrWriterClosed is returned when writing to a closed WebSocketWriter
+var ErrWriterClosed = errors.New("websocket writer closed")
+
+const (
+	defaultWriterQueueSize    = 64
+	defaultWriterWriteTimeout = 10 * time.Second
+)
+
+// WebSocketWriterConfig holds WebSocket writer configuration
+type WebSocketWriterConfig struct {
+	QueueSize    int           // Pending frames before the slow-consumer policy applies, default 64
+	WriteTimeout time.Duration // Deadline for a single frame write, default 10s
+	Logger       telemetry.Logger
+}
+
+// WebSocketWriter serializes all writes to a WebSocket connection through a
+// single write pump. Gorilla connections support only one concurrent writer,
+// so every goroutine writing to the same connection must share one writer.
+//
+// When the client cannot keep up and the queue is full, binary audio frames
+// are dropped while text frames wait for room in the queue.
+type WebSocketWriter struct {
+	conn    *websocket.Conn
+	config  WebSocketWriterConfig
+	logger  telemetry.Logger
+	queue   chan wsFrame
+	closing chan struct{}
+	failed  chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+	failOnce  sync.Once
+	err       error
+	dropped   atomic.Int64
+}
+
+type wsFrame struct {
+	messageType int
+	data        []byte
+}
+
+// NewWebSocketWriter creates a writer and starts its write pump
+func NewWebSocketWriter(conn *websocket.Conn, config WebSocketWriterConfig) *WebSocketWriter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultWriterQueueSize
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWriterWriteTimeout
+	}
+
+	w := &WebSocketWriter{
+		conn:    conn,
+		config:  config,
+		logger:  config.Logger.WithModule("websocket_writer"),
+		queue:   make(chan wsFrame, config.QueueSize),
+		closing: make(chan struct{}),
+		failed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.pump()
+	return w
+}
+
+// WriteText queues a text frame, waiting for room in the queue if needed
+func (w *WebSocketWriter) WriteText(ctx context.Context, data []byte) error {
+	return w.WriteFrame(ctx, websocket.TextMessage, data)
+}
+
+// WriteFrame queues a frame of the given WebSocket message type, waiting for
+// room in the queue if needed. Unlike WriteBinary it never drops the frame.
+func (w *WebSocketWriter) WriteFrame(ctx context.Context, messageType int, data []byte) error {
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-w.closing:
+		return ErrWriterClosed
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.failed:
+		return w.Err()
+	case <-w.closing:
+		return ErrWriterClosed
+	case w.queue <- wsFrame{messageType: messageType, data: data}:
+		return nil
+	}
+}
+
+// WriteBinary queues a binary frame, dropping it if the queue is full
+func (w *WebSocketWriter) WriteBinary(data []byte) error {
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-w.closing:
+		return ErrWriterClosed
+	default:
+	}
+
+	select {
+	case w.queue <- wsFrame{messageType: websocket.BinaryMessage, data: data}:
+	default:
+		w.dropped.Add(1)
+		w.logger.Debug("Dropped binary frame for slow consumer", telemetry.Int("size", len(data)))
+	}
+	return nil
+}
+
+// Dropped returns the number of binary frames dropped for a slow consumer
+func (w *WebSocketWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Err returns the write error that stopped the pump, if any
+func (w *WebSocketWriter) Err() error {
+	select {
+	case <-w.failed:
+		return w.err
+	default:
+		return nil
+	}
+}
+
+// Close flushes queued frames and stops the write pump.
+// It does not close the underlying connection.
+func (w *WebSocketWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closing)
+	})
+	<-w.done
+	return w.Err()
+}
+
+// pump writes queued frames until the writer is closed or a write fails
+func (w *WebSocketWriter) pump() {
+	defer close(w.done)
+
+	for {
+		select {
+		case frame := <-w.queue:
+			if !w.write(frame) {
+				return
+			}
+		case <-w.closing:
+			// Flush whatever is still queued
+			for {
+				select {
+				case frame := <-w.queue:
+					if !w.write(frame) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write sends a single frame, recording the error if it fails
+func (w *WebSocketWriter) write(frame wsFrame) bool {
+	w.conn.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+	if err := w.conn.WriteMessage(frame.messageType, frame.data); err != nil {
+		w.failOnce.Do(func() {
+			w.err = err
+			close(w.failed)
+		})
+		w.logger.Error("Failed to write to WebSocket", telemetry.Err(err))
+		return false
+	}
+	return true
+}
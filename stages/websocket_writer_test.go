@@ -0,0 +1,107 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/infra/telemetry"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketWriter_ConcurrentWrites(t *testing.T) {
+	received := make(chan string, 100)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			_, message, err := c.ReadMessage()
+			if err != nil {
+				break
+			}
+			received <- string(message)
+		}
+	}))
+	defer s.Close()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	writer := NewWebSocketWriter(conn, WebSocketWriterConfig{
+		Logger: telemetry.New(telemetry.Config{Level: "error"}),
+	})
+
+	// Several goroutines share the connection through one writer
+	var wg sync.WaitGroup
+	for g := 0; g < 5; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := writer.WriteText(context.Background(), []byte(fmt.Sprintf("%d-%d", g, i))); err != nil {
+					t.Errorf("WriteText failed: %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	timeout := time.After(time.Second)
+	for len(seen) < 50 {
+		select {
+		case msg := <-received:
+			seen[msg] = true
+		case <-timeout:
+			t.Fatalf("expected 50 messages, got %d", len(seen))
+		}
+	}
+
+	if err := writer.WriteText(context.Background(), []byte("late")); err != ErrWriterClosed {
+		t.Errorf("expected ErrWriterClosed after Close, got %v", err)
+	}
+}
+
+func TestWebSocketWriter_SlowConsumerPolicy(t *testing.T) {
+	// Writer without a running pump so the queue stays full
+	writer := &WebSocketWriter{
+		queue:   make(chan wsFrame, 1),
+		closing: make(chan struct{}),
+		failed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if err := writer.WriteBinary([]byte{0x01}); err != nil {
+		t.Fatalf("WriteBinary failed: %v", err)
+	}
+	if err := writer.WriteBinary([]byte{0x02}); err != nil {
+		t.Fatalf("WriteBinary failed: %v", err)
+	}
+	if writer.Dropped() != 1 {
+		t.Errorf("expected 1 dropped audio frame, got %d", writer.Dropped())
+	}
+
+	// Text frames wait for room instead of being dropped
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := writer.WriteText(ctx, []byte("text")); err != context.DeadlineExceeded {
+		t.Errorf("expected text write to block until deadline, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// PipelineStage runs a whole pipeline as a single stage, so validated graphs
+// such as a "voice-in" or "voice-out" chain can be reused as building blocks
+// of larger graphs. Its input feeds the inner entry node and the inner exit
+// nodes' events become its output.
+type PipelineStage struct {
+	name     string
+	pipeline *Pipeline
+}
+
+// AsStage wraps a pipeline as a stage. The stage is named after the inner
+// entry node; a pipeline can only run once at a time, so each embedding needs
+// its own Pipeline.
+func AsStage(p *Pipeline) *PipelineStage {
+	name := "pipeline"
+	if entry := p.graph.GetEntryNode(); entry != nil {
+		name = "pipeline:" + entry.Name()
+	}
+	return &PipelineStage{
+		name:     name,
+		pipeline: p,
+	}
+}
+
+// Name returns the stage name
+func (ps *PipelineStage) Name() string {
+	return ps.name
+}
+
+// Pipeline returns the wrapped pipeline
+func (ps *PipelineStage) Pipeline() *Pipeline {
+	return ps.pipeline
+}
+
+// InputTypes returns the event types the inner entry node accepts
+func (ps *PipelineStage) InputTypes() []core.EventType {
+	entry := ps.pipeline.graph.GetEntryNode()
+	if entry == nil || entry.Stage() == nil {
+		return []core.EventType{}
+	}
+	return entry.Stage().InputTypes()
+}
+
+// OutputTypes returns the event types the inner exit nodes produce
+func (ps *PipelineStage) OutputTypes() []core.EventType {
+	seen := make(map[core.EventType]bool)
+	var types []core.EventType
+	for _, exit := range ps.pipeline.graph.GetExitNodes() {
+		if exit.Stage() == nil {
+			return []core.EventType{}
+		}
+		outputTypes := exit.Stage().OutputTypes()
+		if len(outputTypes) == 0 {
+			// The exit node may produce any type
+			return []core.EventType{}
+		}
+		for _, t := range outputTypes {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
+
+// Process implements the Stage interface. It returns the first error of an
+// inner stage, so the outer pipeline handles it like any stage failure.
+func (ps *PipelineStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	return ps.pipeline.run(ctx, input, output)
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestAsStage tests that a pipeline embedded as a node forwards its exit
+// events and shares the outer run's correlation ID
+func TestAsStage(t *testing.T) {
+	inner, err := NewBuilder().
+		AddStage("first", &CollectingMockStage{name: "first"}).
+		AddStage("second", &CollectingMockStage{name: "second"}).
+		Connect("first", "second").
+		SetEntryNode("first").
+		AddExitNode("second").
+		Build()
+	if err != nil {
+		t.Fatalf("Build inner failed: %v", err)
+	}
+
+	sink := &CollectingMockStage{name: "sink"}
+	outer, err := NewBuilder().
+		AddStage("voice-in", AsStage(inner)).
+		AddStage("sink", sink).
+		Connect("voice-in", "sink").
+		SetEntryNode("voice-in").
+		AddExitNode("sink").
+		Build()
+	if err != nil {
+		t.Fatalf("Build outer failed: %v", err)
+	}
+
+	input := make(chan core.Event, 2)
+	input <- core.LLMEvent{Delta: "hello"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	ctx := core.WithCorrelationID(context.Background(), "turn-1")
+	var out []core.Event
+	for event := range outer.Execute(ctx, input) {
+		out = append(out, event)
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(out))
+	}
+	for _, event := range out {
+		meta := core.MetaOf(event)
+		if meta.CorrelationID != "turn-1" {
+			t.Errorf("expected correlation ID turn-1, got %q", meta.CorrelationID)
+		}
+		if meta.Origin != "first" {
+			t.Errorf("expected origin of the inner stage that emitted the event, got %q", meta.Origin)
+		}
+	}
+	if name := AsStage(inner).Name(); name != "pipeline:first" {
+		t.Errorf("unexpected stage name %q", name)
+	}
+}
+
+// TestAsStageError tests that an inner stage failure fails the embedding
+// node
+func TestAsStageError(t *testing.T) {
+	inner, err := NewBuilder().
+		AddStage("failing", &FailingMockStage{name: "failing", delay: 10 * time.Millisecond}).
+		SetEntryNode("failing").
+		AddExitNode("failing").
+		Build()
+	if err != nil {
+		t.Fatalf("Build inner failed: %v", err)
+	}
+
+	outer, err := NewBuilder().
+		AddStage("sub", AsStage(inner)).
+		SetEntryNode("sub").
+		AddExitNode("sub").
+		Build()
+	if err != nil {
+		t.Fatalf("Build outer failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	close(input)
+
+	for range outer.Execute(context.Background(), input) {
+	}
+
+	if outer.Metrics().Stages["sub"].Errors != 1 {
+		t.Errorf("expected the embedding node to record the error")
+	}
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// ReplaceStage swaps the stage of a node, e.g. to switch the TTS voice or
+// provider mid-session. When the pipeline isn't running the replacement takes
+// effect immediately. In a running pipeline the old stage is drained at the
+// next turn boundary: it gets every event up to and including the next
+// DoneEvent, its input is closed and the replacement takes over the rest of
+// the node's input. A node whose input carries no DoneEvent switches on the
+// next run.
+func (p *Pipeline) ReplaceStage(name string, stage core.Stage) error {
+	if stage == nil {
+		return fmt.Errorf("replacement for node %q must not be nil", name)
+	}
+	node := p.graph.GetNode(name)
+	if node == nil {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Running stages are swapped with mu held, so read the stage under it
+	if node.stage == nil {
+		return fmt.Errorf("node %q has no stage to replace", name)
+	}
+
+	if p.state == nil {
+		node.stage = stage
+		delete(p.replacements, name)
+		return nil
+	}
+	if p.replacements == nil {
+		p.replacements = make(map[string]core.Stage)
+	}
+	p.replacements[name] = stage
+	return nil
+}
+
+// pendingReplacement returns the stage waiting to replace a node's stage
+func (p *Pipeline) pendingReplacement(name string) (core.Stage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stage, ok := p.replacements[name]
+	return stage, ok
+}
+
+// applyReplacement installs the pending replacement of a node's stage, if any
+func (p *Pipeline) applyReplacement(node *graphNode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if stage, ok := p.replacements[node.Name()]; ok {
+		node.stage = stage
+		delete(p.replacements, node.Name())
+	}
+}
+
+// processStage runs a node's stage on the node's input. The input is relayed
+// through a channel per stage run so that the stage can be swapped at a turn
+// boundary by closing the old stage's input and starting the replacement, or
+// restarted after a panic under PanicRestart.
+func (p *Pipeline) processStage(ctx context.Context, node *graphNode, state *executionState) error {
+	ns := state.nodeStates[node.Name()]
+	var held core.Event
+	restarts := 0
+	for {
+		p.applyReplacement(node)
+
+		input := make(chan core.Event)
+		stop := make(chan struct{})
+		relayed := make(chan relayResult, 1)
+		go func(first core.Event) {
+			defer close(input)
+			relayed <- p.relayTurn(ctx, node.Name(), first, ns.input, input, stop)
+		}(held)
+		held = nil
+
+		err := callStage(ctx, node.Name(), node.runnable(), input, ns.output)
+		if err != nil {
+			close(stop)
+			held = (<-relayed).held
+
+			// Restart a panicking stage, handing it the event its relay held
+			var panicErr *StagePanicError
+			if errors.As(err, &panicErr) && node.PanicPolicy() == PanicRestart &&
+				restarts < DefaultPanicRestarts && ctx.Err() == nil {
+				restarts++
+				ns.span.RecordError(err)
+				ns.metrics.panics.Add(1)
+				continue
+			}
+
+			// Report the event the failed stage never took as lost
+			if held != nil {
+				state.deadLetter(held, DeadLetterStageFailed, core.MetaOf(held).Origin, node.Name(), err)
+			}
+			return err
+		}
+
+		// The stage may return right after reading the DoneEvent that hands
+		// over to a replacement, so wait for the relay before deciding
+		close(stop)
+		result := <-relayed
+		if result.swap {
+			continue
+		}
+		if result.held != nil {
+			state.deadLetter(result.held, DeadLetterInputClosed, core.MetaOf(result.held).Origin, node.Name(), nil)
+		}
+		return nil
+	}
+}
+
+// relayResult is how a relay ended
+type relayResult struct {
+	swap bool       // Stopped after a DoneEvent because a replacement is pending
+	held core.Event // Taken from the node's input but not delivered
+}
+
+// relayTurn forwards events from the node's input to the current stage run
+// until the input ends, the run is stopped or the turn ends with a
+// replacement pending. An event held from a previous run is forwarded first.
+func (p *Pipeline) relayTurn(ctx context.Context, name string, held core.Event, from <-chan core.Event, to chan<- core.Event, stop <-chan struct{}) relayResult {
+	for {
+		event := held
+		held = nil
+		if event == nil {
+			select {
+			case <-ctx.Done():
+				return relayResult{}
+			case <-stop:
+				return relayResult{}
+			case e, ok := <-from:
+				if !ok {
+					return relayResult{}
+				}
+				event = e
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return relayResult{held: event}
+		case <-stop:
+			return relayResult{held: event}
+		case to <- event:
+		}
+
+		if _, ok := event.(core.DoneEvent); ok {
+			if _, pending := p.pendingReplacement(name); pending {
+				return relayResult{swap: true}
+			}
+		}
+	}
+}
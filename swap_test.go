@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// taggingMockStage prefixes LLM deltas with its tag and forwards other events
+type taggingMockStage struct {
+	tag string
+}
+
+func (m *taggingMockStage) Name() string {
+	return "tagging"
+}
+
+func (m *taggingMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		if llm, ok := event.(core.LLMEvent); ok {
+			event = core.LLMEvent{Delta: m.tag + ":" + llm.Delta}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
+
+func (m *taggingMockStage) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+func (m *taggingMockStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// TestReplaceStageAtTurnBoundary tests that a running stage finishes its turn
+// before the replacement takes over
+func TestReplaceStageAtTurnBoundary(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("tts", &taggingMockStage{tag: "old"}).
+		SetEntryNode("tts").
+		AddExitNode("tts").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	output := pipeline.Execute(context.Background(), input)
+
+	next := func() core.Event {
+		t.Helper()
+		select {
+		case event := <-output:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for output")
+			return nil
+		}
+	}
+	expectDelta := func(want string) {
+		t.Helper()
+		event := next()
+		if llm, ok := event.(core.LLMEvent); !ok || llm.Delta != want {
+			t.Errorf("expected delta %q, got %#v", want, event)
+		}
+	}
+
+	input <- core.LLMEvent{Delta: "1"}
+	expectDelta("old:1")
+
+	if err := pipeline.ReplaceStage("tts", &taggingMockStage{tag: "new"}); err != nil {
+		t.Fatalf("ReplaceStage failed: %v", err)
+	}
+
+	// The current turn still belongs to the old stage
+	input <- core.LLMEvent{Delta: "2"}
+	expectDelta("old:2")
+	input <- core.DoneEvent{}
+	if _, ok := next().(core.DoneEvent); !ok {
+		t.Error("expected the old stage to finish the turn")
+	}
+
+	input <- core.LLMEvent{Delta: "3"}
+	expectDelta("new:3")
+
+	close(input)
+	for range output {
+	}
+}
+
+// TestReplaceStageIdle tests replacing stages of a pipeline that isn't running
+func TestReplaceStageIdle(t *testing.T) {
+	pipeline, err := Linear(&taggingMockStage{tag: "old"})
+	if err != nil {
+		t.Fatalf("Linear failed: %v", err)
+	}
+
+	replacement := &taggingMockStage{tag: "new"}
+	if err := pipeline.ReplaceStage("tagging", replacement); err != nil {
+		t.Fatalf("ReplaceStage failed: %v", err)
+	}
+	if pipeline.Graph().GetNode("tagging").Stage() != replacement {
+		t.Error("expected the replacement to take effect immediately")
+	}
+
+	if err := pipeline.ReplaceStage("missing", replacement); err == nil {
+		t.Error("expected error for unknown node")
+	}
+	if err := pipeline.ReplaceStage("tagging", nil); err == nil {
+		t.Error("expected error for nil stage")
+	}
+}
+
+// turnMockStage forwards one turn and returns after its DoneEvent
+type turnMockStage struct {
+	taggingMockStage
+}
+
+func (m *turnMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		if llm, ok := event.(core.LLMEvent); ok {
+			event = core.LLMEvent{Delta: m.tag + ":" + llm.Delta}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+		if _, ok := event.(core.DoneEvent); ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// TestReplaceStageAfterReturn tests that the replacement takes over when the
+// old stage returns before its relay sees the pending replacement
+func TestReplaceStageAfterReturn(t *testing.T) {
+	pipeline, err := NewBuilder().
+		AddStage("tts", &turnMockStage{taggingMockStage{tag: "old"}}).
+		SetEntryNode("tts").
+		AddExitNode("tts").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	output := pipeline.Execute(context.Background(), input)
+
+	input <- core.LLMEvent{Delta: "1"}
+	<-output
+	if err := pipeline.ReplaceStage("tts", &taggingMockStage{tag: "new"}); err != nil {
+		t.Fatalf("ReplaceStage failed: %v", err)
+	}
+
+	// Hold the relay's replacement check until the old stage has returned
+	pipeline.mu.Lock()
+	input <- core.DoneEvent{}
+	<-output
+	time.Sleep(20 * time.Millisecond)
+	pipeline.mu.Unlock()
+
+	select {
+	case input <- core.LLMEvent{Delta: "2"}:
+	case <-time.After(time.Second):
+		t.Fatal("expected the replacement to take the input")
+	}
+	select {
+	case event := <-output:
+		if llm, ok := event.(core.LLMEvent); !ok || llm.Delta != "new:2" {
+			t.Errorf("expected delta %q, got %#v", "new:2", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for output")
+	}
+
+	close(input)
+	for range output {
+	}
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TapFunc observes events for logging or inspection. It receives the events
+// after metadata stamping and must not modify them.
+type TapFunc func(event core.Event)
+
+// tapBufferSize is the number of events queued for a tap before it starts
+// missing events; taps never slow down the main path
+const tapBufferSize = 256
+
+// startTap runs fn on its own goroutine and returns the channel feeding it.
+// Closing the channel stops the tap once the queued events are delivered.
+func startTap(fn TapFunc, wg *sync.WaitGroup) chan<- core.Event {
+	events := make(chan core.Event, tapBufferSize)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range events {
+			callTap(fn, event)
+		}
+	}()
+	return events
+}
+
+// callTap calls fn, containing any panic so a broken observer can't fail the
+// pipeline
+func callTap(fn TapFunc, event core.Event) {
+	defer func() {
+		recover()
+	}()
+	fn(event)
+}
+
+// offerTap hands an event to a tap without blocking, dropping it if the
+// tap's queue is full
+func offerTap(tap chan<- core.Event, event core.Event) {
+	select {
+	case tap <- event:
+	default:
+	}
+}
+
+// TapStage is a pass-through stage that calls a TapFunc for every event, for
+// observing a linear chain of stages without the builder
+type TapStage struct {
+	name string
+	fn   TapFunc
+}
+
+// NewTapStage creates a tap stage
+func NewTapStage(name string, fn TapFunc) *TapStage {
+	return &TapStage{
+		name: name,
+		fn:   fn,
+	}
+}
+
+// Name returns the stage name
+func (ts *TapStage) Name() string {
+	return ts.name
+}
+
+// InputTypes returns the event types this stage accepts
+func (ts *TapStage) InputTypes() []core.EventType {
+	// Tap accepts all event types
+	return []core.EventType{}
+}
+
+// OutputTypes returns the event types this stage produces
+func (ts *TapStage) OutputTypes() []core.EventType {
+	// Tap passes everything through
+	return []core.EventType{}
+}
+
+// Process implements the Stage interface
+func (ts *TapStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	for event := range input {
+		callTap(ts.fn, event)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case output <- event:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestBuilderTap tests that a tap sees a node's events without changing what
+// reaches the exit node
+func TestBuilderTap(t *testing.T) {
+	var mu sync.Mutex
+	var tapped []core.EventType
+
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		AddStage("sink", &CollectingMockStage{name: "sink"}).
+		Connect("source", "sink").
+		SetEntryNode("source").
+		AddExitNode("sink").
+		Tap("source", func(event core.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			tapped = append(tapped, event.EventType())
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 2)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	var out []core.Event
+	for event := range pipeline.Execute(context.Background(), input) {
+		out = append(out, event)
+	}
+
+	if len(out) != 2 {
+		t.Errorf("expected 2 events at the exit, got %d", len(out))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tapped) != 2 || tapped[0] != core.EventTypeLLM || tapped[1] != core.EventTypeDone {
+		t.Errorf("unexpected tapped events: %v", tapped)
+	}
+}
+
+// TestTapDoesNotBlock tests that a slow or panicking tap doesn't hold up the
+// main path
+func TestTapDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	pipeline, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		SetEntryNode("source").
+		AddExitNode("source").
+		Tap("source", func(event core.Event) {
+			<-release
+			panic("broken tap")
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event, 3)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.LLMEvent{Delta: "b"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	output := pipeline.Execute(context.Background(), input)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-output:
+		case <-time.After(time.Second):
+			t.Fatalf("main path blocked by tap after %d events", i)
+		}
+	}
+
+	close(release)
+	for range output {
+	}
+}
+
+// TestTapUnknownNode tests that tapping a missing node fails the build
+func TestTapUnknownNode(t *testing.T) {
+	_, err := NewBuilder().
+		AddStage("source", &CollectingMockStage{name: "source"}).
+		SetEntryNode("source").
+		AddExitNode("source").
+		Tap("missing", func(core.Event) {}).
+		Build()
+	if err == nil {
+		t.Error("expected error for tap on unknown node")
+	}
+}
+
+// TestTapStage tests the pass-through tap stage
+func TestTapStage(t *testing.T) {
+	var count int
+	stage := NewTapStage("debug", func(core.Event) { count++ })
+
+	input := make(chan core.Event, 2)
+	output := make(chan core.Event, 2)
+	input <- core.LLMEvent{Delta: "a"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	if err := stage.Process(context.Background(), input, output); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if count != 2 || len(output) != 2 {
+		t.Errorf("expected 2 tapped and 2 forwarded events, got %d and %d", count, len(output))
+	}
+}
@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// StallingMockStage blocks until its context is cancelled, like a stalled provider
+type StallingMockStage struct {
+	name string
+}
+
+func (m *StallingMockStage) Name() string {
+	return m.name
+}
+
+func (m *StallingMockStage) Process(ctx context.Context, input <-chan core.Event, output chan<- core.Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *StallingMockStage) InputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+func (m *StallingMockStage) OutputTypes() []core.EventType {
+	return []core.EventType{}
+}
+
+// TestPipelineStageTimeout tests that a stalled stage fails with a typed timeout
+func TestPipelineStageTimeout(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	pipeline, err := NewBuilder().
+		AddStage("llm", &StallingMockStage{name: "llm"}).
+		SetEntryNode("llm").
+		AddExitNode("llm").
+		WithStageTimeout("llm", 50*time.Millisecond).
+		WithTracer(tracer).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	defer close(input)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range pipeline.Execute(context.Background(), input) {
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline hung despite stage timeout")
+	}
+
+	span := tracer.span("pipeline.stage llm")
+	if span == nil || !errors.Is(span.err, core.ErrStageTimeout) {
+		t.Fatalf("expected stage timeout error, got %v", span.err)
+	}
+
+	var timeoutErr *core.StageTimeoutError
+	if !errors.As(span.err, &timeoutErr) || timeoutErr.Stage != "llm" {
+		t.Errorf("expected StageTimeoutError for llm, got %v", span.err)
+	}
+}
+
+// TestBuilderStageTimeoutUnknownNode tests that timeouts for missing nodes are rejected
+func TestBuilderStageTimeoutUnknownNode(t *testing.T) {
+	_, err := NewBuilder().
+		AddStage("llm", &MockStage{name: "llm"}).
+		SetEntryNode("llm").
+		AddExitNode("llm").
+		WithStageTimeout("tts", time.Second).
+		Build()
+	if err == nil {
+		t.Error("expected error for timeout on unknown node")
+	}
+}
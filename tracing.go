@@ -0,0 +1,58 @@
+package pipeline
+
+import "context"
+
+// Attribute is a key-value pair attached to a span
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr creates a span attribute
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans for pipeline and stage executions.
+// It mirrors the subset of the OpenTelemetry trace API used by the runtime,
+// so an OTel tracer can be plugged in with a thin adapter:
+//
+//	func (a otelAdapter) Start(ctx context.Context, name string, attrs ...pipeline.Attribute) (context.Context, pipeline.Span) {
+//		ctx, span := a.tracer.Start(ctx, name, trace.WithAttributes(toKeyValues(attrs)...))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	// Start creates a span as a child of any span found in ctx
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Span attribute keys set by the runtime
+const (
+	AttrStageName      = "pipeline.stage.name"
+	AttrEventsIn       = "pipeline.stage.events_in"
+	AttrEventsOut      = "pipeline.stage.events_out"
+	AttrEventsDropped  = "pipeline.stage.events_dropped"
+	AttrStageError     = "pipeline.stage.error"
+	AttrPipelineStages = "pipeline.stages"
+)
+
+// noopTracer is used when no tracer is configured
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// noopSpan discards all span data
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// recordingTracer records all spans for inspection
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &recordingSpan{name: name, attrs: make(map[string]any)}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(attrs...)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+func (t *recordingTracer) span(name string) *recordingSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, span := range t.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+type recordingSpanKey struct{}
+
+// recordingSpan records attributes, errors, and whether it ended
+type recordingSpan struct {
+	mu     sync.Mutex
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// TestPipelineTracingStageSpans tests that every stage execution creates a span
+// linked to the pipeline span with event counts
+func TestPipelineTracingStageSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	pipeline, err := NewBuilder().
+		AddStage("first", &CollectingMockStage{name: "first"}).
+		AddStage("second", &CollectingMockStage{name: "second"}).
+		Connect("first", "second").
+		SetEntryNode("first").
+		AddExitNode("second").
+		WithTracer(tracer).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	input := make(chan core.Event, 3)
+	input <- core.STTEvent{Text: "a"}
+	input <- core.STTEvent{Text: "b"}
+	input <- core.DoneEvent{}
+	close(input)
+
+	var received int
+	for range pipeline.Execute(ctx, input) {
+		received++
+	}
+
+	if received != 3 {
+		t.Fatalf("expected 3 output events, got %d", received)
+	}
+
+	if span := tracer.span("pipeline.execute"); span == nil || !span.ended {
+		t.Fatal("expected ended pipeline span")
+	}
+
+	for _, name := range []string{"first", "second"} {
+		span := tracer.span("pipeline.stage " + name)
+		if span == nil {
+			t.Fatalf("missing span for stage %s", name)
+		}
+		if !span.ended {
+			t.Errorf("span for stage %s not ended", name)
+		}
+		if span.parent != "pipeline.execute" {
+			t.Errorf("span for stage %s not linked to pipeline span", name)
+		}
+		if span.attrs[AttrEventsIn] != int64(3) || span.attrs[AttrEventsOut] != int64(3) {
+			t.Errorf("stage %s: unexpected event counts %v", name, span.attrs)
+		}
+	}
+}
+
+// TestPipelineTracingRecordsStageError tests that stage errors are recorded on spans
+func TestPipelineTracingRecordsStageError(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	pipeline, err := NewBuilder().
+		AddStage("failing", &FailingMockStage{name: "failing"}).
+		SetEntryNode("failing").
+		AddExitNode("failing").
+		WithTracer(tracer).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	input := make(chan core.Event)
+	close(input)
+
+	for range pipeline.Execute(context.Background(), input) {
+	}
+
+	span := tracer.span("pipeline.stage failing")
+	if span == nil || span.err == nil || span.attrs[AttrStageError] != true {
+		t.Fatal("expected stage error to be recorded on span")
+	}
+	if pipelineSpan := tracer.span("pipeline.execute"); pipelineSpan.err == nil {
+		t.Error("expected error to be recorded on pipeline span")
+	}
+}
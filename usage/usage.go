@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"sync"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// Price holds the rates of a provider (or provider model)
+type Price struct {
+	InputPerMillionTokens  float64
+	OutputPerMillionTokens float64
+	PerAudioMinute         float64
+	PerMillionCharacters   float64
+}
+
+// Cost returns the cost of the usage reported by an event at this price
+func (p Price) Cost(event core.UsageEvent) float64 {
+	return float64(event.InputTokens)/1e6*p.InputPerMillionTokens +
+		float64(event.OutputTokens)/1e6*p.OutputPerMillionTokens +
+		event.AudioSeconds/60*p.PerAudioMinute +
+		float64(event.Characters)/1e6*p.PerMillionCharacters
+}
+
+// PriceTable maps a provider name, or "provider/model", to its price.
+// A "provider/model" entry takes precedence over the provider entry.
+type PriceTable map[string]Price
+
+// Lookup returns the price for a provider and model
+func (t PriceTable) Lookup(provider, model string) (Price, bool) {
+	if model != "" {
+		if price, ok := t[provider+"/"+model]; ok {
+			return price, true
+		}
+	}
+	price, ok := t[provider]
+	return price, ok
+}
+
+// Cost returns the cost of the usage reported by an event, or zero if the
+// provider has no price
+func (t PriceTable) Cost(event core.UsageEvent) float64 {
+	price, ok := t.Lookup(event.Provider, event.Model)
+	if !ok {
+		return 0
+	}
+	return price.Cost(event)
+}
+
+// Accumulator sums usage events into a summary. It is safe for concurrent use.
+type Accumulator struct {
+	prices  PriceTable
+	mu      sync.Mutex
+	summary core.UsageSummary
+}
+
+// NewAccumulator creates an accumulator that prices usage with prices
+func NewAccumulator(prices PriceTable) *Accumulator {
+	return &Accumulator{prices: prices}
+}
+
+// Add records a usage event
+func (a *Accumulator) Add(event core.UsageEvent) {
+	cost := a.prices.Cost(event)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.summary.UsageTotals.Add(event, cost)
+
+	if a.summary.ByProvider == nil {
+		a.summary.ByProvider = make(map[string]core.UsageTotals)
+	}
+	totals := a.summary.ByProvider[event.Provider]
+	totals.Add(event, cost)
+	a.summary.ByProvider[event.Provider] = totals
+}
+
+// Summary returns a copy of the usage recorded so far
+func (a *Accumulator) Summary() core.UsageSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summary := core.UsageSummary{UsageTotals: a.summary.UsageTotals}
+	if a.summary.ByProvider != nil {
+		summary.ByProvider = make(map[string]core.UsageTotals, len(a.summary.ByProvider))
+		for provider, totals := range a.summary.ByProvider {
+			summary.ByProvider[provider] = totals
+		}
+	}
+	return summary
+}
+
+// Reset clears the recorded usage, starting a new turn
+func (a *Accumulator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.summary = core.UsageSummary{}
+}
@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/creastat/pipeline/core"
+)
+
+// TestPriceTableCost tests that model prices take precedence over provider prices
+func TestPriceTableCost(t *testing.T) {
+	prices := PriceTable{
+		"openai":             {InputPerMillionTokens: 1, OutputPerMillionTokens: 2},
+		"openai/gpt-4o-mini": {InputPerMillionTokens: 0.5, OutputPerMillionTokens: 1},
+		"deepgram":           {PerAudioMinute: 0.6},
+		"elevenlabs":         {PerMillionCharacters: 100},
+	}
+
+	tests := []struct {
+		event core.UsageEvent
+		want  float64
+	}{
+		{core.UsageEvent{Provider: "openai", Model: "gpt-4o", InputTokens: 1_000_000, OutputTokens: 500_000}, 2},
+		{core.UsageEvent{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 1_000_000, OutputTokens: 500_000}, 1},
+		{core.UsageEvent{Provider: "deepgram", AudioSeconds: 30}, 0.3},
+		{core.UsageEvent{Provider: "elevenlabs", Characters: 1000}, 0.1},
+		{core.UsageEvent{Provider: "unknown", InputTokens: 1000}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := prices.Cost(tt.event); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cost(%+v) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+// TestAccumulator tests totals per turn and per provider
+func TestAccumulator(t *testing.T) {
+	acc := NewAccumulator(PriceTable{"llm": {OutputPerMillionTokens: 1_000_000}})
+
+	acc.Add(core.UsageEvent{Provider: "llm", InputTokens: 10, OutputTokens: 2})
+	acc.Add(core.UsageEvent{Provider: "llm", OutputTokens: 3})
+	acc.Add(core.UsageEvent{Provider: "tts", Characters: 40})
+
+	summary := acc.Summary()
+	if summary.InputTokens != 10 || summary.OutputTokens != 5 || summary.Characters != 40 {
+		t.Errorf("unexpected totals: %+v", summary.UsageTotals)
+	}
+	if summary.Cost != 5 {
+		t.Errorf("expected cost 5, got %v", summary.Cost)
+	}
+	if llm := summary.ByProvider["llm"]; llm.OutputTokens != 5 || llm.Cost != 5 {
+		t.Errorf("unexpected llm totals: %+v", llm)
+	}
+	if tts := summary.ByProvider["tts"]; tts.Characters != 40 || tts.Cost != 0 {
+		t.Errorf("unexpected tts totals: %+v", tts)
+	}
+
+	acc.Reset()
+	if summary := acc.Summary(); summary.Cost != 0 || summary.ByProvider != nil {
+		t.Errorf("expected empty summary after reset, got %+v", summary)
+	}
+}
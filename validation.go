@@ -1,7 +1,10 @@
 package pipeline
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+
 	"github.com/creastat/pipeline/core"
 )
 
@@ -18,32 +21,71 @@ func (e ValidationError) Error() string {
 	return e.Message
 }
 
-// ValidateGraph performs comprehensive validation on a pipeline graph
+// ValidationReport separates problems that stop a graph from running from
+// warnings about graphs that run but probably don't do what was intended
+type ValidationReport struct {
+	Errors   []ValidationError
+	Warnings []ValidationError
+}
+
+// Err returns the first error, or nil if the graph is valid
+func (r ValidationReport) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors[0]
+}
+
+// addError records err if it isn't nil
+func (r *ValidationReport) addError(err error) {
+	if err == nil {
+		return
+	}
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		validationErr = ValidationError{Message: "graph validation failed", Details: err.Error()}
+	}
+	r.Errors = append(r.Errors, validationErr)
+}
+
+// ValidateGraph performs comprehensive validation on a pipeline graph,
+// returning the first fatal problem. Use ValidateGraphDetailed for all
+// problems and warnings.
 func ValidateGraph(graph *PipelineGraph) error {
+	return ValidateGraphDetailed(graph).Err()
+}
+
+// ValidateGraphDetailed validates a pipeline graph and reports every fatal
+// problem along with warnings
+func ValidateGraphDetailed(graph *PipelineGraph) ValidationReport {
+	var report ValidationReport
+
 	// Check that entry node exists
 	if graph.GetEntryNode() == nil {
-		return ValidationError{
+		report.addError(ValidationError{
 			Message: "graph validation failed",
 			Details: "no entry node defined",
-		}
+		})
+		return report
 	}
 	
-	// Check for cycles
+	// Check for cycles, which the remaining checks can't handle
 	if err := detectCycles(graph); err != nil {
-		return err
+		report.addError(err)
+		return report
 	}
 	
 	// Check for unreachable stages
-	if err := checkReachability(graph); err != nil {
-		return err
-	}
+	report.addError(checkReachability(graph))
 	
 	// Check type compatibility
-	if err := validateTypeCompatibility(graph); err != nil {
-		return err
-	}
+	report.addError(validateTypeCompatibility(graph))
 	
-	return nil
+	// Check that every exit receives a DoneEvent
+	report.addError(validateDoneContract(graph))
+
+	report.Warnings = graphWarnings(graph)
+	return report
 }
 
 // detectCycles uses depth-first search to detect cycles in the graph
@@ -56,7 +98,7 @@ func detectCycles(graph *PipelineGraph) error {
 			if hasCycle(node, visited, recStack) {
 				return ValidationError{
 					Message: "graph validation failed",
-					Details: "cycle detected in pipeline graph",
+					Details: "cycle detected in pipeline graph, use AddLoop for iterative subgraphs",
 				}
 			}
 		}
@@ -217,3 +259,209 @@ func hasCompatibleType(upstreamTypes, downstreamTypes []core.EventType, filter m
 	
 	return false
 }
+
+// validateDoneContract checks that every path from the entry node to an exit
+// node passes through a stage that declares EventTypeDone among its outputs,
+// with no edge after it filtering DoneEvents out. Stages with empty output
+// types produce all types and satisfy the contract. A barrier receiving a
+// DoneEvent on any of its inputs emits its own on every path through it.
+func validateDoneContract(graph *PipelineGraph) error {
+	type state struct {
+		node    string
+		hasDone bool
+	}
+	visited := make(map[state]bool)
+	joined := make(map[string]bool)
+
+	var walk func(node *graphNode, hasDone bool, path []string) error
+	walk = func(node *graphNode, hasDone bool, path []string) error {
+		hasDone = hasDone || producesDone(node) || barrierJoinsDone(node, joined)
+		path = append(path, node.Name())
+		if visited[state{node.Name(), hasDone}] {
+			return nil
+		}
+		visited[state{node.Name(), hasDone}] = true
+
+		if !hasDone && isExitNode(graph, node) {
+			return ValidationError{
+				Message: "graph validation failed",
+				Details: fmt.Sprintf("no stage produces a DoneEvent on path %v", path),
+			}
+		}
+
+		for _, edge := range node.Outputs() {
+			if err := walk(edge.To(), hasDone && edge.ShouldForwardEvent(core.EventTypeDone), path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return walk(graph.GetEntryNode(), false, nil)
+}
+
+// producesDone reports whether a node's stage, or every branch of a fan-out
+// node, declares DoneEvents among its outputs. Barriers only forward what
+// their upstreams produce.
+func producesDone(node *graphNode) bool {
+	if fanOut := node.FanOut(); fanOut != nil {
+		for _, branch := range fanOut.Branches {
+			if !stageProducesDone(branch.Stage) {
+				return false
+			}
+		}
+		return len(fanOut.Branches) > 0
+	}
+	if node.Stage() == nil {
+		return false
+	}
+	return stageProducesDone(node.Stage())
+}
+
+// barrierJoinsDone reports whether a node is a barrier with an upstream
+// sending it DoneEvents, memoized in joined
+func barrierJoinsDone(node *graphNode, joined map[string]bool) bool {
+	if barrierConfig(node) == nil {
+		return false
+	}
+	if result, ok := joined[node.Name()]; ok {
+		return result
+	}
+	joined[node.Name()] = false
+	for _, edge := range node.Inputs() {
+		from := edge.From()
+		if edge.ShouldForwardEvent(core.EventTypeDone) && (producesDone(from) || barrierJoinsDone(from, joined)) {
+			joined[node.Name()] = true
+			break
+		}
+	}
+	return joined[node.Name()]
+}
+
+// stageProducesDone reports whether a stage declares DoneEvents among its
+// outputs, empty output types meaning all types
+func stageProducesDone(stage core.Stage) bool {
+	outputTypes := stage.OutputTypes()
+	if len(outputTypes) == 0 {
+		return true
+	}
+	for _, t := range outputTypes {
+		if t == core.EventTypeDone || t == core.EventTypeWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// isExitNode reports whether node is one of the graph's exit nodes
+func isExitNode(graph *PipelineGraph, node *graphNode) bool {
+	for _, exit := range graph.GetExitNodes() {
+		if exit == node {
+			return true
+		}
+	}
+	return false
+}
+
+// graphWarnings reports problems that don't stop a graph from running but
+// usually indicate a mistake
+func graphWarnings(graph *PipelineGraph) []ValidationError {
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	exits := make(map[string]int)
+	for _, exit := range graph.GetExitNodes() {
+		exits[exit.Name()]++
+	}
+	if len(exits) == 0 {
+		warn("no exit nodes defined, the pipeline produces no output")
+	}
+	for name, count := range exits {
+		if count > 1 {
+			warn("exit node %q is declared %d times", name, count)
+		}
+	}
+
+	for _, node := range graph.AllNodes() {
+		if len(node.Outputs()) == 0 && exits[node.Name()] == 0 {
+			warn("stage %q has no outgoing edges and is not an exit node, its output is discarded", node.Name())
+		}
+
+		if node.Stage() != nil {
+			outputTypes := node.Stage().OutputTypes()
+			for _, edge := range node.Outputs() {
+				if len(outputTypes) > 0 && edge.EventFilter() != nil && !forwardsAny(outputTypes, edge.EventFilter()) {
+					warn("edge %q -> %q filters out all output types of %q (%v)", node.Name(), edge.To().Name(), node.Name(), outputTypes)
+				}
+			}
+		}
+
+		if barrier := barrierConfig(node); barrier != nil {
+			if upstreams := barrierUpstreams(node); upstreams != barrier.UpstreamCount {
+				warn("barrier %q waits for %d upstreams but has %d", node.Name(), barrier.UpstreamCount, upstreams)
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	report := make([]ValidationError, 0, len(warnings))
+	for _, details := range warnings {
+		report = append(report, ValidationError{Message: "graph validation warning", Details: details})
+	}
+	return report
+}
+
+// forwardsAny reports whether an edge filter passes any of the given types
+func forwardsAny(types []core.EventType, filter map[core.EventType]bool) bool {
+	for _, t := range types {
+		if filter[t] || t == core.EventTypeWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// barrierConfig returns the configuration of a barrier node, whether added
+// with AddBarrier or as a BarrierStage, or nil for other nodes
+func barrierConfig(node *graphNode) *core.BarrierConfig {
+	if node.Barrier() != nil {
+		return node.Barrier()
+	}
+	if barrier, ok := node.Stage().(*BarrierStage); ok {
+		return barrier.config
+	}
+	return nil
+}
+
+// fanOutConfig returns the configuration of a fan-out node, whether added
+// with AddFanOut or as a FanOutStage, or nil for other nodes
+func fanOutConfig(node *graphNode) *core.FanOutConfig {
+	if node.FanOut() != nil {
+		return node.FanOut()
+	}
+	if fanOut, ok := node.Stage().(*FanOutStage); ok {
+		return fanOut.config
+	}
+	return nil
+}
+
+// barrierUpstreams counts the branches feeding a barrier, each sending one
+// DoneEvent: one per incoming edge, or one per branch of an upstream fan-out.
+// Edges filtering DoneEvents out, such as transcripts passed through, don't
+// count.
+func barrierUpstreams(node *graphNode) int {
+	upstreams := 0
+	for _, edge := range node.Inputs() {
+		if !edge.ShouldForwardEvent(core.EventTypeDone) {
+			continue
+		}
+		if fanOut := fanOutConfig(edge.From()); fanOut != nil {
+			upstreams += len(fanOut.Branches)
+		} else {
+			upstreams++
+		}
+	}
+	return upstreams
+}